@@ -2,7 +2,12 @@ package repository
 
 import (
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	credential "github.com/fabric8-services/fabric8-auth/authentication/credential/repository"
+	impersonation "github.com/fabric8-services/fabric8-auth/authentication/impersonation/repository"
+	mfa "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
 	provider "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
+	accessreport "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	accessrequest "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
 	invitation "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
@@ -17,9 +22,11 @@ type Repositories interface {
 	Identities() account.IdentityRepository
 	Users() account.UserRepository
 	OauthStates() provider.OauthStateReferenceRepository
+	SAMLAssertionRepository() provider.SAMLAssertionRepository
 	ExternalTokens() token.ExternalTokenRepository
 	VerificationCodes() account.VerificationCodeRepository
 	InvitationRepository() invitation.InvitationRepository
+	InvitationImportRepository() invitation.InvitationImportRepository
 	ResourceRepository() resource.ResourceRepository
 	ResourceTypeRepository() resourcetype.ResourceTypeRepository
 	ResourceTypeScopeRepository() resourcetype.ResourceTypeScopeRepository
@@ -27,7 +34,15 @@ type Repositories interface {
 	RoleRepository() role.RoleRepository
 	DefaultRoleMappingRepository() role.DefaultRoleMappingRepository
 	RoleMappingRepository() role.RoleMappingRepository
+	DenyAssignmentRepository() role.DenyAssignmentRepository
 	TokenRepository() token.TokenRepository
 	PrivilegeCacheRepository() permission.PrivilegeCacheRepository
 	WorkerLockRepository() worker.LockRepository
+	CredentialRepository() credential.CredentialRepository
+	MFAEnrolmentRepository() mfa.EnrolmentRepository
+	MFARecoveryCodeRepository() mfa.RecoveryCodeRepository
+	MFAChallengeRepository() mfa.ChallengeRepository
+	ImpersonationSessionRepository() impersonation.SessionRepository
+	AccessReportRepository() accessreport.AccessReportRepository
+	AccessRequestRepository() accessrequest.AccessRequestRepository
 }
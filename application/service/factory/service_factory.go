@@ -9,13 +9,22 @@ import (
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	credentialservice "github.com/fabric8-services/fabric8-auth/authentication/credential/service"
+	impersonationservice "github.com/fabric8-services/fabric8-auth/authentication/impersonation/service"
 	logoutservice "github.com/fabric8-services/fabric8-auth/authentication/logout/service"
+	mfaservice "github.com/fabric8-services/fabric8-auth/authentication/mfa/service"
 	providerservice "github.com/fabric8-services/fabric8-auth/authentication/provider/service"
 	subscriptionservice "github.com/fabric8-services/fabric8-auth/authentication/subscription/service"
+	accessreportservice "github.com/fabric8-services/fabric8-auth/authorization/accessreport/service"
+	accessrequestservice "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/service"
+	groupservice "github.com/fabric8-services/fabric8-auth/authorization/group/service"
 	invitationservice "github.com/fabric8-services/fabric8-auth/authorization/invitation/service"
+	membershipservice "github.com/fabric8-services/fabric8-auth/authorization/membership/service"
 	organizationservice "github.com/fabric8-services/fabric8-auth/authorization/organization/service"
+	permissioncache "github.com/fabric8-services/fabric8-auth/authorization/permission/cache"
 	permissionservice "github.com/fabric8-services/fabric8-auth/authorization/permission/service"
 	resourceservice "github.com/fabric8-services/fabric8-auth/authorization/resource/service"
+	resourcetypeservice "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/service"
 	roleservice "github.com/fabric8-services/fabric8-auth/authorization/role/service"
 	spaceservice "github.com/fabric8-services/fabric8-auth/authorization/space/service"
 	teamservice "github.com/fabric8-services/fabric8-auth/authorization/team/service"
@@ -142,6 +151,7 @@ type ServiceFactory struct {
 	clusterServiceFunc      func() service.ClusterService
 	authProviderServiceFunc func() service.AuthenticationProviderService
 	userServiceFunc         func() service.UserService
+	privilegeCache          *permissioncache.PrivilegeCache // the in-memory privilege cache shared by all the requests, if any
 }
 
 // Option an option to configure the Service Factory
@@ -199,6 +209,13 @@ func WithUserService(s service.UserService) Option {
 	}
 }
 
+// WithPrivilegeCache sets the in-memory cache used by the PrivilegeCacheService in front of the privilege cache table
+func WithPrivilegeCache(c *permissioncache.PrivilegeCache) Option {
+	return func(f *ServiceFactory) {
+		f.privilegeCache = c
+	}
+}
+
 // NewServiceFactory returns a new ServiceFactory which can be configured with the options to replace the default implementations of some services
 func NewServiceFactory(producer servicecontext.ServiceContextProducer, config *configuration.ConfigurationData, options ...Option) *ServiceFactory {
 	f := &ServiceFactory{contextProducer: producer, config: config}
@@ -238,10 +255,38 @@ func (f *ServiceFactory) getContext() servicecontext.ServiceContext {
 	return f.contextProducer()
 }
 
+func (f *ServiceFactory) AccessReportService() service.AccessReportService {
+	return accessreportservice.NewAccessReportService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) AccessRequestService() service.AccessRequestService {
+	return accessrequestservice.NewAccessRequestService(f.getContext())
+}
+
 func (f *ServiceFactory) AuthenticationProviderService() service.AuthenticationProviderService {
 	return f.authProviderServiceFunc()
 }
 
+func (f *ServiceFactory) CredentialService() service.CredentialService {
+	return credentialservice.NewCredentialService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) GroupService() service.GroupService {
+	return groupservice.NewGroupService(f.getContext())
+}
+
+func (f *ServiceFactory) MembershipService() service.MembershipService {
+	return membershipservice.NewMembershipService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) MFAService() service.MFAService {
+	return mfaservice.NewMFAService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) ImpersonationService() service.ImpersonationService {
+	return impersonationservice.NewImpersonationService(f.getContext(), f.config)
+}
+
 func (f *ServiceFactory) InvitationService() service.InvitationService {
 	return invitationservice.NewInvitationService(f.getContext(), f.config)
 }
@@ -267,13 +312,17 @@ func (f *ServiceFactory) PermissionService() service.PermissionService {
 }
 
 func (f *ServiceFactory) PrivilegeCacheService() service.PrivilegeCacheService {
-	return permissionservice.NewPrivilegeCacheService(f.getContext(), f.config)
+	return permissionservice.NewPrivilegeCacheService(f.getContext(), f.config, f.privilegeCache)
 }
 
 func (f *ServiceFactory) ResourceService() service.ResourceService {
 	return resourceservice.NewResourceService(f.getContext())
 }
 
+func (f *ServiceFactory) ResourceTypeService() service.ResourceTypeService {
+	return resourcetypeservice.NewResourceTypeService(f.getContext())
+}
+
 func (f *ServiceFactory) RoleManagementService() service.RoleManagementService {
 	return roleservice.NewRoleManagementService(f.getContext())
 }
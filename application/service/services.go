@@ -2,17 +2,25 @@ package service
 
 import (
 	"context"
+	"io"
 	"net/url"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/fabric8-services/fabric8-auth/app"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	impersonationrepo "github.com/fabric8-services/fabric8-auth/authentication/impersonation/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	mfarepo "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	"github.com/fabric8-services/fabric8-auth/authorization"
+	accessreportrepo "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	accessrequestrepo "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
+	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/resourcetype"
 	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
@@ -45,6 +53,37 @@ Steps for adding a new Service:
    and use the factory method from the step #4
 */
 
+// AccessReportService generates the reports of the effective scopes of every identity for a resource and all its
+// descendants, for the compliance reviews
+type AccessReportService interface {
+	// Request creates a pending access report of the resource and all its descendants, generated later by a worker
+	Request(ctx context.Context, currentIdentity uuid.UUID, resourceID string, format string) (*accessreportrepo.AccessReport, error)
+	// Load returns an access report requested by the current identity
+	Load(ctx context.Context, currentIdentity uuid.UUID, reportID uuid.UUID) (*accessreportrepo.AccessReport, error)
+	// WriteContent writes the content of a completed access report
+	WriteContent(ctx context.Context, w io.Writer, report accessreportrepo.AccessReport) error
+	// Write writes the effective scopes of every identity for the resource and all its descendants in the given format
+	Write(ctx context.Context, w io.Writer, resourceID string, format string) error
+	// GeneratePendingReports generates the content of the pending access reports
+	GeneratePendingReports(ctx context.Context) error
+	// DeleteExpired deletes the access reports which were completed or failed before the retention period
+	DeleteExpired(ctx context.Context) error
+}
+
+// AccessRequestService manages the requests of users for a role on a resource, which the administrators of the
+// resource approve or deny
+type AccessRequestService interface {
+	// Request creates a pending request of the current identity for a role on the resource, and notifies the
+	// administrators of the resource
+	Request(ctx context.Context, currentIdentity uuid.UUID, resourceID string, roleName string, message string) (*accessrequestrepo.AccessRequest, error)
+	// ListPending returns the pending requests for the resource, which only its administrators can see
+	ListPending(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]accessrequestrepo.AccessRequest, error)
+	// Approve assigns the requested role to the user who requested it, and notifies the user
+	Approve(ctx context.Context, currentIdentity uuid.UUID, resourceID string, requestID uuid.UUID) (*accessrequestrepo.AccessRequest, error)
+	// Deny rejects the request with an optional reason, and notifies the user who requested it
+	Deny(ctx context.Context, currentIdentity uuid.UUID, resourceID string, requestID uuid.UUID, reason string) (*accessrequestrepo.AccessRequest, error)
+}
+
 type AuthenticationProviderService interface {
 	AuthorizeCallback(ctx context.Context, state string, code string) (*string, error)
 	CreateOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
@@ -55,9 +94,15 @@ type AuthenticationProviderService interface {
 	GenerateAuthCodeURL(ctx context.Context, redirect *string, apiClient *string,
 		state *string, scopes []string, responseMode *string, referrer string, callbackURL string) (*string, error)
 	LoginCallback(ctx context.Context, state string, code string, redirectURL string) (*string, error)
+	// LoginChallengeCallback completes a login once the user has entered their second factor
+	LoginChallengeCallback(ctx context.Context, challengeID uuid.UUID, code string) (*string, []string, error)
 	LoadReferrerAndResponseMode(ctx context.Context, state string) (string, *string, error)
 	SaveReferrer(ctx context.Context, state string, referrer string,
 		responseMode *string, validReferrerURL string) error
+	// CreateState saves the referrer and response mode of a login or an account link, and returns the value of the
+	// state parameter to send to the provider
+	CreateState(ctx context.Context, state string, referrer string,
+		responseMode *string, validReferrerURL string) (string, error)
 }
 
 type ClusterService interface {
@@ -69,6 +114,32 @@ type ClusterService interface {
 	Stop()
 }
 
+// CredentialService manages the local (username/password) credentials of users
+type CredentialService interface {
+	// Authenticate verifies the username, or verified email address, and password and returns a one-time login code for
+	// the local identity provider
+	Authenticate(ctx context.Context, username string, password string) (string, error)
+	// CheckLoginCode returns an error if the login code is unknown or expired
+	CheckLoginCode(ctx context.Context, code string) error
+	// RedeemLoginCode consumes the login code and returns the profile of the user it was issued for
+	RedeemLoginCode(ctx context.Context, code string) (*provider.UserProfile, error)
+	SetPassword(ctx context.Context, identityID uuid.UUID, password string) error
+	ChangePassword(ctx context.Context, identityID uuid.UUID, currentPassword string, newPassword string) error
+	SendPasswordReset(ctx context.Context, usernameOrEmail string) error
+	ResetPassword(ctx context.Context, code string, newPassword string) error
+	ValidatePassword(password string) error
+}
+
+// ImpersonationService issues short-lived tokens which let user administrators act as another user, and records
+// an audit trail of these sessions
+type ImpersonationService interface {
+	// Impersonate issues an access token for the target identity on behalf of the administrator. The token carries
+	// an `act` claim identifying the administrator and cannot be refreshed.
+	Impersonate(ctx context.Context, adminIdentityID, targetIdentityID uuid.UUID, reason string) (*manager.TokenSet, error)
+	// ListSessions returns the impersonation sessions of the target identity, most recent first
+	ListSessions(ctx context.Context, adminIdentityID, targetIdentityID uuid.UUID) ([]impersonationrepo.Session, error)
+}
+
 type InvitationService interface {
 	// Issue creates a new invitation for a user.
 	Issue(ctx context.Context, issuingUserID uuid.UUID, inviteTo string, invitations []invitation.Invitation) error
@@ -76,6 +147,29 @@ type InvitationService interface {
 	Rescind(ctx context.Context, rescindingUserID, invitationID uuid.UUID) error
 	// Accept processes the invitation acceptance action from the user, converting the invitation into real memberships/roles
 	Accept(ctx context.Context, token uuid.UUID) (string, string, error)
+	// ListPending returns the invitations to an organization, team, security group or resource which have not been accepted yet
+	ListPending(ctx context.Context, currentIdentityID uuid.UUID, inviteTo string) ([]invitation.PendingInvitation, error)
+	// Resend sends the invitation e-mail again with a new accept code, and extends the validity of the invitation
+	Resend(ctx context.Context, resendingUserID, invitationID uuid.UUID) error
+	// DeleteExpired deletes the invitations which can no longer be accepted
+	DeleteExpired(ctx context.Context) error
+	// ClaimInvitations attaches the invitations addressed to the verified email address of a user who just signed up
+	ClaimInvitations(ctx context.Context, identityID uuid.UUID) error
+	// ListForInvitee returns the invitations addressed to a user
+	ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]invitation.PendingInvitation, error)
+	// AcceptByID accepts an invitation on behalf of the user to which it is addressed
+	AcceptByID(ctx context.Context, identityID, invitationID uuid.UUID) (string, error)
+	// Decline deletes an invitation on behalf of the user to which it is addressed and notifies the inviter
+	Decline(ctx context.Context, identityID, invitationID uuid.UUID) error
+	// Import issues the invitations of a bulk import, in the `all-or-nothing` or `best-effort` mode, and reports the
+	// result of each row. Large imports are processed asynchronously.
+	Import(ctx context.Context, currentIdentityID uuid.UUID, rows []invitation.ImportRow, mode string, redirectOnSuccess, redirectOnFailure string) (*invitationrepo.InvitationImport, error)
+	// LoadImport returns a bulk invitation import, which only the identity who requested it can see
+	LoadImport(ctx context.Context, currentIdentityID, importID uuid.UUID) (*invitationrepo.InvitationImport, error)
+	// ProcessPendingImports processes the oldest bulk invitation imports which are still pending
+	ProcessPendingImports(ctx context.Context) error
+	// SendPendingEmails sends the next batch of the invitation e-mails queued on the bulk invitation imports
+	SendPendingEmails(ctx context.Context) error
 }
 
 // LinkService provides the ability to link 3rd party oauth accounts, such as Github and Openshift
@@ -84,6 +178,50 @@ type LinkService interface {
 	Callback(ctx context.Context, req *goa.RequestData, state string, code string) (string, error)
 }
 
+// MFAService manages the TOTP second factor of users and the challenges of logins which require it
+type MFAService interface {
+	// Enrol starts the enrolment of a new TOTP second factor, which is pending until it is confirmed with a valid code
+	Enrol(ctx context.Context, identityID uuid.UUID) (*mfa.PendingEnrolment, error)
+	// ConfirmEnrolment activates the pending enrolment and returns the recovery codes of the user
+	ConfirmEnrolment(ctx context.Context, identityID uuid.UUID, code string) ([]string, error)
+	Disable(ctx context.Context, identityID uuid.UUID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, identityID uuid.UUID, code string) ([]string, error)
+	// ChallengeRequired returns true if the user must enter a second factor to complete their login
+	ChallengeRequired(ctx context.Context, identityID uuid.UUID) (bool, error)
+	CreateChallenge(ctx context.Context, identityID uuid.UUID, referrer string, amr []string) (uuid.UUID, error)
+	// LoadChallenge returns the challenge, and a pending enrolment if the user must enrol before they can complete it
+	LoadChallenge(ctx context.Context, challengeID uuid.UUID) (*mfarepo.Challenge, *mfa.PendingEnrolment, error)
+	// CompleteChallenge verifies the code and consumes the challenge. If the user enrolled during the challenge
+	// their recovery codes are returned too.
+	CompleteChallenge(ctx context.Context, challengeID uuid.UUID, code string) (*mfarepo.Challenge, []string, error)
+	// StepUp verifies the code and issues new tokens of the multi-factor authentication context class
+	StepUp(ctx context.Context, identityID uuid.UUID, code string) (*manager.TokenSet, error)
+}
+
+// GroupService manages the security groups of the organizations, which are identities that may have users, teams and
+// other security groups as members, and may be assigned resource roles like any other identity
+type GroupService interface {
+	// CreateGroup creates a security group in the organization, and assigns its admin role to the creator
+	CreateGroup(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, groupName string) (*uuid.UUID, error)
+	// ListGroupsInOrganization returns the security groups of the organization
+	ListGroupsInOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) ([]account.Identity, error)
+	// ListMembers returns the direct members of the security group
+	ListMembers(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID) ([]account.Identity, error)
+	// AddMember adds a user, a team or another security group to the members of the security group
+	AddMember(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error
+	// RemoveMember removes a user, a team or another security group from the members of the security group
+	RemoveMember(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error
+	// AssignRole assigns a role of the resource to the security group
+	AssignRole(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, resourceID string, roleName string) error
+}
+
+// MembershipService manages the memberships of the identities, which may be nested to form chains of memberships
+type MembershipService interface {
+	// AddMember adds the member to the members of the identity, unless the membership would create a cycle of
+	// memberships or a chain of nested memberships longer than the configured maximum depth
+	AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error
+}
+
 type LogoutService interface {
 	Logout(ctx context.Context, redirectURL string) (string, error)
 }
@@ -96,6 +234,13 @@ type NotificationService interface {
 type OrganizationService interface {
 	CreateOrganization(ctx context.Context, creatorIdentityID uuid.UUID, organizationName string) (*uuid.UUID, error)
 	ListOrganizations(ctx context.Context, identityID uuid.UUID) ([]authorization.IdentityAssociation, error)
+	ListMembers(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) ([]account.Identity, []rolerepo.IdentityRole, error)
+	AddMember(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error
+	RemoveMember(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error
+	SetMemberRole(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID, roleName string) error
+	RenameOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, organizationName string) error
+	TransferOwnership(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, newOwnerID uuid.UUID) error
+	DeleteOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) error
 }
 
 type OSOSubscriptionService interface {
@@ -105,7 +250,11 @@ type OSOSubscriptionService interface {
 
 type PermissionService interface {
 	HasScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) (bool, error)
+	HasScopes(ctx context.Context, identityID uuid.UUID, checks []rolerepo.PermissionCheck) (map[rolerepo.PermissionCheck]bool, error)
 	RequireScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) error
+	RequireUserAdmin(ctx context.Context, identityID uuid.UUID) error
+	Explain(ctx context.Context, currentIdentity uuid.UUID, identityID uuid.UUID, resourceID string, scopeName string) (*rolerepo.PermissionExplanation, error)
+	FindGrantPaths(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) (*rolerepo.PermissionExplanation, error)
 }
 
 type PrivilegeCacheService interface {
@@ -118,15 +267,34 @@ type ResourceService interface {
 	CheckExists(ctx context.Context, resourceID string) error
 	Register(ctx context.Context, resourceTypeName string, resourceID, parentResourceID *string, identity *uuid.UUID) (*resource.Resource, error)
 	FindWithRoleByResourceTypeAndIdentity(ctx context.Context, resourceType string, identityID uuid.UUID) ([]string, error)
+	ListChildren(ctx context.Context, currentIdentity uuid.UUID, resourceID string, offset int, limit int) ([]resource.Resource, int, error)
+	FindAncestors(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]resource.Resource, error)
+	Reparent(ctx context.Context, currentIdentity uuid.UUID, resourceID string, parentResourceID string) error
+}
+
+// ResourceTypeService manages the resource types, and the roles and scopes they define
+type ResourceTypeService interface {
+	RegisterResourceType(ctx context.Context, ownerID uuid.UUID, definition resourcetype.ResourceTypeDescriptor) (*resourcetype.ResourceTypeDescriptor, error)
+	CreateRole(ctx context.Context, identityID uuid.UUID, resourceTypeName string, roleName string, scopeNames []string) (*role.RoleDescriptor, error)
+	UpdateRole(ctx context.Context, identityID uuid.UUID, roleID uuid.UUID, roleName string, scopeNames []string) (*role.RoleDescriptor, error)
+	DeleteRole(ctx context.Context, identityID uuid.UUID, roleID uuid.UUID) error
 }
 
 type RoleManagementService interface {
 	ListByResource(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]rolerepo.IdentityRole, error)
 	ListAvailableRolesByResourceType(ctx context.Context, resourceType string) ([]role.RoleDescriptor, error)
 	ListByResourceAndRoleName(ctx context.Context, currentIdentity uuid.UUID, resourceID string, roleName string) ([]rolerepo.IdentityRole, error)
-	Assign(ctx context.Context, assignedBy uuid.UUID, roleAssignments map[string][]uuid.UUID, resourceID string, appendToExistingRoles bool) error
+	Assign(ctx context.Context, assignedBy uuid.UUID, roleAssignments map[string][]uuid.UUID, resourceID string, appendToExistingRoles bool, validity map[string]rolerepo.Validity) error
 	ForceAssign(ctx context.Context, assignedTo uuid.UUID, roleName string, res resource.Resource) error
 	RevokeResourceRoles(ctx context.Context, currentIdentity uuid.UUID, identities []uuid.UUID, resourceID string) error
+	ListDenyAssignmentsByResource(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]rolerepo.DenyAssignment, error)
+	Deny(ctx context.Context, deniedBy uuid.UUID, denials map[string][]uuid.UUID, resourceID string) ([]rolerepo.DenyAssignment, error)
+	RevokeDenyAssignment(ctx context.Context, currentIdentity uuid.UUID, resourceID string, denyAssignmentID uuid.UUID) error
+	ListRoleMappings(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]rolerepo.RoleMapping, error)
+	CreateRoleMapping(ctx context.Context, currentIdentity uuid.UUID, resourceID string, fromRoleName string, toResourceType string, toRoleName string) (*rolerepo.RoleMapping, error)
+	DeleteRoleMapping(ctx context.Context, currentIdentity uuid.UUID, resourceID string, roleMappingID uuid.UUID) error
+	DeleteExpiredAssignments(ctx context.Context) error
+	RefreshStartedAssignments(ctx context.Context) error
 }
 
 type SpaceService interface {
@@ -138,6 +306,11 @@ type TeamService interface {
 	CreateTeam(ctx context.Context, identityID uuid.UUID, spaceID string, teamName string) (*uuid.UUID, error)
 	ListTeamsInSpace(ctx context.Context, identityID uuid.UUID, spaceID string) ([]account.Identity, error)
 	ListTeamsForIdentity(ctx context.Context, identityID uuid.UUID) ([]authorization.IdentityAssociation, error)
+	ListMembers(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID) ([]account.Identity, error)
+	AddMember(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error
+	RemoveMember(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error
+	RenameTeam(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, teamName string) error
+	DeleteTeam(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID) error
 }
 
 type TokenService interface {
@@ -190,17 +363,25 @@ type CheService interface {
 
 //Services creates instances of service layer objects
 type Services interface {
+	AccessReportService() AccessReportService
+	AccessRequestService() AccessRequestService
 	AuthenticationProviderService() AuthenticationProviderService
 	ClusterService() ClusterService
+	CredentialService() CredentialService
+	GroupService() GroupService
+	ImpersonationService() ImpersonationService
 	InvitationService() InvitationService
 	LinkService() LinkService
 	LogoutService() LogoutService
+	MembershipService() MembershipService
+	MFAService() MFAService
 	NotificationService() NotificationService
 	OrganizationService() OrganizationService
 	OSOSubscriptionService() OSOSubscriptionService
 	PermissionService() PermissionService
 	PrivilegeCacheService() PrivilegeCacheService
 	ResourceService() ResourceService
+	ResourceTypeService() ResourceTypeService
 	RoleManagementService() RoleManagementService
 	SpaceService() SpaceService
 	TeamService() TeamService
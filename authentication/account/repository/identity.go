@@ -10,6 +10,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/application/repository/base"
 	"github.com/fabric8-services/fabric8-auth/authorization"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
@@ -115,6 +116,12 @@ type GormIdentityRepository struct {
 	db *gorm.DB
 }
 
+// membershipsLockID is the key of the advisory lock which serializes the additions of memberships
+const membershipsLockID = 4401
+
+// maxMembershipDepth is the maximum number of nested memberships followed by the recursive membership queries
+var maxMembershipDepth = strconv.Itoa(authorization.MaxMembershipDepth)
+
 // NewIdentityRepository creates a new storage type.
 func NewIdentityRepository(db *gorm.DB) *GormIdentityRepository {
 	return &GormIdentityRepository{db: db}
@@ -137,7 +144,14 @@ type IdentityRepository interface {
 	IsValid(context.Context, uuid.UUID) bool
 	Search(ctx context.Context, q string, start int, limit int) ([]Identity, int, error)
 	FindIdentityMemberships(ctx context.Context, identityID uuid.UUID, resourceType *string) ([]authorization.IdentityAssociation, error)
+	FindDirectMemberships(ctx context.Context, identityID uuid.UUID) ([]Identity, error)
+	FindDirectMembers(ctx context.Context, identityID uuid.UUID) ([]Identity, error)
+	FindTransitiveMembers(ctx context.Context, identityID uuid.UUID) ([]Identity, error)
 	FindIdentitiesByResourceTypeWithParentResource(ctx context.Context, resourceTypeID uuid.UUID, parentResourceID string) ([]Identity, error)
+	IsTransitiveMember(ctx context.Context, memberID uuid.UUID, memberOf uuid.UUID) (bool, error)
+	FindMembershipDepth(ctx context.Context, identityID uuid.UUID, maxDepth int) (int, error)
+	FindMembersDepth(ctx context.Context, identityID uuid.UUID, maxDepth int) (int, error)
+	LockMemberships(ctx context.Context) error
 	AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error
 	RemoveMember(ctx context.Context, memberOf uuid.UUID, memberID uuid.UUID) error
 	FlagPrivilegeCacheStaleForMembershipChange(ctx context.Context, memberID uuid.UUID, memberOf uuid.UUID) error
@@ -361,6 +375,13 @@ func IdentityFilterByID(identityID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityFilterByIdentityResourceID is a gorm filter by 'identity_resource_id'
+func IdentityFilterByIdentityResourceID(resourceID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("identity_resource_id = ?", resourceID)
+	}
+}
+
 // IdentityWithUser is a gorm filter for preloading the User relationship.
 func IdentityWithUser() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -368,6 +389,13 @@ func IdentityWithUser() func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityWithResource is a gorm filter for preloading the IdentityResource relationship.
+func IdentityWithResource() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource")
+	}
+}
+
 // IdentityFilterByProviderType is a gorm filter by 'provider_type'
 func IdentityFilterByProviderType(providerType string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -545,9 +573,9 @@ func (m *GormIdentityRepository) FindIdentityMemberships(ctx context.Context, id
 			Joins("JOIN resource_type rt ON r.resource_type_id = rt.resource_type_id AND rt.name = ?", resourceType)
 	}
 
-	err := q.Where(`identities.id IN (WITH RECURSIVE m AS (
-			SELECT member_of FROM	membership WHERE member_id = ? 
-      UNION SELECT p.member_of	FROM membership p INNER JOIN m ON m.member_of = p.member_id)
+	err := q.Where(`identities.id IN (WITH RECURSIVE m(member_of, depth) AS (
+			SELECT member_of, 1 FROM	membership WHERE member_id = ? 
+      UNION SELECT p.member_of, m.depth + 1	FROM membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`)
 		  SELECT member_of FROM m)`, identityID).
 		Find(&identities).Error
 
@@ -574,6 +602,54 @@ func (m *GormIdentityRepository) FindIdentityMemberships(ctx context.Context, id
 	return associations, nil
 }
 
+// FindDirectMemberships returns the identities (i.e. teams, organizations, security groups) of which the specified
+// identity is a direct member, without those it is only a member of through another membership
+func (m *GormIdentityRepository) FindDirectMemberships(ctx context.Context, identityID uuid.UUID) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindDirectMemberships"}, time.Now())
+	var identities []Identity
+	err := m.db.Table(m.TableName()).Preload("IdentityResource").
+		Where("identities.id IN (SELECT member_of FROM membership WHERE member_id = ?)", identityID).
+		Order("identities.created_at").
+		Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identities, nil
+}
+
+// FindDirectMembers returns the identities which are direct members of the specified identity (i.e. a team, an
+// organization or a security group), without those which are only members through another membership
+func (m *GormIdentityRepository) FindDirectMembers(ctx context.Context, identityID uuid.UUID) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindDirectMembers"}, time.Now())
+	var identities []Identity
+	err := m.db.Table(m.TableName()).Preload("IdentityResource").Preload("IdentityResource.ResourceType").
+		Where("identities.id IN (SELECT member_id FROM membership WHERE member_of = ?)", identityID).
+		Order("identities.created_at").
+		Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identities, nil
+}
+
+// FindTransitiveMembers returns the identities which are members of the specified identity (i.e. a team, an
+// organization or a security group), either directly or through nested memberships
+func (m *GormIdentityRepository) FindTransitiveMembers(ctx context.Context, identityID uuid.UUID) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindTransitiveMembers"}, time.Now())
+	var identities []Identity
+	err := m.db.Table(m.TableName()).
+		Where(`identities.id IN (WITH RECURSIVE m(member_id, depth) AS (
+			SELECT member_id, 1 FROM membership WHERE member_of = ?
+			UNION SELECT p.member_id, m.depth + 1 FROM membership p INNER JOIN m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`)
+			SELECT member_id FROM m)`, identityID).
+		Order("identities.created_at").
+		Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identities, nil
+}
+
 // FindIdentitiesWithParentResource returns an array of Identity objects for which their corresponding resource is a child of the specified parent resource
 func (m *GormIdentityRepository) FindIdentitiesByResourceTypeWithParentResource(ctx context.Context, resourceTypeID uuid.UUID, parentResourceID string) ([]Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindIdentitiesByResourceTypeWithParentResource"}, time.Now())
@@ -591,6 +667,76 @@ func (m *GormIdentityRepository) FindIdentitiesByResourceTypeWithParentResource(
 	return identities, nil
 }
 
+// IsTransitiveMember returns true if the identity with the specified memberID is a member of the memberOf identity,
+// either directly or through any number of nested memberships
+func (m *GormIdentityRepository) IsTransitiveMember(ctx context.Context, memberID uuid.UUID, memberOf uuid.UUID) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "IsTransitiveMember"}, time.Now())
+
+	var result struct {
+		Count int
+	}
+	err := m.db.Raw(`WITH RECURSIVE m AS (
+	  SELECT member_of FROM membership WHERE member_id = ? /* MEMBER_ID */
+	  UNION SELECT p.member_of FROM membership p INNER JOIN m ON m.member_of = p.member_id)
+	SELECT COUNT(*) AS count FROM m WHERE member_of = ? /* MEMBER_OF */`, memberID, memberOf).Scan(&result).Error
+	if err != nil {
+		return false, errs.WithStack(err)
+	}
+	return result.Count > 0, nil
+}
+
+// FindMembershipDepth returns the length of the longest chain of nested memberships from the specified identity to
+// the identities it is a member of. The chains are not followed beyond maxDepth memberships.
+func (m *GormIdentityRepository) FindMembershipDepth(ctx context.Context, identityID uuid.UUID, maxDepth int) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindMembershipDepth"}, time.Now())
+
+	var result struct {
+		Depth int
+	}
+	err := m.db.Raw(`WITH RECURSIVE m(identity_id, depth) AS (
+	  SELECT member_of, 1 FROM membership WHERE member_id = ? /* IDENTITY_ID */
+	  UNION SELECT p.member_of, m.depth + 1 FROM membership p INNER JOIN m ON m.identity_id = p.member_id
+	  WHERE m.depth < ? /* MAX_DEPTH */)
+	SELECT COALESCE(MAX(depth), 0) AS depth FROM m`, identityID, maxDepth).Scan(&result).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return result.Depth, nil
+}
+
+// FindMembersDepth returns the length of the longest chain of nested memberships from the members of the specified
+// identity to the identity itself. The chains are not followed beyond maxDepth memberships.
+func (m *GormIdentityRepository) FindMembersDepth(ctx context.Context, identityID uuid.UUID, maxDepth int) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindMembersDepth"}, time.Now())
+
+	var result struct {
+		Depth int
+	}
+	err := m.db.Raw(`WITH RECURSIVE m(identity_id, depth) AS (
+	  SELECT member_id, 1 FROM membership WHERE member_of = ? /* IDENTITY_ID */
+	  UNION SELECT p.member_id, m.depth + 1 FROM membership p INNER JOIN m ON m.identity_id = p.member_of
+	  WHERE m.depth < ? /* MAX_DEPTH */)
+	SELECT COALESCE(MAX(depth), 0) AS depth FROM m`, identityID, maxDepth).Scan(&result).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return result.Depth, nil
+}
+
+// LockMemberships acquires a transaction level lock which serializes the additions of memberships, so that the checks
+// made before adding a membership, e.g. the detection of cycles, take the memberships added concurrently into account.
+// The lock is released when the transaction is committed or rolled back.
+func (m *GormIdentityRepository) LockMemberships(ctx context.Context) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "LockMemberships"}, time.Now())
+	err := m.db.Exec("SELECT pg_advisory_xact_lock(?)", membershipsLockID).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// AddMember adds a membership for the specified memberID in the identityID. Memberships which would make an identity
+// a member of itself, directly or through nested memberships, are rejected.
 func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "AddMember"}, time.Now())
 
@@ -614,6 +760,17 @@ func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.
 		return errs.WithStack(errors.NewNotFoundError("identity", memberID.String()))
 	}
 
+	if memberID == identityID {
+		return errs.WithStack(errors.NewBadParameterErrorFromString("memberID", memberID.String(), "an identity cannot be a member of itself"))
+	}
+	cycle, err := m.IsTransitiveMember(ctx, identityID, memberID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return errs.WithStack(errors.NewBadParameterErrorFromString("memberID", memberID.String(), "the membership would create a cycle of memberships"))
+	}
+
 	membership := &Membership{
 		MemberOf: identityID,
 		MemberID: memberID,
@@ -629,7 +786,7 @@ func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.
 		return errs.WithStack(err)
 	}
 
-	err = m.FlagPrivilegeCacheStaleForMembershipChange(ctx, identityID, memberID)
+	err = m.FlagPrivilegeCacheStaleForMembershipChange(ctx, memberID, identityID)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"member_of": identityID,
@@ -692,22 +849,24 @@ func (m *GormIdentityRepository) RemoveMember(ctx context.Context, memberOf uuid
 //
 // The second query updates the token table, setting the STALE flag of the token STATUS field to true, for all
 // token records that are mapped to the corresponding privilege cache records in the first query, via the
-// many-to-many TOKEN_PRIVILEGE table
+// many-to-many TOKEN_PRIVILEGE table.
+// The IDs of the identities whose privilege cache records were flagged as stale are published when the transaction
+// is committed, so that the in-memory privilege caches of all the replicas are invalidated
 func (m *GormIdentityRepository) FlagPrivilegeCacheStaleForMembershipChange(ctx context.Context, memberID uuid.UUID, memberOf uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "FlagPrivilegeCacheStaleForMembershipChange"}, time.Now())
 
-	result := m.db.Exec(`WITH member_identity_hierarchy AS (
-	WITH RECURSIVE m AS (
+	err := permission.FlagStaleAndNotify(ctx, m.db, `WITH member_identity_hierarchy AS (
+	WITH RECURSIVE m(member_id, depth) AS (
 	  SELECT
-	    member_id
+	    member_id, 1
 	  FROM
 	    membership
 	  WHERE
 	    member_of = ? /* MEMBER_ID */
 	  UNION SELECT
-	    p.member_id
+	    p.member_id, m.depth + 1
 	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
+	    membership p INNER JOIN m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`
 	  )
 	  SELECT
 	    member_id AS identity_id
@@ -721,17 +880,17 @@ func (m *GormIdentityRepository) FlagPrivilegeCacheStaleForMembershipChange(ctx
 	    id = ? /* MEMBER_ID */
 ),
 member_of_identity_hierarchy AS (
-WITH RECURSIVE m AS (
+WITH RECURSIVE m(member_of, depth) AS (
   SELECT
-    member_of
+    member_of, 1
   FROM
     membership
   WHERE
     member_id = ? /* MEMBER_OF */
   UNION SELECT
-    p.member_of
+    p.member_of, m.depth + 1
   FROM
-    membership p INNER JOIN m ON m.member_of = p.member_id
+    membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`
   )
   SELECT
     member_of AS identity_id
@@ -779,24 +938,25 @@ WHERE
   resource_id IN (SELECT resource_id FROM resource_hierarchy)
   AND identity_id IN (SELECT identity_id FROM member_identity_hierarchy)
   AND deleted_at IS NULL
+RETURNING
+  identity_id
   `, memberID, memberID, memberOf, memberOf)
-
-	if result.Error != nil {
-		return errors.NewInternalError(ctx, result.Error)
+	if err != nil {
+		return err
 	}
 
-	result = m.db.Exec(`WITH member_identity_hierarchy AS (
-	WITH RECURSIVE m AS (
+	result := m.db.Exec(`WITH member_identity_hierarchy AS (
+	WITH RECURSIVE m(member_id, depth) AS (
 	  SELECT
-	    member_id
+	    member_id, 1
 	  FROM
 	    membership
 	  WHERE
 	    member_of = ? /* MEMBER_ID */
 	  UNION SELECT
-	    p.member_id
+	    p.member_id, m.depth + 1
 	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
+	    membership p INNER JOIN m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`
 	  )
 	  SELECT
 	    member_id AS identity_id
@@ -810,17 +970,17 @@ WHERE
 	    id = ? /* MEMBER_ID */
 ),
 member_of_identity_hierarchy AS (
-WITH RECURSIVE m AS (
+WITH RECURSIVE m(member_of, depth) AS (
   SELECT
-    member_of
+    member_of, 1
   FROM
     membership
   WHERE
     member_id = ? /* MEMBER_OF */
   UNION SELECT
-    p.member_of
+    p.member_of, m.depth + 1
   FROM
-    membership p INNER JOIN m ON m.member_of = p.member_id
+    membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`
   )
   SELECT
     member_of AS identity_id
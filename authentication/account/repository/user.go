@@ -219,6 +219,14 @@ func UserFilterByEmail(email string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// UserFilterByVerifiedEmailIgnoreCase is a gorm filter for the users with the given verified email address, whatever
+// its case
+func UserFilterByVerifiedEmailIgnoreCase(email string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("lower(email) = lower(?) AND email_verified IS TRUE", email)
+	}
+}
+
 // UserFilterByEmailPrivacy is to be used to filter only public or only private emails
 func UserFilterByEmailPrivacy(privateEmails bool) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
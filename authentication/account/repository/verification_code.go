@@ -15,6 +15,15 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+const (
+	// VerificationCodePurposeEmailVerification is the purpose of the codes sent to users to verify their email address
+	VerificationCodePurposeEmailVerification = "email_verification"
+	// VerificationCodePurposePasswordReset is the purpose of the codes sent to users to reset their local password
+	VerificationCodePurposePasswordReset = "password_reset"
+	// VerificationCodePurposeLogin is the purpose of the short-lived codes issued after a successful local login
+	VerificationCodePurposeLogin = "login"
+)
+
 type VerificationCode struct {
 	gormsupport.Lifecycle
 	ID     uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"` // This is the ID PK field
@@ -22,6 +31,8 @@ type VerificationCode struct {
 	UserID uuid.UUID `sql:"type:uuid"`
 
 	Code string
+	// Purpose is what the code may be used for, defaults to email verification
+	Purpose string
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -91,6 +102,9 @@ func (m *GormVerificationCodeRepository) Create(ctx context.Context, model *Veri
 	if model.ID == uuid.Nil {
 		model.ID = uuid.NewV4()
 	}
+	if model.Purpose == "" {
+		model.Purpose = VerificationCodePurposeEmailVerification
+	}
 	err := m.db.Create(model).Error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -180,6 +194,13 @@ func VerificationCodeFilterByCode(code string) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// VerificationCodeFilterByPurpose is a gorm filter by 'purpose'
+func VerificationCodeFilterByPurpose(purpose string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("purpose = ?", purpose)
+	}
+}
+
 // VerificationCodeWithUser is a gorm filter for preloading the user relationship.
 func VerificationCodeWithUser() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
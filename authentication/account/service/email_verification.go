@@ -81,7 +81,9 @@ func (c *EmailVerificationClient) VerifyCode(ctx context.Context, code string) (
 
 	err := transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
 
-		verificationCodeList, err := tr.VerificationCodes().LoadByCode(ctx, code)
+		verificationCodeList, err := tr.VerificationCodes().Query(repository.VerificationCodeWithUser(),
+			repository.VerificationCodeFilterByCode(code),
+			repository.VerificationCodeFilterByPurpose(repository.VerificationCodePurposeEmailVerification))
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"err": err,
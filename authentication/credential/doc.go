@@ -0,0 +1,3 @@
+// Package credential provides the local (username/password) credentials of users, for deployments which cannot
+// rely on an external identity provider.
+package credential
@@ -0,0 +1,100 @@
+package credential
+
+import (
+	"bytes"
+	"html/template"
+
+	errs "github.com/pkg/errors"
+)
+
+// LoginFormData contains the values rendered in the local login form
+type LoginFormData struct {
+	// Action is the URL to which the form is posted
+	Action string
+	// State is the state of the login flow, which must be posted back with the credentials
+	State string
+	// ForgotPasswordURL is the URL of the form used to request a password reset
+	ForgotPasswordURL string
+	// Username is the previously entered username, if any
+	Username string
+	// Error is the message to display when a previous attempt failed, if any
+	Error string
+}
+
+// PasswordFormData contains the values rendered in the password forms (forgotten password and password reset)
+type PasswordFormData struct {
+	// Action is the URL to which the form is posted
+	Action string
+	// Code is the password reset code, if any
+	Code string
+	// Error is the message to display when a previous attempt failed, if any
+	Error string
+	// Message is the message to display instead of the form, if any
+	Message string
+}
+
+// RenderLoginForm returns the HTML of the local login form
+func RenderLoginForm(data LoginFormData) ([]byte, error) {
+	return render(loginFormTemplate, data)
+}
+
+// RenderForgotPasswordForm returns the HTML of the form used to request a password reset
+func RenderForgotPasswordForm(data PasswordFormData) ([]byte, error) {
+	return render(forgotPasswordFormTemplate, data)
+}
+
+// RenderPasswordResetForm returns the HTML of the form used to choose a new password
+func RenderPasswordResetForm(data PasswordFormData) ([]byte, error) {
+	return render(passwordResetFormTemplate, data)
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to render template '%s'", tmpl.Name())
+	}
+	return buf.Bytes(), nil
+}
+
+var layoutTemplate = `{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=device-width, initial-scale=1"/>
+<title>{{.}}</title>
+</head>
+<body>
+<h1>{{.}}</h1>
+{{end}}{{define "footer"}}</body>
+</html>{{end}}`
+
+var loginFormTemplate = template.Must(template.Must(template.New("login").Parse(layoutTemplate)).Parse(`{{template "header" "Log in"}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="state" value="{{.State}}"/>
+<p><label for="username">Username</label><br/><input type="text" id="username" name="username" value="{{.Username}}" autocomplete="username" autofocus required/></p>
+<p><label for="password">Password</label><br/><input type="password" id="password" name="password" autocomplete="current-password" required/></p>
+<p><input type="submit" value="Log in"/></p>
+</form>
+<p><a href="{{.ForgotPasswordURL}}">Forgot your password?</a></p>
+{{template "footer"}}`))
+
+var forgotPasswordFormTemplate = template.Must(template.Must(template.New("forgot").Parse(layoutTemplate)).Parse(`{{template "header" "Forgot your password?"}}
+{{if .Message}}<p class="message">{{.Message}}</p>{{else}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<p><label for="username">Username or email address</label><br/><input type="text" id="username" name="username" autofocus required/></p>
+<p><input type="submit" value="Send reset link"/></p>
+</form>{{end}}
+{{template "footer"}}`))
+
+var passwordResetFormTemplate = template.Must(template.Must(template.New("reset").Parse(layoutTemplate)).Parse(`{{template "header" "Choose a new password"}}
+{{if .Message}}<p class="message">{{.Message}}</p>{{else}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="code" value="{{.Code}}"/>
+<p><label for="password">New password</label><br/><input type="password" id="password" name="password" autocomplete="new-password" autofocus required/></p>
+<p><input type="submit" value="Change password"/></p>
+</form>{{end}}
+{{template "footer"}}`))
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Credential is the local password of an identity
+type Credential struct {
+	// IdentityID is the primary key, an identity has at most one local password
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:identity_id"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// PasswordHash is the encoded hash of the password, including the algorithm and its parameters
+	PasswordHash string `gorm:"column:password_hash"`
+	// FailedAttempts is the number of consecutive failed login attempts
+	FailedAttempts int `gorm:"column:failed_attempts"`
+	// LockedUntil is the time until which login attempts are rejected after too many failed attempts
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Credential) TableName() string {
+	return "credentials"
+}
+
+// IsLocked returns true if login attempts are rejected at the given time
+func (m Credential) IsLocked(now time.Time) bool {
+	return m.LockedUntil != nil && now.Before(*m.LockedUntil)
+}
+
+// GormCredentialRepository is the implementation of the storage interface for Credential.
+type GormCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewCredentialRepository creates a new storage type.
+func NewCredentialRepository(db *gorm.DB) CredentialRepository {
+	return &GormCredentialRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormCredentialRepository) TableName() string {
+	return "credentials"
+}
+
+// CredentialRepository represents the storage interface.
+type CredentialRepository interface {
+	Load(ctx context.Context, identityID uuid.UUID) (*Credential, error)
+	Create(ctx context.Context, credential *Credential) error
+	Save(ctx context.Context, credential *Credential) error
+	Delete(ctx context.Context, identityID uuid.UUID) error
+}
+
+// Load returns the credential of the given identity
+func (m *GormCredentialRepository) Load(ctx context.Context, identityID uuid.UUID) (*Credential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "credential", "load"}, time.Now())
+
+	var native Credential
+	err := m.db.Table(m.TableName()).Where("identity_id = ?", identityID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("credential", identityID.String())
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return &native, nil
+}
+
+// Create creates a new record.
+func (m *GormCredentialRepository) Create(ctx context.Context, credential *Credential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "credential", "create"}, time.Now())
+
+	err := m.db.Create(credential).Error
+	if err != nil {
+		if gormsupport.IsUniqueViolation(err, "credentials_pkey") {
+			return errors.NewDataConflictError("credential already exists for identity " + credential.IdentityID.String())
+		}
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": credential.IdentityID,
+			"err":         err,
+		}, "unable to create the credential")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": credential.IdentityID,
+	}, "credential created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormCredentialRepository) Save(ctx context.Context, credential *Credential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "credential", "save"}, time.Now())
+
+	// update with a map so that zero values (such as a reset of failed attempts) are updated too
+	result := m.db.Table(m.TableName()).Where("identity_id = ?", credential.IdentityID).Updates(map[string]interface{}{
+		"password_hash":   credential.PasswordHash,
+		"failed_attempts": credential.FailedAttempts,
+		"locked_until":    credential.LockedUntil,
+		"updated_at":      time.Now(),
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": credential.IdentityID,
+			"err":         result.Error,
+		}, "unable to update the credential")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("credential", credential.IdentityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": credential.IdentityID,
+	}, "credential saved!")
+	return nil
+}
+
+// Delete removes the credential of the given identity. This is a hard delete!
+func (m *GormCredentialRepository) Delete(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "credential", "delete"}, time.Now())
+
+	result := m.db.Delete(&Credential{IdentityID: identityID})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         result.Error,
+		}, "unable to delete the credential")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("credential", identityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "credential deleted!")
+	return nil
+}
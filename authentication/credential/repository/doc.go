@@ -0,0 +1,2 @@
+// Package repository provides the wrappers for 'credentials' related database interactions.
+package repository
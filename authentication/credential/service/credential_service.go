@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	accountrepo "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/credential/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CredentialServiceConfiguration the configuration for the Credential service
+type CredentialServiceConfiguration interface {
+	GetLocalPasswordMinLength() int
+	GetLocalPasswordRequireUppercase() bool
+	GetLocalPasswordRequireLowercase() bool
+	GetLocalPasswordRequireDigit() bool
+	GetLocalPasswordRequireSpecial() bool
+	GetLocalPasswordHashCost() int
+	GetLocalLoginMaxFailedAttempts() int
+	GetLocalLoginLockoutDuration() time.Duration
+	GetLocalLoginCodeExpiry() time.Duration
+	GetLocalPasswordResetURL() string
+	GetLocalPasswordResetExpiry() time.Duration
+}
+
+// invalidCredentialsMessage is deliberately the same whether the username or the password is wrong
+const invalidCredentialsMessage = "invalid username or password"
+
+// dummyPasswordHash is compared against when the user is unknown, so that the response time does not reveal
+// whether a username exists
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte(uuid.NewV4().String()), bcrypt.DefaultCost)
+
+// NewCredentialService creates a new service to manage local credentials
+func NewCredentialService(ctx servicecontext.ServiceContext, config CredentialServiceConfiguration) service.CredentialService {
+	return &credentialServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// credentialServiceImpl implements the CredentialService to manage local credentials
+type credentialServiceImpl struct {
+	base.BaseService
+	config CredentialServiceConfiguration
+}
+
+// Authenticate verifies the given username and password and returns a one-time login code which can be exchanged
+// by the local identity provider during the login callback. The username may also be the verified email address of
+// the user, whatever its case. After too many consecutive failed attempts the credential is locked for the configured
+// duration.
+func (s *credentialServiceImpl) Authenticate(ctx context.Context, username string, password string) (string, error) {
+	var loginCode string
+	var authErr error
+
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.loadIdentityByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		if identity == nil && strings.Contains(username, "@") {
+			identity, err = s.loadIdentityByVerifiedEmail(ctx, username)
+			if err != nil {
+				return err
+			}
+		}
+		var credential *repository.Credential
+		if identity != nil {
+			credential, err = s.Repositories().CredentialRepository().Load(ctx, identity.ID)
+			if err != nil {
+				if notFound, _ := errors.IsNotFoundError(err); !notFound {
+					return err
+				}
+			}
+		}
+		if credential == nil {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			authErr = errors.NewUnauthorizedError(invalidCredentialsMessage)
+			return nil
+		}
+
+		now := time.Now()
+		if credential.IsLocked(now) {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id":  identity.ID,
+				"locked_until": credential.LockedUntil,
+			}, "login attempt for a locked local credential")
+			authErr = errors.NewUnauthorizedError("too many failed login attempts, please try again later")
+			return nil
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(password)) != nil {
+			credential.FailedAttempts++
+			if credential.FailedAttempts >= s.config.GetLocalLoginMaxFailedAttempts() {
+				lockedUntil := now.Add(s.config.GetLocalLoginLockoutDuration())
+				credential.LockedUntil = &lockedUntil
+				credential.FailedAttempts = 0
+				log.Warn(ctx, map[string]interface{}{
+					"identity_id":  identity.ID,
+					"locked_until": lockedUntil,
+				}, "local credential locked after too many failed login attempts")
+			}
+			authErr = errors.NewUnauthorizedError(invalidCredentialsMessage)
+			// the failed attempt must be recorded, so the transaction is not rolled back
+			return s.Repositories().CredentialRepository().Save(ctx, credential)
+		}
+
+		if identity.User.Banned {
+			authErr = errors.NewUnauthorizedError("unauthorized access")
+			return nil
+		}
+
+		if credential.FailedAttempts > 0 || credential.LockedUntil != nil {
+			credential.FailedAttempts = 0
+			credential.LockedUntil = nil
+			err = s.Repositories().CredentialRepository().Save(ctx, credential)
+			if err != nil {
+				return err
+			}
+		}
+
+		code := &accountrepo.VerificationCode{
+			UserID:  identity.User.ID,
+			Code:    uuid.NewV4().String(),
+			Purpose: accountrepo.VerificationCodePurposeLogin,
+		}
+		err = s.Repositories().VerificationCodes().Create(ctx, code)
+		if err != nil {
+			return err
+		}
+		loginCode = code.Code
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if authErr != nil {
+		return "", authErr
+	}
+	return loginCode, nil
+}
+
+// CheckLoginCode returns an error if the given login code is unknown or expired
+func (s *credentialServiceImpl) CheckLoginCode(ctx context.Context, code string) error {
+	_, err := s.loadCode(ctx, code, accountrepo.VerificationCodePurposeLogin, s.config.GetLocalLoginCodeExpiry())
+	return err
+}
+
+// RedeemLoginCode consumes the given login code and returns the profile of the user it was issued for
+func (s *credentialServiceImpl) RedeemLoginCode(ctx context.Context, code string) (*provider.UserProfile, error) {
+	var profile *provider.UserProfile
+	err := s.ExecuteInTransaction(func() error {
+		loginCode, err := s.loadCode(ctx, code, accountrepo.VerificationCodePurposeLogin, s.config.GetLocalLoginCodeExpiry())
+		if err != nil {
+			return err
+		}
+		err = s.Repositories().VerificationCodes().Delete(ctx, loginCode.ID)
+		if err != nil {
+			return err
+		}
+		identity, err := s.loadIdentityByUserID(ctx, loginCode.UserID)
+		if err != nil {
+			return err
+		}
+		givenName, familyName := account.SplitFullName(identity.User.FullName)
+		profile = &provider.UserProfile{
+			Username:      identity.Username,
+			Name:          identity.User.FullName,
+			GivenName:     givenName,
+			FamilyName:    familyName,
+			Email:         identity.User.Email,
+			EmailVerified: identity.User.EmailVerified,
+			Company:       identity.User.Company,
+			Approved:      true,
+			Subject:       identity.ID.String(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// SetPassword sets the local password of the given identity, replacing any existing one
+func (s *credentialServiceImpl) SetPassword(ctx context.Context, identityID uuid.UUID, password string) error {
+	err := s.ValidatePassword(password)
+	if err != nil {
+		return err
+	}
+	return s.ExecuteInTransaction(func() error {
+		return s.storePassword(ctx, identityID, password)
+	})
+}
+
+// ChangePassword replaces the local password of the given identity, after verifying the current one
+func (s *credentialServiceImpl) ChangePassword(ctx context.Context, identityID uuid.UUID, currentPassword string, newPassword string) error {
+	err := s.ValidatePassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.ExecuteInTransaction(func() error {
+		credential, err := s.Repositories().CredentialRepository().Load(ctx, identityID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewUnauthorizedError("no local password has been set for this user")
+			}
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(currentPassword)) != nil {
+			return errors.NewUnauthorizedError("the current password is invalid")
+		}
+		return s.storePassword(ctx, identityID, newPassword)
+	})
+}
+
+// SendPasswordReset sends a password reset link to the user with the given username or email address.  No error is
+// returned if there is no such user, so that this cannot be used to find out which accounts exist.
+func (s *credentialServiceImpl) SendPasswordReset(ctx context.Context, usernameOrEmail string) error {
+	var identity *accountrepo.Identity
+	code := &accountrepo.VerificationCode{
+		Code:    uuid.NewV4().String(),
+		Purpose: accountrepo.VerificationCodePurposePasswordReset,
+	}
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		identity, err = s.loadIdentityByUsername(ctx, usernameOrEmail)
+		if err != nil {
+			return err
+		}
+		if identity == nil {
+			users, err := s.Repositories().Users().Query(accountrepo.UserFilterByEmail(usernameOrEmail))
+			if err != nil {
+				return err
+			}
+			if len(users) == 0 {
+				return nil
+			}
+			identity, err = s.loadIdentityByUserID(ctx, users[0].ID)
+			if err != nil {
+				return err
+			}
+		}
+		if identity.User.Banned {
+			identity = nil
+			return nil
+		}
+		code.UserID = identity.User.ID
+		return s.Repositories().VerificationCodes().Create(ctx, code)
+	})
+	if err != nil {
+		return err
+	}
+	if identity == nil {
+		log.Info(ctx, map[string]interface{}{}, "password reset requested for an unknown user")
+		return nil
+	}
+
+	resetURL, err := rest.AddParam(s.config.GetLocalPasswordResetURL(), "code", code.Code)
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	expiryDate := code.CreatedAt.Add(s.config.GetLocalPasswordResetExpiry()).Format("Mon Jan 2 15:04:05 MST 2006")
+	msg := notification.NewPasswordResetEmail(identity.ID.String(), resetURL, expiryDate)
+	_, err = s.Services().NotificationService().SendMessageAsync(ctx, msg)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+			"err":         err,
+		}, "unable to send the password reset notification")
+		return err
+	}
+	return nil
+}
+
+// ResetPassword consumes the given password reset code and sets the new password of the user it was issued for
+func (s *credentialServiceImpl) ResetPassword(ctx context.Context, code string, newPassword string) error {
+	err := s.ValidatePassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.ExecuteInTransaction(func() error {
+		resetCode, err := s.loadCode(ctx, code, accountrepo.VerificationCodePurposePasswordReset, s.config.GetLocalPasswordResetExpiry())
+		if err != nil {
+			return err
+		}
+		identity, err := s.loadIdentityByUserID(ctx, resetCode.UserID)
+		if err != nil {
+			return err
+		}
+		err = s.storePassword(ctx, identity.ID, newPassword)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().VerificationCodes().Delete(ctx, resetCode.ID)
+	})
+}
+
+// ValidatePassword checks the given password against the configured password policy
+func (s *credentialServiceImpl) ValidatePassword(password string) error {
+	if len([]rune(password)) < s.config.GetLocalPasswordMinLength() {
+		return errors.NewBadParameterErrorFromString("password", "", fmt.Sprintf("password must be at least %d characters long", s.config.GetLocalPasswordMinLength()))
+	}
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSpecial = true
+		}
+	}
+	if s.config.GetLocalPasswordRequireUppercase() && !hasUpper {
+		return errors.NewBadParameterErrorFromString("password", "", "password must contain an uppercase letter")
+	}
+	if s.config.GetLocalPasswordRequireLowercase() && !hasLower {
+		return errors.NewBadParameterErrorFromString("password", "", "password must contain a lowercase letter")
+	}
+	if s.config.GetLocalPasswordRequireDigit() && !hasDigit {
+		return errors.NewBadParameterErrorFromString("password", "", "password must contain a digit")
+	}
+	if s.config.GetLocalPasswordRequireSpecial() && !hasSpecial {
+		return errors.NewBadParameterErrorFromString("password", "", "password must contain a special character")
+	}
+	return nil
+}
+
+// storePassword hashes the given password and creates or updates the credential of the identity, also clearing
+// any failed login attempts
+func (s *credentialServiceImpl) storePassword(ctx context.Context, identityID uuid.UUID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.GetLocalPasswordHashCost())
+	if err != nil {
+		return errors.NewInternalError(ctx, errs.Wrap(err, "unable to hash password"))
+	}
+	credential, err := s.Repositories().CredentialRepository().Load(ctx, identityID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return err
+		}
+		return s.Repositories().CredentialRepository().Create(ctx, &repository.Credential{
+			IdentityID:   identityID,
+			PasswordHash: string(hash),
+		})
+	}
+	credential.PasswordHash = string(hash)
+	credential.FailedAttempts = 0
+	credential.LockedUntil = nil
+	return s.Repositories().CredentialRepository().Save(ctx, credential)
+}
+
+// loadCode loads the code with the given value and purpose, returning a not found error if it does not exist or was
+// created more than `expiry` ago
+func (s *credentialServiceImpl) loadCode(ctx context.Context, code string, purpose string, expiry time.Duration) (*accountrepo.VerificationCode, error) {
+	codes, err := s.Repositories().VerificationCodes().Query(accountrepo.VerificationCodeFilterByCode(code),
+		accountrepo.VerificationCodeFilterByPurpose(purpose))
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 || time.Now().After(codes[0].CreatedAt.Add(expiry)) {
+		return nil, errors.NewNotFoundError("code", code)
+	}
+	return &codes[0], nil
+}
+
+// loadIdentityByUsername returns the main identity with the given username, or nil if there is none
+func (s *credentialServiceImpl) loadIdentityByUsername(ctx context.Context, username string) (*accountrepo.Identity, error) {
+	identities, err := s.Repositories().Identities().Query(accountrepo.IdentityFilterByUsername(username),
+		accountrepo.IdentityFilterByProviderType(accountrepo.DefaultIDP), accountrepo.IdentityWithUser())
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 || identities[0].User.ID == uuid.Nil {
+		return nil, nil
+	}
+	return &identities[0], nil
+}
+
+// loadIdentityByVerifiedEmail returns the main identity of the user with the given verified email address, whatever
+// its case, or nil if there is no such user or if the email address is ambiguous
+func (s *credentialServiceImpl) loadIdentityByVerifiedEmail(ctx context.Context, email string) (*accountrepo.Identity, error) {
+	users, err := s.Repositories().Users().Query(accountrepo.UserFilterByVerifiedEmailIgnoreCase(email))
+	if err != nil {
+		return nil, err
+	}
+	if len(users) != 1 {
+		return nil, nil
+	}
+	identity, err := s.loadIdentityByUserID(ctx, users[0].ID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return identity, nil
+}
+
+// loadIdentityByUserID returns the main identity of the given user
+func (s *credentialServiceImpl) loadIdentityByUserID(ctx context.Context, userID uuid.UUID) (*accountrepo.Identity, error) {
+	identities, err := s.Repositories().Identities().Query(accountrepo.IdentityFilterByUserID(userID),
+		accountrepo.IdentityFilterByProviderType(accountrepo.DefaultIDP), accountrepo.IdentityWithUser())
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.NewNotFoundError("identity for user", userID.String())
+	}
+	return &identities[0], nil
+}
@@ -0,0 +1,292 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const validPassword = "Correct-Horse-42"
+
+type credentialServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	notificationServiceMock *testservice.NotificationServiceMock
+}
+
+func TestCredentialService(t *testing.T) {
+	suite.Run(t, &credentialServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *credentialServiceBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.notificationServiceMock = testservice.NewNotificationServiceMock(s.T())
+	s.Application = gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithNotificationService(s.notificationServiceMock))
+}
+
+func (s *credentialServiceBlackBoxTest) TestAuthenticate() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		code, err := s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		// then
+		require.NoError(t, err)
+		require.NotEmpty(t, code)
+		err = s.Application.CredentialService().CheckLoginCode(s.Ctx, code)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("ok with email", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		user.User().Email = "Login-" + uuid.NewV4().String() + "@example.com"
+		user.User().EmailVerified = true
+		err := s.Application.Users().Save(s.Ctx, user.User())
+		require.NoError(t, err)
+		err = s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		code, err := s.Application.CredentialService().Authenticate(s.Ctx, strings.ToLower(user.User().Email), validPassword)
+		// then
+		require.NoError(t, err)
+		require.NotEmpty(t, code)
+		profile, err := s.Application.CredentialService().RedeemLoginCode(s.Ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, user.Identity().Username, profile.Username)
+	})
+
+	s.T().Run("unverified email", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		user.User().Email = "login-" + uuid.NewV4().String() + "@example.com"
+		user.User().EmailVerified = false
+		err := s.Application.Users().Save(s.Ctx, user.User())
+		require.NoError(t, err)
+		err = s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.User().Email, validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("wrong password", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, "Wrong-Password-42")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown user", func(t *testing.T) {
+		// when
+		_, err := s.Application.CredentialService().Authenticate(s.Ctx, uuid.NewV4().String(), validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("no password set", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		_, err := s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("locked after too many failed attempts", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		for i := 0; i < s.Configuration.GetLocalLoginMaxFailedAttempts(); i++ {
+			_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, "Wrong-Password-42")
+			require.Error(t, err)
+		}
+		// when
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		credential, err := s.Application.CredentialRepository().Load(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		require.NotNil(t, credential.LockedUntil)
+	})
+
+	s.T().Run("banned user", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		user.Ban()
+		// when
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+}
+
+func (s *credentialServiceBlackBoxTest) TestRedeemLoginCode() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		code, err := s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		require.NoError(t, err)
+		// when
+		profile, err := s.Application.CredentialService().RedeemLoginCode(s.Ctx, code)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, user.Identity().Username, profile.Username)
+		assert.Equal(t, user.User().Email, profile.Email)
+		assert.Equal(t, user.IdentityID().String(), profile.Subject)
+		assert.True(t, profile.Approved)
+	})
+
+	s.T().Run("code can only be used once", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		code, err := s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		require.NoError(t, err)
+		_, err = s.Application.CredentialService().RedeemLoginCode(s.Ctx, code)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.CredentialService().RedeemLoginCode(s.Ctx, code)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		err = s.Application.CredentialService().CheckLoginCode(s.Ctx, code)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown code", func(t *testing.T) {
+		// when
+		_, err := s.Application.CredentialService().RedeemLoginCode(s.Ctx, uuid.NewV4().String())
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *credentialServiceBlackBoxTest) TestValidatePassword() {
+	for _, password := range []string{"Short-1", "no-uppercase-42", "NO-LOWERCASE-42", "No-Digits-Here"} {
+		s.T().Run(password, func(t *testing.T) {
+			err := s.Application.CredentialService().ValidatePassword(password)
+			require.Error(t, err)
+			require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	}
+	s.T().Run("ok", func(t *testing.T) {
+		err := s.Application.CredentialService().ValidatePassword(validPassword)
+		require.NoError(t, err)
+	})
+}
+
+func (s *credentialServiceBlackBoxTest) TestChangePassword() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		err = s.Application.CredentialService().ChangePassword(s.Ctx, user.IdentityID(), validPassword, "Battery-Staple-42")
+		// then
+		require.NoError(t, err)
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, "Battery-Staple-42")
+		require.NoError(t, err)
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, validPassword)
+		require.Error(t, err)
+	})
+
+	s.T().Run("wrong current password", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		// when
+		err = s.Application.CredentialService().ChangePassword(s.Ctx, user.IdentityID(), "Wrong-Password-42", "Battery-Staple-42")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+}
+
+func (s *credentialServiceBlackBoxTest) TestPasswordReset() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		err := s.Application.CredentialService().SetPassword(s.Ctx, user.IdentityID(), validPassword)
+		require.NoError(t, err)
+		var resetURL string
+		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
+		s.notificationServiceMock.SendMessageAsyncFunc = func(ctx context.Context, msg notification.Message, options ...rest.HTTPClientOption) (chan error, error) {
+			assert.Equal(t, "user.password.reset", msg.MessageType)
+			assert.Equal(t, user.IdentityID().String(), msg.TargetID)
+			resetURL = msg.Custom["resetURL"].(string)
+			return nil, nil
+		}
+		// when
+		err = s.Application.CredentialService().SendPasswordReset(s.Ctx, user.User().Email)
+		// then
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessageAsyncCounter)
+		code := resetURL[strings.Index(resetURL, "code=")+len("code="):]
+		err = s.Application.CredentialService().ResetPassword(s.Ctx, code, "Battery-Staple-42")
+		require.NoError(t, err)
+		_, err = s.Application.CredentialService().Authenticate(s.Ctx, user.Identity().Username, "Battery-Staple-42")
+		require.NoError(t, err)
+		// the code cannot be used twice
+		err = s.Application.CredentialService().ResetPassword(s.Ctx, code, "Another-Password-42")
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown user", func(t *testing.T) {
+		// given
+		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
+		// when
+		err := s.Application.CredentialService().SendPasswordReset(s.Ctx, uuid.NewV4().String())
+		// then
+		require.NoError(t, err)
+		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessageAsyncCounter)
+	})
+
+	s.T().Run("invalid code", func(t *testing.T) {
+		// when
+		err := s.Application.CredentialService().ResetPassword(s.Ctx, uuid.NewV4().String(), validPassword)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Session is the record of an administrator impersonating a user
+type Session struct {
+	ID        uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	CreatedAt time.Time
+	// AdminIdentityID is the identity of the administrator who impersonated the user
+	AdminIdentityID uuid.UUID `sql:"type:uuid" gorm:"column:admin_identity_id"`
+	// TargetIdentityID is the identity of the impersonated user
+	TargetIdentityID uuid.UUID `sql:"type:uuid" gorm:"column:target_identity_id"`
+	// Reason is the justification given by the administrator, e.g. a support ticket reference
+	Reason string
+	// TokenID is the ID of the access token issued for the session
+	TokenID uuid.UUID `sql:"type:uuid" gorm:"column:token_id"`
+	// ExpiresAt is the time at which the access token of the session expires
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Session) TableName() string {
+	return "impersonation_sessions"
+}
+
+// GormSessionRepository is the implementation of the storage interface for Session.
+type GormSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new storage type.
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &GormSessionRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormSessionRepository) TableName() string {
+	return "impersonation_sessions"
+}
+
+// SessionRepository represents the storage interface.
+type SessionRepository interface {
+	Create(ctx context.Context, session *Session) error
+	// ListForTarget returns the sessions in which the given identity was impersonated, most recent first
+	ListForTarget(ctx context.Context, targetIdentityID uuid.UUID) ([]Session, error)
+}
+
+// Create creates a new record.
+func (m *GormSessionRepository) Create(ctx context.Context, session *Session) error {
+	defer goa.MeasureSince([]string{"goa", "db", "impersonation_session", "create"}, time.Now())
+
+	if session.ID == uuid.Nil {
+		session.ID = uuid.NewV4()
+	}
+	err := m.db.Create(session).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"admin_identity_id":  session.AdminIdentityID,
+			"target_identity_id": session.TargetIdentityID,
+			"err":                err,
+		}, "unable to create the impersonation session")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"session_id": session.ID,
+	}, "impersonation session created!")
+	return nil
+}
+
+// ListForTarget returns the sessions in which the given identity was impersonated, most recent first
+func (m *GormSessionRepository) ListForTarget(ctx context.Context, targetIdentityID uuid.UUID) ([]Session, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "impersonation_session", "list"}, time.Now())
+
+	var rows []Session
+	err := m.db.Table(m.TableName()).Where("target_identity_id = ?", targetIdentityID).Order("created_at desc").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
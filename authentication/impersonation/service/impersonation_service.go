@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/impersonation/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
+
+	"github.com/satori/go.uuid"
+)
+
+// ImpersonationServiceConfiguration the configuration for the impersonation service
+type ImpersonationServiceConfiguration interface {
+	GetImpersonationTokenExpiry() time.Duration
+	IsImpersonationNotificationEnabled() bool
+}
+
+// NewImpersonationService creates a new service to let user administrators impersonate users
+func NewImpersonationService(ctx servicecontext.ServiceContext, config ImpersonationServiceConfiguration) service.ImpersonationService {
+	return &impersonationServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// impersonationServiceImpl implements the ImpersonationService to let user administrators impersonate users
+type impersonationServiceImpl struct {
+	base.BaseService
+	config ImpersonationServiceConfiguration
+}
+
+// Impersonate issues a short-lived access token for the target identity on behalf of the given administrator, who
+// must have the `manage_user` scope of the system resource. The token cannot be refreshed and its `act` claim
+// identifies the administrator. Each session is recorded along with the reason given by the administrator.
+func (s *impersonationServiceImpl) Impersonate(ctx context.Context, adminIdentityID, targetIdentityID uuid.UUID, reason string) (*manager.TokenSet, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.NewBadParameterError("reason", reason).Expected("not empty")
+	}
+	if uuid.Equal(adminIdentityID, targetIdentityID) {
+		return nil, errors.NewBadParameterError("identity_id", targetIdentityID).Expected("another identity than the current one")
+	}
+	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+
+	err = s.Services().PermissionService().RequireUserAdmin(ctx, adminIdentityID)
+	if err != nil {
+		return nil, err
+	}
+	admin, err := s.Repositories().Identities().LoadWithUser(ctx, adminIdentityID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.Repositories().Identities().LoadWithUser(ctx, targetIdentityID)
+	if err != nil {
+		return nil, err
+	}
+	if target.User.Banned {
+		return nil, errors.NewForbiddenError("banned users cannot be impersonated")
+	}
+	// administrators cannot be impersonated, otherwise impersonation could be used to gain their privileges
+	systemResources, err := s.Repositories().ResourceRepository().FindWithRoleByResourceTypeAndIdentity(ctx, authorization.ResourceTypeSystem, targetIdentityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(systemResources) > 0 {
+		return nil, errors.NewForbiddenError("users with a role on the system resource cannot be impersonated")
+	}
+
+	impersonationToken, err := tokenManager.GenerateImpersonationTokenForIdentity(ctx, *target, *admin, s.config.GetImpersonationTokenExpiry())
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	registeredToken, err := s.Services().TokenService().RegisterToken(ctx, targetIdentityID, impersonationToken.AccessToken, token.TOKEN_TYPE_ACCESS, nil)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	session := &repository.Session{
+		AdminIdentityID:  adminIdentityID,
+		TargetIdentityID: targetIdentityID,
+		Reason:           reason,
+		TokenID:          registeredToken.TokenID,
+		ExpiresAt:        registeredToken.ExpiryTime,
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().ImpersonationSessionRepository().Create(ctx, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"session_id":         session.ID,
+		"admin_identity_id":  adminIdentityID,
+		"target_identity_id": targetIdentityID,
+		"reason":             reason,
+		"expires_at":         session.ExpiresAt,
+	}, "user impersonated by an administrator")
+
+	if s.config.IsImpersonationNotificationEnabled() {
+		expiryDate := session.ExpiresAt.Format("Mon Jan 2 15:04:05 MST 2006")
+		msg := notification.NewImpersonationEmail(targetIdentityID.String(), admin.Username, reason, expiryDate)
+		_, err = s.Services().NotificationService().SendMessageAsync(ctx, msg)
+		if err != nil {
+			// the session is already recorded, so the token is still returned
+			log.Error(ctx, map[string]interface{}{
+				"session_id":         session.ID,
+				"target_identity_id": targetIdentityID,
+				"err":                err,
+			}, "unable to send the impersonation notification")
+		}
+	}
+
+	tokenSet, err := tokenManager.ConvertToken(*impersonationToken)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	tokenSet.RefreshToken = nil
+	tokenSet.RefreshExpiresIn = nil
+	return tokenSet, nil
+}
+
+// ListSessions returns the impersonation sessions of the target identity, most recent first. The given administrator
+// must have the `manage_user` scope of the system resource.
+func (s *impersonationServiceImpl) ListSessions(ctx context.Context, adminIdentityID, targetIdentityID uuid.UUID) ([]repository.Session, error) {
+	err := s.Services().PermissionService().RequireUserAdmin(ctx, adminIdentityID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Repositories().Identities().CheckExists(ctx, targetIdentityID.String())
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().ImpersonationSessionRepository().ListForTarget(ctx, targetIdentityID)
+}
@@ -0,0 +1,109 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type impersonationServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestImpersonationService(t *testing.T) {
+	suite.Run(t, &impersonationServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *impersonationServiceBlackBoxTest) TestImpersonate() {
+
+	// given
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	systemResource := g.CreateResource(g.LoadResourceType(authorization.ResourceTypeSystem))
+	systemResource.AddRole(admin, g.RoleByNameAndResourceType(authorization.SystemUserAdminRole, authorization.ResourceTypeSystem))
+	adminCtx, err := testtoken.EmbedIdentityInContext(*admin.Identity())
+	require.NoError(s.T(), err)
+	goajwt.ContextJWT(adminCtx).Claims.(jwt.MapClaims)["acr"] = mfa.ACRMultiFactor
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		target := g.CreateUser()
+		// when
+		tokenSet, err := s.Application.ImpersonationService().Impersonate(adminCtx, admin.IdentityID(), target.IdentityID(), "support ticket 1234")
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, tokenSet.RefreshToken)
+		claims, err := testtoken.TokenManager.ParseToken(adminCtx, *tokenSet.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, target.IdentityID().String(), claims.Subject)
+		require.NotNil(t, claims.Actor)
+		assert.Equal(t, admin.IdentityID().String(), claims.Actor.Subject)
+		assert.Equal(t, mfa.ACRNone, claims.ACR)
+		// the session is recorded
+		sessions, err := s.Application.ImpersonationService().ListSessions(adminCtx, admin.IdentityID(), target.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, admin.IdentityID(), sessions[0].AdminIdentityID)
+		assert.Equal(t, "support ticket 1234", sessions[0].Reason)
+		assert.Equal(t, claims.Id, sessions[0].TokenID.String())
+	})
+
+	s.T().Run("missing reason", func(t *testing.T) {
+		// when
+		_, err := s.Application.ImpersonationService().Impersonate(adminCtx, admin.IdentityID(), g.CreateUser().IdentityID(), " ")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("not a user administrator", func(t *testing.T) {
+		// given
+		user := g.CreateUser()
+		ctx, err := testtoken.EmbedIdentityInContext(*user.Identity())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.ImpersonationService().Impersonate(ctx, user.IdentityID(), g.CreateUser().IdentityID(), "support ticket 1234")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("single factor token", func(t *testing.T) {
+		// given the deployment requires a second factor for managing the users
+		err := s.DB.Exec("UPDATE resource_type_scope SET min_acr = ? WHERE name = ?", mfa.ACRMultiFactor, authorization.ManageUserSystemScope).Error
+		require.NoError(t, err)
+		defer func() {
+			err := s.DB.Exec("UPDATE resource_type_scope SET min_acr = NULL WHERE name = ?", authorization.ManageUserSystemScope).Error
+			require.NoError(t, err)
+		}()
+		ctx, err := testtoken.EmbedIdentityInContext(*admin.Identity())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.ImpersonationService().Impersonate(ctx, admin.IdentityID(), g.CreateUser().IdentityID(), "support ticket 1234")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.InsufficientAuthenticationError{}, errs.Cause(err))
+	})
+
+	s.T().Run("other administrator", func(t *testing.T) {
+		// given
+		otherAdmin := g.CreateUser()
+		systemResource.AddRole(otherAdmin, g.RoleByNameAndResourceType(authorization.SystemUserAdminRole, authorization.ResourceTypeSystem))
+		// when
+		_, err := s.Application.ImpersonationService().Impersonate(adminCtx, admin.IdentityID(), otherAdmin.IdentityID(), "support ticket 1234")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+}
@@ -0,0 +1,3 @@
+// Package mfa provides the time-based one-time password (TOTP) second factor of users, and the authentication
+// context classes which are recorded in the `acr` and `amr` claims of the tokens issued after login.
+package mfa
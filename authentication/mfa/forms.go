@@ -0,0 +1,89 @@
+package mfa
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	errs "github.com/pkg/errors"
+)
+
+// ChallengeFormData contains the values rendered in the multi-factor authentication challenge form
+type ChallengeFormData struct {
+	// Action is the URL to which the form is posted
+	Action string
+	// Challenge is the ID of the challenge, which must be posted back with the code
+	Challenge string
+	// Enrolment is the TOTP secret to register in an authenticator app, if the user has not enrolled yet
+	Enrolment *PendingEnrolment
+	// Error is the message to display when a previous attempt failed, if any
+	Error string
+}
+
+// RecoveryCodesPageData contains the values rendered in the page which shows the new recovery codes of the user
+type RecoveryCodesPageData struct {
+	// RecoveryCodes are the codes to display, they are not shown again
+	RecoveryCodes []string
+	// ContinueURL is the URL to which the user goes once they have saved their codes
+	ContinueURL string
+}
+
+// RenderChallengeForm returns the HTML of the multi-factor authentication challenge form
+func RenderChallengeForm(data ChallengeFormData) ([]byte, error) {
+	return render(challengeFormTemplate, data)
+}
+
+// RenderRecoveryCodesPage returns the HTML of the page which shows the new recovery codes of the user
+func RenderRecoveryCodesPage(data RecoveryCodesPageData) ([]byte, error) {
+	return render(recoveryCodesPageTemplate, data)
+}
+
+func render(tmpl *template.Template, data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to render template '%s'", tmpl.Name())
+	}
+	return buf.Bytes(), nil
+}
+
+var layoutTemplate = `{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8"/>
+<meta name="viewport" content="width=device-width, initial-scale=1"/>
+<title>{{.}}</title>
+</head>
+<body>
+<h1>{{.}}</h1>
+{{end}}{{define "footer"}}</body>
+</html>{{end}}`
+
+// otpauthURL marks the provisioning URI as safe, html/template would otherwise reject its non-http scheme
+func otpauthURL(uri string) template.URL {
+	if !strings.HasPrefix(uri, "otpauth://") {
+		return template.URL("#")
+	}
+	return template.URL(uri)
+}
+
+var challengeFormTemplate = template.Must(template.Must(template.New("challenge").Funcs(template.FuncMap{"otpauth": otpauthURL}).Parse(layoutTemplate)).Parse(`{{template "header" "Two-factor authentication"}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Enrolment}}<p>Two-factor authentication is required for your account. Add the following key to your authenticator app, then enter the code that it shows.</p>
+<p><code>{{.Enrolment.Secret}}</code></p>
+<p><a href="{{otpauth .Enrolment.ProvisioningURI}}">Open in authenticator app</a></p>
+{{else}}<p>Enter the code shown by your authenticator app, or one of your recovery codes.</p>
+{{end}}<form method="post" action="{{.Action}}">
+<input type="hidden" name="challenge" value="{{.Challenge}}"/>
+<p><label for="code">Code</label><br/><input type="text" id="code" name="code" autocomplete="one-time-code" autofocus required/></p>
+<p><input type="submit" value="Verify"/></p>
+</form>
+{{template "footer"}}`))
+
+var recoveryCodesPageTemplate = template.Must(template.Must(template.New("recovery").Parse(layoutTemplate)).Parse(`{{template "header" "Recovery codes"}}
+<p>Two-factor authentication is now enabled. Keep these recovery codes in a safe place: each of them can be used once in place of a code from your authenticator app. They will not be shown again.</p>
+<ul>
+{{range .RecoveryCodes}}<li><code>{{.}}</code></li>
+{{end}}</ul>
+<p><a href="{{.ContinueURL}}">Continue</a></p>
+{{template "footer"}}`))
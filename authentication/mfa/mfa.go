@@ -0,0 +1,49 @@
+package mfa
+
+import (
+	"strconv"
+)
+
+// Authentication context classes, recorded in the `acr` claim of the tokens
+const (
+	// ACRNone is the class of tokens for which the way the user authenticated is unknown
+	ACRNone = "0"
+	// ACRSingleFactor is the class of tokens issued after the user authenticated with a single factor
+	ACRSingleFactor = "1"
+	// ACRMultiFactor is the class of tokens issued after the user also entered their second factor
+	ACRMultiFactor = "2"
+)
+
+// Authentication methods, recorded in the `amr` claim of the tokens (see RFC 8176)
+const (
+	// AMRPassword means that the user entered a password known by this service
+	AMRPassword = "pwd"
+	// AMRFederated means that the user authenticated with an external identity provider
+	AMRFederated = "fed"
+	// AMROneTimePassword means that the user entered a TOTP or recovery code
+	AMROneTimePassword = "otp"
+	// AMRMultiFactor means that the user authenticated with more than one factor
+	AMRMultiFactor = "mfa"
+)
+
+// PendingEnrolment contains what the user needs to register their TOTP secret in an authenticator app
+type PendingEnrolment struct {
+	// Secret is the base32 encoded TOTP secret, for manual entry
+	Secret string
+	// ProvisioningURI is the `otpauth://` URI of the secret, usually rendered as a QR code
+	ProvisioningURI string
+}
+
+// ACRSatisfies returns true if the authentication context class `actual` is at least as strong as `required`.
+// Unknown classes are treated as ACRNone.
+func ACRSatisfies(actual string, required string) bool {
+	return acrLevel(actual) >= acrLevel(required)
+}
+
+func acrLevel(acr string) int {
+	level, err := strconv.Atoi(acr)
+	if err != nil || level < 0 {
+		return 0
+	}
+	return level
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Challenge is a login which is waiting for the user to enter their second factor. It keeps what is needed to
+// complete the login once they have.
+type Challenge struct {
+	ID         uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"column:identity_id"`
+	CreatedAt  time.Time
+	// Referrer is the URL to which the user is redirected, with their tokens, once the login is complete
+	Referrer string
+	// AMR is the comma separated list of authentication methods which the user has already completed
+	AMR string `gorm:"column:amr"`
+	// FailedAttempts is the number of invalid codes entered for this challenge
+	FailedAttempts int `gorm:"column:failed_attempts"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Challenge) TableName() string {
+	return "mfa_challenges"
+}
+
+// Methods returns the authentication methods which the user has already completed
+func (m Challenge) Methods() []string {
+	if m.AMR == "" {
+		return []string{}
+	}
+	return strings.Split(m.AMR, ",")
+}
+
+// GormChallengeRepository is the implementation of the storage interface for Challenge.
+type GormChallengeRepository struct {
+	db *gorm.DB
+}
+
+// NewChallengeRepository creates a new storage type.
+func NewChallengeRepository(db *gorm.DB) ChallengeRepository {
+	return &GormChallengeRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormChallengeRepository) TableName() string {
+	return "mfa_challenges"
+}
+
+// ChallengeRepository represents the storage interface.
+type ChallengeRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*Challenge, error)
+	Create(ctx context.Context, challenge *Challenge) error
+	// RecordFailedAttempt increments the number of failed attempts of the challenge
+	RecordFailedAttempt(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpired removes the challenges created before the given time
+	DeleteExpired(ctx context.Context, createdBefore time.Time) error
+}
+
+// Load returns the challenge with the given ID
+func (m *GormChallengeRepository) Load(ctx context.Context, id uuid.UUID) (*Challenge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "load"}, time.Now())
+
+	var native Challenge
+	err := m.db.Table(m.TableName()).Where("id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("mfa challenge", id.String())
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return &native, nil
+}
+
+// Create creates a new record.
+func (m *GormChallengeRepository) Create(ctx context.Context, challenge *Challenge) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "create"}, time.Now())
+
+	if challenge.ID == uuid.Nil {
+		challenge.ID = uuid.NewV4()
+	}
+	err := m.db.Create(challenge).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": challenge.IdentityID,
+			"err":         err,
+		}, "unable to create the mfa challenge")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"challenge_id": challenge.ID,
+		"identity_id":  challenge.IdentityID,
+	}, "mfa challenge created!")
+	return nil
+}
+
+// RecordFailedAttempt increments the number of failed attempts of the challenge
+func (m *GormChallengeRepository) RecordFailedAttempt(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "failed_attempt"}, time.Now())
+
+	result := m.db.Table(m.TableName()).Where("id = ?", id).Update("failed_attempts", gorm.Expr("failed_attempts + 1"))
+	if result.Error != nil {
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa challenge", id.String())
+	}
+	return nil
+}
+
+// Delete removes the challenge with the given ID. This is a hard delete!
+func (m *GormChallengeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "delete"}, time.Now())
+
+	result := m.db.Delete(&Challenge{ID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"challenge_id": id,
+			"err":          result.Error,
+		}, "unable to delete the mfa challenge")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa challenge", id.String())
+	}
+	return nil
+}
+
+// DeleteExpired removes the challenges created before the given time. This is a hard delete!
+func (m *GormChallengeRepository) DeleteExpired(ctx context.Context, createdBefore time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "delete_expired"}, time.Now())
+
+	err := m.db.Where("created_at < ?", createdBefore).Delete(&Challenge{}).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	return nil
+}
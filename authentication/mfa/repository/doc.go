@@ -0,0 +1,2 @@
+// Package repository provides the wrappers for multi-factor authentication related database interactions.
+package repository
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Enrolment is the TOTP second factor of an identity
+type Enrolment struct {
+	// IdentityID is the primary key, an identity has at most one TOTP second factor
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:identity_id"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	// Secret is the base32 encoded TOTP secret
+	Secret string
+	// ConfirmedAt is the time at which the user proved that they could generate codes, the enrolment is pending until then
+	ConfirmedAt *time.Time `gorm:"column:confirmed_at"`
+	// LastUsedStep is the TOTP time step of the last accepted code, which cannot be used again
+	LastUsedStep int64 `gorm:"column:last_used_step"`
+	// FailedAttempts is the number of consecutive invalid codes
+	FailedAttempts int `gorm:"column:failed_attempts"`
+	// LockedUntil is the time until which all codes are rejected after too many invalid ones
+	LockedUntil *time.Time `gorm:"column:locked_until"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Enrolment) TableName() string {
+	return "mfa_enrolments"
+}
+
+// IsConfirmed returns true if the enrolment is active
+func (m Enrolment) IsConfirmed() bool {
+	return m.ConfirmedAt != nil
+}
+
+// IsLocked returns true if codes are rejected at the given time
+func (m Enrolment) IsLocked(now time.Time) bool {
+	return m.LockedUntil != nil && now.Before(*m.LockedUntil)
+}
+
+// GormEnrolmentRepository is the implementation of the storage interface for Enrolment.
+type GormEnrolmentRepository struct {
+	db *gorm.DB
+}
+
+// NewEnrolmentRepository creates a new storage type.
+func NewEnrolmentRepository(db *gorm.DB) EnrolmentRepository {
+	return &GormEnrolmentRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormEnrolmentRepository) TableName() string {
+	return "mfa_enrolments"
+}
+
+// EnrolmentRepository represents the storage interface.
+type EnrolmentRepository interface {
+	Load(ctx context.Context, identityID uuid.UUID) (*Enrolment, error)
+	Create(ctx context.Context, enrolment *Enrolment) error
+	Save(ctx context.Context, enrolment *Enrolment) error
+	Delete(ctx context.Context, identityID uuid.UUID) error
+}
+
+// Load returns the enrolment of the given identity
+func (m *GormEnrolmentRepository) Load(ctx context.Context, identityID uuid.UUID) (*Enrolment, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_enrolment", "load"}, time.Now())
+
+	var native Enrolment
+	err := m.db.Table(m.TableName()).Where("identity_id = ?", identityID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("mfa enrolment", identityID.String())
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return &native, nil
+}
+
+// Create creates a new record.
+func (m *GormEnrolmentRepository) Create(ctx context.Context, enrolment *Enrolment) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_enrolment", "create"}, time.Now())
+
+	err := m.db.Create(enrolment).Error
+	if err != nil {
+		if gormsupport.IsUniqueViolation(err, "mfa_enrolments_pkey") {
+			return errors.NewDataConflictError("mfa enrolment already exists for identity " + enrolment.IdentityID.String())
+		}
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": enrolment.IdentityID,
+			"err":         err,
+		}, "unable to create the mfa enrolment")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": enrolment.IdentityID,
+	}, "mfa enrolment created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormEnrolmentRepository) Save(ctx context.Context, enrolment *Enrolment) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_enrolment", "save"}, time.Now())
+
+	// update with a map so that zero values are updated too
+	result := m.db.Table(m.TableName()).Where("identity_id = ?", enrolment.IdentityID).Updates(map[string]interface{}{
+		"secret":          enrolment.Secret,
+		"confirmed_at":    enrolment.ConfirmedAt,
+		"last_used_step":  enrolment.LastUsedStep,
+		"failed_attempts": enrolment.FailedAttempts,
+		"locked_until":    enrolment.LockedUntil,
+		"updated_at":      time.Now(),
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": enrolment.IdentityID,
+			"err":         result.Error,
+		}, "unable to update the mfa enrolment")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa enrolment", enrolment.IdentityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": enrolment.IdentityID,
+	}, "mfa enrolment saved!")
+	return nil
+}
+
+// Delete removes the enrolment of the given identity. This is a hard delete!
+func (m *GormEnrolmentRepository) Delete(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_enrolment", "delete"}, time.Now())
+
+	result := m.db.Delete(&Enrolment{IdentityID: identityID})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         result.Error,
+		}, "unable to delete the mfa enrolment")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa enrolment", identityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "mfa enrolment deleted!")
+	return nil
+}
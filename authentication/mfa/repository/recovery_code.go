@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// RecoveryCode is a single use code which may be used in place of a TOTP code, e.g. when the user lost their device
+type RecoveryCode struct {
+	ID         uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key"`
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"column:identity_id"`
+	CreatedAt  time.Time
+	// CodeHash is the hex encoded SHA-256 hash of the code, the code itself is only shown to the user once
+	CodeHash string `gorm:"column:code_hash"`
+	// UsedAt is the time at which the code was used, if it was
+	UsedAt *time.Time `gorm:"column:used_at"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// GormRecoveryCodeRepository is the implementation of the storage interface for RecoveryCode.
+type GormRecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository creates a new storage type.
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &GormRecoveryCodeRepository{db: db}
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormRecoveryCodeRepository) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// RecoveryCodeRepository represents the storage interface.
+type RecoveryCodeRepository interface {
+	Create(ctx context.Context, code *RecoveryCode) error
+	// Use marks the unused code of the identity with the given hash as used, and returns false if there is no such code
+	Use(ctx context.Context, identityID uuid.UUID, codeHash string) (bool, error)
+	// CountUnused returns the number of recovery codes which the identity has not used yet
+	CountUnused(ctx context.Context, identityID uuid.UUID) (int, error)
+	// DeleteForIdentity removes all recovery codes of the identity
+	DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error
+}
+
+// Create creates a new record.
+func (m *GormRecoveryCodeRepository) Create(ctx context.Context, code *RecoveryCode) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "create"}, time.Now())
+
+	if code.ID == uuid.Nil {
+		code.ID = uuid.NewV4()
+	}
+	err := m.db.Create(code).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": code.IdentityID,
+			"err":         err,
+		}, "unable to create the mfa recovery code")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Use marks the unused code of the identity with the given hash as used
+func (m *GormRecoveryCodeRepository) Use(ctx context.Context, identityID uuid.UUID, codeHash string) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "use"}, time.Now())
+
+	result := m.db.Table(m.TableName()).
+		Where("identity_id = ? AND code_hash = ? AND used_at IS NULL", identityID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         result.Error,
+		}, "unable to use the mfa recovery code")
+		return false, errs.WithStack(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnused returns the number of recovery codes which the identity has not used yet
+func (m *GormRecoveryCodeRepository) CountUnused(ctx context.Context, identityID uuid.UUID) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "count"}, time.Now())
+
+	var count int
+	err := m.db.Table(m.TableName()).Where("identity_id = ? AND used_at IS NULL", identityID).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
+// DeleteForIdentity removes all recovery codes of the identity. This is a hard delete!
+func (m *GormRecoveryCodeRepository) DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "delete"}, time.Now())
+
+	err := m.db.Where("identity_id = ?", identityID).Delete(&RecoveryCode{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to delete the mfa recovery codes")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,519 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/satori/go.uuid"
+)
+
+// MFAServiceConfiguration the configuration for the MFA service
+type MFAServiceConfiguration interface {
+	IsMFARequired() bool
+	GetMFAIssuer() string
+	GetMFAClockSkew() int
+	GetMFARecoveryCodesCount() int
+	GetMFAChallengeExpiry() time.Duration
+	GetMFAChallengeMaxAttempts() int
+	GetMFAMaxFailedAttempts() int
+	GetMFALockoutDuration() time.Duration
+}
+
+// invalidCodeMessage is the same whether a TOTP or a recovery code was entered
+const invalidCodeMessage = "invalid multi-factor authentication code"
+
+// lockedMessage is returned for any code entered while the second factor is locked
+const lockedMessage = "too many invalid multi-factor authentication codes, please try again later"
+
+// recoveryCodeAlphabet excludes the characters which are easily confused when read from a print out
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// NewMFAService creates a new service to manage the second factor of users
+func NewMFAService(ctx servicecontext.ServiceContext, config MFAServiceConfiguration) service.MFAService {
+	return &mfaServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// mfaServiceImpl implements the MFAService to manage the second factor of users
+type mfaServiceImpl struct {
+	base.BaseService
+	config MFAServiceConfiguration
+}
+
+// Enrol starts the enrolment of a TOTP second factor for the given identity. If an enrolment is already pending then
+// its secret is returned again, so that the user can retry with the same authenticator app entry.
+func (s *mfaServiceImpl) Enrol(ctx context.Context, identityID uuid.UUID) (*mfa.PendingEnrolment, error) {
+	var result *mfa.PendingEnrolment
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		result, err = s.enrol(ctx, identityID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// enrol must be invoked within a transaction
+func (s *mfaServiceImpl) enrol(ctx context.Context, identityID uuid.UUID) (*mfa.PendingEnrolment, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, identityID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return nil, err
+		}
+		secret, err := mfa.GenerateSecret()
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		enrolment = &repository.Enrolment{
+			IdentityID: identityID,
+			Secret:     secret,
+		}
+		err = s.Repositories().MFAEnrolmentRepository().Create(ctx, enrolment)
+		if err != nil {
+			return nil, err
+		}
+	} else if enrolment.IsConfirmed() {
+		return nil, errors.NewDataConflictError("multi-factor authentication is already enabled")
+	}
+	return &mfa.PendingEnrolment{
+		Secret:          enrolment.Secret,
+		ProvisioningURI: mfa.ProvisioningURI(s.config.GetMFAIssuer(), identity.Username, enrolment.Secret),
+	}, nil
+}
+
+// ConfirmEnrolment activates the pending enrolment of the given identity if the code was generated with its
+// secret, and returns a new set of recovery codes
+func (s *mfaServiceImpl) ConfirmEnrolment(ctx context.Context, identityID uuid.UUID, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.ExecuteInTransaction(func() error {
+		enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		if enrolment.IsConfirmed() {
+			return errors.NewDataConflictError("multi-factor authentication is already enabled")
+		}
+		recoveryCodes, err = s.confirm(ctx, enrolment, code)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "multi-factor authentication enabled")
+	return recoveryCodes, nil
+}
+
+// confirm must be invoked within a transaction
+func (s *mfaServiceImpl) confirm(ctx context.Context, enrolment *repository.Enrolment, code string) ([]string, error) {
+	step, ok := mfa.ValidateCode(enrolment.Secret, code, time.Now(), s.config.GetMFAClockSkew(), enrolment.LastUsedStep)
+	if !ok {
+		return nil, errors.NewBadParameterErrorFromString("code", code, invalidCodeMessage)
+	}
+	now := time.Now()
+	enrolment.ConfirmedAt = &now
+	enrolment.LastUsedStep = step
+	err := s.Repositories().MFAEnrolmentRepository().Save(ctx, enrolment)
+	if err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(ctx, enrolment.IdentityID)
+}
+
+// Disable removes the second factor of the given identity, after verifying the code
+func (s *mfaServiceImpl) Disable(ctx context.Context, identityID uuid.UUID, code string) error {
+	err := s.verifyAndExecute(ctx, identityID, code, func() error {
+		err := s.Repositories().MFARecoveryCodeRepository().DeleteForIdentity(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().MFAEnrolmentRepository().Delete(ctx, identityID)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "multi-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the given identity, after verifying the code
+func (s *mfaServiceImpl) RegenerateRecoveryCodes(ctx context.Context, identityID uuid.UUID, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.verifyAndExecute(ctx, identityID, code, func() error {
+		var err error
+		recoveryCodes, err = s.generateRecoveryCodes(ctx, identityID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// ChallengeRequired returns true if the identity has enabled multi-factor authentication, or if it is required
+// for all users
+func (s *mfaServiceImpl) ChallengeRequired(ctx context.Context, identityID uuid.UUID) (bool, error) {
+	if s.config.IsMFARequired() {
+		return true, nil
+	}
+	enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, identityID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return enrolment.IsConfirmed(), nil
+}
+
+// CreateChallenge records a login which is waiting for the second factor of the user, and returns its ID
+func (s *mfaServiceImpl) CreateChallenge(ctx context.Context, identityID uuid.UUID, referrer string, amr []string) (uuid.UUID, error) {
+	challenge := &repository.Challenge{
+		IdentityID: identityID,
+		Referrer:   referrer,
+		AMR:        strings.Join(amr, ","),
+	}
+	err := s.ExecuteInTransaction(func() error {
+		// housekeeping, so that abandoned logins do not pile up
+		err := s.Repositories().MFAChallengeRepository().DeleteExpired(ctx, time.Now().Add(-s.config.GetMFAChallengeExpiry()))
+		if err != nil {
+			return err
+		}
+		return s.Repositories().MFAChallengeRepository().Create(ctx, challenge)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return challenge.ID, nil
+}
+
+// LoadChallenge returns the challenge with the given ID. If the user has not enrolled yet but multi-factor
+// authentication is required then the pending enrolment to complete is returned too.
+func (s *mfaServiceImpl) LoadChallenge(ctx context.Context, challengeID uuid.UUID) (*repository.Challenge, *mfa.PendingEnrolment, error) {
+	var challenge *repository.Challenge
+	var pending *mfa.PendingEnrolment
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		challenge, err = s.loadChallenge(ctx, challengeID)
+		if err != nil {
+			return err
+		}
+		enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, challenge.IdentityID)
+		if err == nil && enrolment.IsConfirmed() {
+			return nil
+		}
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+		}
+		pending, err = s.enrol(ctx, challenge.IdentityID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return challenge, pending, nil
+}
+
+// CompleteChallenge verifies the code entered for the challenge with the given ID and consumes the challenge.
+// If the user is completing their enrolment then the enrolment is confirmed and the new recovery codes are returned.
+// After too many invalid codes the challenge is removed and the user has to login again.
+func (s *mfaServiceImpl) CompleteChallenge(ctx context.Context, challengeID uuid.UUID, code string) (*repository.Challenge, []string, error) {
+	var challenge *repository.Challenge
+	var recoveryCodes []string
+	var codeErr error
+
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		challenge, err = s.loadChallenge(ctx, challengeID)
+		if err != nil {
+			return err
+		}
+		enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, challenge.IdentityID)
+		if err != nil {
+			return err
+		}
+		if enrolment.IsLocked(time.Now()) {
+			codeErr = errors.NewUnauthorizedError(lockedMessage)
+			return nil
+		}
+		if enrolment.IsConfirmed() {
+			_, err = s.verifyEnrolment(ctx, enrolment, code)
+		} else {
+			recoveryCodes, err = s.confirm(ctx, enrolment, code)
+		}
+		if err != nil {
+			if !isInvalidCodeError(err) {
+				return err
+			}
+			// the failed attempt must be committed, so the error is only returned once the transaction is complete
+			codeErr = errors.NewUnauthorizedError(invalidCodeMessage)
+			err = s.recordFailedAttempt(ctx, enrolment)
+			if err != nil {
+				return err
+			}
+			if challenge.FailedAttempts+1 >= s.config.GetMFAChallengeMaxAttempts() {
+				log.Warn(ctx, map[string]interface{}{
+					"challenge_id": challengeID,
+					"identity_id":  challenge.IdentityID,
+				}, "too many invalid multi-factor authentication codes, the login is cancelled")
+				return s.Repositories().MFAChallengeRepository().Delete(ctx, challengeID)
+			}
+			return s.Repositories().MFAChallengeRepository().RecordFailedAttempt(ctx, challengeID)
+		}
+		err = s.resetFailedAttempts(ctx, enrolment)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().MFAChallengeRepository().Delete(ctx, challengeID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if codeErr != nil {
+		return nil, nil, codeErr
+	}
+	return challenge, recoveryCodes, nil
+}
+
+// StepUp verifies the code entered by the user of the current token and issues new tokens of the multi-factor
+// authentication context class, so that they can access the resources which require it
+func (s *mfaServiceImpl) StepUp(ctx context.Context, identityID uuid.UUID, code string) (*manager.TokenSet, error) {
+	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+
+	err = s.verifyAndExecute(ctx, identityID, code, func() error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	if identity.User.Banned {
+		return nil, errors.NewUnauthorizedError("unauthorized access")
+	}
+
+	amr := manager.ContextAuthenticationMethods(ctx)
+	for _, method := range []string{mfa.AMROneTimePassword, mfa.AMRMultiFactor} {
+		if !contains(amr, method) {
+			amr = append(amr, method)
+		}
+	}
+	userToken, err := tokenManager.GenerateUserTokenForIdentity(ctx, *identity, false,
+		manager.WithAuthenticationContext(mfa.ACRMultiFactor, amr))
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.Services().TokenService().RegisterToken(ctx, identityID, userToken.AccessToken, token.TOKEN_TYPE_ACCESS, nil)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	_, err = s.Services().TokenService().RegisterToken(ctx, identityID, userToken.RefreshToken, token.TOKEN_TYPE_REFRESH, nil)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "multi-factor authentication step-up completed")
+	return tokenManager.ConvertToken(*userToken)
+}
+
+// loadChallenge returns the challenge with the given ID, or a not found error if it has expired
+func (s *mfaServiceImpl) loadChallenge(ctx context.Context, challengeID uuid.UUID) (*repository.Challenge, error) {
+	challenge, err := s.Repositories().MFAChallengeRepository().Load(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.CreatedAt.Add(s.config.GetMFAChallengeExpiry()).Before(time.Now()) {
+		return nil, errors.NewNotFoundError("mfa challenge", challengeID.String())
+	}
+	return challenge, nil
+}
+
+// verifyAndExecute checks the TOTP or recovery code of the confirmed enrolment of the given identity and then
+// executes the given function, within a single transaction. Invalid codes are counted, and after too many of them
+// all codes are rejected for the configured duration, so that the codes cannot be guessed.
+func (s *mfaServiceImpl) verifyAndExecute(ctx context.Context, identityID uuid.UUID, code string, todo func() error) error {
+	var codeErr error
+	err := s.ExecuteInTransaction(func() error {
+		enrolment, err := s.Repositories().MFAEnrolmentRepository().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		if !enrolment.IsConfirmed() {
+			return errors.NewNotFoundError("mfa enrolment", identityID.String())
+		}
+		if enrolment.IsLocked(time.Now()) {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id":  identityID,
+				"locked_until": enrolment.LockedUntil,
+			}, "multi-factor authentication code entered for a locked enrolment")
+			codeErr = errors.NewUnauthorizedError(lockedMessage)
+			return nil
+		}
+		_, err = s.verifyEnrolment(ctx, enrolment, code)
+		if err != nil {
+			if !isInvalidCodeError(err) {
+				return err
+			}
+			// the failed attempt must be committed, so the error is only returned once the transaction is complete
+			codeErr = errors.NewUnauthorizedError(invalidCodeMessage)
+			return s.recordFailedAttempt(ctx, enrolment)
+		}
+		err = s.resetFailedAttempts(ctx, enrolment)
+		if err != nil {
+			return err
+		}
+		return todo()
+	})
+	if err != nil {
+		return err
+	}
+	return codeErr
+}
+
+// recordFailedAttempt counts an invalid code for the enrolment, and locks it after too many consecutive ones.
+// It must be invoked within a transaction.
+func (s *mfaServiceImpl) recordFailedAttempt(ctx context.Context, enrolment *repository.Enrolment) error {
+	enrolment.FailedAttempts++
+	if enrolment.FailedAttempts >= s.config.GetMFAMaxFailedAttempts() {
+		lockedUntil := time.Now().Add(s.config.GetMFALockoutDuration())
+		enrolment.LockedUntil = &lockedUntil
+		enrolment.FailedAttempts = 0
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":  enrolment.IdentityID,
+			"locked_until": lockedUntil,
+		}, "multi-factor authentication locked after too many invalid codes")
+	}
+	return s.Repositories().MFAEnrolmentRepository().Save(ctx, enrolment)
+}
+
+// resetFailedAttempts clears the invalid codes counted for the enrolment after a valid one was entered.
+// It must be invoked within a transaction.
+func (s *mfaServiceImpl) resetFailedAttempts(ctx context.Context, enrolment *repository.Enrolment) error {
+	if enrolment.FailedAttempts == 0 && enrolment.LockedUntil == nil {
+		return nil
+	}
+	enrolment.FailedAttempts = 0
+	enrolment.LockedUntil = nil
+	return s.Repositories().MFAEnrolmentRepository().Save(ctx, enrolment)
+}
+
+func (s *mfaServiceImpl) verifyEnrolment(ctx context.Context, enrolment *repository.Enrolment, code string) (string, error) {
+	if step, ok := mfa.ValidateCode(enrolment.Secret, code, time.Now(), s.config.GetMFAClockSkew(), enrolment.LastUsedStep); ok {
+		// the code cannot be used again
+		enrolment.LastUsedStep = step
+		err := s.Repositories().MFAEnrolmentRepository().Save(ctx, enrolment)
+		if err != nil {
+			return "", err
+		}
+		return mfa.AMROneTimePassword, nil
+	}
+	used, err := s.Repositories().MFARecoveryCodeRepository().Use(ctx, enrolment.IdentityID, hashRecoveryCode(code))
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", errors.NewUnauthorizedError(invalidCodeMessage)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": enrolment.IdentityID,
+	}, "mfa recovery code used")
+	return mfa.AMROneTimePassword, nil
+}
+
+// generateRecoveryCodes replaces the recovery codes of the given identity. It must be invoked within a transaction.
+func (s *mfaServiceImpl) generateRecoveryCodes(ctx context.Context, identityID uuid.UUID) ([]string, error) {
+	err := s.Repositories().MFARecoveryCodeRepository().DeleteForIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, s.config.GetMFARecoveryCodesCount())
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		err = s.Repositories().MFARecoveryCodeRepository().Create(ctx, &repository.RecoveryCode{
+			IdentityID: identityID,
+			CodeHash:   hashRecoveryCode(codes[i]),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as two groups of 5 characters, e.g. `k7hq2-xm4vd`
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 11)
+	for i, c := range b {
+		if i == 5 {
+			code = append(code, '-')
+		}
+		code = append(code, recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// hashRecoveryCode returns the hex encoded SHA-256 hash of the code, ignoring case, spaces and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+func isInvalidCodeError(err error) bool {
+	if unauthorized, _ := errors.IsUnauthorizedError(err); unauthorized {
+		return true
+	}
+	badParameter, _ := errors.IsBadParameterError(err)
+	return badParameter
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
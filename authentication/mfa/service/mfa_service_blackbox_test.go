@@ -0,0 +1,272 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type mfaServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestMFAService(t *testing.T) {
+	suite.Run(t, &mfaServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+// code returns the TOTP code of the given secret, `offset` steps from now. Since an accepted code cannot be used
+// again, consecutive operations in a test use increasing offsets (within the allowed clock skew).
+func code(t *testing.T, secret string, offset int64) string {
+	c, err := mfa.GenerateCode(secret, mfa.TimeStep(time.Now())+offset)
+	require.NoError(t, err)
+	return c
+}
+
+// enrol enables multi-factor authentication for the given identity and returns its secret and recovery codes
+func (s *mfaServiceBlackBoxTest) enrol(t *testing.T, identityID uuid.UUID) (string, []string) {
+	pending, err := s.Application.MFAService().Enrol(s.Ctx, identityID)
+	require.NoError(t, err)
+	recoveryCodes, err := s.Application.MFAService().ConfirmEnrolment(s.Ctx, identityID, code(t, pending.Secret, 0))
+	require.NoError(t, err)
+	return pending.Secret, recoveryCodes
+}
+
+func (s *mfaServiceBlackBoxTest) TestEnrol() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		pending, err := s.Application.MFAService().Enrol(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, pending.Secret)
+		assert.Contains(t, pending.ProvisioningURI, user.Identity().Username)
+		// the pending enrolment is returned again until it is confirmed
+		again, err := s.Application.MFAService().Enrol(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.Equal(t, pending.Secret, again.Secret)
+		required, err := s.Application.MFAService().ChallengeRequired(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.False(t, required)
+		// when
+		recoveryCodes, err := s.Application.MFAService().ConfirmEnrolment(s.Ctx, user.IdentityID(), code(t, pending.Secret, 0))
+		// then
+		require.NoError(t, err)
+		assert.Len(t, recoveryCodes, s.Configuration.GetMFARecoveryCodesCount())
+		required, err = s.Application.MFAService().ChallengeRequired(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.True(t, required)
+	})
+
+	s.T().Run("already enabled", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.enrol(t, user.IdentityID())
+		// when
+		_, err := s.Application.MFAService().Enrol(s.Ctx, user.IdentityID())
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+
+	s.T().Run("invalid code", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		_, err := s.Application.MFAService().Enrol(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().ConfirmEnrolment(s.Ctx, user.IdentityID(), "000000")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+func (s *mfaServiceBlackBoxTest) TestRecoveryCodes() {
+
+	s.T().Run("used once", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		_, recoveryCodes := s.enrol(t, user.IdentityID())
+		ctx, err := testtoken.EmbedIdentityInContext(*user.Identity())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().StepUp(ctx, user.IdentityID(), recoveryCodes[0])
+		// then
+		require.NoError(t, err)
+		_, err = s.Application.MFAService().StepUp(ctx, user.IdentityID(), recoveryCodes[0])
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("regenerated", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		secret, recoveryCodes := s.enrol(t, user.IdentityID())
+		// when
+		newCodes, err := s.Application.MFAService().RegenerateRecoveryCodes(s.Ctx, user.IdentityID(), code(t, secret, 1))
+		// then
+		require.NoError(t, err)
+		require.Len(t, newCodes, len(recoveryCodes))
+		// the previous codes are not valid anymore
+		err = s.Application.MFAService().Disable(s.Ctx, user.IdentityID(), recoveryCodes[0])
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		err = s.Application.MFAService().Disable(s.Ctx, user.IdentityID(), newCodes[0])
+		require.NoError(t, err)
+	})
+}
+
+func (s *mfaServiceBlackBoxTest) TestDisable() {
+	// given
+	user := s.Graph.CreateUser()
+	secret, _ := s.enrol(s.T(), user.IdentityID())
+
+	s.T().Run("replayed code", func(t *testing.T) {
+		// the code accepted by the confirmation cannot be used again
+		err := s.Application.MFAService().Disable(s.Ctx, user.IdentityID(), code(t, secret, 0))
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		err := s.Application.MFAService().Disable(s.Ctx, user.IdentityID(), code(t, secret, 1))
+		require.NoError(t, err)
+		required, err := s.Application.MFAService().ChallengeRequired(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.False(t, required)
+	})
+}
+
+func (s *mfaServiceBlackBoxTest) TestChallenge() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		secret, _ := s.enrol(t, user.IdentityID())
+		challengeID, err := s.Application.MFAService().CreateChallenge(s.Ctx, user.IdentityID(), "https://example.com/home", []string{mfa.AMRPassword})
+		require.NoError(t, err)
+		challenge, pending, err := s.Application.MFAService().LoadChallenge(s.Ctx, challengeID)
+		require.NoError(t, err)
+		assert.Nil(t, pending)
+		assert.Equal(t, []string{mfa.AMRPassword}, challenge.Methods())
+		// when
+		challenge, recoveryCodes, err := s.Application.MFAService().CompleteChallenge(s.Ctx, challengeID, code(t, secret, 1))
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, recoveryCodes)
+		assert.Equal(t, "https://example.com/home", challenge.Referrer)
+		// the challenge cannot be completed twice
+		_, _, err = s.Application.MFAService().LoadChallenge(s.Ctx, challengeID)
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("enrolment during challenge", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		challengeID, err := s.Application.MFAService().CreateChallenge(s.Ctx, user.IdentityID(), "https://example.com/home", []string{mfa.AMRFederated})
+		require.NoError(t, err)
+		_, pending, err := s.Application.MFAService().LoadChallenge(s.Ctx, challengeID)
+		require.NoError(t, err)
+		require.NotNil(t, pending)
+		// when
+		_, recoveryCodes, err := s.Application.MFAService().CompleteChallenge(s.Ctx, challengeID, code(t, pending.Secret, 0))
+		// then
+		require.NoError(t, err)
+		assert.Len(t, recoveryCodes, s.Configuration.GetMFARecoveryCodesCount())
+		required, err := s.Application.MFAService().ChallengeRequired(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.True(t, required)
+	})
+
+	s.T().Run("too many failed attempts", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.enrol(t, user.IdentityID())
+		challengeID, err := s.Application.MFAService().CreateChallenge(s.Ctx, user.IdentityID(), "https://example.com/home", []string{mfa.AMRPassword})
+		require.NoError(t, err)
+		for i := 0; i < s.Configuration.GetMFAChallengeMaxAttempts(); i++ {
+			// when
+			_, _, err = s.Application.MFAService().CompleteChallenge(s.Ctx, challengeID, "000000")
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		}
+		// the challenge was removed and the user has to login again
+		_, _, err = s.Application.MFAService().LoadChallenge(s.Ctx, challengeID)
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *mfaServiceBlackBoxTest) TestStepUp() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		secret, _ := s.enrol(t, user.IdentityID())
+		ctx, err := testtoken.EmbedIdentityInContext(*user.Identity())
+		require.NoError(t, err)
+		// when
+		tokenSet, err := s.Application.MFAService().StepUp(ctx, user.IdentityID(), code(t, secret, 1))
+		// then
+		require.NoError(t, err)
+		claims, err := testtoken.TokenManager.ParseToken(ctx, *tokenSet.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, mfa.ACRMultiFactor, claims.ACR)
+		assert.Contains(t, claims.AMR, mfa.AMROneTimePassword)
+		assert.Contains(t, claims.AMR, mfa.AMRMultiFactor)
+	})
+
+	s.T().Run("not enrolled", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		ctx, err := testtoken.EmbedIdentityInContext(*user.Identity())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().StepUp(ctx, user.IdentityID(), "000000")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *mfaServiceBlackBoxTest) TestLockout() {
+	// given
+	s.OverrideConfig("AUTH_MFA_MAX_FAILED_ATTEMPTS", "3")
+	app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers)
+	user := s.Graph.CreateUser()
+	secret, recoveryCodes := s.enrol(s.T(), user.IdentityID())
+	ctx, err := testtoken.EmbedIdentityInContext(*user.Identity())
+	require.NoError(s.T(), err)
+	// when the invalid codes are entered through different operations
+	_, err = app.MFAService().StepUp(ctx, user.IdentityID(), "000000")
+	require.IsType(s.T(), errors.UnauthorizedError{}, errs.Cause(err))
+	err = app.MFAService().Disable(s.Ctx, user.IdentityID(), "000000")
+	require.IsType(s.T(), errors.UnauthorizedError{}, errs.Cause(err))
+	_, err = app.MFAService().RegenerateRecoveryCodes(s.Ctx, user.IdentityID(), "000000")
+	require.IsType(s.T(), errors.UnauthorizedError{}, errs.Cause(err))
+	// then even valid codes are rejected
+	_, err = app.MFAService().StepUp(ctx, user.IdentityID(), code(s.T(), secret, 1))
+	require.Error(s.T(), err)
+	require.IsType(s.T(), errors.UnauthorizedError{}, errs.Cause(err))
+	err = app.MFAService().Disable(s.Ctx, user.IdentityID(), recoveryCodes[0])
+	require.Error(s.T(), err)
+	require.IsType(s.T(), errors.UnauthorizedError{}, errs.Cause(err))
+	required, err := app.MFAService().ChallengeRequired(s.Ctx, user.IdentityID())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), required)
+}
@@ -0,0 +1,98 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// TOTPPeriod is the duration of a TOTP time step
+	TOTPPeriod = 30 * time.Second
+	// TOTPDigits is the number of digits of a TOTP code
+	TOTPDigits = 6
+
+	secretSize = 20 // 160 bits, as recommended by RFC 4226 for HMAC-SHA1
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random TOTP secret, encoded in base32 as expected by authenticator apps
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to generate TOTP secret")
+	}
+	return secretEncoding.EncodeToString(secret), nil
+}
+
+// TimeStep returns the TOTP time step of the given time
+func TimeStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// GenerateCode returns the TOTP code of the given base32 encoded secret for the given time step (RFC 6238)
+func GenerateCode(secret string, step int64) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", errs.Wrap(err, "invalid TOTP secret")
+	}
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+	// dynamic truncation, see RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000), nil
+}
+
+// ValidateCode checks the given code against the codes of the time steps around the given time, allowing for up to
+// `skew` steps of clock drift in either direction. It returns the matching time step, which must be greater than
+// `lastUsedStep` so that a code cannot be used twice.
+func ValidateCode(secret string, code string, now time.Time, skew int, lastUsedStep int64) (int64, bool) {
+	code = strings.Replace(strings.TrimSpace(code), " ", "", -1)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := TimeStep(now)
+	for i := -skew; i <= skew; i++ {
+		step := current + int64(i)
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := GenerateCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI returns the `otpauth://` URI of the given secret, which authenticator apps can import (usually by
+// scanning it as a QR code)
+func ProvisioningURI(issuer string, accountName string, secret string) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprintf("%d", TOTPDigits)},
+		"period":    {fmt.Sprintf("%d", int64(TOTPPeriod/time.Second))},
+	}
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
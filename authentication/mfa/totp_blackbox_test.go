@@ -0,0 +1,81 @@
+package mfa_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the SHA1 test secret of RFC 6238, appendix B
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateCode(t *testing.T) {
+	// test vectors of RFC 6238, appendix B, truncated to 6 digits
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := mfa.GenerateCode(rfcSecret, mfa.TimeStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "unexpected code at %d", unix)
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := mfa.GenerateCode(rfcSecret, mfa.TimeStep(now))
+	require.NoError(t, err)
+
+	t.Run("current step", func(t *testing.T) {
+		step, ok := mfa.ValidateCode(rfcSecret, code, now, 1, 0)
+		assert.True(t, ok)
+		assert.Equal(t, mfa.TimeStep(now), step)
+	})
+
+	t.Run("within skew", func(t *testing.T) {
+		_, ok := mfa.ValidateCode(rfcSecret, code, now.Add(mfa.TOTPPeriod), 1, 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("outside skew", func(t *testing.T) {
+		_, ok := mfa.ValidateCode(rfcSecret, code, now.Add(3*mfa.TOTPPeriod), 1, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		_, ok := mfa.ValidateCode(rfcSecret, code, now, 1, mfa.TimeStep(now))
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		_, ok := mfa.ValidateCode(rfcSecret, "000000", now, 1, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := mfa.GenerateSecret()
+	require.NoError(t, err)
+	other, err := mfa.GenerateSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+	_, err = mfa.GenerateCode(secret, 1)
+	require.NoError(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := mfa.ProvisioningURI("fabric8", "jdoe", "SECRET")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/fabric8:jdoe?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=fabric8")
+}
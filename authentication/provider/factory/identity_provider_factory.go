@@ -7,6 +7,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/log"
 )
 
 // NewIdentityProviderFactory returns the default Oauth provider factory.
@@ -21,7 +22,21 @@ type identityProviderFactoryImpl struct {
 	base.BaseService
 }
 
-// NewIdentityProvider creates a new identity provider based on the specified configuration
+// NewIdentityProvider creates a new identity provider based on the specified configuration.  If the configured
+// provider type is "saml" then a SAML 2.0 service provider is returned instead of the default OAuth2 provider, and
+// if it is "local" then the built-in username/password provider is returned.
 func (f *identityProviderFactoryImpl) NewIdentityProvider(ctx context.Context, config provider.IdentityProviderConfiguration) provider.IdentityProvider {
+	if provider.IsLocalIdentityProvider(config) {
+		return provider.NewLocalIdentityProvider(config.(provider.LocalIdentityProviderConfiguration), f.Services().CredentialService())
+	}
+	if provider.IsSAMLIdentityProvider(config) {
+		samlProvider, err := provider.NewSAMLIdentityProvider(config.(provider.SAMLIdentityProviderConfiguration), f.Repositories().SAMLAssertionRepository())
+		if err != nil {
+			log.Panic(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to create the SAML identity provider")
+		}
+		return samlProvider
+	}
 	return provider.NewIdentityProvider(config)
 }
@@ -0,0 +1,91 @@
+package provider
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	netcontext "golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// LocalProviderType is the value of the `oauth.provider.type` configuration setting which enables the built-in
+	// username/password identity provider in place of the default OAuth2 identity provider
+	LocalProviderType = "local"
+
+	localTokenType = "local"
+)
+
+// LocalIdentityProviderConfiguration represents the configuration of the local identity provider
+type LocalIdentityProviderConfiguration interface {
+	IdentityProviderConfiguration
+	GetOAuthProviderType() string
+	GetLocalLoginFormURL() string
+}
+
+// LocalLoginCodeStore provides access to the one-time codes which are issued after the user has successfully
+// entered their username and password in the local login form
+type LocalLoginCodeStore interface {
+	// CheckLoginCode returns an error if the given login code is unknown or expired
+	CheckLoginCode(ctx context.Context, code string) error
+	// RedeemLoginCode consumes the given login code and returns the profile of the user it was issued for
+	RedeemLoginCode(ctx context.Context, code string) (*UserProfile, error)
+}
+
+// IsLocalIdentityProvider returns true if the given configuration enables the local identity provider
+func IsLocalIdentityProvider(config IdentityProviderConfiguration) bool {
+	localConfig, ok := config.(LocalIdentityProviderConfiguration)
+	return ok && localConfig.GetOAuthProviderType() == LocalProviderType
+}
+
+// LocalIdentityProvider is the built-in identity provider, which authenticates users with a username and password
+// stored in the local database.  It implements the IdentityProvider interface so that the rest of the login flow is
+// the same as with an external OAuth2 identity provider: the "authorization code" is a one-time login code issued
+// by the login form, and the "provider token" is that same code, which is redeemed for the user profile.
+type LocalIdentityProvider struct {
+	config LocalIdentityProviderConfiguration
+	codes  LocalLoginCodeStore
+}
+
+// NewLocalIdentityProvider creates a new local identity provider
+func NewLocalIdentityProvider(config LocalIdentityProviderConfiguration, codes LocalLoginCodeStore) *LocalIdentityProvider {
+	return &LocalIdentityProvider{
+		config: config,
+		codes:  codes,
+	}
+}
+
+// AuthCodeURL returns the URL of the local login form, which keeps track of the state of the login flow
+func (provider *LocalIdentityProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return addQueryParams(provider.config.GetLocalLoginFormURL(), url.Values{"state": {state}})
+}
+
+// Exchange checks the login code issued by the login form and returns it wrapped in an oauth2.Token
+func (provider *LocalIdentityProvider) Exchange(ctx netcontext.Context, code string) (*oauth2.Token, error) {
+	err := provider.codes.CheckLoginCode(ctx, code)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "invalid local login code")
+		return nil, err
+	}
+	return &oauth2.Token{
+		AccessToken: code,
+		TokenType:   localTokenType,
+	}, nil
+}
+
+// Profile redeems the login code held in the token and returns the profile of the authenticated user
+func (provider *LocalIdentityProvider) Profile(ctx context.Context, token oauth2.Token) (*UserProfile, error) {
+	return provider.codes.RedeemLoginCode(ctx, token.AccessToken)
+}
+
+// SetRedirectURL is a no-op for the local identity provider, since the login form is served by this service
+func (provider *LocalIdentityProvider) SetRedirectURL(redirectURL string) {
+}
+
+// SetScopes is a no-op for the local identity provider, which has no notion of OAuth2 scopes
+func (provider *LocalIdentityProvider) SetScopes(scopes []string) {
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+)
+
+// SAMLAssertionRepository keeps track of the SAML assertions accepted by the service provider, so that none of them
+// can be used twice
+type SAMLAssertionRepository interface {
+	Consume(ctx context.Context, assertionID string, expiresAt time.Time) (bool, error)
+}
+
+// NewSAMLAssertionRepository creates a new SAML assertion repository
+func NewSAMLAssertionRepository(db *gorm.DB) SAMLAssertionRepository {
+	return &GormSAMLAssertionRepository{db: db}
+}
+
+// GormSAMLAssertionRepository implements SAMLAssertionRepository using gorm
+type GormSAMLAssertionRepository struct {
+	db *gorm.DB
+}
+
+// Consume records the assertion with the specified ID as consumed until it expires, and returns true if it was not
+// consumed before. The assertions which have expired are removed along the way, since they are rejected anyway.
+func (r *GormSAMLAssertionRepository) Consume(ctx context.Context, assertionID string, expiresAt time.Time) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "saml_assertion", "consume"}, time.Now())
+
+	err := r.db.Exec("DELETE FROM saml_assertion WHERE expires_at < now()").Error
+	if err != nil {
+		return false, errs.WithStack(err)
+	}
+	result := r.db.Exec(`INSERT INTO saml_assertion (assertion_id, expires_at) VALUES (?, ?)
+		ON CONFLICT (assertion_id) DO NOTHING`, assertionID, expiresAt)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"assertion_id": assertionID,
+			"err":          result.Error,
+		}, "unable to record the SAML assertion")
+		return false, errs.WithStack(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
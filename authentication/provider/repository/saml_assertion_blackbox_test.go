@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type samlAssertionBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo repository.SAMLAssertionRepository
+}
+
+func TestRunSAMLAssertionBlackBoxTest(t *testing.T) {
+	suite.Run(t, &samlAssertionBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *samlAssertionBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = repository.NewSAMLAssertionRepository(s.DB)
+}
+
+func (s *samlAssertionBlackBoxTest) TestConsume() {
+
+	s.T().Run("once", func(t *testing.T) {
+		// given
+		assertionID := "_" + uuid.NewV4().String()
+		expiresAt := time.Now().Add(5 * time.Minute)
+		// when
+		consumed, err := s.repo.Consume(s.Ctx, assertionID, expiresAt)
+		// then
+		require.NoError(t, err)
+		assert.True(t, consumed)
+		// and the same assertion cannot be consumed again
+		consumed, err = s.repo.Consume(s.Ctx, assertionID, expiresAt)
+		require.NoError(t, err)
+		assert.False(t, consumed)
+	})
+
+	s.T().Run("expired assertion is forgotten", func(t *testing.T) {
+		// given
+		assertionID := "_" + uuid.NewV4().String()
+		consumed, err := s.repo.Consume(s.Ctx, assertionID, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		require.True(t, consumed)
+		// when
+		consumed, err = s.repo.Consume(s.Ctx, assertionID, time.Now().Add(-time.Minute))
+		// then
+		require.NoError(t, err)
+		assert.True(t, consumed)
+	})
+}
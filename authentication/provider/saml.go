@@ -0,0 +1,654 @@
+package provider
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/beevik/etree"
+	errs "github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+	netcontext "golang.org/x/net/context"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// SAMLProviderType is the value of the `oauth.provider.type` configuration setting which enables the SAML 2.0
+	// service provider implementation in place of the default OAuth2 identity provider
+	SAMLProviderType = "saml"
+
+	// SAMLBindingHTTPRedirect is the URI identifying the SAML 2.0 HTTP-Redirect binding
+	SAMLBindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	// SAMLBindingHTTPPost is the URI identifying the SAML 2.0 HTTP-POST binding
+	SAMLBindingHTTPPost = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+
+	// SAMLAttributeUsername is the attribute mapping key for the username of the user profile
+	SAMLAttributeUsername = "username"
+	// SAMLAttributeEmail is the attribute mapping key for the email address of the user profile
+	SAMLAttributeEmail = "email"
+	// SAMLAttributeEmailVerified is the attribute mapping key for whether the email address of the user profile was
+	// verified by the identity provider
+	SAMLAttributeEmailVerified = "email_verified"
+	// SAMLAttributeGivenName is the attribute mapping key for the given name of the user profile
+	SAMLAttributeGivenName = "given_name"
+	// SAMLAttributeFamilyName is the attribute mapping key for the family name of the user profile
+	SAMLAttributeFamilyName = "family_name"
+	// SAMLAttributeCompany is the attribute mapping key for the company of the user profile
+	SAMLAttributeCompany = "company"
+
+	samlTokenType          = "saml"
+	samlStatusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearerConfirmation = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlNameIDUnspecified  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlMetadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+)
+
+// SAMLIdentityProviderConfiguration represents the configuration of the SAML 2.0 service provider
+type SAMLIdentityProviderConfiguration interface {
+	IdentityProviderConfiguration
+	GetOAuthProviderType() string
+	GetSAMLServiceProviderEntityID() string
+	GetSAMLServiceProviderACSURL() string
+	GetSAMLServiceProviderSSOURL() string
+	GetSAMLIdentityProviderEntityID() string
+	GetSAMLIdentityProviderSSOURL() string
+	GetSAMLIdentityProviderSSOBinding() string
+	GetSAMLIdentityProviderCertificates() string
+	IsSAMLIdentityProviderEmailTrusted() bool
+	GetSAMLAttributeMapping() map[string]string
+	GetSAMLClockSkew() time.Duration
+}
+
+// SAMLAssertionStore keeps track of the SAML assertions accepted by the service provider until they expire, so that
+// none of them can be used twice
+type SAMLAssertionStore interface {
+	// Consume records the assertion with the given ID as consumed until it expires, and returns false if it was
+	// already consumed
+	Consume(ctx context.Context, assertionID string, expiresAt time.Time) (bool, error)
+}
+
+// StateBoundIdentityProvider is an identity provider which needs the state of the login to validate the code it
+// returned on callback, e.g. to check that a SAML response answers the authentication request sent for this login
+type StateBoundIdentityProvider interface {
+	ExchangeWithState(ctx netcontext.Context, code string, state string) (*oauth2.Token, error)
+}
+
+// IsSAMLIdentityProvider returns true if the given configuration enables the SAML 2.0 service provider
+func IsSAMLIdentityProvider(config IdentityProviderConfiguration) bool {
+	samlConfig, ok := config.(SAMLIdentityProviderConfiguration)
+	return ok && samlConfig.GetOAuthProviderType() == SAMLProviderType
+}
+
+// SAMLIdentityProvider is a SAML 2.0 service provider which implements the IdentityProvider interface, so that
+// it can be used in the login flow in place of an OAuth2 identity provider.  The authorization code in the OAuth2 flow
+// is the (base64 encoded) SAML response posted by the identity provider to the assertion consumer service, and the
+// "provider token" is the validated SAML response itself, from which the user profile is read.
+type SAMLIdentityProvider struct {
+	config       SAMLIdentityProviderConfiguration
+	certificates []*x509.Certificate
+	assertions   SAMLAssertionStore
+	now          func() time.Time
+}
+
+// NewSAMLIdentityProvider creates a new SAML 2.0 service provider, which records the assertions it accepts in the
+// given store
+func NewSAMLIdentityProvider(config SAMLIdentityProviderConfiguration, assertions SAMLAssertionStore) (*SAMLIdentityProvider, error) {
+	certificates, err := parseCertificates(config.GetSAMLIdentityProviderCertificates())
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to load the SAML identity provider certificates")
+	}
+	return &SAMLIdentityProvider{
+		config:       config,
+		certificates: certificates,
+		assertions:   assertions,
+		now:          time.Now,
+	}, nil
+}
+
+// SetClock overrides the clock used when validating the time conditions of SAML assertions
+func (provider *SAMLIdentityProvider) SetClock(now func() time.Time) {
+	provider.now = now
+}
+
+// AuthCodeURL returns the URL to which the user should be redirected in order to authenticate with the SAML identity
+// provider.  With the HTTP-Redirect binding this is the identity provider's single sign-on URL with a deflated
+// AuthnRequest, while with the HTTP-POST binding it is our own single sign-on endpoint which renders a self-submitting
+// form (see AuthnRequestPostForm).  In both cases the state is passed through the RelayState parameter, and the ID of
+// the AuthnRequest is derived from it (see SAMLAuthnRequestID).
+func (provider *SAMLIdentityProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	if provider.config.GetSAMLIdentityProviderSSOBinding() == SAMLBindingHTTPPost {
+		return addQueryParams(provider.config.GetSAMLServiceProviderSSOURL(), url.Values{"RelayState": {state}})
+	}
+	request, err := provider.authnRequest(SAMLBindingHTTPRedirect, state)
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "unable to generate the SAML authentication request")
+		return ""
+	}
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	w.Write(request)
+	w.Close()
+	return addQueryParams(provider.config.GetSAMLIdentityProviderSSOURL(), url.Values{
+		"SAMLRequest": {base64.StdEncoding.EncodeToString(buf.Bytes())},
+		"RelayState":  {state},
+	})
+}
+
+// AuthnRequestPostForm returns an HTML page containing a form which automatically posts a new AuthnRequest to the
+// identity provider, using the HTTP-POST binding
+func (provider *SAMLIdentityProvider) AuthnRequestPostForm(relayState string) ([]byte, error) {
+	request, err := provider.authnRequest(SAMLBindingHTTPPost, relayState)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = samlPostFormTemplate.Execute(&buf, map[string]string{
+		"URL":         provider.config.GetSAMLIdentityProviderSSOURL(),
+		"SAMLRequest": base64.StdEncoding.EncodeToString(request),
+		"RelayState":  relayState,
+	})
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Metadata returns the service provider metadata document which should be registered with the identity provider
+func (provider *SAMLIdentityProvider) Metadata() ([]byte, error) {
+	acsURL := provider.config.GetSAMLServiceProviderACSURL()
+	metadata := samlEntityDescriptor{
+		MD:       samlMetadataNamespace,
+		EntityID: provider.config.GetSAMLServiceProviderEntityID(),
+		SPSSODescriptor: samlSPSSODescriptor{
+			AuthnRequestsSigned:        false,
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: samlProtocolNamespace,
+			NameIDFormat:               samlNameIDUnspecified,
+			AssertionConsumerServices: []samlIndexedEndpoint{
+				{Binding: SAMLBindingHTTPPost, Location: acsURL, Index: 0, IsDefault: true},
+				{Binding: SAMLBindingHTTPRedirect, Location: acsURL, Index: 1},
+			},
+		},
+	}
+	result, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return append([]byte(xml.Header), result...), nil
+}
+
+// Exchange always fails, since a SAML response is only accepted along with the relay state of the login it answers
+// (see ExchangeWithState)
+func (provider *SAMLIdentityProvider) Exchange(ctx netcontext.Context, code string) (*oauth2.Token, error) {
+	return nil, errors.NewUnauthorizedError("SAML responses are only accepted by the assertion consumer service")
+}
+
+// ExchangeWithState validates the SAML response received by the assertion consumer service along with the given
+// relay state, and returns it wrapped in an oauth2.Token, so that the rest of the login flow can treat it as a provider
+// token. The response must answer the AuthnRequest sent for this relay state, and its assertion must not have been
+// accepted before.
+func (provider *SAMLIdentityProvider) ExchangeWithState(ctx netcontext.Context, code string, state string) (*oauth2.Token, error) {
+	if state == "" {
+		return nil, errors.NewUnauthorizedError("missing SAML relay state")
+	}
+	assertion, err := provider.validateResponse(code, SAMLAuthnRequestID(state))
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "invalid SAML response")
+		return nil, err
+	}
+	err = provider.consumeAssertion(ctx, assertion)
+	if err != nil {
+		return nil, err
+	}
+	token := &oauth2.Token{
+		AccessToken: code,
+		TokenType:   samlTokenType,
+	}
+	if assertion.Conditions != nil && !assertion.Conditions.NotOnOrAfter.IsZero() {
+		token.Expiry = assertion.Conditions.NotOnOrAfter
+	}
+	return token, nil
+}
+
+// Profile validates the SAML response held in the token and maps the attributes of its assertion into a UserProfile,
+// according to the configured attribute mapping. The token must have been returned by ExchangeWithState, which
+// already checked that the response answers the request of the login.
+func (provider *SAMLIdentityProvider) Profile(ctx context.Context, token oauth2.Token) (*UserProfile, error) {
+	assertion, err := provider.validateResponse(token.AccessToken, "")
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "invalid SAML response")
+		return nil, err
+	}
+
+	mapping := provider.config.GetSAMLAttributeMapping()
+	profile := &UserProfile{
+		Username:   assertion.attribute(mapping[SAMLAttributeUsername]),
+		GivenName:  assertion.attribute(mapping[SAMLAttributeGivenName]),
+		FamilyName: assertion.attribute(mapping[SAMLAttributeFamilyName]),
+		Email:      assertion.attribute(mapping[SAMLAttributeEmail]),
+		Company:    assertion.attribute(mapping[SAMLAttributeCompany]),
+		Subject:    strings.TrimSpace(assertion.Subject.NameID),
+		// email addresses verified by this service are trusted when claiming invitations, so an asserted email address
+		// is only verified if the identity provider is trusted to verify them all, or says it did
+		EmailVerified: provider.config.IsSAMLIdentityProviderEmailTrusted() || assertion.booleanAttribute(mapping[SAMLAttributeEmailVerified]),
+		// the identity provider is trusted to have approved the user
+		Approved: true,
+	}
+	if profile.Username == "" {
+		profile.Username = profile.Subject
+	}
+	if profile.Username == "" {
+		return nil, errors.NewUnauthorizedError("SAML assertion does not contain a username")
+	}
+	return profile, nil
+}
+
+// SetRedirectURL is a no-op for the SAML identity provider, since SAML responses are always delivered to the
+// configured assertion consumer service URL
+func (provider *SAMLIdentityProvider) SetRedirectURL(redirectURL string) {
+}
+
+// SetScopes is a no-op for the SAML identity provider, which has no notion of OAuth2 scopes
+func (provider *SAMLIdentityProvider) SetScopes(scopes []string) {
+}
+
+// SAMLAuthnRequestID returns the ID of the AuthnRequest sent along with the given relay state. Deriving the ID from
+// the relay state binds the response, which refers to the request by its ID, to the state of the login, which is
+// itself either used once or bound to the browser (see OAuthState).
+func SAMLAuthnRequestID(relayState string) string {
+	hash := sha256.Sum256([]byte(relayState))
+	return "_" + hex.EncodeToString(hash[:])
+}
+
+func (provider *SAMLIdentityProvider) authnRequest(binding string, relayState string) ([]byte, error) {
+	request := samlAuthnRequest{
+		SAMLP:                       samlProtocolNamespace,
+		SAML:                        samlAssertionNamespace,
+		ID:                          SAMLAuthnRequestID(relayState),
+		Version:                     "2.0",
+		IssueInstant:                provider.now().UTC().Format(time.RFC3339),
+		Destination:                 provider.config.GetSAMLIdentityProviderSSOURL(),
+		ProtocolBinding:             SAMLBindingHTTPPost,
+		AssertionConsumerServiceURL: provider.config.GetSAMLServiceProviderACSURL(),
+		Issuer:                      provider.config.GetSAMLServiceProviderEntityID(),
+		NameIDPolicy: samlNameIDPolicy{
+			Format:      samlNameIDUnspecified,
+			AllowCreate: true,
+		},
+	}
+	result, err := xml.Marshal(request)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to marshal the SAML authentication request for the %s binding", binding)
+	}
+	return result, nil
+}
+
+// validateResponse decodes the given SAML response, verifies its signature and checks the conditions of the
+// assertion it contains, returning the assertion if it is valid. Unless the given request ID is empty, the response
+// must also answer the AuthnRequest with this ID.
+func (provider *SAMLIdentityProvider) validateResponse(encoded string, requestID string) (*samlAssertion, error) {
+	raw, err := decodeSAMLMessage(encoded)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError(fmt.Sprintf("unable to decode SAML response: %s", err.Error()))
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return nil, errors.NewUnauthorizedError(fmt.Sprintf("unable to parse SAML response: %s", err.Error()))
+	}
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" {
+		return nil, errors.NewUnauthorizedError("SAML message is not a response")
+	}
+
+	statusCode := response.FindElement("./Status/StatusCode")
+	if statusCode == nil || statusCode.SelectAttrValue("Value", "") != samlStatusSuccess {
+		return nil, errors.NewUnauthorizedError("SAML authentication failed at the identity provider")
+	}
+	if destination := response.SelectAttrValue("Destination", ""); destination != "" && destination != provider.config.GetSAMLServiceProviderACSURL() {
+		return nil, errors.NewUnauthorizedError(fmt.Sprintf("unexpected SAML response destination: %s", destination))
+	}
+	if inResponseTo := response.SelectAttrValue("InResponseTo", ""); requestID != "" && inResponseTo != requestID {
+		return nil, errors.NewUnauthorizedError("SAML response does not answer the authentication request of this login")
+	}
+	if response.FindElement("./EncryptedAssertion") != nil {
+		return nil, errors.NewUnauthorizedError("encrypted SAML assertions are not supported")
+	}
+
+	validationContext := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: provider.certificates,
+	})
+	validationContext.Clock = dsig.NewFakeClockAt(provider.now())
+
+	// Either the whole response or the assertion itself must be signed by the identity provider. Only the elements
+	// returned by the validation context are used from here on, since they contain the signed content only.
+	var assertionElement *etree.Element
+	validatedResponse, err := validationContext.Validate(response)
+	switch {
+	case err == nil:
+		assertionElement = validatedResponse.FindElement("./Assertion")
+		if assertionElement == nil {
+			return nil, errors.NewUnauthorizedError("SAML response does not contain an assertion")
+		}
+	case err == dsig.ErrMissingSignature:
+		unverified := response.FindElement("./Assertion")
+		if unverified == nil {
+			return nil, errors.NewUnauthorizedError("SAML response does not contain an assertion")
+		}
+		// carry the namespace declarations of the response over to the assertion, so that it can be validated on its own
+		nsContext, err := etreeutils.NSBuildParentContext(unverified)
+		if err != nil {
+			return nil, errors.NewUnauthorizedError(fmt.Sprintf("unable to parse SAML assertion: %s", err.Error()))
+		}
+		detached, err := etreeutils.NSDetatch(nsContext, unverified)
+		if err != nil {
+			return nil, errors.NewUnauthorizedError(fmt.Sprintf("unable to parse SAML assertion: %s", err.Error()))
+		}
+		assertionElement, err = validationContext.Validate(detached)
+		if err != nil {
+			return nil, errors.NewUnauthorizedError(fmt.Sprintf("invalid SAML assertion signature: %s", err.Error()))
+		}
+	default:
+		return nil, errors.NewUnauthorizedError(fmt.Sprintf("invalid SAML response signature: %s", err.Error()))
+	}
+
+	assertionDoc := etree.NewDocument()
+	assertionDoc.SetRoot(assertionElement.Copy())
+	assertionXML, err := assertionDoc.WriteToBytes()
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	var assertion samlAssertion
+	if err := xml.Unmarshal(assertionXML, &assertion); err != nil {
+		return nil, errors.NewUnauthorizedError(fmt.Sprintf("unable to parse SAML assertion: %s", err.Error()))
+	}
+	if err := provider.checkAssertion(assertion, requestID); err != nil {
+		return nil, err
+	}
+	return &assertion, nil
+}
+
+// checkAssertion verifies the issuer, audience, time conditions and subject confirmation of an assertion. Unless the
+// given request ID is empty, the subject confirmation must also refer to the AuthnRequest with this ID, since the
+// InResponseTo attribute of the response itself is not necessarily signed.
+func (provider *SAMLIdentityProvider) checkAssertion(assertion samlAssertion, requestID string) error {
+	now := provider.now()
+	skew := provider.config.GetSAMLClockSkew()
+
+	if expected := provider.config.GetSAMLIdentityProviderEntityID(); expected != "" && strings.TrimSpace(assertion.Issuer) != expected {
+		return errors.NewUnauthorizedError(fmt.Sprintf("unexpected SAML assertion issuer: %s", assertion.Issuer))
+	}
+
+	if assertion.Conditions == nil {
+		return errors.NewUnauthorizedError("SAML assertion has no conditions")
+	}
+	if !assertion.Conditions.NotBefore.IsZero() && now.Add(skew).Before(assertion.Conditions.NotBefore) {
+		return errors.NewUnauthorizedError("SAML assertion is not yet valid")
+	}
+	if !assertion.Conditions.NotOnOrAfter.IsZero() && !now.Add(-skew).Before(assertion.Conditions.NotOnOrAfter) {
+		return errors.NewUnauthorizedError("SAML assertion has expired")
+	}
+	audienceFound := false
+	for _, restriction := range assertion.Conditions.AudienceRestrictions {
+		for _, audience := range restriction.Audiences {
+			if strings.TrimSpace(audience) == provider.config.GetSAMLServiceProviderEntityID() {
+				audienceFound = true
+			}
+		}
+	}
+	if !audienceFound {
+		return errors.NewUnauthorizedError("SAML assertion is not intended for this service provider")
+	}
+
+	for _, confirmation := range assertion.Subject.SubjectConfirmations {
+		if confirmation.Method != samlBearerConfirmation {
+			continue
+		}
+		data := confirmation.SubjectConfirmationData
+		if data.Recipient != "" && data.Recipient != provider.config.GetSAMLServiceProviderACSURL() {
+			continue
+		}
+		if !data.NotOnOrAfter.IsZero() && !now.Add(-skew).Before(data.NotOnOrAfter) {
+			continue
+		}
+		if requestID != "" && data.InResponseTo != requestID {
+			continue
+		}
+		return nil
+	}
+	return errors.NewUnauthorizedError("SAML assertion has no valid bearer subject confirmation")
+}
+
+// consumeAssertion records the assertion as consumed until it expires, or returns an UnauthorizedError if it was
+// already consumed, so that an intercepted SAML response cannot be replayed
+func (provider *SAMLIdentityProvider) consumeAssertion(ctx context.Context, assertion *samlAssertion) error {
+	if strings.TrimSpace(assertion.ID) == "" {
+		return errors.NewUnauthorizedError("SAML assertion has no ID")
+	}
+	// the assertion is remembered for as long as it would be accepted, clock skew included, so it must expire: either
+	// its conditions or all its subject confirmations have an expiry
+	expiresAt := assertion.Conditions.NotOnOrAfter
+	if expiresAt.IsZero() {
+		for i, confirmation := range assertion.Subject.SubjectConfirmations {
+			notOnOrAfter := confirmation.SubjectConfirmationData.NotOnOrAfter
+			if notOnOrAfter.IsZero() {
+				expiresAt = time.Time{}
+				break
+			}
+			if i == 0 || notOnOrAfter.After(expiresAt) {
+				expiresAt = notOnOrAfter
+			}
+		}
+	}
+	if expiresAt.IsZero() {
+		return errors.NewUnauthorizedError("SAML assertion does not expire")
+	}
+	expiresAt = expiresAt.Add(provider.config.GetSAMLClockSkew())
+	consumed, err := provider.assertions.Consume(ctx, assertion.ID, expiresAt)
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	if !consumed {
+		log.Error(ctx, map[string]interface{}{
+			"assertion_id": assertion.ID,
+		}, "SAML assertion was already used")
+		return errors.NewUnauthorizedError("SAML assertion has already been used")
+	}
+	return nil
+}
+
+// decodeSAMLMessage decodes a base64 encoded SAML message, which is additionally deflated when the HTTP-Redirect
+// binding is used
+func decodeSAMLMessage(encoded string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(decoded), []byte("<")) {
+		return decoded, nil
+	}
+	inflated, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(decoded)))
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return inflated, nil
+}
+
+// parseCertificates parses all certificates in the given PEM encoded string
+func parseCertificates(pemCertificates string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(pemCertificates)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errs.WithStack(err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, errs.New("no certificate found")
+	}
+	return certificates, nil
+}
+
+func addQueryParams(rawURL string, params url.Values) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + params.Encode()
+}
+
+var samlPostFormTemplate = template.Must(template.New("saml").Parse(`<!DOCTYPE html>
+<html>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.URL}}">
+<input type="hidden" name="SAMLRequest" value="{{.SAMLRequest}}"/>
+<input type="hidden" name="RelayState" value="{{.RelayState}}"/>
+<noscript><input type="submit" value="Continue"/></noscript>
+</form>
+</body>
+</html>`))
+
+// #####################################################################################################################
+//
+// SAML protocol types
+//
+// #####################################################################################################################
+
+type samlAuthnRequest struct {
+	XMLName                     xml.Name         `xml:"samlp:AuthnRequest"`
+	SAMLP                       string           `xml:"xmlns:samlp,attr"`
+	SAML                        string           `xml:"xmlns:saml,attr"`
+	ID                          string           `xml:"ID,attr"`
+	Version                     string           `xml:"Version,attr"`
+	IssueInstant                string           `xml:"IssueInstant,attr"`
+	Destination                 string           `xml:"Destination,attr"`
+	ProtocolBinding             string           `xml:"ProtocolBinding,attr"`
+	AssertionConsumerServiceURL string           `xml:"AssertionConsumerServiceURL,attr"`
+	Issuer                      string           `xml:"saml:Issuer"`
+	NameIDPolicy                samlNameIDPolicy `xml:"samlp:NameIDPolicy"`
+}
+
+type samlNameIDPolicy struct {
+	Format      string `xml:"Format,attr"`
+	AllowCreate bool   `xml:"AllowCreate,attr"`
+}
+
+type samlEntityDescriptor struct {
+	XMLName         xml.Name            `xml:"md:EntityDescriptor"`
+	MD              string              `xml:"xmlns:md,attr"`
+	EntityID        string              `xml:"entityID,attr"`
+	SPSSODescriptor samlSPSSODescriptor `xml:"md:SPSSODescriptor"`
+}
+
+type samlSPSSODescriptor struct {
+	AuthnRequestsSigned        bool                  `xml:"AuthnRequestsSigned,attr"`
+	WantAssertionsSigned       bool                  `xml:"WantAssertionsSigned,attr"`
+	ProtocolSupportEnumeration string                `xml:"protocolSupportEnumeration,attr"`
+	NameIDFormat               string                `xml:"md:NameIDFormat"`
+	AssertionConsumerServices  []samlIndexedEndpoint `xml:"md:AssertionConsumerService"`
+}
+
+type samlIndexedEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     int    `xml:"index,attr"`
+	IsDefault bool   `xml:"isDefault,attr,omitempty"`
+}
+
+type samlAssertion struct {
+	ID                  string                   `xml:"ID,attr"`
+	Issuer              string                   `xml:"Issuer"`
+	Subject             samlSubject              `xml:"Subject"`
+	Conditions          *samlConditions          `xml:"Conditions"`
+	AttributeStatements []samlAttributeStatement `xml:"AttributeStatement"`
+}
+
+// attribute returns the first value of the attribute with the given name or friendly name, or an empty string
+func (a samlAssertion) attribute(name string) string {
+	if name == "" {
+		return ""
+	}
+	for _, statement := range a.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (attribute.Name == name || attribute.FriendlyName == name) && len(attribute.Values) > 0 {
+				return strings.TrimSpace(attribute.Values[0])
+			}
+		}
+	}
+	return ""
+}
+
+// booleanAttribute returns true if the first value of the attribute with the given name or friendly name is a true
+// boolean value, e.g. "true" or "1"
+func (a samlAssertion) booleanAttribute(name string) bool {
+	value, err := strconv.ParseBool(a.attribute(name))
+	return err == nil && value
+}
+
+type samlSubject struct {
+	NameID               string                    `xml:"NameID"`
+	SubjectConfirmations []samlSubjectConfirmation `xml:"SubjectConfirmation"`
+}
+
+type samlSubjectConfirmation struct {
+	Method                  string                      `xml:"Method,attr"`
+	SubjectConfirmationData samlSubjectConfirmationData `xml:"SubjectConfirmationData"`
+}
+
+type samlSubjectConfirmationData struct {
+	Recipient    string    `xml:"Recipient,attr"`
+	NotOnOrAfter time.Time `xml:"NotOnOrAfter,attr"`
+	InResponseTo string    `xml:"InResponseTo,attr"`
+}
+
+type samlConditions struct {
+	NotBefore            time.Time                 `xml:"NotBefore,attr"`
+	NotOnOrAfter         time.Time                 `xml:"NotOnOrAfter,attr"`
+	AudienceRestrictions []samlAudienceRestriction `xml:"AudienceRestriction"`
+}
+
+type samlAudienceRestriction struct {
+	Audiences []string `xml:"Audience"`
+}
+
+type samlAttributeStatement struct {
+	Attributes []samlAttribute `xml:"Attribute"`
+}
+
+type samlAttribute struct {
+	Name         string   `xml:"Name,attr"`
+	FriendlyName string   `xml:"FriendlyName,attr"`
+	Values       []string `xml:"AttributeValue"`
+}
@@ -0,0 +1,624 @@
+package provider_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/beevik/etree"
+	errs "github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/oauth2"
+)
+
+const (
+	samlTestSPEntityID  = "https://auth.example.com/api/saml/metadata"
+	samlTestACSURL      = "https://auth.example.com/api/saml/acs"
+	samlTestSSOURL      = "https://auth.example.com/api/saml/sso"
+	samlTestIdPEntityID = "https://idp.example.com/metadata"
+	samlTestIdPSSOURL   = "https://idp.example.com/sso"
+	samlTestRelayState  = "some-state"
+)
+
+func TestSAMLIdentityProvider(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+	suite.Run(t, &samlIdentityProviderTestSuite{})
+}
+
+type samlIdentityProviderTestSuite struct {
+	suite.Suite
+	idp        *samlTestIdentityProvider
+	config     *samlTestConfig
+	assertions *samlTestAssertionStore
+	now        time.Time
+}
+
+func (s *samlIdentityProviderTestSuite) SetupTest() {
+	config, err := configuration.GetConfigurationData()
+	require.NoError(s.T(), err)
+	s.idp = newSAMLTestIdentityProvider()
+	s.now = time.Now().UTC().Truncate(time.Second)
+	s.assertions = &samlTestAssertionStore{consumed: map[string]time.Time{}}
+	s.config = &samlTestConfig{
+		ConfigurationData: config,
+		certificates:      s.idp.certificatePEM(),
+		binding:           provider.SAMLBindingHTTPRedirect,
+	}
+}
+
+func (s *samlIdentityProviderTestSuite) newProvider() *provider.SAMLIdentityProvider {
+	p, err := provider.NewSAMLIdentityProvider(s.config, s.assertions)
+	require.NoError(s.T(), err)
+	p.SetClock(func() time.Time { return s.now })
+	return p
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeAndProfileOK() {
+	// given
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{})
+
+	// when
+	token, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+
+	// then
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), token)
+	assert.Equal(s.T(), response, token.AccessToken)
+	assert.Equal(s.T(), s.now.Add(5*time.Minute), token.Expiry.UTC())
+
+	profile, err := p.Profile(context.Background(), *token)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "jdoe", profile.Username)
+	assert.Equal(s.T(), "jdoe@example.com", profile.Email)
+	assert.Equal(s.T(), "John", profile.GivenName)
+	assert.Equal(s.T(), "Doe", profile.FamilyName)
+	assert.Equal(s.T(), "Example Inc", profile.Company)
+	assert.Equal(s.T(), "jdoe-name-id", profile.Subject)
+	// the identity provider is not trusted to have verified the email address by default
+	assert.False(s.T(), profile.EmailVerified)
+	assert.True(s.T(), profile.Approved)
+}
+
+func (s *samlIdentityProviderTestSuite) TestProfileEmailVerified() {
+
+	s.T().Run("asserted as verified", func(t *testing.T) {
+		// given
+		s.config.mapping = map[string]string{
+			provider.SAMLAttributeUsername:      "uid",
+			provider.SAMLAttributeEmail:         "mail",
+			provider.SAMLAttributeEmailVerified: "mailVerified",
+		}
+		defer func() { s.config.mapping = nil }()
+		p := s.newProvider()
+		for value, verified := range map[string]bool{"true": true, "1": true, "false": false, "yes": false} {
+			response := s.idp.response(t, s.now, samlTestAssertionOptions{
+				attributes: map[string]string{
+					"uid":          "custom-user",
+					"mail":         "custom@example.com",
+					"mailVerified": value,
+				},
+			})
+			// when
+			profile, err := p.Profile(context.Background(), oauth2.Token{AccessToken: response})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, verified, profile.EmailVerified, "value %s", value)
+		}
+	})
+
+	s.T().Run("trusted identity provider", func(t *testing.T) {
+		// given
+		s.config.emailTrusted = true
+		defer func() { s.config.emailTrusted = false }()
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{})
+		// when
+		profile, err := p.Profile(context.Background(), oauth2.Token{AccessToken: response})
+		// then
+		require.NoError(t, err)
+		assert.True(t, profile.EmailVerified)
+	})
+}
+
+func (s *samlIdentityProviderTestSuite) TestProfileWithCustomAttributeMapping() {
+	// given
+	s.config.mapping = map[string]string{
+		provider.SAMLAttributeUsername:   "uid",
+		provider.SAMLAttributeEmail:      "mail",
+		provider.SAMLAttributeGivenName:  "givenName",
+		provider.SAMLAttributeFamilyName: "sn",
+	}
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{
+		attributes: map[string]string{
+			"uid":       "custom-user",
+			"mail":      "custom@example.com",
+			"givenName": "Custom",
+			"sn":        "User",
+		},
+	})
+
+	// when
+	profile, err := p.Profile(context.Background(), oauth2.Token{AccessToken: response})
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "custom-user", profile.Username)
+	assert.Equal(s.T(), "custom@example.com", profile.Email)
+	assert.Equal(s.T(), "Custom", profile.GivenName)
+	assert.Equal(s.T(), "User", profile.FamilyName)
+	assert.Equal(s.T(), "", profile.Company)
+}
+
+func (s *samlIdentityProviderTestSuite) TestProfileFallsBackToNameID() {
+	// given
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{attributes: map[string]string{}})
+
+	// when
+	profile, err := p.Profile(context.Background(), oauth2.Token{AccessToken: response})
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "jdoe-name-id", profile.Username)
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeSignedResponseOK() {
+	// given
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{signResponse: true})
+
+	// when
+	_, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+
+	// then
+	require.NoError(s.T(), err)
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeRedirectBindingOK() {
+	// given
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{deflate: true})
+
+	// when
+	_, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+
+	// then
+	require.NoError(s.T(), err)
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeFails() {
+
+	s.T().Run("tampered assertion", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{tamper: true})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("unsigned assertion", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{unsigned: true})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("signed by unknown identity provider", func(t *testing.T) {
+		p := s.newProvider()
+		response := newSAMLTestIdentityProvider().response(t, s.now, samlTestAssertionOptions{})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("wrong audience", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{audience: "https://other.example.com"})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("wrong issuer", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{issuer: "https://other-idp.example.com"})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("wrong recipient", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{recipient: "https://other.example.com/acs"})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("expired", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now.Add(-time.Hour), samlTestAssertionOptions{})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("not yet valid", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now.Add(time.Hour), samlTestAssertionOptions{})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("authentication failed", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{status: "urn:oasis:names:tc:SAML:2.0:status:Responder"})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("not base64", func(t *testing.T) {
+		p := s.newProvider()
+		assertSAMLUnauthorized(t, p, "not a SAML response")
+	})
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeReplayFails() {
+	// given
+	p := s.newProvider()
+	response := s.idp.response(s.T(), s.now, samlTestAssertionOptions{})
+	_, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+	require.NoError(s.T(), err)
+
+	// when
+	_, err = p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+
+	// then
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), autherrors.UnauthorizedError{}, errs.Cause(err))
+	assert.Equal(s.T(), "SAML assertion has already been used", err.Error())
+	// and the assertion is remembered until it expires
+	assert.Len(s.T(), s.assertions.consumed, 1)
+	for _, expiresAt := range s.assertions.consumed {
+		assert.Equal(s.T(), s.now.Add(5*time.Minute+s.config.GetSAMLClockSkew()), expiresAt.UTC())
+	}
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeBoundToRelayState() {
+
+	s.T().Run("other relay state", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{})
+		_, err := p.ExchangeWithState(context.Background(), response, "other-state")
+		require.Error(t, err)
+		assert.IsType(t, autherrors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("assertion for another request", func(t *testing.T) {
+		// the InResponseTo attribute of the unsigned response matches, but not the one of the signed assertion
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{assertionInResponseTo: provider.SAMLAuthnRequestID("other-state")})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("unsolicited response", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{unsolicited: true})
+		assertSAMLUnauthorized(t, p, response)
+	})
+
+	s.T().Run("without relay state", func(t *testing.T) {
+		p := s.newProvider()
+		response := s.idp.response(t, s.now, samlTestAssertionOptions{})
+		_, err := p.Exchange(context.Background(), response)
+		require.Error(t, err)
+		assert.IsType(t, autherrors.UnauthorizedError{}, errs.Cause(err))
+		_, err = p.ExchangeWithState(context.Background(), response, "")
+		require.Error(t, err)
+		assert.IsType(t, autherrors.UnauthorizedError{}, errs.Cause(err))
+		// and the assertion was not consumed
+		assert.Empty(t, s.assertions.consumed)
+	})
+}
+
+func (s *samlIdentityProviderTestSuite) TestExchangeWithinClockSkewOK() {
+	// given
+	p := s.newProvider()
+	// the assertion expires 5 minutes after it was issued, so it is only valid thanks to the 60 seconds of clock skew
+	response := s.idp.response(s.T(), s.now.Add(-5*time.Minute-30*time.Second), samlTestAssertionOptions{})
+
+	// when
+	_, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+
+	// then
+	require.NoError(s.T(), err)
+}
+
+func (s *samlIdentityProviderTestSuite) TestAuthCodeURLRedirectBinding() {
+	// given
+	p := s.newProvider()
+
+	// when
+	authURL := p.AuthCodeURL(samlTestRelayState)
+
+	// then
+	u, err := url.Parse(authURL)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "idp.example.com", u.Host)
+	assert.Equal(s.T(), "/sso", u.Path)
+	assert.Equal(s.T(), "some-state", u.Query().Get("RelayState"))
+
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	require.NoError(s.T(), err)
+	request, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.NoError(s.T(), err)
+	doc := etree.NewDocument()
+	require.NoError(s.T(), doc.ReadFromBytes(request))
+	assert.Equal(s.T(), "AuthnRequest", doc.Root().Tag)
+	assert.Equal(s.T(), provider.SAMLAuthnRequestID(samlTestRelayState), doc.Root().SelectAttrValue("ID", ""))
+	assert.Equal(s.T(), samlTestACSURL, doc.Root().SelectAttrValue("AssertionConsumerServiceURL", ""))
+	assert.Equal(s.T(), samlTestIdPSSOURL, doc.Root().SelectAttrValue("Destination", ""))
+	require.NotNil(s.T(), doc.Root().FindElement("./Issuer"))
+	assert.Equal(s.T(), samlTestSPEntityID, doc.Root().FindElement("./Issuer").Text())
+}
+
+func (s *samlIdentityProviderTestSuite) TestAuthCodeURLPostBinding() {
+	// given
+	s.config.binding = provider.SAMLBindingHTTPPost
+	p := s.newProvider()
+
+	// when
+	authURL := p.AuthCodeURL("some-state")
+	form, err := p.AuthnRequestPostForm("some-state")
+
+	// then
+	assert.Equal(s.T(), samlTestSSOURL+"?RelayState=some-state", authURL)
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), string(form), `action="`+samlTestIdPSSOURL+`"`)
+	assert.Contains(s.T(), string(form), `name="SAMLRequest"`)
+	assert.Contains(s.T(), string(form), `value="some-state"`)
+}
+
+func (s *samlIdentityProviderTestSuite) TestMetadata() {
+	// given
+	p := s.newProvider()
+
+	// when
+	metadata, err := p.Metadata()
+
+	// then
+	require.NoError(s.T(), err)
+	doc := etree.NewDocument()
+	require.NoError(s.T(), doc.ReadFromBytes(metadata))
+	assert.Equal(s.T(), "EntityDescriptor", doc.Root().Tag)
+	assert.Equal(s.T(), samlTestSPEntityID, doc.Root().SelectAttrValue("entityID", ""))
+	descriptor := doc.Root().FindElement("./SPSSODescriptor")
+	require.NotNil(s.T(), descriptor)
+	assert.Equal(s.T(), "true", descriptor.SelectAttrValue("WantAssertionsSigned", ""))
+	services := descriptor.FindElements("./AssertionConsumerService")
+	require.Len(s.T(), services, 2)
+	assert.Equal(s.T(), provider.SAMLBindingHTTPPost, services[0].SelectAttrValue("Binding", ""))
+	assert.Equal(s.T(), samlTestACSURL, services[0].SelectAttrValue("Location", ""))
+	assert.Equal(s.T(), provider.SAMLBindingHTTPRedirect, services[1].SelectAttrValue("Binding", ""))
+}
+
+func (s *samlIdentityProviderTestSuite) TestNewSAMLIdentityProviderWithoutCertificateFails() {
+	// given
+	s.config.certificates = ""
+
+	// when
+	_, err := provider.NewSAMLIdentityProvider(s.config, s.assertions)
+
+	// then
+	require.Error(s.T(), err)
+}
+
+func assertSAMLUnauthorized(t *testing.T, p *provider.SAMLIdentityProvider, response string) {
+	_, err := p.ExchangeWithState(context.Background(), response, samlTestRelayState)
+	require.Error(t, err)
+	assert.IsType(t, autherrors.UnauthorizedError{}, errs.Cause(err))
+}
+
+// samlTestConfig overrides the SAML configuration of the default configuration
+type samlTestConfig struct {
+	*configuration.ConfigurationData
+	certificates string
+	binding      string
+	mapping      map[string]string
+	emailTrusted bool
+}
+
+func (c *samlTestConfig) GetOAuthProviderType() string {
+	return provider.SAMLProviderType
+}
+
+func (c *samlTestConfig) GetSAMLServiceProviderEntityID() string {
+	return samlTestSPEntityID
+}
+
+func (c *samlTestConfig) GetSAMLServiceProviderACSURL() string {
+	return samlTestACSURL
+}
+
+func (c *samlTestConfig) GetSAMLServiceProviderSSOURL() string {
+	return samlTestSSOURL
+}
+
+func (c *samlTestConfig) GetSAMLIdentityProviderEntityID() string {
+	return samlTestIdPEntityID
+}
+
+func (c *samlTestConfig) GetSAMLIdentityProviderSSOURL() string {
+	return samlTestIdPSSOURL
+}
+
+func (c *samlTestConfig) GetSAMLIdentityProviderSSOBinding() string {
+	return c.binding
+}
+
+func (c *samlTestConfig) GetSAMLIdentityProviderCertificates() string {
+	return c.certificates
+}
+
+func (c *samlTestConfig) IsSAMLIdentityProviderEmailTrusted() bool {
+	return c.emailTrusted
+}
+
+func (c *samlTestConfig) GetSAMLAttributeMapping() map[string]string {
+	if c.mapping != nil {
+		return c.mapping
+	}
+	return c.ConfigurationData.GetSAMLAttributeMapping()
+}
+
+// samlTestIdentityProvider is a minimal in-process SAML identity provider which issues signed responses
+type samlTestIdentityProvider struct {
+	keyStore dsig.X509KeyStore
+}
+
+type samlTestAssertionOptions struct {
+	issuer                string
+	audience              string
+	recipient             string
+	status                string
+	assertionInResponseTo string
+	attributes            map[string]string
+	signResponse          bool
+	unsigned              bool
+	tamper                bool
+	deflate               bool
+	unsolicited           bool
+}
+
+func newSAMLTestIdentityProvider() *samlTestIdentityProvider {
+	return &samlTestIdentityProvider{keyStore: dsig.RandomKeyStoreForTest()}
+}
+
+func (idp *samlTestIdentityProvider) certificatePEM() string {
+	_, certificate, err := idp.keyStore.GetKeyPair()
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+}
+
+// response returns a base64 encoded SAML response containing an assertion issued at the given time, which is valid
+// for 5 minutes
+func (idp *samlTestIdentityProvider) response(t *testing.T, issuedAt time.Time, options samlTestAssertionOptions) string {
+	if options.issuer == "" {
+		options.issuer = samlTestIdPEntityID
+	}
+	if options.audience == "" {
+		options.audience = samlTestSPEntityID
+	}
+	if options.recipient == "" {
+		options.recipient = samlTestACSURL
+	}
+	if options.status == "" {
+		options.status = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	}
+	requestID := provider.SAMLAuthnRequestID(samlTestRelayState)
+	if options.assertionInResponseTo == "" {
+		options.assertionInResponseTo = requestID
+	}
+	if options.attributes == nil {
+		options.attributes = map[string]string{
+			"username":   "jdoe",
+			"email":      "jdoe@example.com",
+			"givenName":  "John",
+			"sn":         "Doe",
+			"company":    "Example Inc",
+			"irrelevant": "value",
+		}
+	}
+	notOnOrAfter := issuedAt.Add(5 * time.Minute).Format(time.RFC3339)
+
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	assertion.CreateAttr("ID", "_"+uuid.NewV4().String())
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", issuedAt.Format(time.RFC3339))
+	assertion.CreateElement("saml:Issuer").SetText(options.issuer)
+	subject := assertion.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText("jdoe-name-id")
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	confirmationData := confirmation.CreateElement("saml:SubjectConfirmationData")
+	confirmationData.CreateAttr("Recipient", options.recipient)
+	confirmationData.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	if !options.unsolicited {
+		confirmationData.CreateAttr("InResponseTo", options.assertionInResponseTo)
+	}
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", issuedAt.Add(-30*time.Second).Format(time.RFC3339))
+	conditions.CreateAttr("NotOnOrAfter", notOnOrAfter)
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(options.audience)
+	statement := assertion.CreateElement("saml:AttributeStatement")
+	for name, value := range options.attributes {
+		attribute := statement.CreateElement("saml:Attribute")
+		attribute.CreateAttr("Name", name)
+		attribute.CreateElement("saml:AttributeValue").SetText(value)
+	}
+
+	signingContext := dsig.NewDefaultSigningContext(idp.keyStore)
+	if !options.unsigned && !options.signResponse {
+		signed, err := signingContext.SignEnveloped(assertion)
+		require.NoError(t, err)
+		assertion = signed
+	}
+	if options.tamper {
+		assertion.FindElement("./Subject/NameID").SetText("someone-else")
+		for _, value := range assertion.FindElements("./AttributeStatement/Attribute/AttributeValue") {
+			value.SetText("someone-else")
+		}
+	}
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	response.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	response.CreateAttr("ID", "_"+uuid.NewV4().String())
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", issuedAt.Format(time.RFC3339))
+	response.CreateAttr("Destination", samlTestACSURL)
+	if !options.unsolicited {
+		response.CreateAttr("InResponseTo", requestID)
+	}
+	response.CreateElement("saml:Issuer").SetText(options.issuer)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", options.status)
+	response.AddChild(assertion)
+	if options.signResponse {
+		signed, err := signingContext.SignEnveloped(response)
+		require.NoError(t, err)
+		response = signed
+	}
+
+	doc := etree.NewDocument()
+	doc.SetRoot(response)
+	raw, err := doc.WriteToBytes()
+	require.NoError(t, err)
+	if options.deflate {
+		var buf bytes.Buffer
+		w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+		_, err = w.Write(raw)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		raw = buf.Bytes()
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// samlTestAssertionStore keeps the consumed assertions in memory
+type samlTestAssertionStore struct {
+	consumed map[string]time.Time
+}
+
+func (store *samlTestAssertionStore) Consume(ctx context.Context, assertionID string, expiresAt time.Time) (bool, error) {
+	if _, found := store.consumed[assertionID]; found {
+		return false, nil
+	}
+	store.consumed[assertionID] = expiresAt
+	return true, nil
+}
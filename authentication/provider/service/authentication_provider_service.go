@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -20,12 +21,14 @@ import (
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
 	name "github.com/fabric8-services/fabric8-auth/authentication/account"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	providerrepo "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	autherrors "github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/goadesign/goa"
 	errs "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
 	"golang.org/x/oauth2"
@@ -36,6 +39,10 @@ type AuthenticationProviderServiceConfig interface {
 	manager.TokenManagerConfiguration
 	GetPublicOAuthClientID() string
 	GetWITURL() (string, error)
+	GetMFAChallengeURL() string
+	IsStatelessOAuthStateEnabled() bool
+	GetOAuthStateKey() []byte
+	GetOAuthStateExpiry() time.Duration
 }
 
 type authenticationProviderServiceImpl struct {
@@ -86,7 +93,7 @@ func (s *authenticationProviderServiceImpl) GenerateAuthCodeURL(ctx context.Cont
 		return nil, err
 	}
 
-	err = s.SaveReferrer(ctx, *state, *redirect, responseMode, validRedirectURL)
+	providerState, err := s.CreateState(ctx, *state, *redirect, responseMode, validRedirectURL)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"state":         state,
@@ -110,7 +117,7 @@ func (s *authenticationProviderServiceImpl) GenerateAuthCodeURL(ctx context.Cont
 	}
 
 	// Generate the Authorization Code URL
-	redirectTo := provider.AuthCodeURL(*state, oauth2.AccessTypeOnline)
+	redirectTo := provider.AuthCodeURL(providerState, oauth2.AccessTypeOnline)
 
 	return &redirectTo, err
 }
@@ -126,12 +133,12 @@ func (s *authenticationProviderServiceImpl) LoginCallback(ctx context.Context, s
 		"state": state,
 	}, "Redirected from oauth provider")
 
-	referrerURL, _, err := s.reclaimReferrerAndResponseMode(ctx, state, code)
+	referrerURL, _, err := s.reclaimState(ctx, state, code)
 	if err != nil {
 		return nil, err
 	}
 
-	providerToken, err := s.ExchangeCodeWithProvider(ctx, code, redirectURL)
+	providerToken, err := s.exchangeCodeWithProvider(ctx, code, state, redirectURL)
 	if err != nil {
 		redirect := referrerURL.String() + "?error=" + url.QueryEscape(err.Error())
 		return &redirect, err
@@ -154,12 +161,12 @@ func (s *authenticationProviderServiceImpl) LoginCallback(ctx context.Context, s
 // When authorization_code is requested with /api/authorize, oauth provider returns authorization_code at /api/authorize/callback,
 // which would pass on the code along with the state to client using this method
 func (s *authenticationProviderServiceImpl) AuthorizeCallback(ctx context.Context, state string, code string) (*string, error) {
-	referrerURL, responseMode, err := s.reclaimReferrerAndResponseMode(ctx, state, code)
+	referrerURL, oauthState, err := s.reclaimState(ctx, state, code)
 	if err != nil {
 		return nil, err
 	}
 
-	redirectTo := buildRedirectURL(code, state, referrerURL, responseMode)
+	redirectTo := buildRedirectURL(code, oauthState.State, referrerURL, oauthState.ResponseMode)
 	return &redirectTo, nil
 }
 
@@ -200,7 +207,9 @@ func (s *authenticationProviderServiceImpl) ExchangeAuthorizationCodeForUserToke
 		return nil, nil, err
 	}
 
-	notApprovedRedirectURL, userToken, err := s.CreateOrUpdateIdentityAndUser(ctx, redirectURL, providerToken)
+	// there is no browser in this flow to complete a multi-factor challenge, so the exchange is refused for the users
+	// who must enter a second factor at login
+	notApprovedRedirectURL, userToken, err := s.createOrUpdateIdentityAndUser(ctx, redirectURL, providerToken, false)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -232,11 +241,24 @@ func (s *authenticationProviderServiceImpl) ExchangeAuthorizationCodeForUserToke
 
 // Exchange exchanges the given code for OAuth2 token with the Authentication provider
 func (s *authenticationProviderServiceImpl) ExchangeCodeWithProvider(ctx context.Context, code string, redirectURL string) (*oauth2.Token, error) {
+	return s.exchangeCodeWithProvider(ctx, code, "", redirectURL)
+}
+
+// exchangeCodeWithProvider exchanges the given code for OAuth2 token with the Authentication provider. The state of
+// the login which the code was returned for is passed to the providers which validate the code against it (such as
+// the SAML service provider), and those providers refuse the exchange if the state is empty.
+func (s *authenticationProviderServiceImpl) exchangeCodeWithProvider(ctx context.Context, code string, state string, redirectURL string) (*oauth2.Token, error) {
 
 	// Exchange the code for an access token
-	provider := s.Factories().IdentityProviderFactory().NewIdentityProvider(ctx, s.config)
-	provider.SetRedirectURL(redirectURL)
-	token, err := provider.Exchange(ctx, code)
+	identityProvider := s.Factories().IdentityProviderFactory().NewIdentityProvider(ctx, s.config)
+	identityProvider.SetRedirectURL(redirectURL)
+	var token *oauth2.Token
+	var err error
+	if stateBound, ok := identityProvider.(provider.StateBoundIdentityProvider); ok && state != "" {
+		token, err = stateBound.ExchangeWithState(ctx, code, state)
+	} else {
+		token, err = identityProvider.Exchange(ctx, code)
+	}
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"code":         code,
@@ -254,9 +276,16 @@ func (s *authenticationProviderServiceImpl) ExchangeCodeWithProvider(ctx context
 }
 
 // CreateOrUpdateIdentityAndUser creates or updates the user and identity associated with the oauth-provided user token,
-// checks whether the user is approved, generates a new user token and returns a final URL to which the client should redirect
+// checks whether the user is approved, generates a new user token and returns a final URL to which the client should redirect.
+// If the user must also enter their second factor then no token is returned, and the URL is the one of the multi-factor
+// authentication challenge.
 func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
 	providerToken *oauth2.Token) (*string, *oauth2.Token, error) {
+	return s.createOrUpdateIdentityAndUser(ctx, referrerURL, providerToken, true)
+}
+
+func (s *authenticationProviderServiceImpl) createOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
+	providerToken *oauth2.Token, challenge bool) (*string, *oauth2.Token, error) {
 
 	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
 	if err != nil {
@@ -329,6 +358,93 @@ func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx co
 		"user_name":   identity.Username,
 	}, "local user created/updated")
 
+	// Attach the invitations which were addressed to the email address of the user before they signed up
+	err = s.Services().InvitationService().ClaimInvitations(ctx, identity.ID)
+	if err != nil {
+		// Not critical. Just log the error and proceed
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"identity_id": identity.ID,
+		}, "failed to claim the invitations addressed to the user email")
+	}
+
+	amr := []string{mfa.AMRFederated}
+	if provider.IsLocalIdentityProvider(s.config) {
+		amr = []string{mfa.AMRPassword}
+	}
+
+	required, err := s.Services().MFAService().ChallengeRequired(ctx, identity.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if required {
+		if !challenge {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": identity.ID,
+			}, "multi-factor authentication required but no challenge is possible; refusing to issue the tokens")
+			return nil, nil, autherrors.NewUnauthorizedError("multi-factor authentication required, please login with a browser")
+		}
+		challengeID, err := s.Services().MFAService().CreateChallenge(ctx, identity.ID, referrerURL.String(), amr)
+		if err != nil {
+			return nil, nil, err
+		}
+		redirectTo, err := rest.AddParam(s.config.GetMFAChallengeURL(), "challenge", challengeID.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Debug(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+		}, "multi-factor authentication required; redirecting to the challenge")
+		return &redirectTo, nil, nil
+	}
+
+	return s.issueUserToken(ctx, referrerURL, identity, apiClient, mfa.ACRSingleFactor, amr)
+}
+
+// LoginChallengeCallback is invoked after the user entered their second factor in the multi-factor authentication
+// challenge of their login. It completes the login which was started with the identity provider, and also returns
+// the recovery codes of the user if they enrolled during the challenge.
+func (s *authenticationProviderServiceImpl) LoginChallengeCallback(ctx context.Context, challengeID uuid.UUID, code string) (*string, []string, error) {
+	challenge, recoveryCodes, err := s.Services().MFAService().CompleteChallenge(ctx, challengeID, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	referrerURL, err := url.Parse(challenge.Referrer)
+	if err != nil {
+		return nil, nil, autherrors.NewInternalError(ctx, err)
+	}
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, challenge.IdentityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity.User.Banned {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+			"user_name":   identity.Username,
+		}, "banned user tried to login")
+		return nil, nil, autherrors.NewUnauthorizedError("unauthorized access")
+	}
+	amr := append(challenge.Methods(), mfa.AMROneTimePassword, mfa.AMRMultiFactor)
+	redirectTo, _, err := s.issueUserToken(ctx, referrerURL, identity, referrerURL.Query().Get(apiClientParam), mfa.ACRMultiFactor, amr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return redirectTo, recoveryCodes, nil
+}
+
+// issueUserToken generates and registers a new user token with the given authentication context, and encodes it in
+// the referrer URL to which the client should redirect
+func (s *authenticationProviderServiceImpl) issueUserToken(ctx context.Context, referrerURL *url.URL, identity *account.Identity,
+	apiClient string, acr string, amr []string) (*string, *oauth2.Token, error) {
+
+	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to retrieve token manager from context")
+		return nil, nil, autherrors.NewInternalError(ctx, err)
+	}
+
 	// Update the identity's last active timestamp
 	err = s.Repositories().Identities().TouchLastActive(ctx, identity.ID)
 	if err != nil {
@@ -337,7 +453,7 @@ func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx co
 	}
 
 	// Generate a new user token instead of using the original oauth provider token
-	userToken, err := tokenManager.GenerateUserTokenForIdentity(ctx, *identity, false)
+	userToken, err := tokenManager.GenerateUserTokenForIdentity(ctx, *identity, false, manager.WithAuthenticationContext(acr, amr))
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to generate token for user")
 		return nil, nil, err
@@ -466,22 +582,10 @@ func (s *authenticationProviderServiceImpl) saveParams(ctx context.Context, redi
 func (s *authenticationProviderServiceImpl) SaveReferrer(ctx context.Context, state string, referrer string,
 	responseMode *string, validReferrerURL string) error {
 
-	matched, err := regexp.MatchString(validReferrerURL, referrer)
+	err := validateReferrer(ctx, referrer, validReferrerURL)
 	if err != nil {
-		log.Error(ctx, map[string]interface{}{
-			"referrer":           referrer,
-			"valid_referrer_url": validReferrerURL,
-			"err":                err,
-		}, "Can't match referrer and whitelist regex")
 		return err
 	}
-	if !matched {
-		log.Error(ctx, map[string]interface{}{
-			"referrer":           referrer,
-			"valid_referrer_url": validReferrerURL,
-		}, "Referrer not valid")
-		return autherrors.NewBadParameterError("redirect", "not valid redirect URL")
-	}
 	// TODO The state reference table will be collecting dead states left from some failed login attempts.
 	// We need to clean up the old states from time to time.
 	ref := providerrepo.OauthStateReference{
@@ -507,8 +611,72 @@ func (s *authenticationProviderServiceImpl) SaveReferrer(ctx context.Context, st
 	return nil
 }
 
-// LoadReferrerAndResponseMode loads referrer and responseMode from DB
+// CreateState validates the referrer and saves it along with the response mode, and returns the value of the state
+// parameter to send to the provider. If stateless OAuth states are enabled then nothing is saved in the DB: the
+// returned value is the encrypted state, which is bound to the browser by a cookie. Otherwise the given state is
+// returned once saved.
+func (s *authenticationProviderServiceImpl) CreateState(ctx context.Context, state string, referrer string,
+	responseMode *string, validReferrerURL string) (string, error) {
+	if !s.config.IsStatelessOAuthStateEnabled() {
+		err := s.SaveReferrer(ctx, state, referrer, responseMode, validReferrerURL)
+		if err != nil {
+			return "", err
+		}
+		return state, nil
+	}
+
+	err := validateReferrer(ctx, referrer, validReferrerURL)
+	if err != nil {
+		return "", err
+	}
+	binding, err := s.bindOAuthState(ctx)
+	if err != nil {
+		return "", err
+	}
+	value, err := provider.EncryptOAuthState(s.config.GetOAuthStateKey(), provider.OAuthState{
+		Binding:      binding,
+		State:        state,
+		Referrer:     referrer,
+		ResponseMode: responseMode,
+		ExpiresAt:    time.Now().Add(s.config.GetOAuthStateExpiry()).Unix(),
+	})
+	if err != nil {
+		return "", autherrors.NewInternalError(ctx, err)
+	}
+	return value, nil
+}
+
+// LoadReferrerAndResponseMode loads referrer and responseMode of the given state, either from the DB or from the
+// state itself if it is stateless
 func (s *authenticationProviderServiceImpl) LoadReferrerAndResponseMode(ctx context.Context, state string) (string, *string, error) {
+	oauthState, err := s.loadState(ctx, state)
+	if err != nil {
+		return "", nil, err
+	}
+	return oauthState.Referrer, oauthState.ResponseMode, nil
+}
+
+// loadState returns the OAuth state of the given state parameter. Stateless states must be bound to the browser
+// which sent the request, while states saved in the DB are deleted once loaded. States which were saved in the DB
+// are accepted even if stateless states are enabled, and conversely, so that the mode can be switched at any time.
+func (s *authenticationProviderServiceImpl) loadState(ctx context.Context, state string) (*provider.OAuthState, error) {
+	if provider.IsStatelessOAuthState(state) {
+		oauthState, err := provider.DecryptOAuthState(s.config.GetOAuthStateKey(), state)
+		if err == nil {
+			if !oauthState.BoundTo(oauthStateCookie(ctx)) {
+				log.Error(ctx, map[string]interface{}{
+					"referrer": oauthState.Referrer,
+				}, "oauth state is not bound to the browser of the request")
+				return nil, autherrors.NewUnauthorizedError("oauth state is not bound to this browser")
+			}
+			return oauthState, nil
+		}
+		// the state may still have been saved in the DB, if a client of the authorize endpoint chose it
+		log.Warn(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to decrypt the oauth state, looking it up in the DB")
+	}
+
 	var referrer string
 	var responseMode *string
 
@@ -535,14 +703,18 @@ func (s *authenticationProviderServiceImpl) LoadReferrerAndResponseMode(ctx cont
 		return nil
 	})
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	return referrer, responseMode, nil
+	return &provider.OAuthState{
+		State:        state,
+		Referrer:     referrer,
+		ResponseMode: responseMode,
+	}, nil
 }
 
-// reclaimReferrer reclaims referrerURL and verifies the state
-func (s *authenticationProviderServiceImpl) reclaimReferrerAndResponseMode(ctx context.Context, state string, code string) (*url.URL, *string, error) {
-	knownReferrer, responseMode, err := s.LoadReferrerAndResponseMode(ctx, state)
+// reclaimState reclaims referrerURL and verifies the state
+func (s *authenticationProviderServiceImpl) reclaimState(ctx context.Context, state string, code string) (*url.URL, *provider.OAuthState, error) {
+	oauthState, err := s.loadState(ctx, state)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"state": state,
@@ -550,6 +722,7 @@ func (s *authenticationProviderServiceImpl) reclaimReferrerAndResponseMode(ctx c
 		}, "unknown state")
 		return nil, nil, autherrors.NewUnauthorizedError("unknown state: " + err.Error())
 	}
+	knownReferrer := oauthState.Referrer
 	referrerURL, err := url.Parse(knownReferrer)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -565,10 +738,76 @@ func (s *authenticationProviderServiceImpl) reclaimReferrerAndResponseMode(ctx c
 		"code":           code,
 		"state":          state,
 		"known_referrer": knownReferrer,
-		"response_mode":  responseMode,
+		"response_mode":  log.PointerToString(oauthState.ResponseMode),
 	}, "referrer found")
 
-	return referrerURL, responseMode, nil
+	return referrerURL, oauthState, nil
+}
+
+// validateReferrer returns a bad parameter error if the referrer does not match the valid referrer URL regex
+func validateReferrer(ctx context.Context, referrer string, validReferrerURL string) error {
+	matched, err := regexp.MatchString(validReferrerURL, referrer)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"referrer":           referrer,
+			"valid_referrer_url": validReferrerURL,
+			"err":                err,
+		}, "Can't match referrer and whitelist regex")
+		return err
+	}
+	if !matched {
+		log.Error(ctx, map[string]interface{}{
+			"referrer":           referrer,
+			"valid_referrer_url": validReferrerURL,
+		}, "Referrer not valid")
+		return autherrors.NewBadParameterError("redirect", "not valid redirect URL")
+	}
+	return nil
+}
+
+// bindOAuthState returns the value of the cookie which stateless OAuth states are bound to. The cookie of the request
+// is reused if there is one, so that logins started in several tabs of the same browser can all complete.
+func (s *authenticationProviderServiceImpl) bindOAuthState(ctx context.Context) (string, error) {
+	req := goa.ContextRequest(ctx)
+	resp := goa.ContextResponse(ctx)
+	if req == nil || resp == nil {
+		return "", autherrors.NewInternalErrorFromString(ctx, "missing request or response in context")
+	}
+	binding := oauthStateCookie(ctx)
+	if binding == "" {
+		var err error
+		binding, err = provider.NewOAuthStateBinding()
+		if err != nil {
+			return "", autherrors.NewInternalError(ctx, err)
+		}
+	}
+	cookie := &http.Cookie{
+		Name:     provider.OAuthStateCookieName,
+		Value:    binding,
+		Path:     "/api",
+		MaxAge:   int(s.config.GetOAuthStateExpiry() / time.Second),
+		Secure:   true,
+		HttpOnly: true,
+	}
+	// the cookie must be sent along with the cross-site POST of the SAML HTTP-POST binding to the assertion consumer
+	// service, which browsers only do for the cookies with SameSite=None (the default being Lax), and they only accept
+	// such cookies if they are also secure. The attribute is appended since http.SameSiteNoneMode requires Go 1.13.
+	resp.Header().Add("Set-Cookie", cookie.String()+"; SameSite=None")
+	return binding, nil
+}
+
+// oauthStateCookie returns the value of the cookie which stateless OAuth states are bound to, or an empty string if
+// the request has none
+func oauthStateCookie(ctx context.Context) string {
+	req := goa.ContextRequest(ctx)
+	if req == nil {
+		return ""
+	}
+	cookie, err := req.Cookie(provider.OAuthStateCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
 }
 
 // encodeToken
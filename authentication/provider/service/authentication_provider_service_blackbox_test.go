@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -1068,6 +1069,100 @@ func (s *authenticationProviderServiceTestSuite) TestInvalidOAuthStateForAuthori
 	assert.Equal(s.T(), 401, rw.Code)
 }
 
+func (s *authenticationProviderServiceTestSuite) TestStatelessOAuthState() {
+	stateless := os.Getenv("AUTH_OAUTH_STATE_STATELESS")
+	defer func() {
+		os.Setenv("AUTH_OAUTH_STATE_STATELESS", stateless)
+		s.resetConfiguration()
+	}()
+	os.Setenv("AUTH_OAUTH_STATE_STATELESS", "true")
+	s.resetConfiguration()
+	application := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers)
+
+	// newContext returns the context of a request sent with the given cookies
+	newContext := func(cookies ...*http.Cookie) (context.Context, *httptest.ResponseRecorder) {
+		rw := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/api/login", nil)
+		require.NoError(s.T(), err)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return goa.NewContext(goa.WithAction(context.Background(), "LoginTest"), rw, req, url.Values{}), rw
+	}
+	referrer := "https://alm-url.example.org/path"
+	responseMode := "fragment"
+	ctx, rw := newContext()
+	state, err := application.AuthenticationProviderService().CreateState(ctx, "client-state", referrer, &responseMode, s.Configuration.GetValidRedirectURLs())
+	require.NoError(s.T(), err)
+	require.True(s.T(), provider.IsStatelessOAuthState(state))
+	cookies := rw.Result().Cookies()
+	require.Len(s.T(), cookies, 1)
+	assert.Equal(s.T(), provider.OAuthStateCookieName, cookies[0].Name)
+	assert.True(s.T(), cookies[0].HttpOnly)
+	// the cookie is sent along with cross-site requests, as the POST of the SAML HTTP-POST binding
+	assert.True(s.T(), cookies[0].Secure)
+	assert.Contains(s.T(), rw.Header().Get("Set-Cookie"), "; SameSite=None")
+
+	s.T().Run("same browser", func(t *testing.T) {
+		// when
+		ctx, _ := newContext(cookies[0])
+		loadedReferrer, loadedResponseMode, err := application.AuthenticationProviderService().LoadReferrerAndResponseMode(ctx, state)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, referrer, loadedReferrer)
+		require.NotNil(t, loadedResponseMode)
+		assert.Equal(t, responseMode, *loadedResponseMode)
+	})
+
+	s.T().Run("same browser with the SAML HTTP-POST binding", func(t *testing.T) {
+		// given the identity provider makes the browser post the response and the state to the assertion consumer service
+		form := url.Values{"SAMLResponse": {"some-response"}, "RelayState": {state}}
+		req, err := http.NewRequest("POST", "/api/saml/acs", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", "https://idp.example.com")
+		req.AddCookie(cookies[0])
+		ctx := goa.NewContext(goa.WithAction(context.Background(), "SAMLTest"), httptest.NewRecorder(), req, url.Values{})
+		// when
+		loadedReferrer, _, err := application.AuthenticationProviderService().LoadReferrerAndResponseMode(ctx, req.FormValue("RelayState"))
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, referrer, loadedReferrer)
+	})
+
+	s.T().Run("cookie reused", func(t *testing.T) {
+		// when
+		ctx, rw := newContext(cookies[0])
+		_, err := application.AuthenticationProviderService().CreateState(ctx, "other-state", referrer, nil, s.Configuration.GetValidRedirectURLs())
+		// then
+		require.NoError(t, err)
+		require.Len(t, rw.Result().Cookies(), 1)
+		assert.Equal(t, cookies[0].Value, rw.Result().Cookies()[0].Value)
+	})
+
+	s.T().Run("other browser", func(t *testing.T) {
+		// when
+		ctx, _ := newContext(&http.Cookie{Name: provider.OAuthStateCookieName, Value: "other"})
+		_, _, err := application.AuthenticationProviderService().LoadReferrerAndResponseMode(ctx, state)
+		// then
+		require.Error(t, err)
+		require.IsType(t, autherrors.UnauthorizedError{}, err)
+	})
+
+	s.T().Run("stored state still accepted", func(t *testing.T) {
+		// given
+		storedState := uuid.NewV4().String()
+		err := application.AuthenticationProviderService().SaveReferrer(context.Background(), storedState, referrer, nil, s.Configuration.GetValidRedirectURLs())
+		require.NoError(t, err)
+		// when
+		ctx, _ := newContext()
+		loadedReferrer, _, err := application.AuthenticationProviderService().LoadReferrerAndResponseMode(ctx, storedState)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, referrer, loadedReferrer)
+	})
+}
+
 func (s *authenticationProviderServiceTestSuite) TestCreateOrUpdateIdentityAndUserOK() {
 	// given
 	redirectURL := "redirect_url"
@@ -79,8 +79,7 @@ func (s *linkServiceImpl) ProviderLocation(ctx context.Context, req *goa.Request
 	if err != nil {
 		return "", err
 	}
-	state := uuid.NewV4().String()
-	err = s.Services().AuthenticationProviderService().SaveReferrer(ctx, state, redirectURL, nil, s.config.GetValidRedirectURLs())
+	state, err := s.Services().AuthenticationProviderService().CreateState(ctx, uuid.NewV4().String(), redirectURL, nil, s.config.GetValidRedirectURLs())
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"redirect_url": redirectURL,
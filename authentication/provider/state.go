@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+// OAuthStateCookieName is the name of the browser cookie which stateless OAuth states are bound to
+const OAuthStateCookieName = "f8auth_oauth_state"
+
+// statelessOAuthStatePrefix identifies the state parameters which carry an encrypted OAuth state, so that they can be
+// told apart from the states stored in the DB and both kinds of states can be accepted at the same time
+const statelessOAuthStatePrefix = "s1."
+
+// OAuthState is the state of a login or an account link which, when stateless OAuth states are enabled, is encrypted
+// into the state parameter sent to the identity provider instead of being stored in the DB
+type OAuthState struct {
+	// Binding is the random value of the browser cookie which the state is bound to
+	Binding string `json:"bnd"`
+	// State is the state parameter of the client of the authorize endpoint, which is returned to it on callback
+	State string `json:"st,omitempty"`
+	// Referrer is the URL to which the user is redirected once the login or the account link is completed
+	Referrer string `json:"ref"`
+	// ResponseMode is the response mode requested by the client of the authorize endpoint, if any
+	ResponseMode *string `json:"rm,omitempty"`
+	// ExpiresAt is the time after which the state is no longer accepted, in seconds since the epoch
+	ExpiresAt int64 `json:"exp"`
+}
+
+// IsStatelessOAuthState returns true if the given state parameter carries an encrypted OAuth state
+func IsStatelessOAuthState(state string) bool {
+	return strings.HasPrefix(state, statelessOAuthStatePrefix)
+}
+
+// NewOAuthStateBinding returns a new random value for the cookie which OAuth states are bound to
+func NewOAuthStateBinding() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to generate the OAuth state binding")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// BoundTo returns true if the state was issued for the browser which sent the given cookie value
+func (s OAuthState) BoundTo(binding string) bool {
+	return binding != "" && subtle.ConstantTimeCompare([]byte(s.Binding), []byte(binding)) == 1
+}
+
+// EncryptOAuthState encrypts and authenticates the given state with a key derived from the secret, and returns the
+// value to use as the state parameter
+func EncryptOAuthState(secret []byte, state OAuthState) (string, error) {
+	aead, err := oauthStateAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to marshal the OAuth state")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to generate the OAuth state nonce")
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(statelessOAuthStatePrefix))
+	return statelessOAuthStatePrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptOAuthState returns the OAuth state carried by the given state parameter. It returns an error if the state
+// was not encrypted with a key derived from the secret, if it was tampered with or if it has expired.
+func DecryptOAuthState(secret []byte, value string) (*OAuthState, error) {
+	if !IsStatelessOAuthState(value) {
+		return nil, errs.New("not a stateless OAuth state")
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, statelessOAuthStatePrefix))
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to decode the OAuth state")
+	}
+	aead, err := oauthStateAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errs.New("OAuth state is too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(statelessOAuthStatePrefix))
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to decrypt the OAuth state")
+	}
+	var state OAuthState
+	err = json.Unmarshal(plaintext, &state)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to unmarshal the OAuth state")
+	}
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, errs.New("OAuth state has expired")
+	}
+	return &state, nil
+}
+
+// oauthStateAEAD returns the AES-256-GCM cipher keyed with the SHA-256 hash of the secret
+func oauthStateAEAD(secret []byte) (cipher.AEAD, error) {
+	if len(secret) == 0 {
+		return nil, errs.New("OAuth state key is empty")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to create the OAuth state cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to create the OAuth state cipher")
+	}
+	return aead, nil
+}
@@ -0,0 +1,89 @@
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthState(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	secret := []byte("secret")
+	responseMode := "fragment"
+	binding, err := provider.NewOAuthStateBinding()
+	require.NoError(t, err)
+	state := provider.OAuthState{
+		Binding:      binding,
+		State:        uuid.NewV4().String(),
+		Referrer:     "https://example.com/home?foo=bar",
+		ResponseMode: &responseMode,
+		ExpiresAt:    time.Now().Add(time.Minute).Unix(),
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		// when
+		value, err := provider.EncryptOAuthState(secret, state)
+		require.NoError(t, err)
+		// then
+		assert.True(t, provider.IsStatelessOAuthState(value))
+		assert.NotContains(t, value, "example.com")
+		decrypted, err := provider.DecryptOAuthState(secret, value)
+		require.NoError(t, err)
+		assert.Equal(t, state, *decrypted)
+		assert.True(t, decrypted.BoundTo(binding))
+		assert.False(t, decrypted.BoundTo(""))
+		otherBinding, err := provider.NewOAuthStateBinding()
+		require.NoError(t, err)
+		assert.False(t, decrypted.BoundTo(otherBinding))
+	})
+
+	t.Run("stored state", func(t *testing.T) {
+		assert.False(t, provider.IsStatelessOAuthState(uuid.NewV4().String()))
+		_, err := provider.DecryptOAuthState(secret, uuid.NewV4().String())
+		require.Error(t, err)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		// given
+		value, err := provider.EncryptOAuthState(secret, state)
+		require.NoError(t, err)
+		// when
+		_, err = provider.DecryptOAuthState([]byte("other secret"), value)
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		// given
+		value, err := provider.EncryptOAuthState(secret, state)
+		require.NoError(t, err)
+		i := len(value) / 2
+		replacement := "A"
+		if value[i:i+1] == replacement {
+			replacement = "B"
+		}
+		// when
+		_, err = provider.DecryptOAuthState(secret, value[:i]+replacement+value[i+1:])
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		// given
+		expired := state
+		expired.ExpiresAt = time.Now().Add(-time.Second).Unix()
+		value, err := provider.EncryptOAuthState(secret, expired)
+		require.NoError(t, err)
+		// when
+		_, err = provider.DecryptOAuthState(secret, value)
+		// then
+		require.Error(t, err)
+	})
+}
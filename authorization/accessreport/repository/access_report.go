@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// The formats in which an access report can be generated
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// The statuses of an access report
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// AccessReport is an export of the effective scopes of every identity for a resource and all its descendants. Since
+// it may take a while for large resource trees, the report is requested first and generated later by a worker.
+type AccessReport struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	AccessReportID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:access_report_id"`
+	// The resource whose tree is reported
+	ResourceID string
+	// The identity which requested the report
+	RequestedBy uuid.UUID `sql:"type:uuid" gorm:"column:requested_by"`
+	// The format of the report, either `csv` or `ndjson`
+	Format string
+	// The status of the report, either `pending`, `completed` or `failed`
+	Status string
+	// The size of the content of the report in bytes, once it is completed
+	Size int64
+	// The number of chunks in which the content of the report is stored, once it is completed
+	Chunks int
+	// The reason why the report could not be generated, if it failed
+	Error *string
+	// The time at which the report was completed or failed
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m AccessReport) TableName() string {
+	return "access_report"
+}
+
+// AccessReportChunk is a part of the content of an access report. The content is the concatenation of the chunks of
+// the report, in the order of their sequence numbers.
+type AccessReportChunk struct {
+	AccessReportID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:access_report_id"`
+	// The sequence number of the chunk, starting from 0
+	Seq int `gorm:"primary_key;column:seq"`
+	// The content of the chunk
+	Content string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m AccessReportChunk) TableName() string {
+	return "access_report_chunk"
+}
+
+// GormAccessReportRepository is the implementation of the storage interface for AccessReport.
+type GormAccessReportRepository struct {
+	db *gorm.DB
+}
+
+// NewAccessReportRepository creates a new storage type.
+func NewAccessReportRepository(db *gorm.DB) AccessReportRepository {
+	return &GormAccessReportRepository{db: db}
+}
+
+// AccessReportRepository represents the storage interface.
+type AccessReportRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*AccessReport, error)
+	Create(ctx context.Context, report *AccessReport) error
+	Save(ctx context.Context, report *AccessReport) error
+	// FindPending returns the oldest reports which are still to be generated, up to the given limit
+	FindPending(ctx context.Context, limit int) ([]AccessReport, error)
+	// FindExpired returns the oldest reports which were completed or failed before the given time, up to the given limit
+	FindExpired(ctx context.Context, before time.Time, limit int) ([]AccessReport, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// CreateChunk stores a chunk of the content of a report
+	CreateChunk(ctx context.Context, chunk *AccessReportChunk) error
+	// LoadChunk returns the chunk of the content of a report with the given sequence number
+	LoadChunk(ctx context.Context, id uuid.UUID, seq int) (*AccessReportChunk, error)
+	// DeleteChunks deletes all the chunks of the content of a report
+	DeleteChunks(ctx context.Context, id uuid.UUID) error
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormAccessReportRepository) TableName() string {
+	return "access_report"
+}
+
+// Load returns a single AccessReport as a Database Model
+func (m *GormAccessReportRepository) Load(ctx context.Context, id uuid.UUID) (*AccessReport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "load"}, time.Now())
+	var native AccessReport
+	err := m.db.Table(m.TableName()).Where("access_report_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("access_report", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormAccessReportRepository) Create(ctx context.Context, report *AccessReport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "create"}, time.Now())
+	if report.AccessReportID == uuid.Nil {
+		report.AccessReportID = uuid.NewV4()
+	}
+	err := m.db.Create(report).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": report.ResourceID,
+			"err":         err,
+		}, "unable to create the access report")
+		if gormsupport.IsForeignKeyViolation(err, "access_report_resource_id_fkey") {
+			return errs.WithStack(errors.NewNotFoundError("resource", report.ResourceID))
+		}
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"access_report_id": report.AccessReportID,
+	}, "Access report created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormAccessReportRepository) Save(ctx context.Context, report *AccessReport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "save"}, time.Now())
+
+	err := m.db.Save(report).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_report_id": report.AccessReportID,
+			"err":              err,
+		}, "unable to update the access report")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"access_report_id": report.AccessReportID,
+	}, "Access report saved!")
+	return nil
+}
+
+// FindPending returns the oldest reports which are still to be generated, up to the given limit
+func (m *GormAccessReportRepository) FindPending(ctx context.Context, limit int) ([]AccessReport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "FindPending"}, time.Now())
+
+	var rows []AccessReport
+	err := m.db.Table(m.TableName()).Where("status = ?", StatusPending).Order("created_at").Limit(limit).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// FindExpired returns the oldest reports which were completed or failed before the given time, up to the given limit
+func (m *GormAccessReportRepository) FindExpired(ctx context.Context, before time.Time, limit int) ([]AccessReport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "FindExpired"}, time.Now())
+
+	var rows []AccessReport
+	err := m.db.Table(m.TableName()).Where("completed_at IS NOT NULL AND completed_at < ?", before).
+		Order("completed_at").Limit(limit).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// Delete removes a single record, along with the chunks of its content.
+func (m *GormAccessReportRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report", "delete"}, time.Now())
+
+	err := m.DeleteChunks(ctx, id)
+	if err != nil {
+		return err
+	}
+	result := m.db.Delete(&AccessReport{AccessReportID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_report_id": id,
+			"err":              result.Error,
+		}, "unable to delete the access report")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("access_report", id.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"access_report_id": id,
+	}, "Access report deleted!")
+	return nil
+}
+
+// CreateChunk stores a chunk of the content of a report
+func (m *GormAccessReportRepository) CreateChunk(ctx context.Context, chunk *AccessReportChunk) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report_chunk", "create"}, time.Now())
+
+	err := m.db.Create(chunk).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_report_id": chunk.AccessReportID,
+			"seq":              chunk.Seq,
+			"err":              err,
+		}, "unable to create the access report chunk")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// LoadChunk returns the chunk of the content of a report with the given sequence number
+func (m *GormAccessReportRepository) LoadChunk(ctx context.Context, id uuid.UUID, seq int) (*AccessReportChunk, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report_chunk", "load"}, time.Now())
+
+	var native AccessReportChunk
+	err := m.db.Table(native.TableName()).Where("access_report_id = ? AND seq = ?", id, seq).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("access_report_chunk", fmt.Sprintf("%s/%d", id, seq))
+	}
+	return &native, errs.WithStack(err)
+}
+
+// DeleteChunks deletes all the chunks of the content of a report. The chunks are deleted for good, since a report is
+// never restored.
+func (m *GormAccessReportRepository) DeleteChunks(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_report_chunk", "DeleteChunks"}, time.Now())
+
+	err := m.db.Exec("DELETE FROM access_report_chunk WHERE access_report_id = ?", id).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_report_id": id,
+			"err":              err,
+		}, "unable to delete the access report chunks")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	accessreportrepo "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// pendingReportsBatchSize is the maximum number of access reports generated at each cycle of the worker
+const pendingReportsBatchSize = 10
+
+// expiredReportsBatchSize is the maximum number of access reports deleted at each cycle of the purge worker
+const expiredReportsBatchSize = 10
+
+// accessReportChunkSize is the maximum size in bytes of each chunk in which the content of an access report is stored
+const accessReportChunkSize = 1024 * 1024
+
+// AccessReportConfiguration the configuration of the access report service
+type AccessReportConfiguration interface {
+	GetAccessReportMaxSizeBytes() int64
+	GetAccessReportRetention() time.Duration
+}
+
+// NewAccessReportService creates a new service to generate the access reports
+func NewAccessReportService(context servicecontext.ServiceContext, config AccessReportConfiguration) service.AccessReportService {
+	return &accessReportServiceImpl{
+		BaseService: base.NewBaseService(context),
+		config:      config}
+}
+
+// accessReportServiceImpl implements the AccessReportService to generate the access reports
+type accessReportServiceImpl struct {
+	base.BaseService
+	config AccessReportConfiguration
+}
+
+// accessReportRow is a scope granted to an identity for a resource, along with the compact form of every path which
+// grants it
+type accessReportRow struct {
+	ResourceID   string   `json:"resource_id"`
+	ResourceName string   `json:"resource_name"`
+	ResourceType string   `json:"resource_type"`
+	IdentityID   string   `json:"identity_id"`
+	IdentityName string   `json:"identity_name"`
+	IdentityType string   `json:"identity_type"`
+	Scope        string   `json:"scope"`
+	GrantPaths   []string `json:"grant_paths"`
+}
+
+var csvHeader = []string{"resource_id", "resource_name", "resource_type", "identity_id", "identity_name", "identity_type", "scope", "grant_paths"}
+
+// Request creates a pending access report of the resource and all its descendants, in the `csv` or `ndjson` format.
+// The current identity must have the scope for managing the roles of the resource.
+func (s *accessReportServiceImpl) Request(ctx context.Context, currentIdentity uuid.UUID, resourceID string, format string) (*accessreportrepo.AccessReport, error) {
+	if format != accessreportrepo.FormatCSV && format != accessreportrepo.FormatNDJSON {
+		return nil, errors.NewBadParameterError("format", format).Expected(accessreportrepo.FormatCSV + " or " + accessreportrepo.FormatNDJSON)
+	}
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &accessreportrepo.AccessReport{
+		ResourceID:  resourceID,
+		RequestedBy: currentIdentity,
+		Format:      format,
+		Status:      accessreportrepo.StatusPending,
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().AccessReportRepository().Create(ctx, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"access_report_id": report.AccessReportID,
+		"resource_id":      resourceID,
+		"requested_by":     currentIdentity,
+	}, "access report requested")
+	return report, nil
+}
+
+// Load returns the access report, which only the identity who requested it can see
+func (s *accessReportServiceImpl) Load(ctx context.Context, currentIdentity uuid.UUID, reportID uuid.UUID) (*accessreportrepo.AccessReport, error) {
+	report, err := s.Repositories().AccessReportRepository().Load(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	if !uuid.Equal(report.RequestedBy, currentIdentity) {
+		return nil, errors.NewForbiddenError("only the identity who requested the access report can see it")
+	}
+	return report, nil
+}
+
+// GeneratePendingReports generates the content of the oldest pending access reports, storing it chunk by chunk as it
+// is written. A report which cannot be generated, or whose content exceeds the maximum size, is marked as failed, with
+// the reason, and the other ones are generated anyway.
+func (s *accessReportServiceImpl) GeneratePendingReports(ctx context.Context) error {
+	reports, err := s.Repositories().AccessReportRepository().FindPending(ctx, pendingReportsBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		// the chunks stored by a previous attempt which was interrupted are discarded
+		err = s.ExecuteInTransaction(func() error {
+			return s.Repositories().AccessReportRepository().DeleteChunks(ctx, report.AccessReportID)
+		})
+		if err != nil {
+			return err
+		}
+		content := &chunkWriter{
+			ctx:     ctx,
+			service: s,
+			report:  &report,
+			maxSize: s.config.GetAccessReportMaxSizeBytes(),
+		}
+		err = s.Write(ctx, content, report.ResourceID, report.Format)
+		if err == nil {
+			err = content.Close()
+		}
+		now := time.Now()
+		report.CompletedAt = &now
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"access_report_id": report.AccessReportID,
+				"err":              err,
+			}, "unable to generate the access report")
+			reason := err.Error()
+			report.Status = accessreportrepo.StatusFailed
+			report.Error = &reason
+			report.Size = 0
+			report.Chunks = 0
+		} else {
+			report.Status = accessreportrepo.StatusCompleted
+		}
+		err = s.ExecuteInTransaction(func() error {
+			if report.Status == accessreportrepo.StatusFailed {
+				err := s.Repositories().AccessReportRepository().DeleteChunks(ctx, report.AccessReportID)
+				if err != nil {
+					return err
+				}
+			}
+			return s.Repositories().AccessReportRepository().Save(ctx, &report)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteContent writes the content of a completed access report, loading one chunk at a time
+func (s *accessReportServiceImpl) WriteContent(ctx context.Context, w io.Writer, report accessreportrepo.AccessReport) error {
+	if report.Status != accessreportrepo.StatusCompleted {
+		return errors.NewDataConflictError("the access report is " + report.Status)
+	}
+	for seq := 0; seq < report.Chunks; seq++ {
+		chunk, err := s.Repositories().AccessReportRepository().LoadChunk(ctx, report.AccessReportID, seq)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, chunk.Content)
+		if err != nil {
+			return errs.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired deletes the access reports which were completed or failed before the retention period, along with
+// their content. Up to expiredReportsBatchSize reports are deleted at once.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *accessReportServiceImpl) DeleteExpired(ctx context.Context) error {
+	var expired []accessreportrepo.AccessReport
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		expired, err = s.Repositories().AccessReportRepository().FindExpired(ctx, time.Now().Add(-s.config.GetAccessReportRetention()), expiredReportsBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, report := range expired {
+			err = s.Repositories().AccessReportRepository().Delete(ctx, report.AccessReportID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		log.Info(ctx, map[string]interface{}{
+			"access_reports": len(expired),
+		}, "expired access reports deleted")
+	}
+	return nil
+}
+
+// Write writes a row for each scope granted to an identity for the resource or any of its descendants, in the `csv`
+// or `ndjson` format, as soon as it is resolved. The identities are those which are assigned a role for the resource,
+// its ancestors or its descendants, along with all their members, directly or not. The scopes include those inherited
+// through the memberships of the identity, the resource hierarchy and the role mappings, but not those which are denied
+// or granted by role assignments which are not effective. Each row lists the compact form of every path granting the
+// scope: the membership chain from the identity, then the role and the resource chain from the resource it is assigned
+// for, then the roles it is mapped to, e.g. `alice > acme-team: admin@acme > project => contributor`. The grants are
+// resolved with a single query for all the resources of each type in the tree.
+func (s *accessReportServiceImpl) Write(ctx context.Context, w io.Writer, resourceID string, format string) error {
+	var writeRow func(row accessReportRow) error
+	var flush func() error
+	switch format {
+	case accessreportrepo.FormatCSV:
+		csvWriter := csv.NewWriter(w)
+		err := csvWriter.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		writeRow = func(row accessReportRow) error {
+			return csvWriter.Write([]string{row.ResourceID, row.ResourceName, row.ResourceType, row.IdentityID, row.IdentityName,
+				row.IdentityType, row.Scope, strings.Join(row.GrantPaths, " | ")})
+		}
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	case accessreportrepo.FormatNDJSON:
+		encoder := json.NewEncoder(w)
+		writeRow = func(row accessReportRow) error {
+			return encoder.Encode(row)
+		}
+		flush = func() error {
+			return nil
+		}
+	default:
+		return errors.NewBadParameterError("format", format).Expected(accessreportrepo.FormatCSV + " or " + accessreportrepo.FormatNDJSON)
+	}
+
+	resources, err := s.loadTree(ctx, resourceID)
+	if err != nil {
+		return err
+	}
+	// the IDs of the resources of each type, in the order in which the types first appear in the tree
+	resourcesByID := make(map[string]resource.Resource, len(resources))
+	var resourceTypeIDs []uuid.UUID
+	resourceIDsByType := map[uuid.UUID][]string{}
+	for _, r := range resources {
+		resourcesByID[r.ResourceID] = r
+		if _, found := resourceIDsByType[r.ResourceTypeID]; !found {
+			resourceTypeIDs = append(resourceTypeIDs, r.ResourceTypeID)
+		}
+		resourceIDsByType[r.ResourceTypeID] = append(resourceIDsByType[r.ResourceTypeID], r.ResourceID)
+	}
+
+	// the grants of a scope to an identity for a resource are contiguous, so that each row is written once all its
+	// grant paths are read
+	var row *accessReportRow
+	for _, resourceTypeID := range resourceTypeIDs {
+		err = s.Repositories().IdentityRoleRepository().FindAccessGrants(ctx, resourceIDsByType[resourceTypeID], func(grant rolerepo.AccessGrant) error {
+			if row != nil && (row.ResourceID != grant.ResourceID || row.IdentityID != grant.IdentityID.String() || row.Scope != grant.ScopeName) {
+				err := writeRow(*row)
+				if err != nil {
+					return err
+				}
+				row = nil
+			}
+			if row == nil {
+				r := resourcesByID[grant.ResourceID]
+				row = &accessReportRow{
+					ResourceID:   r.ResourceID,
+					ResourceName: r.Name,
+					ResourceType: r.ResourceType.Name,
+					IdentityID:   grant.IdentityID.String(),
+					IdentityName: grant.IdentityName,
+					IdentityType: "user",
+					Scope:        grant.ScopeName,
+					GrantPaths:   []string{},
+				}
+				if grant.Group {
+					row.IdentityType = "group"
+				}
+			}
+			row.GrantPaths = append(row.GrantPaths, compactGrantPath(grant))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if row != nil {
+		err = writeRow(*row)
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// loadTree returns the resource followed by all its descendants, breadth first
+func (s *accessReportServiceImpl) loadTree(ctx context.Context, resourceID string) ([]resource.Resource, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	resources := []resource.Resource{*res}
+	for i := 0; i < len(resources); i++ {
+		children, err := s.Repositories().ResourceRepository().LoadChildren(ctx, resources[i].ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, children...)
+	}
+	return resources, nil
+}
+
+// compactGrantPath returns the compact form of a grant path, e.g. `alice > acme-team: admin@acme > project => contributor`
+func compactGrantPath(grant rolerepo.AccessGrant) string {
+	result := fmt.Sprintf("%s: %s@%s", strings.Join(grant.MembershipChain, " > "), grant.RoleName, strings.Join(grant.ResourceChain, " > "))
+	for _, mappedRole := range grant.MappedRoles {
+		result += " => " + mappedRole
+	}
+	return result
+}
+
+// chunkWriter stores the content of an access report in chunks of up to accessReportChunkSize bytes, each one as soon
+// as it is full, and fails once the content exceeds the maximum size
+type chunkWriter struct {
+	ctx     context.Context
+	service *accessReportServiceImpl
+	report  *accessreportrepo.AccessReport
+	maxSize int64
+	buffer  bytes.Buffer
+}
+
+// Write buffers the given content and stores the chunks which are full
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.report.Size+int64(len(p)) > w.maxSize {
+		return 0, errs.Errorf("the access report exceeds the maximum size of %d bytes", w.maxSize)
+	}
+	w.report.Size += int64(len(p))
+	w.buffer.Write(p)
+	for w.buffer.Len() > accessReportChunkSize {
+		// a chunk never ends in the middle of a UTF-8 encoded character, since it is stored as text
+		n := accessReportChunkSize
+		for n > 0 && !utf8.RuneStart(w.buffer.Bytes()[n]) {
+			n--
+		}
+		err := w.store(w.buffer.Next(n))
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close stores the last chunk, if any
+func (w *chunkWriter) Close() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	return w.store(w.buffer.Next(w.buffer.Len()))
+}
+
+func (w *chunkWriter) store(content []byte) error {
+	chunk := &accessreportrepo.AccessReportChunk{
+		AccessReportID: w.report.AccessReportID,
+		Seq:            w.report.Chunks,
+		Content:        string(content),
+	}
+	err := w.service.ExecuteInTransaction(func() error {
+		return w.service.Repositories().AccessReportRepository().CreateChunk(w.ctx, chunk)
+	})
+	if err != nil {
+		return err
+	}
+	w.report.Chunks++
+	return nil
+}
@@ -0,0 +1,216 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	accessreportrepo "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type accessReportServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestAccessReportService(t *testing.T) {
+	suite.Run(t, &accessReportServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *accessReportServiceBlackBoxTest) TestAccessReport() {
+	// given
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	member := g.CreateUser()
+	team := g.CreateTeam().AddMember(member)
+	rt := g.CreateResourceType()
+	manager := g.CreateRole(rt, "manager").AddScope("manage").AddScope("view")
+	reader := g.CreateRole(rt, "reader").AddScope("view")
+	root := g.CreateResource(rt).AddRole(admin, manager)
+	child := g.CreateResource(root, rt).AddRole(team, reader)
+	adminName := admin.Identity().Username
+	memberName := member.Identity().Username
+	rootName := root.Resource().Name
+	childName := child.Resource().Name
+
+	s.T().Run("write csv", func(t *testing.T) {
+		// when
+		var content bytes.Buffer
+		err := s.Application.AccessReportService().Write(s.Ctx, &content, root.ResourceID(), accessreportrepo.FormatCSV)
+		// then
+		require.NoError(t, err)
+		records, err := csv.NewReader(&content).ReadAll()
+		require.NoError(t, err)
+		require.NotEmpty(t, records)
+		assert.Equal(t, []string{"resource_id", "resource_name", "resource_type", "identity_id", "identity_name", "identity_type", "scope", "grant_paths"}, records[0])
+		assert.ElementsMatch(t, [][]string{
+			{root.ResourceID(), rootName, rt.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "manage", adminName + ": manager@" + rootName},
+			{root.ResourceID(), rootName, rt.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "view", adminName + ": manager@" + rootName},
+			{child.ResourceID(), childName, rt.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "manage", adminName + ": manager@" + rootName + " > " + childName},
+			{child.ResourceID(), childName, rt.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "view", adminName + ": manager@" + rootName + " > " + childName},
+			{child.ResourceID(), childName, rt.ResourceType().Name, team.TeamID().String(), team.TeamName(), "group", "view", team.TeamName() + ": reader@" + childName},
+			{child.ResourceID(), childName, rt.ResourceType().Name, member.IdentityID().String(), memberName, "user", "view", memberName + " > " + team.TeamName() + ": reader@" + childName},
+		}, records[1:])
+	})
+
+	s.T().Run("write ndjson", func(t *testing.T) {
+		// when
+		var content bytes.Buffer
+		err := s.Application.AccessReportService().Write(s.Ctx, &content, child.ResourceID(), accessreportrepo.FormatNDJSON)
+		// then
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(content.String()), "\n")
+		require.Len(t, lines, 4)
+		var memberRows []map[string]interface{}
+		for _, line := range lines {
+			var row map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &row))
+			if row["identity_id"] == member.IdentityID().String() {
+				memberRows = append(memberRows, row)
+			}
+		}
+		require.Len(t, memberRows, 1)
+		assert.Equal(t, "view", memberRows[0]["scope"])
+		assert.Equal(t, []interface{}{memberName + " > " + team.TeamName() + ": reader@" + childName}, memberRows[0]["grant_paths"])
+	})
+
+	s.T().Run("request and generate", func(t *testing.T) {
+
+		t.Run("not a manager of the resource", func(t *testing.T) {
+			// when
+			_, err := s.Application.AccessReportService().Request(s.Ctx, member.IdentityID(), root.ResourceID(), accessreportrepo.FormatCSV)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown format", func(t *testing.T) {
+			// when
+			_, err := s.Application.AccessReportService().Request(s.Ctx, admin.IdentityID(), root.ResourceID(), "xml")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			report, err := s.Application.AccessReportService().Request(s.Ctx, admin.IdentityID(), root.ResourceID(), accessreportrepo.FormatCSV)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, accessreportrepo.StatusPending, report.Status)
+			assert.Equal(t, 0, report.Chunks)
+
+			// when
+			err = s.Application.AccessReportService().GeneratePendingReports(s.Ctx)
+			// then
+			require.NoError(t, err)
+			generated, err := s.Application.AccessReportService().Load(s.Ctx, admin.IdentityID(), report.AccessReportID)
+			require.NoError(t, err)
+			assert.Equal(t, accessreportrepo.StatusCompleted, generated.Status)
+			assert.NotNil(t, generated.CompletedAt)
+			var expected bytes.Buffer
+			require.NoError(t, s.Application.AccessReportService().Write(s.Ctx, &expected, root.ResourceID(), accessreportrepo.FormatCSV))
+			var content bytes.Buffer
+			require.NoError(t, s.Application.AccessReportService().WriteContent(s.Ctx, &content, *generated))
+			assert.Equal(t, expected.String(), content.String())
+			assert.Equal(t, int64(expected.Len()), generated.Size)
+			assert.Equal(t, 1, generated.Chunks)
+
+			t.Run("not the requester", func(t *testing.T) {
+				// when
+				_, err := s.Application.AccessReportService().Load(s.Ctx, member.IdentityID(), report.AccessReportID)
+				// then
+				require.Error(t, err)
+				assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+			})
+
+			t.Run("unknown report", func(t *testing.T) {
+				// when
+				_, err := s.Application.AccessReportService().Load(s.Ctx, admin.IdentityID(), uuid.NewV4())
+				// then
+				require.Error(t, err)
+				assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+			})
+		})
+	})
+
+	s.T().Run("too large", func(t *testing.T) {
+		// given
+		s.OverrideConfig("AUTH_ACCESS_REPORT_MAX_SIZE_BYTES", "100")
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers)
+		report, err := app.AccessReportService().Request(s.Ctx, admin.IdentityID(), root.ResourceID(), accessreportrepo.FormatCSV)
+		require.NoError(t, err)
+		// when
+		err = app.AccessReportService().GeneratePendingReports(s.Ctx)
+		// then
+		require.NoError(t, err)
+		generated, err := app.AccessReportService().Load(s.Ctx, admin.IdentityID(), report.AccessReportID)
+		require.NoError(t, err)
+		assert.Equal(t, accessreportrepo.StatusFailed, generated.Status)
+		require.NotNil(t, generated.Error)
+		assert.Contains(t, *generated.Error, "maximum size of 100 bytes")
+		assert.Equal(t, 0, generated.Chunks)
+		_, err = app.AccessReportRepository().LoadChunk(s.Ctx, report.AccessReportID, 0)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("purge", func(t *testing.T) {
+		// given
+		expired, err := s.Application.AccessReportService().Request(s.Ctx, admin.IdentityID(), root.ResourceID(), accessreportrepo.FormatCSV)
+		require.NoError(t, err)
+		recent, err := s.Application.AccessReportService().Request(s.Ctx, admin.IdentityID(), root.ResourceID(), accessreportrepo.FormatNDJSON)
+		require.NoError(t, err)
+		require.NoError(t, s.Application.AccessReportService().GeneratePendingReports(s.Ctx))
+		require.NoError(t, s.DB.Exec("UPDATE access_report SET completed_at = ? WHERE access_report_id = ?",
+			time.Now().Add(-s.Configuration.GetAccessReportRetention()-time.Hour), expired.AccessReportID).Error)
+		// when
+		err = s.Application.AccessReportService().DeleteExpired(s.Ctx)
+		// then
+		require.NoError(t, err)
+		_, err = s.Application.AccessReportService().Load(s.Ctx, admin.IdentityID(), expired.AccessReportID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.AccessReportRepository().LoadChunk(s.Ctx, expired.AccessReportID, 0)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.AccessReportService().Load(s.Ctx, admin.IdentityID(), recent.AccessReportID)
+		assert.NoError(t, err)
+	})
+}
+
+func (s *accessReportServiceBlackBoxTest) TestAccessReportWithRoleMappings() {
+	// given
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	rt := g.CreateResourceType()
+	childType := g.CreateResourceType()
+	manager := g.CreateRole(rt, "manager").AddScope("manage")
+	editor := g.CreateRole(childType, "editor").AddScope("edit")
+	root := g.CreateResource(rt).AddRole(admin, manager)
+	child := g.CreateResource(root, childType)
+	g.CreateRoleMapping(root, manager, editor)
+	adminName := admin.Identity().Username
+	rootName := root.Resource().Name
+
+	// when
+	var content bytes.Buffer
+	err := s.Application.AccessReportService().Write(s.Ctx, &content, root.ResourceID(), accessreportrepo.FormatCSV)
+	// then
+	require.NoError(s.T(), err)
+	records, err := csv.NewReader(&content).ReadAll()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), [][]string{
+		{"resource_id", "resource_name", "resource_type", "identity_id", "identity_name", "identity_type", "scope", "grant_paths"},
+		{root.ResourceID(), rootName, rt.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "manage", adminName + ": manager@" + rootName},
+		{child.ResourceID(), child.Resource().Name, childType.ResourceType().Name, admin.IdentityID().String(), adminName, "user", "edit", adminName + ": manager@" + rootName + " > " + child.Resource().Name + " => editor"},
+	}, records)
+}
@@ -0,0 +1,2 @@
+// Package service provides the code which encapsulates business logic for generating the access reports
+package service
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+// AccessReportPurgeWorker the interface for the Access Report Purge Worker,
+// which takes care of deleting the access reports whose retention period is over.
+type AccessReportPurgeWorker interface {
+	Start(freq time.Duration)
+	Stop()
+}
+
+const (
+	// AccessReportPurge the name of the worker that deletes the expired access reports.
+	// Also, the name of the lock used by this worker.
+	AccessReportPurge = "access-report-purge"
+)
+
+// NewAccessReportPurgeWorker returns a new AccessReportPurgeWorker
+func NewAccessReportPurgeWorker(ctx context.Context, app application.Application) AccessReportPurgeWorker {
+	w := &accessReportPurgeWorker{
+		worker.Worker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: worker.GetLockOwner(ctx),
+			Name:  AccessReportPurge,
+		},
+	}
+	w.Do = w.purgeAccessReports
+	return w
+}
+
+type accessReportPurgeWorker struct {
+	worker.Worker
+}
+
+func (w *accessReportPurgeWorker) purgeAccessReports() {
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "starting cycle of access reports purge")
+	err := w.App.AccessReportService().DeleteExpired(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while deleting the expired access reports")
+	}
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "ending cycle of access reports purge")
+}
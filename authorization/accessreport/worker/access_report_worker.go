@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+// AccessReportWorker the interface for the Access Report Worker,
+// which takes care of generating the content of the access reports requested by the users.
+type AccessReportWorker interface {
+	Start(freq time.Duration)
+	Stop()
+}
+
+const (
+	// AccessReport the name of the worker that generates the access reports.
+	// Also, the name of the lock used by this worker.
+	AccessReport = "access-report"
+)
+
+// NewAccessReportWorker returns a new AccessReportWorker
+func NewAccessReportWorker(ctx context.Context, app application.Application) AccessReportWorker {
+	w := &accessReportWorker{
+		worker.Worker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: worker.GetLockOwner(ctx),
+			Name:  AccessReport,
+		},
+	}
+	w.Do = w.generateAccessReports
+	return w
+}
+
+type accessReportWorker struct {
+	worker.Worker
+}
+
+func (w *accessReportWorker) generateAccessReports() {
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "starting cycle of access reports generation")
+	err := w.App.AccessReportService().GeneratePendingReports(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while generating the pending access reports")
+	}
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "ending cycle of access reports generation")
+}
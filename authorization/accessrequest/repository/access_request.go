@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// The statuses of an access request
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusDenied   = "denied"
+)
+
+// AccessRequest is the request of a user for a role on a resource, which is either approved or denied by an
+// administrator of the resource
+type AccessRequest struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	AccessRequestID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:access_request_id"`
+	// The resource the role is requested for
+	ResourceID string
+	Resource   resource.Resource `gorm:"foreignkey:ResourceID;association_foreignkey:ResourceID"`
+	// The identity of the user who requested the role
+	RequesterID uuid.UUID        `sql:"type:uuid" gorm:"column:requester_id"`
+	Requester   account.Identity `gorm:"foreignkey:RequesterID;association_foreignkey:ID"`
+	// The requested role
+	RoleID uuid.UUID `sql:"type:uuid" gorm:"column:role_id"`
+	Role   role.Role `gorm:"foreignkey:RoleID;association_foreignkey:RoleID"`
+	// The message left by the user for the administrators of the resource
+	Message string
+	// The status of the request, either `pending`, `approved` or `denied`
+	Status string
+	// The identity of the administrator who approved or denied the request
+	DecidedBy *uuid.UUID `sql:"type:uuid" gorm:"column:decided_by"`
+	// The reason given by the administrator who denied the request, if any
+	Reason string
+	// The time at which the request was approved or denied
+	DecidedAt *time.Time `gorm:"column:decided_at"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m AccessRequest) TableName() string {
+	return "access_request"
+}
+
+// GormAccessRequestRepository is the implementation of the storage interface for AccessRequest.
+type GormAccessRequestRepository struct {
+	db *gorm.DB
+}
+
+// NewAccessRequestRepository creates a new storage type.
+func NewAccessRequestRepository(db *gorm.DB) AccessRequestRepository {
+	return &GormAccessRequestRepository{db: db}
+}
+
+// AccessRequestRepository represents the storage interface.
+type AccessRequestRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*AccessRequest, error)
+	Create(ctx context.Context, request *AccessRequest) error
+	Save(ctx context.Context, request *AccessRequest) error
+	// FindPending returns the pending request of the identity for the resource, or nil if there is none
+	FindPending(ctx context.Context, requesterID uuid.UUID, resourceID string) (*AccessRequest, error)
+	// ListPendingByResource returns the pending requests for the resource, oldest first
+	ListPendingByResource(ctx context.Context, resourceID string) ([]AccessRequest, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormAccessRequestRepository) TableName() string {
+	return "access_request"
+}
+
+// Load returns a single AccessRequest as a Database Model
+func (m *GormAccessRequestRepository) Load(ctx context.Context, id uuid.UUID) (*AccessRequest, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_request", "load"}, time.Now())
+	var native AccessRequest
+	err := m.db.Table(m.TableName()).Preload("Resource").Preload("Requester").Preload("Role").
+		Where("access_request_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("access_request", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormAccessRequestRepository) Create(ctx context.Context, request *AccessRequest) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_request", "create"}, time.Now())
+	if request.AccessRequestID == uuid.Nil {
+		request.AccessRequestID = uuid.NewV4()
+	}
+	err := m.db.Create(request).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id":  request.ResourceID,
+			"requester_id": request.RequesterID,
+			"err":          err,
+		}, "unable to create the access request")
+		if gormsupport.IsUniqueViolation(err, "idx_access_request_pending") {
+			return errs.WithStack(errors.NewDataConflictError("a request for this resource is already pending"))
+		}
+		if gormsupport.IsForeignKeyViolation(err, "access_request_resource_id_fkey") {
+			return errs.WithStack(errors.NewNotFoundError("resource", request.ResourceID))
+		}
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"access_request_id": request.AccessRequestID,
+	}, "Access request created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormAccessRequestRepository) Save(ctx context.Context, request *AccessRequest) error {
+	defer goa.MeasureSince([]string{"goa", "db", "access_request", "save"}, time.Now())
+
+	err := m.db.Save(request).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_request_id": request.AccessRequestID,
+			"err":               err,
+		}, "unable to update the access request")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"access_request_id": request.AccessRequestID,
+	}, "Access request saved!")
+	return nil
+}
+
+// FindPending returns the pending request of the identity for the resource, or nil if there is none
+func (m *GormAccessRequestRepository) FindPending(ctx context.Context, requesterID uuid.UUID, resourceID string) (*AccessRequest, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_request", "FindPending"}, time.Now())
+
+	var rows []AccessRequest
+	err := m.db.Table(m.TableName()).Where("requester_id = ? AND resource_id = ? AND status = ?", requesterID, resourceID, StatusPending).
+		Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
+
+// ListPendingByResource returns the pending requests for the resource, oldest first
+func (m *GormAccessRequestRepository) ListPendingByResource(ctx context.Context, resourceID string) ([]AccessRequest, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "access_request", "ListPendingByResource"}, time.Now())
+
+	var rows []AccessRequest
+	err := m.db.Table(m.TableName()).Preload("Resource").Preload("Requester").Preload("Role").
+		Where("resource_id = ? AND status = ?", resourceID, StatusPending).Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
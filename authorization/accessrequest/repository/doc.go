@@ -0,0 +1,2 @@
+// Package repository provides the wrappers for the database interactions related to the access requests.
+package repository
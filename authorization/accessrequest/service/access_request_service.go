@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	accessrequestrepo "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
+
+	"github.com/satori/go.uuid"
+)
+
+// NewAccessRequestService creates a new service to manage the access requests
+func NewAccessRequestService(context servicecontext.ServiceContext) service.AccessRequestService {
+	return &accessRequestServiceImpl{BaseService: base.NewBaseService(context)}
+}
+
+// accessRequestServiceImpl implements the AccessRequestService to manage the access requests
+type accessRequestServiceImpl struct {
+	base.BaseService
+}
+
+// Request creates a pending request of the current identity for a role on the resource, and notifies the
+// administrators of the resource, i.e. the users who have the scope for managing its roles. A user may only have a
+// single pending request for a resource.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *accessRequestServiceImpl) Request(ctx context.Context, currentIdentity uuid.UUID, resourceID string, roleName string, message string) (*accessrequestrepo.AccessRequest, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.Repositories().RoleRepository().Lookup(ctx, roleName, res.ResourceType.Name)
+	if err != nil {
+		return nil, errors.NewBadParameterErrorFromString("role", roleName, "no such role found for resource type "+res.ResourceType.Name)
+	}
+	requester, err := s.Repositories().Identities().Load(ctx, currentIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &accessrequestrepo.AccessRequest{
+		ResourceID:  resourceID,
+		RequesterID: currentIdentity,
+		RoleID:      role.RoleID,
+		Message:     message,
+		Status:      accessrequestrepo.StatusPending,
+	}
+	err = s.ExecuteInTransaction(func() error {
+		pending, err := s.Repositories().AccessRequestRepository().FindPending(ctx, currentIdentity, resourceID)
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			return errors.NewDataConflictError("a request for this resource is already pending")
+		}
+		return s.Repositories().AccessRequestRepository().Create(ctx, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	request.Resource = *res
+	request.Requester = *requester
+	request.Role = *role
+	log.Info(ctx, map[string]interface{}{
+		"access_request_id": request.AccessRequestID,
+		"resource_id":       resourceID,
+		"requester_id":      currentIdentity,
+		"role":              roleName,
+	}, "access requested")
+
+	admins, err := s.findAdmins(ctx, res)
+	if err != nil {
+		// the request was created anyway, we will just log the error
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"resource_id": resourceID,
+		}, "unable to lookup the administrators of the resource to notify of an access request")
+		return request, nil
+	}
+	var messages []notification.Message
+	for _, admin := range admins {
+		messages = append(messages, notification.NewAccessRequestedEmail(admin.String(), request.AccessRequestID.String(),
+			resourceID, res.Name, roleName, requester.Username, message))
+	}
+	if len(messages) > 0 {
+		_, err = s.Services().NotificationService().SendMessagesAsync(ctx, messages)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to notify the resource administrators of the access request")
+		}
+	}
+	return request, nil
+}
+
+// ListPending returns the pending requests for the resource, which only its administrators can see
+func (s *accessRequestServiceImpl) ListPending(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]accessrequestrepo.AccessRequest, error) {
+	_, err := s.requireManageRoles(ctx, currentIdentity, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().AccessRequestRepository().ListPendingByResource(ctx, resourceID)
+}
+
+// Approve assigns the requested role to the user who requested it with the RoleManagementService, in addition to
+// the roles the user may already have for the resource, then notifies the user.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *accessRequestServiceImpl) Approve(ctx context.Context, currentIdentity uuid.UUID, resourceID string, requestID uuid.UUID) (*accessrequestrepo.AccessRequest, error) {
+	_, request, err := s.loadPendingRequest(ctx, currentIdentity, resourceID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ExecuteInTransaction(func() error {
+		// the request is still pending at this point, which allows the assignment to a user without any role
+		err := s.Services().RoleManagementService().Assign(ctx, currentIdentity,
+			map[string][]uuid.UUID{request.Role.Name: {request.RequesterID}}, resourceID, true, nil)
+		if err != nil {
+			return err
+		}
+		return s.decide(ctx, request, currentIdentity, accessrequestrepo.StatusApproved, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyRequester(ctx, request)
+	return request, nil
+}
+
+// Deny rejects the request with an optional reason, then notifies the user who requested it
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *accessRequestServiceImpl) Deny(ctx context.Context, currentIdentity uuid.UUID, resourceID string, requestID uuid.UUID, reason string) (*accessrequestrepo.AccessRequest, error) {
+	_, request, err := s.loadPendingRequest(ctx, currentIdentity, resourceID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.decide(ctx, request, currentIdentity, accessrequestrepo.StatusDenied, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyRequester(ctx, request)
+	return request, nil
+}
+
+// requireManageRoles loads the resource and checks that the identity has the scope for managing its roles
+func (s *accessRequestServiceImpl) requireManageRoles(ctx context.Context, identityID uuid.UUID, resourceID string) (*resource.Resource, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, identityID, resourceID, authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// loadPendingRequest loads the pending request for the resource, after checking that the identity can manage the
+// roles of the resource
+func (s *accessRequestServiceImpl) loadPendingRequest(ctx context.Context, identityID uuid.UUID, resourceID string, requestID uuid.UUID) (*resource.Resource, *accessrequestrepo.AccessRequest, error) {
+	res, err := s.requireManageRoles(ctx, identityID, resourceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	request, err := s.Repositories().AccessRequestRepository().Load(ctx, requestID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.ResourceID != resourceID {
+		return nil, nil, errors.NewNotFoundError("access_request", requestID.String())
+	}
+	if request.Status != accessrequestrepo.StatusPending {
+		return nil, nil, errors.NewDataConflictError("the access request is already " + request.Status)
+	}
+	return res, request, nil
+}
+
+// decide saves the outcome of the request
+func (s *accessRequestServiceImpl) decide(ctx context.Context, request *accessrequestrepo.AccessRequest, decidedBy uuid.UUID, status string, reason string) error {
+	now := time.Now()
+	request.Status = status
+	request.DecidedBy = &decidedBy
+	request.DecidedAt = &now
+	request.Reason = reason
+	err := s.Repositories().AccessRequestRepository().Save(ctx, request)
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"access_request_id": request.AccessRequestID,
+		"decided_by":        decidedBy,
+		"status":            status,
+	}, "access request decided")
+	return nil
+}
+
+// notifyRequester notifies the user who requested the role of the outcome of the request
+func (s *accessRequestServiceImpl) notifyRequester(ctx context.Context, request *accessrequestrepo.AccessRequest) {
+	_, err := s.Services().NotificationService().SendMessagesAsync(ctx, []notification.Message{
+		notification.NewAccessRequestDecidedEmail(request.RequesterID.String(), request.ResourceID, request.Resource.Name,
+			request.Role.Name, request.Status == accessrequestrepo.StatusApproved, request.Reason),
+	})
+	if err != nil {
+		// the request was decided anyway, we will just log the error
+		log.Error(ctx, map[string]interface{}{
+			"err":               err,
+			"access_request_id": request.AccessRequestID,
+		}, "unable to notify the user of the outcome of the access request")
+	}
+}
+
+// findAdmins returns the users who are assigned a role for the resource or any of its ancestors, either directly or
+// as members of an assigned organization, team or security group, and who have the scope for managing the roles of
+// the resource
+func (s *accessRequestServiceImpl) findAdmins(ctx context.Context, res *resource.Resource) ([]uuid.UUID, error) {
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResource(ctx, res.ResourceID, true)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []account.Identity
+	for _, ir := range identityRoles {
+		if ir.Identity.IsUser() {
+			candidates = append(candidates, ir.Identity)
+			continue
+		}
+		members, err := s.Repositories().Identities().FindTransitiveMembers(ctx, ir.IdentityID)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, members...)
+	}
+	scope := authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name)
+	var admins []uuid.UUID
+	checked := make(map[uuid.UUID]bool)
+	for _, candidate := range candidates {
+		if checked[candidate.ID] || !candidate.IsUser() {
+			continue
+		}
+		checked[candidate.ID] = true
+		hasScope, err := s.Services().PermissionService().HasScope(ctx, candidate.ID, res.ResourceID, scope)
+		if err != nil {
+			return nil, err
+		}
+		if hasScope {
+			admins = append(admins, candidate.ID)
+		}
+	}
+	return admins, nil
+}
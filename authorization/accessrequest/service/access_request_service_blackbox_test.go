@@ -0,0 +1,201 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	accessrequestrepo "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type accessRequestServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	messages []notification.Message
+}
+
+func TestAccessRequestService(t *testing.T) {
+	suite.Run(t, &accessRequestServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *accessRequestServiceBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.messages = nil
+	notificationServiceMock := testservice.NewNotificationServiceMock(s.T())
+	notificationServiceMock.SendMessagesAsyncFunc = func(ctx context.Context, msgs []notification.Message, options ...rest.HTTPClientOption) (chan error, error) {
+		s.messages = append(s.messages, msgs...)
+		return nil, nil
+	}
+	s.Application = gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithNotificationService(notificationServiceMock))
+}
+
+func (s *accessRequestServiceBlackBoxTest) TestAccessRequest() {
+	// given
+	admin := s.Graph.CreateUser()
+	contributor := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(admin).AddContributor(contributor)
+	accessRequestService := s.Application.AccessRequestService()
+
+	s.T().Run("request notifies the admins", func(t *testing.T) {
+		// given
+		s.messages = nil
+		requester := s.Graph.CreateUser()
+		// when
+		request, err := accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceViewerRole, "please")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, accessrequestrepo.StatusPending, request.Status)
+		require.Len(t, s.messages, 1)
+		assert.Equal(t, "access.requested", s.messages[0].MessageType)
+		assert.Equal(t, admin.IdentityID().String(), *s.messages[0].UserID)
+		assert.Equal(t, "please", s.messages[0].Custom["message"])
+		// and a second pending request is rejected
+		_, err = accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceAdminRole, "")
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		// and only the admins can list the pending requests
+		requests, err := accessRequestService.ListPending(s.Ctx, admin.IdentityID(), space.SpaceID())
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.Equal(t, requester.IdentityID(), requests[0].RequesterID)
+		assert.Equal(t, authorization.SpaceViewerRole, requests[0].Role.Name)
+		_, err = accessRequestService.ListPending(s.Ctx, contributor.IdentityID(), space.SpaceID())
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("request notifies the members of an admin organization", func(t *testing.T) {
+		// given
+		teamMember := s.Graph.CreateUser()
+		team := s.Graph.CreateTeam().AddMember(teamMember)
+		org := s.Graph.CreateOrganization().AddMember(team)
+		otherSpace := s.Graph.CreateSpace().AddAdmin(org)
+		s.messages = nil
+		// when
+		_, err := accessRequestService.Request(s.Ctx, s.Graph.CreateUser().IdentityID(), otherSpace.SpaceID(), authorization.SpaceViewerRole, "")
+		// then
+		require.NoError(t, err)
+		var recipients []string
+		for _, msg := range s.messages {
+			assert.Equal(t, "access.requested", msg.MessageType)
+			recipients = append(recipients, *msg.UserID)
+		}
+		assert.Contains(t, recipients, teamMember.IdentityID().String())
+	})
+
+	s.T().Run("assignment without any role requires a request for the role", func(t *testing.T) {
+		// given
+		requester := s.Graph.CreateUser()
+		_, err := accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceViewerRole, "")
+		require.NoError(t, err)
+		// when
+		err = s.Application.RoleManagementService().Assign(s.Ctx, admin.IdentityID(),
+			map[string][]uuid.UUID{authorization.SpaceAdminRole: {requester.IdentityID()}}, space.SpaceID(), true, nil)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown role", func(t *testing.T) {
+		_, err := accessRequestService.Request(s.Ctx, s.Graph.CreateUser().IdentityID(), space.SpaceID(), "unknown", "")
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("approve assigns the role", func(t *testing.T) {
+		// given
+		requester := s.Graph.CreateUser()
+		request, err := accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceViewerRole, "")
+		require.NoError(t, err)
+		s.messages = nil
+		// when a user who is not an admin approves the request
+		_, err = accessRequestService.Approve(s.Ctx, contributor.IdentityID(), space.SpaceID(), request.AccessRequestID)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		// when
+		request, err = accessRequestService.Approve(s.Ctx, admin.IdentityID(), space.SpaceID(), request.AccessRequestID)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, accessrequestrepo.StatusApproved, request.Status)
+		require.NotNil(t, request.DecidedBy)
+		assert.Equal(t, admin.IdentityID(), *request.DecidedBy)
+		hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.ViewSpaceScope)
+		require.NoError(t, err)
+		assert.True(t, hasScope)
+		require.Len(t, s.messages, 1)
+		assert.Equal(t, "access.request.approved", s.messages[0].MessageType)
+		assert.Equal(t, requester.IdentityID().String(), *s.messages[0].UserID)
+		// and the request can no longer be decided
+		_, err = accessRequestService.Deny(s.Ctx, admin.IdentityID(), space.SpaceID(), request.AccessRequestID, "")
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		// and a new request can be made for another role
+		_, err = accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceContributorRole, "")
+		require.NoError(t, err)
+	})
+
+	s.T().Run("approve adds a role to a user who already has one", func(t *testing.T) {
+		// given
+		request, err := accessRequestService.Request(s.Ctx, contributor.IdentityID(), space.SpaceID(), authorization.SpaceAdminRole, "")
+		require.NoError(t, err)
+		// when
+		_, err = accessRequestService.Approve(s.Ctx, admin.IdentityID(), space.SpaceID(), request.AccessRequestID)
+		// then
+		require.NoError(t, err)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, space.SpaceID(), contributor.IdentityID())
+		require.NoError(t, err)
+		var names []string
+		for _, r := range roles {
+			names = append(names, r.Role.Name)
+		}
+		assert.ElementsMatch(t, []string{authorization.SpaceContributorRole, authorization.SpaceAdminRole}, names)
+	})
+
+	s.T().Run("deny notifies the requester", func(t *testing.T) {
+		// given
+		requester := s.Graph.CreateUser()
+		request, err := accessRequestService.Request(s.Ctx, requester.IdentityID(), space.SpaceID(), authorization.SpaceAdminRole, "")
+		require.NoError(t, err)
+		s.messages = nil
+		// when
+		request, err = accessRequestService.Deny(s.Ctx, admin.IdentityID(), space.SpaceID(), request.AccessRequestID, "not now")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, accessrequestrepo.StatusDenied, request.Status)
+		assert.Equal(t, "not now", request.Reason)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, space.SpaceID(), requester.IdentityID())
+		require.NoError(t, err)
+		assert.Empty(t, roles)
+		require.Len(t, s.messages, 1)
+		assert.Equal(t, "access.request.denied", s.messages[0].MessageType)
+		assert.Equal(t, "not now", s.messages[0].Custom["reason"])
+	})
+
+	s.T().Run("request of another resource", func(t *testing.T) {
+		// given
+		other := s.Graph.CreateSpace().AddAdmin(admin)
+		request, err := accessRequestService.Request(s.Ctx, s.Graph.CreateUser().IdentityID(), other.SpaceID(), authorization.SpaceViewerRole, "")
+		require.NoError(t, err)
+		// when
+		_, err = accessRequestService.Approve(s.Ctx, admin.IdentityID(), space.SpaceID(), request.AccessRequestID)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = accessRequestService.Approve(s.Ctx, admin.IdentityID(), space.SpaceID(), uuid.NewV4())
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
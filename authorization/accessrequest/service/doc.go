@@ -0,0 +1,2 @@
+// Package service provides the code which encapsulates business logic for requesting access to a resource
+package service
@@ -37,10 +37,12 @@ const (
 	// ResourceTypeSystem defines the string constant for the system resource type
 	ResourceTypeSystem = "openshift.io/resource/system"
 
-	manageUserScope      = "manage_user"
-	accessScope          = "access"
-	userAdminRole        = "user_admin"
-	adminConsoleUserRole = "admin_console_user"
+	manageUserScope          = "manage_user"
+	manageResourceTypesScope = "manage_resource_types"
+	accessScope              = "access"
+	userAdminRole            = "user_admin"
+	adminConsoleUserRole     = "admin_console_user"
+	resourceTypeAdminRole    = "resource_type_admin"
 
 	// SystemUserAdminRole is the constant used to denote the name of the system resource's user administrator role
 	SystemUserAdminRole = userAdminRole
@@ -54,6 +56,12 @@ const (
 	// ManageUserSystemScope is a general scope required to perform operations for managing users in a resource of type system
 	ManageUserSystemScope = manageUserScope
 
+	// ManageResourceTypesSystemScope is the scope of the system resource required to manage the roles of any resource type
+	ManageResourceTypesSystemScope = manageResourceTypesScope
+
+	// SystemResourceTypeAdminRole is the constant used to denote the name of the system resource's resource type administrator role
+	SystemResourceTypeAdminRole = resourceTypeAdminRole
+
 	// OrganizationAdminRole is the constant used to denote the name of the organization resource's administrator role
 	OrganizationAdminRole = adminRole
 
@@ -63,6 +71,9 @@ const (
 	// SpaceAdminRole is the constant used to denote the name of a space resource's administrator role
 	SpaceAdminRole = adminRole
 
+	// ResourceAdminRole is the constant used to denote the name of the administrator role of any resource type which defines one
+	ResourceAdminRole = adminRole
+
 	// SpaceContributorRole is the constant used to denote the name of the space's contributor role
 	SpaceContributorRole = contributorRole
 
@@ -121,6 +132,11 @@ const (
 	ViewSecurityGroupMembersScope = viewSecurityGroupScope
 )
 
+// MaxMembershipDepth is the maximum number of nested memberships which are followed when the identities an identity is
+// a member of, or the members of an identity, are resolved, e.g. when the permissions are computed. The configured
+// maximum length of a chain of nested memberships is capped to it.
+const MaxMembershipDepth = 32
+
 // CanHaveMembers returns a boolean indicating whether the specified resource type may have member Identities
 func CanHaveMembers(resourceTypeName string) bool {
 	return resourceTypeName == IdentityResourceTypeOrganization ||
@@ -128,6 +144,35 @@ func CanHaveMembers(resourceTypeName string) bool {
 		resourceTypeName == IdentityResourceTypeGroup
 }
 
+// CanBeMemberOf returns a boolean indicating whether an identity of the specified member resource type may be a member
+// of an identity of the specified resource type. Teams and security groups may be members of organizations and
+// security groups, while teams only have users as members.
+func CanBeMemberOf(memberResourceTypeName string, resourceTypeName string) bool {
+	if memberResourceTypeName != IdentityResourceTypeTeam && memberResourceTypeName != IdentityResourceTypeGroup {
+		return false
+	}
+	return resourceTypeName == IdentityResourceTypeOrganization || resourceTypeName == IdentityResourceTypeGroup
+}
+
+// CanBeParentOf returns a boolean indicating whether a resource of the specified parent resource type may be the parent
+// of a resource of the specified resource type. Teams belong to spaces and security groups to organizations, while
+// organizations, users and system resources are root resources. Any other resource may be the child of a resource
+// which is neither a user, a team, a security group nor a system resource.
+func CanBeParentOf(parentResourceTypeName string, resourceTypeName string) bool {
+	switch resourceTypeName {
+	case IdentityResourceTypeTeam:
+		return parentResourceTypeName == ResourceTypeSpace
+	case IdentityResourceTypeGroup:
+		return parentResourceTypeName == IdentityResourceTypeOrganization
+	case IdentityResourceTypeOrganization, IdentityResourceTypeUser, ResourceTypeSystem:
+		return false
+	}
+	return parentResourceTypeName != IdentityResourceTypeUser &&
+		parentResourceTypeName != IdentityResourceTypeTeam &&
+		parentResourceTypeName != IdentityResourceTypeGroup &&
+		parentResourceTypeName != ResourceTypeSystem
+}
+
 // ScopeForManagingRolesInResourceType returns the name of the scope that gives a user privileges to manage roles in a resource
 func ScopeForManagingRolesInResourceType(resourceType string) string {
 	switch resourceType {
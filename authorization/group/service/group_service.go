@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/satori/go.uuid"
+)
+
+// groupServiceImpl is the default implementation of GroupService. It is a private struct and should only be
+// instantiated via the NewGroupService() function.
+type groupServiceImpl struct {
+	base.BaseService
+}
+
+// NewGroupService creates a new service.
+func NewGroupService(context servicecontext.ServiceContext) service.GroupService {
+	return &groupServiceImpl{base.NewBaseService(context)}
+}
+
+// CreateGroup creates a new security group in an organization. The currentIdentity is the user creating the group,
+// who must have the scope for managing the members of the organization, and who is assigned the admin role of the new
+// group. The organizationID is the identity ID of the organization, and the groupName parameter specifies the group
+// name. The administrators of the organization also administer all its groups. The group's identity ID is returned.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) CreateGroup(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, groupName string) (*uuid.UUID, error) {
+	var groupID uuid.UUID
+
+	err := s.ExecuteInTransaction(func() error {
+		_, org, err := s.loadIdentityResource(ctx, organizationID, authorization.IdentityResourceTypeOrganization, "organizationID")
+		if err != nil {
+			return err
+		}
+
+		// Confirm that the user may manage the members of the organization
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		// Lookup the group resource type
+		resourceType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+		if err != nil {
+			return err
+		}
+
+		// Create the group resource
+		res := &resource.Resource{
+			Name:             groupName,
+			ResourceType:     *resourceType,
+			ResourceTypeID:   resourceType.ResourceTypeID,
+			ParentResourceID: &org.ResourceID,
+		}
+
+		err = s.Repositories().ResourceRepository().Create(ctx, res)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+
+		// Create the group identity
+		groupIdentity := &account.Identity{
+			IdentityResourceID: sql.NullString{
+				String: res.ResourceID,
+				Valid:  true,
+			},
+		}
+
+		err = s.Repositories().Identities().Create(ctx, groupIdentity)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+
+		groupID = groupIdentity.ID
+
+		// Assign the admin role for the new group to the current user
+		adminRole, err := s.Repositories().RoleRepository().Lookup(ctx, authorization.ResourceAdminRole, authorization.IdentityResourceTypeGroup)
+		if err != nil {
+			return errors.NewInternalErrorFromString(ctx, "Error looking up admin role for 'identity/group' resource type")
+		}
+
+		err = s.Repositories().IdentityRoleRepository().Create(ctx, &role.IdentityRole{
+			IdentityID: currentIdentity,
+			ResourceID: res.ResourceID,
+			RoleID:     adminRole.RoleID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.mapOrganizationAdminRole(ctx, org.ResourceID, adminRole.RoleID)
+		if err != nil {
+			return err
+		}
+
+		log.Debug(ctx, map[string]interface{}{
+			"group_id":        groupID.String(),
+			"organization_id": organizationID.String(),
+		}, "security group created")
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &groupID, nil
+}
+
+// mapOrganizationAdminRole makes sure that the admin role of the organization is mapped to the admin role of the
+// groups in the organization, so that the organization administrators may manage all its groups
+func (s *groupServiceImpl) mapOrganizationAdminRole(ctx context.Context, organizationResourceID string, groupAdminRoleID uuid.UUID) error {
+	orgAdminRole, err := s.Repositories().RoleRepository().Lookup(ctx, authorization.OrganizationAdminRole, authorization.IdentityResourceTypeOrganization)
+	if err != nil {
+		return errors.NewInternalErrorFromString(ctx, "Error looking up admin role for 'identity/organization' resource type")
+	}
+
+	mappings, err := s.Repositories().RoleMappingRepository().FindForResource(ctx, organizationResourceID)
+	if err != nil {
+		return err
+	}
+	for _, m := range mappings {
+		if m.FromRoleID == orgAdminRole.RoleID && m.ToRoleID == groupAdminRoleID {
+			return nil
+		}
+	}
+
+	return s.Repositories().RoleMappingRepository().Create(ctx, &role.RoleMapping{
+		ResourceID: organizationResourceID,
+		FromRoleID: orgAdminRole.RoleID,
+		ToRoleID:   groupAdminRoleID,
+	})
+}
+
+// ListGroupsInOrganization returns the security groups of an organization. The current identity must have the scope
+// for viewing the members of the organization.
+func (s *groupServiceImpl) ListGroupsInOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) ([]account.Identity, error) {
+	_, org, err := s.loadIdentityResource(ctx, organizationID, authorization.IdentityResourceTypeOrganization, "organizationID")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ViewOrganizationMembersScope)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repositories().Identities().FindIdentitiesByResourceTypeWithParentResource(ctx, resourceType.ResourceTypeID, org.ResourceID)
+}
+
+// ListMembers returns the direct members of a security group, i.e. users and other security groups. The current
+// identity must have the scope for viewing the members of the group.
+func (s *groupServiceImpl) ListMembers(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID) ([]account.Identity, error) {
+	_, group, err := s.loadIdentityResource(ctx, groupID, authorization.IdentityResourceTypeGroup, "groupID")
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, group.ResourceID, authorization.ViewSecurityGroupMembersScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repositories().Identities().FindDirectMembers(ctx, groupID)
+}
+
+// AddMember adds a user, a team or another security group to the members of a security group, unless it is already a
+// member. The current identity must have the scope for managing the members of the group.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) AddMember(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		_, group, err := s.loadIdentityResource(ctx, groupID, authorization.IdentityResourceTypeGroup, "groupID")
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, group.ResourceID, authorization.ManageSecurityGroupMembersScope)
+		if err != nil {
+			return err
+		}
+
+		if memberID == groupID {
+			return errors.NewBadParameterErrorFromString("memberID", memberID, "a security group cannot be a member of itself")
+		}
+
+		isMember, err := s.isDirectMember(ctx, groupID, memberID)
+		if err != nil || isMember {
+			return err
+		}
+
+		// the membership service checks the type of the member, and rejects cycles of nested groups and chains of
+		// nested memberships which are too long
+		return s.Services().MembershipService().AddMember(ctx, groupID, memberID)
+	})
+}
+
+// RemoveMember removes a user, a team or another security group from the members of a security group. The current
+// identity must have the scope for managing the members of the group.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) RemoveMember(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		_, group, err := s.loadIdentityResource(ctx, groupID, authorization.IdentityResourceTypeGroup, "groupID")
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, group.ResourceID, authorization.ManageSecurityGroupMembersScope)
+		if err != nil {
+			return err
+		}
+
+		isMember, err := s.isDirectMember(ctx, groupID, memberID)
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			return errors.NewNotFoundError("membership", memberID.String())
+		}
+
+		return s.Repositories().Identities().RemoveMember(ctx, groupID, memberID)
+	})
+}
+
+// AssignRole assigns a role of a resource to a security group, so that all its members, including the members of its
+// nested groups, are granted the scopes of the role. The current identity must have the scope for viewing the members
+// of the group, and the scope for managing the roles of the resource.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) AssignRole(ctx context.Context, currentIdentity uuid.UUID, groupID uuid.UUID, resourceID string, roleName string) error {
+	return s.ExecuteInTransaction(func() error {
+		_, group, err := s.loadIdentityResource(ctx, groupID, authorization.IdentityResourceTypeGroup, "groupID")
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, group.ResourceID, authorization.ViewSecurityGroupMembersScope)
+		if err != nil {
+			return err
+		}
+
+		res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name))
+		if err != nil {
+			return err
+		}
+
+		r, err := s.Repositories().RoleRepository().Lookup(ctx, roleName, res.ResourceType.Name)
+		if err != nil {
+			return errors.NewBadParameterErrorFromString("roleName", roleName, fmt.Sprintf("no such role found for resource type %s", res.ResourceType.Name))
+		}
+
+		assignedRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, resourceID, groupID)
+		if err != nil {
+			return err
+		}
+		for _, ir := range assignedRoles {
+			if ir.RoleID == r.RoleID {
+				return nil
+			}
+		}
+
+		return s.Repositories().IdentityRoleRepository().Create(ctx, &role.IdentityRole{
+			IdentityID: groupID,
+			ResourceID: resourceID,
+			RoleID:     r.RoleID,
+		})
+	})
+}
+
+// loadIdentityResource loads an identity along with its resource, making sure that the resource is of the
+// expected type
+func (s *groupServiceImpl) loadIdentityResource(ctx context.Context, identityID uuid.UUID, resourceType string, paramName string) (*account.Identity, *resource.Resource, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !identity.IdentityResourceID.Valid {
+		return nil, nil, errors.NewBadParameterErrorFromString(paramName, identityID, fmt.Sprintf("identity is not of type %s", resourceType))
+	}
+
+	res, err := s.Repositories().ResourceRepository().Load(ctx, identity.IdentityResourceID.String)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.ResourceType.Name != resourceType {
+		return nil, nil, errors.NewBadParameterErrorFromString(paramName, identityID, fmt.Sprintf("identity is not of type %s", resourceType))
+	}
+
+	return identity, res, nil
+}
+
+// isDirectMember returns true if the member is a direct member of the security group
+func (s *groupServiceImpl) isDirectMember(ctx context.Context, groupID uuid.UUID, memberID uuid.UUID) (bool, error) {
+	members, err := s.Repositories().Identities().FindDirectMembers(ctx, groupID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range members {
+		if m.ID == memberID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
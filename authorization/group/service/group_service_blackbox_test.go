@@ -0,0 +1,190 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type groupServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunGroupServiceBlackBoxTest(t *testing.T) {
+	suite.Run(t, &groupServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *groupServiceBlackBoxTest) TestSecurityGroups() {
+	// given
+	g := s.NewTestGraph(s.T())
+	creator := g.CreateUser()
+	org := g.CreateOrganization(creator)
+	orgAdmin := g.CreateUser()
+	org.AddAdmin(orgAdmin)
+	outsider := g.CreateUser()
+	user := g.CreateUser()
+	space := g.CreateSpace().AddAdmin(creator)
+	groupService := s.Application.GroupService()
+
+	var parentGroupID, childGroupID uuid.UUID
+
+	s.T().Run("create", func(t *testing.T) {
+		t.Run("ok", func(t *testing.T) {
+			// when
+			id, err := groupService.CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), "parent-group")
+			require.NoError(t, err)
+			parentGroupID = *id
+			id, err = groupService.CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), "child-group")
+			require.NoError(t, err)
+			childGroupID = *id
+			// then
+			groups, err := groupService.ListGroupsInOrganization(s.Ctx, orgAdmin.IdentityID(), org.OrganizationID())
+			require.NoError(t, err)
+			require.Len(t, groups, 2)
+			names := []string{groups[0].IdentityResource.Name, groups[1].IdentityResource.Name}
+			assert.ElementsMatch(t, []string{"parent-group", "child-group"}, names)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// when
+			_, err := groupService.CreateGroup(s.Ctx, outsider.IdentityID(), org.OrganizationID(), "other-group")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("not an organization", func(t *testing.T) {
+			// when
+			_, err := groupService.CreateGroup(s.Ctx, creator.IdentityID(), creator.IdentityID(), "other-group")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("members", func(t *testing.T) {
+		t.Run("nest group and add user", func(t *testing.T) {
+			// the organization admin manages all the groups of the organization
+			err := groupService.AddMember(s.Ctx, orgAdmin.IdentityID(), parentGroupID, childGroupID)
+			require.NoError(t, err)
+			err = groupService.AddMember(s.Ctx, creator.IdentityID(), childGroupID, user.IdentityID())
+			require.NoError(t, err)
+			// adding an existing member again has no effect
+			err = groupService.AddMember(s.Ctx, creator.IdentityID(), childGroupID, user.IdentityID())
+			require.NoError(t, err)
+			// then
+			members, err := groupService.ListMembers(s.Ctx, creator.IdentityID(), parentGroupID)
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, childGroupID, members[0].ID)
+			assert.Equal(t, "child-group", members[0].IdentityResource.Name)
+			members, err = groupService.ListMembers(s.Ctx, creator.IdentityID(), childGroupID)
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, user.IdentityID(), members[0].ID)
+		})
+
+		t.Run("group cannot be a member of itself", func(t *testing.T) {
+			// when
+			err := groupService.AddMember(s.Ctx, creator.IdentityID(), parentGroupID, parentGroupID)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("groups cannot be nested in a cycle", func(t *testing.T) {
+			// given
+			id, err := groupService.CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), "grandchild-group")
+			require.NoError(t, err)
+			grandchildGroupID := *id
+			err = groupService.AddMember(s.Ctx, creator.IdentityID(), childGroupID, grandchildGroupID)
+			require.NoError(t, err)
+			// when
+			err = groupService.AddMember(s.Ctx, creator.IdentityID(), childGroupID, parentGroupID)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			assert.Contains(t, err.Error(), "the membership would create a cycle of memberships")
+			// when
+			err = groupService.AddMember(s.Ctx, creator.IdentityID(), grandchildGroupID, parentGroupID)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			members, err := groupService.ListMembers(s.Ctx, creator.IdentityID(), grandchildGroupID)
+			require.NoError(t, err)
+			assert.Empty(t, members)
+			err = groupService.RemoveMember(s.Ctx, creator.IdentityID(), childGroupID, grandchildGroupID)
+			require.NoError(t, err)
+		})
+
+		t.Run("organization cannot be a member", func(t *testing.T) {
+			// when
+			err := groupService.AddMember(s.Ctx, creator.IdentityID(), parentGroupID, org.OrganizationID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// when
+			err := groupService.AddMember(s.Ctx, outsider.IdentityID(), parentGroupID, outsider.IdentityID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+			_, err = groupService.ListMembers(s.Ctx, outsider.IdentityID(), parentGroupID)
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("assign role", func(t *testing.T) {
+		t.Run("forbidden without the scope for managing the resource roles", func(t *testing.T) {
+			// when
+			err := groupService.AssignRole(s.Ctx, orgAdmin.IdentityID(), parentGroupID, space.SpaceID(), authorization.SpaceContributorRole)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown role", func(t *testing.T) {
+			// when
+			err := groupService.AssignRole(s.Ctx, creator.IdentityID(), parentGroupID, space.SpaceID(), "unknown")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			err := groupService.AssignRole(s.Ctx, creator.IdentityID(), parentGroupID, space.SpaceID(), authorization.SpaceContributorRole)
+			// then
+			require.NoError(t, err)
+			// the members of the nested group are granted the scopes of the role
+			hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, user.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+			require.NoError(t, err)
+			assert.True(t, hasScope)
+		})
+	})
+
+	s.T().Run("remove member", func(t *testing.T) {
+		// when
+		err := groupService.RemoveMember(s.Ctx, creator.IdentityID(), childGroupID, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, user.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+		require.NoError(t, err)
+		assert.False(t, hasScope)
+		// removing it again fails
+		err = groupService.RemoveMember(s.Ctx, creator.IdentityID(), childGroupID, user.IdentityID())
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
@@ -0,0 +1,121 @@
+package invitation
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+)
+
+// The modes of a bulk invitation import
+const (
+	// ImportModeAllOrNothing issues the invitations only if every row is valid, and rolls them all back if any
+	// of them cannot be issued
+	ImportModeAllOrNothing = "all-or-nothing"
+	// ImportModeBestEffort issues the invitations of the valid rows, regardless of the other rows
+	ImportModeBestEffort = "best-effort"
+)
+
+// The statuses of a row of a bulk invitation import
+const (
+	// ImportRowIssued the invitation of the row was issued
+	ImportRowIssued = "issued"
+	// ImportRowInvalid the row is not valid, e.g. an unknown user, target or role
+	ImportRowInvalid = "invalid"
+	// ImportRowFailed the row is valid but its invitation could not be issued
+	ImportRowFailed = "failed"
+	// ImportRowSkipped the row is valid but its invitation was not issued because of another row
+	ImportRowSkipped = "skipped"
+)
+
+// ImportRow is a row of a bulk invitation import. The invited user is identified either by username or by email
+// address, and the target is the organization, team or security group (the Identity ID) or resource (the Resource ID)
+// for which the invitation is issued.
+type ImportRow struct {
+	Username string   `json:"username,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Target   string   `json:"target"`
+	Roles    []string `json:"roles,omitempty"`
+	Member   bool     `json:"member,omitempty"`
+}
+
+// ImportRowResult is the result of a row of a bulk invitation import. Rows are numbered from 1.
+type ImportRowResult struct {
+	Row      int    `json:"row"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Target   string `json:"target"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// csvImportColumns are the columns of a bulk invitation import in the CSV format
+var csvImportColumns = map[string]bool{"username": true, "email": true, "target": true, "roles": true, "member": true}
+
+// ParseImportCSV parses the rows of a bulk invitation import in the CSV format. The first line is a header with the
+// `username`, `email`, `target`, `roles` and `member` columns, in any order, where only `target` and one of `username`
+// or `email` are mandatory. Multiple roles are separated with a semicolon.
+func ParseImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.NewBadParameterErrorFromString("csv", "", "missing header")
+	}
+	if err != nil {
+		return nil, errors.NewBadParameterErrorFromString("csv", "", err.Error())
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !csvImportColumns[name] {
+			return nil, errors.NewBadParameterErrorFromString("csv", name, "unknown column")
+		}
+		columns[name] = i
+	}
+	if _, found := columns["target"]; !found {
+		return nil, errors.NewBadParameterErrorFromString("csv", "target", "missing column")
+	}
+	_, hasUsername := columns["username"]
+	_, hasEmail := columns["email"]
+	if !hasUsername && !hasEmail {
+		return nil, errors.NewBadParameterErrorFromString("csv", "username", "missing username or email column")
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.NewBadParameterErrorFromString("csv", "", err.Error())
+		}
+		value := func(column string) string {
+			if i, found := columns[column]; found {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := ImportRow{
+			Username: value("username"),
+			Email:    value("email"),
+			Target:   value("target"),
+		}
+		for _, role := range strings.Split(value("roles"), ";") {
+			if role = strings.TrimSpace(role); role != "" {
+				row.Roles = append(row.Roles, role)
+			}
+		}
+		if member := value("member"); member != "" {
+			row.Member, err = strconv.ParseBool(member)
+			if err != nil {
+				return nil, errors.NewBadParameterErrorFromString("member", member, "invalid boolean value")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
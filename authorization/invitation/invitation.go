@@ -1,14 +1,40 @@
 package invitation
 
 import (
+	"time"
+
 	"github.com/satori/go.uuid"
 )
 
 // Invitation is a DTO used to pass state between the controller and service layers when issuing new invitations
 type Invitation struct {
-	IdentityID        *uuid.UUID
+	IdentityID *uuid.UUID
+	// Email is the address of the invited person, used if IdentityID is not set. The invitation is addressed to the
+	// existing user with this verified email address if any, or else sent to the address with a sign-up link.
+	Email             string
 	Roles             []string
 	Member            bool
 	RedirectOnSuccess string
 	RedirectOnFailure string
+	// AcceptOnRegistration is true if the invitation addressed to an email address must be accepted as soon as the
+	// invited person has signed up
+	AcceptOnRegistration bool
+}
+
+// PendingInvitation is a DTO used to return the invitations which have been issued but not accepted yet
+type PendingInvitation struct {
+	InvitationID uuid.UUID
+	// InviteTo is the identity of the organization, team or security group the invitation is for, if any
+	InviteTo *uuid.UUID
+	// ResourceID is the resource the invitation is for, if any
+	ResourceID *string
+	// Name is the name of the organization, team, security group or resource the invitation is for
+	Name       string
+	IdentityID *uuid.UUID
+	Username   string
+	Email      string
+	Member     bool
+	Roles      []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
 }
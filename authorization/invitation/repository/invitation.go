@@ -29,8 +29,16 @@ type Invitation struct {
 	// or, the Resource ID to which the user is being invited to accept a role
 	ResourceID *string `sql:"type:string" gorm:"column:resource_id"`
 
+	// The invited user identity, which is only set once the invited person has signed up if the invitation
+	// was addressed to an email address
 	Identity   account.Identity `gorm:"ForeignKey:IdentityID;AssociationForeignKey:ID"`
-	IdentityID uuid.UUID
+	IdentityID *uuid.UUID       `sql:"type:uuid" gorm:"column:identity_id"`
+
+	// Email is the address to which the invitation was sent, if the invited person had no account yet
+	Email string `sql:"type:string" gorm:"column:email"`
+
+	// AcceptOnRegistration is true if the invitation must be accepted as soon as the invited person has signed up
+	AcceptOnRegistration bool `gorm:"column:accept_on_registration"`
 
 	// AcceptCode is the code sent in the invitation e-mail to the user, used to accept the invitation
 	AcceptCode uuid.UUID `sql:"type:uuid" gorm:"column:accept_code"`
@@ -40,12 +48,23 @@ type Invitation struct {
 	// url's to redirect after accepting invitation in case of success or failure
 	SuccessRedirectURL string `sql:"type:string" gorm:"column:success_redirect_url"`
 	FailureRedirectURL string `sql:"type:string" gorm:"column:failure_redirect_url"`
+
+	// InviterID is the identity of the user who issued the invitation
+	InviterID *uuid.UUID `sql:"type:uuid" gorm:"column:inviter_id"`
+
+	// ExpiresAt is the time after which the invitation can no longer be accepted
+	ExpiresAt *time.Time `gorm:"column:expires_at"`
 }
 
 func (m Invitation) TableName() string {
 	return "invitation"
 }
 
+// IsExpired returns true if the invitation can no longer be accepted
+func (m Invitation) IsExpired() bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(time.Now())
+}
+
 // GetLastModified returns the last modification time
 func (m Invitation) GetLastModified() time.Time {
 	return m.UpdatedAt
@@ -81,12 +100,15 @@ type InvitationRepository interface {
 	Save(ctx context.Context, i *Invitation) error
 	ListForIdentity(ctx context.Context, inviteToID uuid.UUID) ([]Invitation, error)
 	ListForResource(ctx context.Context, resourceID string) ([]Invitation, error)
+	ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]Invitation, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 
 	ListRoles(ctx context.Context, id uuid.UUID) ([]rolerepo.Role, error)
 	AddRole(ctx context.Context, invitationId uuid.UUID, roleId uuid.UUID) error
 
 	FindByAcceptCode(ctx context.Context, acceptCode uuid.UUID) (*Invitation, error)
+	FindExpired(ctx context.Context, limit int) ([]Invitation, error)
+	FindUnclaimedByEmail(ctx context.Context, email string) ([]Invitation, error)
 }
 
 func (m *GormInvitationRepository) TableName() string {
@@ -191,6 +213,18 @@ func (m *GormInvitationRepository) ListForResource(ctx context.Context, resource
 	return rows, nil
 }
 
+// ListForInvitee returns the invitations addressed to the specified user identity
+func (m *GormInvitationRepository) ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]Invitation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation", "listForInvitee"}, time.Now())
+	var rows []Invitation
+
+	err := m.db.Model(&Invitation{}).Where("identity_id = ?", identityID).Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
 func (m *GormInvitationRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "invitation", "delete"}, time.Now())
 
@@ -289,3 +323,30 @@ func (m *GormInvitationRepository) FindByAcceptCode(ctx context.Context, acceptC
 	}
 	return &native, errs.WithStack(err)
 }
+
+// FindExpired returns up to `limit` invitations which can no longer be accepted
+func (m *GormInvitationRepository) FindExpired(ctx context.Context, limit int) ([]Invitation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation", "FindExpired"}, time.Now())
+
+	var rows []Invitation
+	err := m.db.Table(m.TableName()).Where("expires_at IS NOT NULL AND expires_at <= now()").
+		Order("expires_at").Limit(limit).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// FindUnclaimedByEmail returns the invitations addressed to the specified email address which have not been attached
+// to an identity yet
+func (m *GormInvitationRepository) FindUnclaimedByEmail(ctx context.Context, email string) ([]Invitation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation", "FindUnclaimedByEmail"}, time.Now())
+
+	var rows []Invitation
+	err := m.db.Table(m.TableName()).Where("identity_id IS NULL AND lower(email) = lower(?)", email).
+		Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
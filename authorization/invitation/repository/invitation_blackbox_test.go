@@ -231,7 +231,7 @@ func (s *invitationBlackBoxTest) CreateTestInvitation() (invitationRepo.Invitati
 
 	invitation = invitationRepo.Invitation{
 		InviteTo:   &orgIdentity.ID,
-		IdentityID: userIdentity.ID,
+		IdentityID: &userIdentity.ID,
 		Member:     false,
 	}
 
@@ -255,7 +255,7 @@ func (s *invitationBlackBoxTest) CreateTestResourceInvitation() (invitationRepo.
 
 	invitation = invitationRepo.Invitation{
 		ResourceID: &resource.ResourceID,
-		IdentityID: userIdentity.ID,
+		IdentityID: &userIdentity.ID,
 		Member:     false,
 	}
 
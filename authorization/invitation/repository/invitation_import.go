@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// The statuses of a bulk invitation import
+const (
+	ImportStatusPending    = "pending"
+	ImportStatusProcessing = "processing"
+	ImportStatusCompleted  = "completed"
+	ImportStatusFailed     = "failed"
+)
+
+// InvitationImport is a bulk import of invitations. Small imports are processed immediately, while large ones are
+// stored as pending and processed later by a worker.
+type InvitationImport struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	InvitationImportID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:invitation_import_id"`
+	// The identity which requested the import
+	RequestedBy uuid.UUID `sql:"type:uuid" gorm:"column:requested_by"`
+	// The mode of the import, either `all-or-nothing` or `best-effort`
+	Mode string
+	// The status of the import, either `pending`, `processing`, `completed` or `failed`
+	Status string
+	// The rows to import, as a JSON array
+	Rows string
+	// The result of each row, as a JSON array, once the import is processed
+	Report *string
+	// The reason why the import failed, if it did
+	Error *string
+	// The URL to redirect to after an invitation is accepted
+	SuccessRedirectURL string `sql:"type:string" gorm:"column:success_redirect_url"`
+	// The URL to redirect to if an invitation could not be accepted
+	FailureRedirectURL string `sql:"type:string" gorm:"column:failure_redirect_url"`
+	// The time at which the import was completed or failed
+	CompletedAt *time.Time `gorm:"column:completed_at"`
+	// The invitation e-mails which are still to be sent, as a JSON array
+	PendingEmails *string `gorm:"column:pending_emails"`
+	// The time at which the last batch of invitation e-mails was sent
+	EmailsSentAt *time.Time `gorm:"column:emails_sent_at"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m InvitationImport) TableName() string {
+	return "invitation_import"
+}
+
+// GormInvitationImportRepository is the implementation of the storage interface for InvitationImport.
+type GormInvitationImportRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationImportRepository creates a new storage type.
+func NewInvitationImportRepository(db *gorm.DB) InvitationImportRepository {
+	return &GormInvitationImportRepository{db: db}
+}
+
+// InvitationImportRepository represents the storage interface.
+type InvitationImportRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*InvitationImport, error)
+	Create(ctx context.Context, imp *InvitationImport) error
+	Save(ctx context.Context, imp *InvitationImport) error
+	// ClaimPending marks the oldest imports which are still to be processed as being processed, up to the given
+	// limit, and returns them
+	ClaimPending(ctx context.Context, limit int) ([]InvitationImport, error)
+	// LockPendingEmails locks and returns the oldest imports with invitation e-mails still to be sent, and whose last
+	// batch of e-mails was not sent after the given time, up to the given limit
+	LockPendingEmails(ctx context.Context, sentBefore time.Time, limit int) ([]InvitationImport, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormInvitationImportRepository) TableName() string {
+	return "invitation_import"
+}
+
+// Load returns a single InvitationImport as a Database Model
+func (m *GormInvitationImportRepository) Load(ctx context.Context, id uuid.UUID) (*InvitationImport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation_import", "load"}, time.Now())
+	var native InvitationImport
+	err := m.db.Table(m.TableName()).Where("invitation_import_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("invitation_import", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormInvitationImportRepository) Create(ctx context.Context, imp *InvitationImport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation_import", "create"}, time.Now())
+	if imp.InvitationImportID == uuid.Nil {
+		imp.InvitationImportID = uuid.NewV4()
+	}
+	err := m.db.Create(imp).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"requested_by": imp.RequestedBy,
+			"err":          err,
+		}, "unable to create the invitation import")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"invitation_import_id": imp.InvitationImportID,
+	}, "Invitation import created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormInvitationImportRepository) Save(ctx context.Context, imp *InvitationImport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation_import", "save"}, time.Now())
+
+	err := m.db.Save(imp).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"invitation_import_id": imp.InvitationImportID,
+			"err":                  err,
+		}, "unable to update the invitation import")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"invitation_import_id": imp.InvitationImportID,
+	}, "Invitation import saved!")
+	return nil
+}
+
+// ClaimPending marks the oldest imports which are still to be processed as being processed, up to the given limit,
+// and returns them. The imports are claimed with a single statement which skips the rows locked by another claim,
+// so that an import is never processed by the workers of two replicas.
+func (m *GormInvitationImportRepository) ClaimPending(ctx context.Context, limit int) ([]InvitationImport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation_import", "ClaimPending"}, time.Now())
+
+	var rows []InvitationImport
+	err := m.db.Raw(`UPDATE invitation_import SET status = ?, updated_at = ?
+WHERE invitation_import_id IN (
+  SELECT invitation_import_id FROM invitation_import
+  WHERE status = ? AND deleted_at IS NULL
+  ORDER BY created_at
+  LIMIT ?
+  FOR UPDATE SKIP LOCKED)
+RETURNING *`, ImportStatusProcessing, time.Now(), ImportStatusPending, limit).Scan(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// LockPendingEmails locks and returns the oldest imports with invitation e-mails still to be sent, and whose last
+// batch of e-mails was not sent after the given time, up to the given limit. The rows locked by another transaction
+// are skipped, so that the e-mails of an import are never sent by the workers of two replicas. This method must be
+// called within a transaction, which holds the locks until the imports are saved.
+func (m *GormInvitationImportRepository) LockPendingEmails(ctx context.Context, sentBefore time.Time, limit int) ([]InvitationImport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation_import", "LockPendingEmails"}, time.Now())
+
+	var rows []InvitationImport
+	err := m.db.Raw(`SELECT * FROM invitation_import
+WHERE pending_emails IS NOT NULL AND deleted_at IS NULL
+  AND (emails_sent_at IS NULL OR emails_sent_at <= ?)
+ORDER BY created_at
+LIMIT ?
+FOR UPDATE SKIP LOCKED`, sentBefore, limit).Scan(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
+	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	errs "github.com/pkg/errors"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// pendingImportsBatchSize is the maximum number of bulk invitation imports processed at each cycle of the worker
+const pendingImportsBatchSize = 5
+
+// importTarget is the organization, team, security group or resource which the rows of an import are invited to
+type importTarget struct {
+	resourceTypeName string
+	isResource       bool
+	err              error
+}
+
+// Import issues the invitations of a bulk import, after validating every row. In the `all-or-nothing` mode, no
+// invitation is issued if any row is invalid or cannot be issued, while in the `best-effort` mode the invitations of
+// the other rows are issued anyway. Imports with more rows than the configured limit are stored as pending and
+// processed later by the invitation import worker, otherwise they are stored as being processed, so that no worker
+// picks them up, and processed immediately. In both cases the result of each row is reported in the returned import.
+// The invitation e-mails are not sent here but queued on the import, and sent in batches by the invitation import
+// worker.
+func (s *invitationServiceImpl) Import(ctx context.Context, currentIdentityID uuid.UUID, rows []invitation.ImportRow, mode string,
+	redirectOnSuccess, redirectOnFailure string) (*invitationrepo.InvitationImport, error) {
+	if mode != invitation.ImportModeAllOrNothing && mode != invitation.ImportModeBestEffort {
+		return nil, errors.NewBadParameterError("mode", mode).Expected(invitation.ImportModeAllOrNothing + " or " + invitation.ImportModeBestEffort)
+	}
+	if len(rows) == 0 {
+		return nil, errors.NewBadParameterErrorFromString("rows", "", "no rows to import")
+	}
+	content, err := json.Marshal(rows)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+
+	immediate := len(rows) <= s.config.GetInvitationImportSyncLimit()
+	status := invitationrepo.ImportStatusPending
+	if immediate {
+		status = invitationrepo.ImportStatusProcessing
+	}
+	imp := &invitationrepo.InvitationImport{
+		RequestedBy:        currentIdentityID,
+		Mode:               mode,
+		Status:             status,
+		Rows:               string(content),
+		SuccessRedirectURL: redirectOnSuccess,
+		FailureRedirectURL: redirectOnFailure,
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().InvitationImportRepository().Create(ctx, imp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"invitation_import_id": imp.InvitationImportID,
+		"requested_by":         currentIdentityID,
+		"rows":                 len(rows),
+		"mode":                 mode,
+	}, "invitation import requested")
+
+	if !immediate {
+		return imp, nil
+	}
+	err = s.processImport(ctx, imp)
+	if err != nil {
+		// the import is not left as being processed forever
+		if failErr := s.failImport(ctx, imp, err); failErr != nil {
+			log.Error(ctx, map[string]interface{}{
+				"invitation_import_id": imp.InvitationImportID,
+				"err":                  failErr,
+			}, "unable to mark the invitation import as failed")
+		}
+		return nil, err
+	}
+	return imp, nil
+}
+
+// LoadImport returns the bulk invitation import, which only the identity who requested it can see
+func (s *invitationServiceImpl) LoadImport(ctx context.Context, currentIdentityID, importID uuid.UUID) (*invitationrepo.InvitationImport, error) {
+	imp, err := s.Repositories().InvitationImportRepository().Load(ctx, importID)
+	if err != nil {
+		return nil, err
+	}
+	if !uuid.Equal(imp.RequestedBy, currentIdentityID) {
+		return nil, errors.NewForbiddenError("only the identity who requested the invitation import can see it")
+	}
+	return imp, nil
+}
+
+// ProcessPendingImports claims and processes the oldest pending bulk invitation imports. An import which cannot be
+// processed is marked as failed, with the reason, and the other ones are processed anyway.
+func (s *invitationServiceImpl) ProcessPendingImports(ctx context.Context) error {
+	var imports []invitationrepo.InvitationImport
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		imports, err = s.Repositories().InvitationImportRepository().ClaimPending(ctx, pendingImportsBatchSize)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, imp := range imports {
+		err := s.processImport(ctx, &imp)
+		if err == nil {
+			continue
+		}
+		err = s.failImport(ctx, &imp, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failImport marks the import which could not be processed as failed, with the reason
+func (s *invitationServiceImpl) failImport(ctx context.Context, imp *invitationrepo.InvitationImport, cause error) error {
+	log.Error(ctx, map[string]interface{}{
+		"invitation_import_id": imp.InvitationImportID,
+		"err":                  cause,
+	}, "unable to process the invitation import")
+	reason := cause.Error()
+	now := time.Now()
+	imp.Status = invitationrepo.ImportStatusFailed
+	imp.Error = &reason
+	imp.CompletedAt = &now
+	return s.ExecuteInTransaction(func() error {
+		return s.Repositories().InvitationImportRepository().Save(ctx, imp)
+	})
+}
+
+// processImport validates every row of the import, then issues the invitations according to the mode of the import,
+// and saves the result of each row along with the invitation e-mails to send
+func (s *invitationServiceImpl) processImport(ctx context.Context, imp *invitationrepo.InvitationImport) error {
+	var rows []invitation.ImportRow
+	err := json.Unmarshal([]byte(imp.Rows), &rows)
+	if err != nil {
+		return errs.Wrap(err, "unable to read the rows of the invitation import")
+	}
+
+	results := make([]invitation.ImportRowResult, len(rows))
+	invitations := make([]*invitation.Invitation, len(rows))
+	targets := map[string]*importTarget{}
+	invalid := 0
+	for i, row := range rows {
+		results[i] = invitation.ImportRowResult{
+			Row:      i + 1,
+			Username: row.Username,
+			Email:    row.Email,
+			Target:   row.Target,
+		}
+		invitations[i], err = s.validateImportRow(ctx, imp, row, targets)
+		if err != nil {
+			results[i].Status = invitation.ImportRowInvalid
+			results[i].Error = err.Error()
+			invalid++
+		}
+	}
+
+	var messages []notification.Message
+	var reason string
+	if imp.Mode == invitation.ImportModeAllOrNothing {
+		failedRow := -1
+		if invalid > 0 {
+			reason = fmt.Sprintf("%d of %d rows are invalid", invalid, len(rows))
+		} else {
+			err = s.ExecuteInTransaction(func() error {
+				for i, inv := range invitations {
+					msgs, err := s.issue(ctx, imp.RequestedBy, rows[i].Target, []invitation.Invitation{*inv})
+					if err != nil {
+						failedRow = i
+						return err
+					}
+					messages = append(messages, msgs...)
+				}
+				return nil
+			})
+			if err != nil {
+				messages = nil
+				reason = errs.Cause(err).Error()
+				if failedRow >= 0 {
+					results[failedRow].Status = invitation.ImportRowFailed
+					results[failedRow].Error = reason
+					reason = fmt.Sprintf("row %d could not be issued: %s", failedRow+1, reason)
+				}
+			}
+		}
+		for i := range results {
+			if results[i].Status != "" {
+				continue
+			}
+			if reason != "" {
+				results[i].Status = invitation.ImportRowSkipped
+			} else {
+				results[i].Status = invitation.ImportRowIssued
+			}
+		}
+	} else {
+		for i, inv := range invitations {
+			if inv == nil {
+				continue
+			}
+			msgs, err := s.issue(ctx, imp.RequestedBy, rows[i].Target, []invitation.Invitation{*inv})
+			if err != nil {
+				results[i].Status = invitation.ImportRowFailed
+				results[i].Error = errs.Cause(err).Error()
+				continue
+			}
+			results[i].Status = invitation.ImportRowIssued
+			messages = append(messages, msgs...)
+		}
+	}
+
+	report, err := json.Marshal(results)
+	if err != nil {
+		return errs.Wrap(err, "unable to write the report of the invitation import")
+	}
+	content := string(report)
+	now := time.Now()
+	imp.Report = &content
+	imp.CompletedAt = &now
+	imp.Status = invitationrepo.ImportStatusCompleted
+	if reason != "" {
+		imp.Status = invitationrepo.ImportStatusFailed
+		imp.Error = &reason
+	}
+	if len(messages) > 0 {
+		emails, err := json.Marshal(messages)
+		if err != nil {
+			return errs.Wrap(err, "unable to queue the invitation e-mails of the invitation import")
+		}
+		pending := string(emails)
+		imp.PendingEmails = &pending
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().InvitationImportRepository().Save(ctx, imp)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"invitation_import_id": imp.InvitationImportID,
+		"status":               imp.Status,
+		"invitations":          len(messages),
+	}, "invitation import processed")
+	return nil
+}
+
+// validateImportRow checks that the invited user and the target of the row exist, that the identity who requested
+// the import can manage the invitations of the target, and that the roles are valid for the target, then returns the
+// invitation to issue. The validated targets are cached in the targets map.
+func (s *invitationServiceImpl) validateImportRow(ctx context.Context, imp *invitationrepo.InvitationImport, row invitation.ImportRow,
+	targets map[string]*importTarget) (*invitation.Invitation, error) {
+	if row.Target == "" {
+		return nil, errors.NewBadParameterErrorFromString("target", "", "no target provided")
+	}
+	target, found := targets[row.Target]
+	if !found {
+		target = s.validateImportTarget(ctx, imp.RequestedBy, row.Target)
+		targets[row.Target] = target
+	}
+	if target.err != nil {
+		return nil, target.err
+	}
+
+	inv := &invitation.Invitation{
+		Roles:             row.Roles,
+		Member:            row.Member,
+		RedirectOnSuccess: imp.SuccessRedirectURL,
+		RedirectOnFailure: imp.FailureRedirectURL,
+	}
+	if row.Username != "" {
+		identities, err := s.Repositories().Identities().Query(account.IdentityFilterByUsername(row.Username),
+			account.IdentityFilterByProviderType(account.DefaultIDP))
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		if len(identities) == 0 {
+			return nil, errors.NewNotFoundError("user", row.Username)
+		}
+		inv.IdentityID = &identities[0].ID
+	} else if row.Email != "" {
+		valid, err := rest.ValidateEmail(row.Email)
+		if err != nil || !valid {
+			return nil, errors.NewBadParameterErrorFromString("email", row.Email, "invalid email address")
+		}
+		inv.Email = row.Email
+	} else {
+		return nil, errors.NewBadParameterErrorFromString("username", "", "no username or email address provided")
+	}
+
+	if row.Member && target.isResource {
+		return nil, errors.NewBadParameterErrorFromString("member", row.Member, "can not invite members to a resource")
+	}
+	for _, roleName := range row.Roles {
+		_, err := s.Repositories().RoleRepository().Lookup(ctx, roleName, target.resourceTypeName)
+		if err != nil {
+			return nil, errors.NewBadParameterErrorFromString("roles", roleName, fmt.Sprintf("no such role found for resource type %s", target.resourceTypeName))
+		}
+	}
+	return inv, nil
+}
+
+// validateImportTarget checks that the target is an organization or a team (the Identity ID) or a space (the Resource
+// ID) for which the identity can manage the invitations
+func (s *invitationServiceImpl) validateImportTarget(ctx context.Context, identityID uuid.UUID, target string) *importTarget {
+	result := &importTarget{}
+	var resourceID string
+	if targetID, err := uuid.FromString(target); err == nil {
+		if identity, err := s.Repositories().Identities().Load(ctx, targetID); err == nil {
+			if !identity.IdentityResourceID.Valid {
+				result.err = errors.NewBadParameterErrorFromString("target", target, "specified identity has no resource")
+				return result
+			}
+			resourceID = identity.IdentityResourceID.String
+		}
+	}
+	if resourceID == "" {
+		resourceID = target
+		result.isResource = true
+	}
+
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		result.err = errors.NewNotFoundError(fmt.Sprintf("invalid identifier '%s' provided for organization, team, security group or resource", target), target)
+		return result
+	}
+	result.resourceTypeName = res.ResourceType.Name
+	if !result.isResource && result.resourceTypeName != authorization.IdentityResourceTypeTeam &&
+		result.resourceTypeName != authorization.IdentityResourceTypeOrganization {
+		result.err = errors.NewBadParameterErrorFromString("target", target, "Invitation is not for a team or organization identity")
+		return result
+	}
+	if result.isResource && result.resourceTypeName != authorization.ResourceTypeSpace {
+		result.err = errors.NewBadParameterErrorFromString("target", target, "Invitation is not for a space")
+		return result
+	}
+	result.err = s.Services().PermissionService().RequireScope(ctx, identityID, res.ResourceID, authorization.ScopeForManagingRolesInResourceType(result.resourceTypeName))
+	return result
+}
+
+// SendPendingEmails sends the next batch of the invitation e-mails queued on the oldest imports, for the imports whose
+// last batch was sent at least the configured delay ago, so that the notification service is not flooded by large
+// imports. The remaining e-mails are kept on the import for the next cycles of the worker.
+func (s *invitationServiceImpl) SendPendingEmails(ctx context.Context) error {
+	return s.ExecuteInTransaction(func() error {
+		imports, err := s.Repositories().InvitationImportRepository().LockPendingEmails(ctx,
+			time.Now().Add(-s.config.GetInvitationImportEmailBatchDelay()), pendingImportsBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, imp := range imports {
+			var messages []notification.Message
+			err := json.Unmarshal([]byte(*imp.PendingEmails), &messages)
+			if err != nil {
+				return errs.Wrap(err, "unable to read the pending invitation e-mails of the invitation import")
+			}
+			batchSize := s.config.GetInvitationImportEmailBatchSize()
+			if batchSize <= 0 || batchSize > len(messages) {
+				batchSize = len(messages)
+			}
+			_, err = s.Services().NotificationService().SendMessagesAsync(ctx, messages[:batchSize])
+			if err != nil {
+				// We will just log the error and send the next batches
+				log.Error(ctx, map[string]interface{}{
+					"invitation_import_id": imp.InvitationImportID,
+					"err":                  err,
+				}, "unable to send the invitation e-mails")
+			}
+			imp.PendingEmails = nil
+			if remaining := messages[batchSize:]; len(remaining) > 0 {
+				emails, err := json.Marshal(remaining)
+				if err != nil {
+					return errs.Wrap(err, "unable to queue the invitation e-mails of the invitation import")
+				}
+				pending := string(emails)
+				imp.PendingEmails = &pending
+			}
+			now := time.Now()
+			imp.EmailsSentAt = &now
+			err = s.Repositories().InvitationImportRepository().Save(ctx, &imp)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
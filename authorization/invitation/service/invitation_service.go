@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
@@ -17,15 +18,25 @@ import (
 	"github.com/fabric8-services/fabric8-auth/client"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	autherrors "github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
 	errs "github.com/pkg/errors"
 
 	uuid "github.com/satori/go.uuid"
 )
 
+// invitationsBatchSize is the maximum number of expired invitations deleted at once
+const invitationsBatchSize = 100
+
 type InvitationConfiguration interface {
 	GetAuthServiceURL() string
 	IsPostgresDeveloperModeEnabled() bool
+	GetInvitationExpiry() time.Duration
+	GetInvitationAcceptedRedirectURL() string
+	GetInvitationImportSyncLimit() int
+	GetInvitationImportEmailBatchSize() int
+	GetInvitationImportEmailBatchDelay() time.Duration
 }
 
 type invitationServiceImpl struct {
@@ -46,6 +57,19 @@ func NewInvitationService(context servicecontext.ServiceContext, config Invitati
 // as part of a user's invitation are created in the INVITATION_ROLE table.
 // IMPORTANT: This is a transactional method, which manages its own transaction/s internally
 func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UUID, inviteTo string, invitations []invitation.Invitation) error {
+	messages, err := s.issue(ctx, issuingUserId, inviteTo, invitations)
+	if err != nil {
+		return err
+	}
+
+	// Use the notification service to send invitation e-mails to the invited users, in a separate thread
+	_, err = s.Services().NotificationService().SendMessagesAsync(ctx, messages)
+	return err
+}
+
+// issue creates the invitation records and returns the notification messages to send to the invited users.
+// If called within an existing transaction then the invitation records are created as part of it.
+func (s *invitationServiceImpl) issue(ctx context.Context, issuingUserId uuid.UUID, inviteTo string, invitations []invitation.Invitation) ([]notification.Message, error) {
 	var inviteToIdentity *account.Identity
 	var identityResource *resource.Resource
 	var inviteToResource *resource.Resource
@@ -81,14 +105,16 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 		// We currently only support:
 		// 1) Invitation to a space
 		// 2) Invitation to a team
+		// 3) Invitation to an organization
 		if inviteToIdentity != nil {
 			identityResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteToIdentity.IdentityResourceID.String)
 			if err != nil {
 				return err
 			}
 
-			if identityResource.ResourceType.Name != authorization.IdentityResourceTypeTeam {
-				return errors.NewBadParameterErrorFromString("inviteTo", inviteTo, "Invitation is not for a team identity")
+			if identityResource.ResourceType.Name != authorization.IdentityResourceTypeTeam &&
+				identityResource.ResourceType.Name != authorization.IdentityResourceTypeOrganization {
+				return errors.NewBadParameterErrorFromString("inviteTo", inviteTo, "Invitation is not for a team or organization identity")
 			}
 		} else if inviteToResource != nil && inviteToResource.ResourceType.Name != authorization.ResourceTypeSpace {
 			return errors.NewBadParameterErrorFromString("inviteTo", inviteTo, "Invitation is not for a space")
@@ -132,14 +158,25 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 		// For each invitation, ensure that the IdentityID value can be found and set it
 		// 3) create invitation records
 		for _, invitation := range invitations {
-			// Load the identity
-			identity, err := s.Repositories().Identities().Load(ctx, *invitation.IdentityID)
-			if err != nil {
-				return errors.NewInternalError(ctx, err)
-			}
+			// Load the identity, or lookup the user with the given email address. If there is none then the
+			// invitation is addressed to the email address.
+			var identity *account.Identity
+			if invitation.IdentityID != nil {
+				identity, err = s.Repositories().Identities().Load(ctx, *invitation.IdentityID)
+				if err != nil {
+					return errors.NewInternalError(ctx, err)
+				}
 
-			if !identity.IsUser() {
-				return errors.NewBadParameterErrorFromString("Identity ID", invitation.IdentityID, "identity is not a user")
+				if !identity.IsUser() {
+					return errors.NewBadParameterErrorFromString("Identity ID", invitation.IdentityID, "identity is not a user")
+				}
+			} else if invitation.Email != "" {
+				identity, err = s.lookupUserByVerifiedEmail(ctx, invitation.Email)
+				if err != nil {
+					return err
+				}
+			} else {
+				return errors.NewBadParameterErrorFromString("Identity ID", "", "no identity ID or email address provided")
 			}
 
 			if invitation.Member && inviteToResource != nil {
@@ -149,8 +186,14 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 
 			// Create the invitation records
 			inv := new(invitationrepo.Invitation)
-			inv.IdentityID = *invitation.IdentityID
-			inv.Identity = *identity
+			inv.InviterID = &issuingUserId
+			if identity != nil {
+				inv.IdentityID = &identity.ID
+				inv.Identity = *identity
+			} else {
+				inv.Email = invitation.Email
+				inv.AcceptOnRegistration = invitation.AcceptOnRegistration
+			}
 			if len(invitation.RedirectOnSuccess) > 0 {
 				inv.SuccessRedirectURL = invitation.RedirectOnSuccess
 			}
@@ -165,6 +208,7 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 			} else if inviteToResource != nil {
 				inv.ResourceID = &inviteToResource.ResourceID
 			}
+			inv.ExpiresAt = s.expiry()
 
 			err = s.Repositories().InvitationRepository().Create(ctx, inv)
 			if err != nil {
@@ -202,40 +246,39 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Lookup the identity record of the user doing the inviting
 	inviter, err := s.Repositories().Identities().LoadWithUser(ctx, issuingUserId)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Use the notification service to send invitation e-mails to the invited users, in a separate thread
 	// Currently we only support sending notifications for two types of invitations;
 	//
 	// 1) Invite user to team, membership only, no organization
 	// 2) Invite user to space, roles only, no organization
+	// 3) Invite user to organization
 	//
 	if inviteToIdentity != nil {
 		identityResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteToIdentity.IdentityResourceID.String)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		if identityResource.ResourceType.Name == authorization.IdentityResourceTypeTeam {
-			err = s.processTeamInviteNotifications(ctx, inviteToIdentity, inviter.User.FullName, notifications)
+		switch identityResource.ResourceType.Name {
+		case authorization.IdentityResourceTypeTeam:
+			return s.teamInviteMessages(ctx, inviteToIdentity, inviter, notifications)
+		case authorization.IdentityResourceTypeOrganization:
+			return s.organizationInviteMessages(inviteToIdentity, identityResource.Name, inviter, notifications)
 		}
 	} else if inviteToResource != nil && inviteToResource.ResourceType.Name == authorization.ResourceTypeSpace {
-		err = s.processSpaceInviteNotifications(ctx, inviteToResource, inviter.User.FullName, notifications)
-	}
-
-	if err != nil {
-		return err
+		return s.spaceInviteMessages(ctx, inviteToResource, inviter, notifications)
 	}
 
-	return nil
+	return nil, nil
 }
 
 type invitationNotification struct {
@@ -243,22 +286,75 @@ type invitationNotification struct {
 	roles      []string
 }
 
-// processTeamInviteNotifications sends an e-mail notification to a user.
-func (s *invitationServiceImpl) processTeamInviteNotifications(ctx context.Context, team *account.Identity, inviterName string,
-	notifications []invitationNotification) error {
+// lookupUserByVerifiedEmail returns the identity of the user with the specified verified email address, or nil if
+// there is no such user
+func (s *invitationServiceImpl) lookupUserByVerifiedEmail(ctx context.Context, email string) (*account.Identity, error) {
+	valid, err := rest.ValidateEmail(email)
+	if err != nil || !valid {
+		return nil, errors.NewBadParameterErrorFromString("Email", email, "invalid email address")
+	}
+	users, err := s.Repositories().Users().Query(account.UserFilterByEmail(email))
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	for _, user := range users {
+		if !user.EmailVerified {
+			continue
+		}
+		identities, err := s.Repositories().Identities().Query(account.IdentityFilterByUserID(user.ID))
+		if err != nil {
+			return nil, errors.NewInternalError(ctx, err)
+		}
+		if len(identities) > 0 {
+			return &identities[0], nil
+		}
+	}
+	return nil, nil
+}
+
+// newEmailInvitationMessage creates the notification message for an invitation addressed to the email address of a
+// person who has no account yet, which includes a link to sign up before accepting the invitation
+func (s *invitationServiceImpl) newEmailInvitationMessage(inviter *account.Identity, targetID, targetName string,
+	n invitationNotification, acceptURL string) (notification.Message, error) {
+	// Once signed up, the user is either redirected to the accept URL, or directly to the success URL if the
+	// invitation is accepted at once
+	redirect := acceptURL
+	if n.invitation.AcceptOnRegistration {
+		redirect = n.invitation.SuccessRedirectURL
+		if redirect == "" {
+			redirect = s.config.GetInvitationAcceptedRedirectURL()
+		}
+	}
+	signUpURL, err := rest.AddParam(fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.LoginLoginPath()), "redirect", redirect)
+	if err != nil {
+		return notification.Message{}, err
+	}
+	return notification.NewEmailInvitationEmail(inviter.ID.String(),
+		targetID,
+		n.invitation.Email,
+		targetName,
+		inviter.User.FullName,
+		strings.Join(n.roles, ","),
+		acceptURL,
+		signUpURL), nil
+}
+
+// teamInviteMessages builds the e-mail notifications for users invited to a team.
+func (s *invitationServiceImpl) teamInviteMessages(ctx context.Context, team *account.Identity, inviter *account.Identity,
+	notifications []invitationNotification) ([]notification.Message, error) {
 	teamName := team.IdentityResource.Name
 
 	var spaceName string
 	res, err := s.Repositories().ResourceRepository().Load(ctx, team.IdentityResourceID.String)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Every team *should* have a parent space, but we'll put this check here just in case
 	if res.ParentResourceID != nil {
 		sp, err := s.Services().WITService().GetSpace(ctx, *res.ParentResourceID)
 		if err != nil {
-			return errs.Wrap(err, "error while retrieving space from WIT")
+			return nil, errs.Wrap(err, "error while retrieving space from WIT")
 		}
 		spaceName = sp.Name
 	}
@@ -268,23 +364,58 @@ func (s *invitationServiceImpl) processTeamInviteNotifications(ctx context.Conte
 	for _, n := range notifications {
 		acceptURL := fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.AcceptInviteInvitationPath(n.invitation.AcceptCode.String()))
 
+		if n.invitation.IdentityID == nil {
+			msg, err := s.newEmailInvitationMessage(inviter, team.ID.String(), teamName, n, acceptURL)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+			continue
+		}
+
 		messages = append(messages, notification.NewTeamInvitationEmail(n.invitation.Identity.ID.String(),
 			teamName,
-			inviterName,
+			inviter.User.FullName,
 			spaceName,
 			acceptURL))
 	}
 
-	_, e := s.Services().NotificationService().SendMessagesAsync(ctx, messages)
-	return e
+	return messages, nil
 }
 
-// processSpaceInviteNotifications sends an e-mail notification to a user.
-func (s *invitationServiceImpl) processSpaceInviteNotifications(ctx context.Context, space *resource.Resource,
-	inviterName string, notifications []invitationNotification) error {
+// organizationInviteMessages builds the e-mail notifications for users invited to an organization.
+func (s *invitationServiceImpl) organizationInviteMessages(organization *account.Identity, organizationName string,
+	inviter *account.Identity, notifications []invitationNotification) ([]notification.Message, error) {
+	var messages []notification.Message
+
+	for _, n := range notifications {
+		acceptURL := fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.AcceptInviteInvitationPath(n.invitation.AcceptCode.String()))
+
+		if n.invitation.IdentityID == nil {
+			msg, err := s.newEmailInvitationMessage(inviter, organization.ID.String(), organizationName, n, acceptURL)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+			continue
+		}
+
+		messages = append(messages, notification.NewOrganizationInvitationEmail(n.invitation.Identity.ID.String(),
+			organizationName,
+			inviter.User.FullName,
+			strings.Join(n.roles, ","),
+			acceptURL))
+	}
+
+	return messages, nil
+}
+
+// spaceInviteMessages builds the e-mail notifications for users invited to a space.
+func (s *invitationServiceImpl) spaceInviteMessages(ctx context.Context, space *resource.Resource,
+	inviter *account.Identity, notifications []invitationNotification) ([]notification.Message, error) {
 	sp, err := s.Services().WITService().GetSpace(ctx, space.ResourceID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	spaceName := sp.Name
 
@@ -293,14 +424,22 @@ func (s *invitationServiceImpl) processSpaceInviteNotifications(ctx context.Cont
 	for _, n := range notifications {
 		acceptURL := fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.AcceptInviteInvitationPath(n.invitation.AcceptCode.String()))
 
+		if n.invitation.IdentityID == nil {
+			msg, err := s.newEmailInvitationMessage(inviter, space.ResourceID, spaceName, n, acceptURL)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+			continue
+		}
+
 		messages = append(messages, notification.NewSpaceInvitationEmail(n.invitation.Identity.ID.String(),
 			spaceName,
-			inviterName,
+			inviter.User.FullName,
 			strings.Join(n.roles, ","),
 			acceptURL))
 	}
-	_, e := s.Services().NotificationService().SendMessagesAsync(ctx, messages)
-	return e
+	return messages, nil
 }
 
 // Rescind revokes an invitation request
@@ -311,6 +450,22 @@ func (s *invitationServiceImpl) Rescind(ctx context.Context, rescindingUserID, i
 		return errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for invitation", invitationID.String()))
 	}
 
+	_, _, err = s.requireManageInvitations(ctx, rescindingUserID, inv)
+	if err != nil {
+		return err
+	}
+
+	err = s.ExecuteInTransaction(func() error {
+		// Delete the invitation
+		return s.Repositories().InvitationRepository().Delete(ctx, invitationID)
+	})
+	return err
+}
+
+// requireManageInvitations confirms that the specified user has the necessary scope to manage the invitations to the
+// organization, team, security group or resource for which the given invitation was issued, and returns the identity
+// (with its resource) or the resource that the invitation is for
+func (s *invitationServiceImpl) requireManageInvitations(ctx context.Context, identityID uuid.UUID, inv *invitationrepo.Invitation) (*account.Identity, *resource.Resource, error) {
 	// Create the permission service
 	permService := s.Services().PermissionService()
 
@@ -318,44 +473,402 @@ func (s *invitationServiceImpl) Rescind(ctx context.Context, rescindingUserID, i
 		// Lookup identity with InviteTo ID
 		inviteToIdentity, err := s.Repositories().Identities().Load(ctx, *inv.InviteTo)
 		if err != nil {
-			return errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for organization, team or security group", inv.InviteTo.String()))
+			return nil, nil, errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for organization, team or security group", inv.InviteTo.String()))
 		}
 
 		if !inviteToIdentity.IdentityResourceID.Valid {
-			return errors.NewNotFoundErrorFromString(fmt.Sprintf("specified identity '%s' has no resource", inv.InviteTo.String()))
+			return nil, nil, errors.NewNotFoundErrorFromString(fmt.Sprintf("specified identity '%s' has no resource", inv.InviteTo.String()))
 		}
 
 		identityResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteToIdentity.IdentityResourceID.String)
 		if err != nil {
-			return errors.NewInternalError(ctx, err)
+			return nil, nil, errors.NewInternalError(ctx, err)
 		}
+		inviteToIdentity.IdentityResource = *identityResource
 
-		// Confirm that the rescinding user has the necessary scope to manage members for the organization, team or security group
-		err = permService.RequireScope(ctx, rescindingUserID, inviteToIdentity.IdentityResourceID.String, authorization.ScopeForManagingRolesInResourceType(identityResource.ResourceType.Name))
+		// Confirm that the user has the necessary scope to manage members for the organization, team or security group
+		err = permService.RequireScope(ctx, identityID, inviteToIdentity.IdentityResourceID.String, authorization.ScopeForManagingRolesInResourceType(identityResource.ResourceType.Name))
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+		return inviteToIdentity, nil, nil
 	} else if inv.ResourceID != nil {
 		// Lookup a resource with the ResourceID value
 		inviteToResource, err := s.Repositories().ResourceRepository().Load(ctx, *inv.ResourceID)
 		if err != nil {
-			return errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for resource", *inv.ResourceID))
+			return nil, nil, errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for resource", *inv.ResourceID))
+		}
+
+		// Confirm that the user has the manage members scope for the resource
+		err = permService.RequireScope(ctx, identityID, inviteToResource.ResourceID, authorization.ScopeForManagingRolesInResourceType(inviteToResource.ResourceType.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, inviteToResource, nil
+	}
+	return nil, nil, errors.NewInternalErrorFromString(ctx, fmt.Sprintf("invitation '%s' is neither for an identity nor for a resource", inv.InvitationID))
+}
+
+// ListPending returns the invitations which have been issued for the specified organization, team, security group
+// (the Identity ID) or resource (Resource ID) and which have neither been accepted nor expired yet.
+// The current identity must have the necessary scope to manage the members or roles of the identity or resource.
+func (s *invitationServiceImpl) ListPending(ctx context.Context, currentIdentityID uuid.UUID, inviteTo string) ([]invitation.PendingInvitation, error) {
+	var invitations []invitationrepo.Invitation
+	var found bool
+	inviteToUUID, err := uuid.FromString(inviteTo)
+	if err == nil {
+		inviteToIdentity, err := s.Repositories().Identities().Load(ctx, inviteToUUID)
+		if err == nil && inviteToIdentity.IdentityResourceID.Valid {
+			found = true
+			identityResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteToIdentity.IdentityResourceID.String)
+			if err != nil {
+				return nil, errors.NewInternalError(ctx, err)
+			}
+			err = s.Services().PermissionService().RequireScope(ctx, currentIdentityID, identityResource.ResourceID, authorization.ScopeForManagingRolesInResourceType(identityResource.ResourceType.Name))
+			if err != nil {
+				return nil, err
+			}
+			invitations, err = s.Repositories().InvitationRepository().ListForIdentity(ctx, inviteToIdentity.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !found {
+		// Try to lookup a resource with the same ID value
+		inviteToResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteTo)
+		if err != nil {
+			return nil, errors.NewNotFoundError(fmt.Sprintf("invalid identifier '%s' provided for organization, team, security group or resource", inviteTo), inviteTo)
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentityID, inviteToResource.ResourceID, authorization.ScopeForManagingRolesInResourceType(inviteToResource.ResourceType.Name))
+		if err != nil {
+			return nil, err
+		}
+		invitations, err = s.Repositories().InvitationRepository().ListForResource(ctx, inviteToResource.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []invitation.PendingInvitation
+	for _, inv := range invitations {
+		if inv.IsExpired() {
+			continue
+		}
+		var username string
+		if inv.IdentityID != nil {
+			identity, err := s.Repositories().Identities().Load(ctx, *inv.IdentityID)
+			if err != nil {
+				return nil, err
+			}
+			username = identity.Username
+		}
+		roleNames, err := s.listRoleNames(ctx, inv.InvitationID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, invitation.PendingInvitation{
+			InvitationID: inv.InvitationID,
+			InviteTo:     inv.InviteTo,
+			ResourceID:   inv.ResourceID,
+			IdentityID:   inv.IdentityID,
+			Username:     username,
+			Email:        inv.Email,
+			Member:       inv.Member,
+			Roles:        roleNames,
+			CreatedAt:    inv.CreatedAt,
+			ExpiresAt:    inv.ExpiresAt,
+		})
+	}
+	return result, nil
+}
+
+// Resend generates a new accept code for the specified invitation, extends its validity and sends the invitation
+// e-mail to the invited user again. Expired invitations which were not purged yet may be resent too.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *invitationServiceImpl) Resend(ctx context.Context, resendingUserID, invitationID uuid.UUID) error {
+	// Locate the invitation
+	inv, err := s.Repositories().InvitationRepository().Load(ctx, invitationID)
+	if err != nil {
+		return errors.NewNotFoundErrorFromString(fmt.Sprintf("invalid identifier '%s' provided for invitation", invitationID.String()))
+	}
+
+	inviteToIdentity, inviteToResource, err := s.requireManageInvitations(ctx, resendingUserID, inv)
+	if err != nil {
+		return err
+	}
+
+	err = s.ExecuteInTransaction(func() error {
+		inv.AcceptCode = uuid.NewV4()
+		inv.ExpiresAt = s.expiry()
+		return s.Repositories().InvitationRepository().Save(ctx, inv)
+	})
+	if err != nil {
+		return err
+	}
+
+	if inv.IdentityID != nil {
+		identity, err := s.Repositories().Identities().Load(ctx, *inv.IdentityID)
+		if err != nil {
+			return err
 		}
+		inv.Identity = *identity
+	}
+
+	roleNames, err := s.listRoleNames(ctx, inv.InvitationID)
+	if err != nil {
+		return err
+	}
 
-		// Confirm that the rescinding user has the manage members scope for the resource
-		err = permService.RequireScope(ctx, rescindingUserID, inviteToResource.ResourceID, authorization.ScopeForManagingRolesInResourceType(inviteToResource.ResourceType.Name))
+	// Lookup the identity record of the user doing the inviting
+	inviter, err := s.Repositories().Identities().LoadWithUser(ctx, resendingUserID)
+	if err != nil {
+		return err
+	}
+
+	var messages []notification.Message
+	notifications := []invitationNotification{{invitation: inv, roles: roleNames}}
+	if inviteToIdentity != nil && inviteToIdentity.IdentityResource.ResourceType.Name == authorization.IdentityResourceTypeTeam {
+		messages, err = s.teamInviteMessages(ctx, inviteToIdentity, inviter, notifications)
+	} else if inviteToResource != nil && inviteToResource.ResourceType.Name == authorization.ResourceTypeSpace {
+		messages, err = s.spaceInviteMessages(ctx, inviteToResource, inviter, notifications)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.Services().NotificationService().SendMessagesAsync(ctx, messages)
+	return err
+}
+
+// DeleteExpired deletes the invitations which can no longer be accepted. Up to invitationsBatchSize invitations are
+// deleted at once.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *invitationServiceImpl) DeleteExpired(ctx context.Context) error {
+	var expired []invitationrepo.Invitation
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		expired, err = s.Repositories().InvitationRepository().FindExpired(ctx, invitationsBatchSize)
 		if err != nil {
 			return err
 		}
+		for _, inv := range expired {
+			err = s.Repositories().InvitationRepository().Delete(ctx, inv.InvitationID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(expired) > 0 {
+		log.Info(ctx, map[string]interface{}{
+			"invitations": len(expired),
+		}, "expired invitations deleted")
 	}
+	return nil
+}
 
+// ClaimInvitations attaches the invitations addressed to the verified email address of the specified user, before
+// they signed up, to their identity so that they can be accepted. The invitations which the inviter chose to be
+// accepted on registration are accepted at once.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *invitationServiceImpl) ClaimInvitations(ctx context.Context, identityID uuid.UUID) error {
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	if !identity.User.EmailVerified || identity.User.Email == "" {
+		return nil
+	}
+
+	var claimed []invitationrepo.Invitation
 	err = s.ExecuteInTransaction(func() error {
-		// Delete the invitation
-		return s.Repositories().InvitationRepository().Delete(ctx, invitationID)
+		invitations, err := s.Repositories().InvitationRepository().FindUnclaimedByEmail(ctx, identity.User.Email)
+		if err != nil {
+			return err
+		}
+		for i := range invitations {
+			inv := &invitations[i]
+			if inv.IsExpired() {
+				continue
+			}
+			inv.IdentityID = &identity.ID
+			err = s.Repositories().InvitationRepository().Save(ctx, inv)
+			if err != nil {
+				return err
+			}
+			claimed = append(claimed, *inv)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identity.ID,
+		"invitations": len(claimed),
+	}, "invitations addressed to the user email claimed")
+
+	for _, inv := range claimed {
+		if !inv.AcceptOnRegistration {
+			continue
+		}
+		_, _, err = s.Accept(ctx, inv.AcceptCode)
+		if err != nil {
+			// The invitation remains pending, we will just log the error and continue
+			log.Error(ctx, map[string]interface{}{
+				"err":           err,
+				"invitation_id": inv.InvitationID,
+			}, "unable to accept the invitation on registration")
+		}
+	}
+	return nil
+}
+
+// ListForInvitee returns the invitations addressed to the specified user which have not expired yet
+func (s *invitationServiceImpl) ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]invitation.PendingInvitation, error) {
+	invitations, err := s.Repositories().InvitationRepository().ListForInvitee(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	var result []invitation.PendingInvitation
+	for _, inv := range invitations {
+		if inv.IsExpired() {
+			continue
+		}
+		name, err := s.invitationTargetName(ctx, &inv)
+		if err != nil {
+			return nil, err
+		}
+		roleNames, err := s.listRoleNames(ctx, inv.InvitationID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, invitation.PendingInvitation{
+			InvitationID: inv.InvitationID,
+			InviteTo:     inv.InviteTo,
+			ResourceID:   inv.ResourceID,
+			Name:         name,
+			IdentityID:   inv.IdentityID,
+			Member:       inv.Member,
+			Roles:        roleNames,
+			CreatedAt:    inv.CreatedAt,
+			ExpiresAt:    inv.ExpiresAt,
+		})
+	}
+	return result, nil
+}
+
+// AcceptByID accepts the specified invitation on behalf of the user to which it is addressed, and returns the
+// resource ID of the resource or identity resource which the invitation is for
+func (s *invitationServiceImpl) AcceptByID(ctx context.Context, identityID, invitationID uuid.UUID) (string, error) {
+	inv, err := s.loadReceivedInvitation(ctx, identityID, invitationID)
+	if err != nil {
+		return "", err
+	}
+	resourceID, _, err := s.Accept(ctx, inv.AcceptCode)
+	return resourceID, err
+}
+
+// Decline deletes the specified invitation on behalf of the user to which it is addressed, and notifies the user who
+// issued it
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *invitationServiceImpl) Decline(ctx context.Context, identityID, invitationID uuid.UUID) error {
+	inv, err := s.loadReceivedInvitation(ctx, identityID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	err = s.ExecuteInTransaction(func() error {
+		// Delete the invitation and its roles
+		return s.Repositories().InvitationRepository().Delete(ctx, inv.InvitationID)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Invitations issued before the inviter was recorded cannot be notified
+	if inv.InviterID == nil {
+		return nil
+	}
+	invitee, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	name, err := s.invitationTargetName(ctx, inv)
+	if err != nil {
+		return err
+	}
+	var targetID string
+	if inv.InviteTo != nil {
+		targetID = inv.InviteTo.String()
+	} else if inv.ResourceID != nil {
+		targetID = *inv.ResourceID
+	}
+	_, err = s.Services().NotificationService().SendMessagesAsync(ctx, []notification.Message{
+		notification.NewInvitationDeclinedEmail(inv.InviterID.String(), targetID, name, invitee.Username),
 	})
 	return err
 }
 
+// loadReceivedInvitation loads the specified invitation, which must be addressed to the given user identity. An
+// invitation addressed to someone else is reported as not found.
+func (s *invitationServiceImpl) loadReceivedInvitation(ctx context.Context, identityID, invitationID uuid.UUID) (*invitationrepo.Invitation, error) {
+	inv, err := s.Repositories().InvitationRepository().Load(ctx, invitationID)
+	if err != nil || inv.IdentityID == nil || *inv.IdentityID != identityID {
+		return nil, errors.NewNotFoundError("invitation", invitationID.String())
+	}
+	return inv, nil
+}
+
+// invitationTargetName returns the name of the organization, team, security group or resource the invitation is for
+func (s *invitationServiceImpl) invitationTargetName(ctx context.Context, inv *invitationrepo.Invitation) (string, error) {
+	resourceID := inv.ResourceID
+	if inv.InviteTo != nil {
+		inviteToIdentity, err := s.Repositories().Identities().Load(ctx, *inv.InviteTo)
+		if err != nil {
+			return "", err
+		}
+		if !inviteToIdentity.IdentityResourceID.Valid {
+			return "", nil
+		}
+		resourceID = &inviteToIdentity.IdentityResourceID.String
+	}
+	if resourceID == nil {
+		return "", nil
+	}
+	res, err := s.Repositories().ResourceRepository().Load(ctx, *resourceID)
+	if err != nil {
+		return "", err
+	}
+	return res.Name, nil
+}
+
+// listRoleNames returns the names of the roles offered in the specified invitation
+func (s *invitationServiceImpl) listRoleNames(ctx context.Context, invitationID uuid.UUID) ([]string, error) {
+	roles, err := s.Repositories().InvitationRepository().ListRoles(ctx, invitationID)
+	if err != nil {
+		return nil, err
+	}
+	roleNames := make([]string, len(roles))
+	for i, role := range roles {
+		roleNames[i] = role.Name
+	}
+	return roleNames, nil
+}
+
+// expiry returns the time after which an invitation issued or resent now can no longer be accepted
+func (s *invitationServiceImpl) expiry() *time.Time {
+	expiresAt := time.Now().Add(s.config.GetInvitationExpiry())
+	return &expiresAt
+}
+
 // Accept processes an invitation acceptance click, returns the resource ID of the resource or identity resource which
 // the invitation is for and url to redirect after accepting invitation
 func (s *invitationServiceImpl) Accept(ctx context.Context, token uuid.UUID) (string, string, error) {
@@ -371,8 +884,16 @@ func (s *invitationServiceImpl) Accept(ctx context.Context, token uuid.UUID) (st
 	redirectOnSuccess := inv.SuccessRedirectURL
 	redirectOnFailure := inv.FailureRedirectURL
 
+	if inv.IsExpired() {
+		return "", redirectOnFailure, autherrors.NewUnauthorizedError("invitation has expired")
+	}
+
+	if inv.IdentityID == nil {
+		return "", redirectOnFailure, autherrors.NewUnauthorizedError("sign up with the email address to which the invitation was sent before accepting it")
+	}
+
 	// get identity for invitation
-	currentIdentityID := inv.IdentityID
+	currentIdentityID := *inv.IdentityID
 	identity, err := s.Repositories().Identities().LoadWithUser(ctx, currentIdentityID)
 	if err != nil {
 		return "", redirectOnFailure, errs.Wrapf(err, "failed to load identity for invitee %d", currentIdentityID)
@@ -395,7 +916,7 @@ func (s *invitationServiceImpl) Accept(ctx context.Context, token uuid.UUID) (st
 
 			// If the invitation is for a membership, add a membership record
 			if inv.Member {
-				err = s.Repositories().Identities().AddMember(ctx, inviteToIdentity.ID, currentIdentityID)
+				err = s.Services().MembershipService().AddMember(ctx, inviteToIdentity.ID, currentIdentityID)
 				if err != nil {
 					return err
 				}
@@ -2,7 +2,11 @@ package service_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application/service"
@@ -19,6 +23,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/test"
 	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
 
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -207,7 +212,7 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 		// There should be 1 invitation only
 		require.Equal(t, 1, len(invs))
 		require.False(t, invs[0].Member)
-		require.Equal(t, invitee.IdentityID(), invs[0].IdentityID)
+		require.Equal(t, invitee.IdentityID(), *invs[0].IdentityID)
 
 		// there should be success and failure redirect url
 		require.Equal(t, success, invs[0].SuccessRedirectURL)
@@ -658,7 +663,7 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 		require.NoError(t, err)
 
 		require.Len(t, invs, 1)
-		require.Equal(t, user.IdentityID(), invs[0].IdentityID)
+		require.Equal(t, user.IdentityID(), *invs[0].IdentityID)
 		require.True(t, invs[0].Member)
 		require.Equal(t, success, invs[0].SuccessRedirectURL)
 		require.Equal(t, failure, invs[0].FailureRedirectURL)
@@ -708,7 +713,7 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 
 		require.NoError(t, err)
 		require.Len(t, invs, 1)
-		require.Equal(t, invitee.IdentityID(), invs[0].IdentityID)
+		require.Equal(t, invitee.IdentityID(), *invs[0].IdentityID)
 		require.False(t, invs[0].Member)
 		require.Equal(t, success, invs[0].SuccessRedirectURL)
 		require.Equal(t, failure, invs[0].FailureRedirectURL)
@@ -824,6 +829,28 @@ func (s *invitationServiceBlackBoxTest) TestAcceptInvitation() {
 		require.Empty(t, resourceID)
 		require.Empty(t, redirectPath)
 	})
+
+	s.T().Run("should fail to accept expired invitation", func(t *testing.T) {
+		// given
+		space := s.Graph.CreateSpace()
+		user := s.Graph.CreateUser()
+		spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+		inv := s.Graph.CreateInvitation(space, user, spaceRole, redirectURL())
+		s.expireInvitation(t, inv.Invitation().InvitationID)
+
+		// when
+		resourceID, redirectPath, err := s.Application.InvitationService().Accept(s.Ctx, inv.Invitation().AcceptCode)
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, err)
+		require.Empty(t, resourceID)
+		require.Equal(t, failure, redirectPath)
+
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesForIdentity(s.Ctx, user.IdentityID(), nil)
+		require.NoError(t, err)
+		require.Empty(t, roles)
+	})
 }
 
 func (s *invitationServiceBlackBoxTest) TestRescindInvitation() {
@@ -988,6 +1015,611 @@ func (s *invitationServiceBlackBoxTest) TestAcceptBasicResourceInvitationUpdates
 	require.Contains(s.T(), privs.ScopesAsArray(), "foo")
 }
 
+func (s *invitationServiceBlackBoxTest) TestListPendingInvitations() {
+	// given
+	admin := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(admin)
+	spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+	invitee := s.Graph.CreateUser()
+	pending := s.Graph.CreateInvitation(space, invitee, spaceRole)
+	expired := s.Graph.CreateInvitation(space, s.Graph.CreateUser(), spaceRole)
+	s.expireInvitation(s.T(), expired.Invitation().InvitationID)
+
+	s.T().Run("should list pending invitations", func(t *testing.T) {
+		// when
+		invitations, err := s.Application.InvitationService().ListPending(s.Ctx, admin.IdentityID(), space.SpaceID())
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		require.Equal(t, pending.Invitation().InvitationID, invitations[0].InvitationID)
+		require.Equal(t, invitee.IdentityID(), *invitations[0].IdentityID)
+		require.Equal(t, invitee.Identity().Username, invitations[0].Username)
+		require.Equal(t, []string{spaceRole.Role().Name}, invitations[0].Roles)
+	})
+
+	s.T().Run("should fail to list pending invitations without privileges", func(t *testing.T) {
+		// when
+		_, err := s.Application.InvitationService().ListPending(s.Ctx, invitee.IdentityID(), space.SpaceID())
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, err)
+	})
+
+	s.T().Run("should fail to list pending invitations for unknown resource", func(t *testing.T) {
+		// when
+		_, err := s.Application.InvitationService().ListPending(s.Ctx, admin.IdentityID(), uuid.NewV4().String())
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *invitationServiceBlackBoxTest) TestResendInvitation() {
+	s.T().Run("should resend expired invitation with a new accept code", func(t *testing.T) {
+		// given
+		admin := s.Graph.CreateUser()
+		space := s.Graph.CreateSpace().AddAdmin(admin)
+		spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+		invitee := s.Graph.CreateUser()
+		inv := s.Graph.CreateInvitation(space, invitee, spaceRole)
+		s.expireInvitation(t, inv.Invitation().InvitationID)
+
+		var messages []notification.Message
+		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
+		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
+			messages = msgs
+			return nil, nil
+		}
+		*s.witServiceMock = *test.NewWITMock(t, admin.IdentityID().String(), spaceName)
+
+		// when
+		err := s.Application.InvitationService().Resend(s.Ctx, admin.IdentityID(), inv.Invitation().InvitationID)
+
+		// then
+		require.NoError(t, err)
+		resent, err := s.invitationRepo.Load(s.Ctx, inv.Invitation().InvitationID)
+		require.NoError(t, err)
+		require.NotEqual(t, inv.Invitation().AcceptCode, resent.AcceptCode)
+		require.False(t, resent.IsExpired())
+		require.Len(t, messages, 1)
+		require.Equal(t, invitee.IdentityID().String(), messages[0].TargetID)
+		require.Contains(t, messages[0].Custom["acceptURL"], resent.AcceptCode.String())
+
+		// the previous accept code cannot be used anymore, while the new one can
+		_, _, err = s.Application.InvitationService().Accept(s.Ctx, inv.Invitation().AcceptCode)
+		require.Error(t, err)
+		resourceID, _, err := s.Application.InvitationService().Accept(s.Ctx, resent.AcceptCode)
+		require.NoError(t, err)
+		require.Equal(t, space.SpaceID(), resourceID)
+	})
+
+	s.T().Run("should fail to resend invitation without privileges", func(t *testing.T) {
+		// given
+		space := s.Graph.CreateSpace()
+		invitee := s.Graph.CreateUser()
+		inv := s.Graph.CreateInvitation(space, invitee, s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace)))
+
+		// when
+		err := s.Application.InvitationService().Resend(s.Ctx, s.Graph.CreateUser().IdentityID(), inv.Invitation().InvitationID)
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, err)
+		loaded, err := s.invitationRepo.Load(s.Ctx, inv.Invitation().InvitationID)
+		require.NoError(t, err)
+		require.Equal(t, inv.Invitation().AcceptCode, loaded.AcceptCode)
+	})
+}
+
+func (s *invitationServiceBlackBoxTest) TestDeleteExpiredInvitations() {
+	// given
+	space := s.Graph.CreateSpace()
+	spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+	pending := s.Graph.CreateInvitation(space, s.Graph.CreateUser(), spaceRole)
+	expired := s.Graph.CreateInvitation(space, s.Graph.CreateUser(), spaceRole)
+	s.expireInvitation(s.T(), expired.Invitation().InvitationID)
+
+	// when
+	err := s.Application.InvitationService().DeleteExpired(s.Ctx)
+
+	// then
+	require.NoError(s.T(), err)
+	_, err = s.invitationRepo.Load(s.Ctx, expired.Invitation().InvitationID)
+	require.IsType(s.T(), errors.NotFoundError{}, err)
+	_, err = s.invitationRepo.Load(s.Ctx, pending.Invitation().InvitationID)
+	require.NoError(s.T(), err)
+}
+
+func (s *invitationServiceBlackBoxTest) TestEmailInvitation() {
+	// given
+	inviter := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(inviter)
+	spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+
+	issue := func(t *testing.T, email string, acceptOnRegistration bool) (invitationrepo.Invitation, []notification.Message) {
+		var messages []notification.Message
+		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
+		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
+			messages = msgs
+			return nil, nil
+		}
+		*s.witServiceMock = *test.NewWITMock(t, inviter.IdentityID().String(), spaceName)
+		err := s.Application.InvitationService().Issue(s.Ctx, inviter.IdentityID(), space.SpaceID(), []invitation.Invitation{
+			{
+				Email:                email,
+				Roles:                []string{spaceRole.Role().Name},
+				AcceptOnRegistration: acceptOnRegistration,
+			},
+		})
+		require.NoError(t, err)
+		invs, err := s.invitationRepo.ListForResource(s.Ctx, space.SpaceID())
+		require.NoError(t, err)
+		for _, inv := range invs {
+			if inv.Email == email {
+				return inv, messages
+			}
+		}
+		require.Fail(t, "invitation not found")
+		return invitationrepo.Invitation{}, nil
+	}
+
+	signUp := func(t *testing.T, email string) uuid.UUID {
+		user := s.Graph.CreateUser()
+		user.User().Email = email
+		user.User().EmailVerified = true
+		err := s.Application.Users().Save(s.Ctx, user.User())
+		require.NoError(t, err)
+		return user.IdentityID()
+	}
+
+	s.T().Run("should invite a person without account and accept once signed up", func(t *testing.T) {
+		// given
+		email := uuid.NewV4().String() + "@example.com"
+
+		// when
+		inv, messages := issue(t, email, false)
+
+		// then
+		require.Nil(t, inv.IdentityID)
+		require.Len(t, messages, 1)
+		require.Equal(t, "invitation.email", messages[0].MessageType)
+		require.Equal(t, email, messages[0].Custom["email"])
+		require.Contains(t, messages[0].Custom["signUpURL"], "/api/login")
+		require.Contains(t, messages[0].Custom["acceptURL"], inv.AcceptCode.String())
+
+		// the invitation cannot be accepted before signing up
+		_, _, err := s.Application.InvitationService().Accept(s.Ctx, inv.AcceptCode)
+		require.Error(t, err)
+		require.IsType(t, errors.UnauthorizedError{}, err)
+
+		// when the person signs up with the same email address
+		identityID := signUp(t, strings.ToUpper(email))
+		err = s.Application.InvitationService().ClaimInvitations(s.Ctx, identityID)
+
+		// then
+		require.NoError(t, err)
+		claimed, err := s.invitationRepo.Load(s.Ctx, inv.InvitationID)
+		require.NoError(t, err)
+		require.Equal(t, identityID, *claimed.IdentityID)
+		resourceID, _, err := s.Application.InvitationService().Accept(s.Ctx, inv.AcceptCode)
+		require.NoError(t, err)
+		require.Equal(t, space.SpaceID(), resourceID)
+	})
+
+	s.T().Run("should accept the invitation on registration", func(t *testing.T) {
+		// given
+		email := uuid.NewV4().String() + "@example.com"
+		inv, _ := issue(t, email, true)
+		identityID := signUp(t, email)
+
+		// when
+		err := s.Application.InvitationService().ClaimInvitations(s.Ctx, identityID)
+
+		// then
+		require.NoError(t, err)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesForIdentity(s.Ctx, identityID, nil)
+		require.NoError(t, err)
+		require.Len(t, roles, 1)
+		require.Equal(t, space.SpaceID(), roles[0].ResourceID)
+		_, err = s.invitationRepo.Load(s.Ctx, inv.InvitationID)
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("should address the invitation to the existing user with the verified email", func(t *testing.T) {
+		// given
+		email := uuid.NewV4().String() + "@example.com"
+		identityID := signUp(t, email)
+
+		// when
+		inv, messages := issue(t, email, false)
+
+		// then
+		require.NotNil(t, inv.IdentityID)
+		require.Equal(t, identityID, *inv.IdentityID)
+		require.Len(t, messages, 1)
+		require.Equal(t, "invitation.space.noorg", messages[0].MessageType)
+	})
+
+	s.T().Run("should fail to invite an invalid email address", func(t *testing.T) {
+		// when
+		err := s.Application.InvitationService().Issue(s.Ctx, inviter.IdentityID(), space.SpaceID(), []invitation.Invitation{
+			{
+				Email: "not-an-email",
+				Roles: []string{spaceRole.Role().Name},
+			},
+		})
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+func (s *invitationServiceBlackBoxTest) TestReceivedInvitations() {
+	// given
+	inviter := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(inviter)
+	spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+
+	var messages []notification.Message
+	*s.notificationServiceMock = *testservice.NewNotificationServiceMock(s.T())
+	s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
+		messages = msgs
+		return nil, nil
+	}
+	*s.witServiceMock = *test.NewWITMock(s.T(), inviter.IdentityID().String(), spaceName)
+
+	invite := func(t *testing.T) (uuid.UUID, uuid.UUID) {
+		inviteeID := s.Graph.CreateUser().IdentityID()
+		err := s.Application.InvitationService().Issue(s.Ctx, inviter.IdentityID(), space.SpaceID(), []invitation.Invitation{
+			{
+				IdentityID: &inviteeID,
+				Roles:      []string{spaceRole.Role().Name},
+			},
+		})
+		require.NoError(t, err)
+		invitations, err := s.Application.InvitationService().ListForInvitee(s.Ctx, inviteeID)
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		return inviteeID, invitations[0].InvitationID
+	}
+
+	s.T().Run("should list received invitations", func(t *testing.T) {
+		// given
+		inviteeID, invitationID := invite(t)
+
+		// when
+		invitations, err := s.Application.InvitationService().ListForInvitee(s.Ctx, inviteeID)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		require.Equal(t, invitationID, invitations[0].InvitationID)
+		require.Equal(t, space.SpaceID(), *invitations[0].ResourceID)
+		require.Equal(t, space.Resource().Name, invitations[0].Name)
+		require.Equal(t, []string{spaceRole.Role().Name}, invitations[0].Roles)
+	})
+
+	s.T().Run("should accept received invitation", func(t *testing.T) {
+		// given
+		inviteeID, invitationID := invite(t)
+
+		// when
+		resourceID, err := s.Application.InvitationService().AcceptByID(s.Ctx, inviteeID, invitationID)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, space.SpaceID(), resourceID)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesForIdentity(s.Ctx, inviteeID, nil)
+		require.NoError(t, err)
+		require.Len(t, roles, 1)
+		_, err = s.invitationRepo.Load(s.Ctx, invitationID)
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("should fail to accept or decline invitation addressed to another user", func(t *testing.T) {
+		// given
+		_, invitationID := invite(t)
+		other := s.Graph.CreateUser().IdentityID()
+
+		// when
+		_, err := s.Application.InvitationService().AcceptByID(s.Ctx, other, invitationID)
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, err)
+		err = s.Application.InvitationService().Decline(s.Ctx, other, invitationID)
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, err)
+		_, err = s.invitationRepo.Load(s.Ctx, invitationID)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("should decline received invitation", func(t *testing.T) {
+		// given
+		inviteeID, invitationID := invite(t)
+		messages = nil
+
+		// when
+		err := s.Application.InvitationService().Decline(s.Ctx, inviteeID, invitationID)
+
+		// then
+		require.NoError(t, err)
+		_, err = s.invitationRepo.Load(s.Ctx, invitationID)
+		require.IsType(t, errors.NotFoundError{}, err)
+		roles, err := s.invitationRepo.ListRoles(s.Ctx, invitationID)
+		require.NoError(t, err)
+		require.Empty(t, roles)
+		require.Len(t, messages, 1)
+		require.Equal(t, "invitation.declined", messages[0].MessageType)
+		require.Equal(t, inviter.IdentityID().String(), *messages[0].UserID)
+		require.Equal(t, space.SpaceID(), messages[0].TargetID)
+	})
+}
+
+func (s *invitationServiceBlackBoxTest) TestBulkImport() {
+	// given
+	inviter := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(inviter)
+	spaceRole := s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.ResourceTypeSpace))
+
+	var batches [][]notification.Message
+	*s.notificationServiceMock = *testservice.NewNotificationServiceMock(s.T())
+	s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
+		batches = append(batches, msgs)
+		return nil, nil
+	}
+	*s.witServiceMock = *test.NewWITMock(s.T(), inviter.IdentityID().String(), spaceName)
+
+	statuses := func(t *testing.T, imp *invitationrepo.InvitationImport) []string {
+		require.NotNil(t, imp.Report)
+		var results []invitation.ImportRowResult
+		require.NoError(t, json.Unmarshal([]byte(*imp.Report), &results))
+		var result []string
+		for i, r := range results {
+			require.Equal(t, i+1, r.Row)
+			result = append(result, r.Status)
+		}
+		return result
+	}
+
+	s.T().Run("best effort issues the valid rows", func(t *testing.T) {
+		// given
+		invitee := s.Graph.CreateUser()
+		other := s.Graph.CreateUser()
+		batches = nil
+		rows, err := invitation.ParseImportCSV(strings.NewReader(fmt.Sprintf("username,email,target,roles\n"+
+			"%[1]s,,%[3]s,%[4]s\n"+
+			",import-%[5]s@example.com,%[3]s,%[4]s\n"+
+			"unknown-%[5]s,,%[3]s,%[4]s\n"+
+			"%[2]s,,%[3]s,unknown-role\n",
+			invitee.Identity().Username, other.Identity().Username, space.SpaceID(), spaceRole.Role().Name, uuid.NewV4())))
+		require.NoError(t, err)
+
+		// when
+		imp, err := s.Application.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeBestEffort, success, failure)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusCompleted, imp.Status)
+		require.Equal(t, []string{invitation.ImportRowIssued, invitation.ImportRowIssued, invitation.ImportRowInvalid, invitation.ImportRowInvalid}, statuses(t, imp))
+		invitations, err := s.Application.InvitationService().ListForInvitee(s.Ctx, invitee.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+		invitations, err = s.Application.InvitationService().ListForInvitee(s.Ctx, other.IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, invitations)
+		// the e-mails are queued, and sent by the worker
+		require.Empty(t, batches)
+		require.NotNil(t, imp.PendingEmails)
+
+		// when
+		err = s.Application.InvitationService().SendPendingEmails(s.Ctx)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		require.Len(t, batches[0], 2)
+		imp, err = s.Application.InvitationService().LoadImport(s.Ctx, inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(t, err)
+		require.Nil(t, imp.PendingEmails)
+		require.NotNil(t, imp.EmailsSentAt)
+	})
+
+	s.T().Run("organization target", func(t *testing.T) {
+		// given
+		org := s.Graph.CreateOrganization(inviter)
+		invitee := s.Graph.CreateUser()
+		batches = nil
+		rows := []invitation.ImportRow{{Username: invitee.Identity().Username, Target: org.OrganizationID().String(), Member: true}}
+
+		// when
+		imp, err := s.Application.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeAllOrNothing, "", "")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusCompleted, imp.Status)
+		require.Equal(t, []string{invitation.ImportRowIssued}, statuses(t, imp))
+		invitations, err := s.Application.InvitationService().ListForInvitee(s.Ctx, invitee.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, invitations, 1)
+
+		// when
+		err = s.Application.InvitationService().SendPendingEmails(s.Ctx)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		require.Len(t, batches[0], 1)
+		require.Equal(t, "invitation.org", batches[0][0].MessageType)
+		require.Equal(t, org.OrganizationName(), batches[0][0].Custom["organizationName"])
+	})
+
+	s.T().Run("all or nothing issues nothing if any row is invalid", func(t *testing.T) {
+		// given
+		invitee := s.Graph.CreateUser()
+		batches = nil
+		rows := []invitation.ImportRow{
+			{Username: invitee.Identity().Username, Target: space.SpaceID(), Roles: []string{spaceRole.Role().Name}},
+			{Username: invitee.Identity().Username, Target: space.SpaceID(), Member: true},
+		}
+
+		// when
+		imp, err := s.Application.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeAllOrNothing, "", "")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusFailed, imp.Status)
+		require.NotNil(t, imp.Error)
+		require.Equal(t, []string{invitation.ImportRowSkipped, invitation.ImportRowInvalid}, statuses(t, imp))
+		invitations, err := s.Application.InvitationService().ListForInvitee(s.Ctx, invitee.IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, invitations)
+		require.Empty(t, batches)
+	})
+
+	s.T().Run("e-mail batches wait for the delay", func(t *testing.T) {
+		// given
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_SIZE", "1")
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_DELAY_MILLIS", "3600000")
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithWITService(s.witServiceMock), factory.WithNotificationService(s.notificationServiceMock))
+		rows := []invitation.ImportRow{
+			{Username: s.Graph.CreateUser().Identity().Username, Target: space.SpaceID(), Roles: []string{spaceRole.Role().Name}},
+			{Username: s.Graph.CreateUser().Identity().Username, Target: space.SpaceID(), Roles: []string{spaceRole.Role().Name}},
+		}
+		imp, err := app.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeBestEffort, "", "")
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusCompleted, imp.Status)
+		batches = nil
+
+		// when
+		err = app.InvitationService().SendPendingEmails(s.Ctx)
+		require.NoError(t, err)
+		err = app.InvitationService().SendPendingEmails(s.Ctx)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, batches, 1)
+		imp, err = app.InvitationService().LoadImport(s.Ctx, inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(t, err)
+		require.NotNil(t, imp.PendingEmails)
+		// the remaining e-mail must not be sent by the next tests
+		imp.PendingEmails = nil
+		require.NoError(t, app.InvitationImportRepository().Save(s.Ctx, imp))
+	})
+
+	s.T().Run("large import is processed asynchronously", func(t *testing.T) {
+		// given
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_SYNC_LIMIT", "1")
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_SIZE", "1")
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_DELAY_MILLIS", "0")
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithWITService(s.witServiceMock), factory.WithNotificationService(s.notificationServiceMock))
+		first := s.Graph.CreateUser()
+		second := s.Graph.CreateUser()
+		batches = nil
+		rows := []invitation.ImportRow{
+			{Username: first.Identity().Username, Target: space.SpaceID(), Roles: []string{spaceRole.Role().Name}},
+			{Username: second.Identity().Username, Target: space.SpaceID(), Roles: []string{spaceRole.Role().Name}},
+		}
+
+		// when
+		imp, err := app.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeAllOrNothing, "", "")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusPending, imp.Status)
+		require.Nil(t, imp.Report)
+		require.Empty(t, batches)
+
+		// when
+		err = app.InvitationService().ProcessPendingImports(s.Ctx)
+
+		// then
+		require.NoError(t, err)
+		imp, err = app.InvitationService().LoadImport(s.Ctx, inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusCompleted, imp.Status)
+		require.NotNil(t, imp.CompletedAt)
+		require.Equal(t, []string{invitation.ImportRowIssued, invitation.ImportRowIssued}, statuses(t, imp))
+		require.Empty(t, batches)
+
+		// when the worker sends the queued e-mails, one batch at each cycle
+		err = app.InvitationService().SendPendingEmails(s.Ctx)
+		require.NoError(t, err)
+		err = app.InvitationService().SendPendingEmails(s.Ctx)
+		require.NoError(t, err)
+		err = app.InvitationService().SendPendingEmails(s.Ctx)
+		require.NoError(t, err)
+
+		// then
+		require.Len(t, batches, 2)
+		require.Len(t, batches[0], 1)
+		require.Len(t, batches[1], 1)
+		imp, err = app.InvitationService().LoadImport(s.Ctx, inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(t, err)
+		require.Nil(t, imp.PendingEmails)
+	})
+
+	s.T().Run("pending import is claimed once", func(t *testing.T) {
+		// given
+		s.OverrideConfig("AUTH_INVITATION_IMPORT_SYNC_LIMIT", "0")
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithWITService(s.witServiceMock), factory.WithNotificationService(s.notificationServiceMock))
+		rows := []invitation.ImportRow{{Username: s.Graph.CreateUser().Identity().Username, Target: space.SpaceID()}}
+		imp, err := app.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeBestEffort, "", "")
+		require.NoError(t, err)
+		require.Equal(t, invitationrepo.ImportStatusPending, imp.Status)
+
+		// when
+		claimed, err := app.InvitationImportRepository().ClaimPending(s.Ctx, 100)
+		require.NoError(t, err)
+		claimedAgain, err := app.InvitationImportRepository().ClaimPending(s.Ctx, 100)
+		require.NoError(t, err)
+
+		// then
+		claimedIDs := func(imports []invitationrepo.InvitationImport) []uuid.UUID {
+			ids := make([]uuid.UUID, len(imports))
+			for i, c := range imports {
+				require.Equal(t, invitationrepo.ImportStatusProcessing, c.Status)
+				ids[i] = c.InvitationImportID
+			}
+			return ids
+		}
+		require.Contains(t, claimedIDs(claimed), imp.InvitationImportID)
+		require.NotContains(t, claimedIDs(claimedAgain), imp.InvitationImportID)
+	})
+
+	s.T().Run("only the requester can see the import", func(t *testing.T) {
+		// given
+		rows := []invitation.ImportRow{{Username: s.Graph.CreateUser().Identity().Username, Target: space.SpaceID()}}
+		imp, err := s.Application.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeBestEffort, "", "")
+		require.NoError(t, err)
+
+		// when
+		_, err = s.Application.InvitationService().LoadImport(s.Ctx, s.Graph.CreateUser().IdentityID(), imp.InvitationImportID)
+
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, err)
+	})
+
+	s.T().Run("should fail with unknown mode", func(t *testing.T) {
+		rows := []invitation.ImportRow{{Username: s.Graph.CreateUser().Identity().Username, Target: space.SpaceID()}}
+		_, err := s.Application.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, "unknown", "", "")
+		require.Error(t, err)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+}
+
+// expireInvitation moves the expiry of the specified invitation to the past
+func (s *invitationServiceBlackBoxTest) expireInvitation(t *testing.T, invitationID uuid.UUID) {
+	err := s.DB.Model(&invitationrepo.Invitation{}).Where("invitation_id = ?", invitationID).
+		Update("expires_at", time.Now().Add(-time.Hour)).Error
+	require.NoError(t, err)
+}
+
 func redirectURL() *app.RedirectURL {
 	success := success
 	failure := failure
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+// InvitationImportWorker the interface for the Invitation Import Worker,
+// which takes care of processing the large bulk invitation imports and of sending their invitation e-mails in batches.
+type InvitationImportWorker interface {
+	Start(freq time.Duration)
+	Stop()
+}
+
+const (
+	// InvitationImport the name of the worker that processes the pending invitation imports.
+	// Also, the name of the lock used by this worker.
+	InvitationImport = "invitation-import"
+)
+
+// NewInvitationImportWorker returns a new InvitationImportWorker
+func NewInvitationImportWorker(ctx context.Context, app application.Application) InvitationImportWorker {
+	w := &invitationImportWorker{
+		worker.Worker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: worker.GetLockOwner(ctx),
+			Name:  InvitationImport,
+		},
+	}
+	w.Do = w.processInvitationImports
+	return w
+}
+
+type invitationImportWorker struct {
+	worker.Worker
+}
+
+func (w *invitationImportWorker) processInvitationImports() {
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "starting cycle of invitation imports processing")
+	err := w.App.InvitationService().ProcessPendingImports(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while processing the pending invitation imports")
+	}
+	err = w.App.InvitationService().SendPendingEmails(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while sending the pending invitation e-mails")
+	}
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "ending cycle of invitation imports processing")
+}
@@ -0,0 +1,139 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
+	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/invitation/worker"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	appservicemock "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+	baseworker "github.com/fabric8-services/fabric8-auth/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type InvitationImportWorkerTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestInvitationImportWorker(t *testing.T) {
+	suite.Run(t, &InvitationImportWorkerTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *InvitationImportWorkerTest) TestProcessImports() {
+	// given
+	s.OverrideConfig("AUTH_INVITATION_IMPORT_SYNC_LIMIT", "0")
+	s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_SIZE", "1")
+	s.OverrideConfig("AUTH_INVITATION_IMPORT_EMAIL_BATCH_DELAY_MILLIS", "0")
+	inviter := s.Graph.CreateUser()
+	org := s.Graph.CreateOrganization(inviter)
+
+	var notificationServiceMock *appservicemock.NotificationServiceMock
+	var app application.Application
+	var lock sync.Mutex
+	var sent []notification.Message
+	s.SetupSubtest = func() {
+		sent = nil
+		notificationServiceMock = appservicemock.NewNotificationServiceMock(s.T())
+		notificationServiceMock.SendMessagesAsyncFunc = func(ctx context.Context, msgs []notification.Message, options ...rest.HTTPClientOption) (r chan error, r1 error) {
+			lock.Lock()
+			defer lock.Unlock()
+			sent = append(sent, msgs...)
+			return nil, nil
+		}
+		app = gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithNotificationService(notificationServiceMock))
+	}
+
+	importRows := func() *invitationrepo.InvitationImport {
+		rows := []invitation.ImportRow{
+			{Username: s.Graph.CreateUser().Identity().Username, Target: org.OrganizationID().String(), Member: true},
+			{Username: s.Graph.CreateUser().Identity().Username, Target: org.OrganizationID().String(), Member: true},
+		}
+		imp, err := app.InvitationService().Import(s.Ctx, inviter.IdentityID(), rows, invitation.ImportModeAllOrNothing, "", "")
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), invitationrepo.ImportStatusPending, imp.Status)
+		return imp
+	}
+
+	s.Run("one worker", func() {
+		// given
+		imp := importRows()
+		// start the worker with a 50ms ticker
+		w := s.newInvitationImportWorker(context.Background(), "pod-a", app)
+		freq := time.Millisecond * 50
+		w.Start(freq)
+		// wait a few cycles before checking the results
+		time.Sleep(freq * 5)
+		w.Stop()
+		time.Sleep(freq * 10) // give workers some time to stop for good
+		// then the import is processed and its e-mails sent, one batch at each cycle
+		result, err := app.InvitationService().LoadImport(context.Background(), inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), invitationrepo.ImportStatusCompleted, result.Status)
+		assert.Nil(s.T(), result.PendingEmails)
+		assert.Len(s.T(), sent, 2)
+		assert.Equal(s.T(), uint64(2), notificationServiceMock.SendMessagesAsyncCounter)
+	})
+
+	s.Run("multiple workers but only one working", func() {
+		// given
+		imp := importRows()
+		// start the workers with a 50ms ticker
+		freq := time.Millisecond * 50
+		latch := sync.WaitGroup{}
+		latch.Add(1)
+		workers := []worker.InvitationImportWorker{}
+		for i := 1; i <= 5; i++ {
+			fmt.Printf("initializing worker %d...\n", i)
+			w := s.newInvitationImportWorker(context.Background(), fmt.Sprintf("pod-%d", i), app)
+			workers = append(workers, w)
+			go func(i int) {
+				// now, wait for latch to be released so that all workers start at the same time
+				fmt.Printf("worker %d now waiting to latch to start...\n", i)
+				latch.Wait()
+				w.Start(freq)
+			}(i)
+		}
+		latch.Done()
+		// wait a few cycles before checking the results
+		time.Sleep(freq * 5)
+		// now stop all workers
+		for _, w := range workers {
+			w.Stop()
+		}
+		time.Sleep(freq * 10) // give workers some time to stop for good
+		// then the import is processed and each e-mail is sent only once
+		result, err := app.InvitationService().LoadImport(context.Background(), inviter.IdentityID(), imp.InvitationImportID)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), invitationrepo.ImportStatusCompleted, result.Status)
+		assert.Nil(s.T(), result.PendingEmails)
+		assert.Len(s.T(), sent, 2)
+		// verify that the lock was released
+		l, err := s.Application.WorkerLockRepository().AcquireLock(context.Background(), "assert", worker.InvitationImport)
+		require.NoError(s.T(), err)
+		l.Close()
+	})
+}
+
+func (s *InvitationImportWorkerTest) newInvitationImportWorker(ctx context.Context, podname string, app application.Application) worker.InvitationImportWorker {
+	os.Setenv("AUTH_POD_NAME", podname)
+	config, err := configuration.GetConfigurationData()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), podname, config.GetPodName())
+	ctx = context.WithValue(ctx, baseworker.LockOwner, podname)
+	return worker.NewInvitationImportWorker(ctx, app)
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+// InvitationPurgeWorker the interface for the Invitation Purge Worker,
+// which takes care of deleting the invitations which can no longer be accepted.
+type InvitationPurgeWorker interface {
+	Start(freq time.Duration)
+	Stop()
+}
+
+const (
+	// InvitationPurge the name of the worker that deletes the expired invitations.
+	// Also, the name of the lock used by this worker.
+	InvitationPurge = "invitation-purge"
+)
+
+// NewInvitationPurgeWorker returns a new InvitationPurgeWorker
+func NewInvitationPurgeWorker(ctx context.Context, app application.Application) InvitationPurgeWorker {
+	w := &invitationPurgeWorker{
+		worker.Worker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: worker.GetLockOwner(ctx),
+			Name:  InvitationPurge,
+		},
+	}
+	w.Do = w.purgeInvitations
+	return w
+}
+
+type invitationPurgeWorker struct {
+	worker.Worker
+}
+
+func (w *invitationPurgeWorker) purgeInvitations() {
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "starting cycle of invitations purge")
+	err := w.App.InvitationService().DeleteExpired(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while deleting the expired invitations")
+	}
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "ending cycle of invitations purge")
+}
@@ -0,0 +1,63 @@
+package worker_test
+
+import (
+	"testing"
+	"time"
+
+	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/invitation/worker"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type invitationPurgeWorkerBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunInvitationPurgeWorkerBlackBoxTest(t *testing.T) {
+	suite.Run(t, &invitationPurgeWorkerBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *invitationPurgeWorkerBlackBoxTest) TestPurgeWorker() {
+	i1 := s.Graph.CreateInvitation().Invitation()
+	i2 := s.Graph.CreateInvitation().Invitation()
+	i3 := s.Graph.CreateInvitation().Invitation()
+
+	s.expireInvitation(i1.InvitationID)
+	s.expireInvitation(i2.InvitationID)
+
+	// Start the worker with a 50ms ticker
+	worker := worker.NewInvitationPurgeWorker(s.Ctx, s.Application)
+	worker.Start(time.Millisecond * 50)
+	defer worker.Stop()
+
+	for i := 0; i < 30; i++ {
+		time.Sleep(time.Millisecond * 100)
+
+		if !s.invitationExists(i1.InvitationID) && !s.invitationExists(i2.InvitationID) {
+			break
+		}
+	}
+
+	require.False(s.T(), s.invitationExists(i1.InvitationID))
+	require.False(s.T(), s.invitationExists(i2.InvitationID))
+	require.True(s.T(), s.invitationExists(i3.InvitationID))
+}
+
+func (s *invitationPurgeWorkerBlackBoxTest) expireInvitation(invitationID uuid.UUID) {
+	err := s.DB.Model(&invitationrepo.Invitation{}).Where("invitation_id = ?", invitationID).
+		Update("expires_at", time.Now().Add(-time.Hour)).Error
+	require.NoError(s.T(), err)
+}
+
+func (s *invitationPurgeWorkerBlackBoxTest) invitationExists(invitationID uuid.UUID) bool {
+	_, err := s.Application.InvitationRepository().Load(s.Ctx, invitationID)
+	if err != nil {
+		require.IsType(s.T(), errors.NotFoundError{}, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,2 @@
+// Package service provides the code which encapsulates business logic for managing the memberships of identities
+package service
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/errors"
+
+	"github.com/satori/go.uuid"
+)
+
+// MembershipServiceConfiguration represents the configuration options for the membership service
+type MembershipServiceConfiguration interface {
+	GetMembershipMaxDepth() int
+}
+
+// membershipServiceImpl is the default implementation of MembershipService. It is a private struct and should only be
+// instantiated via the NewMembershipService() function.
+type membershipServiceImpl struct {
+	base.BaseService
+	config MembershipServiceConfiguration
+}
+
+// NewMembershipService creates a new service.
+func NewMembershipService(context servicecontext.ServiceContext, config MembershipServiceConfiguration) service.MembershipService {
+	return &membershipServiceImpl{
+		BaseService: base.NewBaseService(context),
+		config:      config,
+	}
+}
+
+// AddMember adds the member to the members of the identity. Memberships may be nested, e.g. a team may be a member of
+// a security group, itself a member of an organization, but the membership is rejected if it would make the identity a
+// member of itself, or if it would create a chain of nested memberships longer than the configured maximum depth,
+// itself capped to authorization.MaxMembershipDepth. As the memberships are resolved transitively when the permissions
+// are computed, limiting the depth of the chains when they are created also bounds the cost of resolving them.
+func (s *membershipServiceImpl) AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error {
+	identity, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	member, err := s.Repositories().Identities().Load(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if !member.IsUser() {
+		identityType, err := s.resourceTypeName(ctx, identity)
+		if err != nil {
+			return err
+		}
+		memberType, err := s.resourceTypeName(ctx, member)
+		if err != nil {
+			return err
+		}
+		if !authorization.CanBeMemberOf(memberType, identityType) {
+			return errors.NewBadParameterErrorFromString("memberID", memberID, fmt.Sprintf("an identity of type '%s' cannot be a member of an identity of type '%s'", memberType, identityType))
+		}
+	}
+
+	// the checks and the addition are made while holding a lock on the memberships, otherwise concurrent additions,
+	// e.g. of A to B and of B to A, could each pass the checks and together create a cycle
+	return s.ExecuteInTransaction(func() error {
+		err := s.Repositories().Identities().LockMemberships(ctx)
+		if err != nil {
+			return err
+		}
+
+		// the repository rejects cycles too, but they are reported first as they would also make the chains too long
+		cycle, err := s.Repositories().Identities().IsTransitiveMember(ctx, identityID, memberID)
+		if err != nil {
+			return err
+		}
+		if cycle || identityID == memberID {
+			return errors.NewBadParameterErrorFromString("memberID", memberID, "the membership would create a cycle of memberships")
+		}
+
+		maxDepth := s.config.GetMembershipMaxDepth()
+		if maxDepth > authorization.MaxMembershipDepth {
+			maxDepth = authorization.MaxMembershipDepth
+		}
+
+		// the longest chain going through the new membership is made of the longest chain of memberships of the
+		// identity, the new membership itself and the longest chain of members of the member
+		membershipDepth, err := s.Repositories().Identities().FindMembershipDepth(ctx, identityID, maxDepth)
+		if err != nil {
+			return err
+		}
+		membersDepth, err := s.Repositories().Identities().FindMembersDepth(ctx, memberID, maxDepth)
+		if err != nil {
+			return err
+		}
+		if membershipDepth+1+membersDepth > maxDepth {
+			return errors.NewBadParameterErrorFromString("memberID", memberID, fmt.Sprintf("the membership would exceed the maximum depth of %d nested memberships", maxDepth))
+		}
+
+		return s.Repositories().Identities().AddMember(ctx, identityID, memberID)
+	})
+}
+
+// resourceTypeName returns the name of the type of the resource of the identity, or an empty string if the identity
+// has no resource
+func (s *membershipServiceImpl) resourceTypeName(ctx context.Context, identity *account.Identity) (string, error) {
+	if !identity.IdentityResourceID.Valid {
+		return "", nil
+	}
+	res, err := s.Repositories().ResourceRepository().Load(ctx, identity.IdentityResourceID.String)
+	if err != nil {
+		return "", err
+	}
+	return res.ResourceType.Name, nil
+}
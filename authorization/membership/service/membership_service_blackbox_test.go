@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type membershipServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunMembershipServiceBlackBoxTest(t *testing.T) {
+	suite.Run(t, &membershipServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *membershipServiceBlackBoxTest) TestNestedMemberships() {
+	// given
+	g := s.NewTestGraph(s.T())
+	creator := g.CreateUser()
+	user := g.CreateUser()
+	org := g.CreateOrganization(creator)
+	team := g.CreateTeam(g.CreateSpace().AddAdmin(creator))
+	membershipService := s.Application.MembershipService()
+
+	// a chain of 6 nested security groups, the first one being a member of the organization
+	groupIDs := make([]uuid.UUID, 6)
+	for i := range groupIDs {
+		id, err := s.Application.GroupService().CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), fmt.Sprintf("group-%d", i))
+		require.NoError(s.T(), err)
+		groupIDs[i] = *id
+	}
+
+	s.T().Run("deep hierarchy", func(t *testing.T) {
+		// given
+		scope := "scope-" + uuid.NewV4().String()
+		rt := g.CreateResourceType().AddScope(scope)
+		r := g.CreateRole(rt).AddScope(scope)
+		res := g.CreateResource(rt).AddRole(g.LoadIdentity(org.OrganizationID()), r)
+		// when
+		err := membershipService.AddMember(s.Ctx, org.OrganizationID(), groupIDs[0])
+		require.NoError(t, err)
+		for i := 1; i < len(groupIDs); i++ {
+			err = membershipService.AddMember(s.Ctx, groupIDs[i-1], groupIDs[i])
+			require.NoError(t, err)
+		}
+		err = membershipService.AddMember(s.Ctx, groupIDs[len(groupIDs)-1], team.TeamID())
+		require.NoError(t, err)
+		err = membershipService.AddMember(s.Ctx, team.TeamID(), user.IdentityID())
+		require.NoError(t, err)
+		// then the user is a member of the organization through 8 nested memberships
+		isMember, err := s.Application.Identities().IsTransitiveMember(s.Ctx, user.IdentityID(), org.OrganizationID())
+		require.NoError(t, err)
+		assert.True(t, isMember)
+		depth, err := s.Application.Identities().FindMembershipDepth(s.Ctx, user.IdentityID(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 8, depth)
+		depth, err = s.Application.Identities().FindMembersDepth(s.Ctx, org.OrganizationID(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 8, depth)
+		resourceType := authorization.IdentityResourceTypeOrganization
+		memberships, err := s.Application.Identities().FindIdentityMemberships(s.Ctx, user.IdentityID(), &resourceType)
+		require.NoError(t, err)
+		require.Len(t, memberships, 1)
+		assert.Equal(t, org.OrganizationID(), *memberships[0].IdentityID)
+		// and the role assigned to the organization is granted to the user
+		hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, user.IdentityID(), res.ResourceID(), scope)
+		require.NoError(t, err)
+		assert.True(t, hasScope)
+	})
+
+	s.T().Run("cycle", func(t *testing.T) {
+		// when
+		err := membershipService.AddMember(s.Ctx, groupIDs[len(groupIDs)-1], groupIDs[0])
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		isMember, err := s.Application.Identities().IsTransitiveMember(s.Ctx, groupIDs[0], groupIDs[len(groupIDs)-1])
+		require.NoError(t, err)
+		assert.False(t, isMember)
+	})
+
+	s.T().Run("concurrent cycle", func(t *testing.T) {
+		// given
+		first, err := s.Application.GroupService().CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), "group-"+uuid.NewV4().String())
+		require.NoError(t, err)
+		second, err := s.Application.GroupService().CreateGroup(s.Ctx, creator.IdentityID(), org.OrganizationID(), "group-"+uuid.NewV4().String())
+		require.NoError(t, err)
+		// when each group is concurrently added to the other one
+		results := make([]error, 2)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			results[0] = membershipService.AddMember(s.Ctx, *first, *second)
+		}()
+		go func() {
+			defer wg.Done()
+			results[1] = membershipService.AddMember(s.Ctx, *second, *first)
+		}()
+		wg.Wait()
+		// then only one of the memberships was added
+		if results[0] == nil {
+			require.Error(t, results[1])
+		} else {
+			require.NoError(t, results[1])
+		}
+		cycle, err := s.Application.Identities().IsTransitiveMember(s.Ctx, *first, *first)
+		require.NoError(t, err)
+		assert.False(t, cycle)
+	})
+
+	s.T().Run("invalid member type", func(t *testing.T) {
+		// an organization cannot be a member of a security group
+		err := membershipService.AddMember(s.Ctx, groupIDs[0], org.OrganizationID())
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		// and a team only has users as members
+		err = membershipService.AddMember(s.Ctx, team.TeamID(), groupIDs[0])
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("max depth", func(t *testing.T) {
+		// given
+		s.OverrideConfig("AUTH_MEMBERSHIP_MAX_DEPTH", "7")
+		app := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers)
+		// when the chain would be 8 memberships long
+		err := app.MembershipService().AddMember(s.Ctx, team.TeamID(), g.CreateUser().IdentityID())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		// while shorter chains are still accepted
+		err = app.MembershipService().AddMember(s.Ctx, groupIDs[0], g.CreateUser().IdentityID())
+		require.NoError(t, err)
+	})
+}
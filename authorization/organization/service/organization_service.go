@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
@@ -129,3 +130,484 @@ func (s *organizationServiceImpl) ListOrganizations(ctx context.Context, identit
 
 	return authorization.MergeAssociations(memberships, roles), nil
 }
+
+// ListMembers returns the members of an organization, i.e. the identities which are direct members of the organization
+// or which are assigned a role for it, along with the roles assigned for the organization. The current identity must
+// have the scope for viewing the members of the organization.
+func (s *organizationServiceImpl) ListMembers(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) ([]account.Identity, []role.IdentityRole, error) {
+	org, err := s.loadOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ViewOrganizationMembersScope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	members, err := s.Repositories().Identities().FindDirectMembers(ctx, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResource(ctx, org.ResourceID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Include the identities which are assigned a role for the organization without being direct members of it,
+	// such as the organization creator
+	listed := make(map[uuid.UUID]bool)
+	for _, member := range members {
+		listed[member.ID] = true
+	}
+	for _, ir := range identityRoles {
+		if !listed[ir.IdentityID] {
+			listed[ir.IdentityID] = true
+			members = append(members, ir.Identity)
+		}
+	}
+
+	return members, identityRoles, nil
+}
+
+// AddMember adds a user, a team or a security group to the members of an organization, unless it is already a member.
+// New members are assigned the contributor role of the organization. The current identity must have the scope for
+// managing the members of the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) AddMember(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		isMember, err := s.isDirectMember(ctx, organizationID, memberID)
+		if err != nil || isMember {
+			return err
+		}
+
+		err = s.Services().MembershipService().AddMember(ctx, organizationID, memberID)
+		if err != nil {
+			return err
+		}
+
+		assignedRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, org.ResourceID, memberID)
+		if err != nil || len(assignedRoles) > 0 {
+			return err
+		}
+		return s.assignRole(ctx, org, memberID, authorization.OrganizationContributorRole)
+	})
+}
+
+// RemoveMember removes an identity from the members of an organization, and revokes the roles assigned to it for the
+// organization. The last administrator of an organization cannot be removed. The current identity must have the scope
+// for managing the members of the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) RemoveMember(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		isMember, err := s.isDirectMember(ctx, organizationID, memberID)
+		if err != nil {
+			return err
+		}
+		assignedRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, org.ResourceID, memberID)
+		if err != nil {
+			return err
+		}
+		if !isMember && len(assignedRoles) == 0 {
+			return errors.NewNotFoundError("organization member", memberID.String())
+		}
+
+		err = s.requireAnotherAdmin(ctx, org, memberID)
+		if err != nil {
+			return err
+		}
+
+		for _, ir := range assignedRoles {
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, ir.IdentityRoleID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if isMember {
+			return s.Repositories().Identities().RemoveMember(ctx, organizationID, memberID)
+		}
+		return nil
+	})
+}
+
+// SetMemberRole changes the role of a member of an organization, which is either the admin or the contributor role.
+// The last administrator of an organization cannot be demoted. The current identity must have the scope for managing
+// the members of the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) SetMemberRole(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID, roleName string) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		if roleName != authorization.OrganizationAdminRole && roleName != authorization.OrganizationContributorRole {
+			return errors.NewBadParameterError("roleName", roleName).Expected(fmt.Sprintf("%s or %s", authorization.OrganizationAdminRole, authorization.OrganizationContributorRole))
+		}
+
+		return s.setMemberRole(ctx, organizationID, org, memberID, roleName)
+	})
+}
+
+// RenameOrganization changes the name of an organization. The current identity must have the scope for managing the
+// members of the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) RenameOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, organizationName string) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		if len(strings.TrimSpace(organizationName)) == 0 {
+			return errors.NewBadParameterErrorFromString("name", organizationName, "organization name cannot be empty")
+		}
+
+		org.Name = organizationName
+		return s.Repositories().ResourceRepository().Save(ctx, org)
+	})
+}
+
+// TransferOwnership makes another member of an organization its administrator in place of the current identity, which
+// must be an administrator of the organization and becomes a contributor.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) TransferOwnership(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID, newOwnerID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		admins, err := s.Repositories().IdentityRoleRepository().FindEffectiveIdentityRolesByResourceAndRoleName(ctx, org.ResourceID, authorization.OrganizationAdminRole)
+		if err != nil {
+			return err
+		}
+		isAdmin := false
+		for _, admin := range admins {
+			if admin.IdentityID == currentIdentity {
+				isAdmin = true
+			}
+		}
+		if !isAdmin {
+			return errors.NewForbiddenError("only an administrator of the organization may transfer its ownership")
+		}
+
+		if newOwnerID == currentIdentity {
+			return errors.NewBadParameterErrorFromString("newOwnerID", newOwnerID, "the identity already owns the organization")
+		}
+
+		// The new owner is promoted first, so that the organization keeps an administrator when the current
+		// identity is demoted
+		err = s.setMemberRole(ctx, organizationID, org, newOwnerID, authorization.OrganizationAdminRole)
+		if err != nil {
+			return err
+		}
+		err = s.setMemberRole(ctx, organizationID, org, currentIdentity, authorization.OrganizationContributorRole)
+		if err != nil {
+			return err
+		}
+
+		log.Info(ctx, map[string]interface{}{
+			"organization_id": organizationID.String(),
+			"new_owner_id":    newOwnerID.String(),
+		}, "organization ownership transferred")
+
+		return nil
+	})
+}
+
+// DeleteOrganization deletes an organization along with its child identity resources, such as its security groups.
+// The roles assigned to the organization and its child identities are revoked, their memberships are removed and
+// their pending invitations are deleted. The organization cannot be deleted while other resources, such as spaces,
+// are under it, since they would be deleted too. The current identity must have the scope for managing the members
+// of the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) DeleteOrganization(ctx context.Context, currentIdentity uuid.UUID, organizationID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, org.ResourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		err = s.cleanupResourceTree(ctx, org.ResourceID, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+
+		// Delete the organization resource, along with its child resources and their identities
+		err = s.Services().ResourceService().Delete(ctx, org.ResourceID)
+		if err != nil {
+			return err
+		}
+
+		log.Info(ctx, map[string]interface{}{
+			"organization_id": organizationID.String(),
+		}, "organization deleted")
+
+		return nil
+	})
+}
+
+// cleanupResourceTree removes the memberships, role assignments and invitations which refer to the identities of the
+// specified resource and of its descendants, so that the resources may be safely deleted. A data conflict error is
+// returned if any of the descendants is not the resource of an identity.
+func (s *organizationServiceImpl) cleanupResourceTree(ctx context.Context, resourceID string, visited map[string]bool) error {
+	visited[resourceID] = true
+	children, err := s.Repositories().ResourceRepository().LoadChildren(ctx, resourceID)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if visited[child.ResourceID] {
+			return errors.NewInternalErrorFromString(ctx, fmt.Sprintf("cycle resource references detected for resource %s with parent %s", child.ResourceID, resourceID))
+		}
+		if !isIdentityResourceType(child.ResourceType.Name) {
+			return errors.NewDataConflictError(fmt.Sprintf("the organization cannot be deleted while resource '%s' of type '%s' is under it", child.Name, child.ResourceType.Name))
+		}
+		err = s.cleanupResourceTree(ctx, child.ResourceID, visited)
+		if err != nil {
+			return err
+		}
+	}
+
+	invitations, err := s.Repositories().InvitationRepository().ListForResource(ctx, resourceID)
+	if err != nil {
+		return err
+	}
+	for _, inv := range invitations {
+		err = s.Repositories().InvitationRepository().Delete(ctx, inv.InvitationID)
+		if err != nil {
+			return err
+		}
+	}
+
+	identities, err := s.Repositories().Identities().Query(account.IdentityFilterByIdentityResourceID(resourceID))
+	if err != nil {
+		return err
+	}
+	for _, identity := range identities {
+		err = s.cleanupIdentity(ctx, identity.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanupIdentity revokes the roles assigned to an organization or group identity, removes its memberships and
+// deletes its pending invitations. The roles are revoked while the members are still known, so that their cached
+// privileges are flagged as stale.
+func (s *organizationServiceImpl) cleanupIdentity(ctx context.Context, identityID uuid.UUID) error {
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, ir := range identityRoles {
+		err = s.Repositories().IdentityRoleRepository().Delete(ctx, ir.IdentityRoleID)
+		if err != nil {
+			return err
+		}
+	}
+
+	members, err := s.Repositories().Identities().FindDirectMembers(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		err = s.Repositories().Identities().RemoveMember(ctx, identityID, member.ID)
+		if err != nil {
+			return err
+		}
+	}
+	memberships, err := s.Repositories().Identities().FindDirectMemberships(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, membership := range memberships {
+		err = s.Repositories().Identities().RemoveMember(ctx, membership.ID, identityID)
+		if err != nil {
+			return err
+		}
+	}
+
+	invitations, err := s.Repositories().InvitationRepository().ListForIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, inv := range invitations {
+		err = s.Repositories().InvitationRepository().Delete(ctx, inv.InvitationID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMemberRole replaces the roles assigned to a member of an organization with the specified role, making sure that
+// the organization keeps an administrator
+func (s *organizationServiceImpl) setMemberRole(ctx context.Context, organizationID uuid.UUID, org *resource.Resource, memberID uuid.UUID, roleName string) error {
+	isMember, err := s.isDirectMember(ctx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+	assignedRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, org.ResourceID, memberID)
+	if err != nil {
+		return err
+	}
+	if !isMember && len(assignedRoles) == 0 {
+		return errors.NewNotFoundError("organization member", memberID.String())
+	}
+
+	if roleName != authorization.OrganizationAdminRole {
+		err = s.requireAnotherAdmin(ctx, org, memberID)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := s.Repositories().RoleRepository().Lookup(ctx, roleName, authorization.IdentityResourceTypeOrganization)
+	if err != nil {
+		return err
+	}
+
+	alreadyAssigned := false
+	for _, ir := range assignedRoles {
+		if ir.RoleID == r.RoleID {
+			alreadyAssigned = true
+			continue
+		}
+		err = s.Repositories().IdentityRoleRepository().Delete(ctx, ir.IdentityRoleID)
+		if err != nil {
+			return err
+		}
+	}
+	if alreadyAssigned {
+		return nil
+	}
+	return s.assignRole(ctx, org, memberID, roleName)
+}
+
+// assignRole assigns the specified organization role to an identity
+func (s *organizationServiceImpl) assignRole(ctx context.Context, org *resource.Resource, identityID uuid.UUID, roleName string) error {
+	r, err := s.Repositories().RoleRepository().Lookup(ctx, roleName, authorization.IdentityResourceTypeOrganization)
+	if err != nil {
+		return errors.NewInternalErrorFromString(ctx, fmt.Sprintf("Error looking up %s role for 'identity/organization' resource type", roleName))
+	}
+
+	return s.Repositories().IdentityRoleRepository().Create(ctx, &role.IdentityRole{
+		IdentityID: identityID,
+		ResourceID: org.ResourceID,
+		RoleID:     r.RoleID,
+	})
+}
+
+// requireAnotherAdmin returns an error if the specified identity is the last administrator of the organization. Only
+// the admin role assignments which are currently effective are counted. It must be called in the transaction which
+// removes or demotes the identity, as it locks the role assignments of the organization until that transaction ends,
+// so that concurrent removals cannot each leave the other as the last administrator.
+func (s *organizationServiceImpl) requireAnotherAdmin(ctx context.Context, org *resource.Resource, identityID uuid.UUID) error {
+	err := s.Repositories().IdentityRoleRepository().LockResourceRoles(ctx, org.ResourceID)
+	if err != nil {
+		return err
+	}
+	admins, err := s.Repositories().IdentityRoleRepository().FindEffectiveIdentityRolesByResourceAndRoleName(ctx, org.ResourceID, authorization.OrganizationAdminRole)
+	if err != nil {
+		return err
+	}
+	isAdmin := false
+	otherAdmins := 0
+	for _, admin := range admins {
+		if admin.IdentityID == identityID {
+			isAdmin = true
+		} else {
+			otherAdmins++
+		}
+	}
+	if isAdmin && otherAdmins == 0 {
+		return errors.NewDataConflictError("the last administrator of the organization cannot be removed or demoted")
+	}
+	return nil
+}
+
+// loadOrganization returns the resource of the organization with the specified identity ID
+func (s *organizationServiceImpl) loadOrganization(ctx context.Context, organizationID uuid.UUID) (*resource.Resource, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !identity.IdentityResourceID.Valid {
+		return nil, errors.NewBadParameterErrorFromString("organizationID", organizationID, "identity is not an organization")
+	}
+
+	org, err := s.Repositories().ResourceRepository().Load(ctx, identity.IdentityResourceID.String)
+	if err != nil {
+		return nil, err
+	}
+	if org.ResourceType.Name != authorization.IdentityResourceTypeOrganization {
+		return nil, errors.NewBadParameterErrorFromString("organizationID", organizationID, "identity is not an organization")
+	}
+
+	return org, nil
+}
+
+// isDirectMember returns true if the member is a direct member of the organization
+func (s *organizationServiceImpl) isDirectMember(ctx context.Context, organizationID uuid.UUID, memberID uuid.UUID) (bool, error) {
+	members, err := s.Repositories().Identities().FindDirectMembers(ctx, organizationID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range members {
+		if m.ID == memberID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isIdentityResourceType returns true if the resources of the given type are the resources of identities, such as
+// organizations, teams and security groups
+func isIdentityResourceType(resourceTypeName string) bool {
+	switch resourceTypeName {
+	case authorization.IdentityResourceTypeOrganization, authorization.IdentityResourceTypeTeam,
+		authorization.IdentityResourceTypeGroup, authorization.IdentityResourceTypeUser:
+		return true
+	}
+	return false
+}
@@ -1,17 +1,22 @@
 package service_test
 
 import (
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/test"
 
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -105,6 +110,234 @@ func (s *organizationServiceBlackBoxTest) TestListOrganization() {
 	s.equalOrganization(*orgId2, orgName2, s.findOrganizationWithID(*orgId2, orgs))
 }
 
+func (s *organizationServiceBlackBoxTest) TestOrganizationMembershipAndLifecycle() {
+	// given
+	g := s.NewTestGraph(s.T())
+	creator := g.CreateUser()
+	org := g.CreateOrganization(creator)
+	user1 := g.CreateUser()
+	user2 := g.CreateUser()
+	outsider := g.CreateUser()
+
+	memberRoles := func(t *testing.T, currentIdentity uuid.UUID) map[uuid.UUID][]string {
+		members, identityRoles, err := s.orgService.ListMembers(s.Ctx, currentIdentity, org.OrganizationID())
+		require.NoError(t, err)
+		result := make(map[uuid.UUID][]string)
+		for _, member := range members {
+			result[member.ID] = []string{}
+		}
+		for _, ir := range identityRoles {
+			result[ir.IdentityID] = append(result[ir.IdentityID], ir.Role.Name)
+		}
+		return result
+	}
+
+	s.T().Run("members", func(t *testing.T) {
+		t.Run("add", func(t *testing.T) {
+			// when
+			err := s.orgService.AddMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), user1.IdentityID())
+			require.NoError(t, err)
+			err = s.orgService.AddMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), user2.IdentityID())
+			require.NoError(t, err)
+			// adding an existing member again has no effect
+			err = s.orgService.AddMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), user2.IdentityID())
+			require.NoError(t, err)
+			// then the new members are contributors, who may view the members
+			roles := memberRoles(t, user1.IdentityID())
+			require.Len(t, roles, 3)
+			assert.Equal(t, []string{authorization.OrganizationAdminRole}, roles[creator.IdentityID()])
+			assert.Equal(t, []string{authorization.OrganizationContributorRole}, roles[user1.IdentityID()])
+			assert.Equal(t, []string{authorization.OrganizationContributorRole}, roles[user2.IdentityID()])
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			err := s.orgService.AddMember(s.Ctx, outsider.IdentityID(), org.OrganizationID(), outsider.IdentityID())
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+			_, _, err = s.orgService.ListMembers(s.Ctx, outsider.IdentityID(), org.OrganizationID())
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+			err = s.orgService.AddMember(s.Ctx, user1.IdentityID(), org.OrganizationID(), outsider.IdentityID())
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown role", func(t *testing.T) {
+			err := s.orgService.SetMemberRole(s.Ctx, creator.IdentityID(), org.OrganizationID(), user1.IdentityID(), "owner")
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("change role", func(t *testing.T) {
+			// when
+			err := s.orgService.SetMemberRole(s.Ctx, creator.IdentityID(), org.OrganizationID(), user1.IdentityID(), authorization.OrganizationAdminRole)
+			require.NoError(t, err)
+			err = s.orgService.SetMemberRole(s.Ctx, user1.IdentityID(), org.OrganizationID(), creator.IdentityID(), authorization.OrganizationContributorRole)
+			require.NoError(t, err)
+			// then
+			roles := memberRoles(t, user1.IdentityID())
+			assert.Equal(t, []string{authorization.OrganizationContributorRole}, roles[creator.IdentityID()])
+			assert.Equal(t, []string{authorization.OrganizationAdminRole}, roles[user1.IdentityID()])
+		})
+
+		t.Run("last admin", func(t *testing.T) {
+			err := s.orgService.SetMemberRole(s.Ctx, user1.IdentityID(), org.OrganizationID(), user1.IdentityID(), authorization.OrganizationContributorRole)
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+			err = s.orgService.RemoveMember(s.Ctx, user1.IdentityID(), org.OrganizationID(), user1.IdentityID())
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("transfer ownership", func(t *testing.T) {
+		// when
+		err := s.orgService.TransferOwnership(s.Ctx, user1.IdentityID(), org.OrganizationID(), user2.IdentityID())
+		require.NoError(t, err)
+		// then
+		roles := memberRoles(t, user2.IdentityID())
+		assert.Equal(t, []string{authorization.OrganizationContributorRole}, roles[user1.IdentityID()])
+		assert.Equal(t, []string{authorization.OrganizationAdminRole}, roles[user2.IdentityID()])
+		// only an administrator may transfer the ownership
+		err = s.orgService.TransferOwnership(s.Ctx, user1.IdentityID(), org.OrganizationID(), user1.IdentityID())
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("rename", func(t *testing.T) {
+		// when
+		err := s.orgService.RenameOrganization(s.Ctx, user2.IdentityID(), org.OrganizationID(), "renamed-organization")
+		require.NoError(t, err)
+		// then
+		res, err := s.resourceRepo.Load(s.Ctx, org.ResourceID())
+		require.NoError(t, err)
+		assert.Equal(t, "renamed-organization", res.Name)
+	})
+
+	s.T().Run("remove member", func(t *testing.T) {
+		// when
+		err := s.orgService.RemoveMember(s.Ctx, user2.IdentityID(), org.OrganizationID(), user1.IdentityID())
+		require.NoError(t, err)
+		// then
+		roles := memberRoles(t, user2.IdentityID())
+		assert.NotContains(t, roles, user1.IdentityID())
+		err = s.orgService.RemoveMember(s.Ctx, user2.IdentityID(), org.OrganizationID(), user1.IdentityID())
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		groupID, err := s.Application.GroupService().CreateGroup(s.Ctx, user2.IdentityID(), org.OrganizationID(), "doomed-group")
+		require.NoError(t, err)
+		err = s.Application.GroupService().AddMember(s.Ctx, user2.IdentityID(), *groupID, creator.IdentityID())
+		require.NoError(t, err)
+		// when
+		err = s.orgService.DeleteOrganization(s.Ctx, user1.IdentityID(), org.OrganizationID())
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+
+		// a space under the organization prevents its deletion
+		spaceAdmin := g.CreateUser()
+		space := g.CreateSpace(org).AddAdmin(spaceAdmin)
+		err = s.orgService.DeleteOrganization(s.Ctx, user2.IdentityID(), org.OrganizationID())
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		_, err = s.resourceRepo.Load(s.Ctx, space.SpaceID())
+		require.NoError(t, err)
+		spaceRoles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, space.SpaceID(), spaceAdmin.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, spaceRoles, 1)
+		_, err = s.identityRepo.Load(s.Ctx, *groupID)
+		require.NoError(t, err)
+		err = s.Application.ResourceService().Delete(s.Ctx, space.SpaceID())
+		require.NoError(t, err)
+
+		err = s.orgService.DeleteOrganization(s.Ctx, user2.IdentityID(), org.OrganizationID())
+		require.NoError(t, err)
+		// then
+		_, err = s.identityRepo.Load(s.Ctx, org.OrganizationID())
+		require.Error(t, err)
+		_, err = s.identityRepo.Load(s.Ctx, *groupID)
+		require.Error(t, err)
+		memberships, err := s.identityRepo.FindDirectMemberships(s.Ctx, creator.IdentityID())
+		require.NoError(t, err)
+		assert.Empty(t, memberships)
+		orgs, err := s.orgService.ListOrganizations(s.Ctx, user2.IdentityID())
+		require.NoError(t, err)
+		assert.Nil(t, s.findOrganizationWithID(org.OrganizationID(), orgs))
+	})
+}
+
+func (s *organizationServiceBlackBoxTest) TestLastAdmin() {
+
+	s.T().Run("admin assignment which is not effective", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		creator := g.CreateUser()
+		org := g.CreateOrganization(creator)
+		adminRole, err := s.Application.RoleRepository().Lookup(s.Ctx, authorization.OrganizationAdminRole, authorization.IdentityResourceTypeOrganization)
+		require.NoError(t, err)
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		for _, validity := range []role.Validity{{ValidUntil: &past}, {ValidFrom: &future}} {
+			user := g.CreateUser()
+			err = s.orgService.AddMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), user.IdentityID())
+			require.NoError(t, err)
+			err = s.identityRoleRepo.Create(s.Ctx, &role.IdentityRole{
+				IdentityID: user.IdentityID(),
+				ResourceID: org.ResourceID(),
+				RoleID:     adminRole.RoleID,
+				ValidFrom:  validity.ValidFrom,
+				ValidUntil: validity.ValidUntil,
+			})
+			require.NoError(t, err)
+		}
+		// when
+		err = s.orgService.SetMemberRole(s.Ctx, creator.IdentityID(), org.OrganizationID(), creator.IdentityID(), authorization.OrganizationContributorRole)
+		// then the creator is still the last admin
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		err = s.orgService.RemoveMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), creator.IdentityID())
+		require.Error(t, err)
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+
+	s.T().Run("concurrent removals", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		creator := g.CreateUser()
+		org := g.CreateOrganization(creator)
+		other := g.CreateUser()
+		err := s.orgService.AddMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), other.IdentityID())
+		require.NoError(t, err)
+		err = s.orgService.SetMemberRole(s.Ctx, creator.IdentityID(), org.OrganizationID(), other.IdentityID(), authorization.OrganizationAdminRole)
+		require.NoError(t, err)
+		// when both admins concurrently leave the organization
+		results := make([]error, 2)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			results[0] = s.orgService.RemoveMember(s.Ctx, creator.IdentityID(), org.OrganizationID(), creator.IdentityID())
+		}()
+		go func() {
+			defer wg.Done()
+			results[1] = s.orgService.RemoveMember(s.Ctx, other.IdentityID(), org.OrganizationID(), other.IdentityID())
+		}()
+		wg.Wait()
+		// then only one of them left
+		if results[0] == nil {
+			require.Error(t, results[1])
+		} else {
+			require.NoError(t, results[1])
+		}
+		admins, err := s.identityRoleRepo.FindEffectiveIdentityRolesByResourceAndRoleName(s.Ctx, org.ResourceID(), authorization.OrganizationAdminRole)
+		require.NoError(t, err)
+		assert.Len(t, admins, 1)
+	})
+}
+
 func (s *organizationServiceBlackBoxTest) findOrganizationWithID(orgId uuid.UUID, orgs []authorization.IdentityAssociation) *authorization.IdentityAssociation {
 	for _, org := range orgs {
 		if *org.IdentityID == orgId {
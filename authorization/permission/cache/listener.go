@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"time"
+
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/lib/pq"
+)
+
+// InvalidationListener listens to the privilege cache invalidations published by all the replicas, and drops the
+// entries of the affected identities from the in-memory cache. All entries are dropped whenever the connection to
+// the DB is lost, and no entry is cached until the connection is re-established, since the invalidations published in
+// the meantime are not delivered.
+type InvalidationListener interface {
+	Start(pingInterval time.Duration)
+	Stop()
+}
+
+// NewInvalidationListener returns a new listener which opens its own connection to the DB with the given
+// connection string
+func NewInvalidationListener(connectionString string, cache *PrivilegeCache) InvalidationListener {
+	return &invalidationListener{
+		connectionString: connectionString,
+		cache:            cache,
+	}
+}
+
+type invalidationListener struct {
+	connectionString string
+	cache            *PrivilegeCache
+	listener         *pq.Listener
+	stopCh           chan bool
+}
+
+// Start starts listening to the invalidations, checking the connection to the DB at the given interval
+func (l *invalidationListener) Start(pingInterval time.Duration) {
+	l.cache.Suspend()
+	l.listener = pq.NewListener(l.connectionString, time.Second, time.Minute, l.onEvent)
+	err := l.listener.Listen(permission.PrivilegeCacheInvalidationChannel)
+	if err != nil {
+		// the listener keeps trying to connect in the background, and listens to the channel once connected
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "unable to listen to the privilege cache invalidations")
+	}
+	l.stopCh = make(chan bool, 1)
+
+	go l.listenLoop(pingInterval)
+}
+
+func (l *invalidationListener) listenLoop(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case notification := <-l.listener.Notify:
+			if notification == nil {
+				// the connection was re-established, see onEvent
+				continue
+			}
+			identityIDs, err := permission.ParsePrivilegeCacheInvalidation(notification.Extra)
+			if err != nil {
+				log.Error(nil, map[string]interface{}{
+					"err": err,
+				}, "invalid privilege cache invalidation, dropping all the cached privileges")
+				l.cache.Purge()
+				continue
+			}
+			l.cache.InvalidateIdentities(identityIDs...)
+		case <-ticker.C:
+			go l.listener.Ping()
+		case <-l.stopCh:
+			l.listener.Close()
+			return
+		}
+	}
+}
+
+func (l *invalidationListener) onEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+		log.Info(nil, nil, "listening to the privilege cache invalidations")
+		l.cache.Resume()
+	case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "unable to listen to the privilege cache invalidations")
+		l.cache.Suspend()
+	}
+}
+
+// Stop stops listening to the invalidations
+func (l *invalidationListener) Stop() {
+	if l.stopCh != nil {
+		l.stopCh <- true
+	}
+}
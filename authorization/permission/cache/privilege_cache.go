@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/satori/go.uuid"
+)
+
+var (
+	hitsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "auth",
+		Subsystem: "privilege_cache",
+		Name:      "hits_total",
+		Help:      "Number of privilege cache lookups served from memory.",
+	})
+	missesCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "auth",
+		Subsystem: "privilege_cache",
+		Name:      "misses_total",
+		Help:      "Number of privilege cache lookups which were not served from memory.",
+	})
+	invalidationsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "auth",
+		Subsystem: "privilege_cache",
+		Name:      "invalidations_total",
+		Help:      "Number of in-memory privilege cache entries dropped after a change of privileges.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(hitsCounter, missesCounter, invalidationsCounter)
+}
+
+// PrivilegeCache is a bounded, in-memory LRU cache of the privilege cache records stored in the DB. Entries are
+// evicted once they have been in the cache for longer than the TTL, when the DB record they were copied from expires,
+// or when the privileges of their identity change.
+type PrivilegeCache struct {
+	mu         sync.Mutex
+	size       int
+	ttl        time.Duration
+	lru        *list.List
+	entries    map[uuid.UUID]map[string]*list.Element // entries by identity ID and resource ID
+	generation uint64
+	suspended  bool
+}
+
+type entry struct {
+	record    permission.PrivilegeCache
+	expiresAt time.Time
+}
+
+// NewPrivilegeCache returns a new cache holding up to `size` entries for at most the given TTL
+func NewPrivilegeCache(size int, ttl time.Duration) *PrivilegeCache {
+	return &PrivilegeCache{
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: map[uuid.UUID]map[string]*list.Element{},
+	}
+}
+
+// Get returns a copy of the cached record for the given identity and resource, if any
+func (c *PrivilegeCache) Get(identityID uuid.UUID, resourceID string) (*permission.PrivilegeCache, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, found := c.entries[identityID][resourceID]
+	if !found || c.suspended {
+		missesCounter.Inc()
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(elem)
+		missesCounter.Inc()
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	hitsCounter.Inc()
+	record := e.record
+	return &record, true
+}
+
+// Generation returns the current generation of the cache, which changes every time entries are invalidated.
+// Callers must read it before loading a record from the DB, and pass it to Put, so that a record which was loaded
+// before an invalidation is not cached after it.
+func (c *PrivilegeCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Put caches a copy of the given record, unless entries were invalidated since the given generation or the record
+// is stale or expired. The least recently used entry is evicted if the cache is full.
+func (c *PrivilegeCache) Put(generation uint64, record permission.PrivilegeCache) {
+	now := time.Now()
+	if record.Stale || !record.ExpiryTime.After(now) {
+		return
+	}
+	expiresAt := now.Add(c.ttl)
+	if record.ExpiryTime.Before(expiresAt) {
+		expiresAt = record.ExpiryTime
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation || c.size <= 0 || c.suspended {
+		return
+	}
+	if elem, found := c.entries[record.IdentityID][record.ResourceID]; found {
+		elem.Value = &entry{record: record, expiresAt: expiresAt}
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	resources, found := c.entries[record.IdentityID]
+	if !found {
+		resources = map[string]*list.Element{}
+		c.entries[record.IdentityID] = resources
+	}
+	resources[record.ResourceID] = c.lru.PushFront(&entry{record: record, expiresAt: expiresAt})
+}
+
+// InvalidateIdentities drops all the entries of the given identities
+func (c *PrivilegeCache) InvalidateIdentities(identityIDs ...uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, identityID := range identityIDs {
+		for _, elem := range c.entries[identityID] {
+			c.remove(elem)
+			invalidationsCounter.Inc()
+		}
+	}
+}
+
+// Purge drops all the entries
+func (c *PrivilegeCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purge()
+}
+
+// purge drops all the entries. The lock must be held by the caller.
+func (c *PrivilegeCache) purge() {
+	c.generation++
+	invalidationsCounter.Add(float64(c.lru.Len()))
+	c.lru.Init()
+	c.entries = map[uuid.UUID]map[string]*list.Element{}
+}
+
+// Suspend drops all the entries and stops caching new ones until Resume is called, so that no entry is served while
+// the invalidations cannot be received
+func (c *PrivilegeCache) Suspend() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purge()
+	c.suspended = true
+}
+
+// Resume resumes caching after Suspend was called
+func (c *PrivilegeCache) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.suspended = false
+}
+
+// Len returns the number of entries in the cache
+func (c *PrivilegeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// remove removes the given element from the LRU list and from the entries. The lock must be held by the caller.
+func (c *PrivilegeCache) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	resources := c.entries[e.record.IdentityID]
+	delete(resources, e.record.ResourceID)
+	if len(resources) == 0 {
+		delete(c.entries, e.record.IdentityID)
+	}
+}
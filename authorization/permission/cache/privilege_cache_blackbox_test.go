@@ -0,0 +1,141 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authorization/permission/cache"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRecord(identityID uuid.UUID, resourceID string) permission.PrivilegeCache {
+	return permission.PrivilegeCache{
+		PrivilegeCacheID: uuid.NewV4(),
+		IdentityID:       identityID,
+		ResourceID:       resourceID,
+		Scopes:           "view,manage",
+		ExpiryTime:       time.Now().Add(time.Hour),
+	}
+}
+
+func TestPrivilegeCache(t *testing.T) {
+
+	t.Run("get after put", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		record := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		// when
+		c.Put(c.Generation(), record)
+		// then
+		cached, found := c.Get(record.IdentityID, record.ResourceID)
+		require.True(t, found)
+		assert.Equal(t, record.PrivilegeCacheID, cached.PrivilegeCacheID)
+		assert.Equal(t, []string{"view", "manage"}, cached.ScopesAsArray())
+		_, found = c.Get(record.IdentityID, uuid.NewV4().String())
+		assert.False(t, found)
+	})
+
+	t.Run("least recently used entry evicted", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(2, time.Minute)
+		first := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		second := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		third := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		c.Put(c.Generation(), first)
+		c.Put(c.Generation(), second)
+		_, found := c.Get(first.IdentityID, first.ResourceID)
+		require.True(t, found)
+		// when
+		c.Put(c.Generation(), third)
+		// then
+		assert.Equal(t, 2, c.Len())
+		_, found = c.Get(first.IdentityID, first.ResourceID)
+		assert.True(t, found)
+		_, found = c.Get(second.IdentityID, second.ResourceID)
+		assert.False(t, found)
+		_, found = c.Get(third.IdentityID, third.ResourceID)
+		assert.True(t, found)
+	})
+
+	t.Run("entry expired", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		record := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		record.ExpiryTime = time.Now().Add(50 * time.Millisecond)
+		c.Put(c.Generation(), record)
+		// when
+		time.Sleep(100 * time.Millisecond)
+		// then
+		_, found := c.Get(record.IdentityID, record.ResourceID)
+		assert.False(t, found)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("stale record not cached", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		record := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		record.Stale = true
+		// when
+		c.Put(c.Generation(), record)
+		// then
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("identities invalidated", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		identityID := uuid.NewV4()
+		first := newRecord(identityID, uuid.NewV4().String())
+		second := newRecord(identityID, uuid.NewV4().String())
+		other := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		c.Put(c.Generation(), first)
+		c.Put(c.Generation(), second)
+		c.Put(c.Generation(), other)
+		// when
+		c.InvalidateIdentities(identityID)
+		// then
+		assert.Equal(t, 1, c.Len())
+		_, found := c.Get(first.IdentityID, first.ResourceID)
+		assert.False(t, found)
+		_, found = c.Get(other.IdentityID, other.ResourceID)
+		assert.True(t, found)
+	})
+
+	t.Run("record loaded before invalidation not cached", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		record := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		generation := c.Generation()
+		c.InvalidateIdentities(record.IdentityID)
+		// when
+		c.Put(generation, record)
+		// then
+		_, found := c.Get(record.IdentityID, record.ResourceID)
+		assert.False(t, found)
+	})
+
+	t.Run("suspended", func(t *testing.T) {
+		// given
+		c := cache.NewPrivilegeCache(10, time.Minute)
+		record := newRecord(uuid.NewV4(), uuid.NewV4().String())
+		c.Put(c.Generation(), record)
+		// when
+		c.Suspend()
+		c.Put(c.Generation(), record)
+		// then
+		assert.Equal(t, 0, c.Len())
+		_, found := c.Get(record.IdentityID, record.ResourceID)
+		assert.False(t, found)
+		// and when
+		c.Resume()
+		c.Put(c.Generation(), record)
+		// then
+		_, found = c.Get(record.IdentityID, record.ResourceID)
+		assert.True(t, found)
+	})
+}
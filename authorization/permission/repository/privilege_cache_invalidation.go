@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// PrivilegeCacheInvalidationChannel is the Postgres notification channel on which the IDs of the identities whose
+// privilege cache records were flagged as stale are published, so that every replica can drop its in-memory copies
+const PrivilegeCacheInvalidationChannel = "privilege_cache_invalidation"
+
+// maxIdentitiesPerNotification limits the number of identity IDs in a single notification, as Postgres rejects
+// payloads longer than 8000 bytes
+const maxIdentitiesPerNotification = 200
+
+// FlagStaleAndNotify executes the given update query, which must flag privilege cache records as stale and return the
+// `identity_id` of each updated record, then publishes the IDs of the affected identities on the
+// PrivilegeCacheInvalidationChannel. Postgres only delivers the notifications once the current transaction is
+// committed, and discards them if it is rolled back.
+func FlagStaleAndNotify(ctx context.Context, db *gorm.DB, query string, values ...interface{}) error {
+	defer goa.MeasureSince([]string{"goa", "db", "privilege_cache", "FlagStaleAndNotify"}, time.Now())
+
+	rows, err := db.Raw(query, values...).Rows()
+	if err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+	defer rows.Close()
+
+	var rowsMarkedStale int64
+	identityIDs := []uuid.UUID{}
+	seen := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var identityID uuid.UUID
+		err = rows.Scan(&identityID)
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+		rowsMarkedStale++
+		if !seen[identityID] {
+			seen[identityID] = true
+			identityIDs = append(identityIDs, identityID)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.NewInternalError(ctx, err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"rows_marked_stale": rowsMarkedStale,
+	}, "Privilege cache rows marked stale")
+
+	return NotifyPrivilegeCacheInvalidation(ctx, db, identityIDs)
+}
+
+// NotifyPrivilegeCacheInvalidation publishes the given identity IDs on the PrivilegeCacheInvalidationChannel,
+// splitting them into as many notifications as needed
+func NotifyPrivilegeCacheInvalidation(ctx context.Context, db *gorm.DB, identityIDs []uuid.UUID) error {
+	for start := 0; start < len(identityIDs); start += maxIdentitiesPerNotification {
+		end := start + maxIdentitiesPerNotification
+		if end > len(identityIDs) {
+			end = len(identityIDs)
+		}
+		ids := make([]string, 0, end-start)
+		for _, identityID := range identityIDs[start:end] {
+			ids = append(ids, identityID.String())
+		}
+		err := db.Exec("SELECT pg_notify(?, ?)", PrivilegeCacheInvalidationChannel, strings.Join(ids, ",")).Error
+		if err != nil {
+			return errors.NewInternalError(ctx, err)
+		}
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identities": len(identityIDs),
+	}, "Privilege cache invalidation published")
+	return nil
+}
+
+// ParsePrivilegeCacheInvalidation returns the identity IDs carried by a notification received on the
+// PrivilegeCacheInvalidationChannel
+func ParsePrivilegeCacheInvalidation(payload string) ([]uuid.UUID, error) {
+	identityIDs := []uuid.UUID{}
+	if strings.TrimSpace(payload) == "" {
+		return identityIDs, nil
+	}
+	for _, id := range strings.Split(payload, ",") {
+		identityID, err := uuid.FromString(strings.TrimSpace(id))
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid identity ID in privilege cache invalidation: '%s'", id)
+		}
+		identityIDs = append(identityIDs, identityID)
+	}
+	return identityIDs, nil
+}
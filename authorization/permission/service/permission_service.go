@@ -3,11 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/satori/go.uuid"
 )
 
@@ -28,28 +36,355 @@ func NewPermissionService(context servicecontext.ServiceContext) service.Permiss
 // assigned a role that grants the specified scope.  It takes into account resource hierarchies, checking the roles of
 // parent and other ancestor resources, and also takes into account role mappings, which allow roles assigned for a
 // certain type of resource in the resource ancestry to map to a role for a different resource type lower in the
-// resource hierarchy.
+// resource hierarchy.  If the resource type or the scope require a minimum authentication context class, then the
+// scope is only granted if the token of the current request is of that class or stronger.
 func (s *permissionServiceImpl) HasScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) (bool, error) {
-
-	identityRoles, err := s.Repositories().IdentityRoleRepository().FindPermissions(ctx, identityID, resourceID, scopeName)
+	granted, requiredACR, err := s.hasScope(ctx, identityID, resourceID, scopeName)
 	if err != nil {
 		return false, err
 	}
+	return granted && requiredACR == "", nil
+}
+
+// MaxPermissionChecks is the maximum number of permission checks which can be done at once with HasScopes
+const MaxPermissionChecks = 500
+
+// HasScopes does the same permission checks as HasScope for many resources and scopes at once, with a single query
+// against the database. It returns whether the identity has each of the given scopes for the corresponding resource.
+// Checks of unknown resources are not granted, and neither are those which require a stronger authentication than
+// the one of the token of the current request.
+func (s *permissionServiceImpl) HasScopes(ctx context.Context, identityID uuid.UUID, checks []rolerepo.PermissionCheck) (map[rolerepo.PermissionCheck]bool, error) {
+	if len(checks) > MaxPermissionChecks {
+		return nil, errors.NewBadParameterError("checks", len(checks)).Expected(fmt.Sprintf("at most %d checks", MaxPermissionChecks))
+	}
+	result := make(map[rolerepo.PermissionCheck]bool, len(checks))
+	for _, check := range checks {
+		result[check] = false
+	}
 
-	return len(identityRoles) > 0, nil
+	granted, err := s.Repositories().IdentityRoleRepository().FindGrantedPermissions(ctx, identityID, checks)
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	acr := manager.ContextAuthenticationContextClass(ctx)
+	for _, permission := range granted {
+		if permission.ResourceTypeMinACR != nil && !mfa.ACRSatisfies(acr, *permission.ResourceTypeMinACR) {
+			continue
+		}
+		if permission.ScopeMinACR != nil && !mfa.ACRSatisfies(acr, *permission.ScopeMinACR) {
+			continue
+		}
+		result[permission.PermissionCheck] = true
+	}
+	return result, nil
 }
 
 // RequireScope is the same as HasScope, except instead of returning a boolean value it will just return an error if the
-// identity does not have the specified scope for the resource
+// identity does not have the specified scope for the resource.  If the identity has the scope but the token of the
+// current request is not of the required authentication context class then an InsufficientAuthenticationError is
+// returned, so that the client can ask the user to step up.
 func (s *permissionServiceImpl) RequireScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) error {
-	result, err := s.HasScope(ctx, identityID, resourceID, scopeName)
+	granted, requiredACR, err := s.hasScope(ctx, identityID, resourceID, scopeName)
 	if err != nil {
 		return errors.NewInternalError(ctx, err)
 	}
 
-	if !result {
+	if !granted {
 		return errors.NewForbiddenError(fmt.Sprintf("identity with ID %s does not have required scope %s for resource %s", identityID.String(), scopeName, resourceID))
 	}
 
+	if requiredACR != "" {
+		return errors.NewInsufficientAuthenticationError(fmt.Sprintf("scope %s for resource %s requires a stronger authentication", scopeName, resourceID), requiredACR)
+	}
+
 	return nil
 }
+
+// hasScope returns true if the identity was granted the scope for the resource. If it was, it also returns the
+// authentication context class which the token of the current request lacks, or an empty string if it is sufficient.
+func (s *permissionServiceImpl) hasScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) (bool, string, error) {
+
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindPermissions(ctx, identityID, resourceID, scopeName)
+	if err != nil {
+		return false, "", err
+	}
+	if len(identityRoles) == 0 {
+		return false, "", nil
+	}
+
+	requiredACR, err := s.requiredACR(ctx, resourceID, scopeName)
+	if err != nil {
+		return false, "", err
+	}
+	if requiredACR == "" || mfa.ACRSatisfies(manager.ContextAuthenticationContextClass(ctx), requiredACR) {
+		return true, "", nil
+	}
+	return true, requiredACR, nil
+}
+
+// requiredACR returns the strongest of the minimum authentication context classes of the resource type and of the
+// scope, or an empty string if neither requires one
+func (s *permissionServiceImpl) requiredACR(ctx context.Context, resourceID string, scopeName string) (string, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return "", err
+	}
+	requiredACR := ""
+	if res.ResourceType.MinACR != nil {
+		requiredACR = *res.ResourceType.MinACR
+	}
+	scope, err := s.Repositories().ResourceTypeScopeRepository().LookupByResourceTypeAndScope(ctx, res.ResourceTypeID, scopeName)
+	if err != nil {
+		return "", err
+	}
+	if scope != nil && scope.MinACR != nil && !mfa.ACRSatisfies(requiredACR, *scope.MinACR) {
+		requiredACR = *scope.MinACR
+	}
+	return requiredACR, nil
+}
+
+// Explain explains whether an identity has a scope for a resource, for the user administrators who need to understand
+// why it was, or was not, granted. It returns every path through which a role granting the scope reaches the identity:
+// the identity role itself, the chain of memberships from the identity to the identity the role is assigned to, the
+// chain of parent resources from the resource the role is assigned for to the given resource, and the role mappings
+// applied to map the assigned role to a role granting the scope. The paths which do not grant the scope, because the
+// identity role is expired or not effective yet, or because of a deny assignment, are returned along with the reason.
+// The current identity must have the `manage_user` scope of a system resource.
+func (s *permissionServiceImpl) Explain(ctx context.Context, currentIdentity uuid.UUID, identityID uuid.UUID, resourceID string, scopeName string) (*rolerepo.PermissionExplanation, error) {
+	err := s.RequireUserAdmin(ctx, currentIdentity)
+	if err != nil {
+		return nil, err
+	}
+	return s.FindGrantPaths(ctx, identityID, resourceID, scopeName)
+}
+
+// FindGrantPaths returns the same explanation as Explain, without checking the privileges of the current identity. It
+// is meant for the services which already checked that the current identity may see the permissions of the identity.
+func (s *permissionServiceImpl) FindGrantPaths(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) (*rolerepo.PermissionExplanation, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, identityID, account.IdentityWithResource())
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the resource followed by its ancestors, up to the root resource
+	resources := []resource.Resource{*res}
+	for current := res; current.ParentResourceID != nil; {
+		current, err = s.Repositories().ResourceRepository().Load(ctx, *current.ParentResourceID)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, *current)
+	}
+
+	// the shortest membership chain from the identity to each of the identities it is a member of, directly or not,
+	// following at most the maximum number of nested memberships, like the permission checks do
+	identityIDs := []uuid.UUID{identityID}
+	membershipChains := map[uuid.UUID][]rolerepo.PathNode{
+		identityID: {identityPathNode(*identity)},
+	}
+	for i := 0; i < len(identityIDs); i++ {
+		// the chain starts with the identity itself, then has a node for each membership
+		if len(membershipChains[identityIDs[i]])-1 >= authorization.MaxMembershipDepth {
+			continue
+		}
+		memberships, err := s.Repositories().Identities().FindDirectMemberships(ctx, identityIDs[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, membership := range memberships {
+			if _, found := membershipChains[membership.ID]; found {
+				continue
+			}
+			chain := make([]rolerepo.PathNode, 0, len(membershipChains[identityIDs[i]])+1)
+			chain = append(chain, membershipChains[identityIDs[i]]...)
+			membershipChains[membership.ID] = append(chain, identityPathNode(membership))
+			identityIDs = append(identityIDs, membership.ID)
+		}
+	}
+
+	// the role mappings of the resource and of its ancestors
+	var roleMappings []rolerepo.RoleMapping
+	for _, r := range resources {
+		mappings, err := s.Repositories().RoleMappingRepository().FindForResource(ctx, r.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		roleMappings = append(roleMappings, mappings...)
+	}
+
+	explanation := &rolerepo.PermissionExplanation{
+		PermissionCheck: rolerepo.PermissionCheck{
+			ResourceID: resourceID,
+			ScopeName:  scopeName,
+		},
+		IdentityID: identityID,
+		Paths:      []rolerepo.GrantPath{},
+		Denials:    []rolerepo.DenyAssignment{},
+	}
+	grantingRoles := map[uuid.UUID]bool{}
+	now := time.Now()
+	for _, id := range identityIDs {
+		for i, r := range resources {
+			identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, r.ResourceID, id)
+			if err != nil {
+				return nil, err
+			}
+			for _, identityRole := range identityRoles {
+				role, err := s.Repositories().RoleRepository().Load(ctx, identityRole.RoleID)
+				if err != nil {
+					return nil, err
+				}
+				identityRole.Role = *role
+
+				// a role of the type of the resource grants the scope directly, any other role only through role mappings
+				var mappingChains [][]rolerepo.RoleMapping
+				if uuid.Equal(role.ResourceTypeID, res.ResourceTypeID) {
+					granted, err := s.roleGrantsScope(ctx, *role, scopeName, grantingRoles)
+					if err != nil {
+						return nil, err
+					}
+					if granted {
+						mappingChains = append(mappingChains, []rolerepo.RoleMapping{})
+					}
+				}
+				chains, err := s.roleMappingChains(ctx, role.RoleID, scopeName, roleMappings, map[uuid.UUID]bool{role.RoleID: true}, grantingRoles)
+				if err != nil {
+					return nil, err
+				}
+				mappingChains = append(mappingChains, chains...)
+
+				blockedBy := ""
+				if identityRole.ValidFrom != nil && identityRole.ValidFrom.After(now) {
+					blockedBy = rolerepo.GrantPathBlockedByValidFrom
+				} else if identityRole.ValidUntil != nil && !identityRole.ValidUntil.After(now) {
+					blockedBy = rolerepo.GrantPathBlockedByExpiry
+				}
+				for _, mappingChain := range mappingChains {
+					explanation.Paths = append(explanation.Paths, rolerepo.GrantPath{
+						IdentityRole:    identityRole,
+						MembershipChain: membershipChains[id],
+						ResourceChain:   resourcePathNodes(resources[:i+1]),
+						RoleMappings:    mappingChain,
+						BlockedBy:       blockedBy,
+					})
+				}
+			}
+		}
+	}
+
+	// the deny assignments of the identity, or of any identity it is a member of, for the scope
+	denyAssignments, err := s.Repositories().DenyAssignmentRepository().FindByResource(ctx, resourceID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, denyAssignment := range denyAssignments {
+		if _, found := membershipChains[denyAssignment.IdentityID]; found && denyAssignment.Scope.Name == scopeName {
+			explanation.Denials = append(explanation.Denials, denyAssignment)
+		}
+	}
+
+	for i := range explanation.Paths {
+		if explanation.Paths[i].BlockedBy == "" && len(explanation.Denials) > 0 {
+			explanation.Paths[i].BlockedBy = rolerepo.GrantPathBlockedByDenyAssignment
+		}
+		if explanation.Paths[i].BlockedBy == "" {
+			explanation.Granted = true
+		}
+	}
+	return explanation, nil
+}
+
+// roleMappingChains returns the chains of role mappings which map the specified role to a role granting the scope.
+// The visited roles are skipped, so that cycles of role mappings are not followed.
+func (s *permissionServiceImpl) roleMappingChains(ctx context.Context, roleID uuid.UUID, scopeName string, roleMappings []rolerepo.RoleMapping, visited map[uuid.UUID]bool, grantingRoles map[uuid.UUID]bool) ([][]rolerepo.RoleMapping, error) {
+	var chains [][]rolerepo.RoleMapping
+	for _, roleMapping := range roleMappings {
+		if !uuid.Equal(roleMapping.FromRoleID, roleID) || visited[roleMapping.ToRoleID] {
+			continue
+		}
+		granted, err := s.roleGrantsScope(ctx, roleMapping.ToRole, scopeName, grantingRoles)
+		if err != nil {
+			return nil, err
+		}
+		if granted {
+			chains = append(chains, []rolerepo.RoleMapping{roleMapping})
+		}
+		visited[roleMapping.ToRoleID] = true
+		subChains, err := s.roleMappingChains(ctx, roleMapping.ToRoleID, scopeName, roleMappings, visited, grantingRoles)
+		delete(visited, roleMapping.ToRoleID)
+		if err != nil {
+			return nil, err
+		}
+		for _, subChain := range subChains {
+			chains = append(chains, append([]rolerepo.RoleMapping{roleMapping}, subChain...))
+		}
+	}
+	return chains, nil
+}
+
+// roleGrantsScope returns true if the role grants the scope, remembering the result for the next calls with the
+// same role in grantingRoles
+func (s *permissionServiceImpl) roleGrantsScope(ctx context.Context, role rolerepo.Role, scopeName string, grantingRoles map[uuid.UUID]bool) (bool, error) {
+	if granted, found := grantingRoles[role.RoleID]; found {
+		return granted, nil
+	}
+	scopes, err := s.Repositories().RoleRepository().ListScopes(ctx, &role)
+	if err != nil {
+		return false, err
+	}
+	granted := false
+	for _, scope := range scopes {
+		if scope.Name == scopeName {
+			granted = true
+			break
+		}
+	}
+	grantingRoles[role.RoleID] = granted
+	return granted, nil
+}
+
+// RequireUserAdmin returns a forbidden error unless the given identity has the `manage_user` scope of a system
+// resource, or an insufficient authentication error if the scope requires a second factor which the current token
+// was not issued with
+func (s *permissionServiceImpl) RequireUserAdmin(ctx context.Context, identityID uuid.UUID) error {
+	resourceIDs, err := s.Repositories().ResourceRepository().FindWithRoleByResourceTypeAndIdentity(ctx, authorization.ResourceTypeSystem, identityID)
+	if err != nil {
+		return err
+	}
+	for _, resourceID := range resourceIDs {
+		err = s.RequireScope(ctx, identityID, resourceID, authorization.ManageUserSystemScope)
+		if err == nil {
+			return nil
+		}
+		if insufficient, _ := errors.IsInsufficientAuthenticationError(err); insufficient {
+			return err
+		}
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "identity is not a user administrator")
+	return errors.NewForbiddenError("operation requires the '" + authorization.ManageUserSystemScope + "' scope")
+}
+
+// identityPathNode returns the grant path node of the identity, named after its resource for the teams, organizations
+// and security groups, and after its username for the users
+func identityPathNode(identity account.Identity) rolerepo.PathNode {
+	if identity.IdentityResourceID.Valid {
+		return rolerepo.PathNode{ID: identity.ID.String(), Name: identity.IdentityResource.Name}
+	}
+	return rolerepo.PathNode{ID: identity.ID.String(), Name: identity.Username}
+}
+
+// resourcePathNodes returns the grant path nodes of the given resources, each one being the parent of the previous
+// one, from the last to the first one
+func resourcePathNodes(resources []resource.Resource) []rolerepo.PathNode {
+	nodes := make([]rolerepo.PathNode, 0, len(resources))
+	for i := len(resources) - 1; i >= 0; i-- {
+		nodes = append(nodes, rolerepo.PathNode{ID: resources[i].ResourceID, Name: resources[i].Name})
+	}
+	return nodes
+}
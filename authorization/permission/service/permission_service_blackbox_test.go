@@ -1,10 +1,22 @@
 package service_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	permissionservice "github.com/fabric8-services/fabric8-auth/authorization/permission/service"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 
+	jwt "github.com/dgrijalva/jwt-go"
+	jwtgoa "github.com/goadesign/goa/middleware/security/jwt"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -356,3 +368,275 @@ func (s *PermissionServiceTestSuite) TestHasScope() {
 	})
 
 }
+
+func (s *PermissionServiceTestSuite) TestHasScopes() {
+
+	permissionService := s.Application.PermissionService()
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		identity := g.CreateIdentity()
+		org := g.CreateOrganization()
+		org.AddMember(identity)
+		parentResourceType := g.CreateResourceType()
+		parentRole := g.CreateRole(parentResourceType).AddScope("test-parent-scope")
+		childResourceType := g.CreateResourceType()
+		childRole := g.CreateRole(childResourceType).AddScope("test-child-scope")
+		// a role assigned directly to the user
+		directResource := g.CreateResource(parentResourceType).AddRole(identity, parentRole)
+		// a role assigned to the organization, which is mapped to the role of the child resource
+		parentResource := g.CreateResource(parentResourceType).AddRole(org, parentRole)
+		childResource := g.CreateResource(parentResource, childResourceType)
+		g.CreateRoleMapping(childResource, parentRole, childRole)
+		// a resource without any role
+		otherResource := g.CreateResource(parentResourceType)
+		checks := []rolerepo.PermissionCheck{
+			{ResourceID: directResource.ResourceID(), ScopeName: "test-parent-scope"},
+			{ResourceID: directResource.ResourceID(), ScopeName: "test-child-scope"},
+			{ResourceID: parentResource.ResourceID(), ScopeName: "test-parent-scope"},
+			{ResourceID: childResource.ResourceID(), ScopeName: "test-child-scope"},
+			{ResourceID: otherResource.ResourceID(), ScopeName: "test-parent-scope"},
+			{ResourceID: uuid.NewV4().String(), ScopeName: "test-parent-scope"},
+		}
+		// when
+		result, err := permissionService.HasScopes(s.Ctx, identity.ID(), checks)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, len(checks))
+		for i, expected := range []bool{true, false, true, true, false, false} {
+			assert.Equal(t, expected, result[checks[i]], "unexpected result for check %d", i)
+			// the results are the same as with single checks of known resources
+			if i < len(checks)-1 {
+				single, err := permissionService.HasScope(s.Ctx, identity.ID(), checks[i].ResourceID, checks[i].ScopeName)
+				require.NoError(t, err)
+				assert.Equal(t, single, result[checks[i]], "unexpected result for check %d", i)
+			}
+		}
+	})
+
+	s.T().Run("scope requires multi-factor", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		identity := g.CreateIdentity()
+		resourceType := g.CreateResourceType()
+		role := g.CreateRole(resourceType, "test-role").AddScope("test-scope").AddScope("other-scope")
+		resource := g.CreateResource(resourceType).AddRole(identity, role)
+		scope, err := s.Application.ResourceTypeScopeRepository().LookupByResourceTypeAndScope(s.Ctx, resourceType.ResourceType().ResourceTypeID, "test-scope")
+		require.NoError(t, err)
+		multiFactor := mfa.ACRMultiFactor
+		scope.MinACR = &multiFactor
+		require.NoError(t, s.Application.ResourceTypeScopeRepository().Save(s.Ctx, scope))
+		checks := []rolerepo.PermissionCheck{
+			{ResourceID: resource.ResourceID(), ScopeName: "test-scope"},
+			{ResourceID: resource.ResourceID(), ScopeName: "other-scope"},
+		}
+		// when
+		result, err := permissionService.HasScopes(jwtgoa.WithJWT(s.Ctx, &jwt.Token{Claims: jwt.MapClaims{"acr": mfa.ACRSingleFactor}}), identity.ID(), checks)
+		// then
+		require.NoError(t, err)
+		assert.False(t, result[checks[0]])
+		assert.True(t, result[checks[1]])
+	})
+
+	s.T().Run("too many checks", func(t *testing.T) {
+		// given
+		checks := make([]rolerepo.PermissionCheck, permissionservice.MaxPermissionChecks+1)
+		// when
+		_, err := permissionService.HasScopes(s.Ctx, uuid.NewV4(), checks)
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+func (s *PermissionServiceTestSuite) TestRequiredAuthenticationContextClass() {
+
+	permissionService := s.Application.PermissionService()
+	withACR := func(acr string) context.Context {
+		return jwtgoa.WithJWT(s.Ctx, &jwt.Token{Claims: jwt.MapClaims{"acr": acr}})
+	}
+	multiFactor := mfa.ACRMultiFactor
+
+	s.T().Run("resource type requires multi-factor", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		identity := g.CreateIdentity()
+		resourceType := g.CreateResourceType()
+		role := g.CreateRole(resourceType, "test-role").AddScope("test-scope")
+		resource := g.CreateResource(resourceType).AddRole(identity, role)
+		rt := resourceType.ResourceType()
+		rt.MinACR = &multiFactor
+		require.NoError(t, s.Application.ResourceTypeRepository().Save(s.Ctx, rt))
+
+		t.Run("single factor token", func(t *testing.T) {
+			// when
+			result, err := permissionService.HasScope(withACR(mfa.ACRSingleFactor), identity.ID(), resource.ResourceID(), "test-scope")
+			// then
+			require.NoError(t, err)
+			require.False(t, result)
+			err = permissionService.RequireScope(withACR(mfa.ACRSingleFactor), identity.ID(), resource.ResourceID(), "test-scope")
+			require.Error(t, err)
+			require.IsType(t, errors.InsufficientAuthenticationError{}, errs.Cause(err))
+			require.Equal(t, mfa.ACRMultiFactor, errs.Cause(err).(errors.InsufficientAuthenticationError).RequiredACR)
+		})
+
+		t.Run("multi-factor token", func(t *testing.T) {
+			// when
+			result, err := permissionService.HasScope(withACR(mfa.ACRMultiFactor), identity.ID(), resource.ResourceID(), "test-scope")
+			// then
+			require.NoError(t, err)
+			require.True(t, result)
+			require.NoError(t, permissionService.RequireScope(withACR(mfa.ACRMultiFactor), identity.ID(), resource.ResourceID(), "test-scope"))
+		})
+
+		t.Run("scope not granted", func(t *testing.T) {
+			// when
+			err := permissionService.RequireScope(withACR(mfa.ACRSingleFactor), g.CreateIdentity().ID(), resource.ResourceID(), "test-scope")
+			// then
+			require.Error(t, err)
+			require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("scope requires multi-factor", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		identity := g.CreateIdentity()
+		resourceType := g.CreateResourceType()
+		role := g.CreateRole(resourceType, "test-role").AddScope("test-scope").AddScope("other-scope")
+		resource := g.CreateResource(resourceType).AddRole(identity, role)
+		scope, err := s.Application.ResourceTypeScopeRepository().LookupByResourceTypeAndScope(s.Ctx, resourceType.ResourceType().ResourceTypeID, "test-scope")
+		require.NoError(t, err)
+		scope.MinACR = &multiFactor
+		require.NoError(t, s.Application.ResourceTypeScopeRepository().Save(s.Ctx, scope))
+		// when
+		err = permissionService.RequireScope(withACR(mfa.ACRSingleFactor), identity.ID(), resource.ResourceID(), "test-scope")
+		// then
+		require.Error(t, err)
+		require.IsType(t, errors.InsufficientAuthenticationError{}, errs.Cause(err))
+		// other scopes of the same resource type are not affected
+		require.NoError(t, permissionService.RequireScope(withACR(mfa.ACRSingleFactor), identity.ID(), resource.ResourceID(), "other-scope"))
+	})
+}
+
+func (s *PermissionServiceTestSuite) TestExplain() {
+	// given
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	g.CreateResource(g.LoadResourceType(authorization.ResourceTypeSystem)).AddRole(admin, g.RoleByNameAndResourceType(authorization.SystemUserAdminRole, authorization.ResourceTypeSystem))
+	adminCtx := jwtgoa.WithJWT(s.Ctx, &jwt.Token{Claims: jwt.MapClaims{"acr": mfa.ACRMultiFactor}})
+
+	user := g.CreateUser()
+	org := g.CreateOrganization().AddMember(user)
+	parentResourceType := g.CreateResourceType()
+	parentRole := g.CreateRole(parentResourceType, "test-parent-role").AddScope("test-parent-scope")
+	parentResource := g.CreateResource(parentResourceType).AddRole(org, parentRole)
+	childResourceType := g.CreateResourceType()
+	childRole := g.CreateRole(childResourceType, "test-child-role").AddScope("test-child-scope")
+	childResource := g.CreateResource(parentResource, childResourceType).AddRole(user, childRole)
+	g.CreateRoleMapping(childResource, parentRole, childRole)
+
+	s.T().Run("not a user admin", func(t *testing.T) {
+		// when
+		_, err := s.Application.PermissionService().Explain(adminCtx, user.IdentityID(), user.IdentityID(), childResource.ResourceID(), "test-child-scope")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("granted through direct role and mapped membership role", func(t *testing.T) {
+		// when
+		explanation, err := s.Application.PermissionService().Explain(adminCtx, admin.IdentityID(), user.IdentityID(), childResource.ResourceID(), "test-child-scope")
+		// then
+		require.NoError(t, err)
+		assert.True(t, explanation.Granted)
+		assert.Empty(t, explanation.Denials)
+		require.Len(t, explanation.Paths, 2)
+		direct := explanation.Paths[0]
+		assert.Equal(t, "test-child-role", direct.IdentityRole.Role.Name)
+		assert.Equal(t, []rolerepo.PathNode{{ID: user.IdentityID().String(), Name: user.Identity().Username}}, direct.MembershipChain)
+		assert.Equal(t, []rolerepo.PathNode{{ID: childResource.ResourceID(), Name: childResource.Resource().Name}}, direct.ResourceChain)
+		assert.Empty(t, direct.RoleMappings)
+		assert.Empty(t, direct.BlockedBy)
+		inherited := explanation.Paths[1]
+		assert.Equal(t, "test-parent-role", inherited.IdentityRole.Role.Name)
+		assert.Equal(t, []rolerepo.PathNode{
+			{ID: user.IdentityID().String(), Name: user.Identity().Username},
+			{ID: org.OrganizationID().String(), Name: org.OrganizationName()},
+		}, inherited.MembershipChain)
+		assert.Equal(t, []rolerepo.PathNode{
+			{ID: parentResource.ResourceID(), Name: parentResource.Resource().Name},
+			{ID: childResource.ResourceID(), Name: childResource.Resource().Name},
+		}, inherited.ResourceChain)
+		require.Len(t, inherited.RoleMappings, 1)
+		assert.Equal(t, parentRole.Role().RoleID, inherited.RoleMappings[0].FromRoleID)
+		assert.Equal(t, childRole.Role().RoleID, inherited.RoleMappings[0].ToRoleID)
+		assert.Empty(t, inherited.BlockedBy)
+	})
+
+	s.T().Run("blocked by expiry", func(t *testing.T) {
+		// given
+		identityRoles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, childResource.ResourceID(), user.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, identityRoles, 1)
+		yesterday := time.Now().Add(-24 * time.Hour)
+		identityRoles[0].ValidUntil = &yesterday
+		require.NoError(t, s.Application.IdentityRoleRepository().Save(s.Ctx, &identityRoles[0]))
+		// when
+		explanation, err := s.Application.PermissionService().Explain(adminCtx, admin.IdentityID(), user.IdentityID(), childResource.ResourceID(), "test-child-scope")
+		// then
+		require.NoError(t, err)
+		assert.True(t, explanation.Granted)
+		require.Len(t, explanation.Paths, 2)
+		assert.Equal(t, rolerepo.GrantPathBlockedByExpiry, explanation.Paths[0].BlockedBy)
+		assert.Empty(t, explanation.Paths[1].BlockedBy)
+	})
+
+	s.T().Run("blocked by deny assignment", func(t *testing.T) {
+		// given
+		scope, err := s.Application.ResourceTypeScopeRepository().LookupByResourceTypeAndScope(s.Ctx, childResourceType.ResourceType().ResourceTypeID, "test-child-scope")
+		require.NoError(t, err)
+		require.NotNil(t, scope)
+		err = s.Application.DenyAssignmentRepository().Create(s.Ctx, &rolerepo.DenyAssignment{
+			IdentityID: org.OrganizationID(),
+			ResourceID: parentResource.ResourceID(),
+			ScopeID:    scope.ResourceTypeScopeID,
+		})
+		require.NoError(t, err)
+		// when
+		explanation, err := s.Application.PermissionService().Explain(adminCtx, admin.IdentityID(), user.IdentityID(), childResource.ResourceID(), "test-child-scope")
+		// then
+		require.NoError(t, err)
+		assert.False(t, explanation.Granted)
+		require.Len(t, explanation.Denials, 1)
+		assert.Equal(t, org.OrganizationID(), explanation.Denials[0].IdentityID)
+		require.Len(t, explanation.Paths, 2)
+		assert.Equal(t, rolerepo.GrantPathBlockedByExpiry, explanation.Paths[0].BlockedBy)
+		assert.Equal(t, rolerepo.GrantPathBlockedByDenyAssignment, explanation.Paths[1].BlockedBy)
+	})
+
+	s.T().Run("nested memberships beyond the maximum depth", func(t *testing.T) {
+		// given a chain of nested organizations, one more than the maximum depth
+		member := g.CreateUser()
+		var orgIDs []uuid.UUID
+		var last interface{} = member
+		res := g.CreateResource(childResourceType)
+		for i := 1; i <= authorization.MaxMembershipDepth+1; i++ {
+			o := g.CreateOrganization().AddMember(last)
+			orgIDs = append(orgIDs, o.OrganizationID())
+			if i >= authorization.MaxMembershipDepth {
+				res.AddRole(o, childRole)
+			}
+			last = o
+		}
+		// when
+		explanation, err := s.Application.PermissionService().Explain(adminCtx, admin.IdentityID(), member.IdentityID(), res.ResourceID(), "test-child-scope")
+		// then only the role of the organization at the maximum depth is found
+		require.NoError(t, err)
+		require.Len(t, explanation.Paths, 1)
+		chain := explanation.Paths[0].MembershipChain
+		require.Len(t, chain, authorization.MaxMembershipDepth+1)
+		assert.Equal(t, orgIDs[authorization.MaxMembershipDepth-1].String(), chain[len(chain)-1].ID)
+	})
+}
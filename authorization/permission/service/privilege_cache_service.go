@@ -8,6 +8,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	permissioncache "github.com/fabric8-services/fabric8-auth/authorization/permission/cache"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/satori/go.uuid"
@@ -21,21 +22,45 @@ type PrivilegeCacheServiceConfiguration interface {
 // privilegeCacheServiceImpl is the implementation of the interface for PrivilegeCacheService
 type privilegeCacheServiceImpl struct {
 	base.BaseService
-	conf PrivilegeCacheServiceConfiguration
+	conf       PrivilegeCacheServiceConfiguration
+	localCache *permissioncache.PrivilegeCache
 }
 
-// NewPrivilegeCacheService creates a new service.
-func NewPrivilegeCacheService(context servicecontext.ServiceContext, config PrivilegeCacheServiceConfiguration) service.PrivilegeCacheService {
+// NewPrivilegeCacheService creates a new service. The given in-memory cache, if not nil, is used in front of the
+// privilege cache table.
+func NewPrivilegeCacheService(context servicecontext.ServiceContext, config PrivilegeCacheServiceConfiguration, localCache *permissioncache.PrivilegeCache) service.PrivilegeCacheService {
 	return &privilegeCacheServiceImpl{
 		BaseService: base.NewBaseService(context),
 		conf:        config,
+		localCache:  localCache,
 	}
 }
 
 // CachedPrivileges returns the cached privileges that an identity has for a specified resource.
+// The privileges are first looked up in the in-memory cache, then in the privilege cache table.
 // If there are no privileges cached, or the cached value is stale, the privileges will be re-calculated and
 // the cached value updated.
 func (s *privilegeCacheServiceImpl) CachedPrivileges(ctx context.Context, identityID uuid.UUID, resourceID string) (*permission.PrivilegeCache, error) {
+	if s.localCache == nil {
+		return s.loadPrivileges(ctx, identityID, resourceID)
+	}
+	if privilegeCache, found := s.localCache.Get(identityID, resourceID); found {
+		return privilegeCache, nil
+	}
+	// the generation must be read before loading the privileges, so that they are not cached if they are
+	// invalidated in the meantime
+	generation := s.localCache.Generation()
+	privilegeCache, err := s.loadPrivileges(ctx, identityID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	s.localCache.Put(generation, *privilegeCache)
+	return privilegeCache, nil
+}
+
+// loadPrivileges returns the privileges that an identity has for a specified resource from the privilege cache table,
+// re-calculating them if needed
+func (s *privilegeCacheServiceImpl) loadPrivileges(ctx context.Context, identityID uuid.UUID, resourceID string) (*permission.PrivilegeCache, error) {
 	nowTime := time.Now()
 
 	// Attempt to load the privilege cache record from the database
@@ -3,10 +3,14 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/repository/base"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -51,6 +55,12 @@ type GormResourceRepository struct {
 	resourceTypeRepo resourcetype.ResourceTypeRepository
 }
 
+// maxMembershipDepth is the maximum number of nested memberships followed by the recursive membership queries
+var maxMembershipDepth = strconv.Itoa(authorization.MaxMembershipDepth)
+
+// hierarchyLockID is the key of the advisory lock which serializes the changes of parent resources
+const hierarchyLockID = 4403
+
 // NewResourceRepository creates a new storage type.
 func NewResourceRepository(db *gorm.DB) ResourceRepository {
 	return &GormResourceRepository{db: db, resourceTypeRepo: resourcetype.NewResourceTypeRepository(db)}
@@ -61,10 +71,14 @@ type ResourceRepository interface {
 	base.Exister
 	Load(ctx context.Context, id string) (*Resource, error)
 	LoadChildren(ctx context.Context, id string) ([]Resource, error)
+	FindChildren(ctx context.Context, id string, offset int, limit int) ([]Resource, int, error)
 	Create(ctx context.Context, resource *Resource) error
 	Save(ctx context.Context, resource *Resource) error
 	Delete(ctx context.Context, id string) error
 	FindWithRoleByResourceTypeAndIdentity(ctx context.Context, resourceType string, identityID uuid.UUID) ([]string, error)
+	HasDescendantOfType(ctx context.Context, id string, resourceTypeID uuid.UUID) (bool, error)
+	FlagPrivilegeCacheStaleForHierarchyChange(ctx context.Context, id string) error
+	LockHierarchy(ctx context.Context) error
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -100,6 +114,25 @@ func (m *GormResourceRepository) LoadChildren(ctx context.Context, id string) ([
 	return rows, nil
 }
 
+// FindChildren returns a page of the direct children of the given resource, oldest first, along with the total number
+// of children
+func (m *GormResourceRepository) FindChildren(ctx context.Context, id string, offset int, limit int) ([]Resource, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "findChildren"}, time.Now())
+
+	var count int
+	err := m.db.Model(&Resource{}).Where("parent_resource_id = ?", id).Count(&count).Error
+	if err != nil {
+		return nil, 0, errs.WithStack(err)
+	}
+	var rows []Resource
+	err = m.db.Model(&Resource{}).Preload("ResourceType").Where("parent_resource_id = ?", id).
+		Order("created_at, resource_id").Offset(offset).Limit(limit).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, errs.WithStack(err)
+	}
+	return rows, count, nil
+}
+
 // CheckExists returns nil if the given ID exists otherwise returns an error
 func (m *GormResourceRepository) CheckExists(ctx context.Context, id string) error {
 	defer goa.MeasureSince([]string{"goa", "db", "resource", "exists"}, time.Now())
@@ -217,11 +250,11 @@ func (m *GormResourceRepository) FindWithRoleByResourceTypeAndIdentity(ctx conte
 		),
 		/* list the identities of the teams to which the current user belongs, plus herself's identity */
 		teams AS ( 
-			SELECT member_of as "id"
+			SELECT member_of as "id", 1 AS depth
 			FROM membership
 			WHERE member_id = $1 /* user's identity */
-			UNION SELECT m.member_of
-			FROM membership m INNER JOIN teams ON teams.id = m.member_id
+			UNION SELECT m.member_of, teams.depth + 1
+			FROM membership m INNER JOIN teams ON teams.id = m.member_id AND teams.depth < `+maxMembershipDepth+`
 		)
 
 		/* list the roles on resources of the given type when the user has a direct role */
@@ -294,3 +327,130 @@ func (m *GormResourceRepository) FindWithRoleByResourceTypeAndIdentity(ctx conte
 	}
 	return result, err
 }
+
+// HasDescendantOfType returns true if the given resource has any descendant (via the resource hierarchy) of the
+// given resource type
+func (m *GormResourceRepository) HasDescendantOfType(ctx context.Context, id string, resourceTypeID uuid.UUID) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "HasDescendantOfType"}, time.Now())
+
+	type Result struct {
+		Found bool
+	}
+
+	var result Result
+	err := m.db.Raw(`WITH RECURSIVE descendants AS (
+  SELECT
+    resource_id, resource_type_id
+  FROM
+    resource
+  WHERE
+    parent_resource_id = ? /* RESOURCE_ID */
+    AND deleted_at IS NULL
+  UNION SELECT
+    c.resource_id, c.resource_type_id
+  FROM
+    resource c INNER JOIN descendants d ON d.resource_id = c.parent_resource_id
+  WHERE
+    c.deleted_at IS NULL
+)
+SELECT EXISTS (SELECT 1 FROM descendants WHERE resource_type_id = ?) AS found`, id, resourceTypeID).Scan(&result).Error
+	if err != nil {
+		return false, errs.WithStack(err)
+	}
+	return result.Found, nil
+}
+
+// FlagPrivilegeCacheStaleForHierarchyChange executes two update queries; the first sets the stale flag to true for
+// all privilege cache records where the resource ID is equal to, or a descendent of (via the resource hierarchy) the
+// specified resource ID, as moving the resource to another parent changes the privileges inherited by any identity in
+// it. The second query updates the token table, setting the STALE flag of the token STATUS field to true, for all
+// token records that are mapped to the corresponding privilege cache records in the first query, via the
+// many-to-many TOKEN_PRIVILEGE table.
+// The IDs of the identities whose privilege cache records were flagged as stale are published when the transaction
+// is committed, so that the in-memory privilege caches of all the replicas are invalidated
+func (m *GormResourceRepository) FlagPrivilegeCacheStaleForHierarchyChange(ctx context.Context, id string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "FlagPrivilegeCacheStaleForHierarchyChange"}, time.Now())
+
+	err := permission.FlagStaleAndNotify(ctx, m.db, `WITH resource_hierarchy AS (
+	WITH RECURSIVE m AS (
+	  SELECT
+	    resource_id, parent_resource_id
+	  FROM
+	    resource
+	  WHERE
+	    resource_id = ? /* RESOURCE_ID */
+	  UNION SELECT
+	    p.resource_id, p.parent_resource_id
+	  FROM
+	    resource p INNER JOIN m ON m.resource_id = p.parent_resource_id
+	  )
+	  SELECT
+	    m.resource_id
+	  FROM
+	    m
+)
+UPDATE privilege_cache SET
+  STALE = true
+WHERE
+  resource_id IN (SELECT resource_id FROM resource_hierarchy)
+  AND deleted_at IS NULL
+RETURNING
+  identity_id
+  `, id)
+	if err != nil {
+		return err
+	}
+
+	result := m.db.Exec(`WITH resource_hierarchy AS (
+	WITH RECURSIVE m AS (
+	  SELECT
+	    resource_id, parent_resource_id
+	  FROM
+	    resource
+	  WHERE
+	    resource_id = ? /* RESOURCE_ID */
+	  UNION SELECT
+	    p.resource_id, p.parent_resource_id
+	  FROM
+	    resource p INNER JOIN m ON m.resource_id = p.parent_resource_id
+	  )
+	  SELECT
+	    m.resource_id
+	  FROM
+	    m
+)
+UPDATE token t SET
+  STATUS = STATUS | ? /* TOKEN_STATUS_STALE */
+FROM
+  token_privilege tp,
+  privilege_cache pc
+WHERE
+  t.token_id = tp.token_id
+  AND tp.privilege_cache_id = pc.privilege_cache_id
+  AND pc.resource_id IN (SELECT resource_id FROM resource_hierarchy)
+  AND pc.deleted_at IS NULL
+`, id, token.TOKEN_STATUS_STALE)
+
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"rows_marked_stale": result.RowsAffected,
+		"resource_id":       id,
+	}, "Token rows marked stale")
+
+	return nil
+}
+
+// LockHierarchy acquires a transaction level lock which serializes the changes of parent resources, so that the checks
+// made before moving a resource, e.g. the detection of cycles, take the resources moved concurrently into account.
+// The lock is released when the transaction is committed or rolled back.
+func (m *GormResourceRepository) LockHierarchy(ctx context.Context) error {
+	defer goa.MeasureSince([]string{"goa", "db", "resource", "LockHierarchy"}, time.Now())
+	err := m.db.Exec("SELECT pg_advisory_xact_lock(?)", hierarchyLockID).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authorization"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -206,3 +207,129 @@ func (s *resourceServiceImpl) Register(ctx context.Context, resourceTypeName str
 func (s *resourceServiceImpl) FindWithRoleByResourceTypeAndIdentity(ctx context.Context, resourceType string, identityID uuid.UUID) ([]string, error) {
 	return s.Repositories().ResourceRepository().FindWithRoleByResourceTypeAndIdentity(ctx, resourceType, identityID)
 }
+
+// ListChildren returns a page of the direct children of the specified resource, oldest first, along with the total
+// number of children. The current identity must have the scope for viewing the roles of the resource.
+func (s *resourceServiceImpl) ListChildren(ctx context.Context, currentIdentity uuid.UUID, resourceID string, offset int, limit int) ([]resource.Resource, int, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForViewingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.Repositories().ResourceRepository().FindChildren(ctx, resourceID, offset, limit)
+}
+
+// FindAncestors returns the ancestors of the specified resource, from the root resource down to its parent. The
+// current identity must have the scope for viewing the roles of the resource.
+func (s *resourceServiceImpl) FindAncestors(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]resource.Resource, error) {
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForViewingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return nil, err
+	}
+	ancestors, err := s.ancestors(ctx, *res)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+	return ancestors, nil
+}
+
+// Reparent moves the specified resource, along with all its descendants, to another parent resource. The current
+// identity must have the scope for managing the roles of both the current parent (or of the resource itself, if it has
+// no parent) and the new parent. The new parent can be neither the resource itself nor any of its descendants, and its
+// type must be an allowed parent type of the type of the resource, e.g. a space cannot be moved under a team. The
+// privileges cached for the resource and all its descendants are flagged as stale, along with the tokens which
+// include them, since the privileges inherited from the ancestors change.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *resourceServiceImpl) Reparent(ctx context.Context, currentIdentity uuid.UUID, resourceID string, parentResourceID string) error {
+	return s.ExecuteInTransaction(func() error {
+		// the resources moved concurrently must be taken into account by the detection of cycles
+		err := s.Repositories().ResourceRepository().LockHierarchy(ctx)
+		if err != nil {
+			return err
+		}
+		res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+		newParent, err := s.Repositories().ResourceRepository().Load(ctx, parentResourceID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewBadParameterErrorFromString("parent resource ID", parentResourceID, err.Error())
+			}
+			return err
+		}
+		if res.ParentResourceID != nil && *res.ParentResourceID == parentResourceID {
+			return nil
+		}
+		if !authorization.CanBeParentOf(newParent.ResourceType.Name, res.ResourceType.Name) {
+			return errors.NewBadParameterErrorFromString("parent resource ID", parentResourceID,
+				fmt.Sprintf("a resource of type '%s' cannot be the parent of a resource of type '%s'", newParent.ResourceType.Name, res.ResourceType.Name))
+		}
+
+		// the current parent, or the resource itself if it is a root resource
+		managed := res
+		if res.ParentResourceID != nil {
+			managed, err = s.Repositories().ResourceRepository().Load(ctx, *res.ParentResourceID)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, managed.ResourceID, authorization.ScopeForManagingRolesInResourceType(managed.ResourceType.Name))
+		if err != nil {
+			return err
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, newParent.ResourceID, authorization.ScopeForManagingRolesInResourceType(newParent.ResourceType.Name))
+		if err != nil {
+			return err
+		}
+
+		// the new parent must not be in the tree of the resource
+		ancestors, err := s.ancestors(ctx, *newParent)
+		if err != nil {
+			return err
+		}
+		for _, r := range append(ancestors, *newParent) {
+			if r.ResourceID == resourceID {
+				return errors.NewBadParameterError("parent resource ID", parentResourceID).Expected("a resource which is neither the resource itself nor one of its descendants")
+			}
+		}
+
+		res.ParentResourceID = &newParent.ResourceID
+		res.ParentResource = nil
+		err = s.Repositories().ResourceRepository().Save(ctx, res)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().ResourceRepository().FlagPrivilegeCacheStaleForHierarchyChange(ctx, resourceID)
+	})
+}
+
+// ancestors returns the ancestors of the given resource, from its parent up to the root resource
+func (s *resourceServiceImpl) ancestors(ctx context.Context, res resource.Resource) ([]resource.Resource, error) {
+	var ancestors []resource.Resource
+	// visited is used to make sure we don't have cycle resource references
+	visited := map[string]bool{res.ResourceID: true}
+	for current := &res; current.ParentResourceID != nil; {
+		if visited[*current.ParentResourceID] {
+			return nil, errors.NewInternalErrorFromString(ctx, fmt.Sprintf("cycle resource references detected for resource %s with parent %s", current.ResourceID, *current.ParentResourceID))
+		}
+		visited[*current.ParentResourceID] = true
+		parent, err := s.Repositories().ResourceRepository().Load(ctx, *current.ParentResourceID)
+		if err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, *parent)
+		current = parent
+	}
+	return ancestors, nil
+}
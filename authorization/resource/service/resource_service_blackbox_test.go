@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
@@ -251,6 +252,162 @@ func (s *resourceServiceBlackBoxTest) TestDeleteResourceWithCycleReferencesFails
 	testsupport.AssertError(s.T(), err, errors.InternalError{}, "cycle resource references detected for resource %s with parent %s", parent.ResourceID(), child.ResourceID())
 }
 
+func (s *resourceServiceBlackBoxTest) TestResourceHierarchy() {
+	// given
+	g := s.NewTestGraph(s.T())
+	rt := g.CreateResourceType()
+	managerRole := g.CreateRole(rt).AddScope(authorization.ManageRoleAssignmentsInSpaceScope).AddScope(authorization.ViewRoleAssignmentsInSpaceScope)
+	manager := g.CreateUser()
+	oldRoot := g.CreateResource(rt).AddRole(manager, managerRole)
+	newRoot := g.CreateResource(rt).AddRole(manager, managerRole)
+	child := g.CreateResource(oldRoot, rt)
+	g.CreateResource(oldRoot, rt)
+	g.CreateResource(oldRoot, rt)
+	grandchild := g.CreateResource(child, rt)
+
+	s.T().Run("list children", func(t *testing.T) {
+		t.Run("ok", func(t *testing.T) {
+			// when
+			children, count, err := s.resourceService.ListChildren(s.Ctx, manager.IdentityID(), oldRoot.ResourceID(), 0, 2)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+			require.Len(t, children, 2)
+			assert.Equal(t, child.ResourceID(), children[0].ResourceID)
+			assert.Equal(t, rt.Name(), children[0].ResourceType.Name)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// when
+			_, _, err := s.resourceService.ListChildren(s.Ctx, g.CreateUser().IdentityID(), oldRoot.ResourceID(), 0, 2)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("find ancestors", func(t *testing.T) {
+		// when
+		ancestors, err := s.resourceService.FindAncestors(s.Ctx, manager.IdentityID(), grandchild.ResourceID())
+		// then
+		require.NoError(t, err)
+		require.Len(t, ancestors, 2)
+		assert.Equal(t, oldRoot.ResourceID(), ancestors[0].ResourceID)
+		assert.Equal(t, child.ResourceID(), ancestors[1].ResourceID)
+	})
+
+	s.T().Run("reparent", func(t *testing.T) {
+		t.Run("cycle", func(t *testing.T) {
+			for _, parentID := range []string{child.ResourceID(), grandchild.ResourceID()} {
+				// when
+				err := s.resourceService.Reparent(s.Ctx, manager.IdentityID(), child.ResourceID(), parentID)
+				// then
+				require.Error(t, err)
+				assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			}
+		})
+
+		t.Run("parent of a type which is not allowed", func(t *testing.T) {
+			// given
+			space := g.CreateSpace().AddAdmin(manager)
+			team := g.CreateTeam(space)
+			// when
+			err := s.resourceService.Reparent(s.Ctx, manager.IdentityID(), space.SpaceID(), team.ResourceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			// when
+			err = s.resourceService.Reparent(s.Ctx, manager.IdentityID(), team.ResourceID(), g.CreateOrganization(manager).ResourceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("concurrent moves which would create a cycle", func(t *testing.T) {
+			// given
+			first := g.CreateResource(rt).AddRole(manager, managerRole)
+			second := g.CreateResource(rt).AddRole(manager, managerRole)
+			results := make([]error, 2)
+			var wg sync.WaitGroup
+			wg.Add(2)
+			// when
+			go func() {
+				defer wg.Done()
+				results[0] = s.resourceService.Reparent(s.Ctx, manager.IdentityID(), first.ResourceID(), second.ResourceID())
+			}()
+			go func() {
+				defer wg.Done()
+				results[1] = s.resourceService.Reparent(s.Ctx, manager.IdentityID(), second.ResourceID(), first.ResourceID())
+			}()
+			wg.Wait()
+			// then only one of the resources was moved under the other one
+			failed := 0
+			for _, err := range results {
+				if err != nil {
+					assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+					failed++
+				}
+			}
+			assert.Equal(t, 1, failed)
+		})
+
+		t.Run("unknown parent", func(t *testing.T) {
+			// when
+			err := s.resourceService.Reparent(s.Ctx, manager.IdentityID(), child.ResourceID(), uuid.NewV4().String())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("forbidden without manage scope on the current parent", func(t *testing.T) {
+			// given
+			other := g.CreateUser()
+			newRoot.AddRole(other, managerRole)
+			// when
+			err := s.resourceService.Reparent(s.Ctx, other.IdentityID(), child.ResourceID(), newRoot.ResourceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("forbidden without manage scope on the new parent", func(t *testing.T) {
+			// given
+			other := g.CreateUser()
+			oldRoot.AddRole(other, managerRole)
+			// when
+			err := s.resourceService.Reparent(s.Ctx, other.IdentityID(), child.ResourceID(), newRoot.ResourceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("ok", func(t *testing.T) {
+			// given
+			viewer := g.CreateUser()
+			oldRoot.AddRole(viewer, managerRole)
+			pc := g.CreatePrivilegeCache(viewer, grandchild, authorization.ViewRoleAssignmentsInSpaceScope)
+			// when
+			err := s.resourceService.Reparent(s.Ctx, manager.IdentityID(), child.ResourceID(), newRoot.ResourceID())
+			// then
+			require.NoError(t, err)
+			ancestors, err := s.resourceService.FindAncestors(s.Ctx, manager.IdentityID(), grandchild.ResourceID())
+			require.NoError(t, err)
+			require.Len(t, ancestors, 2)
+			assert.Equal(t, newRoot.ResourceID(), ancestors[0].ResourceID)
+			assert.Equal(t, child.ResourceID(), ancestors[1].ResourceID)
+			_, count, err := s.resourceService.ListChildren(s.Ctx, manager.IdentityID(), oldRoot.ResourceID(), 0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+			// the privileges cached for the descendants of the moved resource are stale
+			assert.True(t, g.LoadPrivilegeCache(pc.PrivilegeCache().PrivilegeCacheID).PrivilegeCache().Stale)
+			// and the access inherited from the old parent is gone
+			hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, viewer.IdentityID(), grandchild.ResourceID(), authorization.ViewRoleAssignmentsInSpaceScope)
+			require.NoError(t, err)
+			assert.False(t, hasScope)
+		})
+	})
+}
+
 func (s *resourceServiceBlackBoxTest) checkRoleMapping(shouldExist bool, roleMappingID uuid.UUID) {
 	err := s.Application.RoleMappingRepository().CheckExists(s.Ctx, roleMappingID)
 	if shouldExist {
@@ -22,6 +22,12 @@ type ResourceType struct {
 	Name string
 
 	DefaultRoleID *uuid.UUID `sql:"type:string" gorm:"column:default_role_id"`
+
+	// MinACR is the minimum authentication context class of the token required for any scope of this resource type
+	MinACR *string `gorm:"column:min_acr"`
+
+	// OwnerID is the ID of the identity which owns the resource type, and may manage its roles
+	OwnerID *uuid.UUID `sql:"type:uuid" gorm:"column:owner_id"`
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
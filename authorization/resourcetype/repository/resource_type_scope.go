@@ -26,6 +26,8 @@ type ResourceTypeScope struct {
 	ResourceTypeID uuid.UUID
 	// The name of this scope
 	Name string
+	// MinACR is the minimum authentication context class of the token required for this scope
+	MinACR *string `gorm:"column:min_acr"`
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -0,0 +1,25 @@
+package resourcetype
+
+// ResourceTypeDescriptor is a DTO used to pass the definition of a resource type, its scopes, roles and default role
+// mappings between the service layer and controller layer
+type ResourceTypeDescriptor struct {
+	Name                string
+	Scopes              []string
+	Roles               []RoleDescriptor
+	DefaultRoleName     *string
+	DefaultRoleMappings []DefaultRoleMappingDescriptor
+}
+
+// RoleDescriptor is a DTO used to pass the definition of a role of a resource type
+type RoleDescriptor struct {
+	Name   string
+	Scopes []string
+}
+
+// DefaultRoleMappingDescriptor is a DTO used to pass the definition of a default role mapping of a resource type,
+// from one of its roles to a role of a descendant resource type
+type DefaultRoleMappingDescriptor struct {
+	FromRoleName   string
+	ToResourceType string
+	ToRoleName     string
+}
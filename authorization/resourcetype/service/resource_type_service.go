@@ -0,0 +1,613 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/authorization/resourcetype"
+	resourcetyperepo "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/satori/go.uuid"
+)
+
+// NewResourceTypeService creates a new service to manage the resource types and their roles
+func NewResourceTypeService(context servicecontext.ServiceContext) service.ResourceTypeService {
+	return &resourceTypeServiceImpl{BaseService: base.NewBaseService(context)}
+}
+
+// resourceTypeServiceImpl implements the ResourceTypeService to manage the resource types and their roles
+type resourceTypeServiceImpl struct {
+	base.BaseService
+}
+
+// RegisterResourceType registers the specified resource type on behalf of the specified service account, which becomes
+// its owner, along with its scopes, roles, default role and default role mappings. Registering the same definition
+// again is a no-op. New scopes, roles and default role mappings may be added to a registered resource type, but
+// removing or changing any of them is reported as a DataConflictError.
+func (s *resourceTypeServiceImpl) RegisterResourceType(ctx context.Context, ownerID uuid.UUID, definition resourcetype.ResourceTypeDescriptor) (*resourcetype.ResourceTypeDescriptor, error) {
+	err := validateDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *resourcetype.ResourceTypeDescriptor
+	err = s.ExecuteInTransaction(func() error {
+		rt, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, definition.Name)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			rt = &resourcetyperepo.ResourceType{
+				Name:    definition.Name,
+				OwnerID: &ownerID,
+			}
+			err = s.Repositories().ResourceTypeRepository().Create(ctx, rt)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if rt.OwnerID == nil || !uuid.Equal(*rt.OwnerID, ownerID) {
+			return errors.NewForbiddenError(fmt.Sprintf("resource type '%s' is not owned by the service account", definition.Name))
+		}
+
+		err = s.registerScopes(ctx, rt, definition.Scopes)
+		if err != nil {
+			return err
+		}
+		err = s.registerRoles(ctx, rt, definition.Roles)
+		if err != nil {
+			return err
+		}
+		err = s.registerDefaultRole(ctx, rt, definition.DefaultRoleName)
+		if err != nil {
+			return err
+		}
+		err = s.registerDefaultRoleMappings(ctx, rt, definition.DefaultRoleMappings)
+		if err != nil {
+			return err
+		}
+		result, err = s.loadDefinition(ctx, rt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"owner_id":      ownerID,
+		"resource_type": definition.Name,
+	}, "resource type registered")
+	return result, nil
+}
+
+// validateDefinition checks that the roles of the definition only grant scopes of the resource type, and that its
+// default role and the roles it maps from are roles of the resource type
+func validateDefinition(definition resourcetype.ResourceTypeDescriptor) error {
+	if strings.TrimSpace(definition.Name) == "" {
+		return errors.NewBadParameterError("name", definition.Name).Expected("not empty")
+	}
+	roleNames := map[string]bool{}
+	for _, r := range definition.Roles {
+		if strings.TrimSpace(r.Name) == "" {
+			return errors.NewBadParameterError("role name", r.Name).Expected("not empty")
+		}
+		if roleNames[r.Name] {
+			return errors.NewBadParameterError("role name", r.Name).Expected("unique role names")
+		}
+		roleNames[r.Name] = true
+		for _, scope := range r.Scopes {
+			if !contains(definition.Scopes, scope) {
+				return errors.NewBadParameterError("scope", scope).Expected("a scope of resource type " + definition.Name)
+			}
+		}
+	}
+	if definition.DefaultRoleName != nil && !roleNames[*definition.DefaultRoleName] {
+		return errors.NewBadParameterError("default_role", *definition.DefaultRoleName).Expected("a role of resource type " + definition.Name)
+	}
+	for _, m := range definition.DefaultRoleMappings {
+		if !roleNames[m.FromRoleName] {
+			return errors.NewBadParameterError("from_role", m.FromRoleName).Expected("a role of resource type " + definition.Name)
+		}
+	}
+	return nil
+}
+
+// registerScopes creates the scopes of the resource type which do not exist yet
+func (s *resourceTypeServiceImpl) registerScopes(ctx context.Context, rt *resourcetyperepo.ResourceType, scopeNames []string) error {
+	existing, err := s.Repositories().ResourceTypeScopeRepository().LookupForType(ctx, rt.ResourceTypeID)
+	if err != nil {
+		return err
+	}
+	existingNames := make([]string, len(existing))
+	for i, scope := range existing {
+		if !contains(scopeNames, scope.Name) {
+			return errors.NewDataConflictError(fmt.Sprintf("scope '%s' of resource type '%s' cannot be removed", scope.Name, rt.Name))
+		}
+		existingNames[i] = scope.Name
+	}
+	for _, scopeName := range scopeNames {
+		if contains(existingNames, scopeName) {
+			continue
+		}
+		err = s.Repositories().ResourceTypeScopeRepository().Create(ctx, &resourcetyperepo.ResourceTypeScope{
+			ResourceTypeID: rt.ResourceTypeID,
+			Name:           scopeName,
+		})
+		if err != nil {
+			return err
+		}
+		existingNames = append(existingNames, scopeName)
+	}
+	return nil
+}
+
+// registerRoles creates the roles of the resource type which do not exist yet. The custom roles created through the
+// role management API are not part of the definition, and are left untouched.
+func (s *resourceTypeServiceImpl) registerRoles(ctx context.Context, rt *resourcetyperepo.ResourceType, roles []resourcetype.RoleDescriptor) error {
+	existing, err := s.Repositories().RoleRepository().FindRolesByResourceType(ctx, rt.Name)
+	if err != nil {
+		return err
+	}
+	existingScopes := map[string][]string{}
+	customRoles := map[string]bool{}
+	for _, r := range existing {
+		if r.Custom {
+			customRoles[r.RoleName] = true
+			continue
+		}
+		existingScopes[r.RoleName] = r.Scopes
+	}
+	definedRoles := map[string]bool{}
+	for _, r := range roles {
+		definedRoles[r.Name] = true
+	}
+	for _, r := range existing {
+		if !r.Custom && !definedRoles[r.RoleName] {
+			return errors.NewDataConflictError(fmt.Sprintf("role '%s' of resource type '%s' cannot be removed", r.RoleName, rt.Name))
+		}
+	}
+
+	for _, r := range roles {
+		if customRoles[r.Name] {
+			return errors.NewDataConflictError(fmt.Sprintf("role '%s' of resource type '%s' is a custom role", r.Name, rt.Name))
+		}
+		if scopes, found := existingScopes[r.Name]; found {
+			if !sameElements(scopes, r.Scopes) {
+				return errors.NewDataConflictError(fmt.Sprintf("the scopes of role '%s' of resource type '%s' cannot be changed", r.Name, rt.Name))
+			}
+			continue
+		}
+		scopes, err := s.lookupScopes(ctx, rt, r.Scopes)
+		if err != nil {
+			return err
+		}
+		newRole := &rolerepo.Role{
+			ResourceTypeID: rt.ResourceTypeID,
+			Name:           r.Name,
+		}
+		err = s.Repositories().RoleRepository().Create(ctx, newRole)
+		if err != nil {
+			return err
+		}
+		for i := range scopes {
+			err = s.Repositories().RoleRepository().AddScope(ctx, newRole, &scopes[i])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// registerDefaultRole sets the default role of the resource type if it has none yet
+func (s *resourceTypeServiceImpl) registerDefaultRole(ctx context.Context, rt *resourcetyperepo.ResourceType, roleName *string) error {
+	if roleName == nil {
+		if rt.DefaultRoleID != nil {
+			return errors.NewDataConflictError(fmt.Sprintf("the default role of resource type '%s' cannot be removed", rt.Name))
+		}
+		return nil
+	}
+	r, err := s.Repositories().RoleRepository().Lookup(ctx, *roleName, rt.Name)
+	if err != nil {
+		return err
+	}
+	if rt.DefaultRoleID == nil {
+		rt.DefaultRoleID = &r.RoleID
+		return s.Repositories().ResourceTypeRepository().Save(ctx, rt)
+	}
+	if !uuid.Equal(*rt.DefaultRoleID, r.RoleID) {
+		return errors.NewDataConflictError(fmt.Sprintf("the default role of resource type '%s' cannot be changed", rt.Name))
+	}
+	return nil
+}
+
+// registerDefaultRoleMappings creates the default role mappings of the resource type which do not exist yet
+func (s *resourceTypeServiceImpl) registerDefaultRoleMappings(ctx context.Context, rt *resourcetyperepo.ResourceType, mappings []resourcetype.DefaultRoleMappingDescriptor) error {
+	existing, err := s.Repositories().DefaultRoleMappingRepository().FindForResourceType(ctx, rt.ResourceTypeID)
+	if err != nil {
+		return err
+	}
+	defined := map[uuid.UUID]bool{}
+	for _, m := range mappings {
+		fromRole, err := s.Repositories().RoleRepository().Lookup(ctx, m.FromRoleName, rt.Name)
+		if err != nil {
+			return err
+		}
+		toRole, err := s.Repositories().RoleRepository().Lookup(ctx, m.ToRoleName, m.ToResourceType)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return errors.NewBadParameterError("to_role", m.ToRoleName).Expected("a role of resource type " + m.ToResourceType)
+		} else if err != nil {
+			return err
+		}
+
+		found := false
+		for _, e := range existing {
+			if uuid.Equal(e.FromRoleID, fromRole.RoleID) && uuid.Equal(e.ToRoleID, toRole.RoleID) {
+				defined[e.DefaultRoleMappingID] = true
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		newMapping := &rolerepo.DefaultRoleMapping{
+			ResourceTypeID: rt.ResourceTypeID,
+			FromRoleID:     fromRole.RoleID,
+			ToRoleID:       toRole.RoleID,
+		}
+		err = s.Repositories().DefaultRoleMappingRepository().Create(ctx, newMapping)
+		if err != nil {
+			return err
+		}
+		existing = append(existing, *newMapping)
+		defined[newMapping.DefaultRoleMappingID] = true
+	}
+	for _, e := range existing {
+		if !defined[e.DefaultRoleMappingID] {
+			return errors.NewDataConflictError(fmt.Sprintf("the default role mappings of resource type '%s' cannot be removed", rt.Name))
+		}
+	}
+	return nil
+}
+
+// loadDefinition returns the definition of the specified resource type, which does not include its custom roles
+func (s *resourceTypeServiceImpl) loadDefinition(ctx context.Context, rt *resourcetyperepo.ResourceType) (*resourcetype.ResourceTypeDescriptor, error) {
+	definition := resourcetype.ResourceTypeDescriptor{
+		Name:                rt.Name,
+		Scopes:              []string{},
+		Roles:               []resourcetype.RoleDescriptor{},
+		DefaultRoleMappings: []resourcetype.DefaultRoleMappingDescriptor{},
+	}
+	scopes, err := s.Repositories().ResourceTypeScopeRepository().LookupForType(ctx, rt.ResourceTypeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		definition.Scopes = append(definition.Scopes, scope.Name)
+	}
+	sort.Strings(definition.Scopes)
+
+	roles, err := s.Repositories().RoleRepository().FindRolesByResourceType(ctx, rt.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roles {
+		if r.Custom {
+			continue
+		}
+		roleScopes := r.Scopes
+		if roleScopes == nil {
+			roleScopes = []string{}
+		}
+		sort.Strings(roleScopes)
+		definition.Roles = append(definition.Roles, resourcetype.RoleDescriptor{
+			Name:   r.RoleName,
+			Scopes: roleScopes,
+		})
+	}
+	sort.Slice(definition.Roles, func(i, j int) bool {
+		return definition.Roles[i].Name < definition.Roles[j].Name
+	})
+
+	if rt.DefaultRoleID != nil {
+		defaultRole, err := s.Repositories().RoleRepository().Load(ctx, *rt.DefaultRoleID)
+		if err != nil {
+			return nil, err
+		}
+		definition.DefaultRoleName = &defaultRole.Name
+	}
+
+	mappings, err := s.Repositories().DefaultRoleMappingRepository().FindForResourceType(ctx, rt.ResourceTypeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mappings {
+		fromRole, err := s.Repositories().RoleRepository().Load(ctx, m.FromRoleID)
+		if err != nil {
+			return nil, err
+		}
+		toRole, err := s.Repositories().RoleRepository().Load(ctx, m.ToRoleID)
+		if err != nil {
+			return nil, err
+		}
+		definition.DefaultRoleMappings = append(definition.DefaultRoleMappings, resourcetype.DefaultRoleMappingDescriptor{
+			FromRoleName:   fromRole.Name,
+			ToResourceType: toRole.ResourceType.Name,
+			ToRoleName:     toRole.Name,
+		})
+	}
+	return &definition, nil
+}
+
+// CreateRole creates a new role for the specified resource type, granting the specified scopes of the resource type.
+// The identity must be either the owner of the resource type or a system administrator with the
+// `manage_resource_types` scope.
+func (s *resourceTypeServiceImpl) CreateRole(ctx context.Context, identityID uuid.UUID, resourceTypeName string, roleName string, scopeNames []string) (*role.RoleDescriptor, error) {
+	roleName = strings.TrimSpace(roleName)
+	if roleName == "" {
+		return nil, errors.NewBadParameterError("role_name", roleName).Expected("not empty")
+	}
+	rt, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, resourceTypeName)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireResourceTypeManager(ctx, identityID, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	var r *rolerepo.Role
+	err = s.ExecuteInTransaction(func() error {
+		scopes, err := s.lookupScopes(ctx, rt, scopeNames)
+		if err != nil {
+			return err
+		}
+		r = &rolerepo.Role{
+			ResourceTypeID: rt.ResourceTypeID,
+			Name:           roleName,
+			Custom:         true,
+		}
+		err = s.Repositories().RoleRepository().Create(ctx, r)
+		if err != nil {
+			return err
+		}
+		for i := range scopes {
+			err = s.Repositories().RoleRepository().AddScope(ctx, r, &scopes[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":   identityID,
+		"resource_type": rt.Name,
+		"role_id":       r.RoleID,
+		"role_name":     r.Name,
+		"scopes":        scopeNames,
+	}, "role created")
+	return &role.RoleDescriptor{
+		RoleID:       r.RoleID.String(),
+		RoleName:     r.Name,
+		Scopes:       scopeNames,
+		ResourceType: rt.Name,
+	}, nil
+}
+
+// UpdateRole renames the specified role and replaces the set of scopes it grants. Only custom roles can be renamed,
+// since the other roles are referred to by name by the code or by the definition of their resource type, and the new
+// name must not be used by another role of the resource type. The privilege cache of all the resources of the role's
+// resource type are flagged as stale, since the privileges of the identities to which the role was assigned may have
+// changed.
+func (s *resourceTypeServiceImpl) UpdateRole(ctx context.Context, identityID uuid.UUID, roleID uuid.UUID, roleName string, scopeNames []string) (*role.RoleDescriptor, error) {
+	roleName = strings.TrimSpace(roleName)
+	if roleName == "" {
+		return nil, errors.NewBadParameterError("role_name", roleName).Expected("not empty")
+	}
+	r, err := s.Repositories().RoleRepository().Load(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireResourceTypeManager(ctx, identityID, &r.ResourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ExecuteInTransaction(func() error {
+		scopes, err := s.lookupScopes(ctx, &r.ResourceType, scopeNames)
+		if err != nil {
+			return err
+		}
+		if r.Name != roleName {
+			if !r.Custom {
+				return errors.NewDataConflictError(fmt.Sprintf("role '%s' of resource type '%s' is not a custom role and cannot be renamed", r.Name, r.ResourceType.Name))
+			}
+			_, err = s.Repositories().RoleRepository().Lookup(ctx, roleName, r.ResourceType.Name)
+			if err == nil {
+				return errors.NewDataConflictError(fmt.Sprintf("role '%s' already exists for resource type '%s'", roleName, r.ResourceType.Name))
+			} else if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+			r.Name = roleName
+			err = s.Repositories().RoleRepository().Save(ctx, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		currentScopes, err := s.Repositories().RoleRepository().ListScopes(ctx, r)
+		if err != nil {
+			return err
+		}
+		for i := range currentScopes {
+			if !containsScope(scopes, currentScopes[i]) {
+				err = s.Repositories().RoleRepository().RemoveScope(ctx, r, &currentScopes[i])
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for i := range scopes {
+			if !containsScope(currentScopes, scopes[i]) {
+				err = s.Repositories().RoleRepository().AddScope(ctx, r, &scopes[i])
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return s.Repositories().RoleRepository().FlagPrivilegeCacheStaleForRoleChange(ctx, r.RoleID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":   identityID,
+		"resource_type": r.ResourceType.Name,
+		"role_id":       r.RoleID,
+		"role_name":     r.Name,
+		"scopes":        scopeNames,
+	}, "role updated")
+	return &role.RoleDescriptor{
+		RoleID:       r.RoleID.String(),
+		RoleName:     r.Name,
+		Scopes:       scopeNames,
+		ResourceType: r.ResourceType.Name,
+	}, nil
+}
+
+// DeleteRole deletes the specified role, unless it is still assigned to an identity, used by a role mapping or a
+// pending invitation, or is the default role of its resource type.
+func (s *resourceTypeServiceImpl) DeleteRole(ctx context.Context, identityID uuid.UUID, roleID uuid.UUID) error {
+	r, err := s.Repositories().RoleRepository().Load(ctx, roleID)
+	if err != nil {
+		return err
+	}
+	err = s.requireResourceTypeManager(ctx, identityID, &r.ResourceType)
+	if err != nil {
+		return err
+	}
+
+	err = s.ExecuteInTransaction(func() error {
+		inUse, err := s.Repositories().RoleRepository().IsInUse(ctx, roleID)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return errors.NewDataConflictError("the role is still in use and cannot be deleted")
+		}
+		scopes, err := s.Repositories().RoleRepository().ListScopes(ctx, r)
+		if err != nil {
+			return err
+		}
+		for i := range scopes {
+			err = s.Repositories().RoleRepository().RemoveScope(ctx, r, &scopes[i])
+			if err != nil {
+				return err
+			}
+		}
+		return s.Repositories().RoleRepository().Delete(ctx, roleID)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":   identityID,
+		"resource_type": r.ResourceType.Name,
+		"role_id":       roleID,
+		"role_name":     r.Name,
+	}, "role deleted")
+	return nil
+}
+
+// lookupScopes returns the scopes of the resource type with the specified names, or a BadParameterError if any of
+// them is not defined for the resource type
+func (s *resourceTypeServiceImpl) lookupScopes(ctx context.Context, rt *resourcetyperepo.ResourceType, scopeNames []string) ([]resourcetyperepo.ResourceTypeScope, error) {
+	scopes := []resourcetyperepo.ResourceTypeScope{}
+	for _, scopeName := range scopeNames {
+		scope, err := s.Repositories().ResourceTypeScopeRepository().LookupByResourceTypeAndScope(ctx, rt.ResourceTypeID, scopeName)
+		if err != nil {
+			return nil, err
+		}
+		if scope == nil {
+			return nil, errors.NewBadParameterError("scope", scopeName).Expected("a scope of resource type " + rt.Name)
+		}
+		if !containsScope(scopes, *scope) {
+			scopes = append(scopes, *scope)
+		}
+	}
+	return scopes, nil
+}
+
+// requireResourceTypeManager returns nil if the specified identity owns the resource type, or has the
+// `manage_resource_types` scope of the system resource, otherwise it returns a ForbiddenError
+func (s *resourceTypeServiceImpl) requireResourceTypeManager(ctx context.Context, identityID uuid.UUID, rt *resourcetyperepo.ResourceType) error {
+	if rt.OwnerID != nil && uuid.Equal(*rt.OwnerID, identityID) {
+		return nil
+	}
+	resourceIDs, err := s.Repositories().ResourceRepository().FindWithRoleByResourceTypeAndIdentity(ctx, authorization.ResourceTypeSystem, identityID)
+	if err != nil {
+		return err
+	}
+	for _, resourceID := range resourceIDs {
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, resourceID, authorization.ManageResourceTypesSystemScope)
+		if err == nil {
+			return nil
+		}
+		if insufficient, _ := errors.IsInsufficientAuthenticationError(err); insufficient {
+			return err
+		}
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"identity_id":   identityID,
+		"resource_type": rt.Name,
+	}, "identity is neither the owner of the resource type nor a resource type administrator")
+	return errors.NewForbiddenError("managing the roles of a resource type requires to own it or to have the '" + authorization.ManageResourceTypesSystemScope + "' scope")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sameElements returns true if both slices contain the same values, regardless of their order and duplicates
+func sameElements(a, b []string) bool {
+	for _, v := range a {
+		if !contains(b, v) {
+			return false
+		}
+	}
+	for _, v := range b {
+		if !contains(a, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsScope(scopes []resourcetyperepo.ResourceTypeScope, scope resourcetyperepo.ResourceTypeScope) bool {
+	for _, s := range scopes {
+		if uuid.Equal(s.ResourceTypeScopeID, scope.ResourceTypeScopeID) {
+			return true
+		}
+	}
+	return false
+}
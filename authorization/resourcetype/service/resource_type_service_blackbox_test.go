@@ -0,0 +1,303 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/authorization/resourcetype"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type resourceTypeServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestResourceTypeService(t *testing.T) {
+	suite.Run(t, &resourceTypeServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *resourceTypeServiceBlackBoxTest) TestRegisterResourceType() {
+
+	// given
+	serviceAccountID := uuid.NewV4()
+	spaceType := s.Graph.CreateResourceType()
+	s.Graph.CreateRole(spaceType, "admin").AddScope("manage")
+	newDefinition := func(name string) resourcetype.ResourceTypeDescriptor {
+		defaultRole := "admin"
+		return resourcetype.ResourceTypeDescriptor{
+			Name:   name,
+			Scopes: []string{"view", "manage"},
+			Roles: []resourcetype.RoleDescriptor{
+				{Name: "admin", Scopes: []string{"view", "manage"}},
+				{Name: "viewer", Scopes: []string{"view"}},
+			},
+			DefaultRoleName: &defaultRole,
+			DefaultRoleMappings: []resourcetype.DefaultRoleMappingDescriptor{
+				{FromRoleName: "admin", ToResourceType: spaceType.ResourceType().Name, ToRoleName: "admin"},
+			},
+		}
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		definition := newDefinition("pipelines-" + uuid.NewV4().String())
+		// when
+		registered, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, definition)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, definition.Name, registered.Name)
+		assert.Equal(t, []string{"manage", "view"}, registered.Scopes)
+		require.Len(t, registered.Roles, 2)
+		assert.Equal(t, "admin", registered.Roles[0].Name)
+		assert.Equal(t, []string{"manage", "view"}, registered.Roles[0].Scopes)
+		require.NotNil(t, registered.DefaultRoleName)
+		assert.Equal(t, "admin", *registered.DefaultRoleName)
+		require.Len(t, registered.DefaultRoleMappings, 1)
+		assert.Equal(t, definition.DefaultRoleMappings[0], registered.DefaultRoleMappings[0])
+		rt, err := s.Application.ResourceTypeRepository().Lookup(s.Ctx, definition.Name)
+		require.NoError(t, err)
+		require.NotNil(t, rt.OwnerID)
+		assert.Equal(t, serviceAccountID, *rt.OwnerID)
+
+		t.Run("same definition", func(t *testing.T) {
+			// when
+			again, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, definition)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, registered, again)
+		})
+
+		t.Run("custom role", func(t *testing.T) {
+			// given
+			_, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, serviceAccountID, definition.Name, "release-manager", []string{"manage"})
+			require.NoError(t, err)
+			// when
+			again, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, definition)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, registered, again)
+			roles, err := s.Application.RoleManagementService().ListAvailableRolesByResourceType(s.Ctx, definition.Name)
+			require.NoError(t, err)
+			assert.Len(t, roles, 3)
+
+			t.Run("defined with the name of a custom role", func(t *testing.T) {
+				// given
+				changed := newDefinition(definition.Name)
+				changed.Roles = append(changed.Roles, resourcetype.RoleDescriptor{Name: "release-manager", Scopes: []string{"manage"}})
+				// when
+				_, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, changed)
+				// then
+				testsupport.AssertError(t, err, errors.DataConflictError{}, "role 'release-manager' of resource type '"+definition.Name+"' is a custom role")
+			})
+		})
+
+		t.Run("new scope and role", func(t *testing.T) {
+			// given
+			extended := newDefinition(definition.Name)
+			extended.Scopes = append(extended.Scopes, "edit")
+			extended.Roles = append(extended.Roles, resourcetype.RoleDescriptor{Name: "editor", Scopes: []string{"view", "edit"}})
+			// when
+			updated, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, extended)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []string{"edit", "manage", "view"}, updated.Scopes)
+			assert.Len(t, updated.Roles, 3)
+		})
+
+		t.Run("removed role", func(t *testing.T) {
+			// given
+			changed := newDefinition(definition.Name)
+			changed.Roles = changed.Roles[:1]
+			// when
+			_, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, changed)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		})
+
+		t.Run("changed role scopes", func(t *testing.T) {
+			// given
+			changed := newDefinition(definition.Name)
+			changed.Scopes = append(changed.Scopes, "edit")
+			changed.Roles = append(changed.Roles, resourcetype.RoleDescriptor{Name: "editor", Scopes: []string{"view", "edit"}})
+			changed.Roles[1].Scopes = []string{"view", "manage"}
+			// when
+			_, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, changed)
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "the scopes of role 'viewer' of resource type '"+definition.Name+"' cannot be changed")
+		})
+
+		t.Run("another service account", func(t *testing.T) {
+			// when
+			_, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, uuid.NewV4(), definition)
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("role with unknown scope", func(t *testing.T) {
+		// given
+		definition := newDefinition("pipelines-" + uuid.NewV4().String())
+		definition.Roles[1].Scopes = []string{"delete"}
+		// when
+		_, err := s.Application.ResourceTypeService().RegisterResourceType(s.Ctx, serviceAccountID, definition)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+func (s *resourceTypeServiceBlackBoxTest) TestManageRoles() {
+
+	// given
+	admin := s.Graph.CreateUser()
+	systemResource := s.Graph.CreateResource(s.Graph.LoadResourceType(authorization.ResourceTypeSystem))
+	systemResource.AddRole(admin, s.Graph.RoleByNameAndResourceType(authorization.SystemResourceTypeAdminRole, authorization.ResourceTypeSystem))
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		rt := s.Graph.CreateResourceType()
+		s.Graph.CreateRole(rt, "viewer").AddScope("view")
+		s.Graph.CreateRole(rt, "editor").AddScope("edit")
+		res := s.Graph.CreateResource(rt)
+		user := s.Graph.CreateUser()
+
+		// when
+		created, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, admin.IdentityID(), rt.ResourceType().Name, "reviewer", []string{"view"})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "reviewer", created.RoleName)
+		assert.Equal(t, rt.ResourceType().Name, created.ResourceType)
+		roles, err := s.Application.RoleManagementService().ListAvailableRolesByResourceType(s.Ctx, rt.ResourceType().Name)
+		require.NoError(t, err)
+		require.Len(t, roles, 3)
+		roleID, err := uuid.FromString(created.RoleID)
+		require.NoError(t, err)
+		reviewer := s.Graph.RoleByNameAndResourceType("reviewer", rt.ResourceType().Name)
+
+		t.Run("update", func(t *testing.T) {
+			// given
+			res.AddRole(user, reviewer)
+			privs, err := s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, user.IdentityID(), res.ResourceID())
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"view"}, privs.ScopesAsArray())
+			// when
+			updated, err := s.Application.ResourceTypeService().UpdateRole(s.Ctx, admin.IdentityID(), roleID, "contributor", []string{"edit"})
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "contributor", updated.RoleName)
+			scopes, err := s.Application.RoleRepository().ListScopes(s.Ctx, reviewer.Role())
+			require.NoError(t, err)
+			require.Len(t, scopes, 1)
+			assert.Equal(t, "edit", scopes[0].Name)
+			// the privilege cache was flagged as stale
+			privs, err = s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, user.IdentityID(), res.ResourceID())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"edit"}, privs.ScopesAsArray())
+		})
+
+		t.Run("delete role in use", func(t *testing.T) {
+			// when
+			err := s.Application.ResourceTypeService().DeleteRole(s.Ctx, admin.IdentityID(), roleID)
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "the role is still in use and cannot be deleted")
+		})
+
+		t.Run("delete", func(t *testing.T) {
+			// given
+			err := s.Application.IdentityRoleRepository().DeleteForIdentityAndResource(s.Ctx, res.ResourceID(), user.IdentityID())
+			require.NoError(t, err)
+			// when
+			err = s.Application.ResourceTypeService().DeleteRole(s.Ctx, admin.IdentityID(), roleID)
+			// then
+			require.NoError(t, err)
+			roles, err := s.Application.RoleManagementService().ListAvailableRolesByResourceType(s.Ctx, rt.ResourceType().Name)
+			require.NoError(t, err)
+			assert.Len(t, roles, 2)
+		})
+	})
+
+	s.T().Run("owner of the resource type", func(t *testing.T) {
+		// given
+		owner := s.Graph.CreateUser()
+		rt := s.Graph.CreateResourceType()
+		s.Graph.CreateRole(rt, "viewer").AddScope("view")
+		ownerID := owner.IdentityID()
+		rt.ResourceType().OwnerID = &ownerID
+		err := s.Application.ResourceTypeRepository().Save(s.Ctx, rt.ResourceType())
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.ResourceTypeService().CreateRole(s.Ctx, owner.IdentityID(), rt.ResourceType().Name, "reviewer", []string{"view"})
+		// then
+		require.NoError(t, err)
+	})
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("not a resource type administrator", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			// when
+			_, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, s.Graph.CreateUser().IdentityID(), rt.ResourceType().Name, "reviewer", []string{"view"})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown scope", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			// when
+			_, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, admin.IdentityID(), rt.ResourceType().Name, "reviewer", []string{"delete"})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("rename built-in role", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			viewer := s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			// when
+			_, err := s.Application.ResourceTypeService().UpdateRole(s.Ctx, admin.IdentityID(), viewer.Role().RoleID, "reader", []string{"view"})
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "role 'viewer' of resource type '"+rt.ResourceType().Name+"' is not a custom role and cannot be renamed")
+		})
+
+		t.Run("rename to existing role", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			created, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, admin.IdentityID(), rt.ResourceType().Name, "reviewer", []string{"view"})
+			require.NoError(t, err)
+			roleID, err := uuid.FromString(created.RoleID)
+			require.NoError(t, err)
+			// when
+			_, err = s.Application.ResourceTypeService().UpdateRole(s.Ctx, admin.IdentityID(), roleID, "viewer", []string{"view"})
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "role 'viewer' already exists for resource type '"+rt.ResourceType().Name+"'")
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			// when
+			_, err := s.Application.ResourceTypeService().CreateRole(s.Ctx, admin.IdentityID(), rt.ResourceType().Name, "viewer", []string{"view"})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		})
+	})
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/repository/base"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// DenyAssignment is used to explicitly deny a scope to an identity for a resource and all its descendants. A deny
+// assignment takes precedence over the scopes granted by the roles of the identity, whether they are assigned directly,
+// inherited from the resource hierarchy, from the memberships of the identity or from role mappings. A deny assignment
+// for a team or an organization applies to all its members. The scope is denied by name for the descendant resources,
+// whatever their resource type.
+type DenyAssignment struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	DenyAssignmentID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:deny_assignment_id"`
+	// The identity to which the scope is denied
+	IdentityID uuid.UUID        `gorm:"type:uuid"`
+	Identity   account.Identity `gorm:"foreignkey:IdentityID;association_foreignkey:ID"`
+	// The resource for which the scope is denied
+	ResourceID string
+	Resource   resource.Resource `gorm:"foreignkey:ResourceID;association_foreignkey:ResourceID"`
+	// The scope that is denied
+	ScopeID uuid.UUID                      `gorm:"type:uuid"`
+	Scope   resourcetype.ResourceTypeScope `gorm:"foreignkey:ScopeID;association_foreignkey:ResourceTypeScopeID"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m DenyAssignment) TableName() string {
+	return "deny_assignment"
+}
+
+// GetLastModified returns the last modification time
+func (m DenyAssignment) GetLastModified() time.Time {
+	return m.UpdatedAt
+}
+
+// GormDenyAssignmentRepository is the implementation of the storage interface for DenyAssignment.
+type GormDenyAssignmentRepository struct {
+	db *gorm.DB
+}
+
+// NewDenyAssignmentRepository creates a new storage type.
+func NewDenyAssignmentRepository(db *gorm.DB) DenyAssignmentRepository {
+	return &GormDenyAssignmentRepository{db: db}
+}
+
+// DenyAssignmentRepository represents the storage interface.
+type DenyAssignmentRepository interface {
+	CheckExists(ctx context.Context, id uuid.UUID) error
+	Load(ctx context.Context, id uuid.UUID) (*DenyAssignment, error)
+	Create(ctx context.Context, u *DenyAssignment) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	FindByResource(ctx context.Context, resourceID string, includeParentResources bool) ([]DenyAssignment, error)
+	FindDeniedScopes(ctx context.Context, identityID uuid.UUID, resourceID string) ([]string, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormDenyAssignmentRepository) TableName() string {
+	return "deny_assignment"
+}
+
+// CheckExists returns nil if the given ID exists otherwise returns an error
+func (m *GormDenyAssignmentRepository) CheckExists(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "exists"}, time.Now())
+	return base.CheckExistsWithCustomIDColumn(ctx, m.db, m.TableName(), "deny_assignment_id", id.String())
+}
+
+// Load returns a single DenyAssignment as a Database Model
+func (m *GormDenyAssignmentRepository) Load(ctx context.Context, id uuid.UUID) (*DenyAssignment, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "load"}, time.Now())
+	var native DenyAssignment
+	err := m.db.Table(m.TableName()).Preload("Scope").Where("deny_assignment_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("deny_assignment", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record, and flags the privilege cache of the identity and all its members as stale for the
+// resource and all its descendants.
+func (m *GormDenyAssignmentRepository) Create(ctx context.Context, u *DenyAssignment) error {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "create"}, time.Now())
+	if u.DenyAssignmentID == uuid.Nil {
+		u.DenyAssignmentID = uuid.NewV4()
+	}
+	err := m.db.Create(u).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"deny_assignment_id": u.DenyAssignmentID,
+			"err":                err,
+		}, "unable to create the deny assignment")
+		if gormsupport.IsUniqueViolation(err, "uix_deny_assignment") {
+			return errs.WithStack(errors.NewDataConflictError("the scope is already denied to the identity for the resource"))
+		}
+		if gormsupport.IsForeignKeyViolation(err, "deny_assignment_identity_id_fkey") {
+			return errs.WithStack(errors.NewNotFoundError("identity", u.IdentityID.String()))
+		}
+		if gormsupport.IsForeignKeyViolation(err, "deny_assignment_resource_id_fkey") {
+			return errs.WithStack(errors.NewNotFoundError("resource", u.ResourceID))
+		}
+		if gormsupport.IsForeignKeyViolation(err, "deny_assignment_scope_id_fkey") {
+			return errs.WithStack(errors.NewNotFoundError("scope", u.ScopeID.String()))
+		}
+		return errs.WithStack(err)
+	}
+
+	err = NewIdentityRoleRepository(m.db).FlagPrivilegeCacheStaleForIdentityRoleChange(ctx, u.IdentityID, u.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"deny_assignment_id": u.DenyAssignmentID,
+			"err":                err,
+		}, "error notifying privilege cache when creating deny assignment")
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"deny_assignment_id": u.DenyAssignmentID,
+	}, "Deny assignment created!")
+	return nil
+}
+
+// Delete removes a single record, and flags the privilege cache of the identity and all its members as stale for the
+// resource and all its descendants.
+func (m *GormDenyAssignmentRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "delete"}, time.Now())
+
+	obj, err := m.Load(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = m.db.Delete(obj).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"deny_assignment_id": id,
+			"err":                err,
+		}, "unable to delete the deny assignment")
+		return errs.WithStack(err)
+	}
+
+	err = NewIdentityRoleRepository(m.db).FlagPrivilegeCacheStaleForIdentityRoleChange(ctx, obj.IdentityID, obj.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"deny_assignment_id": id,
+			"err":                err,
+		}, "error notifying privilege cache when deleting deny assignment")
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"deny_assignment_id": id,
+	}, "Deny assignment deleted!")
+	return nil
+}
+
+// FindByResource returns the deny assignments for the specified resource, optionally including those for its ancestors
+// which also apply to the resource
+func (m *GormDenyAssignmentRepository) FindByResource(ctx context.Context, resourceID string, includeParentResources bool) ([]DenyAssignment, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "FindByResource"}, time.Now())
+
+	var denyAssignments []DenyAssignment
+	db := m.db.Table(m.TableName()).Preload("Identity").Preload("Resource").Preload("Scope")
+	if includeParentResources {
+		db = db.Where(`resource_id in (WITH RECURSIVE r AS (
+      SELECT resource_id, parent_resource_id FROM resource WHERE resource_id = ? AND deleted_at IS NULL
+      UNION SELECT p.resource_id, p.parent_resource_id FROM resource p INNER JOIN r ON r.parent_resource_id = p.resource_id)
+	    SELECT r.resource_id FROM r)`, resourceID)
+	} else {
+		db = db.Where("resource_id = ?", resourceID)
+	}
+	err := db.Order("created_at").Find(&denyAssignments).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return denyAssignments, nil
+}
+
+// FindDeniedScopes returns the names of the scopes which are denied to the specified identity for the specified
+// resource, by a deny assignment for the identity or any of the identities it is a member of, and for the resource or
+// any of its ancestors.
+func (m *GormDenyAssignmentRepository) FindDeniedScopes(ctx context.Context, identityID uuid.UUID, resourceID string) ([]string, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "deny_assignment", "FindDeniedScopes"}, time.Now())
+
+	type Result struct {
+		Scope string
+	}
+
+	var results []Result
+	err := m.db.Raw(`WITH RECURSIVE identity_hierarchy AS ( /* the identity and all the identities it is a member of */
+  SELECT
+    ?::uuid AS identity_id, 0 AS depth /* IDENTITY_ID */
+  UNION SELECT
+    p.member_of, ih.depth + 1
+  FROM
+    membership p INNER JOIN identity_hierarchy ih ON ih.identity_id = p.member_id AND ih.depth < `+maxMembershipDepth+`
+),
+resource_hierarchy AS ( /* the resource and all its ancestors */
+  SELECT
+    resource_id, parent_resource_id
+  FROM
+    resource
+  WHERE
+    deleted_at IS NULL
+    AND resource_id = ? /* RESOURCE_ID */
+  UNION SELECT
+    p.resource_id, p.parent_resource_id
+  FROM
+    resource p INNER JOIN resource_hierarchy rh ON rh.parent_resource_id = p.resource_id
+)
+SELECT DISTINCT
+  rts.name AS scope
+FROM
+  deny_assignment da INNER JOIN resource_type_scope rts ON rts.resource_type_scope_id = da.scope_id
+WHERE
+  da.deleted_at IS NULL
+  AND da.resource_id IN (SELECT resource_id FROM resource_hierarchy)
+  AND da.identity_id IN (SELECT identity_id FROM identity_hierarchy)`, identityID, resourceID).Scan(&results).Error
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+
+	scopes := make([]string, len(results))
+	for i := range results {
+		scopes[i] = results[i].Scope
+	}
+	return scopes, nil
+}
@@ -0,0 +1,162 @@
+package repository_test
+
+import (
+	"testing"
+
+	resourcetyperepo "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type denyAssignmentBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo rolerepo.DenyAssignmentRepository
+}
+
+func TestRunDenyAssignmentBlackBoxTest(t *testing.T) {
+	suite.Run(t, &denyAssignmentBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *denyAssignmentBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = rolerepo.NewDenyAssignmentRepository(s.DB)
+}
+
+func (s *denyAssignmentBlackBoxTest) lookupScope(rt *resourcetyperepo.ResourceType, scopeName string) *resourcetyperepo.ResourceTypeScope {
+	scope, err := s.Application.ResourceTypeScopeRepository().LookupByResourceTypeAndScope(s.Ctx, rt.ResourceTypeID, scopeName)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), scope)
+	return scope
+}
+
+func (s *denyAssignmentBlackBoxTest) TestDenyAssignment() {
+
+	s.T().Run("deny for team on parent resource", func(t *testing.T) {
+		// given
+		rt := s.Graph.CreateResourceType()
+		editor := s.Graph.CreateRole(rt, "editor").AddScope("view").AddScope("edit")
+		parent := s.Graph.CreateResource(rt)
+		child := s.Graph.CreateResource(rt, parent)
+		user := s.Graph.CreateUser()
+		team := s.Graph.CreateTeam().AddMember(user)
+		parent.AddRole(user, editor)
+		scopes, err := s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), child.ResourceID())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"view", "edit"}, scopes)
+		privs, err := s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, user.IdentityID(), child.ResourceID())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"view", "edit"}, privs.ScopesAsArray())
+		// when
+		da := rolerepo.DenyAssignment{
+			IdentityID: team.TeamID(),
+			ResourceID: parent.ResourceID(),
+			ScopeID:    s.lookupScope(rt.ResourceType(), "edit").ResourceTypeScopeID,
+		}
+		err = s.repo.Create(s.Ctx, &da)
+		// then
+		require.NoError(t, err)
+		scopes, err = s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), child.ResourceID())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"view"}, scopes)
+		identityRoles, err := s.Application.IdentityRoleRepository().FindPermissions(s.Ctx, user.IdentityID(), child.ResourceID(), "edit")
+		require.NoError(t, err)
+		assert.Empty(t, identityRoles)
+		identityRoles, err = s.Application.IdentityRoleRepository().FindPermissions(s.Ctx, user.IdentityID(), child.ResourceID(), "view")
+		require.NoError(t, err)
+		assert.NotEmpty(t, identityRoles)
+		granted, err := s.Application.IdentityRoleRepository().FindGrantedPermissions(s.Ctx, user.IdentityID(), []rolerepo.PermissionCheck{
+			{ResourceID: child.ResourceID(), ScopeName: "view"},
+			{ResourceID: child.ResourceID(), ScopeName: "edit"},
+		})
+		require.NoError(t, err)
+		require.Len(t, granted, 1)
+		assert.Equal(t, "view", granted[0].ScopeName)
+		// the privilege cache of the member was flagged as stale
+		privs, err = s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, user.IdentityID(), child.ResourceID())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"view"}, privs.ScopesAsArray())
+		// the deny assignment is listed for the child resource as inherited
+		denyAssignments, err := s.repo.FindByResource(s.Ctx, child.ResourceID(), true)
+		require.NoError(t, err)
+		require.Len(t, denyAssignments, 1)
+		assert.Equal(t, da.DenyAssignmentID, denyAssignments[0].DenyAssignmentID)
+		assert.Equal(t, "edit", denyAssignments[0].Scope.Name)
+		denyAssignments, err = s.repo.FindByResource(s.Ctx, child.ResourceID(), false)
+		require.NoError(t, err)
+		assert.Empty(t, denyAssignments)
+
+		t.Run("revoked", func(t *testing.T) {
+			// when
+			err := s.repo.Delete(s.Ctx, da.DenyAssignmentID)
+			// then
+			require.NoError(t, err)
+			scopes, err := s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), child.ResourceID())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"view", "edit"}, scopes)
+		})
+	})
+
+	s.T().Run("deny overrides role mapping", func(t *testing.T) {
+		// given
+		orgType := s.Graph.CreateResourceType()
+		orgAdmin := s.Graph.CreateRole(orgType, "admin").AddScope("manage")
+		spaceType := s.Graph.CreateResourceType()
+		spaceAdmin := s.Graph.CreateRole(spaceType, "admin").AddScope("manage").AddScope("delete")
+		org := s.Graph.CreateResource(orgType)
+		space := s.Graph.CreateResource(spaceType, org)
+		s.Graph.CreateRoleMapping(org, orgAdmin, spaceAdmin)
+		user := s.Graph.CreateUser()
+		org.AddRole(user, orgAdmin)
+		scopes, err := s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), space.ResourceID())
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"manage", "delete"}, scopes)
+		// when
+		err = s.repo.Create(s.Ctx, &rolerepo.DenyAssignment{
+			IdentityID: user.IdentityID(),
+			ResourceID: space.ResourceID(),
+			ScopeID:    s.lookupScope(spaceType.ResourceType(), "delete").ResourceTypeScopeID,
+		})
+		// then
+		require.NoError(t, err)
+		scopes, err = s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), space.ResourceID())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"manage"}, scopes)
+		// the scope of the organization is not denied
+		scopes, err = s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), org.ResourceID())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"manage"}, scopes)
+	})
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("duplicate", func(t *testing.T) {
+			// given
+			rt := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(rt, "viewer").AddScope("view")
+			res := s.Graph.CreateResource(rt)
+			user := s.Graph.CreateUser()
+			scopeID := s.lookupScope(rt.ResourceType(), "view").ResourceTypeScopeID
+			err := s.repo.Create(s.Ctx, &rolerepo.DenyAssignment{IdentityID: user.IdentityID(), ResourceID: res.ResourceID(), ScopeID: scopeID})
+			require.NoError(t, err)
+			// when
+			err = s.repo.Create(s.Ctx, &rolerepo.DenyAssignment{IdentityID: user.IdentityID(), ResourceID: res.ResourceID(), ScopeID: scopeID})
+			// then
+			testsupport.AssertError(t, err, errors.DataConflictError{}, "the scope is already denied to the identity for the resource")
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			// when
+			err := s.repo.Delete(s.Ctx, uuid.NewV4())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, err)
+		})
+	})
+}
@@ -3,11 +3,13 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/repository/base"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -17,6 +19,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/goadesign/goa"
 	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
 	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 )
@@ -35,6 +38,34 @@ type IdentityRole struct {
 	// The role that is assigned
 	RoleID uuid.UUID `gorm:"type:uuid"`
 	Role   Role      `gorm:"foreignkey:RoleID;association_foreignkey:RoleID"`
+	// The time from which the role assignment is effective, if it is not effective immediately
+	ValidFrom *time.Time `gorm:"column:valid_from"`
+	// The time until which the role assignment is effective, if it does not last until it is removed
+	ValidUntil *time.Time `gorm:"column:valid_until"`
+}
+
+// Validity is the period during which a role assignment is effective. A nil bound means that the period is not
+// bounded on that side.
+type Validity struct {
+	ValidFrom  *time.Time
+	ValidUntil *time.Time
+}
+
+// resourceRolesLockID is the first key of the advisory locks on the role assignments of a resource, the second key being
+// derived from the resource ID
+const resourceRolesLockID = 4402
+
+// effectiveIdentityRoleCondition is the condition on the `valid_from` and `valid_until` columns of the identity roles
+// which are currently effective
+const effectiveIdentityRoleCondition = `(%[1]svalid_from IS NULL OR %[1]svalid_from <= now()) AND (%[1]svalid_until IS NULL OR %[1]svalid_until > now())`
+
+// effectiveIdentityRole returns the condition on the identity roles with the given table alias, if any, which are
+// currently effective
+func effectiveIdentityRole(alias string) string {
+	if alias != "" {
+		alias = alias + "."
+	}
+	return fmt.Sprintf(effectiveIdentityRoleCondition, alias)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -48,11 +79,93 @@ func (m IdentityRole) GetLastModified() time.Time {
 	return m.UpdatedAt
 }
 
+// PermissionCheck is a resource and a scope for which the permission of an identity is checked
+type PermissionCheck struct {
+	ResourceID string
+	ScopeName  string
+}
+
+// GrantedPermission is a permission check which was granted to an identity, along with the minimum authentication
+// context classes of the resource type and of the scope, if any
+type GrantedPermission struct {
+	PermissionCheck
+	ResourceTypeMinACR *string
+	ScopeMinACR        *string
+}
+
+// The reasons why a grant path does not grant its scope
+const (
+	GrantPathBlockedByDenyAssignment = "denied"
+	GrantPathBlockedByExpiry         = "expired"
+	GrantPathBlockedByValidFrom      = "not_yet_valid"
+)
+
+// PermissionExplanation explains whether a scope is granted to an identity for a resource, with every path through
+// which it is granted and the deny assignments which revoke it
+type PermissionExplanation struct {
+	PermissionCheck
+	IdentityID uuid.UUID
+	Granted    bool
+	Paths      []GrantPath
+	Denials    []DenyAssignment
+}
+
+// GrantPath is a path through which an identity role grants a scope to an identity for a resource
+type GrantPath struct {
+	// The identity role granting the scope, with its role
+	IdentityRole IdentityRole
+	// The identities from the identity whose permission is explained to the one the role is assigned to, through
+	// the memberships of each identity in the next one
+	MembershipChain []PathNode
+	// The resources from the one the role is assigned for to the resource whose permission is explained, through
+	// the parent of each resource
+	ResourceChain []PathNode
+	// The role mappings applied, in order, to map the assigned role to a role granting the scope
+	RoleMappings []RoleMapping
+	// The reason why the path does not grant the scope, or an empty string if it does
+	BlockedBy string
+}
+
+// PathNode is an identity or a resource of a grant path
+type PathNode struct {
+	ID   string
+	Name string
+}
+
+// AccessGrant is a path through which an effective identity role grants a scope to an identity for a resource, as
+// listed by the access reports
+type AccessGrant struct {
+	ResourceID   string
+	IdentityID   uuid.UUID
+	IdentityName string
+	// True if the identity is an organization, a team or a security group
+	Group     bool
+	ScopeName string
+	// The names of the identities from the identity to the one the role is assigned to
+	MembershipChain []string
+	// The name of the assigned role
+	RoleName string
+	// The names of the resources from the one the role is assigned for to the resource
+	ResourceChain []string
+	// The names of the roles the assigned role is mapped to, in order
+	MappedRoles []string
+}
+
+// SpaceCollaborator is a role of a space held by a user, either assigned to the user or to an identity the user is a
+// member of, directly or not
+type SpaceCollaborator struct {
+	IdentityID uuid.UUID
+	RoleName   string
+}
+
 // GormIdentityRoleRepository is the implementation of the storage interface for IdentityRole.
 type GormIdentityRoleRepository struct {
 	db *gorm.DB
 }
 
+// maxMembershipDepth is the maximum number of nested memberships followed by the recursive membership queries
+var maxMembershipDepth = strconv.Itoa(authorization.MaxMembershipDepth)
+
 // NewIdentityRoleRepository creates a new storage type.
 func NewIdentityRoleRepository(db *gorm.DB) IdentityRoleRepository {
 	return &GormIdentityRoleRepository{db: db}
@@ -69,12 +182,21 @@ type IdentityRoleRepository interface {
 	DeleteForResource(ctx context.Context, resourceID string) error
 	DeleteForIdentityAndResource(ctx context.Context, resourceID string, identityID uuid.UUID) error
 	FindPermissions(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) ([]IdentityRole, error)
+	FindGrantedPermissions(ctx context.Context, identityID uuid.UUID, checks []PermissionCheck) ([]GrantedPermission, error)
+	FindAccessGrants(ctx context.Context, resourceIDs []string, fn func(grant AccessGrant) error) error
+	FindSpaceCollaborators(ctx context.Context, spaceID string, q string) ([]SpaceCollaborator, error)
 	FindIdentityRolesForIdentity(ctx context.Context, identityID uuid.UUID, resourceType *string) ([]authorization.IdentityAssociation, error)
 	FindIdentityRolesByResourceAndRoleName(ctx context.Context, resourceID string, roleName string, includeParenResources bool) ([]IdentityRole, error)
+	FindEffectiveIdentityRolesByResourceAndRoleName(ctx context.Context, resourceID string, roleName string) ([]IdentityRole, error)
+	LockResourceRoles(ctx context.Context, resourceID string) error
 	FindIdentityRolesByResource(ctx context.Context, resourceID string, includeParenResources bool) ([]IdentityRole, error)
 	FindIdentityRolesByIdentityAndResource(ctx context.Context, resourceID string, identityID uuid.UUID) ([]IdentityRole, error)
+	FindIdentityRolesByIdentity(ctx context.Context, identityID uuid.UUID) ([]IdentityRole, error)
 	FindScopesByIdentityAndResource(ctx context.Context, identityID uuid.UUID, resourceID string) ([]string, error)
 	FlagPrivilegeCacheStaleForIdentityRoleChange(ctx context.Context, identityID uuid.UUID, resourceID string) error
+	FindExpired(ctx context.Context, limit int) ([]IdentityRole, error)
+	FindStarted(ctx context.Context, limit int) ([]IdentityRole, error)
+	MarkStarted(ctx context.Context, identityRole IdentityRole) error
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -226,9 +348,11 @@ func (m *GormIdentityRoleRepository) List(ctx context.Context) ([]IdentityRole,
 }
 
 // FindPermissions returns an IdentityRole array containing entries that match the specified identity, resource and scope
+// Only the identity roles which are currently effective are returned. No identity role is returned if the scope is
+// denied to the identity for the resource by a deny assignment.
 func (m *GormIdentityRoleRepository) FindPermissions(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) ([]IdentityRole, error) {
 	var results []IdentityRole
-	err := m.db.Table(m.TableName()).Where(`deleted_at IS NULL AND identity_id IN (
+	err := m.db.Table(m.TableName()).Where(`deleted_at IS NULL AND `+effectiveIdentityRole("")+` AND identity_id IN (
   SELECT
     id
   FROM
@@ -236,17 +360,17 @@ func (m *GormIdentityRoleRepository) FindPermissions(ctx context.Context, identi
   WHERE
     id = ? /* IDENTITY_ID */
     OR id IN (
-    WITH RECURSIVE m AS (
+    WITH RECURSIVE m(member_of, depth) AS (
       SELECT 
-        member_of 
+        member_of, 1 
       FROM 
         membership 
       WHERE 
         member_id = ? /* IDENTITY_ID */
       UNION SELECT 
-        p.member_of 
+        p.member_of, m.depth + 1 
       FROM 
-        membership p INNER JOIN m ON m.member_of = p.member_id
+        membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`
     ) 
     SELECT member_of FROM m
     )
@@ -367,6 +491,424 @@ func (m *GormIdentityRoleRepository) FindPermissions(ctx context.Context, identi
 		return nil, errs.WithStack(err)
 	}
 
+	if len(results) > 0 {
+		// the identity roles do not grant the scope if it is explicitly denied
+		deniedScopes, err := NewDenyAssignmentRepository(m.db).FindDeniedScopes(ctx, identityID, resourceID)
+		if err != nil {
+			return nil, err
+		}
+		for _, deniedScope := range deniedScopes {
+			if deniedScope == scopeName {
+				return []IdentityRole{}, nil
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// FindGrantedPermissions returns the permission checks which are granted to the specified identity, in a single query.
+// A check is granted under the same conditions as FindPermissions returns identity roles for its resource and scope:
+// the identity, or any of the identities it is a member of, must have been assigned for the resource or any of its
+// ancestors either a role which grants the scope, or a role which is mapped to such a role. Checks of unknown resources,
+// and checks of scopes denied to the identity by a deny assignment, are never granted.
+func (m *GormIdentityRoleRepository) FindGrantedPermissions(ctx context.Context, identityID uuid.UUID, checks []PermissionCheck) ([]GrantedPermission, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindGrantedPermissions"}, time.Now())
+
+	results := []GrantedPermission{}
+	if len(checks) == 0 {
+		return results, nil
+	}
+	resourceIDs := make([]string, len(checks))
+	scopeNames := make([]string, len(checks))
+	for i, check := range checks {
+		resourceIDs[i] = check.ResourceID
+		scopeNames[i] = check.ScopeName
+	}
+
+	rows, err := m.db.Raw(`WITH RECURSIVE checks AS (
+  SELECT DISTINCT
+    c.resource_id, c.scope_name
+  FROM
+    unnest(?::text[], ?::text[]) AS c(resource_id, scope_name) /* RESOURCE_IDS, SCOPE_NAMES */
+),
+identity_hierarchy AS ( /* the identity and all the identities it is a member of */
+  SELECT
+    ?::uuid AS identity_id, 0 AS depth /* IDENTITY_ID */
+  UNION SELECT
+    p.member_of, ih.depth + 1
+  FROM
+    membership p INNER JOIN identity_hierarchy ih ON ih.identity_id = p.member_id AND ih.depth < `+maxMembershipDepth+`
+),
+resource_hierarchy AS ( /* each checked resource and all its ancestors */
+  SELECT
+    r.resource_id AS checked_resource_id, r.resource_id, r.parent_resource_id
+  FROM
+    resource r
+  WHERE
+    r.deleted_at IS NULL
+    AND r.resource_id IN (SELECT resource_id FROM checks)
+  UNION SELECT
+    rh.checked_resource_id, p.resource_id, p.parent_resource_id
+  FROM
+    resource p INNER JOIN resource_hierarchy rh ON rh.parent_resource_id = p.resource_id
+),
+assigned_roles AS ( /* the roles assigned to the identity hierarchy for each checked resource or its ancestors */
+  SELECT DISTINCT
+    rh.checked_resource_id, ir.role_id
+  FROM
+    identity_role ir INNER JOIN resource_hierarchy rh ON rh.resource_id = ir.resource_id
+  WHERE
+    ir.deleted_at IS NULL
+    AND `+effectiveIdentityRole("ir")+`
+    AND ir.identity_id IN (SELECT identity_id FROM identity_hierarchy)
+),
+scope_roles AS ( /* the roles of the type of each checked resource which grant the checked scope */
+  SELECT
+    c.resource_id AS checked_resource_id, c.scope_name, r.role_id
+  FROM
+    checks c
+    INNER JOIN resource res ON res.resource_id = c.resource_id AND res.deleted_at IS NULL
+    INNER JOIN role r ON r.resource_type_id = res.resource_type_id AND r.deleted_at IS NULL
+    INNER JOIN role_scope rs ON rs.role_id = r.role_id AND rs.deleted_at IS NULL
+    INNER JOIN resource_type_scope rts ON rts.resource_type_scope_id = rs.scope_id AND rts.deleted_at IS NULL
+  WHERE
+    rts.name = c.scope_name
+),
+mapped_roles AS ( /* the chains of role mappings which lead to a role granting the checked scope */
+  SELECT
+    c.resource_id AS checked_resource_id, c.scope_name, rm.from_role_id, rm.to_role_id
+  FROM
+    checks c
+    INNER JOIN resource_hierarchy rh ON rh.checked_resource_id = c.resource_id
+    INNER JOIN role_mapping rm ON rm.resource_id = rh.resource_id AND rm.deleted_at IS NULL
+    INNER JOIN role r ON r.role_id = rm.to_role_id AND r.deleted_at IS NULL
+    INNER JOIN role_scope rs ON rs.role_id = r.role_id AND rs.deleted_at IS NULL
+    INNER JOIN resource_type_scope rts ON rts.resource_type_scope_id = rs.scope_id AND rts.deleted_at IS NULL
+  WHERE
+    rts.name = c.scope_name
+  UNION SELECT
+    mr.checked_resource_id, mr.scope_name, trm.from_role_id, trm.to_role_id
+  FROM
+    role_mapping trm
+    INNER JOIN mapped_roles mr ON mr.from_role_id = trm.to_role_id
+    INNER JOIN resource tres ON tres.resource_id = trm.resource_id AND tres.deleted_at IS NULL
+  WHERE
+    trm.deleted_at IS NULL
+)
+SELECT
+  c.resource_id, c.scope_name, rt.min_acr, rts.min_acr
+FROM
+  checks c
+  INNER JOIN resource res ON res.resource_id = c.resource_id
+  INNER JOIN resource_type rt ON rt.resource_type_id = res.resource_type_id
+  LEFT JOIN resource_type_scope rts ON rts.resource_type_id = rt.resource_type_id AND rts.name = c.scope_name AND rts.deleted_at IS NULL
+WHERE
+  EXISTS (
+    SELECT
+      1
+    FROM
+      assigned_roles ar
+    WHERE
+      ar.checked_resource_id = c.resource_id
+      AND (ar.role_id IN (
+        SELECT role_id FROM scope_roles sr WHERE sr.checked_resource_id = c.resource_id AND sr.scope_name = c.scope_name
+      ) OR ar.role_id IN (
+        SELECT from_role_id FROM mapped_roles mr WHERE mr.checked_resource_id = c.resource_id AND mr.scope_name = c.scope_name
+      ) OR ar.role_id IN (
+        SELECT to_role_id FROM mapped_roles mr WHERE mr.checked_resource_id = c.resource_id AND mr.scope_name = c.scope_name
+      ))
+  )
+  AND NOT EXISTS ( /* the scope denied to the identity hierarchy for the checked resource or its ancestors */
+    SELECT
+      1
+    FROM
+      deny_assignment da
+      INNER JOIN resource_hierarchy rh ON rh.resource_id = da.resource_id
+      INNER JOIN resource_type_scope drts ON drts.resource_type_scope_id = da.scope_id
+    WHERE
+      da.deleted_at IS NULL
+      AND rh.checked_resource_id = c.resource_id
+      AND drts.name = c.scope_name
+      AND da.identity_id IN (SELECT identity_id FROM identity_hierarchy)
+  )`, pq.Array(resourceIDs), pq.Array(scopeNames), identityID).Rows()
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var granted GrantedPermission
+		err = rows.Scan(&granted.ResourceID, &granted.ScopeName, &granted.ResourceTypeMinACR, &granted.ScopeMinACR)
+		if err != nil {
+			return nil, errs.WithStack(err)
+		}
+		results = append(results, granted)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return results, nil
+}
+
+// FindAccessGrants calls fn with each path through which an effective identity role grants a scope of their type to an
+// identity for the specified resources, which should all be of the same type, in a single query. The identities are
+// those which are assigned a role for the resources or their ancestors, along with all their members, directly or not,
+// through the shortest membership chain. The roles granting the scopes include those which the assigned roles are
+// mapped to by the role mappings of the resources or their ancestors. The scopes denied to an identity, or to any
+// identity it is a member of, are skipped. The grants are ordered by resource, in the order of the specified resource
+// IDs, then by identity name and by scope, so that the grants of a scope to an identity for a resource are contiguous.
+func (m *GormIdentityRoleRepository) FindAccessGrants(ctx context.Context, resourceIDs []string, fn func(grant AccessGrant) error) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindAccessGrants"}, time.Now())
+
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+	rows, err := m.db.Raw(`WITH RECURSIVE reported AS ( /* the reported resources, in the specified order */
+  SELECT
+    r.resource_id, r.resource_type_id, rid.position
+  FROM
+    unnest(?::text[]) WITH ORDINALITY AS rid(resource_id, position) /* RESOURCE_IDS */
+    INNER JOIN resource r ON r.resource_id = rid.resource_id AND r.deleted_at IS NULL
+),
+resource_hierarchy AS ( /* each reported resource and all its ancestors, with the names of the resources down to the reported one */
+  SELECT
+    rr.resource_id AS reported_resource_id, r.resource_id, r.parent_resource_id, ARRAY[r.name::text] AS resource_chain
+  FROM
+    reported rr INNER JOIN resource r ON r.resource_id = rr.resource_id
+  UNION ALL SELECT
+    rh.reported_resource_id, p.resource_id, p.parent_resource_id, p.name::text || rh.resource_chain
+  FROM
+    resource p INNER JOIN resource_hierarchy rh ON rh.parent_resource_id = p.resource_id
+  WHERE
+    p.deleted_at IS NULL
+),
+assignments AS ( /* the effective roles assigned for each reported resource or its ancestors */
+  SELECT
+    rh.reported_resource_id, rh.resource_chain, ir.identity_id AS assignee_id, ir.role_id
+  FROM
+    identity_role ir INNER JOIN resource_hierarchy rh ON rh.resource_id = ir.resource_id
+  WHERE
+    ir.deleted_at IS NULL
+    AND `+effectiveIdentityRole("ir")+`
+),
+members AS ( /* each assignee and all its members, with the identities from the member to the assignee */
+  SELECT
+    a.assignee_id, a.assignee_id AS identity_id, ARRAY[a.assignee_id] AS chain
+  FROM
+    (SELECT DISTINCT assignee_id FROM assignments) a
+  UNION ALL SELECT
+    m.assignee_id, p.member_id, p.member_id || m.chain
+  FROM
+    membership p INNER JOIN members m ON m.identity_id = p.member_of
+  WHERE
+    array_length(m.chain, 1) <= `+maxMembershipDepth+`
+    AND NOT p.member_id = ANY(m.chain)
+),
+shortest_members AS ( /* the shortest membership chain from each member to each assignee */
+  SELECT DISTINCT ON (assignee_id, identity_id)
+    assignee_id, identity_id, chain
+  FROM
+    members
+  ORDER BY
+    assignee_id, identity_id, array_length(chain, 1)
+),
+member_hierarchy AS ( /* each member and all the identities it is a member of, for the deny assignments */
+  SELECT
+    identity_id, identity_id AS member_of, 0 AS depth
+  FROM
+    (SELECT DISTINCT identity_id FROM shortest_members) sm
+  UNION SELECT
+    mh.identity_id, p.member_of, mh.depth + 1
+  FROM
+    membership p INNER JOIN member_hierarchy mh ON mh.member_of = p.member_id AND mh.depth < `+maxMembershipDepth+`
+),
+denials AS ( /* the scopes denied to each member for each reported resource */
+  SELECT DISTINCT
+    mh.identity_id, rh.reported_resource_id, rts.name AS scope_name
+  FROM
+    deny_assignment da
+    INNER JOIN member_hierarchy mh ON mh.member_of = da.identity_id
+    INNER JOIN resource_hierarchy rh ON rh.resource_id = da.resource_id
+    INNER JOIN resource_type_scope rts ON rts.resource_type_scope_id = da.scope_id
+  WHERE
+    da.deleted_at IS NULL
+),
+role_chains AS ( /* the assigned roles and the roles they are mapped to for each reported resource, without cycles */
+  SELECT DISTINCT
+    a.reported_resource_id, a.role_id AS assigned_role_id, a.role_id, ARRAY[]::text[] AS mapped_roles, ARRAY[a.role_id] AS visited
+  FROM
+    assignments a
+  UNION ALL SELECT
+    rc.reported_resource_id, rc.assigned_role_id, rm.to_role_id, rc.mapped_roles || tr.name::text, rc.visited || rm.to_role_id
+  FROM
+    role_chains rc
+    INNER JOIN role_mapping rm ON rm.from_role_id = rc.role_id AND rm.deleted_at IS NULL
+    INNER JOIN resource_hierarchy rh ON rh.reported_resource_id = rc.reported_resource_id AND rh.resource_id = rm.resource_id
+    INNER JOIN role tr ON tr.role_id = rm.to_role_id AND tr.deleted_at IS NULL
+  WHERE
+    NOT rm.to_role_id = ANY(rc.visited)
+),
+grants AS ( /* the scopes granted by the roles of the type of each reported resource */
+  SELECT
+    rc.reported_resource_id, rc.assigned_role_id, rc.mapped_roles, rts.name AS scope_name
+  FROM
+    role_chains rc
+    INNER JOIN reported rr ON rr.resource_id = rc.reported_resource_id
+    INNER JOIN role r ON r.role_id = rc.role_id AND r.resource_type_id = rr.resource_type_id AND r.deleted_at IS NULL
+    INNER JOIN role_scope rs ON rs.role_id = r.role_id AND rs.deleted_at IS NULL
+    INNER JOIN resource_type_scope rts ON rts.resource_type_scope_id = rs.scope_id AND rts.deleted_at IS NULL
+)
+SELECT
+  rr.resource_id,
+  i.id,
+  COALESCE(ires.name, i.username) AS identity_name,
+  i.identity_resource_id IS NOT NULL AS grp,
+  g.scope_name,
+  ARRAY(
+    SELECT
+      COALESCE(cres.name, ci.username)
+    FROM
+      unnest(sm.chain) WITH ORDINALITY AS c(identity_id, position)
+      INNER JOIN identities ci ON ci.id = c.identity_id
+      LEFT JOIN resource cres ON cres.resource_id = ci.identity_resource_id
+    ORDER BY
+      c.position
+  ) AS membership_chain,
+  ar.name,
+  a.resource_chain,
+  g.mapped_roles
+FROM
+  assignments a
+  INNER JOIN reported rr ON rr.resource_id = a.reported_resource_id
+  INNER JOIN grants g ON g.reported_resource_id = a.reported_resource_id AND g.assigned_role_id = a.role_id
+  INNER JOIN role ar ON ar.role_id = a.role_id
+  INNER JOIN shortest_members sm ON sm.assignee_id = a.assignee_id
+  INNER JOIN identities i ON i.id = sm.identity_id
+  LEFT JOIN resource ires ON ires.resource_id = i.identity_resource_id
+WHERE
+  NOT EXISTS (
+    SELECT
+      1
+    FROM
+      denials d
+    WHERE
+      d.identity_id = sm.identity_id
+      AND d.reported_resource_id = rr.resource_id
+      AND d.scope_name = g.scope_name
+  )
+ORDER BY
+  rr.position, identity_name, i.id, g.scope_name, array_length(sm.chain, 1), ar.name, a.resource_chain, g.mapped_roles`, pq.Array(resourceIDs)).Rows()
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var grant AccessGrant
+		var membershipChain, resourceChain, mappedRoles pq.StringArray
+		err = rows.Scan(&grant.ResourceID, &grant.IdentityID, &grant.IdentityName, &grant.Group, &grant.ScopeName,
+			&membershipChain, &grant.RoleName, &resourceChain, &mappedRoles)
+		if err != nil {
+			return errs.WithStack(err)
+		}
+		grant.MembershipChain = membershipChain
+		grant.ResourceChain = resourceChain
+		grant.MappedRoles = mappedRoles
+		err = fn(grant)
+		if err != nil {
+			return err
+		}
+	}
+	return errs.WithStack(rows.Err())
+}
+
+// FindSpaceCollaborators returns the roles of the space held by users, in a single query. A user holds a role when it
+// is assigned to the user, or to an identity the user is a member of, for the space, or when a role assigned for an
+// ancestor of the space is mapped to it by the role mappings of that ancestor. Only the effective role assignments are
+// taken into account. If q is not empty, only the users whose username, full name or public email address contains it,
+// ignoring the case, are returned.
+func (m *GormIdentityRoleRepository) FindSpaceCollaborators(ctx context.Context, spaceID string, q string) ([]SpaceCollaborator, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindSpaceCollaborators"}, time.Now())
+
+	rows, err := m.db.Raw(`WITH RECURSIVE resource_hierarchy AS ( /* the ancestors of the space */
+  SELECT
+    resource_id, parent_resource_id
+  FROM
+    resource
+  WHERE
+    deleted_at IS NULL
+    AND resource_id = ? /* SPACE_ID */
+  UNION SELECT
+    p.resource_id, p.parent_resource_id
+  FROM
+    resource p INNER JOIN resource_hierarchy rh ON rh.parent_resource_id = p.resource_id
+),
+space_roles AS ( /* the roles of the space held by each identity, assigned or mapped */
+  SELECT
+    ir.identity_id, r.name AS role_name
+  FROM
+    identity_role ir INNER JOIN role r ON r.role_id = ir.role_id
+  WHERE
+    ir.deleted_at IS NULL
+    AND `+effectiveIdentityRole("ir")+`
+    AND ir.resource_id = ? /* SPACE_ID */
+  UNION SELECT
+    ir.identity_id, tr.name
+  FROM
+    identity_role ir
+    INNER JOIN resource_hierarchy rh ON rh.resource_id = ir.resource_id
+    INNER JOIN role_mapping rm ON rm.resource_id = ir.resource_id AND rm.from_role_id = ir.role_id AND rm.deleted_at IS NULL
+    INNER JOIN role tr ON tr.role_id = rm.to_role_id AND tr.deleted_at IS NULL
+    INNER JOIN resource_type trt ON trt.resource_type_id = tr.resource_type_id
+  WHERE
+    ir.deleted_at IS NULL
+    AND `+effectiveIdentityRole("ir")+`
+    AND ir.resource_id != ? /* SPACE_ID */
+    AND trt.name = ? /* SPACE_RESOURCE_TYPE */
+),
+members AS ( /* the identities holding a role of the space and all their members */
+  SELECT
+    identity_id AS member_id, identity_id, 0 AS depth
+  FROM
+    (SELECT DISTINCT identity_id FROM space_roles) sr
+  UNION SELECT
+    p.member_id, m.identity_id, m.depth + 1
+  FROM
+    membership p INNER JOIN members m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`
+)
+SELECT DISTINCT
+  i.id, i.username, sr.role_name
+FROM
+  members m
+  INNER JOIN space_roles sr ON sr.identity_id = m.identity_id
+  INNER JOIN identities i ON i.id = m.member_id AND i.deleted_at IS NULL
+  INNER JOIN users u ON u.id = i.user_id AND u.deleted_at IS NULL
+WHERE
+  ? = '' /* Q */
+  OR strpos(lower(i.username), lower(?)) > 0 /* Q */
+  OR strpos(lower(u.full_name), lower(?)) > 0 /* Q */
+  OR (NOT u.email_private AND strpos(lower(u.email), lower(?)) > 0) /* Q */
+ORDER BY
+  i.username, i.id, sr.role_name`, spaceID, spaceID, spaceID, authorization.ResourceTypeSpace, q, q, q, q).Rows()
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	defer rows.Close()
+
+	results := []SpaceCollaborator{}
+	for rows.Next() {
+		var collaborator SpaceCollaborator
+		var username string
+		err = rows.Scan(&collaborator.IdentityID, &username, &collaborator.RoleName)
+		if err != nil {
+			return nil, errs.WithStack(err)
+		}
+		results = append(results, collaborator)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errs.WithStack(err)
+	}
 	return results, nil
 }
 
@@ -388,9 +930,9 @@ func (m *GormIdentityRoleRepository) FindIdentityRolesForIdentity(ctx context.Co
 	}
 	q = q.Joins("JOIN role ON role.role_id = identity_role.role_id")
 
-	rows, err := q.Where(`(identity_role.identity_id = ? OR identity_role.identity_id IN (WITH RECURSIVE m AS (
-			SELECT member_of FROM	membership WHERE member_id = ? 
-      UNION SELECT p.member_of	FROM membership p INNER JOIN m ON m.member_of = p.member_id)
+	rows, err := q.Where(`(identity_role.identity_id = ? OR identity_role.identity_id IN (WITH RECURSIVE m(member_of, depth) AS (
+			SELECT member_of, 1 FROM	membership WHERE member_id = ? 
+      UNION SELECT p.member_of, m.depth + 1	FROM membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`)
 		  SELECT member_of FROM m))`, identityID, identityID).Rows()
 
 	if err != nil {
@@ -447,6 +989,32 @@ func (m *GormIdentityRoleRepository) findIdentityRolesByResourceAndRoleNameWithP
 	return identityRoles, err
 }
 
+// FindEffectiveIdentityRolesByResourceAndRoleName returns the assignments of the role with the specified name for the
+// specified resource which are currently effective, i.e. neither expired nor yet to start
+func (m *GormIdentityRoleRepository) FindEffectiveIdentityRolesByResourceAndRoleName(ctx context.Context, resourceID string, roleName string) ([]IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindEffectiveIdentityRolesByResourceAndRoleName"}, time.Now())
+
+	var identityRoles []IdentityRole
+
+	err := m.db.Table(m.TableName()).Preload("Role").Preload("Resource").Preload("Identity").
+		Where(`resource_id = ? AND `+effectiveIdentityRole(m.TableName()), resourceID).
+		Joins("JOIN role ON identity_role.role_id = role.role_id AND role.name = ?", roleName).Order("created_at").Find(&identityRoles).Error
+
+	return identityRoles, errs.WithStack(err)
+}
+
+// LockResourceRoles acquires a transaction level lock on the role assignments of the specified resource, which
+// serializes the changes checking the remaining assignments, e.g. that a resource keeps an administrator. The lock is
+// released when the transaction is committed or rolled back.
+func (m *GormIdentityRoleRepository) LockResourceRoles(ctx context.Context, resourceID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "LockResourceRoles"}, time.Now())
+	err := m.db.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", resourceRolesLockID, resourceID).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
 // FindIdentityRolesByResource returns an array of IdentityRole for the specified resource
 func (m *GormIdentityRoleRepository) FindIdentityRolesByResource(ctx context.Context, resourceID string, includeParenResources bool) ([]IdentityRole, error) {
 	if includeParenResources {
@@ -481,6 +1049,47 @@ func (m *GormIdentityRoleRepository) findIdentityRolesByResourceWithParents(ctx
 	return identityRoles, err
 }
 
+// FindExpired returns up to `limit` identity roles whose validity has ended
+func (m *GormIdentityRoleRepository) FindExpired(ctx context.Context, limit int) ([]IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindExpired"}, time.Now())
+
+	var identityRoles []IdentityRole
+	err := m.db.Table(m.TableName()).Preload("Role").Preload("Resource").Preload("Identity").
+		Where("valid_until IS NOT NULL AND valid_until <= now()").
+		Order("valid_until").Limit(limit).Find(&identityRoles).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identityRoles, nil
+}
+
+// FindStarted returns up to `limit` identity roles whose validity has started since they were last updated, i.e. the
+// identity roles which became effective after the privilege cache was last flagged as stale for them
+func (m *GormIdentityRoleRepository) FindStarted(ctx context.Context, limit int) ([]IdentityRole, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FindStarted"}, time.Now())
+
+	var identityRoles []IdentityRole
+	err := m.db.Table(m.TableName()).
+		Where("valid_from IS NOT NULL AND valid_from <= now() AND valid_from > updated_at").
+		Order("valid_from").Limit(limit).Find(&identityRoles).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identityRoles, nil
+}
+
+// MarkStarted flags the privilege cache as stale for the given identity role whose validity has started, and updates
+// it so that it is not returned by FindStarted anymore
+func (m *GormIdentityRoleRepository) MarkStarted(ctx context.Context, identityRole IdentityRole) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "MarkStarted"}, time.Now())
+
+	err := m.db.Exec("UPDATE identity_role SET updated_at = now() WHERE identity_role_id = ?", identityRole.IdentityRoleID).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	return m.FlagPrivilegeCacheStaleForIdentityRoleChange(ctx, identityRole.IdentityID, identityRole.ResourceID)
+}
+
 // DeleteForResource deletes all identity roles for the given resource ID
 // No error is returned if no identity role found
 func (m *GormIdentityRoleRepository) DeleteForResource(ctx context.Context, resourceID string) error {
@@ -517,8 +1126,14 @@ func (m *GormIdentityRoleRepository) FindIdentityRolesByIdentityAndResource(ctx
 	return m.query(identityRoleFilterByIdentityID(identityID), identityRoleFilterByResource(resourceID))
 }
 
+// FindIdentityRolesByIdentity returns all identity roles assigned to the identity, whatever their resource
+func (m *GormIdentityRoleRepository) FindIdentityRolesByIdentity(ctx context.Context, identityID uuid.UUID) ([]IdentityRole, error) {
+	return m.query(identityRoleFilterByIdentityID(identityID))
+}
+
 // FindScopesByIdentityAndResource returns all scopes for the specified identity and resource, both assigned directly and
-// also those indirectly inherited via memberships, resource hierarchy and role mappings.
+// also those indirectly inherited via memberships, resource hierarchy and role mappings. The identity roles which are not
+// effective yet, or not anymore, are ignored. The scopes denied to the identity by deny assignments are never returned.
 func (m *GormIdentityRoleRepository) FindScopesByIdentityAndResource(ctx context.Context, identityID uuid.UUID, resourceID string) ([]string, error) {
 
 	type Result struct {
@@ -529,17 +1144,17 @@ func (m *GormIdentityRoleRepository) FindScopesByIdentityAndResource(ctx context
 
 	err := m.db.Raw(`WITH identity_resource_roles AS (
 	WITH identity_hierarchy AS (
-		WITH RECURSIVE m AS (
+		WITH RECURSIVE m(member_of, depth) AS (
 			SELECT
-		    member_of
+		    member_of, 1
 		  FROM
 		    membership
 		  WHERE
 		    member_id = ? /* IDENTITY_ID */
 		  UNION SELECT
-		    p.member_of
+		    p.member_of, m.depth + 1
 		  FROM
-		    membership p INNER JOIN m ON m.member_of = p.member_id
+		    membership p INNER JOIN m ON m.member_of = p.member_id AND m.depth < `+maxMembershipDepth+`
 		)
 		SELECT
 		  member_of AS identity_id
@@ -638,6 +1253,7 @@ func (m *GormIdentityRoleRepository) FindScopesByIdentityAndResource(ctx context
 	WHERE
       ir.role_id = rm.from_role_id
       AND ir.deleted_at IS NULL
+      AND `+effectiveIdentityRole("ir")+`
 	  AND ir.resource_id IN (SELECT resource_id FROM resource_hierarchy)
 	  AND ir.identity_id IN (SELECT identity_id FROM identity_hierarchy)
 	UNION SELECT
@@ -647,6 +1263,7 @@ func (m *GormIdentityRoleRepository) FindScopesByIdentityAndResource(ctx context
 	WHERE 
 	  ir2.resource_id IN (SELECT resource_id FROM resource_hierarchy)
       AND ir2.deleted_at IS NULL
+      AND `+effectiveIdentityRole("ir2")+`
 	  AND ir2.identity_id IN (SELECT identity_id FROM identity_hierarchy)
 	  AND ir2.role_id IN (SELECT role_id FROM matching_roles)
 )
@@ -660,10 +1277,21 @@ FROM
 		return nil, errs.WithStack(err)
 	}
 
+	deniedScopes, err := NewDenyAssignmentRepository(m.db).FindDeniedScopes(ctx, identityID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	denied := make(map[string]bool, len(deniedScopes))
+	for _, deniedScope := range deniedScopes {
+		denied[deniedScope] = true
+	}
+
 	var scopes []string
 
 	for i := range results {
-		scopes = append(scopes, results[i].Scope)
+		if !denied[results[i].Scope] {
+			scopes = append(scopes, results[i].Scope)
+		}
 	}
 
 	return scopes, nil
@@ -703,22 +1331,24 @@ func identityRoleFilterByIdentityID(identityID uuid.UUID) func(db *gorm.DB) *gor
 // equal to, or a descendent of (via the resource hierarchy) the specified resource ID.
 // The second query updates the token table, setting the STALE flag of the token STATUS field to true, for all
 // token records that are mapped to the corresponding privilege cache records in the first query, via the
-// many-to-many TOKEN_PRIVILEGE table
+// many-to-many TOKEN_PRIVILEGE table.
+// The IDs of the identities whose privilege cache records were flagged as stale are published when the transaction
+// is committed, so that the in-memory privilege caches of all the replicas are invalidated
 func (m *GormIdentityRoleRepository) FlagPrivilegeCacheStaleForIdentityRoleChange(ctx context.Context, identityID uuid.UUID, resourceID string) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FlagPrivilegeCacheStaleForIdentityRoleChange"}, time.Now())
 
-	result := m.db.Exec(`WITH identity_hierarchy AS (
-	WITH RECURSIVE m AS (
+	err := permission.FlagStaleAndNotify(ctx, m.db, `WITH identity_hierarchy AS (
+	WITH RECURSIVE m(member_id, depth) AS (
 	  SELECT
-	    member_id
+	    member_id, 1
 	  FROM
 	    membership
 	  WHERE
 	    member_of = ? /* IDENTITY_ID */
 	  UNION SELECT
-	    p.member_id
+	    p.member_id, m.depth + 1
 	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
+	    membership p INNER JOIN m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`
 	  )
 	  SELECT
 	    member_id AS identity_id
@@ -755,28 +1385,25 @@ WHERE
   resource_id IN (SELECT resource_id FROM resource_hierarchy)
   AND identity_id IN (SELECT identity_id FROM identity_hierarchy)
   AND deleted_at IS NULL
+RETURNING
+  identity_id
   `, identityID, identityID, resourceID)
-
-	if result.Error != nil {
-		return errors.NewInternalError(ctx, result.Error)
+	if err != nil {
+		return err
 	}
 
-	log.Debug(ctx, map[string]interface{}{
-		"rows_marked_stale": result.RowsAffected,
-	}, "Privilege cache rows marked stale")
-
-	result = m.db.Exec(`WITH identity_hierarchy AS (
-	WITH RECURSIVE m AS (
+	result := m.db.Exec(`WITH identity_hierarchy AS (
+	WITH RECURSIVE m(member_id, depth) AS (
 	  SELECT
-	    member_id
+	    member_id, 1
 	  FROM
 	    membership
 	  WHERE
 	    member_of = ? /* IDENTITY_ID */
 	  UNION SELECT
-	    p.member_id
+	    p.member_id, m.depth + 1
 	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
+	    membership p INNER JOIN m ON m.member_id = p.member_of AND m.depth < `+maxMembershipDepth+`
 	  )
 	  SELECT
 	    member_id AS identity_id
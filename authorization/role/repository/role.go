@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/repository/base"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -30,6 +32,9 @@ type Role struct {
 	ResourceTypeID uuid.UUID
 	// The name of this role
 	Name string
+	// Custom is true if the role was created through the role management API, false if it was created by a migration
+	// or by the registration of its resource type
+	Custom bool
 }
 
 // GormRoleRepository is the implementation of the storage interface for Role.
@@ -54,8 +59,11 @@ type RoleRepository interface {
 	Lookup(ctx context.Context, name string, resourceType string) (*Role, error)
 	ListScopes(ctx context.Context, u *Role) ([]resourcetype.ResourceTypeScope, error)
 	AddScope(ctx context.Context, u *Role, s *resourcetype.ResourceTypeScope) error
+	RemoveScope(ctx context.Context, u *Role, s *resourcetype.ResourceTypeScope) error
 
 	FindRolesByResourceType(ctx context.Context, resourceType string) ([]role.RoleDescriptor, error)
+	IsInUse(ctx context.Context, roleID uuid.UUID) (bool, error)
+	FlagPrivilegeCacheStaleForRoleChange(ctx context.Context, roleID uuid.UUID) error
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -243,13 +251,38 @@ func (m *GormRoleRepository) AddScope(ctx context.Context, u *Role, s *resourcet
 	return nil
 }
 
+// RemoveScope removes the specified scope from the role
+func (m *GormRoleRepository) RemoveScope(ctx context.Context, u *Role, s *resourcetype.ResourceTypeScope) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "removescope"}, time.Now())
+
+	// the role scope is hard deleted, so that the scope may be added to the role again later
+	result := m.db.Unscoped().Where("role_id = ? AND scope_id = ?", u.RoleID, s.ResourceTypeScopeID).Delete(&RoleScope{})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_id":  u.RoleID,
+			"scope_id": s.ResourceTypeScopeID,
+			"err":      result.Error,
+		}, "unable to delete the role scope")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("role_scope", s.ResourceTypeScopeID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"role_id":  u.RoleID,
+		"scope_id": s.ResourceTypeScopeID,
+	}, "Role scope deleted!")
+	return nil
+}
+
 func (m *GormRoleRepository) FindRolesByResourceType(ctx context.Context, resourceType string) ([]role.RoleDescriptor, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "role", "FindRolesByResourceType"}, time.Now())
 	var roles []role.RoleDescriptor
 
 	db := m.db.Raw(`SELECT r.role_id,
 		r.name role_name,
-		array_to_string(array_agg(rts.NAME), ',') scopes
+		array_to_string(array_agg(rts.NAME), ',') scopes,
+		r.custom
 		FROM   
 		  role r LEFT OUTER JOIN role_scope rs ON r.role_id = rs.role_id
 		  LEFT OUTER JOIN resource_type_scope rts ON rs.scope_id = rts.resource_type_scope_id,
@@ -261,7 +294,8 @@ func (m *GormRoleRepository) FindRolesByResourceType(ctx context.Context, resour
       AND rt.deleted_at IS NULL
 		GROUP BY 
 		  r.role_id, 
-		  r.name`, resourceType)
+		  r.name,
+		  r.custom`, resourceType)
 
 	rows, err := db.Rows()
 	if err != nil {
@@ -293,10 +327,12 @@ func (m *GormRoleRepository) FindRolesByResourceType(ctx context.Context, resour
 		var roleName string
 		var scopeNames string
 		var roleID string
+		var custom bool
 
 		columnValues[0] = &roleID
 		columnValues[1] = &roleName
 		columnValues[2] = &scopeNames
+		columnValues[3] = &custom
 
 		if err = rows.Scan(columnValues...); err != nil {
 			log.Error(ctx, map[string]interface{}{
@@ -314,8 +350,96 @@ func (m *GormRoleRepository) FindRolesByResourceType(ctx context.Context, resour
 			RoleID:       roleID,
 			Scopes:       scopesList,
 			ResourceType: resourceType,
+			Custom:       custom,
 		}
 		roles = append(roles, roleScope)
 	}
 	return roles, err
 }
+
+// IsInUse returns true if the specified role is assigned to any identity, is used by any role mapping, default role
+// mapping or pending invitation, or is the default role of its resource type
+func (m *GormRoleRepository) IsInUse(ctx context.Context, roleID uuid.UUID) (bool, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "IsInUse"}, time.Now())
+
+	type Result struct {
+		InUse bool
+	}
+
+	var result Result
+	err := m.db.Raw(`SELECT (
+  EXISTS (SELECT 1 FROM identity_role WHERE role_id = ? AND deleted_at IS NULL)
+  OR EXISTS (SELECT 1 FROM role_mapping WHERE (from_role_id = ? OR to_role_id = ?) AND deleted_at IS NULL)
+  OR EXISTS (SELECT 1 FROM default_role_mapping WHERE (from_role_id = ? OR to_role_id = ?) AND deleted_at IS NULL)
+  OR EXISTS (SELECT 1 FROM invitation_role ir INNER JOIN invitation i ON i.invitation_id = ir.invitation_id WHERE ir.role_id = ? AND i.deleted_at IS NULL)
+  OR EXISTS (SELECT 1 FROM resource_type WHERE default_role_id = ? AND deleted_at IS NULL)
+) AS in_use`, roleID, roleID, roleID, roleID, roleID, roleID, roleID).Scan(&result).Error
+	if err != nil {
+		return false, errs.WithStack(err)
+	}
+	return result.InUse, nil
+}
+
+// FlagPrivilegeCacheStaleForRoleChange is used to flag privilege cache records as stale after the definition of the
+// specified role has changed. It executes two queries; the first query updates the privilege_cache table, setting the
+// STALE value to true for all privilege cache records of the resources of the role's resource type, as the role may
+// have been assigned, directly or by a role mapping, for any of them.
+// The second query updates the token table, setting the STALE flag of the token STATUS field to true, for all
+// token records that are mapped to the corresponding privilege cache records in the first query, via the
+// many-to-many TOKEN_PRIVILEGE table.
+// The IDs of the identities whose privilege cache records were flagged as stale are published when the transaction
+// is committed, so that the in-memory privilege caches of all the replicas are invalidated
+func (m *GormRoleRepository) FlagPrivilegeCacheStaleForRoleChange(ctx context.Context, roleID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role", "FlagPrivilegeCacheStaleForRoleChange"}, time.Now())
+
+	err := permission.FlagStaleAndNotify(ctx, m.db, `WITH role_resources AS (
+  SELECT
+    res.resource_id
+  FROM
+    resource res INNER JOIN role r ON r.resource_type_id = res.resource_type_id
+  WHERE
+    r.role_id = ? /* ROLE_ID */
+)
+UPDATE privilege_cache SET
+  STALE = true
+WHERE
+  resource_id IN (SELECT resource_id FROM role_resources)
+  AND deleted_at IS NULL
+RETURNING
+  identity_id
+  `, roleID)
+	if err != nil {
+		return err
+	}
+
+	result := m.db.Exec(`WITH role_resources AS (
+  SELECT
+    res.resource_id
+  FROM
+    resource res INNER JOIN role r ON r.resource_type_id = res.resource_type_id
+  WHERE
+    r.role_id = ? /* ROLE_ID */
+)
+UPDATE token t SET
+  STATUS = STATUS | ? /* TOKEN_STATUS_STALE */
+FROM
+  token_privilege tp,
+  privilege_cache pc
+WHERE
+  t.token_id = tp.token_id
+  AND tp.privilege_cache_id = pc.privilege_cache_id
+  AND pc.resource_id IN (SELECT resource_id FROM role_resources)
+  AND pc.deleted_at IS NULL
+`, roleID, token.TOKEN_STATUS_STALE)
+
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"rows_marked_stale": result.RowsAffected,
+		"role_id":           roleID,
+	}, "Token rows marked stale")
+
+	return nil
+}
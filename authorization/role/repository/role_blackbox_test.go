@@ -171,6 +171,19 @@ func (s *roleBlackBoxTest) TestCreateConflictError() {
 	require.IsType(s.T(), errors.DataConflictError{}, err)
 }
 
+func (s *roleBlackBoxTest) TestCreateWithNameOfDeletedRole() {
+	role1, err := testsupport.CreateTestRoleWithDefaultType(s.Ctx, s.DB, uuid.NewV4().String())
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), role1)
+
+	err = s.repo.Delete(s.Ctx, role1.RoleID)
+	require.NoError(s.T(), err)
+
+	role2, err := testsupport.CreateTestRoleWithDefaultType(s.Ctx, s.DB, role1.Name)
+	require.NoError(s.T(), err)
+	require.NotEqual(s.T(), role1.RoleID, role2.RoleID)
+}
+
 func (s *roleBlackBoxTest) TestKnownRolesExist() {
 	t := s.T()
 
@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/repository/base"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -69,6 +71,7 @@ type RoleMappingRepository interface {
 	Delete(ctx context.Context, ID uuid.UUID) error
 	DeleteForResource(ctx context.Context, resourceID string) error
 	FindForResource(ctx context.Context, resourceID string) ([]RoleMapping, error)
+	FlagPrivilegeCacheStaleForRoleMappingChange(ctx context.Context, resourceID string) error
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -110,6 +113,15 @@ func (m *GormRoleMappingRepository) Create(ctx context.Context, u *RoleMapping)
 		}, "unable to create the role mapping")
 		return errs.WithStack(err)
 	}
+
+	err = m.FlagPrivilegeCacheStaleForRoleMappingChange(ctx, u.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_mapping_id": u.RoleMappingID,
+			"err":             err,
+		}, "error notifying privilege cache when creating role mapping")
+	}
+
 	log.Debug(ctx, map[string]interface{}{
 		"role_mapping_id": u.RoleMappingID,
 	}, "Role mapping created!")
@@ -133,6 +145,14 @@ func (m *GormRoleMappingRepository) Save(ctx context.Context, model *RoleMapping
 		return errs.WithStack(err)
 	}
 
+	err = m.FlagPrivilegeCacheStaleForRoleMappingChange(ctx, obj.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_mapping_id": model.RoleMappingID,
+			"err":             err,
+		}, "error notifying privilege cache when saving role mapping")
+	}
+
 	log.Debug(ctx, map[string]interface{}{
 		"role_mapping_id": model.RoleMappingID,
 	}, "Role mapping saved!")
@@ -155,9 +175,12 @@ func (m *GormRoleMappingRepository) List(ctx context.Context) ([]RoleMapping, er
 func (m *GormRoleMappingRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "role_mapping", "delete"}, time.Now())
 
-	obj := RoleMapping{RoleMappingID: id}
+	obj, err := m.Load(ctx, id)
+	if err != nil {
+		return err
+	}
 
-	result := m.db.Delete(&obj)
+	result := m.db.Delete(&RoleMapping{RoleMappingID: id})
 
 	if result.Error != nil {
 		log.Error(ctx, map[string]interface{}{
@@ -170,6 +193,14 @@ func (m *GormRoleMappingRepository) Delete(ctx context.Context, id uuid.UUID) er
 		return errors.NewNotFoundError("role_mapping", id.String())
 	}
 
+	err = m.FlagPrivilegeCacheStaleForRoleMappingChange(ctx, obj.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_mapping_id": id,
+			"err":             err,
+		}, "error notifying privilege cache when deleting role mapping")
+	}
+
 	log.Debug(ctx, map[string]interface{}{
 		"role_mapping_id": id,
 	}, "Role mapping deleted!")
@@ -182,9 +213,18 @@ func (m *GormRoleMappingRepository) Delete(ctx context.Context, id uuid.UUID) er
 func (m *GormRoleMappingRepository) DeleteForResource(ctx context.Context, resourceID string) error {
 	defer goa.MeasureSince([]string{"goa", "db", "role_mapping", "deleteForResource"}, time.Now())
 
-	err := m.db.Table(m.TableName()).Where("resource_id = ?", resourceID).Delete(nil).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return errs.WithStack(err)
+	result := m.db.Table(m.TableName()).Where("resource_id = ?", resourceID).Delete(nil)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected > 0 {
+		err := m.FlagPrivilegeCacheStaleForRoleMappingChange(ctx, resourceID)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"resource_id": resourceID,
+				"err":         err,
+			}, "error notifying privilege cache when deleting role mappings")
+		}
 	}
 	return nil
 }
@@ -194,9 +234,92 @@ func (m *GormRoleMappingRepository) FindForResource(ctx context.Context, resourc
 
 	var rows []RoleMapping
 
-	err := m.db.Model(&RoleMapping{}).Where("resource_id = ?", resourceID).Find(&rows).Error
+	err := m.db.Model(&RoleMapping{}).Preload("FromRole").Preload("ToRole").Preload("ToRole.ResourceType").Where("resource_id = ?", resourceID).Find(&rows).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(err)
 	}
 	return rows, nil
 }
+
+// FlagPrivilegeCacheStaleForRoleMappingChange executes two update queries; the first sets the stale flag to true for
+// all privilege cache records where the resource ID is equal to, or a descendent of (via the resource hierarchy) the
+// specified resource ID, as a role mapping of the resource may change the privileges of any identity in it.
+// The second query updates the token table, setting the STALE flag of the token STATUS field to true, for all
+// token records that are mapped to the corresponding privilege cache records in the first query, via the
+// many-to-many TOKEN_PRIVILEGE table.
+// The IDs of the identities whose privilege cache records were flagged as stale are published when the transaction
+// is committed, so that the in-memory privilege caches of all the replicas are invalidated
+func (m *GormRoleMappingRepository) FlagPrivilegeCacheStaleForRoleMappingChange(ctx context.Context, resourceID string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "role_mapping", "FlagPrivilegeCacheStaleForRoleMappingChange"}, time.Now())
+
+	err := permission.FlagStaleAndNotify(ctx, m.db, `WITH resource_hierarchy AS (
+	WITH RECURSIVE m AS (
+	  SELECT
+	    resource_id, parent_resource_id
+	  FROM
+	    resource
+	  WHERE
+	    resource_id = ? /* RESOURCE_ID */
+	  UNION SELECT
+	    p.resource_id, p.parent_resource_id
+	  FROM
+	    resource p INNER JOIN m ON m.resource_id = p.parent_resource_id
+	  )
+	  SELECT
+	    m.resource_id
+	  FROM
+	    m
+)
+UPDATE privilege_cache SET
+  STALE = true
+WHERE
+  resource_id IN (SELECT resource_id FROM resource_hierarchy)
+  AND deleted_at IS NULL
+RETURNING
+  identity_id
+  `, resourceID)
+	if err != nil {
+		return err
+	}
+
+	result := m.db.Exec(`WITH resource_hierarchy AS (
+	WITH RECURSIVE m AS (
+	  SELECT
+	    resource_id, parent_resource_id
+	  FROM
+	    resource
+	  WHERE
+	    resource_id = ? /* RESOURCE_ID */
+	  UNION SELECT
+	    p.resource_id, p.parent_resource_id
+	  FROM
+	    resource p INNER JOIN m ON m.resource_id = p.parent_resource_id
+	  )
+	  SELECT
+	    m.resource_id
+	  FROM
+	    m
+)
+UPDATE token t SET
+  STATUS = STATUS | ? /* TOKEN_STATUS_STALE */
+FROM
+  token_privilege tp,
+  privilege_cache pc
+WHERE
+  t.token_id = tp.token_id
+  AND tp.privilege_cache_id = pc.privilege_cache_id
+  AND pc.resource_id IN (SELECT resource_id FROM resource_hierarchy)
+  AND pc.deleted_at IS NULL
+`, resourceID, token.TOKEN_STATUS_STALE)
+
+	if result.Error != nil {
+		return errors.NewInternalError(ctx, result.Error)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"rows_marked_stale": result.RowsAffected,
+		"resource_id":       resourceID,
+	}, "Token rows marked stale")
+
+	return nil
+}
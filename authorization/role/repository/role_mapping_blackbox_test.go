@@ -6,6 +6,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	resourcetyperepo "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
@@ -200,3 +201,36 @@ func (s *roleMappingBlackBoxTest) TestFindForResource() {
 	require.Equal(s.T(), m.RoleMapping().FromRoleID, mappings[0].FromRoleID)
 	require.Equal(s.T(), m.RoleMapping().ToRoleID, mappings[0].ToRoleID)
 }
+
+func (s *roleMappingBlackBoxTest) TestFlagPrivilegeCacheStaleForRoleMappingChange() {
+	// Create a resource with a child resource, and another unrelated resource
+	parent := s.Graph.CreateResource()
+	child := s.Graph.CreateResource(parent)
+	other := s.Graph.CreateResource()
+
+	// Create privilege cache records for the child and the unrelated resources
+	pc := s.Graph.CreatePrivilegeCache(child, "foo")
+	pc2 := s.Graph.CreatePrivilegeCache(other, "foo")
+
+	// Link a token to each privilege cache record
+	t := s.Graph.CreateToken()
+	t.AddPrivilege(pc)
+	t2 := s.Graph.CreateToken()
+	t2.AddPrivilege(pc2)
+
+	// Flag the privilege cache as stale for a role mapping change of the parent resource
+	err := s.repo.FlagPrivilegeCacheStaleForRoleMappingChange(s.Ctx, parent.ResourceID())
+	require.NoError(s.T(), err)
+
+	// Assert that only the privilege cache of the child resource is now stale
+	pc = s.Graph.LoadPrivilegeCache(pc.PrivilegeCache().PrivilegeCacheID)
+	require.True(s.T(), pc.PrivilegeCache().Stale)
+	pc2 = s.Graph.LoadPrivilegeCache(pc2.PrivilegeCache().PrivilegeCacheID)
+	require.False(s.T(), pc2.PrivilegeCache().Stale)
+
+	// Assert that only the token linked to the privilege cache of the child resource is now stale
+	t = s.Graph.LoadToken(s.Ctx, t.TokenID())
+	require.True(s.T(), t.Token().HasStatus(token.TOKEN_STATUS_STALE))
+	t2 = s.Graph.LoadToken(s.Ctx, t2.TokenID())
+	require.False(s.T(), t2.Token().HasStatus(token.TOKEN_STATUS_STALE))
+}
@@ -6,4 +6,6 @@ type RoleDescriptor struct {
 	RoleName     string
 	Scopes       []string
 	ResourceType string
+	// Custom is true if the role was created through the role management API
+	Custom bool
 }
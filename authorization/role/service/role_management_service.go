@@ -3,19 +3,25 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
 
 	"github.com/satori/go.uuid"
 )
 
+// roleAssignmentsBatchSize is the maximum number of expired or started role assignments processed at once
+const roleAssignmentsBatchSize = 100
+
 // NewRoleManagementService creates a new service to manage role assignments
 func NewRoleManagementService(context servicecontext.ServiceContext) *roleManagementServiceImpl {
 	return &roleManagementServiceImpl{base.NewBaseService(context)}
@@ -67,14 +73,30 @@ func (s *roleManagementServiceImpl) ListAvailableRolesByResourceType(ctx context
 // which we want to assign the role to.
 // If appendToExistingRoles == true then the new roles for these identities will be appended to the existing roles.
 // If appendToExistingRoles == false then the new roles will replace the existing ones (the existing ones will be deleted).
+// validity is an optional map of the periods during which the assignments are effective, where the key is a role name.
+// The assignments of the roles which are not in the map are effective immediately and until they are removed.
+// The identities must already have a role for the resource, unless they have a pending access request for the role.
 // IMPORTANT: This is a transactional method, which manages its own transaction/s internally
-func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.UUID, roleAssignments map[string][]uuid.UUID, resourceID string, appendToExistingRoles bool) error {
+func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.UUID, roleAssignments map[string][]uuid.UUID, resourceID string, appendToExistingRoles bool, validity map[string]rolerepo.Validity) error {
 	// Lookup the resourceID and ensure the resource is valid
 	rt, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
 	if err != nil {
 		return err
 	}
 
+	now := time.Now()
+	for roleName, v := range validity {
+		if v.ValidUntil == nil {
+			continue
+		}
+		if !v.ValidUntil.After(now) {
+			return errors.NewBadParameterErrorFromString("valid_until", *v.ValidUntil, fmt.Sprintf("the assignment of role '%s' must be valid until a time in the future", roleName))
+		}
+		if v.ValidFrom != nil && !v.ValidUntil.After(*v.ValidFrom) {
+			return errors.NewBadParameterErrorFromString("valid_until", *v.ValidUntil, fmt.Sprintf("the assignment of role '%s' must be valid until a time after it is valid from", roleName))
+		}
+	}
+
 	// check if the current user token belongs to a user who has the necessary privileges
 	// for assigning roles to other users.
 	permissionService := s.Services().PermissionService()
@@ -86,6 +108,7 @@ func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.
 	// Valid all the roles and user identity IDs, and ensure each user has been previously assigned
 	// privileges for the resource, otherwise the invitation workflow should be used instead
 	assignments := make(map[uuid.UUID][]uuid.UUID)
+	validityByRoleID := make(map[uuid.UUID]rolerepo.Validity)
 
 	existingRoles := []rolerepo.IdentityRole{}
 
@@ -101,6 +124,9 @@ func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.
 			roleID = roleRef.RoleID
 			roleIDByNameCache[roleName] = roleID
 		}
+		if v, found := validity[roleName]; found {
+			validityByRoleID[roleID] = v
+		}
 
 		for _, identityIDAsUUID := range identityIDs {
 			if found, _ := checkedIdentityIDs[identityIDAsUUID]; !found { // Don't check the same identity multiple times
@@ -112,7 +138,16 @@ func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.
 					}, "error looking up existing assignments")
 					return err
 				}
+				requested := false
 				if len(assignedRoles) == 0 {
+					// an identity which requested access to the resource may be assigned the requested role
+					request, err := s.Repositories().AccessRequestRepository().FindPending(ctx, identityIDAsUUID, resourceID)
+					if err != nil {
+						return err
+					}
+					requested = request != nil && uuid.Equal(request.RoleID, roleID)
+				}
+				if len(assignedRoles) == 0 && !requested {
 					log.Error(ctx, map[string]interface{}{
 						"resource_id": resourceID,
 						"identity_id": identityIDAsUUID,
@@ -153,6 +188,8 @@ func (s *roleManagementServiceImpl) Assign(ctx context.Context, assignedBy uuid.
 					ResourceID: resourceID,
 					IdentityID: identityIDAsUUID,
 					RoleID:     roleID,
+					ValidFrom:  validityByRoleID[roleID].ValidFrom,
+					ValidUntil: validityByRoleID[roleID].ValidUntil,
 				}
 
 				err = s.Repositories().IdentityRoleRepository().Create(ctx, &ir)
@@ -231,3 +268,315 @@ func (s *roleManagementServiceImpl) RevokeResourceRoles(ctx context.Context, cur
 
 	return err
 }
+
+// ListDenyAssignmentsByResource lists the deny assignments which apply to the resource, including those for its
+// ancestors, if the current user has permissions to view the roles
+func (s *roleManagementServiceImpl) ListDenyAssignmentsByResource(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]rolerepo.DenyAssignment, error) {
+	err := s.requireViewRolesScope(ctx, currentIdentity, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repositories().DenyAssignmentRepository().FindByResource(ctx, resourceID, true)
+}
+
+// Deny denies scopes to one or more identities (users, organizations, teams or groups) for a resource and all its
+// descendants, whatever the roles of the identities.
+// denials is a map of deny assignments where the key is a scope name and the value is an array of IDs of the identities
+// which we want to deny the scope to. The scopes must be defined by the resource type of the resource.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) Deny(ctx context.Context, deniedBy uuid.UUID, denials map[string][]uuid.UUID, resourceID string) ([]rolerepo.DenyAssignment, error) {
+	// Lookup the resourceID and ensure the resource is valid
+	rt, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the current user token belongs to a user who has the necessary privileges for managing roles
+	err = s.Services().PermissionService().RequireScope(ctx, deniedBy, resourceID, authorization.ScopeForManagingRolesInResourceType(rt.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make(map[string]*resourcetype.ResourceTypeScope)
+	for scopeName := range denials {
+		scope, err := s.Repositories().ResourceTypeScopeRepository().LookupByResourceTypeAndScope(ctx, rt.ResourceTypeID, scopeName)
+		if err != nil {
+			return nil, err
+		}
+		if scope == nil {
+			return nil, errors.NewBadParameterErrorFromString("scope", scopeName, fmt.Sprintf("scope '%s' is not defined for resource type '%s'", scopeName, rt.ResourceType.Name))
+		}
+		scopes[scopeName] = scope
+	}
+
+	denyAssignments := []rolerepo.DenyAssignment{}
+	err = s.ExecuteInTransaction(func() error {
+		for scopeName, identityIDs := range denials {
+			for _, identityID := range identityIDs {
+				identity, err := s.Repositories().Identities().Load(ctx, identityID)
+				if err != nil {
+					return err
+				}
+				da := rolerepo.DenyAssignment{
+					IdentityID: identityID,
+					ResourceID: resourceID,
+					ScopeID:    scopes[scopeName].ResourceTypeScopeID,
+				}
+				err = s.Repositories().DenyAssignmentRepository().Create(ctx, &da)
+				if err != nil {
+					log.Error(ctx, map[string]interface{}{
+						"resource_id": resourceID,
+						"identity_id": identityID,
+						"scope":       scopeName,
+					}, "deny assignment failed")
+					return err
+				}
+				da.Identity = *identity
+				da.Scope = *scopes[scopeName]
+				denyAssignments = append(denyAssignments, da)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return denyAssignments, nil
+}
+
+// RevokeDenyAssignment removes a deny assignment for the resource
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) RevokeDenyAssignment(ctx context.Context, currentIdentity uuid.UUID, resourceID string, denyAssignmentID uuid.UUID) error {
+	// Lookup the resourceID and ensure the resource is valid
+	rt, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return err
+	}
+
+	// check if the current user token belongs to a user who has the necessary privileges for managing roles
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForManagingRolesInResourceType(rt.Name))
+	if err != nil {
+		return err
+	}
+
+	return s.ExecuteInTransaction(func() error {
+		da, err := s.Repositories().DenyAssignmentRepository().Load(ctx, denyAssignmentID)
+		if err != nil {
+			return err
+		}
+		if da.ResourceID != resourceID {
+			return errors.NewNotFoundError("deny_assignment", denyAssignmentID.String())
+		}
+		return s.Repositories().DenyAssignmentRepository().Delete(ctx, denyAssignmentID)
+	})
+}
+
+// ListRoleMappings lists the role mappings of the resource if the current user has permissions to view the roles
+func (s *roleManagementServiceImpl) ListRoleMappings(ctx context.Context, currentIdentity uuid.UUID, resourceID string) ([]rolerepo.RoleMapping, error) {
+	err := s.requireViewRolesScope(ctx, currentIdentity, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repositories().RoleMappingRepository().FindForResource(ctx, resourceID)
+}
+
+// CreateRoleMapping creates a role mapping for the resource, so that the identities which were assigned the specified
+// role of the resource's type inherit the specified role of a descendant resource type for the descendants of the
+// resource. The resource type to map to must be the type of a descendant of the resource, or the target of a default
+// role mapping of the resource's type. The privilege cache is flagged as stale for the resource and its descendants.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) CreateRoleMapping(ctx context.Context, currentIdentity uuid.UUID, resourceID string, fromRoleName string, toResourceType string, toRoleName string) (*rolerepo.RoleMapping, error) {
+	// Lookup the resourceID and ensure the resource is valid
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the current user token belongs to a user who has the necessary privileges for managing roles
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	var rm *rolerepo.RoleMapping
+	err = s.ExecuteInTransaction(func() error {
+		fromRole, err := s.Repositories().RoleRepository().Lookup(ctx, fromRoleName, res.ResourceType.Name)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return errors.NewBadParameterError("from_role", fromRoleName).Expected("a role of resource type " + res.ResourceType.Name)
+		} else if err != nil {
+			return err
+		}
+		toRole, err := s.Repositories().RoleRepository().Lookup(ctx, toRoleName, toResourceType)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return errors.NewBadParameterError("to_role", toRoleName).Expected("a role of resource type " + toResourceType)
+		} else if err != nil {
+			return err
+		}
+
+		descendantType, err := s.isDescendantResourceType(ctx, res, toRole.ResourceTypeID)
+		if err != nil {
+			return err
+		}
+		if !descendantType {
+			return errors.NewBadParameterError("to_resource_type", toResourceType).Expected("a resource type of the descendants of the resource")
+		}
+
+		mappings, err := s.Repositories().RoleMappingRepository().FindForResource(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+		for _, m := range mappings {
+			if uuid.Equal(m.FromRoleID, fromRole.RoleID) && uuid.Equal(m.ToRoleID, toRole.RoleID) {
+				return errors.NewDataConflictError(fmt.Sprintf("role '%s' is already mapped to role '%s' of resource type '%s' for the resource", fromRoleName, toRoleName, toResourceType))
+			}
+		}
+
+		rm = &rolerepo.RoleMapping{
+			ResourceID: resourceID,
+			FromRoleID: fromRole.RoleID,
+			ToRoleID:   toRole.RoleID,
+		}
+		err = s.Repositories().RoleMappingRepository().Create(ctx, rm)
+		if err != nil {
+			return err
+		}
+		rm.FromRole = *fromRole
+		rm.ToRole = *toRole
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
+
+// isDescendantResourceType returns true if the resource has a descendant of the specified resource type, or if a
+// default role mapping of the resource's type maps to a role of the specified resource type
+func (s *roleManagementServiceImpl) isDescendantResourceType(ctx context.Context, res *resource.Resource, resourceTypeID uuid.UUID) (bool, error) {
+	defaultMappings, err := s.Repositories().DefaultRoleMappingRepository().FindForResourceType(ctx, res.ResourceTypeID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range defaultMappings {
+		toRole, err := s.Repositories().RoleRepository().Load(ctx, m.ToRoleID)
+		if err != nil {
+			return false, err
+		}
+		if uuid.Equal(toRole.ResourceTypeID, resourceTypeID) {
+			return true, nil
+		}
+	}
+	return s.Repositories().ResourceRepository().HasDescendantOfType(ctx, res.ResourceID, resourceTypeID)
+}
+
+// DeleteRoleMapping deletes a role mapping of the resource, and flags the privilege cache as stale for the resource and
+// its descendants
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) DeleteRoleMapping(ctx context.Context, currentIdentity uuid.UUID, resourceID string, roleMappingID uuid.UUID) error {
+	// Lookup the resourceID and ensure the resource is valid
+	res, err := s.Repositories().ResourceRepository().Load(ctx, resourceID)
+	if err != nil {
+		return err
+	}
+
+	// check if the current user token belongs to a user who has the necessary privileges for managing roles
+	err = s.Services().PermissionService().RequireScope(ctx, currentIdentity, resourceID, authorization.ScopeForManagingRolesInResourceType(res.ResourceType.Name))
+	if err != nil {
+		return err
+	}
+
+	return s.ExecuteInTransaction(func() error {
+		rm, err := s.Repositories().RoleMappingRepository().Load(ctx, roleMappingID)
+		if err != nil {
+			return err
+		}
+		if rm.ResourceID != resourceID {
+			return errors.NewNotFoundError("role_mapping", roleMappingID.String())
+		}
+		return s.Repositories().RoleMappingRepository().Delete(ctx, roleMappingID)
+	})
+}
+
+// DeleteExpiredAssignments deletes the role assignments whose validity has ended, which flags the privilege cache and
+// the tokens of the affected identities as stale, then notifies the administrators of the resources for which the
+// roles were assigned. Up to roleAssignmentsBatchSize assignments are deleted at once.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) DeleteExpiredAssignments(ctx context.Context) error {
+	var expired []rolerepo.IdentityRole
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		expired, err = s.Repositories().IdentityRoleRepository().FindExpired(ctx, roleAssignmentsBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, ir := range expired {
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, ir.IdentityRoleID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	log.Info(ctx, map[string]interface{}{
+		"assignments": len(expired),
+	}, "expired role assignments deleted")
+
+	var messages []notification.Message
+	for _, ir := range expired {
+		admins, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResourceAndRoleName(ctx, ir.ResourceID, authorization.ResourceAdminRole, true)
+		if err != nil {
+			// the assignment was removed anyway, we will just log the error and continue
+			log.Error(ctx, map[string]interface{}{
+				"err":         err,
+				"resource_id": ir.ResourceID,
+			}, "unable to lookup the administrators of the resource to notify of an expired role assignment")
+			continue
+		}
+		notified := make(map[uuid.UUID]bool)
+		for _, admin := range admins {
+			if notified[admin.IdentityID] {
+				continue
+			}
+			notified[admin.IdentityID] = true
+			messages = append(messages, notification.NewRoleAssignmentExpiredEmail(admin.IdentityID.String(), ir.ResourceID,
+				ir.Resource.Name, ir.Role.Name, ir.Identity.Username, ir.ValidUntil.UTC().Format(time.RFC1123)))
+		}
+	}
+	if len(messages) > 0 {
+		_, err = s.Services().NotificationService().SendMessagesAsync(ctx, messages)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err": err,
+			}, "unable to notify the resource administrators of the expired role assignments")
+		}
+	}
+	return nil
+}
+
+// RefreshStartedAssignments flags the privilege cache and the tokens of the identities whose role assignments became
+// effective as stale, so that the new privileges are granted without waiting for the privilege cache to expire. Up to
+// roleAssignmentsBatchSize assignments are refreshed at once.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *roleManagementServiceImpl) RefreshStartedAssignments(ctx context.Context) error {
+	return s.ExecuteInTransaction(func() error {
+		started, err := s.Repositories().IdentityRoleRepository().FindStarted(ctx, roleAssignmentsBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, ir := range started {
+			err = s.Repositories().IdentityRoleRepository().MarkStarted(ctx, ir)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -3,16 +3,22 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/role"
 	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormapplication"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/rest"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
 
 	"github.com/jinzhu/gorm"
 	errs "github.com/pkg/errors"
@@ -235,7 +241,7 @@ func (s *roleManagementServiceBlackboxTest) checkAssignRoleOK(appendToExistingRo
 	roleAssignments[authorization.SpaceAdminRole] = usersToBeAssignedAsAdmin
 	roleAssignments[authorization.SpaceContributorRole] = usersToBeAssignedAsContributor
 
-	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), appendToExistingRoles)
+	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), appendToExistingRoles, nil)
 	require.NoError(s.T(), err)
 
 	s.addNoisyAssignments()
@@ -288,7 +294,7 @@ func (s *roleManagementServiceBlackboxTest) TestAssignRoleWithLackOfPermissionsF
 	roleAssignments := make(map[string][]uuid.UUID)
 	roleAssignments[authorization.SpaceAdminRole] = []uuid.UUID{userToBeAssigned.Identity().ID}
 
-	err := s.service.Assign(context.Background(), viewer.Identity().ID, roleAssignments, newSpace.SpaceID(), false)
+	err := s.service.Assign(context.Background(), viewer.Identity().ID, roleAssignments, newSpace.SpaceID(), false, nil)
 	testsupport.AssertError(s.T(), err, errors.ForbiddenError{}, "identity with ID %s does not have required scope manage for resource %s", viewer.Identity().ID.String(), newSpace.SpaceID())
 }
 
@@ -303,7 +309,7 @@ func (s *roleManagementServiceBlackboxTest) TestAssignRoleAlreadyExists() {
 
 	// We've already assigned the contributor and admin roles, lets try to add the admin role again
 	roleAssignments[authorization.SpaceAdminRole] = []uuid.UUID{userToBeAssigned.Identity().ID}
-	err := s.service.Assign(context.Background(), spaceAdmin.Identity().ID, roleAssignments, newSpace.SpaceID(), true)
+	err := s.service.Assign(context.Background(), spaceAdmin.Identity().ID, roleAssignments, newSpace.SpaceID(), true, nil)
 	require.Error(s.T(), err)
 	require.IsType(s.T(), errors.DataConflictError{}, errs.Cause(err))
 }
@@ -315,7 +321,7 @@ func (s *roleManagementServiceBlackboxTest) TestAssignRoleResourceNotFound() {
 	roleAssignments := make(map[string][]uuid.UUID)
 	roleAssignments[authorization.SpaceContributorRole] = userToBeAdded
 
-	err := s.service.Assign(context.Background(), identityID, roleAssignments, uuid.NewV4().String(), false)
+	err := s.service.Assign(context.Background(), identityID, roleAssignments, uuid.NewV4().String(), false, nil)
 	require.IsType(s.T(), errors.NotFoundError{}, errs.Cause(err))
 }
 
@@ -327,7 +333,7 @@ func (s *roleManagementServiceBlackboxTest) TestAssignRoleWithRoleNotFound() {
 	roleAssignments := make(map[string][]uuid.UUID)
 	roleAssignments[uuid.NewV4().String()] = userToBeAdded
 
-	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), false)
+	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), false, nil)
 	require.IsType(s.T(), errors.NotFoundError{}, errs.Cause(err))
 }
 
@@ -339,7 +345,7 @@ func (s *roleManagementServiceBlackboxTest) TestAssignRoleWithIdentityNotFound()
 	roleAssignments := make(map[string][]uuid.UUID)
 	roleAssignments[authorization.SpaceAdminRole] = userToBeAdded
 
-	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), false)
+	err := s.service.Assign(context.Background(), adminUser.Identity().ID, roleAssignments, newSpace.SpaceID(), false, nil)
 	require.IsType(s.T(), errors.BadParameterError{}, errs.Cause(err))
 }
 
@@ -549,7 +555,7 @@ func (s *roleManagementServiceBlackboxTest) TestPrivilegeCacheNotified() {
 
 	// Assign a role via the role management service Assign() function
 	assignments := map[string][]uuid.UUID{"barRole": {user.IdentityID()}}
-	err = s.Application.RoleManagementService().Assign(s.Ctx, admin.IdentityID(), assignments, res.ResourceID(), true)
+	err = s.Application.RoleManagementService().Assign(s.Ctx, admin.IdentityID(), assignments, res.ResourceID(), true, nil)
 	require.NoError(s.T(), err)
 
 	// Hit the privilege cache again
@@ -630,3 +636,240 @@ func validateAssignee(t *testing.T, amongUsers []uuid.UUID, resourceID string, r
 		require.True(t, foundUser)
 	}
 }
+
+func (s *roleManagementServiceBlackboxTest) TestAssignWithValidity() {
+	admin := s.Graph.CreateUser()
+	viewer := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(admin).AddViewer(viewer)
+	assignments := map[string][]uuid.UUID{authorization.SpaceContributorRole: {viewer.IdentityID()}}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	tomorrow := time.Now().Add(24 * time.Hour)
+	nextWeek := time.Now().Add(7 * 24 * time.Hour)
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("valid until in the past", func(t *testing.T) {
+			// when
+			err := s.service.Assign(s.Ctx, admin.IdentityID(), assignments, space.SpaceID(), true, map[string]rolerepo.Validity{
+				authorization.SpaceContributorRole: {ValidUntil: &yesterday},
+			})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("valid until before valid from", func(t *testing.T) {
+			// when
+			err := s.service.Assign(s.Ctx, admin.IdentityID(), assignments, space.SpaceID(), true, map[string]rolerepo.Validity{
+				authorization.SpaceContributorRole: {ValidFrom: &nextWeek, ValidUntil: &tomorrow},
+			})
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("not valid yet", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		sp := s.Graph.CreateSpace().AddAdmin(admin).AddViewer(user)
+		// when
+		err := s.service.Assign(s.Ctx, admin.IdentityID(), map[string][]uuid.UUID{authorization.SpaceContributorRole: {user.IdentityID()}}, sp.SpaceID(), true, map[string]rolerepo.Validity{
+			authorization.SpaceContributorRole: {ValidFrom: &tomorrow, ValidUntil: &nextWeek},
+		})
+		// then
+		require.NoError(t, err)
+		identityRoles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, sp.SpaceID(), user.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, identityRoles, 2)
+		scopes, err := s.Application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), sp.SpaceID())
+		require.NoError(t, err)
+		assert.NotContains(t, scopes, authorization.ContributeSpaceScope)
+	})
+
+	s.T().Run("expired", func(t *testing.T) {
+		// given
+		notificationServiceMock := testservice.NewNotificationServiceMock(t)
+		var messages []notification.Message
+		notificationServiceMock.SendMessagesAsyncFunc = func(ctx context.Context, msgs []notification.Message, options ...rest.HTTPClientOption) (chan error, error) {
+			messages = append(messages, msgs...)
+			return nil, nil
+		}
+		application := gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithNotificationService(notificationServiceMock))
+		user := s.Graph.CreateUser()
+		sp := s.Graph.CreateSpace().AddAdmin(admin).AddViewer(user)
+		err := application.RoleManagementService().Assign(s.Ctx, admin.IdentityID(), map[string][]uuid.UUID{authorization.SpaceContributorRole: {user.IdentityID()}}, sp.SpaceID(), true, map[string]rolerepo.Validity{
+			authorization.SpaceContributorRole: {ValidUntil: &tomorrow},
+		})
+		require.NoError(t, err)
+		scopes, err := application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), sp.SpaceID())
+		require.NoError(t, err)
+		require.Contains(t, scopes, authorization.ContributeSpaceScope)
+		// expire all the assignments with a validity, including those created by other tests
+		err = s.DB.Exec("UPDATE identity_role SET valid_until = now() - interval '1 minute' WHERE valid_until IS NOT NULL").Error
+		require.NoError(t, err)
+		scopes, err = application.IdentityRoleRepository().FindScopesByIdentityAndResource(s.Ctx, user.IdentityID(), sp.SpaceID())
+		require.NoError(t, err)
+		require.NotContains(t, scopes, authorization.ContributeSpaceScope)
+		// when
+		for {
+			expired, err := application.IdentityRoleRepository().FindExpired(s.Ctx, 1)
+			require.NoError(t, err)
+			if len(expired) == 0 {
+				break
+			}
+			err = application.RoleManagementService().DeleteExpiredAssignments(s.Ctx)
+			require.NoError(t, err)
+		}
+		// then
+		identityRoles, err := application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, sp.SpaceID(), user.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, identityRoles, 1)
+		assert.Nil(t, identityRoles[0].ValidUntil) // the viewer role remains
+		var notified bool
+		for _, msg := range messages {
+			if msg.TargetID == sp.SpaceID() {
+				notified = true
+				assert.Equal(t, "role.assignment.expired", msg.MessageType)
+				assert.Equal(t, admin.IdentityID().String(), *msg.UserID)
+				assert.Equal(t, authorization.SpaceContributorRole, msg.Custom["roleName"])
+				assert.Equal(t, user.Identity().Username, msg.Custom["assigneeName"])
+			}
+		}
+		assert.True(t, notified)
+	})
+}
+
+func (s *roleManagementServiceBlackboxTest) TestDeny() {
+	admin := s.Graph.CreateUser()
+	contributor := s.Graph.CreateUser()
+	space := s.Graph.CreateSpace().AddAdmin(admin).AddContributor(contributor)
+	denials := map[string][]uuid.UUID{authorization.ContributeSpaceScope: {contributor.IdentityID()}}
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("not an admin", func(t *testing.T) {
+			// when
+			_, err := s.service.Deny(s.Ctx, contributor.IdentityID(), denials, space.SpaceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown scope", func(t *testing.T) {
+			// when
+			_, err := s.service.Deny(s.Ctx, admin.IdentityID(), map[string][]uuid.UUID{"unknown": {contributor.IdentityID()}}, space.SpaceID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		denyAssignments, err := s.service.Deny(s.Ctx, admin.IdentityID(), denials, space.SpaceID())
+		// then
+		require.NoError(t, err)
+		require.Len(t, denyAssignments, 1)
+		granted, err := s.Application.PermissionService().HasScope(s.Ctx, contributor.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+		require.NoError(t, err)
+		assert.False(t, granted)
+		granted, err = s.Application.PermissionService().HasScope(s.Ctx, contributor.IdentityID(), space.SpaceID(), authorization.ViewSpaceScope)
+		require.NoError(t, err)
+		assert.True(t, granted)
+		listed, err := s.service.ListDenyAssignmentsByResource(s.Ctx, admin.IdentityID(), space.SpaceID())
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, contributor.IdentityID(), listed[0].IdentityID)
+		assert.Equal(t, authorization.ContributeSpaceScope, listed[0].Scope.Name)
+
+		t.Run("revoked", func(t *testing.T) {
+			// when
+			err := s.service.RevokeDenyAssignment(s.Ctx, admin.IdentityID(), space.SpaceID(), denyAssignments[0].DenyAssignmentID)
+			// then
+			require.NoError(t, err)
+			granted, err := s.Application.PermissionService().HasScope(s.Ctx, contributor.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+			require.NoError(t, err)
+			assert.True(t, granted)
+		})
+	})
+}
+
+func (s *roleManagementServiceBlackboxTest) TestRoleMappings() {
+	orgType := s.Graph.CreateResourceType()
+	orgAdmin := s.Graph.CreateRole(orgType, "admin").AddScope("manage")
+	envType := s.Graph.CreateResourceType()
+	s.Graph.CreateRole(envType, "deployer").AddScope("deploy")
+	org := s.Graph.CreateResource(orgType)
+	env := s.Graph.CreateResource(envType, org)
+	admin := s.Graph.CreateUser()
+	org.AddRole(admin, orgAdmin)
+	privs, err := s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, admin.IdentityID(), env.ResourceID())
+	require.NoError(s.T(), err)
+	require.Empty(s.T(), privs.ScopesAsArray())
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("not an admin", func(t *testing.T) {
+			// when
+			_, err := s.service.CreateRoleMapping(s.Ctx, s.Graph.CreateUser().IdentityID(), org.ResourceID(), "admin", envType.ResourceType().Name, "deployer")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+
+		t.Run("role of another resource type", func(t *testing.T) {
+			// when
+			_, err := s.service.CreateRoleMapping(s.Ctx, admin.IdentityID(), org.ResourceID(), "deployer", envType.ResourceType().Name, "deployer")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("not a descendant resource type", func(t *testing.T) {
+			// given
+			otherType := s.Graph.CreateResourceType()
+			s.Graph.CreateRole(otherType, "deployer").AddScope("deploy")
+			// when
+			_, err := s.service.CreateRoleMapping(s.Ctx, admin.IdentityID(), org.ResourceID(), "admin", otherType.ResourceType().Name, "deployer")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		roleMapping, err := s.service.CreateRoleMapping(s.Ctx, admin.IdentityID(), org.ResourceID(), "admin", envType.ResourceType().Name, "deployer")
+		// then
+		require.NoError(t, err)
+		privs, err := s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, admin.IdentityID(), env.ResourceID())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"deploy"}, privs.ScopesAsArray())
+		roleMappings, err := s.service.ListRoleMappings(s.Ctx, admin.IdentityID(), org.ResourceID())
+		require.NoError(t, err)
+		require.Len(t, roleMappings, 1)
+		assert.Equal(t, roleMapping.RoleMappingID, roleMappings[0].RoleMappingID)
+		assert.Equal(t, "admin", roleMappings[0].FromRole.Name)
+		assert.Equal(t, "deployer", roleMappings[0].ToRole.Name)
+		assert.Equal(t, envType.ResourceType().Name, roleMappings[0].ToRole.ResourceType.Name)
+
+		t.Run("duplicate", func(t *testing.T) {
+			// when
+			_, err := s.service.CreateRoleMapping(s.Ctx, admin.IdentityID(), org.ResourceID(), "admin", envType.ResourceType().Name, "deployer")
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		})
+
+		t.Run("deleted", func(t *testing.T) {
+			// when
+			err := s.service.DeleteRoleMapping(s.Ctx, admin.IdentityID(), org.ResourceID(), roleMapping.RoleMappingID)
+			// then
+			require.NoError(t, err)
+			privs, err := s.Application.PrivilegeCacheService().CachedPrivileges(s.Ctx, admin.IdentityID(), env.ResourceID())
+			require.NoError(t, err)
+			assert.Empty(t, privs.ScopesAsArray())
+		})
+	})
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+// RoleAssignmentExpiryWorker the interface for the Role Assignment Expiry Worker,
+// which takes care of deleting the role assignments whose validity has ended, and of refreshing the privileges of
+// the identities whose role assignments became effective.
+type RoleAssignmentExpiryWorker interface {
+	Start(freq time.Duration)
+	Stop()
+}
+
+const (
+	// RoleAssignmentExpiry the name of the worker that deletes the expired role assignments.
+	// Also, the name of the lock used by this worker.
+	RoleAssignmentExpiry = "role-assignment-expiry"
+)
+
+// NewRoleAssignmentExpiryWorker returns a new RoleAssignmentExpiryWorker
+func NewRoleAssignmentExpiryWorker(ctx context.Context, app application.Application) RoleAssignmentExpiryWorker {
+	w := &roleAssignmentExpiryWorker{
+		worker.Worker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: worker.GetLockOwner(ctx),
+			Name:  RoleAssignmentExpiry,
+		},
+	}
+	w.Do = w.expireRoleAssignments
+	return w
+}
+
+type roleAssignmentExpiryWorker struct {
+	worker.Worker
+}
+
+func (w *roleAssignmentExpiryWorker) expireRoleAssignments() {
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "starting cycle of role assignments expiry")
+	err := w.App.RoleManagementService().DeleteExpiredAssignments(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while deleting the expired role assignments")
+	}
+	err = w.App.RoleManagementService().RefreshStartedAssignments(w.Ctx)
+	if err != nil {
+		// We will just log the error and continue
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "error while refreshing the privileges of the started role assignments")
+	}
+	log.Debug(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+	}, "ending cycle of role assignments expiry")
+}
@@ -173,3 +173,233 @@ func (s *teamServiceImpl) ListTeamsForIdentity(ctx context.Context, identityID u
 
 	return authorization.MergeAssociations(memberships, roles), nil
 }
+
+// ListMembers returns the direct members of a team. The current identity must have the scope for viewing the members
+// of the team, or the scope for viewing the teams of the space the team belongs to.
+func (s *teamServiceImpl) ListMembers(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID) ([]account.Identity, error) {
+	team, err := s.loadTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.requireTeamScope(ctx, currentIdentity, team, authorization.ViewTeamMembersScope, authorization.ViewTeamsInSpaceScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Repositories().Identities().FindDirectMembers(ctx, teamID)
+}
+
+// AddMember adds a user to the members of a team, unless the user is already a member. The current identity must have
+// the scope for managing the members of the team, or the scope for managing the teams of the space the team belongs to.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) AddMember(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+
+		err = s.requireTeamScope(ctx, currentIdentity, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		member, err := s.Repositories().Identities().Load(ctx, memberID)
+		if err != nil {
+			return err
+		}
+		if !member.IsUser() {
+			return errors.NewBadParameterErrorFromString("memberID", memberID, "identity is not a user")
+		}
+
+		isMember, err := s.isDirectMember(ctx, teamID, memberID)
+		if err != nil || isMember {
+			return err
+		}
+
+		// the privileges cached for the new member are flagged as stale by the repository
+		return s.Services().MembershipService().AddMember(ctx, teamID, memberID)
+	})
+}
+
+// RemoveMember removes a member from a team. The current identity must have the scope for managing the members of the
+// team, or the scope for managing the teams of the space the team belongs to.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) RemoveMember(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+
+		err = s.requireTeamScope(ctx, currentIdentity, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		isMember, err := s.isDirectMember(ctx, teamID, memberID)
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			return errors.NewNotFoundError("team member", memberID.String())
+		}
+
+		// the privileges cached for the former member are flagged as stale by the repository
+		return s.Repositories().Identities().RemoveMember(ctx, teamID, memberID)
+	})
+}
+
+// RenameTeam changes the name of a team. The current identity must have the scope for managing the teams of the space
+// the team belongs to.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) RenameTeam(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID, teamName string) error {
+	return s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+
+		err = s.requireTeamScope(ctx, currentIdentity, team, authorization.ManageTeamsInSpaceScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		team.Name = teamName
+		team.ParentResource = nil
+		return s.Repositories().ResourceRepository().Save(ctx, team)
+	})
+}
+
+// DeleteTeam deletes a team, along with its memberships and the roles assigned to it. The privileges cached for its
+// members are flagged as stale. The current identity must have the scope for managing the teams of the space the team
+// belongs to.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) DeleteTeam(ctx context.Context, currentIdentity uuid.UUID, teamID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+
+		err = s.requireTeamScope(ctx, currentIdentity, team, authorization.ManageTeamsInSpaceScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		// Revoke the roles assigned to the team while its members are still known, so that their cached privileges
+		// are flagged as stale
+		identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentity(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		for _, ir := range identityRoles {
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, ir.IdentityRoleID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Remove the members of the team, and the team from the identities it is a member of
+		members, err := s.Repositories().Identities().FindDirectMembers(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = s.Repositories().Identities().RemoveMember(ctx, teamID, member.ID)
+			if err != nil {
+				return err
+			}
+		}
+		memberships, err := s.Repositories().Identities().FindDirectMemberships(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		for _, membership := range memberships {
+			err = s.Repositories().Identities().RemoveMember(ctx, membership.ID, teamID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Delete the pending invitations to the team
+		invitations, err := s.Repositories().InvitationRepository().ListForIdentity(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		for _, inv := range invitations {
+			err = s.Repositories().InvitationRepository().Delete(ctx, inv.InvitationID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Delete the team resource, along with the team identity
+		err = s.Services().ResourceService().Delete(ctx, team.ResourceID)
+		if err != nil {
+			return err
+		}
+
+		log.Debug(ctx, map[string]interface{}{
+			"team_id": teamID.String(),
+		}, "team deleted")
+
+		return nil
+	})
+}
+
+// loadTeam returns the resource of the team with the specified identity ID
+func (s *teamServiceImpl) loadTeam(ctx context.Context, teamID uuid.UUID) (*resource.Resource, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if !identity.IdentityResourceID.Valid {
+		return nil, errors.NewBadParameterErrorFromString("teamID", teamID, "identity is not a team")
+	}
+
+	team, err := s.Repositories().ResourceRepository().Load(ctx, identity.IdentityResourceID.String)
+	if err != nil {
+		return nil, err
+	}
+	if team.ResourceType.Name != authorization.IdentityResourceTypeTeam {
+		return nil, errors.NewBadParameterErrorFromString("teamID", teamID, "identity is not a team")
+	}
+
+	return team, nil
+}
+
+// requireTeamScope returns a ForbiddenError unless the identity has either the team scope for the team, or the space
+// scope for the space the team belongs to
+func (s *teamServiceImpl) requireTeamScope(ctx context.Context, identityID uuid.UUID, team *resource.Resource, teamScope string, spaceScope string) error {
+	permService := s.Services().PermissionService()
+
+	if team.ParentResourceID == nil {
+		return permService.RequireScope(ctx, identityID, team.ResourceID, teamScope)
+	}
+
+	hasScope, err := permService.HasScope(ctx, identityID, team.ResourceID, teamScope)
+	if err != nil {
+		return err
+	}
+	if hasScope {
+		return nil
+	}
+
+	return permService.RequireScope(ctx, identityID, *team.ParentResourceID, spaceScope)
+}
+
+// isDirectMember returns true if the member is a direct member of the team
+func (s *teamServiceImpl) isDirectMember(ctx context.Context, teamID uuid.UUID, memberID uuid.UUID) (bool, error) {
+	members, err := s.Repositories().Identities().FindDirectMembers(ctx, teamID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range members {
+		if m.ID == memberID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -3,10 +3,13 @@ package service_test
 import (
 	"testing"
 
+	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -289,3 +292,113 @@ func (s *teamServiceBlackBoxTest) TestListTeamsForIdentity() {
 	require.NoError(s.T(), err)
 	require.Len(s.T(), teams, 0)
 }
+
+func (s *teamServiceBlackBoxTest) TestTeamMembershipAndLifecycle() {
+	// given
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	space := g.CreateSpace().AddAdmin(admin)
+	team := g.CreateTeam(space)
+	space.AddContributor(team)
+	member := g.CreateUser()
+	outsider := g.CreateUser()
+	teamService := s.Application.TeamService()
+
+	s.T().Run("add member", func(t *testing.T) {
+		t.Run("ok", func(t *testing.T) {
+			// given
+			pc := g.CreatePrivilegeCache(member, g.LoadResource(space.SpaceID()))
+			// when
+			err := teamService.AddMember(s.Ctx, admin.IdentityID(), team.TeamID(), member.IdentityID())
+			require.NoError(t, err)
+			// adding an existing member again has no effect
+			err = teamService.AddMember(s.Ctx, admin.IdentityID(), team.TeamID(), member.IdentityID())
+			require.NoError(t, err)
+			// then
+			members, err := teamService.ListMembers(s.Ctx, admin.IdentityID(), team.TeamID())
+			require.NoError(t, err)
+			require.Len(t, members, 1)
+			assert.Equal(t, member.IdentityID(), members[0].ID)
+			assert.True(t, g.LoadPrivilegeCache(pc.PrivilegeCache().PrivilegeCacheID).PrivilegeCache().Stale)
+			hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, member.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+			require.NoError(t, err)
+			assert.True(t, hasScope)
+		})
+
+		t.Run("not a user", func(t *testing.T) {
+			// when
+			err := teamService.AddMember(s.Ctx, admin.IdentityID(), team.TeamID(), g.CreateTeam(space).TeamID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			// when
+			err := teamService.AddMember(s.Ctx, outsider.IdentityID(), team.TeamID(), outsider.IdentityID())
+			// then
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+			_, err = teamService.ListMembers(s.Ctx, outsider.IdentityID(), team.TeamID())
+			require.Error(t, err)
+			assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("rename", func(t *testing.T) {
+		// when
+		err := teamService.RenameTeam(s.Ctx, admin.IdentityID(), team.TeamID(), "renamed-team")
+		// then
+		require.NoError(t, err)
+		teams, err := teamService.ListTeamsForIdentity(s.Ctx, member.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, teams, 1)
+		assert.Equal(t, "renamed-team", teams[0].ResourceName)
+		err = teamService.RenameTeam(s.Ctx, outsider.IdentityID(), team.TeamID(), "other-name")
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove member", func(t *testing.T) {
+		// given
+		other := g.CreateUser()
+		err := teamService.AddMember(s.Ctx, admin.IdentityID(), team.TeamID(), other.IdentityID())
+		require.NoError(t, err)
+		// when
+		err = teamService.RemoveMember(s.Ctx, admin.IdentityID(), team.TeamID(), other.IdentityID())
+		// then
+		require.NoError(t, err)
+		hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, other.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+		require.NoError(t, err)
+		assert.False(t, hasScope)
+		// removing it again fails
+		err = teamService.RemoveMember(s.Ctx, admin.IdentityID(), team.TeamID(), other.IdentityID())
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		pc := g.CreatePrivilegeCache(member, g.LoadResource(space.SpaceID()))
+		// when
+		err := teamService.DeleteTeam(s.Ctx, outsider.IdentityID(), team.TeamID())
+		require.Error(t, err)
+		assert.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		err = teamService.DeleteTeam(s.Ctx, admin.IdentityID(), team.TeamID())
+		// then
+		require.NoError(t, err)
+		teams, err := teamService.ListTeamsForIdentity(s.Ctx, member.IdentityID())
+		require.NoError(t, err)
+		assert.Empty(t, teams)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentity(s.Ctx, team.TeamID())
+		require.NoError(t, err)
+		assert.Empty(t, roles)
+		assert.True(t, g.LoadPrivilegeCache(pc.PrivilegeCache().PrivilegeCacheID).PrivilegeCache().Stale)
+		hasScope, err := s.Application.PermissionService().HasScope(s.Ctx, member.IdentityID(), space.SpaceID(), authorization.ContributeSpaceScope)
+		require.NoError(t, err)
+		assert.False(t, hasScope)
+		_, err = teamService.ListMembers(s.Ctx, admin.IdentityID(), team.TeamID())
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
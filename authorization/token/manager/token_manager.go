@@ -14,6 +14,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/authentication/account"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	authclient "github.com/fabric8-services/fabric8-auth/client"
 	autherrors "github.com/fabric8-services/fabric8-auth/errors"
@@ -76,9 +77,31 @@ type TokenClaims struct {
 	SessionState  string         `json:"session_state"`
 	Approved      bool           `json:"approved"`
 	Permissions   *[]Permissions `json:"permissions"`
+	ACR           string         `json:"acr"`
+	AMR           []string       `json:"amr"`
+	Actor         *ActorClaims   `json:"act,omitempty"`
 	jwt.StandardClaims
 }
 
+// ActorClaims identifies the party acting on behalf of the subject of the token, e.g. an administrator
+// impersonating a user (`act` claim, see RFC 8693)
+type ActorClaims struct {
+	Subject  string `json:"sub"`
+	Username string `json:"preferred_username,omitempty"`
+}
+
+// TokenOption customizes the claims of the tokens generated for an identity
+type TokenOption func(claims jwt.MapClaims)
+
+// WithAuthenticationContext sets the authentication context class (`acr` claim) and the authentication methods
+// (`amr` claim) with which the user authenticated
+func WithAuthenticationContext(acr string, amr []string) TokenOption {
+	return func(claims jwt.MapClaims) {
+		claims["acr"] = acr
+		claims["amr"] = amr
+	}
+}
+
 // Permissions represents a "permissions" claim in the AuthorizationPayload
 type Permissions struct {
 	ResourceSetName *string  `json:"resource_set_name"`
@@ -143,6 +166,48 @@ func ContextIdentity(ctx context.Context) (*uuid.UUID, error) {
 	return &uuid, nil
 }
 
+// ContextAuthenticationContextClass returns the authentication context class (`acr` claim) of the token found in
+// the given context, or ACRNone if there is no token or if it has no such claim
+func ContextAuthenticationContextClass(ctx context.Context) string {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return mfa.ACRNone
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return mfa.ACRNone
+	}
+	acr, ok := claims["acr"].(string)
+	if !ok || acr == "" {
+		return mfa.ACRNone
+	}
+	return acr
+}
+
+// ContextAuthenticationMethods returns the authentication methods (`amr` claim) of the token found in the given
+// context
+func ContextAuthenticationMethods(ctx context.Context) []string {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return nil
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+	values, ok := claims["amr"].([]interface{})
+	if !ok {
+		return nil
+	}
+	methods := make([]string, 0, len(values))
+	for _, value := range values {
+		if method, ok := value.(string); ok {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
 // ContextWithTokenManager injects tokenManager in the context for every incoming request
 // Accepts Token.Manager in order to make sure that correct object is set in the context.
 // Only other possible value is nil
@@ -194,8 +259,9 @@ type TokenManager interface {
 	GenerateServiceAccountToken(saID string, saName string) (string, error)
 	GenerateUnsignedServiceAccountToken(saID string, saName string) *jwt.Token
 	GenerateUserTokenForAPIClient(ctx context.Context, providerToken oauth2.Token) (*oauth2.Token, error)
-	GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool) (*oauth2.Token, error)
+	GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool, options ...TokenOption) (*oauth2.Token, error)
 	GenerateTransientUserAccessTokenForIdentity(ctx context.Context, identity repository.Identity) (*string, error)
+	GenerateImpersonationTokenForIdentity(ctx context.Context, identity repository.Identity, actor repository.Identity, expiresIn time.Duration) (*oauth2.Token, error)
 	GenerateUserTokenUsingRefreshToken(ctx context.Context, refreshTokenString string, identity *repository.Identity, permissions []Permissions) (*oauth2.Token, error)
 	GenerateUnsignedRPTTokenForIdentity(ctx context.Context, tokenClaims *TokenClaims, identity repository.Identity, permissions *[]Permissions) (*jwt.Token, error)
 	SignRPTToken(ctx context.Context, rptToken *jwt.Token) (string, error)
@@ -324,13 +390,15 @@ func (m *tokenManager) AuthServiceAccountToken() string {
 //
 // #####################################################################################################################
 
-// GenerateUserTokenForIdentity generates an OAuth2 user token for the given identity
-func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool) (*oauth2.Token, error) {
+// GenerateUserTokenForIdentity generates an OAuth2 user token for the given identity. Unless specified otherwise
+// with the WithAuthenticationContext option, the authentication context class of the tokens is unknown.
+func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool, options ...TokenOption) (*oauth2.Token, error) {
 	nowTime := time.Now().Unix()
 	unsignedAccessToken, err := m.GenerateUnsignedUserAccessTokenForIdentity(ctx, identity)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	applyTokenOptions(unsignedAccessToken, options)
 	accessToken, err := unsignedAccessToken.SignedString(m.userAccountPrivateKey.Key)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -339,6 +407,7 @@ func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identit
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	applyTokenOptions(unsignedRefreshToken, options)
 	refreshToken, err := unsignedRefreshToken.SignedString(m.userAccountPrivateKey.Key)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -451,7 +520,14 @@ func (m *tokenManager) GenerateUnsignedUserAccessTokenFromClaims(ctx context.Con
 
 	claims["azp"] = tokenClaims.Audience
 	claims["session_state"] = tokenClaims.SessionState
-	claims["acr"] = "0"
+	if tokenClaims.Actor != nil {
+		claims["act"] = tokenClaims.Actor
+	}
+	// the authentication context of the original token is kept, so that a user who stepped up does not lose it
+	claims["acr"] = authenticationContextClass(tokenClaims)
+	if len(tokenClaims.AMR) > 0 {
+		claims["amr"] = tokenClaims.AMR
+	}
 
 	realmAccess := make(map[string]interface{})
 	realmAccess["roles"] = []string{"uma_authorization"}
@@ -535,6 +611,42 @@ func (m *tokenManager) GenerateTransientUserAccessTokenForIdentity(ctx context.C
 	return &accessToken, nil
 }
 
+// GenerateImpersonationTokenForIdentity generates a short-lived access token for the given identity, with an `act`
+// claim naming the actor who uses it on the user's behalf. No refresh token is issued, so the actor has to request
+// a new token once it expires.
+func (m *tokenManager) GenerateImpersonationTokenForIdentity(ctx context.Context, identity repository.Identity, actor repository.Identity, expiresIn time.Duration) (*oauth2.Token, error) {
+	token, err := m.GenerateUnsignedUserAccessTokenForIdentity(ctx, identity)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	iat := time.Now().Unix()
+	expiresInSeconds := int64(expiresIn / time.Second)
+	claims["exp"] = iat + expiresInSeconds
+	claims["acr"] = mfa.ACRNone
+	claims["act"] = ActorClaims{
+		Subject:  actor.ID.String(),
+		Username: actor.Username,
+	}
+
+	accessToken, err := token.SignedString(m.userAccountPrivateKey.Key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	oauthToken := &oauth2.Token{
+		AccessToken: accessToken,
+		Expiry:      time.Unix(iat+expiresInSeconds, 0),
+		TokenType:   "Bearer",
+	}
+	return oauthToken.WithExtra(map[string]interface{}{
+		"expires_in":         expiresInSeconds,
+		"refresh_expires_in": int64(0),
+		"not_before_policy":  int64(0),
+	}), nil
+}
+
 // #####################################################################################################################
 //
 // Refresh token functions (refresh tokens are used to obtain a new user token)
@@ -633,6 +745,11 @@ func (m *tokenManager) GenerateUnsignedUserRefreshToken(ctx context.Context, ref
 
 	claims["azp"] = oldClaims.Audience
 	claims["session_state"] = oldClaims.SessionState
+	// the authentication context is kept for the whole session
+	claims["acr"] = authenticationContextClass(oldClaims)
+	if len(oldClaims.AMR) > 0 {
+		claims["amr"] = oldClaims.AMR
+	}
 
 	return token, nil
 }
@@ -698,7 +815,10 @@ func (m *tokenManager) GenerateUnsignedUserAccessTokenFromRefreshToken(ctx conte
 
 	claims["azp"] = refreshTokenClaims.Audience
 	claims["session_state"] = refreshTokenClaims.SessionState
-	claims["acr"] = "0"
+	claims["acr"] = authenticationContextClass(refreshTokenClaims)
+	if len(refreshTokenClaims.AMR) > 0 {
+		claims["amr"] = refreshTokenClaims.AMR
+	}
 
 	realmAccess := make(map[string]interface{})
 	realmAccess["roles"] = []string{"uma_authorization"}
@@ -1133,6 +1253,23 @@ func (m *tokenManager) AddLoginRequiredHeaderToUnauthorizedError(err error, rw h
 //
 // #####################################################################################################################
 
+// applyTokenOptions sets the default authentication context class of the token, then applies the given options
+func applyTokenOptions(token *jwt.Token, options []TokenOption) {
+	claims := token.Claims.(jwt.MapClaims)
+	claims["acr"] = mfa.ACRNone
+	for _, option := range options {
+		option(claims)
+	}
+}
+
+// authenticationContextClass returns the `acr` claim of the given token claims, or ACRNone if it is missing
+func authenticationContextClass(claims *TokenClaims) string {
+	if claims.ACR == "" {
+		return mfa.ACRNone
+	}
+	return claims.ACR
+}
+
 // extraInt
 func (m *tokenManager) extraInt(oauthToken oauth2.Token, claimName string) (*int64, error) {
 	claim := oauthToken.Extra(claimName)
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
@@ -79,6 +80,42 @@ func (s *tokenServiceBlackboxTest) TestSimpleAuditAccessToken() {
 	require.Nil(s.T(), rptToken)
 }
 
+func (s *tokenServiceBlackboxTest) TestAuditKeepsAuthenticationContext() {
+	tm := testtoken.TokenManager
+
+	// Create a user who stepped up with a second factor
+	u := s.Graph.CreateUser()
+	at, err := tm.GenerateUserTokenForIdentity(s.Ctx, *u.Identity(), false,
+		manager.WithAuthenticationContext(mfa.ACRMultiFactor, []string{mfa.AMRPassword, mfa.AMROneTimePassword, mfa.AMRMultiFactor}))
+	require.NoError(s.T(), err)
+
+	rt := s.Graph.CreateResourceType().AddScope("echo").AddScope("foxtrot")
+	r := s.Graph.CreateResource(rt)
+	s.Graph.CreateIdentityRole(u, r, s.Graph.CreateRole(rt).AddScope("echo"))
+	r2 := s.Graph.CreateResource(rt)
+	s.Graph.CreateIdentityRole(u, r2, s.Graph.CreateRole(rt).AddScope("foxtrot"))
+
+	// Audit the user token
+	rptToken, err := s.Application.TokenService().Audit(manager.ContextWithTokenManager(s.Ctx, tm), u.Identity(), at.AccessToken, r.ResourceID())
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), rptToken)
+
+	// The RPT token keeps the authentication context of the user token
+	tokenClaims, err := tm.ParseToken(s.Ctx, *rptToken)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), mfa.ACRMultiFactor, tokenClaims.ACR)
+	assert.Equal(s.T(), []string{mfa.AMRPassword, mfa.AMROneTimePassword, mfa.AMRMultiFactor}, tokenClaims.AMR)
+
+	// And so does the RPT token replacing it for another resource
+	rptToken, err = s.Application.TokenService().Audit(manager.ContextWithTokenManager(s.Ctx, tm), u.Identity(), *rptToken, r2.ResourceID())
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), rptToken)
+	tokenClaims, err = tm.ParseToken(s.Ctx, *rptToken)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), mfa.ACRMultiFactor, tokenClaims.ACR)
+	assert.Equal(s.T(), []string{mfa.AMRPassword, mfa.AMROneTimePassword, mfa.AMRMultiFactor}, tokenClaims.AMR)
+}
+
 func (s *tokenServiceBlackboxTest) TestAuditRPTTokenReplacedWithAdditionalResource() {
 	tm := testtoken.TokenManager
 
@@ -85,6 +85,32 @@ func IsServiceAccount(ctx context.Context) bool {
 	return ok
 }
 
+// IsImpersonation checks if the request is done with a token issued to an administrator impersonating a user,
+// based on the `act` claim of the JWT Token provided in context
+func IsImpersonation(ctx context.Context) bool {
+	_, ok := ImpersonatorID(ctx)
+	return ok
+}
+
+// ImpersonatorID returns the ID of the identity impersonating the user of the JWT Token provided in context,
+// if the token is an impersonation token
+func ImpersonatorID(ctx context.Context) (string, bool) {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return "", false
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", false
+	}
+	actor, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	actorID, ok := actor["sub"].(string)
+	return actorID, ok && actorID != ""
+}
+
 func extractServiceAccountName(ctx context.Context) (string, bool) {
 	token := goajwt.ContextJWT(ctx)
 	if token == nil {
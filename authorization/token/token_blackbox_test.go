@@ -1,11 +1,16 @@
 package token_test
 
 import (
+	"context"
+	"testing"
+
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	testsuite "github.com/fabric8-services/fabric8-auth/test/suite"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"testing"
 )
 
 type tokenBlackboxTest struct {
@@ -22,3 +27,25 @@ func (s *tokenBlackboxTest) TestIsValidTokenType() {
 	require.True(s.T(), token.IsValidTokenType("RPT"))
 	require.False(s.T(), token.IsValidTokenType("foo"))
 }
+
+func (s *tokenBlackboxTest) TestIsImpersonation() {
+	withClaims := func(claims jwt.MapClaims) context.Context {
+		return goajwt.WithJWT(context.Background(), &jwt.Token{Claims: claims})
+	}
+
+	s.T().Run("impersonation token", func(t *testing.T) {
+		ctx := withClaims(jwt.MapClaims{"sub": "user", "act": map[string]interface{}{"sub": "admin"}})
+		require.True(t, token.IsImpersonation(ctx))
+		actorID, ok := token.ImpersonatorID(ctx)
+		require.True(t, ok)
+		require.Equal(t, "admin", actorID)
+	})
+
+	s.T().Run("user token", func(t *testing.T) {
+		require.False(t, token.IsImpersonation(withClaims(jwt.MapClaims{"sub": "user"})))
+	})
+
+	s.T().Run("no token", func(t *testing.T) {
+		require.False(t, token.IsImpersonation(context.Background()))
+	})
+}
@@ -91,6 +91,82 @@ const (
 	varOAuthProviderEndpointToken    = "oauth.provider.endpoint.token"
 	varOAuthProviderEndpointLogout   = "oauth.provider.endpoint.logout"
 
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// SAML 2.0 Service Provider (used when oauth.provider.type is set to "saml")
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	varSAMLServiceProviderEntityID      = "saml.sp.entityid"
+	varSAMLServiceProviderACSURL        = "saml.sp.acs.url"
+	varSAMLServiceProviderSSOURL        = "saml.sp.sso.url"
+	varSAMLIdentityProviderEntityID     = "saml.idp.entityid"
+	varSAMLIdentityProviderSSOURL       = "saml.idp.sso.url"
+	varSAMLIdentityProviderSSOBinding   = "saml.idp.sso.binding"
+	varSAMLIdentityProviderCertificates = "saml.idp.certificates" // One or more PEM encoded certificates
+	varSAMLIdentityProviderEmailTrusted = "saml.idp.email.trusted" // if true then all the asserted email addresses are verified
+	varSAMLAttributeUsername            = "saml.attribute.username"
+	varSAMLAttributeEmail               = "saml.attribute.email"
+	varSAMLAttributeEmailVerified       = "saml.attribute.emailverified"
+	varSAMLAttributeGivenName           = "saml.attribute.givenname"
+	varSAMLAttributeFamilyName          = "saml.attribute.familyname"
+	varSAMLAttributeCompany             = "saml.attribute.company"
+	varSAMLClockSkewSeconds             = "saml.clock.skew.seconds"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Local Identity Provider (used when oauth.provider.type is set to "local")
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	varLocalLoginFormURL               = "local.login.form.url"
+	varLocalLoginMaxFailedAttempts     = "local.login.max.failed.attempts"
+	varLocalLoginLockoutMinutes        = "local.login.lockout.minutes"
+	varLocalLoginCodeExpirySeconds     = "local.login.code.expiry.seconds"
+	varLocalPasswordMinLength          = "local.password.min.length"
+	varLocalPasswordRequireUppercase   = "local.password.require.uppercase"
+	varLocalPasswordRequireLowercase   = "local.password.require.lowercase"
+	varLocalPasswordRequireDigit       = "local.password.require.digit"
+	varLocalPasswordRequireSpecial     = "local.password.require.special"
+	varLocalPasswordHashCost           = "local.password.hash.cost" // bcrypt cost
+	varLocalPasswordResetURL           = "local.password.reset.url"
+	varLocalPasswordResetExpiryMinutes = "local.password.reset.expiry.minutes"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Multi-factor Authentication
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	varMFARequired               = "mfa.required" // if true then all users must enrol a second factor
+	varMFAIssuer                 = "mfa.issuer"   // the issuer name displayed by authenticator apps
+	varMFAClockSkewSteps         = "mfa.clock.skew.steps"
+	varMFARecoveryCodesCount     = "mfa.recovery.codes.count"
+	varMFAChallengeURL           = "mfa.challenge.url"
+	varMFAChallengeExpiryMinutes = "mfa.challenge.expiry.minutes"
+	varMFAChallengeMaxAttempts   = "mfa.challenge.max.attempts"
+	varMFAMaxFailedAttempts      = "mfa.max.failed.attempts"
+	varMFALockoutMinutes         = "mfa.lockout.minutes"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Impersonation
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	varImpersonationTokenExpiryMinutes = "impersonation.token.expiry.minutes"
+	varImpersonationNotifyUser         = "impersonation.notify.user" // if true then impersonated users receive a notification
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// OAuth state
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	varOAuthStateStateless     = "oauth.state.stateless" // if true then the OAuth state is encrypted in the state parameter instead of stored in the DB
+	varOAuthStateKey           = "oauth.state.key"
+	varOAuthStateExpiryMinutes = "oauth.state.expiry.minutes"
+
 	//------------------------------------------------------------------------------------------------------------------
 	//
 	// Service Account Keys
@@ -178,6 +254,65 @@ const (
 
 	varPrivilegeCacheExpirySeconds = "privilege.cache.expiry.seconds"
 	varRPTTokenMaxPermissions      = "rpt.token.max.permissions"
+	// varPrivilegeCacheLocalSize is the maximum number of privilege cache records kept in memory by each replica,
+	// 0 to disable the in-memory cache
+	varPrivilegeCacheLocalSize = "privilege.cache.local.size"
+	// varPrivilegeCacheLocalTTLSeconds is the maximum number of seconds a privilege cache record is kept in memory
+	varPrivilegeCacheLocalTTLSeconds = "privilege.cache.local.ttl.seconds"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Role assignments
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varRoleAssignmentExpiryWorkerIntervalMinutes is the interval between 2 cycles of the role assignment expiry worker in minutes
+	varRoleAssignmentExpiryWorkerIntervalMinutes = "role.assignment.expiry.worker.interval.minutes"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Access reports
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varAccessReportWorkerIntervalSeconds is the interval between 2 cycles of the access report worker in seconds
+	varAccessReportWorkerIntervalSeconds = "access.report.worker.interval.seconds"
+	// varAccessReportMaxSizeBytes is the maximum size of the content of an access report in bytes
+	varAccessReportMaxSizeBytes = "access.report.max.size.bytes"
+	// varAccessReportRetentionHours is the number of hours an access report is kept for, after it was completed or failed
+	varAccessReportRetentionHours = "access.report.retention.hours"
+	// varAccessReportPurgeWorkerIntervalMinutes is the interval between 2 cycles of the access report purge worker in minutes
+	varAccessReportPurgeWorkerIntervalMinutes = "access.report.purge.worker.interval.minutes"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Memberships
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varMembershipMaxDepth is the maximum length of a chain of nested memberships, from a user to the outermost
+	// organization or group it is a member of
+	varMembershipMaxDepth = "membership.max.depth"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Invitations
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varInvitationExpiryHours is the number of hours an invitation can be accepted for, after it was issued or resent
+	varInvitationExpiryHours = "invitation.expiry.hours"
+	// varInvitationPurgeWorkerIntervalMinutes is the interval between 2 cycles of the invitation purge worker in minutes
+	varInvitationPurgeWorkerIntervalMinutes = "invitation.purge.worker.interval.minutes"
+	// varInvitationImportSyncLimit is the maximum number of rows of a bulk invitation import processed immediately,
+	// larger imports are processed by the invitation import worker
+	varInvitationImportSyncLimit = "invitation.import.sync.limit"
+	// varInvitationImportEmailBatchSize is the maximum number of invitation e-mails sent at once during a bulk import
+	varInvitationImportEmailBatchSize = "invitation.import.email.batch.size"
+	// varInvitationImportEmailBatchDelayMillis is the delay between 2 batches of invitation e-mails in milliseconds
+	varInvitationImportEmailBatchDelayMillis = "invitation.import.email.batch.delay.millis"
+	// varInvitationImportWorkerIntervalSeconds is the interval between 2 cycles of the invitation import worker in seconds
+	varInvitationImportWorkerIntervalSeconds = "invitation.import.worker.interval.seconds"
 
 	//------------------------------------------------------------------------------------------------------------------
 	//
@@ -313,6 +448,9 @@ func NewConfigurationData(mainConfigFile string, serviceAccountConfigFile string
 	if c.GetGitHubClientSecret() == defaultGitHubClientSecret {
 		c.appendDefaultConfigErrorMessage("default GitHub client secret is used")
 	}
+	if c.IsStatelessOAuthStateEnabled() && string(c.GetOAuthStateKey()) == defaultOAuthStateKey {
+		c.appendDefaultConfigErrorMessage("default OAuth state key is used")
+	}
 	if c.GetValidRedirectURLs() == ".*" {
 		c.appendDefaultConfigErrorMessage("no restrictions for valid redirect URLs")
 	}
@@ -574,6 +712,72 @@ func (c *ConfigurationData) setConfigDefaults() {
 	c.v.SetDefault(varOAuthProviderEndpointUserInfo, defaultOAuthProviderEndpointUserInfo)
 	c.v.SetDefault(varOAuthProviderEndpointLogout, defaultOAuthProviderEndpointLogout)
 
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// SAML 2.0 Service Provider Defaults
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	c.v.SetDefault(varSAMLIdentityProviderSSOBinding, defaultSAMLIdentityProviderSSOBinding)
+	c.v.SetDefault(varSAMLAttributeUsername, defaultSAMLAttributeUsername)
+	c.v.SetDefault(varSAMLAttributeEmail, defaultSAMLAttributeEmail)
+	c.v.SetDefault(varSAMLAttributeGivenName, defaultSAMLAttributeGivenName)
+	c.v.SetDefault(varSAMLAttributeFamilyName, defaultSAMLAttributeFamilyName)
+	c.v.SetDefault(varSAMLAttributeCompany, defaultSAMLAttributeCompany)
+	c.v.SetDefault(varSAMLIdentityProviderEmailTrusted, false)
+	c.v.SetDefault(varSAMLClockSkewSeconds, defaultSAMLClockSkewSeconds)
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Local Identity Provider Defaults
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	c.v.SetDefault(varLocalLoginMaxFailedAttempts, defaultLocalLoginMaxFailedAttempts)
+	c.v.SetDefault(varLocalLoginLockoutMinutes, defaultLocalLoginLockoutMinutes)
+	c.v.SetDefault(varLocalLoginCodeExpirySeconds, defaultLocalLoginCodeExpirySeconds)
+	c.v.SetDefault(varLocalPasswordMinLength, defaultLocalPasswordMinLength)
+	c.v.SetDefault(varLocalPasswordRequireUppercase, true)
+	c.v.SetDefault(varLocalPasswordRequireLowercase, true)
+	c.v.SetDefault(varLocalPasswordRequireDigit, true)
+	c.v.SetDefault(varLocalPasswordRequireSpecial, false)
+	c.v.SetDefault(varLocalPasswordHashCost, defaultLocalPasswordHashCost)
+	c.v.SetDefault(varLocalPasswordResetExpiryMinutes, defaultLocalPasswordResetExpiryMinutes)
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Multi-factor Authentication Defaults
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	c.v.SetDefault(varMFARequired, false)
+	c.v.SetDefault(varMFAIssuer, defaultMFAIssuer)
+	c.v.SetDefault(varMFAClockSkewSteps, defaultMFAClockSkewSteps)
+	c.v.SetDefault(varMFARecoveryCodesCount, defaultMFARecoveryCodesCount)
+	c.v.SetDefault(varMFAChallengeExpiryMinutes, defaultMFAChallengeExpiryMinutes)
+	c.v.SetDefault(varMFAChallengeMaxAttempts, defaultMFAChallengeMaxAttempts)
+	c.v.SetDefault(varMFAMaxFailedAttempts, defaultMFAMaxFailedAttempts)
+	c.v.SetDefault(varMFALockoutMinutes, defaultMFALockoutMinutes)
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Impersonation Defaults
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	c.v.SetDefault(varImpersonationTokenExpiryMinutes, defaultImpersonationTokenExpiryMinutes)
+	c.v.SetDefault(varImpersonationNotifyUser, false)
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// OAuth state Defaults
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	c.v.SetDefault(varOAuthStateStateless, false)
+	c.v.SetDefault(varOAuthStateKey, defaultOAuthStateKey)
+	c.v.SetDefault(varOAuthStateExpiryMinutes, defaultOAuthStateExpiryMinutes)
+
 	//------------------------------------------------------------------------------------------------------------------
 	//
 	// Http
@@ -657,6 +861,30 @@ func (c *ConfigurationData) setConfigDefaults() {
 	// RPT Token maximum permissions
 	c.v.SetDefault(varRPTTokenMaxPermissions, 10)
 
+	// In-memory privilege cache
+	c.v.SetDefault(varPrivilegeCacheLocalSize, defaultPrivilegeCacheLocalSize)
+	c.v.SetDefault(varPrivilegeCacheLocalTTLSeconds, defaultPrivilegeCacheLocalTTLSeconds)
+
+	// Role assignments
+	c.v.SetDefault(varRoleAssignmentExpiryWorkerIntervalMinutes, defaultRoleAssignmentExpiryWorkerIntervalMinutes)
+
+	// Access reports
+	c.v.SetDefault(varAccessReportWorkerIntervalSeconds, defaultAccessReportWorkerIntervalSeconds)
+	c.v.SetDefault(varAccessReportMaxSizeBytes, defaultAccessReportMaxSizeBytes)
+	c.v.SetDefault(varAccessReportRetentionHours, defaultAccessReportRetentionHours)
+	c.v.SetDefault(varAccessReportPurgeWorkerIntervalMinutes, defaultAccessReportPurgeWorkerIntervalMinutes)
+
+	// Memberships
+	c.v.SetDefault(varMembershipMaxDepth, defaultMembershipMaxDepth)
+
+	// Invitations
+	c.v.SetDefault(varInvitationExpiryHours, defaultInvitationExpiryHours)
+	c.v.SetDefault(varInvitationPurgeWorkerIntervalMinutes, defaultInvitationPurgeWorkerIntervalMinutes)
+	c.v.SetDefault(varInvitationImportSyncLimit, defaultInvitationImportSyncLimit)
+	c.v.SetDefault(varInvitationImportEmailBatchSize, defaultInvitationImportEmailBatchSize)
+	c.v.SetDefault(varInvitationImportEmailBatchDelayMillis, defaultInvitationImportEmailBatchDelayMillis)
+	c.v.SetDefault(varInvitationImportWorkerIntervalSeconds, defaultInvitationImportWorkerIntervalSeconds)
+
 	// Expired token retention time, after which tokens will be cleaned up
 	c.v.SetDefault(varExpiredTokenRetentionHours, defaultExpiredTokenRetentionHours)
 
@@ -953,6 +1181,228 @@ func (c *ConfigurationData) GetOAuthProviderEndpointLogout() string {
 	return c.v.GetString(varOAuthProviderEndpointLogout)
 }
 
+// GetSAMLServiceProviderEntityID returns the entity ID of this service when acting as a SAML 2.0 service provider.
+// If nothing set then the URL of the service provider metadata endpoint is used.
+func (c *ConfigurationData) GetSAMLServiceProviderEntityID() string {
+	if c.v.IsSet(varSAMLServiceProviderEntityID) {
+		return c.v.GetString(varSAMLServiceProviderEntityID)
+	}
+	return c.GetAuthServiceURL() + "/api/saml/metadata"
+}
+
+// GetSAMLServiceProviderACSURL returns the URL of the assertion consumer service to which the SAML identity provider
+// delivers its responses. If nothing set then the URL is calculated from the Auth service URL.
+func (c *ConfigurationData) GetSAMLServiceProviderACSURL() string {
+	if c.v.IsSet(varSAMLServiceProviderACSURL) {
+		return c.v.GetString(varSAMLServiceProviderACSURL)
+	}
+	return c.GetAuthServiceURL() + "/api/saml/acs"
+}
+
+// GetSAMLServiceProviderSSOURL returns the URL of the endpoint which initiates a login with the SAML identity provider
+// when the HTTP-POST binding is used. If nothing set then the URL is calculated from the Auth service URL.
+func (c *ConfigurationData) GetSAMLServiceProviderSSOURL() string {
+	if c.v.IsSet(varSAMLServiceProviderSSOURL) {
+		return c.v.GetString(varSAMLServiceProviderSSOURL)
+	}
+	return c.GetAuthServiceURL() + "/api/saml/sso"
+}
+
+// GetSAMLIdentityProviderEntityID returns the entity ID of the SAML identity provider, which must match the issuer
+// of the received assertions
+func (c *ConfigurationData) GetSAMLIdentityProviderEntityID() string {
+	return c.v.GetString(varSAMLIdentityProviderEntityID)
+}
+
+// GetSAMLIdentityProviderSSOURL returns the single sign-on service URL of the SAML identity provider
+func (c *ConfigurationData) GetSAMLIdentityProviderSSOURL() string {
+	return c.v.GetString(varSAMLIdentityProviderSSOURL)
+}
+
+// GetSAMLIdentityProviderSSOBinding returns the binding (HTTP-Redirect or HTTP-POST) used to send authentication
+// requests to the SAML identity provider
+func (c *ConfigurationData) GetSAMLIdentityProviderSSOBinding() string {
+	return c.v.GetString(varSAMLIdentityProviderSSOBinding)
+}
+
+// GetSAMLIdentityProviderCertificates returns the PEM encoded certificates used to verify the signatures of the SAML
+// identity provider. More than one certificate may be configured to allow for key rollover.
+func (c *ConfigurationData) GetSAMLIdentityProviderCertificates() string {
+	return c.v.GetString(varSAMLIdentityProviderCertificates)
+}
+
+// GetSAMLAttributeMapping returns the names of the SAML assertion attributes which are mapped to the user profile,
+// keyed by the user profile field
+func (c *ConfigurationData) GetSAMLAttributeMapping() map[string]string {
+	return map[string]string{
+		"username":       c.v.GetString(varSAMLAttributeUsername),
+		"email":          c.v.GetString(varSAMLAttributeEmail),
+		"email_verified": c.v.GetString(varSAMLAttributeEmailVerified),
+		"given_name":     c.v.GetString(varSAMLAttributeGivenName),
+		"family_name":    c.v.GetString(varSAMLAttributeFamilyName),
+		"company":        c.v.GetString(varSAMLAttributeCompany),
+	}
+}
+
+// IsSAMLIdentityProviderEmailTrusted returns true if the SAML identity provider is trusted to have verified all the
+// email addresses it asserts. Otherwise an email address is only verified if the attribute mapped to `email_verified`
+// says so.
+func (c *ConfigurationData) IsSAMLIdentityProviderEmailTrusted() bool {
+	return c.v.GetBool(varSAMLIdentityProviderEmailTrusted)
+}
+
+// GetSAMLClockSkew returns the tolerated clock skew when validating the time conditions of SAML assertions
+func (c *ConfigurationData) GetSAMLClockSkew() time.Duration {
+	return time.Duration(c.v.GetInt(varSAMLClockSkewSeconds)) * time.Second
+}
+
+// GetLocalLoginFormURL returns the URL of the login form of the local identity provider.
+// If nothing set then the URL is calculated from the Auth service URL.
+func (c *ConfigurationData) GetLocalLoginFormURL() string {
+	if c.v.IsSet(varLocalLoginFormURL) {
+		return c.v.GetString(varLocalLoginFormURL)
+	}
+	return c.GetAuthServiceURL() + "/api/login/local"
+}
+
+// GetLocalLoginMaxFailedAttempts returns the number of consecutive failed login attempts after which a local
+// credential is locked
+func (c *ConfigurationData) GetLocalLoginMaxFailedAttempts() int {
+	return c.v.GetInt(varLocalLoginMaxFailedAttempts)
+}
+
+// GetLocalLoginLockoutDuration returns how long a local credential is locked after too many failed login attempts
+func (c *ConfigurationData) GetLocalLoginLockoutDuration() time.Duration {
+	return time.Duration(c.v.GetInt(varLocalLoginLockoutMinutes)) * time.Minute
+}
+
+// GetLocalLoginCodeExpiry returns how long the one-time code issued after a successful local login remains valid
+func (c *ConfigurationData) GetLocalLoginCodeExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varLocalLoginCodeExpirySeconds)) * time.Second
+}
+
+// GetLocalPasswordMinLength returns the minimum length of local passwords
+func (c *ConfigurationData) GetLocalPasswordMinLength() int {
+	return c.v.GetInt(varLocalPasswordMinLength)
+}
+
+// GetLocalPasswordRequireUppercase returns true if local passwords must contain an uppercase letter
+func (c *ConfigurationData) GetLocalPasswordRequireUppercase() bool {
+	return c.v.GetBool(varLocalPasswordRequireUppercase)
+}
+
+// GetLocalPasswordRequireLowercase returns true if local passwords must contain a lowercase letter
+func (c *ConfigurationData) GetLocalPasswordRequireLowercase() bool {
+	return c.v.GetBool(varLocalPasswordRequireLowercase)
+}
+
+// GetLocalPasswordRequireDigit returns true if local passwords must contain a digit
+func (c *ConfigurationData) GetLocalPasswordRequireDigit() bool {
+	return c.v.GetBool(varLocalPasswordRequireDigit)
+}
+
+// GetLocalPasswordRequireSpecial returns true if local passwords must contain a special character
+func (c *ConfigurationData) GetLocalPasswordRequireSpecial() bool {
+	return c.v.GetBool(varLocalPasswordRequireSpecial)
+}
+
+// GetLocalPasswordHashCost returns the bcrypt cost used to hash local passwords
+func (c *ConfigurationData) GetLocalPasswordHashCost() int {
+	return c.v.GetInt(varLocalPasswordHashCost)
+}
+
+// GetLocalPasswordResetURL returns the URL of the password reset form, to which the reset code is appended in the
+// password reset emails. If nothing set then the URL is calculated from the Auth service URL.
+func (c *ConfigurationData) GetLocalPasswordResetURL() string {
+	if c.v.IsSet(varLocalPasswordResetURL) {
+		return c.v.GetString(varLocalPasswordResetURL)
+	}
+	return c.GetAuthServiceURL() + "/api/password/reset"
+}
+
+// GetLocalPasswordResetExpiry returns how long a password reset code remains valid
+func (c *ConfigurationData) GetLocalPasswordResetExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varLocalPasswordResetExpiryMinutes)) * time.Minute
+}
+
+// IsMFARequired returns true if all users must enrol a second factor, which they are then asked for at every login.
+// Otherwise only the users who have enrolled one are asked for it.
+func (c *ConfigurationData) IsMFARequired() bool {
+	return c.v.GetBool(varMFARequired)
+}
+
+// GetMFAIssuer returns the issuer name under which authenticator apps display the TOTP secret
+func (c *ConfigurationData) GetMFAIssuer() string {
+	return c.v.GetString(varMFAIssuer)
+}
+
+// GetMFAClockSkew returns the number of TOTP time steps of clock drift tolerated in either direction
+func (c *ConfigurationData) GetMFAClockSkew() int {
+	return c.v.GetInt(varMFAClockSkewSteps)
+}
+
+// GetMFARecoveryCodesCount returns the number of recovery codes generated for a user
+func (c *ConfigurationData) GetMFARecoveryCodesCount() int {
+	return c.v.GetInt(varMFARecoveryCodesCount)
+}
+
+// GetMFAChallengeURL returns the URL of the form in which users enter their second factor during login.
+// If nothing set then the URL is calculated from the Auth service URL.
+func (c *ConfigurationData) GetMFAChallengeURL() string {
+	if c.v.IsSet(varMFAChallengeURL) {
+		return c.v.GetString(varMFAChallengeURL)
+	}
+	return c.GetAuthServiceURL() + "/api/mfa/challenge"
+}
+
+// GetMFAChallengeExpiry returns how long a user has to enter their second factor during login
+func (c *ConfigurationData) GetMFAChallengeExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varMFAChallengeExpiryMinutes)) * time.Minute
+}
+
+// GetMFAChallengeMaxAttempts returns the number of invalid codes after which a login challenge is cancelled
+func (c *ConfigurationData) GetMFAChallengeMaxAttempts() int {
+	return c.v.GetInt(varMFAChallengeMaxAttempts)
+}
+
+// GetMFAMaxFailedAttempts returns the number of consecutive invalid codes after which the second factor of a user
+// is locked, whatever the operation for which the codes were entered
+func (c *ConfigurationData) GetMFAMaxFailedAttempts() int {
+	return c.v.GetInt(varMFAMaxFailedAttempts)
+}
+
+// GetMFALockoutDuration returns how long the second factor of a user is locked after too many invalid codes
+func (c *ConfigurationData) GetMFALockoutDuration() time.Duration {
+	return time.Duration(c.v.GetInt(varMFALockoutMinutes)) * time.Minute
+}
+
+// GetImpersonationTokenExpiry returns the lifetime of the access tokens issued to administrators impersonating a user
+func (c *ConfigurationData) GetImpersonationTokenExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varImpersonationTokenExpiryMinutes)) * time.Minute
+}
+
+// IsImpersonationNotificationEnabled returns true if users are notified when an administrator impersonates them
+func (c *ConfigurationData) IsImpersonationNotificationEnabled() bool {
+	return c.v.GetBool(varImpersonationNotifyUser)
+}
+
+// IsStatelessOAuthStateEnabled returns true if the OAuth state of logins and account links is encrypted into the
+// state parameter and bound to a browser cookie, rather than stored in the DB. States created in either mode are
+// accepted by the callbacks, so that the mode can be switched while logins are in progress.
+func (c *ConfigurationData) IsStatelessOAuthStateEnabled() bool {
+	return c.v.GetBool(varOAuthStateStateless)
+}
+
+// GetOAuthStateKey returns the secret from which the key used to encrypt stateless OAuth states is derived
+func (c *ConfigurationData) GetOAuthStateKey() []byte {
+	return []byte(c.v.GetString(varOAuthStateKey))
+}
+
+// GetOAuthStateExpiry returns how long a user has to complete a login or an account link started in stateless mode
+func (c *ConfigurationData) GetOAuthStateExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varOAuthStateExpiryMinutes)) * time.Minute
+}
+
 // GetNotificationServiceURL returns the URL for the Notification service used for event notification
 func (c *ConfigurationData) GetNotificationServiceURL() string {
 	return c.v.GetString(varNotificationServiceURL)
@@ -1082,6 +1532,79 @@ func (c *ConfigurationData) GetPrivilegeCacheExpirySeconds() int64 {
 	return c.v.GetInt64(varPrivilegeCacheExpirySeconds)
 }
 
+// GetPrivilegeCacheLocalSize returns the maximum number of privilege cache records kept in memory by each replica.
+// The in-memory cache is disabled if the size is 0.
+func (c *ConfigurationData) GetPrivilegeCacheLocalSize() int {
+	return c.v.GetInt(varPrivilegeCacheLocalSize)
+}
+
+// GetPrivilegeCacheLocalTTL returns the maximum duration during which a privilege cache record is kept in memory
+func (c *ConfigurationData) GetPrivilegeCacheLocalTTL() time.Duration {
+	return time.Duration(c.v.GetInt64(varPrivilegeCacheLocalTTLSeconds)) * time.Second
+}
+
+// GetRoleAssignmentExpiryWorkerIntervalMinutes returns the interval between 2 cycles of the role assignment expiry worker.
+func (c *ConfigurationData) GetRoleAssignmentExpiryWorkerIntervalMinutes() time.Duration {
+	return time.Duration(c.v.GetInt(varRoleAssignmentExpiryWorkerIntervalMinutes)) * time.Minute
+}
+
+// GetAccessReportWorkerIntervalSeconds returns the interval between 2 cycles of the access report worker.
+func (c *ConfigurationData) GetAccessReportWorkerIntervalSeconds() time.Duration {
+	return time.Duration(c.v.GetInt(varAccessReportWorkerIntervalSeconds)) * time.Second
+}
+
+// GetAccessReportMaxSizeBytes returns the maximum size of the content of an access report. A report which would be
+// larger than this fails.
+func (c *ConfigurationData) GetAccessReportMaxSizeBytes() int64 {
+	return c.v.GetInt64(varAccessReportMaxSizeBytes)
+}
+
+// GetAccessReportRetention returns how long an access report is kept for, after it was completed or failed.
+func (c *ConfigurationData) GetAccessReportRetention() time.Duration {
+	return time.Duration(c.v.GetInt(varAccessReportRetentionHours)) * time.Hour
+}
+
+// GetAccessReportPurgeWorkerIntervalMinutes returns the interval between 2 cycles of the access report purge worker.
+func (c *ConfigurationData) GetAccessReportPurgeWorkerIntervalMinutes() time.Duration {
+	return time.Duration(c.v.GetInt(varAccessReportPurgeWorkerIntervalMinutes)) * time.Minute
+}
+
+// GetMembershipMaxDepth returns the maximum length of a chain of nested memberships. A membership which would make a
+// chain longer than this is rejected.
+func (c *ConfigurationData) GetMembershipMaxDepth() int {
+	return c.v.GetInt(varMembershipMaxDepth)
+}
+
+// GetInvitationExpiry returns how long an invitation can be accepted for, after it was issued or resent.
+func (c *ConfigurationData) GetInvitationExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varInvitationExpiryHours)) * time.Hour
+}
+
+// GetInvitationPurgeWorkerIntervalMinutes returns the interval between 2 cycles of the invitation purge worker.
+func (c *ConfigurationData) GetInvitationPurgeWorkerIntervalMinutes() time.Duration {
+	return time.Duration(c.v.GetInt(varInvitationPurgeWorkerIntervalMinutes)) * time.Minute
+}
+
+// GetInvitationImportSyncLimit returns the maximum number of rows of a bulk invitation import processed immediately.
+func (c *ConfigurationData) GetInvitationImportSyncLimit() int {
+	return c.v.GetInt(varInvitationImportSyncLimit)
+}
+
+// GetInvitationImportEmailBatchSize returns the maximum number of invitation e-mails sent at once during a bulk import.
+func (c *ConfigurationData) GetInvitationImportEmailBatchSize() int {
+	return c.v.GetInt(varInvitationImportEmailBatchSize)
+}
+
+// GetInvitationImportEmailBatchDelay returns the delay between 2 batches of invitation e-mails during a bulk import.
+func (c *ConfigurationData) GetInvitationImportEmailBatchDelay() time.Duration {
+	return time.Duration(c.v.GetInt(varInvitationImportEmailBatchDelayMillis)) * time.Millisecond
+}
+
+// GetInvitationImportWorkerIntervalSeconds returns the interval between 2 cycles of the invitation import worker.
+func (c *ConfigurationData) GetInvitationImportWorkerIntervalSeconds() time.Duration {
+	return time.Duration(c.v.GetInt(varInvitationImportWorkerIntervalSeconds)) * time.Second
+}
+
 // GetRPTTokenMaxPermissions returns the maximum number of permissions that may be stored in an RPT token
 func (c *ConfigurationData) GetRPTTokenMaxPermissions() int {
 	return c.v.GetInt(varRPTTokenMaxPermissions)
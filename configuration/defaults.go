@@ -94,6 +94,71 @@ vwIDAQAB
 	defaultOAuthProviderEndpointUserInfo = "https://sso.prod-preview.openshift.io/auth/realms/fabric8-test/protocol/openid-connect/userinfo"
 	defaultOAuthProviderEndpointLogout   = "https://sso.prod-preview.openshift.io/auth/realms/fabric8-test/protocol/openid-connect/logout"
 
+	// SAML 2.0 Service Provider defaults
+	defaultSAMLIdentityProviderSSOBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	defaultSAMLAttributeUsername          = "username"
+	defaultSAMLAttributeEmail             = "email"
+	defaultSAMLAttributeGivenName         = "givenName"
+	defaultSAMLAttributeFamilyName        = "sn"
+	defaultSAMLAttributeCompany           = "company"
+	defaultSAMLClockSkewSeconds           = 60
+
+	// Local Identity Provider defaults
+	defaultLocalLoginMaxFailedAttempts     = 5
+	defaultLocalLoginLockoutMinutes        = 15
+	defaultLocalLoginCodeExpirySeconds     = 60
+	defaultLocalPasswordMinLength          = 10
+	defaultLocalPasswordHashCost           = 12
+	defaultLocalPasswordResetExpiryMinutes = 60
+
+	// Multi-factor Authentication defaults
+	defaultMFAIssuer                 = "fabric8-auth"
+	defaultMFAClockSkewSteps         = 1
+	defaultMFARecoveryCodesCount     = 10
+	defaultMFAChallengeExpiryMinutes = 5
+	defaultMFAChallengeMaxAttempts   = 5
+	defaultMFAMaxFailedAttempts      = 10
+	defaultMFALockoutMinutes         = 15
+
+	// Impersonation defaults
+	defaultImpersonationTokenExpiryMinutes = 15
+
+	// OAuth state defaults
+	defaultOAuthStateKey           = "6b2f0c9a8e4d47f1b3a5c7e9d1f3a5b7"
+	defaultOAuthStateExpiryMinutes = 10
+
+	// In-memory privilege cache defaults
+	defaultPrivilegeCacheLocalSize       = 10000
+	defaultPrivilegeCacheLocalTTLSeconds = 60
+
+	// defaultRoleAssignmentExpiryWorkerIntervalMinutes the default interval between 2 cycles of the role assignment expiry worker
+	defaultRoleAssignmentExpiryWorkerIntervalMinutes = 5
+
+	// defaultAccessReportWorkerIntervalSeconds the default interval between 2 cycles of the access report worker
+	defaultAccessReportWorkerIntervalSeconds = 30
+	// defaultAccessReportMaxSizeBytes the default maximum size of the content of an access report
+	defaultAccessReportMaxSizeBytes = 100 * 1024 * 1024 // 100 MiB
+	// defaultAccessReportRetentionHours the default number of hours an access report is kept for
+	defaultAccessReportRetentionHours = 7 * 24 // 7 days
+	// defaultAccessReportPurgeWorkerIntervalMinutes the default interval between 2 cycles of the access report purge worker
+	defaultAccessReportPurgeWorkerIntervalMinutes = 60
+
+	// defaultMembershipMaxDepth the default maximum length of a chain of nested memberships
+	defaultMembershipMaxDepth = 10
+
+	// defaultInvitationExpiryHours the default number of hours an invitation can be accepted for
+	defaultInvitationExpiryHours = 7 * 24 // 7 days
+	// defaultInvitationPurgeWorkerIntervalMinutes the default interval between 2 cycles of the invitation purge worker
+	defaultInvitationPurgeWorkerIntervalMinutes = 60
+	// defaultInvitationImportSyncLimit the default maximum number of rows of a bulk invitation import processed immediately
+	defaultInvitationImportSyncLimit = 20
+	// defaultInvitationImportEmailBatchSize the default maximum number of invitation e-mails sent at once during a bulk import
+	defaultInvitationImportEmailBatchSize = 50
+	// defaultInvitationImportEmailBatchDelayMillis the default delay between 2 batches of invitation e-mails
+	defaultInvitationImportEmailBatchDelayMillis = 1000
+	// defaultInvitationImportWorkerIntervalSeconds the default interval between 2 cycles of the invitation import worker
+	defaultInvitationImportWorkerIntervalSeconds = 30
+
 	defaultPublicOAuthClientID = "740650a2-9c44-4db5-b067-a3d1b2cd2d01"
 	defaultWITDomainPrefix     = "api"
 	devModeWITURL              = "http://localhost:8080"
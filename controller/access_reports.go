@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	accessreportrepo "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	"github.com/goadesign/goa"
+)
+
+// AccessReportsController implements the access_reports resource.
+type AccessReportsController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewAccessReportsController creates an access_reports controller.
+func NewAccessReportsController(service *goa.Service, app application.Application) *AccessReportsController {
+	return &AccessReportsController{
+		Controller: service.NewController("AccessReportsController"),
+		app:        app,
+	}
+}
+
+// Request runs the request action, which creates a pending access report of a resource and all its descendants
+func (c *AccessReportsController) Request(ctx *app.RequestAccessReportsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	report, err := c.app.AccessReportService().Request(ctx, *currentIdentity, ctx.Payload.Data.ResourceID, ctx.Payload.Data.Format)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.Payload.Data.ResourceID,
+			"err":         err,
+		}, "error requesting the access report")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, app.AccessReportsHref(report.AccessReportID), nil))
+	return ctx.Accepted(convertAccessReportToApp(ctx.RequestData, *report))
+}
+
+// Show runs the show action, which returns the status of an access report
+func (c *AccessReportsController) Show(ctx *app.ShowAccessReportsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	report, err := c.app.AccessReportService().Load(ctx, *currentIdentity, ctx.ReportID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertAccessReportToApp(ctx.RequestData, *report))
+}
+
+// Download runs the download action, which returns the content of a completed access report
+func (c *AccessReportsController) Download(ctx *app.DownloadAccessReportsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	report, err := c.app.AccessReportService().Load(ctx, *currentIdentity, ctx.ReportID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if report.Status != accessreportrepo.StatusCompleted {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewDataConflictError("the access report is "+report.Status))
+	}
+	if report.Format == accessreportrepo.FormatNDJSON {
+		ctx.ResponseData.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		ctx.ResponseData.Header().Set("Content-Type", "text/csv")
+	}
+	ctx.ResponseData.Header().Set("Content-Disposition", "attachment; filename=\"access-report-"+report.AccessReportID.String()+"."+report.Format+"\"")
+	ctx.ResponseData.Header().Set("Content-Length", strconv.FormatInt(report.Size, 10))
+	ctx.ResponseData.WriteHeader(http.StatusOK)
+	// the content is streamed chunk by chunk, so an error can only be logged once the status was sent, and the client
+	// sees a content shorter than its length
+	err = c.app.AccessReportService().WriteContent(ctx, ctx.ResponseData, *report)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_report_id": report.AccessReportID,
+			"err":              err,
+		}, "error writing the content of the access report")
+	}
+	return nil
+}
+
+func convertAccessReportToApp(request *goa.RequestData, report accessreportrepo.AccessReport) *app.AccessReport {
+	self := rest.AbsoluteURL(request, app.AccessReportsHref(report.AccessReportID), nil)
+	data := &app.AccessReportData{
+		ID:          report.AccessReportID,
+		ResourceID:  report.ResourceID,
+		Format:      report.Format,
+		Status:      report.Status,
+		Error:       report.Error,
+		CreatedAt:   report.CreatedAt,
+		CompletedAt: report.CompletedAt,
+		Links: &app.GenericLinks{
+			Self: &self,
+		},
+	}
+	if report.Status == accessreportrepo.StatusCompleted {
+		content := self + "/content"
+		data.Links.Related = &content
+	}
+	return &app.AccessReport{
+		Data: data,
+	}
+}
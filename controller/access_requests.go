@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	accessrequestrepo "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+)
+
+// AccessRequestsController implements the access_requests resource.
+type AccessRequestsController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewAccessRequestsController creates an access_requests controller.
+func NewAccessRequestsController(service *goa.Service, app application.Application) *AccessRequestsController {
+	return &AccessRequestsController{
+		Controller: service.NewController("AccessRequestsController"),
+		app:        app,
+	}
+}
+
+// Create runs the create action, which requests a role for a resource on behalf of the current user
+func (c *AccessRequestsController) Create(ctx *app.CreateAccessRequestsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	var message string
+	if ctx.Payload.Data.Message != nil {
+		message = *ctx.Payload.Data.Message
+	}
+	request, err := c.app.AccessRequestService().Request(ctx, *currentIdentity, ctx.ResourceID, ctx.Payload.Data.Role, message)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+			"err":         err,
+		}, "error requesting access to the resource")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.Created(&app.AccessRequest{
+		Data: convertAccessRequestToApp(*request),
+	})
+}
+
+// List runs the list action, which returns the pending access requests for a resource
+func (c *AccessRequestsController) List(ctx *app.ListAccessRequestsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	requests, err := c.app.AccessRequestService().ListPending(ctx, *currentIdentity, ctx.ResourceID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := []*app.AccessRequestData{}
+	for _, request := range requests {
+		data = append(data, convertAccessRequestToApp(request))
+	}
+	return ctx.OK(&app.AccessRequests{
+		Data: data,
+	})
+}
+
+// Approve runs the approve action, which assigns the requested role to the user who requested it
+func (c *AccessRequestsController) Approve(ctx *app.ApproveAccessRequestsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	request, err := c.app.AccessRequestService().Approve(ctx, *currentIdentity, ctx.ResourceID, ctx.RequestID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_request_id": ctx.RequestID,
+			"err":               err,
+		}, "error approving the access request")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.AccessRequest{
+		Data: convertAccessRequestToApp(*request),
+	})
+}
+
+// Deny runs the deny action, which rejects an access request
+func (c *AccessRequestsController) Deny(ctx *app.DenyAccessRequestsContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	var reason string
+	if ctx.Payload != nil && ctx.Payload.Data.Reason != nil {
+		reason = *ctx.Payload.Data.Reason
+	}
+	request, err := c.app.AccessRequestService().Deny(ctx, *currentIdentity, ctx.ResourceID, ctx.RequestID, reason)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"access_request_id": ctx.RequestID,
+			"err":               err,
+		}, "error denying the access request")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.AccessRequest{
+		Data: convertAccessRequestToApp(*request),
+	})
+}
+
+func convertAccessRequestToApp(request accessrequestrepo.AccessRequest) *app.AccessRequestData {
+	data := &app.AccessRequestData{
+		ID:          request.AccessRequestID,
+		ResourceID:  request.ResourceID,
+		RequesterID: request.RequesterID,
+		Role:        request.Role.Name,
+		Status:      request.Status,
+		CreatedAt:   request.CreatedAt,
+		DecidedAt:   request.DecidedAt,
+	}
+	if request.Requester.Username != "" {
+		username := request.Requester.Username
+		data.RequesterName = &username
+	}
+	if request.Message != "" {
+		message := request.Message
+		data.Message = &message
+	}
+	if request.Reason != "" {
+		reason := request.Reason
+		data.Reason = &reason
+	}
+	return data
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"sort"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
@@ -48,32 +49,41 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 
 	isServiceAccount := token.IsSpecificServiceAccount(ctx, token.Notification)
 
-	var currentIdentity *account.Identity
 	if !isServiceAccount {
-		currentIdentity, err = c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+		_, err = c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
 		if err != nil {
 			return jsonapi.JSONErrorResponse(ctx, err)
 		}
 	}
 
-	cm := make(map[uuid.UUID]account.Identity) // Use map to check duplications
-	var collaborators []account.Identity
-	// Collect all contributors and admins of the space
-	contributors, err := c.listRoles(ctx, currentIdentity, authorization.SpaceContributorRole, isServiceAccount)
-	if err != nil {
-		return jsonapi.JSONErrorResponse(ctx, err)
+	// By default only the contributors and admins of the space are listed
+	roleNames := ctx.Role
+	if len(roleNames) == 0 {
+		roleNames = []string{authorization.SpaceContributorRole, authorization.SpaceAdminRole}
 	}
-	for _, c := range contributors {
-		cm[c.IdentityID] = c.Identity
-		collaborators = append(collaborators, c.Identity)
+	roleFilter := make(map[string]bool, len(roleNames))
+	for _, roleName := range roleNames {
+		_, err := c.spaceRole(ctx, roleName)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		roleFilter[roleName] = true
+	}
+
+	// We can't check if the current identity has permissions to list collaborators because it breaks the existing collaborators API
+	// So, using the repositories which don't check permissions instead of the services even if the current identity is not a service account
+	q := ""
+	if ctx.Q != nil {
+		q = *ctx.Q
 	}
-	admins, err := c.listRoles(ctx, currentIdentity, authorization.SpaceAdminRole, isServiceAccount)
+	all, err := c.findCollaborators(ctx, ctx.SpaceID.String(), q)
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
-	for _, a := range admins {
-		if _, found := cm[a.IdentityID]; !found { // Add admins only if they are not already added as contributors
-			collaborators = append(collaborators, a.Identity)
+	var collaborators []collaborator
+	for _, cb := range all {
+		if roleFilter[cb.role] {
+			collaborators = append(collaborators, cb)
 		}
 	}
 
@@ -90,14 +100,13 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 	page := collaborators[pageOffset:pageLimit]
 	resultIdentities := make([]account.Identity, len(page))
 	resultUsers := make([]account.User, len(page))
-	for i, idn := range page {
-		user, err := c.app.Users().Load(ctx, idn.UserID.UUID)
+	for i := range page {
+		identity, err := c.app.Identities().LoadWithUser(ctx, page[i].identityID)
 		if err != nil {
 			return jsonapi.JSONErrorResponse(ctx, autherrors.NewInternalError(ctx, err))
 		}
-		idn.User = *user
-		resultUsers[i] = idn.User
-		resultIdentities[i] = idn
+		resultUsers[i] = identity.User
+		resultIdentities[i] = *identity
 	}
 	log.Debug(ctx, map[string]interface{}{"offset": offset, "limit": limit, "page_offset": pageOffset, "page_limit": pageLimit, "count": len(resultIdentities), "resource_id": ctx.SpaceID.String()}, "listed collaborators for resource")
 
@@ -105,6 +114,8 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 		data := make([]*app.UserData, len(page))
 		for i := range resultUsers {
 			appUser := ConvertToAppUser(ctx.RequestData, &resultUsers[i], &resultIdentities[i], isServiceAccount)
+			role := page[i].role
+			appUser.Data.Attributes.Role = &role
 			data[i] = appUser.Data
 		}
 		response := app.UserList{
@@ -117,13 +128,59 @@ func (c *CollaboratorsController) List(ctx *app.ListCollaboratorsContext) error
 	})
 }
 
-func (c *CollaboratorsController) listRoles(ctx *app.ListCollaboratorsContext, currentIdentity *account.Identity, roleName string, isServiceAccount bool) ([]rolerepo.IdentityRole, error) {
-	//if isServiceAccount {
-	// We can't check if the current identity has permissions to list collaborators because it breaks the existing collaborators API
-	// So, using the repo which doesn't check permissions instead of the service even if the current identity is not a service account
-	return c.app.IdentityRoleRepository().FindIdentityRolesByResourceAndRoleName(ctx, ctx.SpaceID.String(), roleName, false)
-	//}
-	//return c.app.RoleManagementService().ListByResourceAndRoleName(ctx, currentIdentity.ID, ctx.SpaceID.String(), roleName)
+// spaceRoleRanks orders the standard space roles from the least to the most privileged. Any other role of the
+// space resource type ranks below the viewer role.
+var spaceRoleRanks = map[string]int{
+	authorization.SpaceViewerRole:      1,
+	authorization.SpaceContributorRole: 2,
+	authorization.SpaceAdminRole:       3,
+}
+
+// collaborator is a user identity holding a role in a space, along with its effective role
+type collaborator struct {
+	identityID uuid.UUID
+	role       string
+}
+
+// findCollaborators returns the user identities holding a role in the space, either assigned directly or inherited
+// from the role mappings of its ancestors, along with the most privileged role each one holds. The roles assigned to
+// organizations, teams and security groups are held by their users, directly or through nested memberships. If q is
+// not empty, only the users whose username, full name or public email address contains it are returned. The
+// collaborators are ordered from the least to the most privileged role.
+func (c *CollaboratorsController) findCollaborators(ctx context.Context, spaceID string, q string) ([]collaborator, error) {
+	spaceCollaborators, err := c.app.IdentityRoleRepository().FindSpaceCollaborators(ctx, spaceID, q)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[uuid.UUID]int)
+	var collaborators []collaborator
+	for _, sc := range spaceCollaborators {
+		if i, found := indexes[sc.IdentityID]; found {
+			if spaceRoleRanks[sc.RoleName] > spaceRoleRanks[collaborators[i].role] {
+				collaborators[i].role = sc.RoleName
+			}
+			continue
+		}
+		indexes[sc.IdentityID] = len(collaborators)
+		collaborators = append(collaborators, collaborator{identityID: sc.IdentityID, role: sc.RoleName})
+	}
+	sort.SliceStable(collaborators, func(i, j int) bool {
+		return spaceRoleRanks[collaborators[i].role] < spaceRoleRanks[collaborators[j].role]
+	})
+	return collaborators, nil
+}
+
+// spaceRole looks up the role with the given name for the space resource type, returning a bad parameter error
+// if the space resource type has no such role
+func (c *CollaboratorsController) spaceRole(ctx context.Context, roleName string) (*rolerepo.Role, error) {
+	r, err := c.app.RoleRepository().Lookup(ctx, roleName, authorization.ResourceTypeSpace)
+	if err != nil {
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			return nil, autherrors.NewBadParameterErrorFromString("role", roleName, "no such role for the space resource type")
+		}
+		return nil, err
+	}
+	return r, nil
 }
 
 func (c *CollaboratorsController) checkSpaceExist(ctx context.Context, spaceID string) error {
@@ -146,8 +203,8 @@ func (c *CollaboratorsController) Add(ctx *app.AddCollaboratorsContext) error {
 	}
 
 	identityIDs := []*app.UpdateUserID{{ID: ctx.IdentityID}}
-	// Assign contributor role to the collaborator
-	err = c.addContributors(ctx, currentIdentity.ID, identityIDs, ctx.SpaceID.String())
+	// Assign the requested role (contributor by default) to the collaborator
+	err = c.addCollaborators(ctx, currentIdentity.ID, identityIDs, ctx.SpaceID.String(), ctx.Role)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err":      err,
@@ -167,8 +224,8 @@ func (c *CollaboratorsController) AddMany(ctx *app.AddManyCollaboratorsContext)
 	}
 
 	if ctx.Payload != nil && ctx.Payload.Data != nil {
-		// Assign contributor role to the collaborators
-		err := c.addContributors(ctx, currentIdentity.ID, ctx.Payload.Data, ctx.SpaceID.String())
+		// Assign the requested role (contributor by default) to the collaborators
+		err := c.addCollaborators(ctx, currentIdentity.ID, ctx.Payload.Data, ctx.SpaceID.String(), ctx.Role)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"err":      err,
@@ -181,7 +238,7 @@ func (c *CollaboratorsController) AddMany(ctx *app.AddManyCollaboratorsContext)
 	return ctx.OK([]byte{})
 }
 
-func (c *CollaboratorsController) addContributors(ctx context.Context, currentIdentity uuid.UUID, contributors []*app.UpdateUserID, spaceID string) error {
+func (c *CollaboratorsController) addCollaborators(ctx context.Context, currentIdentity uuid.UUID, contributors []*app.UpdateUserID, spaceID string, roleName *string) error {
 	err := c.checkSpaceExist(ctx, spaceID)
 	if err != nil {
 		return err
@@ -192,6 +249,15 @@ func (c *CollaboratorsController) addContributors(ctx context.Context, currentId
 		return err
 	}
 
+	role := authorization.SpaceContributorRole
+	if roleName != nil && *roleName != "" {
+		r, err := c.spaceRole(ctx, *roleName)
+		if err != nil {
+			return err
+		}
+		role = r.Name
+	}
+
 	res := resource.Resource{ResourceType: resourcetype.ResourceType{Name: authorization.ResourceTypeSpace}, ResourceID: spaceID}
 	for _, contributor := range contributors {
 		identityID, err := uuid.FromString(contributor.ID)
@@ -200,17 +266,18 @@ func (c *CollaboratorsController) addContributors(ctx context.Context, currentId
 		}
 
 		// Have to use ForceAssign() because Assign() requires assignees to already have any role in the space
-		err = c.app.RoleManagementService().ForceAssign(ctx, identityID, authorization.SpaceContributorRole, res)
+		err = c.app.RoleManagementService().ForceAssign(ctx, identityID, role, res)
 		if err != nil {
 
 			if _, ok := errs.Cause(err).(autherrors.DataConflictError); ok {
-				// If the error occured because the user is already a contributor
+				// If the error occured because the user already has the role
 				// we log the error, and proceed - instead of returning a non-200 response.
 				log.Warn(ctx, map[string]interface{}{
 					"err":      err,
 					"identity": contributor.ID,
 					"resource": res.ResourceID,
-				}, "identity already has the contributor role(s) associated with the resource")
+					"role":     role,
+				}, "identity already has the role associated with the resource")
 			} else {
 				return err
 			}
@@ -2,12 +2,15 @@ package controller_test
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/app/test"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	. "github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/resource"
@@ -71,7 +74,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 			spaceID, err := uuid.FromString(space.SpaceID())
 			require.NoError(t, err)
 			// when
-			res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// then
 			assertResponseHeaders(t, res)
 			checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity())
@@ -84,15 +87,15 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 			space := g.CreateSpace().AddAdmin(admin)
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredController(admin.Identity())
-			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			checkCollaborators(t, actualUsers, admin.Identity())
 			currentIdentity := g.CreateUser().Identity()
 			svc, ctrl = s.NewSecuredController(currentIdentity)
 			// 403 from Auth
 			// We have to allow any OSIO user to list collaborators. See https://github.com/fabric8-services/fabric8-auth/pull/521 for details
-			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// when
-			_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// then
 			checkCollaborators(t, actualUsers, admin.Identity()) // viewer user is not included, since she has no `collaborate` scope
 		})
@@ -106,7 +109,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredControllerWithServiceAccount(testsupport.TestNotificationIdentity)
 			// when
-			res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// then
 			assertResponseHeaders(t, res)
 			checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity())
@@ -127,7 +130,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "0"
 				limit := 3
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity()) // viewer user is not included, since she has no `collaborate` scope
 				assertResponseHeaders(t, res)
@@ -138,7 +141,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "0"
 				limit := 5
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity()) // viewer user is not included, since she has no `collaborate` scope
 				assertResponseHeaders(t, res)
@@ -149,7 +152,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "1"
 				limit := 1
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				assertResponseHeaders(t, res)
 				checkCollaborators(t, actualUsers, admin.Identity()) // because contributors are collected before admins, so 1st contrib is skipped from results page
@@ -160,7 +163,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "1"
 				limit := 10
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				assertResponseHeaders(t, res)
 				checkCollaborators(t, actualUsers, admin.Identity()) // because contributors are collected before admins, so 1st contrib is skipped from results page
@@ -171,7 +174,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "2"
 				limit := 1
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				assertResponseHeaders(t, res)
 				checkCollaborators(t, actualUsers) // expect no result
@@ -182,7 +185,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				offset := "3"
 				limit := 10
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &limit, &offset, nil, nil, nil, nil)
 				// then
 				assert.Empty(t, actualUsers.Data)
 				assertResponseHeaders(t, res) // expect no result either
@@ -205,7 +208,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				require.NoError(t, err)
 				// when
 				ifModifiedSince := app.ToHTTPTime(time.Now().Add(-1 * time.Hour))
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, &ifModifiedSince, nil)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, &ifModifiedSince, nil)
 				// then
 				checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity())
 				assertResponseHeaders(t, res)
@@ -224,7 +227,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				require.NoError(t, err)
 				ifNoneMatch := "foo"
 				// when
-				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, &ifNoneMatch)
+				res, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, &ifNoneMatch)
 				// then
 				checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity())
 				assertResponseHeaders(t, res)
@@ -238,11 +241,11 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				space := g.CreateSpace().AddAdmin(admin).AddContributor(contrib)
 				spaceID, _ := uuid.FromString(space.SpaceID())
 				svc, ctrl := s.NewSecuredController(admin.Identity())
-				res, _ := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+				res, _ := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 				lastModified, err := getHeader(res, app.LastModified)
 				require.NoError(t, err)
 				// when
-				res = test.ListCollaboratorsNotModified(t, svc.Context, svc, ctrl, spaceID, nil, nil, lastModified, nil)
+				res = test.ListCollaboratorsNotModified(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, lastModified, nil)
 				// then
 				assertResponseHeaders(t, res)
 			})
@@ -255,11 +258,11 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 				space := g.CreateSpace().AddAdmin(admin).AddContributor(contrib)
 				spaceID, _ := uuid.FromString(space.SpaceID())
 				svc, ctrl := s.NewSecuredController(admin.Identity())
-				res, _ := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+				res, _ := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 				etag, err := getHeader(res, app.ETag)
 				require.NoError(t, err)
 				// when
-				res = test.ListCollaboratorsNotModified(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, etag)
+				res = test.ListCollaboratorsNotModified(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, etag)
 				// then
 				assertResponseHeaders(t, res)
 			})
@@ -273,7 +276,7 @@ func (s *CollaboratorsControllerTestSuite) TestListCollaborators() {
 			// given
 			svc, ctrl := s.NewUnsecuredController()
 			// when/then
-			test.ListCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil, nil, nil)
+			test.ListCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, nil, nil, nil, nil, nil)
 		})
 	})
 
@@ -290,7 +293,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 		spaceID, _ := uuid.FromString(space.SpaceID())
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		// when
-		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		// then
 		require.Len(t, actualUsers.Data, 2)
 		assert.ElementsMatch(t,
@@ -298,13 +301,13 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 			[]string{*actualUsers.Data[0].ID, *actualUsers.Data[1].ID})
 		// given
 		extraUser := g.CreateUser()
-		test.AddCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String())
+		test.AddCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String(), nil)
 		// when
-		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		// then
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity(), extraUser.Identity())
 		// try adding again, should still return OK
-		test.AddCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String())
+		test.AddCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String(), nil)
 	})
 
 	s.T().Run("not found", func(t *testing.T) {
@@ -313,7 +316,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 		admin := g.CreateUser()
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		// when/then
-		test.AddCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), uuid.NewV4().String())
+		test.AddCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), uuid.NewV4().String(), nil)
 	})
 
 	s.T().Run("bad request", func(t *testing.T) {
@@ -325,7 +328,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 		spaceID, _ := uuid.FromString(space.SpaceID())
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		// when/then
-		test.AddCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, "wrongFormatID")
+		test.AddCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, "wrongFormatID", nil)
 	})
 
 	s.T().Run("unauthorized", func(t *testing.T) {
@@ -340,7 +343,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 			svc, ctrl := s.NewUnsecuredController()
 			extraUser := g.CreateUser()
 			// when/then
-			test.AddCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String())
+			test.AddCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String(), nil)
 		})
 
 		t.Run("banned user", func(t *testing.T) {
@@ -353,7 +356,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddSingleCollaborator() {
 			svc, ctrl := s.NewUnsecuredControllerBannedUser()
 			extraUser := g.CreateUser()
 			// when/then
-			test.AddCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String())
+			test.AddCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, extraUser.IdentityID().String(), nil)
 
 		})
 	})
@@ -371,25 +374,25 @@ func (s *CollaboratorsControllerTestSuite) TestAddManyCollaborators() {
 		spaceID, _ := uuid.FromString(space.SpaceID())
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		// when
-		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		// then
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity())
 		// given
 		viewer1 := g.CreateUser()
 		payload := newAddManyCollaboratorsPayload(t, admin.Identity(), contrib.Identity(), viewer1.Identity())
-		test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, payload)
+		test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 		// when
-		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		// then
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity(), viewer1.Identity())
 		// If an identity already has a role, do not bother.
 		// given
 		viewer2 := g.CreateUser()
 		payload = newAddManyCollaboratorsPayload(t, admin.Identity(), contrib.Identity(), viewer1.Identity(), viewer2.Identity())
-		test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, payload)
+		test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 
 		// when
-		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		// then
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity(), viewer1.Identity(), viewer2.Identity())
 	})
@@ -401,7 +404,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddManyCollaborators() {
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		payload := newAddManyCollaboratorsPayload(t)
 		// when/then
-		test.AddManyCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), payload)
+		test.AddManyCollaboratorsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), nil, payload)
 	})
 
 	s.T().Run("bad request", func(t *testing.T) {
@@ -413,7 +416,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddManyCollaborators() {
 		svc, ctrl := s.NewSecuredController(admin.Identity())
 		payload := newAddManyCollaboratorsPayload(t, "foo")
 		// when/then
-		test.AddManyCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, payload)
+		test.AddManyCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 	})
 
 	s.T().Run("unauthorized", func(t *testing.T) {
@@ -427,7 +430,7 @@ func (s *CollaboratorsControllerTestSuite) TestAddManyCollaborators() {
 			svc, ctrl := s.NewUnsecuredController()
 			payload := newAddManyCollaboratorsPayload(t, admin.IdentityID())
 			// when/then
-			test.AddManyCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, payload)
+			test.AddManyCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 		})
 
 		t.Run("banned user", func(t *testing.T) {
@@ -439,13 +442,156 @@ func (s *CollaboratorsControllerTestSuite) TestAddManyCollaborators() {
 			svc, ctrl := s.NewUnsecuredControllerBannedUser()
 			payload := newAddManyCollaboratorsPayload(t, admin.IdentityID())
 			// when/then
-			test.AddManyCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, payload)
+			test.AddManyCollaboratorsUnauthorized(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 		})
 
 	})
 
 }
 
+func (s *CollaboratorsControllerTestSuite) TestCollaboratorRoles() {
+
+	s.T().Run("add with role", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		viewer := g.CreateUser()
+		otherAdmin := g.CreateUser()
+		space := g.CreateSpace().AddAdmin(admin)
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		viewerRole := authorization.SpaceViewerRole
+		adminRole := authorization.SpaceAdminRole
+		// when
+		test.AddCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, viewer.IdentityID().String(), &viewerRole)
+		test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, &adminRole, newAddManyCollaboratorsPayload(t, otherAdmin.IdentityID()))
+		// then the viewer is not listed by default
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			admin.IdentityID().String():      authorization.SpaceAdminRole,
+			otherAdmin.IdentityID().String(): authorization.SpaceAdminRole,
+		})
+		// but only when filtering on the viewer role
+		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, []string{viewerRole}, nil, nil)
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			viewer.IdentityID().String(): authorization.SpaceViewerRole,
+		})
+	})
+
+	s.T().Run("unknown role", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		space := g.CreateSpace().AddAdmin(admin)
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		unknownRole := "role-" + uuid.NewV4().String()
+		// when/then
+		test.AddCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, g.CreateUser().IdentityID().String(), &unknownRole)
+		test.AddManyCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, &unknownRole, newAddManyCollaboratorsPayload(t, g.CreateUser().IdentityID()))
+		test.ListCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, []string{unknownRole}, nil, nil)
+	})
+
+	s.T().Run("search", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		contrib := g.CreateUser()
+		space := g.CreateSpace().AddAdmin(admin).AddContributor(contrib)
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		q := strings.ToUpper(contrib.Identity().Username)
+		// when
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, &q, nil, nil, nil)
+		// then
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			contrib.IdentityID().String(): authorization.SpaceContributorRole,
+		})
+		assert.Equal(t, 1, actualUsers.Meta.TotalCount)
+	})
+
+	s.T().Run("assignments which are not effective", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		expired := g.CreateUser()
+		notYetValid := g.CreateUser()
+		expiredTeamMember := g.CreateUser()
+		space := g.CreateSpace().AddAdmin(admin)
+		team := g.CreateTeam(space).AddMember(expiredTeamMember)
+		contributorRole := g.RoleByNameAndResourceType(authorization.SpaceContributorRole, authorization.ResourceTypeSpace)
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		for identityID, validity := range map[uuid.UUID]rolerepo.Validity{
+			expired.IdentityID():     {ValidUntil: &past},
+			notYetValid.IdentityID(): {ValidFrom: &future},
+			team.TeamID():            {ValidUntil: &past},
+		} {
+			err := s.Application.IdentityRoleRepository().Create(s.Ctx, &rolerepo.IdentityRole{
+				IdentityID: identityID,
+				ResourceID: space.SpaceID(),
+				RoleID:     contributorRole.Role().RoleID,
+				ValidFrom:  validity.ValidFrom,
+				ValidUntil: validity.ValidUntil,
+			})
+			require.NoError(t, err)
+		}
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		// when
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+		// then
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			admin.IdentityID().String(): authorization.SpaceAdminRole,
+		})
+	})
+
+	s.T().Run("inherited from organization", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		member := g.CreateUser()
+		org := g.CreateOrganization(admin)
+		orgRole := g.CreateRole(g.LoadResourceType(authorization.IdentityResourceTypeOrganization))
+		g.CreateRoleMapping(org.Resource(), orgRole, g.RoleByNameAndResourceType(authorization.SpaceContributorRole, authorization.ResourceTypeSpace))
+		org.AddRole(member, orgRole)
+		space := g.CreateSpace(org).AddAdmin(admin).AddViewer(member)
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		// when
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+		// then the contributor role inherited from the organization prevails over the viewer role of the space
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			admin.IdentityID().String():  authorization.SpaceAdminRole,
+			member.IdentityID().String(): authorization.SpaceContributorRole,
+		})
+	})
+
+	s.T().Run("inherited from team and nested memberships", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		admin := g.CreateUser()
+		teamMember := g.CreateUser()
+		nestedMember := g.CreateUser()
+		space := g.CreateSpace().AddAdmin(admin).AddViewer(teamMember)
+		team := g.CreateTeam(space).AddMember(teamMember)
+		otherTeam := g.CreateTeam(space).AddMember(nestedMember)
+		org := g.CreateOrganization(admin).AddMember(otherTeam)
+		space.AddContributor(team).AddContributor(org)
+		spaceID, _ := uuid.FromString(space.SpaceID())
+		svc, ctrl := s.NewSecuredController(admin.Identity())
+		// when
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+		// then the users of the team and of the team which is a member of the organization are listed, but not the
+		// team and the organization themselves
+		checkCollaboratorRoles(t, actualUsers, map[string]string{
+			admin.IdentityID().String():        authorization.SpaceAdminRole,
+			teamMember.IdentityID().String():   authorization.SpaceContributorRole,
+			nestedMember.IdentityID().String(): authorization.SpaceContributorRole,
+		})
+	})
+}
+
 func (s *CollaboratorsControllerTestSuite) TestRemoveSingleCollaborator() {
 
 	s.T().Run("ok", func(t *testing.T) {
@@ -457,12 +603,12 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveSingleCollaborator() {
 		space := g.CreateSpace().AddAdmin(admin).AddContributor(contrib).AddViewer(viewer)
 		spaceID, _ := uuid.FromString(space.SpaceID())
 		svc, ctrl := s.NewSecuredController(admin.Identity())
-		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib.Identity()) // viewer user is not included, since she has no `collaborate` scope
 		// when
 		test.RemoveCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, contrib.IdentityID().String())
 		// then
-		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers = test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		checkCollaborators(t, actualUsers, admin.Identity()) // viewer user is not included, since she has no `collaborate` scope
 	})
 
@@ -502,19 +648,19 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveSingleCollaborator() {
 			space := g.CreateSpace().AddAdmin(admin)
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredController(admin.Identity())
-			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			checkCollaborators(t, actualUsers, admin.Identity())
 			currentIdentity := g.CreateUser().Identity()
 			svc, ctrl = s.NewSecuredController(currentIdentity)
 			// 403 from Auth
 			// We have to allow any OSIO user to list collaborators. See https://github.com/fabric8-services/fabric8-auth/pull/521 for details
-			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
-			test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+			test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// when
 			payload := newAddManyCollaboratorsPayload(t, g.CreateUser().Identity())
 			// then
-			test.AddCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, g.CreateUser().IdentityID().String())
-			test.AddManyCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, payload)
+			test.AddCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, g.CreateUser().IdentityID().String(), nil)
+			test.AddManyCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, payload)
 		})
 
 		t.Run("remove", func(t *testing.T) {
@@ -524,14 +670,14 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveSingleCollaborator() {
 			space := g.CreateSpace().AddAdmin(admin)
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredController(admin.Identity())
-			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			checkCollaborators(t, actualUsers, admin.Identity())
 			currentIdentity := g.CreateUser().Identity()
 			svc, ctrl = s.NewSecuredController(currentIdentity)
 			// 403 from Auth
 			// We have to allow any OSIO user to list collaborators. See https://github.com/fabric8-services/fabric8-auth/pull/521 for details
-			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
-			test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			//test.ListCollaboratorsForbidden(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
+			test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			// when
 			payload := newRemoveManyCollaboratorsPayload(t, g.CreateUser().Identity())
 			// then
@@ -550,8 +696,8 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveSingleCollaborator() {
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredController(admin.Identity())
 			addPayload := newAddManyCollaboratorsPayload(t, admin.Identity())
-			test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, addPayload)
-			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			test.AddManyCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, addPayload)
+			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			checkCollaborators(t, actualUsers, admin.Identity())
 			// when/then
 			test.RemoveCollaboratorsBadRequest(t, svc.Context, svc, ctrl, spaceID, admin.IdentityID().String())
@@ -589,7 +735,7 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveMultipleCollaborator() {
 		space := g.CreateSpace().AddAdmin(admin).AddContributor(contrib1).AddContributor(contrib2)
 		spaceID, _ := uuid.FromString(space.SpaceID())
 		svc, ctrl := s.NewSecuredController(admin.Identity())
-		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+		_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 		checkCollaborators(t, actualUsers, admin.Identity(), contrib1.Identity(), contrib2.Identity())
 		payload := newRemoveManyCollaboratorsPayload(t, contrib1.Identity(), contrib2.Identity())
 		// when/then
@@ -605,7 +751,7 @@ func (s *CollaboratorsControllerTestSuite) TestRemoveMultipleCollaborator() {
 			space := g.CreateSpace().AddAdmin(admin)
 			spaceID, _ := uuid.FromString(space.SpaceID())
 			svc, ctrl := s.NewSecuredController(admin.Identity())
-			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil)
+			_, actualUsers := test.ListCollaboratorsOK(t, svc.Context, svc, ctrl, spaceID, nil, nil, nil, nil, nil, nil)
 			checkCollaborators(t, actualUsers, admin.Identity())
 			payload := newRemoveManyCollaboratorsPayload(t, admin.Identity())
 			// when/then
@@ -731,6 +877,17 @@ func checkCollaborators(t *testing.T, actualUsers *app.UserList, expectedIdentit
 
 }
 
+func checkCollaboratorRoles(t *testing.T, actualUsers *app.UserList, expectedRoles map[string]string) {
+	require.Len(t, actualUsers.Data, len(expectedRoles))
+	for _, data := range actualUsers.Data {
+		require.NotNil(t, data.ID)
+		expectedRole, found := expectedRoles[*data.ID]
+		require.True(t, found, "unexpected collaborator %s", *data.ID)
+		require.NotNil(t, data.Attributes.Role)
+		assert.Equal(t, expectedRole, *data.Attributes.Role)
+	}
+}
+
 func assertResponseHeaders(t *testing.T, res http.ResponseWriter) (string, string, string) {
 	lastModified, err := getHeader(res, app.LastModified)
 	require.NoError(t, err)
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/goadesign/goa"
+)
+
+// GroupController implements the group resource.
+type GroupController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewGroupController creates a group controller.
+func NewGroupController(service *goa.Service, app application.Application) *GroupController {
+	return &GroupController{Controller: service.NewController("GroupController"), app: app}
+}
+
+// Create runs the create action.
+func (c *GroupController) Create(ctx *app.CreateGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	if len(strings.TrimSpace(ctx.Payload.Name)) == 0 {
+		log.Error(ctx, map[string]interface{}{}, "security group name cannot be empty")
+		return jsonapi.JSONErrorResponse(ctx, goa.ErrBadRequest("security group name cannot be empty"))
+	}
+
+	groupID, err := c.app.GroupService().CreateGroup(ctx, *currentUser, ctx.Payload.OrganizationID, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.Payload.OrganizationID,
+			"group_name":      ctx.Payload.Name,
+		}, "failed to create security group")
+
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	groupIDStr := groupID.String()
+
+	return ctx.Created(&app.CreateGroupResponse{
+		GroupID: &groupIDStr,
+	})
+}
+
+// ListMembers runs the listMembers action.
+func (c *GroupController) ListMembers(ctx *app.ListMembersGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, err := c.app.GroupService().ListMembers(ctx, *currentUser, ctx.GroupID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"group_id": ctx.GroupID,
+		}, "failed to list security group members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.OK(&app.GroupMemberArray{
+		Data: convertToGroupMemberData(members),
+	})
+}
+
+// AddMember runs the addMember action.
+func (c *GroupController) AddMember(ctx *app.AddMemberGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().AddMember(ctx, *currentUser, ctx.GroupID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.GroupID,
+			"member_id": ctx.MemberID,
+		}, "failed to add security group member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// RemoveMember runs the removeMember action.
+func (c *GroupController) RemoveMember(ctx *app.RemoveMemberGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().RemoveMember(ctx, *currentUser, ctx.GroupID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.GroupID,
+			"member_id": ctx.MemberID,
+		}, "failed to remove security group member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// AssignRole runs the assignRole action.
+func (c *GroupController) AssignRole(ctx *app.AssignRoleGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().AssignRole(ctx, *currentUser, ctx.GroupID, ctx.ResourceID, ctx.RoleName)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"group_id":    ctx.GroupID,
+			"resource_id": ctx.ResourceID,
+			"role_name":   ctx.RoleName,
+		}, "failed to assign role to security group")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+func convertToGroupMemberData(members []account.Identity) []*app.GroupMemberData {
+	results := []*app.GroupMemberData{}
+
+	for _, member := range members {
+		memberData := &app.GroupMemberData{
+			ID:   member.ID.String(),
+			Type: "user",
+			Name: member.Username,
+		}
+		if !member.IsUser() {
+			memberData.Type = "group"
+			if member.IdentityResource.ResourceType.Name == authorization.IdentityResourceTypeTeam {
+				memberData.Type = "team"
+			}
+			memberData.Name = member.IdentityResource.Name
+		}
+
+		results = append(results, memberData)
+	}
+
+	return results
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+)
+
+// ImpersonationController implements the impersonation resource.
+type ImpersonationController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewImpersonationController creates an impersonation controller.
+func NewImpersonationController(service *goa.Service, app application.Application) *ImpersonationController {
+	return &ImpersonationController{
+		Controller: service.NewController("ImpersonationController"),
+		app:        app,
+	}
+}
+
+// Impersonate runs the impersonate action, which issues an access token to act as another user.
+func (c *ImpersonationController) Impersonate(ctx *app.ImpersonateImpersonationContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	adminIdentityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	targetIdentityID, err := uuid.FromString(ctx.Payload.IdentityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("identity_id", ctx.Payload.IdentityID).Expected("UUID"))
+	}
+	tokenSet, err := c.app.ImpersonationService().Impersonate(ctx, *adminIdentityID, targetIdentityID, ctx.Payload.Reason)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"admin_identity_id":  *adminIdentityID,
+			"target_identity_id": targetIdentityID,
+			"err":                err,
+		}, "failed to impersonate user")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToken(*tokenSet))
+}
+
+// ListSessions runs the listSessions action.
+func (c *ImpersonationController) ListSessions(ctx *app.ListSessionsImpersonationContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	adminIdentityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	targetIdentityID, err := uuid.FromString(ctx.IdentityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("identity_id", ctx.IdentityID).Expected("UUID"))
+	}
+	sessions, err := c.app.ImpersonationService().ListSessions(ctx, *adminIdentityID, targetIdentityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := []*app.ImpersonationSessionData{}
+	for _, session := range sessions {
+		data = append(data, &app.ImpersonationSessionData{
+			ID:              session.ID.String(),
+			AdminIdentityID: session.AdminIdentityID.String(),
+			Reason:          session.Reason,
+			CreatedAt:       session.CreatedAt,
+			ExpiresAt:       session.ExpiresAt,
+		})
+	}
+	return ctx.OK(&app.ImpersonationSessionArray{Data: data})
+}
+
+// checkNotImpersonating returns a forbidden error if the current request was made with an impersonation token.
+// Impersonation tokens must not be used to manage the credentials of the impersonated user, to access their
+// external tokens, or to impersonate someone else.
+func checkNotImpersonating(ctx context.Context) error {
+	if !token.IsImpersonation(ctx) {
+		return nil
+	}
+	impersonatorID, _ := token.ImpersonatorID(ctx)
+	log.Warn(ctx, map[string]interface{}{
+		"impersonator_id": impersonatorID,
+	}, "operation not allowed while impersonating a user")
+	return errors.NewForbiddenError("operation not allowed while impersonating a user")
+}
@@ -1,9 +1,14 @@
 package controller
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
+	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
+	"github.com/fabric8-services/fabric8-auth/client"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -55,23 +60,34 @@ func (c *InvitationController) CreateInvite(ctx *app.CreateInviteInvitationConte
 
 	for _, invitee := range ctx.Payload.Data {
 		// Validate that an identifying parameter has been set
-		if invitee.IdentityID == nil {
+		if invitee.IdentityID == nil && (invitee.Email == nil || *invitee.Email == "") {
 			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("user identifier", "", "no identifier provided"))
 		}
 
 		// If an identity ID has been provided for the user, convert it to a UUID here
-		var identityID uuid.UUID
+		var identityID *uuid.UUID
 		if invitee.IdentityID != nil && *invitee.IdentityID != "" {
-			identityID, err = uuid.FromString(*invitee.IdentityID)
+			id, err := uuid.FromString(*invitee.IdentityID)
+			if err != nil {
+				return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("identity-id", *invitee.IdentityID, "invalid identity ID"))
+			}
+			identityID = &id
+		}
+
+		var email string
+		if invitee.Email != nil {
+			email = *invitee.Email
 		}
 
 		// Create the Invitation object, and append it to our list of invitations
 		invitations = append(invitations, invitation.Invitation{
-			IdentityID:        &identityID,
-			Roles:             invitee.Roles,
-			Member:            *invitee.Member,
-			RedirectOnSuccess: redirectOnSuccess,
-			RedirectOnFailure: redirectOnFailure,
+			IdentityID:           identityID,
+			Email:                email,
+			Roles:                invitee.Roles,
+			Member:               invitee.Member != nil && *invitee.Member,
+			RedirectOnSuccess:    redirectOnSuccess,
+			RedirectOnFailure:    redirectOnFailure,
+			AcceptOnRegistration: invitee.AcceptOnRegistration != nil && *invitee.AcceptOnRegistration,
 		})
 	}
 
@@ -170,3 +186,300 @@ func (c *InvitationController) AcceptInvite(ctx *app.AcceptInviteInvitationConte
 	ctx.ResponseData.Header().Set("Location", redirectURL)
 	return ctx.TemporaryRedirect()
 }
+
+// ListPending runs the listPending action.
+func (c *InvitationController) ListPending(ctx *app.ListPendingInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	invitations, err := c.app.InvitationService().ListPending(ctx, currentIdentity.ID, ctx.InviteTo)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"invite-to": ctx.InviteTo,
+		}, "failed to list pending invitations")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	results := []*app.PendingInvitationData{}
+	for _, inv := range invitations {
+		results = append(results, convertPendingInvitation(inv))
+	}
+
+	return ctx.OK(&app.PendingInvitationArray{Data: results})
+}
+
+// ResendInvite runs the resendInvite action.
+func (c *InvitationController) ResendInvite(ctx *app.ResendInviteInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	invitationID, err := uuid.FromString(ctx.InviteTo)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundError("invitationID", ctx.InviteTo))
+	}
+
+	err = c.app.InvitationService().Resend(ctx, currentIdentity.ID, invitationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":          err,
+			"invitationID": invitationID,
+		}, "failed to resend invitation")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"resending-user-id": currentIdentity.ID,
+		"invitation-id":     ctx.InviteTo,
+	}, "invitation resent")
+
+	return ctx.OK([]byte{})
+}
+
+// ListReceived runs the listReceived action.
+func (c *InvitationController) ListReceived(ctx *app.ListReceivedInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	invitations, err := c.app.InvitationService().ListForInvitee(ctx, currentIdentity.ID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":         err,
+			"identity_id": currentIdentity.ID,
+		}, "failed to list received invitations")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	results := []*app.PendingInvitationData{}
+	for _, inv := range invitations {
+		results = append(results, convertPendingInvitation(inv))
+	}
+
+	return ctx.OK(&app.PendingInvitationArray{Data: results})
+}
+
+// AcceptReceived runs the acceptReceived action.
+func (c *InvitationController) AcceptReceived(ctx *app.AcceptReceivedInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	invitationID, err := uuid.FromString(ctx.InviteTo)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundError("invitationID", ctx.InviteTo))
+	}
+
+	resourceID, err := c.app.InvitationService().AcceptByID(ctx, currentIdentity.ID, invitationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":          err,
+			"invitationID": invitationID,
+		}, "failed to accept invitation")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"invitation-id": ctx.InviteTo,
+	}, "invitation accepted")
+
+	return ctx.OK(&app.AcceptedInvitation{
+		Data: &app.AcceptedInvitationData{
+			ResourceID: resourceID,
+		},
+	})
+}
+
+// DeclineInvite runs the declineInvite action.
+func (c *InvitationController) DeclineInvite(ctx *app.DeclineInviteInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	invitationID, err := uuid.FromString(ctx.InviteTo)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundError("invitationID", ctx.InviteTo))
+	}
+
+	err = c.app.InvitationService().Decline(ctx, currentIdentity.ID, invitationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":          err,
+			"invitationID": invitationID,
+		}, "failed to decline invitation")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"invitation-id": ctx.InviteTo,
+	}, "invitation declined")
+
+	return ctx.OK([]byte{})
+}
+
+// ImportInvites runs the importInvites action.
+func (c *InvitationController) ImportInvites(ctx *app.ImportInvitesInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	var redirectOnSuccess, redirectOnFailure string
+	links := ctx.Payload.Links
+	if links != nil {
+		if links.OnSuccess != nil {
+			redirectOnSuccess = *links.OnSuccess
+		}
+		if links.OnFailure != nil {
+			redirectOnFailure = *links.OnFailure
+		}
+	}
+
+	var rows []invitation.ImportRow
+	data := ctx.Payload.Data
+	if data.Csv != nil && *data.Csv != "" {
+		if len(data.Rows) > 0 {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("csv", "", "either csv or rows must be provided, not both"))
+		}
+		rows, err = invitation.ParseImportCSV(strings.NewReader(*data.Csv))
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	} else {
+		for _, row := range data.Rows {
+			importRow := invitation.ImportRow{
+				Target: row.Target,
+				Roles:  row.Roles,
+				Member: row.Member != nil && *row.Member,
+			}
+			if row.Username != nil {
+				importRow.Username = *row.Username
+			}
+			if row.Email != nil {
+				importRow.Email = *row.Email
+			}
+			rows = append(rows, importRow)
+		}
+	}
+
+	imp, err := c.app.InvitationService().Import(ctx, currentIdentity.ID, rows, data.Mode, redirectOnSuccess, redirectOnFailure)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "failed to import invitations")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"issuing-user-id":      currentIdentity.ID,
+		"invitation-import-id": imp.InvitationImportID,
+		"status":               imp.Status,
+	}, "invitations imported")
+
+	result, err := convertInvitationImport(*imp)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	ctx.ResponseData.Header().Set("Location", rest.AbsoluteURL(ctx.RequestData, client.ShowImportInvitationPath(imp.InvitationImportID), nil))
+	if imp.Status == invitationrepo.ImportStatusPending {
+		return ctx.Accepted(result)
+	}
+	return ctx.OK(result)
+}
+
+// ShowImport runs the showImport action.
+func (c *InvitationController) ShowImport(ctx *app.ShowImportInvitationContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	imp, err := c.app.InvitationService().LoadImport(ctx, currentIdentity.ID, ctx.ImportID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	result, err := convertInvitationImport(*imp)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	return ctx.OK(result)
+}
+
+func convertInvitationImport(imp invitationrepo.InvitationImport) (*app.InvitationImport, error) {
+	data := &app.InvitationImportData{
+		ID:          imp.InvitationImportID,
+		Mode:        imp.Mode,
+		Status:      imp.Status,
+		Error:       imp.Error,
+		CreatedAt:   imp.CreatedAt,
+		CompletedAt: imp.CompletedAt,
+	}
+	if imp.Report != nil {
+		var results []invitation.ImportRowResult
+		err := json.Unmarshal([]byte(*imp.Report), &results)
+		if err != nil {
+			return nil, err
+		}
+		data.Report = []*app.InvitationImportRowResult{}
+		for _, r := range results {
+			result := &app.InvitationImportRowResult{
+				Row:    r.Row,
+				Target: r.Target,
+				Status: r.Status,
+			}
+			if r.Username != "" {
+				username := r.Username
+				result.Username = &username
+			}
+			if r.Email != "" {
+				email := r.Email
+				result.Email = &email
+			}
+			if r.Error != "" {
+				reason := r.Error
+				result.Error = &reason
+			}
+			data.Report = append(data.Report, result)
+		}
+	}
+	return &app.InvitationImport{
+		Data: data,
+	}, nil
+}
+
+func convertPendingInvitation(inv invitation.PendingInvitation) *app.PendingInvitationData {
+	data := &app.PendingInvitationData{
+		ID:        inv.InvitationID.String(),
+		Member:    inv.Member,
+		Roles:     inv.Roles,
+		CreatedAt: inv.CreatedAt,
+		ExpiresAt: inv.ExpiresAt,
+	}
+	if inv.InviteTo != nil {
+		inviteTo := inv.InviteTo.String()
+		data.InviteTo = &inviteTo
+	}
+	if inv.ResourceID != nil {
+		data.ResourceID = inv.ResourceID
+	}
+	if inv.Name != "" {
+		data.Name = &inv.Name
+	}
+	if inv.IdentityID != nil {
+		identityID := inv.IdentityID.String()
+		data.IdentityID = &identityID
+		data.Username = &inv.Username
+	}
+	if inv.Email != "" {
+		data.Email = &inv.Email
+	}
+	return data
+}
@@ -99,7 +99,7 @@ func (s *InvitationControllerTestSuite) TestCreateInvitation() {
 			require.NoError(t, err, "could not list invitations")
 			// We should have 1 invitation
 			require.Len(t, invitations, 1)
-			assert.Equal(t, invitee.IdentityID(), invitations[0].IdentityID)
+			assert.Equal(t, invitee.IdentityID(), *invitations[0].IdentityID)
 			assert.True(t, invitations[0].Member)
 			// verify wit service is called once
 			require.Equal(t, uint64(1), s.witServiceMock.GetSpaceCounter)
@@ -128,7 +128,7 @@ func (s *InvitationControllerTestSuite) TestCreateInvitation() {
 			require.NoError(t, err, "could not list invitations")
 			// We should have 1 invitation
 			require.Len(t, invitations, 1)
-			assert.Equal(t, invitee.IdentityID(), invitations[0].IdentityID)
+			assert.Equal(t, invitee.IdentityID(), *invitations[0].IdentityID)
 			assert.False(t, invitations[0].Member)
 			roles, err := s.invRepo.ListRoles(s.Ctx, invitations[0].InvitationID)
 			require.NoError(t, err, "could not list invitation roles")
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/credential"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/client"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/goadesign/goa"
+)
+
+// LocalLoginControllerConfiguration the Configuration for the LocalLoginController
+type LocalLoginControllerConfiguration interface {
+	GetOAuthProviderType() string
+}
+
+// LocalLoginController implements the localLogin resource.
+type LocalLoginController struct {
+	*goa.Controller
+	app    application.Application
+	config LocalLoginControllerConfiguration
+}
+
+// NewLocalLoginController creates a localLogin controller.
+func NewLocalLoginController(service *goa.Service, app application.Application, config LocalLoginControllerConfiguration) *LocalLoginController {
+	return &LocalLoginController{
+		Controller: service.NewController("LocalLoginController"),
+		app:        app,
+		config:     config,
+	}
+}
+
+// Form runs the form action, which renders the login form of the local identity provider.
+func (c *LocalLoginController) Form(ctx *app.FormLocalLoginContext) error {
+	if c.config.GetOAuthProviderType() != provider.LocalProviderType {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	form, err := credential.RenderLoginForm(credential.LoginFormData{
+		Action:            rest.AbsoluteURL(ctx.RequestData, client.LoginLocalLoginPath(), nil),
+		State:             ctx.State,
+		ForgotPasswordURL: rest.AbsoluteURL(ctx.RequestData, client.ForgotFormPasswordPath(), nil),
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+	return ctx.OK(form)
+}
+
+// Login runs the login action, which authenticates the user with the credentials submitted in the login form and
+// then completes the login flow in the same way as the callback of an external identity provider.
+func (c *LocalLoginController) Login(ctx *app.LoginLocalLoginContext) error {
+	if c.config.GetOAuthProviderType() != provider.LocalProviderType {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	code, err := c.app.CredentialService().Authenticate(ctx, ctx.Payload.Username, ctx.Payload.Password)
+	if err != nil {
+		if unauthorized, _ := errors.IsUnauthorizedError(err); !unauthorized {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		log.Info(ctx, map[string]interface{}{
+			"err": err,
+		}, "local login failed")
+		form, renderErr := credential.RenderLoginForm(credential.LoginFormData{
+			Action:            rest.AbsoluteURL(ctx.RequestData, client.LoginLocalLoginPath(), nil),
+			State:             ctx.Payload.State,
+			ForgotPasswordURL: rest.AbsoluteURL(ctx.RequestData, client.ForgotFormPasswordPath(), nil),
+			Username:          ctx.Payload.Username,
+			Error:             err.Error(),
+		})
+		if renderErr != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, renderErr))
+		}
+		ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+		return ctx.Unauthorized(form)
+	}
+	callbackURL := rest.AbsoluteURL(ctx.RequestData, client.CallbackLoginPath(), nil)
+	redirectTo, err := c.app.AuthenticationProviderService().LoginCallback(ctx, ctx.Payload.State, code, callbackURL)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	// 303 See Other makes sure the browser does not re-post the credentials to the redirect location
+	ctx.ResponseData.Header().Set("Location", *redirectTo)
+	return ctx.SeeOther()
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/client"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+)
+
+// MFAController implements the mfa resource.
+type MFAController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewMFAController creates a mfa controller.
+func NewMFAController(service *goa.Service, app application.Application) *MFAController {
+	return &MFAController{
+		Controller: service.NewController("MFAController"),
+		app:        app,
+	}
+}
+
+// ChallengeForm runs the challengeForm action, which renders the form in which the user enters their second factor.
+// If the user has not enrolled yet then the form also shows the secret to register in their authenticator app.
+func (c *MFAController) ChallengeForm(ctx *app.ChallengeFormMfaContext) error {
+	challengeID, err := uuid.FromString(ctx.Challenge)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("challenge", ctx.Challenge))
+	}
+	form, err := c.renderChallengeForm(ctx, ctx.RequestData, challengeID, "")
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+	return ctx.OK(form)
+}
+
+// Challenge runs the challenge action, which verifies the second factor of the user and completes their login.
+// If the user enrolled during the challenge then their recovery codes are shown before they continue.
+func (c *MFAController) Challenge(ctx *app.ChallengeMfaContext) error {
+	challengeID, err := uuid.FromString(ctx.Payload.Challenge)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("challenge", ctx.Payload.Challenge))
+	}
+	redirectTo, recoveryCodes, err := c.app.AuthenticationProviderService().LoginChallengeCallback(ctx, challengeID, ctx.Payload.Code)
+	if err != nil {
+		if unauthorized, _ := errors.IsUnauthorizedError(err); !unauthorized {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		log.Info(ctx, map[string]interface{}{
+			"challenge_id": challengeID,
+			"err":          err,
+		}, "multi-factor authentication challenge failed")
+		// the challenge is gone after too many failed attempts, in which case this returns a not found error
+		form, renderErr := c.renderChallengeForm(ctx, ctx.RequestData, challengeID, err.Error())
+		if renderErr != nil {
+			return jsonapi.JSONErrorResponse(ctx, renderErr)
+		}
+		ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+		return ctx.Unauthorized(form)
+	}
+	if len(recoveryCodes) > 0 {
+		page, err := mfa.RenderRecoveryCodesPage(mfa.RecoveryCodesPageData{
+			RecoveryCodes: recoveryCodes,
+			ContinueURL:   *redirectTo,
+		})
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+		}
+		ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+		return ctx.OK(page)
+	}
+	// 303 See Other makes sure the browser does not re-post the code to the redirect location
+	ctx.ResponseData.Header().Set("Location", *redirectTo)
+	return ctx.SeeOther()
+}
+
+// Enrol runs the enrol action.
+func (c *MFAController) Enrol(ctx *app.EnrolMfaContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	enrolment, err := c.app.MFAService().Enrol(ctx, *identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.MFAEnrolment{
+		Secret:          enrolment.Secret,
+		ProvisioningURI: enrolment.ProvisioningURI,
+	})
+}
+
+// Confirm runs the confirm action.
+func (c *MFAController) Confirm(ctx *app.ConfirmMfaContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	recoveryCodes, err := c.app.MFAService().ConfirmEnrolment(ctx, *identityID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.MFARecoveryCodes{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// Disable runs the disable action.
+func (c *MFAController) Disable(ctx *app.DisableMfaContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	err = c.app.MFAService().Disable(ctx, *identityID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// RecoveryCodes runs the recoveryCodes action.
+func (c *MFAController) RecoveryCodes(ctx *app.RecoveryCodesMfaContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	recoveryCodes, err := c.app.MFAService().RegenerateRecoveryCodes(ctx, *identityID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.MFARecoveryCodes{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// StepUp runs the stepUp action, which issues new tokens of the multi-factor authentication context class once
+// the user has entered their second factor.
+func (c *MFAController) StepUp(ctx *app.StepUpMfaContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	tokenSet, err := c.app.MFAService().StepUp(ctx, *identityID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToken(*tokenSet))
+}
+
+func (c *MFAController) renderChallengeForm(ctx context.Context, req *goa.RequestData, challengeID uuid.UUID, message string) ([]byte, error) {
+	_, pending, err := c.app.MFAService().LoadChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	form, err := mfa.RenderChallengeForm(mfa.ChallengeFormData{
+		Action:    rest.AbsoluteURL(req, client.ChallengeMfaPath(), nil),
+		Challenge: challengeID.String(),
+		Enrolment: pending,
+		Error:     message,
+	})
+	if err != nil {
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return form, nil
+}
@@ -102,3 +102,190 @@ func convertToAppOrganization(orgs []authorization.IdentityAssociation) []*app.O
 
 	return results
 }
+
+// ListGroups runs the listGroups action.
+func (c *OrganizationController) ListGroups(ctx *app.ListGroupsOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	groups, err := c.app.GroupService().ListGroupsInOrganization(ctx, *currentUser, ctx.OrganizationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+		}, "failed to list security groups")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	results := []*app.GroupData{}
+	for _, group := range groups {
+		results = append(results, &app.GroupData{
+			ID:             group.ID.String(),
+			Name:           group.IdentityResource.Name,
+			OrganizationID: ctx.OrganizationID.String(),
+		})
+	}
+
+	return ctx.OK(&app.GroupArray{Data: results})
+}
+
+// Update runs the update action, which renames an organization.
+func (c *OrganizationController) Update(ctx *app.UpdateOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	if len(strings.TrimSpace(ctx.Payload.Name)) == 0 {
+		log.Error(ctx, map[string]interface{}{}, "organization name cannot be empty")
+		return jsonapi.JSONErrorResponse(ctx, goa.ErrBadRequest("organization name cannot be empty"))
+	}
+
+	err = c.app.OrganizationService().RenameOrganization(ctx, *currentUser, ctx.OrganizationID, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+			"org_name":        ctx.Payload.Name,
+		}, "failed to rename organization")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// Delete runs the delete action.
+func (c *OrganizationController) Delete(ctx *app.DeleteOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().DeleteOrganization(ctx, *currentUser, ctx.OrganizationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+		}, "failed to delete organization")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// ListMembers runs the listMembers action.
+func (c *OrganizationController) ListMembers(ctx *app.ListMembersOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, identityRoles, err := c.app.OrganizationService().ListMembers(ctx, *currentUser, ctx.OrganizationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+		}, "failed to list organization members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	results := []*app.OrganizationMemberData{}
+	for _, member := range members {
+		roles := []string{}
+		for _, ir := range identityRoles {
+			if ir.IdentityID == member.ID {
+				roles = append(roles, ir.Role.Name)
+			}
+		}
+		results = append(results, &app.OrganizationMemberData{
+			ID:       member.ID.String(),
+			Username: member.Username,
+			Roles:    roles,
+		})
+	}
+
+	return ctx.OK(&app.OrganizationMemberArray{Data: results})
+}
+
+// AddMember runs the addMember action.
+func (c *OrganizationController) AddMember(ctx *app.AddMemberOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().AddMember(ctx, *currentUser, ctx.OrganizationID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+			"member_id":       ctx.MemberID,
+		}, "failed to add organization member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// RemoveMember runs the removeMember action.
+func (c *OrganizationController) RemoveMember(ctx *app.RemoveMemberOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().RemoveMember(ctx, *currentUser, ctx.OrganizationID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+			"member_id":       ctx.MemberID,
+		}, "failed to remove organization member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// SetMemberRole runs the setMemberRole action.
+func (c *OrganizationController) SetMemberRole(ctx *app.SetMemberRoleOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().SetMemberRole(ctx, *currentUser, ctx.OrganizationID, ctx.MemberID, ctx.RoleName)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+			"member_id":       ctx.MemberID,
+			"role_name":       ctx.RoleName,
+		}, "failed to change the role of organization member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// TransferOwnership runs the transferOwnership action.
+func (c *OrganizationController) TransferOwnership(ctx *app.TransferOwnershipOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().TransferOwnership(ctx, *currentUser, ctx.OrganizationID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.OrganizationID,
+			"new_owner_id":    ctx.MemberID,
+		}, "failed to transfer organization ownership")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
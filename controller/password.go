@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/credential"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/client"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/goadesign/goa"
+)
+
+const (
+	passwordResetSentMessage = "If an account exists for this username or email address, a link to reset its password has been sent to the email address of the account."
+	passwordResetDoneMessage = "Your password has been changed. You can now log in with your new password."
+)
+
+// PasswordControllerConfiguration the Configuration for the PasswordController
+type PasswordControllerConfiguration interface {
+	GetOAuthProviderType() string
+}
+
+// PasswordController implements the password resource.
+type PasswordController struct {
+	*goa.Controller
+	app    application.Application
+	config PasswordControllerConfiguration
+}
+
+// NewPasswordController creates a password controller.
+func NewPasswordController(service *goa.Service, app application.Application, config PasswordControllerConfiguration) *PasswordController {
+	return &PasswordController{
+		Controller: service.NewController("PasswordController"),
+		app:        app,
+		config:     config,
+	}
+}
+
+// ForgotForm runs the forgotForm action.
+func (c *PasswordController) ForgotForm(ctx *app.ForgotFormPasswordContext) error {
+	if !c.localLoginEnabled() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	form, err := credential.RenderForgotPasswordForm(credential.PasswordFormData{
+		Action: rest.AbsoluteURL(ctx.RequestData, client.ForgotPasswordPath(), nil),
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	return ctx.OK(form)
+}
+
+// Forgot runs the forgot action. The same page is returned whether or not the user exists.
+func (c *PasswordController) Forgot(ctx *app.ForgotPasswordContext) error {
+	if !c.localLoginEnabled() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	err := c.app.CredentialService().SendPasswordReset(ctx, ctx.Payload.Username)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	page, err := credential.RenderForgotPasswordForm(credential.PasswordFormData{
+		Message: passwordResetSentMessage,
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	return ctx.OK(page)
+}
+
+// ResetForm runs the resetForm action.
+func (c *PasswordController) ResetForm(ctx *app.ResetFormPasswordContext) error {
+	if !c.localLoginEnabled() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	form, err := credential.RenderPasswordResetForm(credential.PasswordFormData{
+		Action: rest.AbsoluteURL(ctx.RequestData, client.ResetPasswordPath(), nil),
+		Code:   ctx.Code,
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+	return ctx.OK(form)
+}
+
+// Reset runs the reset action. If the new password is rejected then the form is rendered again with the reason.
+func (c *PasswordController) Reset(ctx *app.ResetPasswordContext) error {
+	if !c.localLoginEnabled() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	err := c.app.CredentialService().ResetPassword(ctx, ctx.Payload.Code, ctx.Payload.Password)
+	if err != nil {
+		badParameter, _ := errors.IsBadParameterError(err)
+		notFound, _ := errors.IsNotFoundError(err)
+		if !badParameter && !notFound {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		log.Info(ctx, map[string]interface{}{
+			"err": err,
+		}, "password reset rejected")
+		message := err.Error()
+		if notFound {
+			message = "The password reset link is invalid or has expired."
+		}
+		form, renderErr := credential.RenderPasswordResetForm(credential.PasswordFormData{
+			Action: rest.AbsoluteURL(ctx.RequestData, client.ResetPasswordPath(), nil),
+			Code:   ctx.Payload.Code,
+			Error:  message,
+		})
+		if renderErr != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, renderErr))
+		}
+		return ctx.BadRequest(form)
+	}
+	page, err := credential.RenderPasswordResetForm(credential.PasswordFormData{
+		Message: passwordResetDoneMessage,
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	return ctx.OK(page)
+}
+
+// Change runs the change action, which changes the password of the current user.
+func (c *PasswordController) Change(ctx *app.ChangePasswordContext) error {
+	if !c.localLoginEnabled() {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewNotFoundErrorFromString("local login is not enabled"))
+	}
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	identityID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	err = c.app.CredentialService().ChangePassword(ctx, *identityID, ctx.Payload.CurrentPassword, ctx.Payload.NewPassword)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+func (c *PasswordController) localLoginEnabled() bool {
+	return c.config.GetOAuthProviderType() == provider.LocalProviderType
+}
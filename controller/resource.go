@@ -3,6 +3,7 @@ package controller
 import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -127,3 +128,84 @@ func (c *ResourceController) Scopes(ctx *app.ScopesResourceContext) error {
 
 	return ctx.OK(res)
 }
+
+// ListChildren runs the listChildren action, which returns a page of the direct children of the specified resource
+func (c *ResourceController) ListChildren(ctx *app.ListChildrenResourceContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	children, count, err := c.app.ResourceService().ListChildren(ctx, currentIdentity.ID, ctx.ResourceID, offset, limit)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+			"err":         err,
+		}, "unable to list the children of the resource")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	response := app.ResourceList{
+		Links: &app.PagingLinks{},
+		Meta:  &app.ResourceListMeta{TotalCount: count},
+		Data:  convertResourcesToApp(children),
+	}
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(children), offset, limit, count)
+	return ctx.OK(&response)
+}
+
+// Ancestors runs the ancestors action, which returns the ancestors of the specified resource from the root resource
+// down to its parent
+func (c *ResourceController) Ancestors(ctx *app.AncestorsResourceContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	ancestors, err := c.app.ResourceService().FindAncestors(ctx, currentIdentity.ID, ctx.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+			"err":         err,
+		}, "unable to list the ancestors of the resource")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.OK(&app.ResourceAncestors{
+		Data: convertResourcesToApp(ancestors),
+	})
+}
+
+// Reparent runs the reparent action, which moves the specified resource to another parent resource
+func (c *ResourceController) Reparent(ctx *app.ReparentResourceContext) error {
+	currentIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	err = c.app.ResourceService().Reparent(ctx, currentIdentity.ID, ctx.ResourceID, ctx.Payload.Data.ParentResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id":        ctx.ResourceID,
+			"parent_resource_id": ctx.Payload.Data.ParentResourceID,
+			"err":                err,
+		}, "unable to move the resource to another parent")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+func convertResourcesToApp(resources []resource.Resource) []*app.ResourceData {
+	data := make([]*app.ResourceData, len(resources))
+	for i, r := range resources {
+		data[i] = &app.ResourceData{
+			ID:               r.ResourceID,
+			Name:             r.Name,
+			Type:             r.ResourceType.Name,
+			ParentResourceID: r.ParentResourceID,
+		}
+	}
+	return data
+}
@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
 	rolerepository "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
@@ -46,8 +49,18 @@ func (c *ResourceRolesController) ListAssigned(ctx *app.ListAssignedResourceRole
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 	roleList := convertIdentityRoleToAppRoles(roles)
+
+	denyAssignments, err := c.app.RoleManagementService().ListDenyAssignmentsByResource(ctx, currentIdentity.ID, ctx.ResourceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+			"err":         err,
+		}, "error retrieving list of deny assignments for a specific resource")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	return ctx.OK(&app.Identityroles{
-		Data: roleList,
+		Data:   roleList,
+		Denied: convertDenyAssignmentsToApp(denyAssignments, ctx.ResourceID),
 	})
 }
 
@@ -90,7 +103,18 @@ func (c *ResourceRolesController) AssignRole(ctx *app.AssignRoleResourceRolesCon
 	}
 
 	roleAssignments := make(map[string][]uuid.UUID)
+	validity := make(map[string]rolerepository.Validity)
 	for _, assignment := range ctx.Payload.Data {
+		if assignment.ValidFrom != nil || assignment.ValidUntil != nil {
+			v := rolerepository.Validity{
+				ValidFrom:  assignment.ValidFrom,
+				ValidUntil: assignment.ValidUntil,
+			}
+			if existing, found := validity[assignment.Role]; found && !sameValidity(existing, v) {
+				return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("role", assignment.Role, "the same role cannot be assigned with different validity periods"))
+			}
+			validity[assignment.Role] = v
+		}
 		for _, id := range assignment.Ids {
 
 			identityIDAsUUID, err := uuid.FromString(id)
@@ -109,13 +133,134 @@ func (c *ResourceRolesController) AssignRole(ctx *app.AssignRoleResourceRolesCon
 			}
 		}
 	}
-	err = c.app.RoleManagementService().Assign(ctx, *currentIdentity, roleAssignments, ctx.ResourceID, false)
+	err = c.app.RoleManagementService().Assign(ctx, *currentIdentity, roleAssignments, ctx.ResourceID, false, validity)
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 	return ctx.NoContent()
 }
 
+// Deny denies scopes for a resource and all its descendants, to one or more identities.
+func (c *ResourceRolesController) Deny(ctx *app.DenyResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	denials := make(map[string][]uuid.UUID)
+	for _, denial := range ctx.Payload.Data {
+		for _, id := range denial.Ids {
+			identityID, err := uuid.FromString(id)
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{
+					"resource_id": ctx.ResourceID,
+					"identity_id": id,
+					"scope":       denial.Scope,
+				}, "invalid identity ID")
+				return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("ids", id).Expected("uuid"))
+			}
+			denials[denial.Scope] = append(denials[denial.Scope], identityID)
+		}
+	}
+	denyAssignments, err := c.app.RoleManagementService().Deny(ctx, *currentIdentity, denials, ctx.ResourceID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.DenyAssignments{
+		Data: convertDenyAssignmentsToApp(denyAssignments, ctx.ResourceID),
+	})
+}
+
+// RevokeDeny removes a deny assignment for a resource.
+func (c *ResourceRolesController) RevokeDeny(ctx *app.RevokeDenyResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.RoleManagementService().RevokeDenyAssignment(ctx, *currentIdentity, ctx.ResourceID, ctx.DenyAssignmentID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// ListRoleMappings lists the role mappings of a resource.
+func (c *ResourceRolesController) ListRoleMappings(ctx *app.ListRoleMappingsResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	roleMappings, err := c.app.RoleManagementService().ListRoleMappings(ctx, *currentIdentity, ctx.ResourceID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := []*app.RoleMappingData{}
+	for _, rm := range roleMappings {
+		data = append(data, convertRoleMappingToApp(rm))
+	}
+	return ctx.OK(&app.RoleMappings{
+		Data: data,
+	})
+}
+
+// CreateRoleMapping creates a role mapping for a resource.
+func (c *ResourceRolesController) CreateRoleMapping(ctx *app.CreateRoleMappingResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	roleMapping, err := c.app.RoleManagementService().CreateRoleMapping(ctx, *currentIdentity, ctx.ResourceID,
+		ctx.Payload.Data.FromRole, ctx.Payload.Data.ToResourceType, ctx.Payload.Data.ToRole)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.Created(&app.RoleMapping{
+		Data: convertRoleMappingToApp(*roleMapping),
+	})
+}
+
+// DeleteRoleMapping deletes a role mapping of a resource.
+func (c *ResourceRolesController) DeleteRoleMapping(ctx *app.DeleteRoleMappingResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_id": ctx.ResourceID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.RoleManagementService().DeleteRoleMapping(ctx, *currentIdentity, ctx.ResourceID, ctx.RoleMappingID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+func convertRoleMappingToApp(rm rolerepository.RoleMapping) *app.RoleMappingData {
+	id := rm.RoleMappingID
+	return &app.RoleMappingData{
+		ID:             &id,
+		FromRole:       rm.FromRole.Name,
+		ToResourceType: rm.ToRole.ResourceType.Name,
+		ToRole:         rm.ToRole.Name,
+	}
+}
+
 // HasScope checks if the user has the given scope in the requested resource
 func (c *ResourceRolesController) HasScope(ctx *app.HasScopeResourceRolesContext) error {
 	// retrieve the current user's identity from the request token
@@ -150,6 +295,132 @@ func (c *ResourceRolesController) HasScope(ctx *app.HasScopeResourceRolesContext
 	})
 }
 
+// ExplainScope runs the explainScope action, which explains whether an identity has a scope in the requested resource
+func (c *ResourceRolesController) ExplainScope(ctx *app.ExplainScopeResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	explanation, err := c.app.PermissionService().Explain(ctx, *currentIdentity, ctx.IdentityID, ctx.ResourceID, ctx.ScopeName)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": ctx.IdentityID,
+			"resource_id": ctx.ResourceID,
+			"scope_name":  ctx.ScopeName,
+			"err":         err,
+		}, "error explaining the scope of the identity in the requested resource")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	paths := make([]*app.GrantPathData, len(explanation.Paths))
+	for i, path := range explanation.Paths {
+		paths[i] = convertGrantPathToApp(path)
+	}
+	return ctx.OK(&app.PermissionExplanation{
+		Data: &app.PermissionExplanationData{
+			IdentityID: explanation.IdentityID,
+			ResourceID: explanation.ResourceID,
+			ScopeName:  explanation.ScopeName,
+			Granted:    explanation.Granted,
+			Paths:      paths,
+			Denied:     convertDenyAssignmentsToApp(explanation.Denials, explanation.ResourceID),
+		},
+	})
+}
+
+func convertGrantPathToApp(path rolerepository.GrantPath) *app.GrantPathData {
+	data := &app.GrantPathData{
+		RoleName:         path.IdentityRole.Role.Name,
+		RoleResourceType: path.IdentityRole.Role.ResourceType.Name,
+		AssigneeID:       path.IdentityRole.IdentityID.String(),
+		AssignedFor:      path.IdentityRole.ResourceID,
+		ValidFrom:        path.IdentityRole.ValidFrom,
+		ValidUntil:       path.IdentityRole.ValidUntil,
+		MembershipChain:  convertPathNodesToApp(path.MembershipChain),
+		ResourceChain:    convertPathNodesToApp(path.ResourceChain),
+		RoleMappings:     make([]*app.RoleMappingData, len(path.RoleMappings)),
+	}
+	for i, rm := range path.RoleMappings {
+		data.RoleMappings[i] = convertRoleMappingToApp(rm)
+	}
+	if path.BlockedBy != "" {
+		blockedBy := path.BlockedBy
+		data.BlockedBy = &blockedBy
+	}
+	return data
+}
+
+func convertPathNodesToApp(nodes []rolerepository.PathNode) []*app.PathNodeData {
+	result := make([]*app.PathNodeData, len(nodes))
+	for i, node := range nodes {
+		result[i] = &app.PathNodeData{
+			ID:   node.ID,
+			Name: node.Name,
+		}
+	}
+	return result
+}
+
+// HasScopes runs the hasScopes action, which checks many scopes on many resources at once
+func (c *ResourceRolesController) HasScopes(ctx *app.HasScopesResourceRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+	identityID := *currentIdentity
+	if ctx.Payload.IdentityID != nil {
+		// only service accounts can check the scopes of another identity
+		if !token.IsServiceAccount(ctx) {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": *ctx.Payload.IdentityID,
+			}, "only service accounts can check the scopes of another identity")
+			return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("only service accounts can check the scopes of another identity"))
+		}
+		identityID, err = uuid.FromString(*ctx.Payload.IdentityID)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("identity_id", *ctx.Payload.IdentityID).Expected("UUID"))
+		}
+		err = c.app.Identities().CheckExists(ctx, identityID.String())
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
+
+	checks := []rolerepository.PermissionCheck{}
+	for _, check := range ctx.Payload.Checks {
+		checks = append(checks, rolerepository.PermissionCheck{ResourceID: check.ResourceID, ScopeName: check.ScopeName})
+	}
+	if len(ctx.Payload.ResourceIds) > 0 {
+		if ctx.Payload.ScopeName == nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("scope_name", nil).Expected("the scope to check on the resources"))
+		}
+		for _, resourceID := range ctx.Payload.ResourceIds {
+			checks = append(checks, rolerepository.PermissionCheck{ResourceID: resourceID, ScopeName: *ctx.Payload.ScopeName})
+		}
+	}
+	if len(checks) == 0 {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("checks", nil).Expected("at least one check"))
+	}
+
+	result, err := c.app.PermissionService().HasScopes(ctx, identityID, checks)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"checks":      len(checks),
+			"err":         err,
+		}, "error checking if the identity has the given scopes in the requested resources")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.IdentityResourceScopeCheckData, len(checks))
+	for i, check := range checks {
+		data[i] = &app.IdentityResourceScopeCheckData{
+			ResourceID: check.ResourceID,
+			ScopeName:  check.ScopeName,
+			HasScope:   result[check],
+		}
+	}
+	return ctx.OK(&app.IdentityResourceScopeChecks{Data: data})
+}
+
 func convertIdentityRoleToAppRoles(roles []rolerepository.IdentityRole) []*app.IdentityRolesData {
 	var rolesList []*app.IdentityRolesData
 	for _, r := range roles {
@@ -169,5 +440,41 @@ func convertIdentityRoleToAppRole(r rolerepository.IdentityRole) *app.IdentityRo
 	if inherited {
 		rolesData.InheritedFrom = r.Resource.ParentResourceID
 	}
+	rolesData.ValidFrom = r.ValidFrom
+	rolesData.ValidUntil = r.ValidUntil
 	return &rolesData
 }
+
+func convertDenyAssignmentsToApp(denyAssignments []rolerepository.DenyAssignment, resourceID string) []*app.DenyAssignmentData {
+	result := make([]*app.DenyAssignmentData, len(denyAssignments))
+	for i, da := range denyAssignments {
+		assigneeType := "user"
+		if da.Identity.IdentityResourceID.Valid {
+			assigneeType = "group"
+		}
+		data := app.DenyAssignmentData{
+			ID:           da.DenyAssignmentID,
+			ScopeName:    da.Scope.Name,
+			AssigneeID:   da.IdentityID.String(),
+			AssigneeType: assigneeType,
+			Inherited:    da.ResourceID != resourceID,
+		}
+		if data.Inherited {
+			inheritedFrom := da.ResourceID
+			data.InheritedFrom = &inheritedFrom
+		}
+		result[i] = &data
+	}
+	return result
+}
+
+// sameValidity returns true if both validity periods have the same bounds
+func sameValidity(v1, v2 rolerepository.Validity) bool {
+	sameTime := func(t1, t2 *time.Time) bool {
+		if t1 == nil || t2 == nil {
+			return t1 == t2
+		}
+		return t1.Equal(*t2)
+	}
+	return sameTime(v1.ValidFrom, v2.ValidFrom) && sameTime(v1.ValidUntil, v2.ValidUntil)
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/resourcetype"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+)
+
+// ResourceTypesController implements the resource_types resource.
+type ResourceTypesController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewResourceTypesController creates a resource_types controller.
+func NewResourceTypesController(service *goa.Service, app application.Application) *ResourceTypesController {
+	return &ResourceTypesController{
+		Controller: service.NewController("ResourceTypesController"),
+		app:        app,
+	}
+}
+
+// Register runs the register action.
+func (c *ResourceTypesController) Register(ctx *app.RegisterResourceTypesContext) error {
+	if !token.IsServiceAccount(ctx) {
+		log.Error(ctx, nil, "The account is not a service account allowed to register resource types")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("only service accounts may register resource types"))
+	}
+	serviceAccountID, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_type": ctx.Payload.Data.Name,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	registered, err := c.app.ResourceTypeService().RegisterResourceType(ctx, *serviceAccountID, convertAppResourceTypeToDescriptor(ctx.Payload.Data))
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_type": ctx.Payload.Data.Name,
+			"err":           err,
+		}, "error registering the resource type")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.ResourceType{
+		Data: convertResourceTypeDescriptorToApp(*registered),
+	})
+}
+
+func convertAppResourceTypeToDescriptor(data *app.ResourceTypeData) resourcetype.ResourceTypeDescriptor {
+	definition := resourcetype.ResourceTypeDescriptor{
+		Name:            data.Name,
+		Scopes:          data.Scopes,
+		DefaultRoleName: data.DefaultRole,
+	}
+	for _, r := range data.Roles {
+		definition.Roles = append(definition.Roles, resourcetype.RoleDescriptor{
+			Name:   r.Name,
+			Scopes: r.Scopes,
+		})
+	}
+	for _, m := range data.DefaultRoleMappings {
+		definition.DefaultRoleMappings = append(definition.DefaultRoleMappings, resourcetype.DefaultRoleMappingDescriptor{
+			FromRoleName:   m.FromRole,
+			ToResourceType: m.ToResourceType,
+			ToRoleName:     m.ToRole,
+		})
+	}
+	return definition
+}
+
+func convertResourceTypeDescriptorToApp(definition resourcetype.ResourceTypeDescriptor) *app.ResourceTypeData {
+	data := &app.ResourceTypeData{
+		Name:                definition.Name,
+		Scopes:              definition.Scopes,
+		DefaultRole:         definition.DefaultRoleName,
+		Roles:               []*app.ResourceTypeRoleData{},
+		DefaultRoleMappings: []*app.DefaultRoleMappingData{},
+	}
+	for _, r := range definition.Roles {
+		data.Roles = append(data.Roles, &app.ResourceTypeRoleData{
+			Name:   r.Name,
+			Scopes: r.Scopes,
+		})
+	}
+	for _, m := range definition.DefaultRoleMappings {
+		data.DefaultRoleMappings = append(data.DefaultRoleMappings, &app.DefaultRoleMappingData{
+			FromRole:       m.FromRoleName,
+			ToResourceType: m.ToResourceType,
+			ToRole:         m.ToRoleName,
+		})
+	}
+	return data
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/authorization/role"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -60,6 +61,83 @@ func (c *RolesController) List(ctx *app.ListRolesContext) error {
 	return ctx.OK(res)
 }
 
+// Create runs the create action.
+func (c *RolesController) Create(ctx *app.CreateRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_type": ctx.Payload.Data.ResourceType,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	r, err := c.app.ResourceTypeService().CreateRole(ctx, *currentIdentity, ctx.Payload.Data.ResourceType, ctx.Payload.Data.RoleName, ctx.Payload.Data.Scope)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"resource_type": ctx.Payload.Data.ResourceType,
+			"role_name":     ctx.Payload.Data.RoleName,
+			"err":           err,
+		}, "error creating the role")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.Created(&app.Role{
+		Data: convertRoleScopeToAppRole(*r),
+	})
+}
+
+// Update runs the update action.
+func (c *RolesController) Update(ctx *app.UpdateRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_id": ctx.RoleID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	existing, err := c.app.RoleRepository().Load(ctx, ctx.RoleID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	// the resource type of a role cannot be changed
+	if existing.ResourceType.Name != ctx.Payload.Data.ResourceType {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("resource_type", ctx.Payload.Data.ResourceType).Expected(existing.ResourceType.Name))
+	}
+
+	r, err := c.app.ResourceTypeService().UpdateRole(ctx, *currentIdentity, ctx.RoleID, ctx.Payload.Data.RoleName, ctx.Payload.Data.Scope)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_id": ctx.RoleID,
+			"err":     err,
+		}, "error updating the role")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.Role{
+		Data: convertRoleScopeToAppRole(*r),
+	})
+}
+
+// Delete runs the delete action.
+func (c *RolesController) Delete(ctx *app.DeleteRolesContext) error {
+	currentIdentity, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_id": ctx.RoleID,
+		}, "error getting identity information from token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.ResourceTypeService().DeleteRole(ctx, *currentIdentity, ctx.RoleID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"role_id": ctx.RoleID,
+			"err":     err,
+		}, "error deleting the role")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
 func convertRoleScopeToAppRoles(roles []role.RoleDescriptor) []*app.RolesData {
 	var rolesList []*app.RolesData
 	for _, r := range roles {
@@ -69,7 +147,9 @@ func convertRoleScopeToAppRoles(roles []role.RoleDescriptor) []*app.RolesData {
 }
 
 func convertRoleScopeToAppRole(r role.RoleDescriptor) *app.RolesData {
+	roleID := r.RoleID
 	return &app.RolesData{
+		RoleID:       &roleID,
 		RoleName:     r.RoleName,
 		ResourceType: r.ResourceType,
 		Scope:        r.Scopes,
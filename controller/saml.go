@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/goadesign/goa"
+)
+
+// SamlControllerConfiguration the Configuration for the SamlController
+type SamlControllerConfiguration interface {
+	provider.SAMLIdentityProviderConfiguration
+}
+
+// SamlController implements the saml resource.
+type SamlController struct {
+	*goa.Controller
+	app    application.Application
+	config SamlControllerConfiguration
+}
+
+// NewSamlController creates a saml controller.
+func NewSamlController(service *goa.Service, app application.Application, config SamlControllerConfiguration) *SamlController {
+	return &SamlController{
+		Controller: service.NewController("SamlController"),
+		app:        app,
+		config:     config,
+	}
+}
+
+// Metadata runs the metadata action.
+func (c *SamlController) Metadata(ctx *app.MetadataSamlContext) error {
+	samlProvider, err := c.samlIdentityProvider(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	metadata, err := samlProvider.Metadata()
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	return ctx.OK(metadata)
+}
+
+// Sso runs the sso action.
+func (c *SamlController) Sso(ctx *app.SsoSamlContext) error {
+	samlProvider, err := c.samlIdentityProvider(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	form, err := samlProvider.AuthnRequestPostForm(ctx.RelayState)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewInternalError(ctx, err))
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache, no-store")
+	return ctx.OK(form)
+}
+
+// Acs runs the acs action, which receives SAML responses sent with the HTTP-POST binding.
+func (c *SamlController) Acs(ctx *app.AcsSamlContext) error {
+	if _, err := c.samlIdentityProvider(ctx); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	redirectTo, err := c.app.AuthenticationProviderService().LoginCallback(ctx, ctx.Payload.RelayState, ctx.Payload.SAMLResponse, c.config.GetSAMLServiceProviderACSURL())
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	// 303 See Other makes sure the browser does not re-post the SAML response to the redirect location
+	ctx.ResponseData.Header().Set("Location", *redirectTo)
+	return ctx.SeeOther()
+}
+
+// AcsRedirect runs the acsRedirect action, which receives SAML responses sent with the HTTP-Redirect binding.
+func (c *SamlController) AcsRedirect(ctx *app.AcsRedirectSamlContext) error {
+	if _, err := c.samlIdentityProvider(ctx); err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	redirectTo, err := c.app.AuthenticationProviderService().LoginCallback(ctx, ctx.RelayState, ctx.SAMLResponse, c.config.GetSAMLServiceProviderACSURL())
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Location", *redirectTo)
+	return ctx.TemporaryRedirect()
+}
+
+// samlIdentityProvider returns the SAML service provider, or a not found error if SAML login is not enabled
+func (c *SamlController) samlIdentityProvider(ctx context.Context) (*provider.SAMLIdentityProvider, error) {
+	if c.config.GetOAuthProviderType() != provider.SAMLProviderType {
+		return nil, errors.NewNotFoundErrorFromString("SAML login is not enabled")
+	}
+	samlProvider, err := provider.NewSAMLIdentityProvider(c.config, c.app.SAMLAssertionRepository())
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to create the SAML identity provider")
+		return nil, errors.NewInternalError(ctx, err)
+	}
+	return samlProvider, nil
+}
@@ -84,6 +84,117 @@ func (c *TeamController) List(ctx *app.ListTeamContext) error {
 	})
 }
 
+// Update runs the update action, which renames a team.
+func (c *TeamController) Update(ctx *app.UpdateTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	if len(strings.TrimSpace(ctx.Payload.Name)) == 0 {
+		log.Error(ctx, map[string]interface{}{}, "team name cannot be empty")
+		return jsonapi.JSONErrorResponse(ctx, goa.ErrBadRequest("team name cannot be empty"))
+	}
+
+	err = c.app.TeamService().RenameTeam(ctx, *currentUser, ctx.TeamID, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"team_id":   ctx.TeamID,
+			"team_name": ctx.Payload.Name,
+		}, "failed to rename team")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// Delete runs the delete action.
+func (c *TeamController) Delete(ctx *app.DeleteTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.TeamService().DeleteTeam(ctx, *currentUser, ctx.TeamID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"team_id": ctx.TeamID,
+		}, "failed to delete team")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// ListMembers runs the listMembers action.
+func (c *TeamController) ListMembers(ctx *app.ListMembersTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, err := c.app.TeamService().ListMembers(ctx, *currentUser, ctx.TeamID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"team_id": ctx.TeamID,
+		}, "failed to list team members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	results := []*app.TeamMemberData{}
+	for _, member := range members {
+		results = append(results, &app.TeamMemberData{
+			ID:       member.ID.String(),
+			Username: member.Username,
+		})
+	}
+
+	return ctx.OK(&app.TeamMemberArray{Data: results})
+}
+
+// AddMember runs the addMember action.
+func (c *TeamController) AddMember(ctx *app.AddMemberTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.TeamService().AddMember(ctx, *currentUser, ctx.TeamID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"team_id":   ctx.TeamID,
+			"member_id": ctx.MemberID,
+		}, "failed to add team member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// RemoveMember runs the removeMember action.
+func (c *TeamController) RemoveMember(ctx *app.RemoveMemberTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.TeamService().RemoveMember(ctx, *currentUser, ctx.TeamID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"team_id":   ctx.TeamID,
+			"member_id": ctx.MemberID,
+		}, "failed to remove team member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
 func convertToIdentityTeamData(teams []authorization.IdentityAssociation) []*app.IdentityTeamData {
 	results := []*app.IdentityTeamData{}
 
@@ -101,6 +101,11 @@ func convertToken(t manager.TokenSet) *app.AuthToken {
 
 // Retrieve fetches the stored external provider token.
 func (c *TokenController) Retrieve(ctx *app.RetrieveTokenContext) error {
+	// external tokens of the user are not disclosed to administrators impersonating them
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	appToken, errorResponse, err := c.app.TokenService().RetrieveExternalToken(ctx, ctx.For, ctx.RequestData, ctx.ForcePull)
 	if errorResponse != nil {
 		ctx.ResponseData.Header().Add("Access-Control-Expose-Headers", "WWW-Authenticate")
@@ -138,6 +143,10 @@ func (c *TokenController) Status(ctx *app.StatusTokenContext) error {
 
 // Delete deletes the stored external provider token.
 func (c *TokenController) Delete(ctx *app.DeleteTokenContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	currentIdentity, err := manager.ContextIdentity(ctx)
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
@@ -305,6 +314,10 @@ func (c *TokenController) exchangeWithGrantTypeClientCredentials(ctx *app.Exchan
 
 // Link links the user account to an external resource provider such as GitHub
 func (c *TokenController) Link(ctx *app.LinkTokenContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	if ctx.For == "" {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("for", "").Expected("git or OpenShift resource URL"))
 	}
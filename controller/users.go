@@ -290,6 +290,12 @@ func (c *UsersController) createUserInDB(ctx *app.CreateUsersContext, identityID
 // TODO move business logic to the user service
 // Update updates the authorized user based on the provided Token
 func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
+	// the email address and username of the user cannot be changed by an administrator impersonating them, since
+	// they could then take over the account with a password reset
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 
 	loggedInIdentity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
 	if err != nil {
@@ -731,6 +737,10 @@ func (c *UsersController) ListTokens(ctx *app.ListTokensUsersContext) error {
 }
 
 func (c *UsersController) RevokeAllTokens(ctx *app.RevokeAllTokensUsersContext) error {
+	err := checkNotImpersonating(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
 	if !isSvcAccount {
 		log.Error(ctx, nil, "The account is not an authorized service account allowed to manage user tokens")
@@ -0,0 +1,98 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("access_reports", func() {
+	a.BasePath("/access_reports")
+
+	a.Action("request", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Payload(accessReportPayload)
+		a.Description("Request a report of the effective scopes of every identity for a resource and all its descendants, in the CSV or NDJSON format. The report is generated asynchronously, and can be downloaded once its status is 'completed'.")
+		a.Response(d.Accepted, accessReportMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:reportID"),
+		)
+		a.Params(func() {
+			a.Param("reportID", d.UUID, "ID of the access report")
+		})
+		a.Description("Show the status of an access report")
+		a.Response(d.OK, accessReportMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("download", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:reportID/content"),
+		)
+		a.Params(func() {
+			a.Param("reportID", d.UUID, "ID of the access report")
+		})
+		a.Description("Download the content of a completed access report, as CSV (text/csv) or NDJSON (application/x-ndjson)")
+		a.Response(d.OK, "text/csv")
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.CanonicalActionRoute("show")
+})
+
+var accessReportPayload = a.Type("AccessReportPayload", func() {
+	a.Attribute("data", accessReportRequestData)
+	a.Required("data")
+})
+
+var accessReportRequestData = a.Type("AccessReportRequestData", func() {
+	a.Attribute("resource_id", d.String, "The ID of the resource to report, along with all its descendants")
+	a.Attribute("format", d.String, "The format of the report", func() {
+		a.Enum("csv", "ndjson")
+		a.Default("csv")
+	})
+	a.Required("resource_id")
+})
+
+var accessReportMedia = a.MediaType("application/vnd.access-report+json", func() {
+	a.Description("An access report of a resource and all its descendants")
+	a.Attributes(func() {
+		a.Attribute("data", accessReportData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var accessReportData = a.Type("AccessReportData", func() {
+	a.Attribute("id", d.UUID, "The ID of the access report")
+	a.Attribute("resource_id", d.String, "The ID of the reported resource")
+	a.Attribute("format", d.String, "The format of the report, either 'csv' or 'ndjson'")
+	a.Attribute("status", d.String, "The status of the report, either 'pending', 'completed' or 'failed'")
+	a.Attribute("error", d.String, "The reason why the report could not be generated, if it failed")
+	a.Attribute("created_at", d.DateTime, "The time at which the report was requested")
+	a.Attribute("completed_at", d.DateTime, "The time at which the report was completed or failed")
+	a.Attribute("links", genericLinks, "The 'related' link is the URL to download the content of the report")
+	a.Required("id", "resource_id", "format", "status", "created_at")
+})
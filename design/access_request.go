@@ -0,0 +1,140 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("access_requests", func() {
+	a.BasePath("/resources")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:resourceID/access_requests"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+		})
+		a.Payload(accessRequestPayload)
+		a.Description("Request a role for a resource. The administrators of the resource are notified, and can approve or deny the request.")
+		a.Response(d.Created, accessRequestMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceID/access_requests"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+		})
+		a.Description("List the pending access requests for a resource")
+		a.Response(d.OK, accessRequestsMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("approve", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:resourceID/access_requests/:requestID/approve"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+			a.Param("requestID", d.UUID, "ID of the access request")
+		})
+		a.Description("Approve an access request, assigning the requested role to the user who requested it")
+		a.Response(d.OK, accessRequestMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("deny", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:resourceID/access_requests/:requestID/deny"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+			a.Param("requestID", d.UUID, "ID of the access request")
+		})
+		a.OptionalPayload(accessRequestDenialPayload)
+		a.Description("Deny an access request, with an optional reason for the user who requested it")
+		a.Response(d.OK, accessRequestMedia)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var accessRequestPayload = a.Type("AccessRequestPayload", func() {
+	a.Attribute("data", accessRequestPayloadData)
+	a.Required("data")
+})
+
+var accessRequestPayloadData = a.Type("AccessRequestPayloadData", func() {
+	a.Attribute("role", d.String, "The name of the requested role")
+	a.Attribute("message", d.String, "A message for the administrators of the resource")
+	a.Required("role")
+})
+
+var accessRequestDenialPayload = a.Type("AccessRequestDenialPayload", func() {
+	a.Attribute("data", accessRequestDenialData)
+	a.Required("data")
+})
+
+var accessRequestDenialData = a.Type("AccessRequestDenialData", func() {
+	a.Attribute("reason", d.String, "The reason why the request is denied")
+})
+
+var accessRequestMedia = a.MediaType("application/vnd.access-request+json", func() {
+	a.Description("A request of a user for a role on a resource")
+	a.Attributes(func() {
+		a.Attribute("data", accessRequestData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var accessRequestsMedia = a.MediaType("application/vnd.access-requests+json", func() {
+	a.Description("Requests of users for a role on a resource")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(accessRequestData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var accessRequestData = a.Type("AccessRequestData", func() {
+	a.Attribute("id", d.UUID, "The ID of the access request")
+	a.Attribute("resource_id", d.String, "The ID of the resource")
+	a.Attribute("requester_id", d.UUID, "The ID of the identity who requested the role")
+	a.Attribute("requester_name", d.String, "The username of the identity who requested the role")
+	a.Attribute("role", d.String, "The name of the requested role")
+	a.Attribute("message", d.String, "The message for the administrators of the resource")
+	a.Attribute("status", d.String, "The status of the request, either 'pending', 'approved' or 'denied'")
+	a.Attribute("reason", d.String, "The reason why the request was denied, if any")
+	a.Attribute("created_at", d.DateTime, "The time at which the role was requested")
+	a.Attribute("decided_at", d.DateTime, "The time at which the request was approved or denied")
+	a.Required("id", "resource_id", "requester_id", "role", "status", "created_at")
+})
@@ -17,6 +17,8 @@ var _ = a.Resource("collaborators", func() {
 			a.Param("spaceID", d.UUID, "ID of the space")
 			a.Param("page[offset]", d.String, "Paging start position")
 			a.Param("page[limit]", d.Integer, "Paging size")
+			a.Param("role", a.ArrayOf(d.String), "Names of the space roles to filter the collaborators by. Defaults to the admin and contributor roles")
+			a.Param("q", d.String, "Text to search for in the username, full name or email of the collaborators")
 		})
 		a.UseTrait("conditional")
 		a.Response(d.OK, userList)
@@ -36,6 +38,7 @@ var _ = a.Resource("collaborators", func() {
 		a.Description("Add users to the list of space collaborators.")
 		a.Params(func() {
 			a.Param("spaceID", d.UUID, "ID of the space")
+			a.Param("role", d.String, "Name of the space role to assign to the users. Defaults to the contributor role")
 		})
 		a.Response(d.OK)
 		a.Payload(updateUserIDList)
@@ -54,6 +57,7 @@ var _ = a.Resource("collaborators", func() {
 		a.Description("Add a user to the list of space collaborators.")
 		a.Params(func() {
 			a.Param("spaceID", d.UUID, "ID of the space")
+			a.Param("role", d.String, "Name of the space role to assign to the user. Defaults to the contributor role")
 		})
 		a.Response(d.OK)
 		a.Response(d.NotFound, JSONAPIErrors)
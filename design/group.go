@@ -0,0 +1,165 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("group", func() {
+
+	a.BasePath("/groups")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Create a new security group in an organization")
+		a.Payload(CreateGroupRequestMedia)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Created, CreateGroupResponseMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:groupID/members"),
+		)
+		a.Params(func() {
+			a.Param("groupID", d.UUID, "ID of the security group")
+		})
+		a.Description("Lists the direct members of a security group")
+		a.Response(d.OK, groupMemberArray)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("addMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:groupID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("groupID", d.UUID, "ID of the security group")
+			a.Param("memberID", d.UUID, "ID of the user, team or security group to add to the members")
+		})
+		a.Description("Adds a user, a team or another security group to the members of a security group")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:groupID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("groupID", d.UUID, "ID of the security group")
+			a.Param("memberID", d.UUID, "ID of the user, team or security group to remove from the members")
+		})
+		a.Description("Removes a user, a team or another security group from the members of a security group")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("assignRole", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:groupID/resources/:resourceID/roles/:roleName"),
+		)
+		a.Params(func() {
+			a.Param("groupID", d.UUID, "ID of the security group")
+			a.Param("resourceID", d.String, "ID of the resource")
+			a.Param("roleName", d.String, "Name of the role of the resource to assign to the security group")
+		})
+		a.Description("Assigns a role of a resource to a security group")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+})
+
+var CreateGroupRequestMedia = a.MediaType("application/vnd.create_group_request+json", func() {
+	a.Description("Request payload required to create a new security group")
+	a.Attributes(func() {
+		a.Attribute("organization_id", d.UUID, "The identifier of the organization in which to create the security group")
+		a.Attribute("name", d.String, "The name of the new security group")
+		a.Required("organization_id", "name")
+	})
+	a.View("default", func() {
+		a.Attribute("organization_id")
+		a.Attribute("name")
+	})
+})
+
+var CreateGroupResponseMedia = a.MediaType("application/vnd.create_group_response+json", func() {
+	a.Description("Response returned when creating a new security group")
+	a.Attributes(func() {
+		a.Attribute("group_id", d.String, "The identifier of the new security group")
+	})
+	a.View("default", func() {
+		a.Attribute("group_id")
+	})
+})
+
+var groupArray = a.MediaType("application/vnd.group-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("GroupArray")
+	a.Description("Security Group Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(groupData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var groupData = a.Type("GroupData", func() {
+	a.Attribute("id", d.String, "unique id for the security group")
+	a.Attribute("name", d.String, "name of the security group")
+	a.Attribute("organization_id", d.String, "unique id of the organization the security group belongs to")
+	a.Required("id", "name", "organization_id")
+})
+
+var groupMemberArray = a.MediaType("application/vnd.group-member-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("GroupMemberArray")
+	a.Description("Security Group Member Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(groupMemberData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var groupMemberData = a.Type("GroupMemberData", func() {
+	a.Attribute("id", d.String, "unique id of the member identity")
+	a.Attribute("type", d.String, "type of the member", func() {
+		a.Enum("user", "team", "group")
+	})
+	a.Attribute("name", d.String, "username of the user, or name of the team or security group")
+	a.Required("id", "type", "name")
+})
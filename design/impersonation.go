@@ -0,0 +1,75 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("impersonation", func() {
+
+	a.BasePath("/impersonation")
+
+	a.Action("impersonate", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Payload(impersonationRequest)
+		a.Description("Issues a short-lived access token which lets a user administrator act as another user. The session is recorded with the given reason.")
+		a.Response(d.OK, func() {
+			a.Media(AuthToken)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listSessions", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/sessions"),
+		)
+		a.Params(func() {
+			a.Param("identity_id", d.String, "The ID of the impersonated identity")
+			a.Required("identity_id")
+		})
+		a.Description("Lists the impersonation sessions of an identity, most recent first")
+		a.Response(d.OK, impersonationSessionArray)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var impersonationRequest = a.Type("ImpersonationRequest", func() {
+	a.Attribute("identity_id", d.String, "The ID of the identity to impersonate")
+	a.Attribute("reason", d.String, "The justification of the impersonation, e.g. a support ticket reference")
+	a.Required("identity_id", "reason")
+})
+
+var impersonationSessionArray = a.MediaType("application/vnd.impersonation-session-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("ImpersonationSessionArray")
+	a.Description("Impersonation Session Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(impersonationSessionData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var impersonationSessionData = a.Type("ImpersonationSessionData", func() {
+	a.Attribute("id", d.String, "unique id of the session")
+	a.Attribute("admin_identity_id", d.String, "the ID of the administrator who impersonated the user")
+	a.Attribute("reason", d.String, "the justification given by the administrator")
+	a.Attribute("created_at", d.DateTime, "the time at which the session started")
+	a.Attribute("expires_at", d.DateTime, "the time at which the access token of the session expires")
+	a.Required("id", "admin_identity_id", "reason", "created_at", "expires_at")
+})
@@ -52,6 +52,113 @@ var _ = a.Resource("invitation", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("listPending", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:inviteTo"),
+		)
+		a.Params(func() {
+			a.Param("inviteTo", d.String, "Unique identifier of the organization, team, security group or resource")
+		})
+		a.Description("List the invitations to an organization, team, security group or resource which have neither been accepted nor expired yet")
+		a.Response(d.OK, pendingInvitationArray)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("resendInvite", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:inviteTo/resend"),
+		)
+		a.Params(func() {
+			a.Param("inviteTo", d.String, "Unique identifier for the invitation to the organization, team, security group or resource")
+		})
+		a.Description("Send the invitation e-mail again with a new accept code, and extend the validity of the invitation")
+		a.Response(d.OK)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("listReceived", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/received"),
+		)
+		a.Description("List the invitations addressed to the current user which have neither been accepted nor expired yet")
+		a.Response(d.OK, pendingInvitationArray)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("acceptReceived", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:inviteTo/accept"),
+		)
+		a.Params(func() {
+			a.Param("inviteTo", d.String, "Unique identifier for the invitation addressed to the current user")
+		})
+		a.Description("Accept an invitation addressed to the current user")
+		a.Response(d.OK, acceptedInvitation)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("declineInvite", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:inviteTo/decline"),
+		)
+		a.Params(func() {
+			a.Param("inviteTo", d.String, "Unique identifier for the invitation addressed to the current user")
+		})
+		a.Description("Decline an invitation addressed to the current user. The user who issued the invitation is notified.")
+		a.Response(d.OK)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("importInvites", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/import"),
+		)
+		a.Description("Issue invitations in bulk, from a CSV document or a list of rows. Every row is validated first, then the invitations are issued either all or none (all-or-nothing) or for the valid rows only (best-effort). Large imports are processed asynchronously, and their report can be retrieved once their status is no longer 'pending'.")
+		a.Payload(invitationImportPayload)
+		a.Response(d.OK, invitationImportMedia)
+		a.Response(d.Accepted, invitationImportMedia)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+	})
+
+	a.Action("showImport", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/import/:importID"),
+		)
+		a.Params(func() {
+			a.Param("importID", d.UUID, "ID of the bulk invitation import")
+		})
+		a.Description("Show the status and the per-row report of a bulk invitation import")
+		a.Response(d.OK, invitationImportMedia)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
 
 var CreateInvitationRequestMedia = a.MediaType("application/vnd.create_invitation_request+json", func() {
@@ -74,6 +181,115 @@ var redirectURL = a.Type("RedirectURL", func() {
 
 var invitee = a.Type("Invitee", func() {
 	a.Attribute("identity-id", d.String, "unique id for the user identity")
+	a.Attribute("email", d.String, "email address of the person to invite, if the identity id is not known or the person has no account yet", func() {
+		a.Format("email")
+	})
 	a.Attribute("member", d.Boolean, "if true invites the user to become a member")
 	a.Attribute("roles", a.ArrayOf(d.String), "An array of role names")
+	a.Attribute("accept-on-registration", d.Boolean, "if true the invitation sent to an email address is accepted as soon as the person has signed up")
+})
+
+var pendingInvitationArray = a.MediaType("application/vnd.pending-invitation-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("PendingInvitationArray")
+	a.Description("Pending Invitation Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(pendingInvitationData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var pendingInvitationData = a.Type("PendingInvitationData", func() {
+	a.Attribute("id", d.String, "unique id of the invitation")
+	a.Attribute("invite-to", d.String, "unique id of the organization, team or security group the invitation is for, if any")
+	a.Attribute("resource-id", d.String, "unique id of the resource the invitation is for, if any")
+	a.Attribute("name", d.String, "name of the organization, team, security group or resource the invitation is for")
+	a.Attribute("identity-id", d.String, "unique id of the invited user identity, unless the invited person has not signed up yet")
+	a.Attribute("username", d.String, "username of the invited user, unless the invited person has not signed up yet")
+	a.Attribute("email", d.String, "email address to which the invitation was sent, if the invited person had no account")
+	a.Attribute("member", d.Boolean, "true if the user is invited to become a member")
+	a.Attribute("roles", a.ArrayOf(d.String), "names of the roles offered to the user")
+	a.Attribute("created-at", d.DateTime, "time at which the invitation was issued")
+	a.Attribute("expires-at", d.DateTime, "time after which the invitation can no longer be accepted")
+	a.Required("id", "member", "roles", "created-at")
+})
+
+var acceptedInvitation = a.MediaType("application/vnd.accepted-invitation+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("AcceptedInvitation")
+	a.Description("Accepted Invitation")
+	a.Attributes(func() {
+		a.Attribute("data", acceptedInvitationData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var acceptedInvitationData = a.Type("AcceptedInvitationData", func() {
+	a.Attribute("resource-id", d.String, "unique id of the resource, or identity resource, which the invitation was for")
+	a.Required("resource-id")
+})
+
+var invitationImportPayload = a.Type("InvitationImportPayload", func() {
+	a.Attribute("data", invitationImportRequestData)
+	a.Attribute("links", redirectURL, "links to redirect after accepting invitation sucessfully or in case of error")
+	a.Required("data")
+})
+
+var invitationImportRequestData = a.Type("InvitationImportRequestData", func() {
+	a.Attribute("csv", d.String, "CSV document with a header line and the username, email, target, roles (separated with a semicolon) and member columns. Either csv or rows must be provided.")
+	a.Attribute("rows", a.ArrayOf(invitationImportRow), "rows to import. Either csv or rows must be provided.")
+	a.Attribute("mode", d.String, "whether no invitation is issued if any row fails (all-or-nothing) or the valid rows are issued anyway (best-effort)", func() {
+		a.Enum("all-or-nothing", "best-effort")
+		a.Default("all-or-nothing")
+	})
+})
+
+var invitationImportRow = a.Type("InvitationImportRow", func() {
+	a.Attribute("username", d.String, "username of the invited user")
+	a.Attribute("email", d.String, "email address of the invited person, if the username is not provided")
+	a.Attribute("target", d.String, "unique id of the team (identity id) or space (resource id) the invitation is for")
+	a.Attribute("roles", a.ArrayOf(d.String), "An array of role names")
+	a.Attribute("member", d.Boolean, "if true invites the user to become a member")
+	a.Required("target")
+})
+
+var invitationImportMedia = a.MediaType("application/vnd.invitation-import+json", func() {
+	a.Description("A bulk invitation import")
+	a.Attributes(func() {
+		a.Attribute("data", invitationImportData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var invitationImportData = a.Type("InvitationImportData", func() {
+	a.Attribute("id", d.UUID, "The ID of the bulk invitation import")
+	a.Attribute("mode", d.String, "The mode of the import, either 'all-or-nothing' or 'best-effort'")
+	a.Attribute("status", d.String, "The status of the import, either 'pending', 'completed' or 'failed'")
+	a.Attribute("error", d.String, "The reason why the import failed, if it did")
+	a.Attribute("report", a.ArrayOf(invitationImportRowResult), "The result of each row, once the import is processed")
+	a.Attribute("created-at", d.DateTime, "The time at which the import was requested")
+	a.Attribute("completed-at", d.DateTime, "The time at which the import was completed or failed")
+	a.Required("id", "mode", "status", "created-at")
+})
+
+var invitationImportRowResult = a.Type("InvitationImportRowResult", func() {
+	a.Attribute("row", d.Integer, "The number of the row, starting from 1")
+	a.Attribute("username", d.String, "The username of the invited user")
+	a.Attribute("email", d.String, "The email address of the invited person")
+	a.Attribute("target", d.String, "The unique id of the team or space the invitation is for")
+	a.Attribute("status", d.String, "The result of the row, either 'issued', 'invalid', 'failed' or 'skipped'")
+	a.Attribute("error", d.String, "The reason why the row is invalid or failed")
+	a.Required("row", "target", "status")
 })
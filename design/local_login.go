@@ -0,0 +1,129 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("localLogin", func() {
+
+	a.BasePath("/login/local")
+
+	a.Action("form", func() {
+		a.Routing(
+			a.GET(""),
+		)
+		a.Params(func() {
+			a.Param("state", d.String, "The state value generated by the login request")
+			a.Required("state")
+		})
+		a.Description("Renders the login form of the built-in username/password identity provider")
+		a.Response(d.OK, "text/html")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("login", func() {
+		a.Routing(
+			a.POST(""),
+		)
+		a.Payload(localLogin)
+		a.Description("Authenticates the user with the username and password submitted in the login form")
+		a.Response(d.SeeOther)
+		a.Response(d.Unauthorized, "text/html")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var _ = a.Resource("password", func() {
+
+	a.BasePath("/password")
+
+	a.Action("forgotForm", func() {
+		a.Routing(
+			a.GET("/forgot"),
+		)
+		a.Description("Renders the form used to request a password reset")
+		a.Response(d.OK, "text/html")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("forgot", func() {
+		a.Routing(
+			a.POST("/forgot"),
+		)
+		a.Payload(forgotPassword)
+		a.Description("Sends a password reset link to the email address of the user, if such a user exists")
+		a.Response(d.OK, "text/html")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("resetForm", func() {
+		a.Routing(
+			a.GET("/reset"),
+		)
+		a.Params(func() {
+			a.Param("code", d.String, "The password reset code")
+			a.Required("code")
+		})
+		a.Description("Renders the form used to choose a new password")
+		a.Response(d.OK, "text/html")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("reset", func() {
+		a.Routing(
+			a.POST("/reset"),
+		)
+		a.Payload(resetPassword)
+		a.Description("Sets a new password for the user to whom the password reset code was issued")
+		a.Response(d.OK, "text/html")
+		a.Response(d.BadRequest, "text/html")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("change", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH(""),
+		)
+		a.Payload(changePassword)
+		a.Description("Changes the password of the current user")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var localLogin = a.Type("LocalLogin", func() {
+	a.Attribute("username", d.String, "The username or email address of the user")
+	a.Attribute("password", d.String, "The password of the user")
+	a.Attribute("state", d.String, "The state value generated by the login request")
+	a.Required("username", "password", "state")
+})
+
+var forgotPassword = a.Type("ForgotPassword", func() {
+	a.Attribute("username", d.String, "The username or email address of the user")
+	a.Required("username")
+})
+
+var resetPassword = a.Type("ResetPassword", func() {
+	a.Attribute("code", d.String, "The password reset code")
+	a.Attribute("password", d.String, "The new password")
+	a.Required("code", "password")
+})
+
+var changePassword = a.Type("ChangePassword", func() {
+	a.Attribute("current_password", d.String, "The current password of the user")
+	a.Attribute("new_password", d.String, "The new password")
+	a.Required("current_password", "new_password")
+})
@@ -0,0 +1,153 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("mfa", func() {
+
+	a.BasePath("/mfa")
+
+	a.Action("challengeForm", func() {
+		a.Routing(
+			a.GET("/challenge"),
+		)
+		a.Params(func() {
+			a.Param("challenge", d.String, "The ID of the multi-factor authentication challenge of the login")
+			a.Required("challenge")
+		})
+		a.Description("Renders the form in which the user enters their second factor to complete their login")
+		a.Response(d.OK, "text/html")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("challenge", func() {
+		a.Routing(
+			a.POST("/challenge"),
+		)
+		a.Payload(mfaChallenge)
+		a.Description("Verifies the second factor submitted in the challenge form and completes the login")
+		a.Response(d.SeeOther)
+		a.Response(d.OK, "text/html")
+		a.Response(d.Unauthorized, "text/html")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("enrol", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/enrolment"),
+		)
+		a.Description("Starts the enrolment of a TOTP second factor for the current user")
+		a.Response(d.OK, mfaEnrolment)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("confirm", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/enrolment/confirm"),
+		)
+		a.Payload(mfaCode)
+		a.Description("Confirms the pending TOTP enrolment of the current user with a code from their authenticator app")
+		a.Response(d.OK, mfaRecoveryCodes)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("disable", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/enrolment"),
+		)
+		a.Payload(mfaCode)
+		a.Description("Disables the multi-factor authentication of the current user")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("recoveryCodes", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/recovery-codes"),
+		)
+		a.Payload(mfaCode)
+		a.Description("Replaces the recovery codes of the current user")
+		a.Response(d.OK, mfaRecoveryCodes)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("stepUp", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/stepup"),
+		)
+		a.Payload(mfaCode)
+		a.Description("Verifies the second factor of the current user and issues new tokens of the multi-factor authentication context class")
+		a.Response(d.OK, func() {
+			a.Media(AuthToken)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var mfaChallenge = a.Type("MFAChallenge", func() {
+	a.Attribute("challenge", d.String, "The ID of the multi-factor authentication challenge of the login")
+	a.Attribute("code", d.String, "The code shown by the authenticator app of the user, or one of their recovery codes")
+	a.Required("challenge", "code")
+})
+
+var mfaCode = a.Type("MFACode", func() {
+	a.Attribute("code", d.String, "The code shown by the authenticator app of the user, or one of their recovery codes")
+	a.Required("code")
+})
+
+var mfaEnrolment = a.MediaType("application/vnd.mfa_enrolment+json", func() {
+	a.TypeName("MFAEnrolment")
+	a.Description("The TOTP secret to register in an authenticator app")
+	a.Attributes(func() {
+		a.Attribute("secret", d.String, "The base32 encoded TOTP secret, for manual entry")
+		a.Attribute("provisioning_uri", d.String, "The otpauth:// URI of the secret, usually rendered as a QR code")
+		a.Required("secret", "provisioning_uri")
+	})
+	a.View("default", func() {
+		a.Attribute("secret")
+		a.Attribute("provisioning_uri")
+	})
+})
+
+var mfaRecoveryCodes = a.MediaType("application/vnd.mfa_recovery_codes+json", func() {
+	a.TypeName("MFARecoveryCodes")
+	a.Description("Single use codes which can be entered in place of a TOTP code. They are only returned once.")
+	a.Attributes(func() {
+		a.Attribute("recovery_codes", a.ArrayOf(d.String), "The recovery codes")
+		a.Required("recovery_codes")
+	})
+	a.View("default", func() {
+		a.Attribute("recovery_codes")
+	})
+})
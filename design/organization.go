@@ -33,6 +33,164 @@ var _ = a.Resource("organization", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("listGroups", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:organizationID/groups"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+		})
+		a.Description("Lists the security groups of an organization")
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.OK, groupArray)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:organizationID"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+		})
+		a.Description("Rename an organization")
+		a.Payload(UpdateOrganizationRequestMedia)
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:organizationID"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+		})
+		a.Description("Delete an organization, along with its child resources, memberships and the roles assigned for it")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:organizationID/members"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+		})
+		a.Description("Lists the members of an organization, along with their roles")
+		a.Response(d.OK, organizationMemberArray)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("addMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:organizationID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+			a.Param("memberID", d.UUID, "ID of the user, team or security group to add")
+		})
+		a.Description("Adds a user, a team or a security group to the members of an organization, as a contributor")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:organizationID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+			a.Param("memberID", d.UUID, "ID of the member to remove")
+		})
+		a.Description("Removes a member from an organization, revoking the roles assigned to it for the organization")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+
+	a.Action("setMemberRole", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:organizationID/members/:memberID/role/:roleName"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+			a.Param("memberID", d.UUID, "ID of the member")
+			a.Param("roleName", d.String, "Name of the organization role to assign", func() {
+				a.Enum("admin", "contributor")
+			})
+		})
+		a.Description("Changes the role of a member of an organization")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+
+	a.Action("transferOwnership", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:organizationID/owner/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("organizationID", d.UUID, "ID of the organization")
+			a.Param("memberID", d.UUID, "ID of the member becoming the new owner")
+		})
+		a.Description("Transfers the ownership of an organization to another member, demoting the current user to contributor")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+})
+
+var UpdateOrganizationRequestMedia = a.MediaType("application/vnd.update_organization_request+json", func() {
+	a.Description("Request payload required to rename an organization")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The new name of the organization")
+		a.Required("name")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+	})
 })
 
 var CreateOrganizationRequestMedia = a.MediaType("application/vnd.create_organization_request+json", func() {
@@ -77,3 +235,24 @@ var organizationData = a.Type("OrganizationData", func() {
 	a.Attribute("roles", a.ArrayOf(d.String), "roles assigned to the user for the organization")
 	a.Required("id", "name", "member", "roles")
 })
+
+var organizationMemberArray = a.MediaType("application/vnd.organization-member-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("OrganizationMemberArray")
+	a.Description("Organization Member Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(organizationMemberData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var organizationMemberData = a.Type("OrganizationMemberData", func() {
+	a.Attribute("id", d.String, "unique id of the member identity")
+	a.Attribute("username", d.String, "username of the member")
+	a.Attribute("roles", a.ArrayOf(d.String), "roles assigned to the member for the organization")
+	a.Required("id", "username", "roles")
+})
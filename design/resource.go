@@ -71,6 +71,59 @@ var _ = a.Resource("resource", func() {
 		a.Response(d.NotFound, JSONAPIErrors)
 	})
 
+	a.Action("listChildren", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceId/children"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource to list the children of")
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
+		})
+		a.Description("List the direct children of a resource")
+		a.Response(d.OK, resourceList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("ancestors", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceId/ancestors"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource to list the ancestors of")
+		})
+		a.Description("List the ancestors of a resource, from the root resource down to its parent")
+		a.Response(d.OK, ResourceAncestorsMedia)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("reparent", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:resourceId/parent"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "Identifier of the resource to move")
+		})
+		a.Description("Move a resource, along with all its descendants, to another parent resource")
+		a.Payload(ReparentResourcePayload)
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
 })
 
 // ResourceMedia represents a protected resource
@@ -140,3 +193,45 @@ var RegisterResourceResponseMedia = a.MediaType("application/vnd.register_resour
 		a.Attribute("resource_id")
 	})
 })
+
+var resourceListMeta = a.Type("ResourceListMeta", func() {
+	a.Attribute("totalCount", d.Integer)
+	a.Required("totalCount")
+})
+
+var resourceList = JSONList(
+	"Resource", "Holds the paginated response to a resource list request",
+	resourceData,
+	pagingLinks,
+	resourceListMeta)
+
+// resourceData represents a resource in a resource hierarchy
+var resourceData = a.Type("ResourceData", func() {
+	a.Attribute("id", d.String, "The identifier of the resource")
+	a.Attribute("name", d.String, "The name of the resource")
+	a.Attribute("type", d.String, "The type of resource")
+	a.Attribute("parent_resource_id", d.String, "The parent resource to which this resource belongs")
+	a.Required("id", "name", "type")
+})
+
+var ResourceAncestorsMedia = a.MediaType("application/vnd.resource_ancestors+json", func() {
+	a.Description("The ancestors of a resource, from the root resource down to its parent")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(resourceData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var ReparentResourcePayload = a.Type("ReparentResourcePayload", func() {
+	a.Attribute("data", ReparentResourceData)
+	a.Required("data")
+})
+
+var ReparentResourceData = a.Type("ReparentResourceData", func() {
+	a.Attribute("parent_resource_id", d.String, "The identifier of the new parent resource")
+	a.Required("parent_resource_id")
+})
@@ -0,0 +1,65 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("resource_types", func() {
+	a.BasePath("/resource_types")
+
+	a.Action("register", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT(""),
+		)
+		a.Payload(resourceTypePayload)
+		a.Description("Register a resource type along with its scopes, roles, default role and default role mappings. Only service accounts may register resource types.")
+		a.Response(d.OK, resourceTypeMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var resourceTypePayload = a.Type("ResourceTypePayload", func() {
+	a.Attribute("data", resourceTypeData)
+	a.Required("data")
+})
+
+var resourceTypeMedia = a.MediaType("application/vnd.resource_type+json", func() {
+	a.Description("The definition of a resource type")
+	a.Attributes(func() {
+		a.Attribute("data", resourceTypeData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var resourceTypeData = a.Type("ResourceTypeData", func() {
+	a.Attribute("name", d.String, "The name of the resource type")
+	a.Attribute("scopes", a.ArrayOf(d.String), "The scopes of the resource type")
+	a.Attribute("roles", a.ArrayOf(resourceTypeRoleData), "The roles of the resource type")
+	a.Attribute("default_role", d.String, "The name of the role assigned by default to the creator of a resource")
+	a.Attribute("default_role_mappings", a.ArrayOf(defaultRoleMappingData), "The role mappings created for every new resource of this type")
+	a.Required("name", "scopes")
+})
+
+var resourceTypeRoleData = a.Type("ResourceTypeRoleData", func() {
+	a.Attribute("name", d.String, "The name of the role")
+	a.Attribute("scopes", a.ArrayOf(d.String), "The scopes granted by the role")
+	a.Required("name", "scopes")
+})
+
+var defaultRoleMappingData = a.Type("DefaultRoleMappingData", func() {
+	a.Attribute("from_role", d.String, "The name of the role of the resource type to map from")
+	a.Attribute("to_resource_type", d.String, "The descendant resource type of the role to map to")
+	a.Attribute("to_role", d.String, "The name of the role of the descendant resource type to map to")
+	a.Required("from_role", "to_resource_type", "to_role")
+})
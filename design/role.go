@@ -22,6 +22,73 @@ var _ = a.Resource("roles", func() {
 		a.Response(d.NotFound, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 	})
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Payload(rolePayload)
+		a.Description("Create a role for a resource type, granting some of the scopes of the resource type")
+		a.Response(d.Created, roleMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:roleID"),
+		)
+		a.Params(func() {
+			a.Param("roleID", d.UUID, "ID of the role to update")
+		})
+		a.Payload(rolePayload)
+		a.Description("Rename a role and replace the set of scopes it grants")
+		a.Response(d.OK, roleMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:roleID"),
+		)
+		a.Params(func() {
+			a.Param("roleID", d.UUID, "ID of the role to delete")
+		})
+		a.Description("Delete a role which is not in use")
+		a.Response(d.NoContent)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var rolePayload = a.Type("RolePayload", func() {
+	a.Attribute("data", rolesData)
+	a.Required("data")
+})
+
+var roleMedia = a.MediaType("application/vnd.role+json", func() {
+	a.Description("A role of a resource type")
+	a.Attributes(func() {
+		a.Attribute("data", rolesData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
 })
 
 var rolesMedia = a.MediaType("application/vnd.roles+json", func() {
@@ -38,6 +105,7 @@ var rolesMedia = a.MediaType("application/vnd.roles+json", func() {
 })
 
 var rolesData = a.Type("rolesData", func() {
+	a.Attribute("role_id", d.String, "The ID of the role")
 	a.Attribute("role_name", d.String, "The name of the role")
 	a.Attribute("resource_type", d.String, "The resource type ")
 	a.Attribute("scope", a.ArrayOf(d.String), "The scopes defined for this role")
@@ -87,6 +155,80 @@ var _ = a.Resource("resource_roles", func() {
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.Conflict, JSONAPIErrors)
 	})
+	a.Action("deny", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:resourceID/deny_assignments"),
+		)
+		a.Payload(denyAssignmentArray)
+		a.Description("Denies scopes to one or more identities for a specific resource and all its descendants, whatever their roles")
+		a.Response(d.OK, denyAssignmentsMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+	a.Action("revokeDeny", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:resourceID/deny_assignments/:denyAssignmentID"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+			a.Param("denyAssignmentID", d.UUID, "ID of the deny assignment to remove")
+		})
+		a.Description("Removes a deny assignment for a specific resource")
+		a.Response(d.NoContent)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+	a.Action("listRoleMappings", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceID/role_mappings"),
+		)
+		a.Description("List the role mappings of a specific resource")
+		a.Response(d.OK, roleMappingsMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+	a.Action("createRoleMapping", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:resourceID/role_mappings"),
+		)
+		a.Payload(roleMappingPayload)
+		a.Description("Create a role mapping for a specific resource, granting a role for its descendants to the identities which have a role for the resource")
+		a.Response(d.Created, roleMappingMedia)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+	})
+	a.Action("deleteRoleMapping", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:resourceID/role_mappings/:roleMappingID"),
+		)
+		a.Params(func() {
+			a.Param("resourceID", d.String, "ID of the resource")
+			a.Param("roleMappingID", d.UUID, "ID of the role mapping to delete")
+		})
+		a.Description("Delete a role mapping of a specific resource")
+		a.Response(d.NoContent)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
 	a.Action("hasScope", func() {
 		a.Security("jwt")
 		a.Routing(
@@ -102,6 +244,39 @@ var _ = a.Resource("resource_roles", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.NotFound, JSONAPIErrors)
 	})
+	a.Action("explainScope", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:resourceId/scopes/:scopeName/explanation"),
+		)
+		a.Params(func() {
+			a.Param("resourceId", d.String, "The identifier of the resource to explain the scope of")
+			a.Param("scopeName", d.String, "The name of the scope to explain")
+			a.Param("identity_id", d.UUID, "The ID of the identity to explain the scope of")
+			a.Required("identity_id")
+		})
+		a.Description("Explains whether an identity has the given scope on the requested resource, with every path granting the scope and the reason why it is blocked, if it is. Only user administrators can explain the scopes of an identity.")
+		a.Response(d.OK, permissionExplanationMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+	a.Action("hasScopes", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/scopes"),
+		)
+		a.Payload(scopeChecks)
+		a.Description("Checks if the user has each of the given scopes on the corresponding resources. Service accounts can check the scopes of another identity.")
+		a.Response(d.OK, identityResourceScopeChecks)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
 
 })
 
@@ -111,10 +286,12 @@ var identityRolesMedia = a.MediaType("application/vnd.identityRoles+json", func(
 	a.Attributes(func() {
 		// keeping one level of nesting so that it's easier to add metadata in future.
 		a.Attribute("data", a.ArrayOf(identityRolesData))
+		a.Attribute("denied", a.ArrayOf(denyAssignmentData), "The scopes explicitly denied for the resource, which take precedence over the assigned roles")
 		a.Required("data")
 	})
 	a.View("default", func() {
 		a.Attribute("data")
+		a.Attribute("denied")
 		a.Required("data")
 	})
 })
@@ -125,10 +302,136 @@ var identityRolesData = a.Type("identityRolesData", func() {
 	a.Attribute("assignee_type", d.String, "The type of assignee, example: user,group,team")
 	a.Attribute("inherited", d.Boolean)
 	a.Attribute("inherited_from", d.String, "The ID of the resource from this role was inherited")
+	a.Attribute("valid_from", d.DateTime, "The time from which the role assignment is effective, if it is not effective immediately")
+	a.Attribute("valid_until", d.DateTime, "The time until which the role assignment is effective, if it does not last until it is removed")
 
 	a.Required("role_name", "assignee_id", "assignee_type", "inherited")
 })
 
+var denyAssignmentsMedia = a.MediaType("application/vnd.deny-assignments+json", func() {
+	a.Description("Deny Assignments in a Protected Resource")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(denyAssignmentData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var denyAssignmentData = a.Type("denyAssignmentData", func() {
+	a.Attribute("id", d.UUID, "The ID of the deny assignment")
+	a.Attribute("scope_name", d.String, "The name of the denied scope")
+	a.Attribute("assignee_id", d.String, "The ID of the identity to which the scope is denied")
+	a.Attribute("assignee_type", d.String, "The type of assignee, example: user,group")
+	a.Attribute("inherited", d.Boolean)
+	a.Attribute("inherited_from", d.String, "The ID of the ancestor resource for which the scope is denied")
+
+	a.Required("id", "scope_name", "assignee_id", "assignee_type", "inherited")
+})
+
+var roleMappingsMedia = a.MediaType("application/vnd.role-mappings+json", func() {
+	a.Description("Role Mappings of a Protected Resource")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(roleMappingData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var roleMappingMedia = a.MediaType("application/vnd.role-mapping+json", func() {
+	a.Description("Role Mapping of a Protected Resource")
+	a.Attributes(func() {
+		a.Attribute("data", roleMappingData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var roleMappingPayload = a.Type("RoleMappingPayload", func() {
+	a.Attribute("data", roleMappingData)
+	a.Required("data")
+})
+
+var roleMappingData = a.Type("RoleMappingData", func() {
+	a.Attribute("id", d.UUID, "The ID of the role mapping")
+	a.Attribute("from_role", d.String, "The name of the role of the resource to map from")
+	a.Attribute("to_resource_type", d.String, "The resource type of the descendant resources")
+	a.Attribute("to_role", d.String, "The name of the role of the descendant resources to map to")
+	a.Required("from_role", "to_resource_type", "to_role")
+})
+
+var permissionExplanationMedia = a.MediaType("application/vnd.permission-explanation+json", func() {
+	a.Description("Explanation of a scope of an identity on a Protected Resource")
+	a.Attributes(func() {
+		a.Attribute("data", permissionExplanationData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var permissionExplanationData = a.Type("PermissionExplanationData", func() {
+	a.Attribute("identity_id", d.UUID, "The ID of the identity")
+	a.Attribute("resource_id", d.String, "The ID of the resource")
+	a.Attribute("scope_name", d.String, "The name of the scope")
+	a.Attribute("granted", d.Boolean, "'true' if at least one path grants the scope, 'false' otherwise")
+	a.Attribute("paths", a.ArrayOf(grantPathData), "The paths through which a role granting the scope reaches the identity")
+	a.Attribute("denied", a.ArrayOf(denyAssignmentData), "The deny assignments revoking the scope, which take precedence over the paths")
+	a.Required("identity_id", "resource_id", "scope_name", "granted", "paths", "denied")
+})
+
+var grantPathData = a.Type("GrantPathData", func() {
+	a.Attribute("role_name", d.String, "The name of the assigned role")
+	a.Attribute("role_resource_type", d.String, "The resource type of the assigned role")
+	a.Attribute("assignee_id", d.String, "The ID of the identity the role is assigned to")
+	a.Attribute("assigned_for", d.String, "The ID of the resource the role is assigned for")
+	a.Attribute("valid_from", d.DateTime, "The time from which the role assignment is effective, if it is not effective immediately")
+	a.Attribute("valid_until", d.DateTime, "The time until which the role assignment is effective, if it does not last until it is removed")
+	a.Attribute("membership_chain", a.ArrayOf(pathNodeData), "The identities from the explained identity to the assignee, each one a member of the next one")
+	a.Attribute("resource_chain", a.ArrayOf(pathNodeData), "The resources from the one the role is assigned for to the explained resource, each one the parent of the next one")
+	a.Attribute("role_mappings", a.ArrayOf(roleMappingData), "The role mappings applied, in order, to map the assigned role to a role granting the scope")
+	a.Attribute("blocked_by", d.String, "The reason why the path does not grant the scope, if it does not", func() {
+		a.Enum("denied", "expired", "not_yet_valid")
+	})
+	a.Required("role_name", "role_resource_type", "assignee_id", "assigned_for", "membership_chain", "resource_chain", "role_mappings")
+})
+
+var pathNodeData = a.Type("PathNodeData", func() {
+	a.Attribute("id", d.String, "The ID of the identity or resource")
+	a.Attribute("name", d.String, "The name of the identity or resource")
+	a.Required("id", "name")
+})
+
+var denyAssignmentArray = a.MediaType("application/vnd.deny-assignment-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("DenyAssignmentArray")
+	a.Description("Deny Assignment Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(denyAssignmentPayloadData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var denyAssignmentPayloadData = a.Type("DenyAssignmentPayloadData", func() {
+	a.Attribute("scope", d.String, "name of the scope to deny")
+	a.Attribute("ids", a.ArrayOf(d.String), "identity ids to deny the scope to")
+	a.Required("scope", "ids")
+})
+
 var assignRoleArray = a.MediaType("application/vnd.assign-role-array+json", func() {
 	a.UseTrait("jsonapi-media-type")
 	a.TypeName("AssignRoleArray")
@@ -146,6 +449,8 @@ var assignRoleArray = a.MediaType("application/vnd.assign-role-array+json", func
 var assignRoleData = a.Type("AssignRoleData", func() {
 	a.Attribute("role", d.String, "name of the role to assign")
 	a.Attribute("ids", a.ArrayOf(d.String), "identity ids to assign role to")
+	a.Attribute("valid_from", d.DateTime, "the time from which the role assignment is effective. Effective immediately if not specified")
+	a.Attribute("valid_until", d.DateTime, "the time until which the role assignment is effective. Effective until removed if not specified")
 	a.Required("role", "ids")
 })
 
@@ -170,3 +475,41 @@ var identityResourceScopeData = a.Type("identityResourceScopeData", func() {
 	a.Attribute("hasScope", d.Boolean, "'true' if the user has the given scope, 'false' otherwise")
 	a.Required("scopeName", "hasScope")
 })
+
+// scopeChecks represents a request to check many scopes of a user on many resources at once
+var scopeChecks = a.Type("ScopeChecks", func() {
+	a.Attribute("identity_id", d.String, "The ID of the identity whose scopes are checked, only for service accounts. Defaults to the current user")
+	a.Attribute("checks", a.ArrayOf(scopeCheck), "The resources and scopes to check")
+	a.Attribute("scope_name", d.String, "The name of a scope to check on each of the resources in 'resource_ids'")
+	a.Attribute("resource_ids", a.ArrayOf(d.String), "The identifiers of the resources on which the scope in 'scope_name' is checked")
+})
+
+// scopeCheck represents a resource and a scope to check
+var scopeCheck = a.Type("ScopeCheck", func() {
+	a.Attribute("resource_id", d.String, "The identifier of the resource")
+	a.Attribute("scope_name", d.String, "The name of the scope")
+	a.Required("resource_id", "scope_name")
+})
+
+// identityResourceScopeChecks represents a response to many permission/scope checks for a user
+var identityResourceScopeChecks = a.MediaType("application/vnd.resource.scope-checks+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("IdentityResourceScopeChecks")
+	a.Description("Scope checks for a user on many resources")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(identityResourceScopeCheckData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// identityResourceScopeCheckData
+var identityResourceScopeCheckData = a.Type("identityResourceScopeCheckData", func() {
+	a.Attribute("resource_id", d.String, "the identifier of the resource that was checked")
+	a.Attribute("scope_name", d.String, "the name of the scope that was checked")
+	a.Attribute("has_scope", d.Boolean, "'true' if the user has the given scope for the resource, 'false' otherwise")
+	a.Required("resource_id", "scope_name", "has_scope")
+})
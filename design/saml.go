@@ -0,0 +1,72 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("saml", func() {
+
+	a.BasePath("/saml")
+
+	a.Action("metadata", func() {
+		a.Routing(
+			a.GET("/metadata"),
+		)
+		a.Description("Returns the SAML 2.0 service provider metadata which should be registered with the SAML identity provider")
+		a.Response(d.OK, "application/samlmetadata+xml")
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("sso", func() {
+		a.Routing(
+			a.GET("/sso"),
+		)
+		a.Params(func() {
+			a.Param("RelayState", d.String, "The state value generated by the login request")
+			a.Required("RelayState")
+		})
+		a.Description("Renders a self-submitting form which sends an authentication request to the SAML identity provider using the HTTP-POST binding")
+		a.Response(d.OK, "text/html")
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("acs", func() {
+		a.Routing(
+			a.POST("/acs"),
+		)
+		a.Payload(samlResponse)
+		a.Description("Assertion consumer service for SAML responses delivered using the HTTP-POST binding")
+		a.Response(d.SeeOther)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("acsRedirect", func() {
+		a.Routing(
+			a.GET("/acs"),
+		)
+		a.Params(func() {
+			a.Param("SAMLResponse", d.String, "The base64 encoded and deflated SAML response")
+			a.Param("RelayState", d.String, "The state value generated by the login request")
+			a.Required("SAMLResponse", "RelayState")
+		})
+		a.Description("Assertion consumer service for SAML responses delivered using the HTTP-Redirect binding")
+		a.Response(d.TemporaryRedirect)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var samlResponse = a.Type("SAMLResponse", func() {
+	a.Attribute("SAMLResponse", d.String, "The base64 encoded SAML response")
+	a.Attribute("RelayState", d.String, "The state value generated by the login request")
+	a.Required("SAMLResponse", "RelayState")
+})
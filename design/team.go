@@ -34,6 +34,94 @@ var _ = a.Resource("team", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:teamID"),
+		)
+		a.Params(func() {
+			a.Param("teamID", d.UUID, "ID of the team")
+		})
+		a.Description("Rename a team")
+		a.Payload(UpdateTeamRequestMedia)
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:teamID"),
+		)
+		a.Params(func() {
+			a.Param("teamID", d.UUID, "ID of the team")
+		})
+		a.Description("Delete a team, along with its memberships and the roles assigned to it")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:teamID/members"),
+		)
+		a.Params(func() {
+			a.Param("teamID", d.UUID, "ID of the team")
+		})
+		a.Description("Lists the members of a team")
+		a.Response(d.OK, teamMemberArray)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("addMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:teamID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("teamID", d.UUID, "ID of the team")
+			a.Param("memberID", d.UUID, "ID of the user to add to the members")
+		})
+		a.Description("Adds a user to the members of a team")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:teamID/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("teamID", d.UUID, "ID of the team")
+			a.Param("memberID", d.UUID, "ID of the member to remove")
+		})
+		a.Description("Removes a member from a team")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+	})
 })
 
 var CreateTeamRequestMedia = a.MediaType("application/vnd.create_team_request+json", func() {
@@ -48,6 +136,17 @@ var CreateTeamRequestMedia = a.MediaType("application/vnd.create_team_request+js
 	})
 })
 
+var UpdateTeamRequestMedia = a.MediaType("application/vnd.update_team_request+json", func() {
+	a.Description("Request payload required to rename a team")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The new name of the team")
+		a.Required("name")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+	})
+})
+
 var CreateTeamResponseMedia = a.MediaType("application/vnd.create_team_response+json", func() {
 	a.Description("Response returned when creating a new team")
 	a.Attributes(func() {
@@ -81,3 +180,23 @@ var identityTeamData = a.Type("IdentityTeamData", func() {
 	a.Attribute("roles", a.ArrayOf(d.String), "roles assigned to the user for the team")
 	a.Required("id", "name", "space_id", "member", "roles")
 })
+
+var teamMemberArray = a.MediaType("application/vnd.team-member-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("TeamMemberArray")
+	a.Description("Team Member Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(teamMemberData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+var teamMemberData = a.Type("TeamMemberData", func() {
+	a.Attribute("id", d.String, "unique id of the member identity")
+	a.Attribute("username", d.String, "username of the member")
+	a.Required("id", "username")
+})
@@ -84,6 +84,21 @@ func IsForbiddenError(err error) (bool, error) {
 	return true, e
 }
 
+// NewInsufficientAuthenticationError returns the custom defined error of type InsufficientAuthenticationError.
+func NewInsufficientAuthenticationError(msg string, requiredACR string) InsufficientAuthenticationError {
+	return InsufficientAuthenticationError{simpleError{msg}, requiredACR}
+}
+
+// IsInsufficientAuthenticationError returns true if the cause of the given error can be
+// converted to an InsufficientAuthenticationError, which is returned as the second result.
+func IsInsufficientAuthenticationError(err error) (bool, error) {
+	e, ok := errs.Cause(err).(InsufficientAuthenticationError)
+	if !ok {
+		return false, nil
+	}
+	return true, e
+}
+
 // InternalError means that the operation failed for some internal, unexpected reason
 type InternalError struct {
 	Err error
@@ -104,6 +119,13 @@ type ForbiddenError struct {
 	simpleError
 }
 
+// InsufficientAuthenticationError means that the operation would be allowed, but the user did not authenticate
+// strongly enough and must step up their authentication (e.g. enter their second factor) to reach `RequiredACR`
+type InsufficientAuthenticationError struct {
+	simpleError
+	RequiredACR string
+}
+
 // VersionConflictError means that the version was not as expected in an update operation
 type VersionConflictError struct {
 	simpleError
@@ -10,7 +10,12 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	credential "github.com/fabric8-services/fabric8-auth/authentication/credential/repository"
+	impersonation "github.com/fabric8-services/fabric8-auth/authentication/impersonation/repository"
+	mfa "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
 	provider "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
+	accessreport "github.com/fabric8-services/fabric8-auth/authorization/accessreport/repository"
+	accessrequest "github.com/fabric8-services/fabric8-auth/authorization/accessrequest/repository"
 	invitation "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
@@ -100,6 +105,11 @@ func (g *GormBase) OauthStates() provider.OauthStateReferenceRepository {
 	return provider.NewOauthStateReferenceRepository(g.db)
 }
 
+// SAMLAssertionRepository returns a SAML assertion repository
+func (g *GormBase) SAMLAssertionRepository() provider.SAMLAssertionRepository {
+	return provider.NewSAMLAssertionRepository(g.db)
+}
+
 // ExternalTokens returns an ExternalTokens repository
 func (g *GormBase) ExternalTokens() token.ExternalTokenRepository {
 	return token.NewExternalTokenRepository(g.db)
@@ -114,6 +124,10 @@ func (g *GormBase) InvitationRepository() invitation.InvitationRepository {
 	return invitation.NewInvitationRepository(g.db)
 }
 
+func (g *GormBase) InvitationImportRepository() invitation.InvitationImportRepository {
+	return invitation.NewInvitationImportRepository(g.db)
+}
+
 func (g *GormBase) ResourceRepository() resource.ResourceRepository {
 	return resource.NewResourceRepository(g.db)
 }
@@ -142,6 +156,10 @@ func (g *GormBase) RoleMappingRepository() role.RoleMappingRepository {
 	return role.NewRoleMappingRepository(g.db)
 }
 
+func (g *GormBase) DenyAssignmentRepository() role.DenyAssignmentRepository {
+	return role.NewDenyAssignmentRepository(g.db)
+}
+
 func (g *GormBase) TokenRepository() token.TokenRepository {
 	return token.NewTokenRepository(g.db)
 }
@@ -154,16 +172,72 @@ func (g *GormBase) WorkerLockRepository() worker.LockRepository {
 	return worker.NewLockRepository(g.db.DB())
 }
 
+func (g *GormBase) CredentialRepository() credential.CredentialRepository {
+	return credential.NewCredentialRepository(g.db)
+}
+
+func (g *GormBase) MFAEnrolmentRepository() mfa.EnrolmentRepository {
+	return mfa.NewEnrolmentRepository(g.db)
+}
+
+func (g *GormBase) MFARecoveryCodeRepository() mfa.RecoveryCodeRepository {
+	return mfa.NewRecoveryCodeRepository(g.db)
+}
+
+func (g *GormBase) MFAChallengeRepository() mfa.ChallengeRepository {
+	return mfa.NewChallengeRepository(g.db)
+}
+
+func (g *GormBase) ImpersonationSessionRepository() impersonation.SessionRepository {
+	return impersonation.NewSessionRepository(g.db)
+}
+
+func (g *GormBase) AccessReportRepository() accessreport.AccessReportRepository {
+	return accessreport.NewAccessReportRepository(g.db)
+}
+
+func (g *GormBase) AccessRequestRepository() accessrequest.AccessRequestRepository {
+	return accessrequest.NewAccessRequestRepository(g.db)
+}
+
 //----------------------------------------------------------------------------------------------------------------------
 //
 // Services
 //
 //----------------------------------------------------------------------------------------------------------------------
 
+func (g *GormDB) AccessReportService() service.AccessReportService {
+	return g.serviceFactory.AccessReportService()
+}
+
+func (g *GormDB) AccessRequestService() service.AccessRequestService {
+	return g.serviceFactory.AccessRequestService()
+}
+
 func (g *GormDB) AuthenticationProviderService() service.AuthenticationProviderService {
 	return g.serviceFactory.AuthenticationProviderService()
 }
 
+func (g *GormDB) CredentialService() service.CredentialService {
+	return g.serviceFactory.CredentialService()
+}
+
+func (g *GormDB) GroupService() service.GroupService {
+	return g.serviceFactory.GroupService()
+}
+
+func (g *GormDB) MembershipService() service.MembershipService {
+	return g.serviceFactory.MembershipService()
+}
+
+func (g *GormDB) MFAService() service.MFAService {
+	return g.serviceFactory.MFAService()
+}
+
+func (g *GormDB) ImpersonationService() service.ImpersonationService {
+	return g.serviceFactory.ImpersonationService()
+}
+
 func (g *GormDB) InvitationService() service.InvitationService {
 	return g.serviceFactory.InvitationService()
 }
@@ -204,6 +278,10 @@ func (g *GormDB) ResourceService() service.ResourceService {
 	return g.serviceFactory.ResourceService()
 }
 
+func (g *GormDB) ResourceTypeService() service.ResourceTypeService {
+	return g.serviceFactory.ResourceTypeService()
+}
+
 func (g *GormDB) SpaceService() service.SpaceService {
 	return g.serviceFactory.SpaceService()
 }
@@ -43,6 +43,9 @@ func ErrorHandler(service *goa.Service, verbose bool) goa.Middleware {
 			var respBody interface{}
 			respBody, status = ErrorToJSONAPIErrors(ctx, e)
 			rw.Header().Set("Content-Type", ErrorMediaIdentifier)
+			if stepUp, err := errors.IsInsufficientAuthenticationError(e); stepUp {
+				addStepUpHeader(ctx, err.(errors.InsufficientAuthenticationError))
+			}
 			if err, ok := cause.(goa.ServiceError); ok {
 				status = err.ResponseStatus()
 				//respBody = err
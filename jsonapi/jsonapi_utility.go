@@ -2,6 +2,7 @@ package jsonapi
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -25,6 +26,9 @@ const (
 	ErrorCodeUnauthorizedError = "unauthorized_error"
 	ErrorCodeForbiddenError    = "forbidden_error"
 	ErrorCodeJWTSecurityError  = "jwt_security_error"
+
+	// ErrorCodeInsufficientAuthentication is the error code defined by RFC 9470 for step-up authentication
+	ErrorCodeInsufficientAuthentication = "insufficient_user_authentication"
 )
 
 // ErrorToJSONAPIError returns the JSONAPI representation
@@ -71,6 +75,10 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		code = ErrorCodeForbiddenError
 		title = "Forbidden error"
 		statusCode = http.StatusForbidden
+	case errors.InsufficientAuthenticationError:
+		code = ErrorCodeInsufficientAuthentication
+		title = "Insufficient authentication error"
+		statusCode = http.StatusUnauthorized
 	default:
 		code = ErrorCodeUnknownError
 		title = "Unknown error"
@@ -144,6 +152,9 @@ type Conflict interface {
 // If all else fails, InternalServerError is returned
 func JSONErrorResponse(ctx InternalServerError, err error) error {
 	jsonErr, status := ErrorToJSONAPIErrors(ctx, err)
+	if stepUp, e := errors.IsInsufficientAuthenticationError(err); stepUp {
+		addStepUpHeader(ctx, e.(errors.InsufficientAuthenticationError))
+	}
 	switch status {
 	case http.StatusBadRequest:
 		if ctx, ok := ctx.(BadRequest); ok {
@@ -170,3 +181,15 @@ func JSONErrorResponse(ctx InternalServerError, err error) error {
 	sentry.Sentry().CaptureError(ctx, err)
 	return errs.WithStack(ctx.InternalServerError(jsonErr))
 }
+
+// addStepUpHeader tells the client which authentication context class the user must step up to, as specified by
+// RFC 9470
+func addStepUpHeader(ctx context.Context, err errors.InsufficientAuthenticationError) {
+	resp := goa.ContextResponse(ctx)
+	if resp == nil {
+		return
+	}
+	resp.Header().Add("Access-Control-Expose-Headers", "WWW-Authenticate")
+	resp.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"%s\", error_description=\"%s\", acr_values=\"%s\"",
+		ErrorCodeInsufficientAuthentication, err.Error(), err.RequiredACR))
+}
@@ -14,8 +14,13 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	factorymanager "github.com/fabric8-services/fabric8-auth/application/factory/manager"
 	appservice "github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	accountservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	accessreportworker "github.com/fabric8-services/fabric8-auth/authorization/accessreport/worker"
+	invitationworker "github.com/fabric8-services/fabric8-auth/authorization/invitation/worker"
+	permissioncache "github.com/fabric8-services/fabric8-auth/authorization/permission/cache"
+	roleworker "github.com/fabric8-services/fabric8-auth/authorization/role/worker"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	tokenworker "github.com/fabric8-services/fabric8-auth/authorization/token/worker"
 	"github.com/fabric8-services/fabric8-auth/configuration"
@@ -26,6 +31,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/migration"
 	"github.com/fabric8-services/fabric8-auth/sentry"
+	"github.com/fabric8-services/fabric8-auth/worker"
 
 	"github.com/goadesign/goa"
 	goalogrus "github.com/goadesign/goa/logging/logrus"
@@ -141,8 +147,19 @@ func main() {
 
 	service.WithLogger(goalogrus.New(log.Logger()))
 
+	// Setup the in-memory privilege cache, whose entries are invalidated by all the replicas through the DB
+	var factoryOptions []factory.Option
+	workers := []Worker{}
+	if config.GetPrivilegeCacheLocalSize() > 0 {
+		privilegeCache := permissioncache.NewPrivilegeCache(config.GetPrivilegeCacheLocalSize(), config.GetPrivilegeCacheLocalTTL())
+		privilegeCacheListener := permissioncache.NewInvalidationListener(config.GetPostgresConfigString(), privilegeCache)
+		privilegeCacheListener.Start(time.Minute)
+		workers = append(workers, privilegeCacheListener)
+		factoryOptions = append(factoryOptions, factory.WithPrivilegeCache(privilegeCache))
+	}
+
 	// Setup Account/Login/Security
-	appDB := gormapplication.NewGormDB(db, config, factorymanager.NewDisabledFactoryWrappers())
+	appDB := gormapplication.NewGormDB(db, config, factorymanager.NewDisabledFactoryWrappers(), factoryOptions...)
 
 	tokenManager, err := manager.DefaultManager(config)
 	if err != nil {
@@ -185,6 +202,26 @@ func main() {
 	loginCtrl := controller.NewLoginController(service, appDB)
 	app.MountLoginController(service, loginCtrl)
 
+	// Mount "saml" controller
+	samlCtrl := controller.NewSamlController(service, appDB, config)
+	app.MountSamlController(service, samlCtrl)
+
+	// Mount "localLogin" controller
+	localLoginCtrl := controller.NewLocalLoginController(service, appDB, config)
+	app.MountLocalLoginController(service, localLoginCtrl)
+
+	// Mount "password" controller
+	passwordCtrl := controller.NewPasswordController(service, appDB, config)
+	app.MountPasswordController(service, passwordCtrl)
+
+	// Mount "mfa" controller
+	mfaCtrl := controller.NewMFAController(service, appDB)
+	app.MountMFAController(service, mfaCtrl)
+
+	// Mount "impersonation" controller
+	impersonationCtrl := controller.NewImpersonationController(service, appDB)
+	app.MountImpersonationController(service, impersonationCtrl)
+
 	// Mount "resource-roles" controller
 	resourceRoleCtrl := controller.NewResourceRolesController(service, appDB)
 	app.MountResourceRolesController(service, resourceRoleCtrl)
@@ -193,6 +230,18 @@ func main() {
 	rolesCtrl := controller.NewRolesController(service, appDB)
 	app.MountRolesController(service, rolesCtrl)
 
+	// Mount "resource-types" controller
+	resourceTypesCtrl := controller.NewResourceTypesController(service, appDB)
+	app.MountResourceTypesController(service, resourceTypesCtrl)
+
+	// Mount "access-reports" controller
+	accessReportsCtrl := controller.NewAccessReportsController(service, appDB)
+	app.MountAccessReportsController(service, accessReportsCtrl)
+
+	// Mount "access-requests" controller
+	accessRequestsCtrl := controller.NewAccessRequestsController(service, appDB)
+	app.MountAccessRequestsController(service, accessRequestsCtrl)
+
 	// Mount "authorize" controller
 	authorizeCtrl := controller.NewAuthorizeController(service, appDB, config)
 	app.MountAuthorizeController(service, authorizeCtrl)
@@ -259,6 +308,10 @@ func main() {
 	teamCtrl := controller.NewTeamController(service, appDB)
 	app.MountTeamController(service, teamCtrl)
 
+	// Mount "groups" controller
+	groupCtrl := controller.NewGroupController(service, appDB)
+	app.MountGroupController(service, groupCtrl)
+
 	// Mount "invitations" controller
 	invitationCtrl := controller.NewInvitationController(service, appDB, config)
 	app.MountInvitationController(service, invitationCtrl)
@@ -294,6 +347,29 @@ func main() {
 	// token cleanup, running once every hour
 	tokenCleanupWorker := tokenworker.NewTokenCleanupWorker(context.Background(), appDB)
 	tokenCleanupWorker.Start(time.Hour)
+	workers = append(workers, tokenCleanupWorker)
+	// role assignment expiry, running on a single pod at a time
+	roleWorkerCtx := manager.ContextWithTokenManager(context.Background(), tokenManager)
+	roleWorkerCtx = context.WithValue(roleWorkerCtx, worker.LockOwner, config.GetPodName())
+	roleAssignmentExpiryWorker := roleworker.NewRoleAssignmentExpiryWorker(roleWorkerCtx, appDB)
+	roleAssignmentExpiryWorker.Start(config.GetRoleAssignmentExpiryWorkerIntervalMinutes())
+	workers = append(workers, roleAssignmentExpiryWorker)
+	// access reports generation, running on a single pod at a time
+	accessReportWorker := accessreportworker.NewAccessReportWorker(roleWorkerCtx, appDB)
+	accessReportWorker.Start(config.GetAccessReportWorkerIntervalSeconds())
+	workers = append(workers, accessReportWorker)
+	// expired access reports purge, running on a single pod at a time
+	accessReportPurgeWorker := accessreportworker.NewAccessReportPurgeWorker(roleWorkerCtx, appDB)
+	accessReportPurgeWorker.Start(config.GetAccessReportPurgeWorkerIntervalMinutes())
+	workers = append(workers, accessReportPurgeWorker)
+	// expired invitations purge, running on a single pod at a time
+	invitationPurgeWorker := invitationworker.NewInvitationPurgeWorker(roleWorkerCtx, appDB)
+	invitationPurgeWorker.Start(config.GetInvitationPurgeWorkerIntervalMinutes())
+	workers = append(workers, invitationPurgeWorker)
+	// bulk invitation imports, running on a single pod at a time
+	invitationImportWorker := invitationworker.NewInvitationImportWorker(roleWorkerCtx, appDB)
+	invitationImportWorker.Start(config.GetInvitationImportWorkerIntervalSeconds())
+	workers = append(workers, invitationImportWorker)
 	// // user deactivation and notification workers, running once per day
 	// DISABLED FOR NOW
 	// ctx := manager.ContextWithTokenManager(context.Background(), tokenManager)
@@ -304,7 +380,7 @@ func main() {
 	// userDeactivationNotificationWorker.Start(config.GetUserDeactivationNotificationWorkerIntervalMinutes())
 
 	// gracefull shutdown
-	go handleShutdown(db, tokenCleanupWorker) //, userDeactivationNotificationWorker, userDeactivationWorker)
+	go handleShutdown(db, workers...) //, userDeactivationNotificationWorker, userDeactivationWorker)
 
 	// Start http
 	if err := http.ListenAndServe(config.GetHTTPAddress(), nil); err != nil {
@@ -259,6 +259,69 @@ func GetMigrations(configuration MigrationConfiguration) Migrations {
 	// Version 50
 	m = append(m, steps{ExecuteSQLFile("050-worker-lock.sql")})
 
+	// Version 51
+	m = append(m, steps{ExecuteSQLFile("051-local-credentials.sql")})
+
+	// Version 52
+	m = append(m, steps{ExecuteSQLFile("052-mfa.sql")})
+
+	// Version 53
+	m = append(m, steps{ExecuteSQLFile("053-impersonation-sessions.sql")})
+
+	// Version 54
+	m = append(m, steps{ExecuteSQLFile("054-identity-role-validity.sql")})
+
+	// Version 55
+	m = append(m, steps{ExecuteSQLFile("055-deny-assignments.sql")})
+
+	// Version 56
+	m = append(m, steps{ExecuteSQLFile("056-resource-type-roles-management.sql")})
+
+	// Version 57
+	m = append(m, steps{ExecuteSQLFile("057-access-reports.sql")})
+
+	// Version 58
+	m = append(m, steps{ExecuteSQLFile("058-security-group-roles.sql")})
+
+	// Version 59
+	m = append(m, steps{ExecuteSQLFile("059-organization-roles.sql")})
+
+	// Version 60
+	m = append(m, steps{ExecuteSQLFile("060-invitation-expiry.sql")})
+
+	// Version 61
+	m = append(m, steps{ExecuteSQLFile("061-invitation-email.sql")})
+
+	// Version 62
+	m = append(m, steps{ExecuteSQLFile("062-invitation-inviter.sql")})
+
+	// Version 63
+	m = append(m, steps{ExecuteSQLFile("063-invitation-import.sql")})
+
+	// Version 64
+	m = append(m, steps{ExecuteSQLFile("064-access-request.sql")})
+
+	// Version 65
+	m = append(m, steps{ExecuteSQLFile("065-mfa-lockout.sql")})
+
+	// Version 66
+	m = append(m, steps{ExecuteSQLFile("066-role-custom.sql")})
+
+	// Version 67
+	m = append(m, steps{ExecuteSQLFile("067-saml-assertion.sql")})
+
+	// Version 68
+	m = append(m, steps{ExecuteSQLFile("068-user-management-min-acr.sql")})
+
+	// Version 69
+	m = append(m, steps{ExecuteSQLFile("069-access-report-chunk.sql")})
+
+	// Version 70
+	m = append(m, steps{ExecuteSQLFile("070-invitation-import-pending-emails.sql")})
+
+	// Version 71
+	m = append(m, steps{ExecuteSQLFile("071-role-name-unique-not-deleted.sql")})
+
 	// Version N
 	//
 	// In order to add an upgrade, simply append an array of MigrationFunc to the
@@ -125,6 +125,9 @@ func TestMigrations(t *testing.T) {
 	t.Run("TestMigration41", testMigration41)
 	t.Run("TestMigration43", testMigration43)
 	t.Run("TestMigration46", testMigration46)
+	t.Run("TestMigration51", testMigration51)
+	t.Run("TestMigration52", testMigration52)
+	t.Run("TestMigration53", testMigration53)
 
 	// Perform the migration
 	if err := migration.Migrate(sqlDB, databaseName, conf); err != nil {
@@ -561,6 +564,46 @@ func testMigration46(t *testing.T) {
 	assert.True(t, lastActive.Add(1*time.Minute).After(time.Now()))
 }
 
+func testMigration51(t *testing.T) {
+	// given
+	migrateToVersion(sqlDB, migrations[:(52)], (52))
+	// then existing verification codes are email verification codes
+	_, err := sqlDB.Exec("INSERT INTO verification_codes (id, code) VALUES ('00000000-0000-0000-0000-000000000051', 'some-code')")
+	require.NoError(t, err)
+	var purpose string
+	err = sqlDB.QueryRow("SELECT purpose FROM verification_codes WHERE id = '00000000-0000-0000-0000-000000000051'").Scan(&purpose)
+	require.NoError(t, err)
+	assert.Equal(t, "email_verification", purpose)
+	// and credentials can only be stored for existing identities
+	_, err = sqlDB.Exec("INSERT INTO credentials (identity_id, password_hash) VALUES (uuid_generate_v4(), 'hash')")
+	require.Error(t, err)
+	countRows(t, "SELECT count(1) FROM credentials", 0)
+}
+
+func testMigration52(t *testing.T) {
+	// given
+	migrateToVersion(sqlDB, migrations[:(53)], (53))
+	// then the MFA tables exist
+	assert.True(t, dialect.HasTable("mfa_enrolments"))
+	assert.True(t, dialect.HasTable("mfa_recovery_codes"))
+	assert.True(t, dialect.HasTable("mfa_challenges"))
+	assert.True(t, dialect.HasColumn("resource_type", "min_acr"))
+	assert.True(t, dialect.HasColumn("resource_type_scope", "min_acr"))
+	// and managing users of the system resource requires multi-factor authentication
+	var minACR string
+	err := sqlDB.QueryRow("SELECT min_acr FROM resource_type_scope WHERE resource_type_scope_id = '4c1c4790-c86c-4937-9223-ac054f6e1251'").Scan(&minACR)
+	require.NoError(t, err)
+	assert.Equal(t, "2", minACR)
+}
+
+func testMigration53(t *testing.T) {
+	// given
+	migrateToVersion(sqlDB, migrations[:(54)], (54))
+	// then
+	assert.True(t, dialect.HasTable("impersonation_sessions"))
+	assert.True(t, dialect.HasIndex("impersonation_sessions", "idx_impersonation_sessions_target_identity_id"))
+}
+
 // runSQLscript loads the given filename from the packaged SQL test files and
 // executes it on the given database. Golang text/template module is used
 // to handle all the optional arguments passed to the sql test files
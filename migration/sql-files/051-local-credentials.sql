@@ -0,0 +1,11 @@
+-- one-time codes may now be issued for purposes other than email verification (e.g. password reset, local login)
+ALTER TABLE verification_codes ADD COLUMN purpose TEXT NOT NULL DEFAULT 'email_verification';
+
+CREATE TABLE credentials (
+    identity_id uuid PRIMARY KEY REFERENCES identities(id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    password_hash TEXT NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until timestamp with time zone
+);
@@ -0,0 +1,39 @@
+-- TOTP second factor of users
+CREATE TABLE mfa_enrolments (
+    identity_id uuid PRIMARY KEY REFERENCES identities(id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    secret TEXT NOT NULL,
+    -- the enrolment is only active once the user has proven that they can generate codes
+    confirmed_at timestamp with time zone,
+    -- the last accepted TOTP time step, so that a code cannot be replayed
+    last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+-- single use recovery codes, which may be used in place of a TOTP code
+CREATE TABLE mfa_recovery_codes (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    code_hash TEXT NOT NULL,
+    used_at timestamp with time zone
+);
+
+CREATE INDEX idx_mfa_recovery_codes_identity_id ON mfa_recovery_codes (identity_id);
+
+-- logins waiting for the user to enter their second factor
+CREATE TABLE mfa_challenges (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    referrer TEXT NOT NULL,
+    amr TEXT NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0
+);
+
+-- minimum authentication context class (`acr` claim) required to be granted the scopes of a resource type, or a single scope
+ALTER TABLE resource_type ADD COLUMN min_acr TEXT;
+ALTER TABLE resource_type_scope ADD COLUMN min_acr TEXT;
+
+-- managing users of the system resource requires multi-factor authentication
+UPDATE resource_type_scope SET min_acr = '2' WHERE resource_type_scope_id = '4c1c4790-c86c-4937-9223-ac054f6e1251';
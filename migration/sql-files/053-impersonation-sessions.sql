@@ -0,0 +1,14 @@
+-- audit trail of the sessions in which an administrator impersonated a user
+CREATE TABLE impersonation_sessions (
+    id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    created_at timestamp with time zone,
+    admin_identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    target_identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    -- the ID (jti claim) of the access token issued for the session
+    token_id uuid NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX idx_impersonation_sessions_target_identity_id ON impersonation_sessions (target_identity_id);
+CREATE INDEX idx_impersonation_sessions_admin_identity_id ON impersonation_sessions (admin_identity_id);
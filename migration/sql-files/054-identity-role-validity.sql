@@ -0,0 +1,6 @@
+-- optional period during which a role assignment is effective
+ALTER TABLE identity_role ADD COLUMN valid_from timestamp with time zone;
+ALTER TABLE identity_role ADD COLUMN valid_until timestamp with time zone;
+
+CREATE INDEX idx_identity_role_valid_from ON identity_role (valid_from) WHERE valid_from IS NOT NULL AND deleted_at IS NULL;
+CREATE INDEX idx_identity_role_valid_until ON identity_role (valid_until) WHERE valid_until IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,15 @@
+-- explicit denials of a scope to an identity (and its members) for a resource and all its descendants, which take
+-- precedence over the scopes granted by roles
+CREATE TABLE deny_assignment (
+    deny_assignment_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    resource_id varchar(256) NOT NULL REFERENCES resource(resource_id) ON DELETE CASCADE,
+    scope_id uuid NOT NULL REFERENCES resource_type_scope(resource_type_scope_id) ON DELETE CASCADE,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+
+CREATE INDEX idx_deny_assignment_resource_id ON deny_assignment (resource_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_deny_assignment_identity_id ON deny_assignment (identity_id) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX uix_deny_assignment ON deny_assignment (identity_id, resource_id, scope_id) WHERE deleted_at IS NULL;
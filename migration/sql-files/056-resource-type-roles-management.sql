@@ -0,0 +1,40 @@
+-- the identity which owns a resource type, and may manage its roles
+ALTER TABLE resource_type ADD COLUMN owner_id uuid NULL;
+
+-- create a scope named 'manage_resource_types', required to manage the roles of any resource type
+
+INSERT INTO resource_type_scope 
+            (resource_type_scope_id, 
+             resource_type_id, 
+             NAME) 
+VALUES     ('598cd553-3349-4445-8f5a-7865f47f692a', 
+            'f5dd9ef5-1bf6-4222-a844-9247ed961a1d', 
+            'manage_resource_types');
+
+-- create a role named 'resource_type_admin'
+
+INSERT INTO role 
+            (role_id, 
+             resource_type_id, 
+             NAME, 
+             created_at, 
+             updated_at) 
+VALUES     ('3e0f5e3c-5389-4717-a9a2-27583c15f3bf', 
+            'f5dd9ef5-1bf6-4222-a844-9247ed961a1d', 
+            'resource_type_admin', 
+            Now(), 
+            Now()); 
+
+-- add scopes 'access', 'manage_resource_types' to role 'resource_type_admin'
+
+INSERT INTO role_scope 
+            (scope_id, 
+             role_id) 
+VALUES     ('ac95b9d7-755a-4c25-8f78-ac1d613b59c9', 
+            '3e0f5e3c-5389-4717-a9a2-27583c15f3bf'); 
+
+INSERT INTO role_scope 
+            (scope_id, 
+             role_id) 
+VALUES     ('598cd553-3349-4445-8f5a-7865f47f692a', 
+            '3e0f5e3c-5389-4717-a9a2-27583c15f3bf'); 
@@ -0,0 +1,17 @@
+-- exports of the effective access of every identity for a resource and all its descendants, generated asynchronously
+CREATE TABLE access_report (
+    access_report_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    resource_id varchar(256) NOT NULL REFERENCES resource(resource_id) ON DELETE CASCADE,
+    requested_by uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    format varchar(16) NOT NULL,
+    status varchar(16) NOT NULL,
+    content text,
+    error text,
+    completed_at timestamp with time zone,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+
+CREATE INDEX idx_access_report_resource_id ON access_report (resource_id) WHERE deleted_at IS NULL;
+CREATE INDEX idx_access_report_status ON access_report (status) WHERE deleted_at IS NULL;
@@ -0,0 +1,16 @@
+-- create the 'view' and 'manage' scopes for security groups
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at)
+  SELECT '412c8107-8e60-434a-b7b8-83ff419ca04b', rt.resource_type_id, 'view', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at)
+  SELECT '6bec5e15-b472-4e6e-b8a2-967793a5b1cf', rt.resource_type_id, 'manage', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+
+-- create the 'admin' role for security groups, with both scopes
+INSERT INTO role (role_id, resource_type_id, name, created_at)
+  SELECT '3aa3c498-b347-4fe8-96f9-f1004a1ab4d6', rt.resource_type_id, 'admin', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('412c8107-8e60-434a-b7b8-83ff419ca04b', '3aa3c498-b347-4fe8-96f9-f1004a1ab4d6', now());
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('6bec5e15-b472-4e6e-b8a2-967793a5b1cf', '3aa3c498-b347-4fe8-96f9-f1004a1ab4d6', now());
+
+-- create the 'viewer' role for security groups, with the 'view' scope
+INSERT INTO role (role_id, resource_type_id, name, created_at)
+  SELECT '647ade41-b7df-49ce-99dd-128498a12f34', rt.resource_type_id, 'viewer', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('412c8107-8e60-434a-b7b8-83ff419ca04b', '647ade41-b7df-49ce-99dd-128498a12f34', now());
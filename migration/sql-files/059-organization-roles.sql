@@ -0,0 +1,10 @@
+-- create the 'view' scope for organizations, and grant it to the 'admin' role
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at)
+  SELECT 'c4f2b1d6-3a0e-4c57-9d8b-5e2f7a61b903', rt.resource_type_id, 'view', now() FROM resource_type rt WHERE rt.name = 'identity/organization';
+INSERT INTO role_scope (scope_id, role_id, created_at)
+  SELECT 'c4f2b1d6-3a0e-4c57-9d8b-5e2f7a61b903', r.role_id, now() FROM role r, resource_type rt WHERE r.resource_type_id = rt.resource_type_id AND r.name = 'admin' AND rt.name = 'identity/organization';
+
+-- create the 'contributor' role for organizations, with the 'view' scope
+INSERT INTO role (role_id, resource_type_id, name, created_at)
+  SELECT '9e7d3c58-61b4-4f0a-8c2e-d5a4b7f10e62', rt.resource_type_id, 'contributor', now() FROM resource_type rt WHERE rt.name = 'identity/organization';
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('c4f2b1d6-3a0e-4c57-9d8b-5e2f7a61b903', '9e7d3c58-61b4-4f0a-8c2e-d5a4b7f10e62', now());
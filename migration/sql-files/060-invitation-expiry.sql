@@ -0,0 +1,7 @@
+-- time after which an invitation can no longer be accepted
+ALTER TABLE invitation ADD COLUMN expires_at timestamp with time zone;
+
+-- pending invitations issued before invitations could expire are given the default validity of 7 days
+UPDATE invitation SET expires_at = created_at + interval '7 days' WHERE deleted_at IS NULL;
+
+CREATE INDEX idx_invitation_expires_at ON invitation (expires_at) WHERE expires_at IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,11 @@
+-- invitations may be addressed to an email address of a person who has no account yet, in which case the identity is
+-- only known once the person has signed up with that email address
+ALTER TABLE invitation ALTER COLUMN identity_id DROP NOT NULL;
+ALTER TABLE invitation ADD COLUMN email TEXT;
+-- if true, the invitation is accepted as soon as the invited person has signed up
+ALTER TABLE invitation ADD COLUMN accept_on_registration BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE invitation ADD CONSTRAINT identity_id_or_email_has_value
+  CHECK (identity_id IS NOT NULL OR (email IS NOT NULL AND email <> ''));
+
+CREATE INDEX idx_invitation_email ON invitation (lower(email)) WHERE identity_id IS NULL AND deleted_at IS NULL;
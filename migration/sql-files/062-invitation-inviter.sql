@@ -0,0 +1,4 @@
+-- the user who issued the invitation, to be notified if it is declined
+ALTER TABLE invitation ADD COLUMN inviter_id uuid REFERENCES identities (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_invitation_identity_id ON invitation (identity_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,18 @@
+-- bulk invitation imports, with the rows to import and the per-row report once processed
+CREATE TABLE invitation_import (
+    invitation_import_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    requested_by uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    mode varchar(16) NOT NULL,
+    status varchar(16) NOT NULL,
+    rows text NOT NULL,
+    report text,
+    error text,
+    success_redirect_url text,
+    failure_redirect_url text,
+    completed_at timestamp with time zone,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+
+CREATE INDEX idx_invitation_import_status ON invitation_import (status) WHERE deleted_at IS NULL;
@@ -0,0 +1,19 @@
+-- requests of users for a role on a resource, approved or denied by the administrators of the resource
+CREATE TABLE access_request (
+    access_request_id uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    resource_id varchar(256) NOT NULL REFERENCES resource(resource_id) ON DELETE CASCADE,
+    requester_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+    role_id uuid NOT NULL REFERENCES role(role_id) ON DELETE CASCADE,
+    message text,
+    status varchar(16) NOT NULL,
+    decided_by uuid REFERENCES identities(id) ON DELETE SET NULL,
+    reason text,
+    decided_at timestamp with time zone,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+
+-- a user may only have a single pending request for a resource
+CREATE UNIQUE INDEX idx_access_request_pending ON access_request (resource_id, requester_id) WHERE status = 'pending' AND deleted_at IS NULL;
+CREATE INDEX idx_access_request_requester_id ON access_request (requester_id) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- consecutive invalid codes entered for the second factor of a user, which is locked for a while after too many of them
+ALTER TABLE mfa_enrolments ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE mfa_enrolments ADD COLUMN locked_until timestamp with time zone;
@@ -0,0 +1,3 @@
+-- custom roles are created through the role management API, as opposed to the roles created by migrations or by the
+-- registration of a resource type, which the code or the resource type definition refer to by name
+ALTER TABLE role ADD COLUMN custom BOOLEAN NOT NULL DEFAULT FALSE;
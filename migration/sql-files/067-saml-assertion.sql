@@ -0,0 +1,8 @@
+-- the SAML assertions accepted by the service provider, which are kept until they expire so that none of them can be
+-- used twice
+CREATE TABLE saml_assertion (
+    assertion_id TEXT PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+CREATE INDEX idx_saml_assertion_expires_at ON saml_assertion (expires_at);
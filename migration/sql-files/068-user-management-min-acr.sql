@@ -0,0 +1,4 @@
+-- requiring a second factor for managing the users is left to each deployment, as the user administrators of an
+-- existing deployment have not enrolled one yet. It is enabled with:
+-- UPDATE resource_type_scope SET min_acr = '2' WHERE resource_type_scope_id = '4c1c4790-c86c-4937-9223-ac054f6e1251';
+UPDATE resource_type_scope SET min_acr = NULL WHERE resource_type_scope_id = '4c1c4790-c86c-4937-9223-ac054f6e1251';
@@ -0,0 +1,19 @@
+-- the content of the access reports is stored in chunks, so that it is neither built nor served in a single value
+CREATE TABLE access_report_chunk (
+    access_report_id uuid NOT NULL REFERENCES access_report(access_report_id) ON DELETE CASCADE,
+    seq integer NOT NULL,
+    content text NOT NULL,
+    PRIMARY KEY (access_report_id, seq)
+);
+
+ALTER TABLE access_report ADD COLUMN size bigint NOT NULL DEFAULT 0;
+ALTER TABLE access_report ADD COLUMN chunks integer NOT NULL DEFAULT 0;
+
+INSERT INTO access_report_chunk (access_report_id, seq, content)
+    SELECT access_report_id, 0, content FROM access_report WHERE content IS NOT NULL;
+UPDATE access_report SET size = octet_length(content), chunks = 1 WHERE content IS NOT NULL;
+
+ALTER TABLE access_report DROP COLUMN content;
+
+-- the finished reports are purged once their retention period is over
+CREATE INDEX idx_access_report_completed_at ON access_report (completed_at) WHERE completed_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- the invitation e-mails of a bulk import are queued and sent in batches by the invitation import worker
+ALTER TABLE invitation_import ADD COLUMN pending_emails text;
+ALTER TABLE invitation_import ADD COLUMN emails_sent_at timestamp with time zone;
+
+CREATE INDEX idx_invitation_import_pending_emails ON invitation_import (created_at) WHERE pending_emails IS NOT NULL;
@@ -0,0 +1,3 @@
+-- the name of a deleted role may be reused by a new role of the same resource type
+DROP INDEX IF EXISTS uq_role_resource_type_name;
+CREATE UNIQUE INDEX uq_role_resource_type_name ON role (resource_type_id, name) WHERE deleted_at IS NULL;
@@ -82,6 +82,77 @@ func NewSpaceInvitationEmail(identityID string, spaceName string, inviterName st
 	}
 }
 
+// NewOrganizationInvitationEmail creates a Message for the notification service in order to send an invitation e-mail to a user
+//
+// The following custom parameter values are required:
+//
+// organizationName - the name of the organization
+// inviter - the name of the user sending the invitation
+// roleNames - a comma-separated list of role names
+// acceptToken - the unique acceptance token value
+func NewOrganizationInvitationEmail(identityID string, organizationName string, inviterName string, roleNames string, acceptURL string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "invitation.org",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"organizationName": organizationName,
+			"inviter":          inviterName,
+			"roleNames":        roleNames,
+			"acceptURL":        acceptURL,
+		},
+	}
+}
+
+// NewEmailInvitationEmail creates a Message for the notification service in order to send an invitation e-mail to a
+// person who has no account yet
+//
+// The following custom parameter values are included:
+//
+// email - the email address of the invited person
+// targetName - the name of the organization, team or space to which the person is invited
+// inviter - the name of the user sending the invitation
+// roleNames - a comma-separated list of role names
+// acceptURL - the URL to follow in order to accept the invitation, once signed up
+// signUpURL - the URL to follow in order to sign up
+func NewEmailInvitationEmail(inviterID, targetID, email, targetName, inviterName, roleNames, acceptURL, signUpURL string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "invitation.email",
+		TargetID:    targetID,
+		UserID:      &inviterID,
+		Custom: map[string]interface{}{
+			"email":      email,
+			"targetName": targetName,
+			"inviter":    inviterName,
+			"roleNames":  roleNames,
+			"acceptURL":  acceptURL,
+			"signUpURL":  signUpURL,
+		},
+	}
+}
+
+// NewInvitationDeclinedEmail creates a Message for the notification service in order to inform the user who issued
+// an invitation that it was declined
+//
+// The following custom parameter values are included:
+//
+// targetName - the name of the organization, team, security group or resource the invitation was for
+// inviteeName - the username of the user who declined the invitation
+func NewInvitationDeclinedEmail(inviterID, targetID, targetName, inviteeName string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "invitation.declined",
+		TargetID:    targetID,
+		UserID:      &inviterID,
+		Custom: map[string]interface{}{
+			"targetName":  targetName,
+			"inviteeName": inviteeName,
+		},
+	}
+}
+
 // NewUserDeactivationEmail is a helper constructor which returns a message to inform the user that her
 // account will be deactivated soon
 func NewUserDeactivationEmail(identityID, email, deactivationDate string) Message {
@@ -96,3 +167,125 @@ func NewUserDeactivationEmail(identityID, email, deactivationDate string) Messag
 		},
 	}
 }
+
+// NewPasswordResetEmail is a helper constructor which returns a message containing the link the user should follow
+// in order to choose a new local password
+//
+// The following custom parameter values are included:
+//
+// resetURL - the URL of the password reset form, including the one-time reset code
+// expiryDate - the time after which the reset code is no longer valid
+func NewPasswordResetEmail(identityID, resetURL, expiryDate string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "user.password.reset",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"resetURL":   resetURL,
+			"expiryDate": expiryDate,
+		},
+	}
+}
+
+// NewImpersonationEmail is a helper constructor which returns a message to inform the user that an administrator
+// has impersonated them
+//
+// The following custom parameter values are included:
+//
+// adminName - the username of the administrator who impersonated the user
+// reason - the justification given by the administrator
+// expiryDate - the time after which the administrator can no longer act as the user
+func NewImpersonationEmail(identityID, adminName, reason, expiryDate string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "user.impersonation",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"adminName":  adminName,
+			"reason":     reason,
+			"expiryDate": expiryDate,
+		},
+	}
+}
+
+// NewRoleAssignmentExpiredEmail is a helper constructor which returns a message to inform an administrator of a
+// resource that a role assignment for the resource has expired and was removed
+//
+// The following custom parameter values are included:
+//
+// resourceID - the ID of the resource
+// resourceName - the name of the resource
+// roleName - the name of the role which was assigned
+// assigneeName - the username of the identity to which the role was assigned
+// expiryDate - the time at which the role assignment expired
+func NewRoleAssignmentExpiredEmail(identityID, resourceID, resourceName, roleName, assigneeName, expiryDate string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "role.assignment.expired",
+		TargetID:    resourceID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"resourceID":   resourceID,
+			"resourceName": resourceName,
+			"roleName":     roleName,
+			"assigneeName": assigneeName,
+			"expiryDate":   expiryDate,
+		},
+	}
+}
+
+// NewAccessRequestedEmail is a helper constructor which returns a message to inform an administrator of a resource
+// that a user requested a role for the resource
+//
+// The following custom parameter values are included:
+//
+// requestID - the ID of the access request
+// resourceName - the name of the resource
+// roleName - the name of the requested role
+// requesterName - the username of the user who requested the role
+// message - the message left by the user who requested the role
+func NewAccessRequestedEmail(identityID, requestID, resourceID, resourceName, roleName, requesterName, message string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "access.requested",
+		TargetID:    resourceID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"requestID":     requestID,
+			"resourceName":  resourceName,
+			"roleName":      roleName,
+			"requesterName": requesterName,
+			"message":       message,
+		},
+	}
+}
+
+// NewAccessRequestDecidedEmail is a helper constructor which returns a message to inform a user that the role they
+// requested for a resource was either granted or denied
+//
+// The following custom parameter values are included:
+//
+// resourceName - the name of the resource
+// roleName - the name of the requested role
+// approved - true if the role was granted, false if it was denied
+// reason - the reason given by the administrator who denied the request, if any
+func NewAccessRequestDecidedEmail(identityID, resourceID, resourceName, roleName string, approved bool, reason string) Message {
+	messageType := "access.request.denied"
+	if approved {
+		messageType = "access.request.approved"
+	}
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: messageType,
+		TargetID:    resourceID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"resourceName": resourceName,
+			"roleName":     roleName,
+			"approved":     approved,
+			"reason":       reason,
+		},
+	}
+}
@@ -54,6 +54,9 @@ func newInvitationWrapper(g *TestGraph, params []interface{}) interface{} {
 		case teamWrapper:
 			teamID := t.TeamID()
 			inviteTo = &teamID
+		case string:
+			// an email address, for invitations addressed to people without an account
+			w.invitation.Email = t
 		case bool:
 			w.invitation.Member = t
 		case *roleWrapper:
@@ -71,9 +74,9 @@ func newInvitationWrapper(g *TestGraph, params []interface{}) interface{} {
 	}
 
 	if identityID != nil {
-		w.invitation.IdentityID = *identityID
-	} else {
-		w.invitation.IdentityID = w.graph.CreateUser().Identity().ID
+		w.invitation.IdentityID = identityID
+	} else if w.invitation.Email == "" {
+		w.invitation.IdentityID = &w.graph.CreateUser().Identity().ID
 	}
 
 	// The invitation is either for an identity (e.g. org, team), or for a resource (e.g. space), but not both